@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// calls is the side effect that keeps tick from being optimized away.
+var calls int
+
+//go:noinline
+func tick() {
+	calls++
+}
+
+func main() {
+	n := 10000000
+	if len(os.Args) > 1 {
+		v, err := strconv.Atoi(os.Args[1])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		n = v
+	}
+
+	// The baseline loop does the same work inline, so the difference is
+	// the cost of the call and return alone.
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		calls++
+	}
+	baseline := time.Since(start)
+	calls = 0
+
+	start = time.Now()
+	for i := 0; i < n; i++ {
+		tick()
+	}
+	elapsed := time.Since(start)
+
+	fmt.Printf("calls = %d\n", calls)
+	fmt.Printf("ns/call = %.2f\n", float64(elapsed-baseline)/float64(n))
+}
@@ -14,7 +14,7 @@ def main():
 
     # Verify sorted
     ok = all(data[i] <= data[i + 1] for i in range(len(data) - 1))
-    print(f"sort({n}) sorted={ok}")
+    print(f"sort({n}) sorted={str(ok).lower()}")
 
 
 if __name__ == "__main__":
@@ -2,10 +2,13 @@
 """bench/generate_report.py — Benchmark dashboard / report generator.
 
 Reads CSV output from run_all.sh and generates a Markdown report with
-summary tables and comparative analysis.
+summary tables and comparative analysis. Every language is compared against
+a baseline language (Go unless --baseline says otherwise), and benchmarks
+whose runs vary too much to trust are marked unreliable.
 
 Usage:
-    python3 bench/generate_report.py results.csv [--output report.md]
+    python3 bench/generate_report.py results.csv [--output report.md] [--baseline LANG]
+        [--cv-threshold 0.10]
     python3 bench/generate_report.py results.csv --json
 """
 
@@ -17,6 +20,9 @@ import sys
 from collections import defaultdict
 from pathlib import Path
 
+DEFAULT_BASELINE = "go"
+DEFAULT_CV_THRESHOLD = 0.10
+
 
 def load_csv(path: str) -> list[dict]:
     """Load benchmark results from CSV."""
@@ -24,7 +30,7 @@ def load_csv(path: str) -> list[dict]:
     with open(path, newline="") as f:
         reader = csv.DictReader(f)
         for row in reader:
-            if row["time_ms"] != "ERROR":
+            if row["time_ms"] not in ("ERROR", "WRONG"):
                 row["time_ms"] = float(row["time_ms"])
                 rows.append(row)
     return rows
@@ -59,14 +65,122 @@ def find_fastest(bench_data: dict) -> str:
     return min(bench_data, key=lambda lang: bench_data[lang]["median"])
 
 
-def generate_markdown(data: dict, csv_path: str) -> str:
+def confidence(a: dict, b: dict) -> str:
+    """How far apart two aggregated results are.
+
+    "high" when the mean ± stdev ranges do not overlap, "low" when they do,
+    and "unknown" when either side has fewer than two runs (no stdev).
+    """
+    if a["runs"] < 2 or b["runs"] < 2:
+        return "unknown"
+    a_lo, a_hi = a["mean"] - a["stdev"], a["mean"] + a["stdev"]
+    b_lo, b_hi = b["mean"] - b["stdev"], b["mean"] + b["stdev"]
+    return "low" if a_lo <= b_hi and b_lo <= a_hi else "high"
+
+
+def describe_ratio(ratio: float | None, baseline: str) -> str:
+    """Render a ratio as "2.3x slower", "1.5x faster", or "same speed"."""
+    if ratio is None:
+        return f"no {baseline} result"
+    if round(ratio, 1) == 1.0:
+        return "same speed"
+    if ratio > 1:
+        return f"{ratio:.1f}x slower"
+    return f"{1 / ratio:.1f}x faster"
+
+
+def compare(data: dict, baseline: str = DEFAULT_BASELINE) -> list[dict]:
+    """Compare each language's median against the baseline language's.
+
+    Returns one entry per benchmark and non-baseline language, in benchmark
+    then language order. `ratio` is language median / baseline median, so
+    2.0 means twice as slow; it is None when the baseline has no usable
+    result for that benchmark.
+    """
+    comparisons = []
+    for bench, langs in sorted(data.items()):
+        base = langs.get(baseline)
+        for lang, stats in sorted(langs.items()):
+            if lang == baseline:
+                continue
+            if base is None or base["median"] <= 0:
+                ratio, conf = None, "unknown"
+            else:
+                ratio = stats["median"] / base["median"]
+                conf = confidence(stats, base)
+            comparisons.append(
+                {
+                    "benchmark": bench,
+                    "language": lang,
+                    "baseline": baseline,
+                    "ratio": ratio,
+                    "confidence": conf,
+                    "summary": describe_ratio(ratio, baseline),
+                }
+            )
+    return comparisons
+
+
+def coefficient_of_variation(stats: dict) -> float | None:
+    """stdev / mean of one aggregated result; None with fewer than two runs."""
+    if stats["runs"] < 2 or stats["mean"] <= 0:
+        return None
+    return stats["stdev"] / stats["mean"]
+
+
+def noisy_results(data: dict, cv_threshold: float) -> list[tuple[str, str, float]]:
+    """(benchmark, language, cv) for each result whose cv exceeds the threshold."""
+    noisy = []
+    for bench, langs in sorted(data.items()):
+        for lang, stats in sorted(langs.items()):
+            cv = coefficient_of_variation(stats)
+            if cv is not None and cv > cv_threshold:
+                noisy.append((bench, lang, cv))
+    return noisy
+
+
+def detect_flaky(data: dict, cv_threshold: float = DEFAULT_CV_THRESHOLD) -> list[str]:
+    """Names of benchmarks where any language's runs vary by more than
+    `cv_threshold` (as a coefficient of variation), in name order."""
+    return sorted({bench for bench, _, _ in noisy_results(data, cv_threshold)})
+
+
+def geometric_mean_ratio(comparisons: list[dict], language: str) -> float | None:
+    """Geometric mean of a language's ratios over benchmarks with a baseline."""
+    ratios = [
+        c["ratio"] for c in comparisons if c["language"] == language and c["ratio"]
+    ]
+    return statistics.geometric_mean(ratios) if ratios else None
+
+
+def generate_markdown(
+    data: dict,
+    csv_path: str,
+    baseline: str = DEFAULT_BASELINE,
+    cv_threshold: float = DEFAULT_CV_THRESHOLD,
+) -> str:
     """Generate a Markdown report from aggregated data."""
+    comparisons = compare(data, baseline)
+    flaky = set(detect_flaky(data, cv_threshold))
+
+    def label(bench: str) -> str:
+        return f"{bench} (unreliable)" if bench in flaky else bench
+
     lines = []
     lines.append("# Lumen Cross-Language Benchmark Report")
     lines.append("")
     lines.append(f"Source: `{csv_path}`")
     lines.append("")
 
+    lumen_overall = geometric_mean_ratio(comparisons, "lumen")
+    if lumen_overall is not None:
+        counted = sum(1 for c in comparisons if c["language"] == "lumen" and c["ratio"])
+        lines.append(
+            f"**lumen: {describe_ratio(lumen_overall, baseline)} than {baseline}** "
+            f"(geometric mean of medians over {counted} benchmarks)"
+        )
+        lines.append("")
+
     # All languages across all benchmarks
     all_langs = sorted({lang for bench in data.values() for lang in bench})
 
@@ -82,7 +196,7 @@ def generate_markdown(data: dict, csv_path: str) -> str:
 
     for bench, langs in sorted(data.items()):
         fastest = find_fastest(langs)
-        row = f"| {bench} |"
+        row = f"| {label(bench)} |"
         for lang in all_langs:
             if lang in langs:
                 val = langs[lang]["median"]
@@ -97,36 +211,55 @@ def generate_markdown(data: dict, csv_path: str) -> str:
     lines.append("")
 
     # --- Relative Performance ---
-    lines.append("## Relative Performance (vs C baseline)")
+    other_langs = [lang for lang in all_langs if lang != baseline]
+    lines.append(f"## Relative Performance (vs {baseline} baseline)")
     lines.append("")
-    lines.append("Values show how many times slower than C (1.0x = same speed).")
+    lines.append(
+        f"Median time of each language against {baseline}. A `~` marks a result "
+        "whose mean ± stdev overlaps the baseline's, so the difference may be noise."
+    )
     lines.append("")
-    header2 = "| Benchmark |" + "|".join(f" {lang} " for lang in all_langs) + "|"
-    sep2 = "|-----------|" + "|".join("------:" for _ in all_langs) + "|"
+    header2 = "| Benchmark |" + "|".join(f" {lang} " for lang in other_langs) + "|"
+    sep2 = "|-----------|" + "|".join("------:" for _ in other_langs) + "|"
     lines.append(header2)
     lines.append(sep2)
 
-    for bench, langs in sorted(data.items()):
-        c_median = langs.get("c", {}).get("median")
-        row = f"| {bench} |"
-        for lang in all_langs:
-            if lang in langs and c_median and c_median > 0:
-                ratio = langs[lang]["median"] / c_median
-                row += f" {ratio:.1f}x |"
-            elif lang in langs:
-                row += f" {langs[lang]['median']:.0f}ms |"
-            else:
+    by_cell = {(c["benchmark"], c["language"]): c for c in comparisons}
+    for bench in sorted(data):
+        row = f"| {label(bench)} |"
+        for lang in other_langs:
+            c = by_cell.get((bench, lang))
+            if c is None:
                 row += " - |"
+            else:
+                marker = "~" if c["confidence"] == "low" else ""
+                row += f" {marker}{c['summary']} |"
         lines.append(row)
 
     lines.append("")
 
+    # --- Unreliable Benchmarks ---
+    noisy = noisy_results(data, cv_threshold)
+    if noisy:
+        lines.append("## Unreliable Benchmarks")
+        lines.append("")
+        lines.append(
+            f"These results vary by more than {cv_threshold:.0%} of their mean across runs "
+            "(coefficient of variation), so small differences in them are not meaningful."
+        )
+        lines.append("")
+        lines.append("| Benchmark | Language | CV |")
+        lines.append("|-----------|----------|---:|")
+        for bench, lang, cv in noisy:
+            lines.append(f"| {bench} | {lang} | {cv:.0%} |")
+        lines.append("")
+
     # --- Detailed Results ---
     lines.append("## Detailed Results")
     lines.append("")
 
     for bench, langs in sorted(data.items()):
-        lines.append(f"### {bench}")
+        lines.append(f"### {label(bench)}")
         lines.append("")
         lines.append(
             "| Language | Median (ms) | Mean (ms) | Min (ms) | Max (ms) | Stdev | Runs |"
@@ -184,6 +317,18 @@ def main():
     parser.add_argument(
         "--json", action="store_true", help="Output JSON instead of Markdown"
     )
+    parser.add_argument(
+        "--baseline",
+        default=DEFAULT_BASELINE,
+        help=f"Language to compare against (default: {DEFAULT_BASELINE})",
+    )
+    parser.add_argument(
+        "--cv-threshold",
+        type=float,
+        default=DEFAULT_CV_THRESHOLD,
+        help="Coefficient of variation above which a benchmark is unreliable "
+        f"(default: {DEFAULT_CV_THRESHOLD})",
+    )
     args = parser.parse_args()
 
     rows = load_csv(args.csv_file)
@@ -194,9 +339,17 @@ def main():
     data = aggregate(rows)
 
     if args.json:
-        output = json.dumps(data, indent=2)
+        output = json.dumps(
+            {
+                "baseline": args.baseline,
+                "benchmarks": data,
+                "comparisons": compare(data, args.baseline),
+                "flaky": detect_flaky(data, args.cv_threshold),
+            },
+            indent=2,
+        )
     else:
-        output = generate_markdown(data, args.csv_file)
+        output = generate_markdown(data, args.csv_file, args.baseline, args.cv_threshold)
 
     if args.output:
         Path(args.output).write_text(output)
@@ -1,9 +1,13 @@
 #!/usr/bin/env bash
 # bench/run_all.sh — Cross-language benchmark runner
 # Compiles and runs each benchmark in each language, records wall-clock time.
-# Usage: bash bench/run_all.sh [--csv output.csv] [--runs N]
+# Benchmarks, their expected output, and their tags come from
+# cross-language/bench.toml; a run that does not print the benchmark's
+# checksum line is recorded as WRONG.
+# Usage: bash bench/run_all.sh [--csv output.csv] [--runs N] [--tag TAG]
 #
-# Requires: gcc, go, python3, npx (for ts-node/tsx), zig, cargo (for Lumen)
+# Requires: python3 3.11+ (to read bench.toml)
+# Optional: gcc, go, npx (for ts-node/tsx), zig, cargo (for Lumen)
 # Missing compilers are skipped gracefully.
 
 set -euo pipefail
@@ -15,16 +19,19 @@ BUILD_DIR="$SCRIPT_DIR/.build"
 
 RUNS=3
 CSV_FILE=""
+TAG=""
 
 # Parse arguments
 while [[ $# -gt 0 ]]; do
   case "$1" in
     --csv)    CSV_FILE="$2"; shift 2 ;;
     --runs)   RUNS="$2"; shift 2 ;;
+    --tag)    TAG="$2"; shift 2 ;;
     -h|--help)
-      echo "Usage: $0 [--csv output.csv] [--runs N]"
+      echo "Usage: $0 [--csv output.csv] [--runs N] [--tag TAG]"
       echo "  --csv FILE   Write results to CSV file"
       echo "  --runs N     Number of runs per benchmark (default: 3)"
+      echo "  --tag TAG    Only run benchmarks tagged TAG (compute-bound, memory-bound, gc)"
       exit 0
       ;;
     *) echo "Unknown option: $1"; exit 1 ;;
@@ -52,25 +59,47 @@ echo "Runs per benchmark: $RUNS"
 echo "Compilers: gcc=$HAS_GCC go=$HAS_GO rust=$HAS_RUST zig=$HAS_ZIG python3=$HAS_PY ts=$HAS_TS lumen=$HAS_LUMEN"
 echo ""
 
-BENCHMARKS=("fibonacci" "json_parse" "string_ops" "tree" "sort")
-
-# File mapping: benchmark -> filename prefix
-declare -A FILE_MAP=(
-  [fibonacci]="fib"
-  [json_parse]="json_parse"
-  [string_ops]="string_ops"
-  [tree]="tree"
-  [sort]="sort"
-)
+# Read the manifest as "name<TAB>file<TAB>tags<TAB>checksum" rows
+MANIFEST="$CROSS_DIR/bench.toml"
+MANIFEST_ROWS=$(python3 - "$MANIFEST" <<'PY'
+import sys
+import tomllib
+
+with open(sys.argv[1], "rb") as f:
+    manifest = tomllib.load(f)
+for name, bench in sorted(manifest.items()):
+    print("\t".join([name, bench["file"], ",".join(bench["tags"]), bench["checksum"]]))
+PY
+) || { echo "Cannot read $MANIFEST (needs python3 3.11+)"; exit 1; }
+
+BENCHMARKS=()
+declare -A FILE_MAP=()  # benchmark -> filename prefix
+declare -A TAGS=()      # benchmark -> comma-separated tags
+declare -A CHECKSUM=()  # benchmark -> line every correct run prints
+while IFS=$'\t' read -r name file tags checksum; do
+  if [ -n "$TAG" ] && [[ ",$tags," != *",$TAG,"* ]]; then
+    continue
+  fi
+  BENCHMARKS+=("$name")
+  FILE_MAP[$name]="$file"
+  TAGS[$name]="$tags"
+  CHECKSUM[$name]="$checksum"
+done <<< "$MANIFEST_ROWS"
+
+if [ ${#BENCHMARKS[@]} -eq 0 ]; then
+  echo "No benchmarks tagged '$TAG' in $MANIFEST"
+  exit 1
+fi
 
-# Results array: "benchmark,language,run,time_ms"
+# Results array: "benchmark,language,run,time_ms"; time_ms is ERROR or WRONG on failure
 RESULTS=()
+OUTPUT_FILE="$BUILD_DIR/last_output.txt"
 
-# Time a command, return elapsed milliseconds
+# Time a command, return elapsed milliseconds; its output goes to $OUTPUT_FILE
 time_ms() {
   local start end elapsed
   start=$(date +%s%N 2>/dev/null || python3 -c 'import time; print(int(time.time()*1e9))')
-  "$@" > /dev/null 2>&1
+  "$@" > "$OUTPUT_FILE" 2>&1
   local exit_code=$?
   end=$(date +%s%N 2>/dev/null || python3 -c 'import time; print(int(time.time()*1e9))')
   elapsed=$(( (end - start) / 1000000 ))
@@ -86,9 +115,14 @@ run_benchmark() {
   for run in $(seq 1 "$RUNS"); do
     local ms
     ms=$(time_ms bash -c "$cmd") || ms="ERROR"
+    if [ "$ms" != "ERROR" ] && ! grep -qxF -- "${CHECKSUM[$bench]}" "$OUTPUT_FILE"; then
+      ms="WRONG"
+    fi
     RESULTS+=("$bench,$lang,$run,$ms")
     if [ "$ms" = "ERROR" ]; then
       printf "  %-12s %-10s run %d: ERROR\n" "$bench" "$lang" "$run"
+    elif [ "$ms" = "WRONG" ]; then
+      printf "  %-12s %-10s run %d: WRONG (expected line: %s)\n" "$bench" "$lang" "$run" "${CHECKSUM[$bench]}"
     else
       printf "  %-12s %-10s run %d: %s ms\n" "$bench" "$lang" "$run" "$ms"
     fi
@@ -151,9 +185,10 @@ done
 
 # Write CSV if requested
 if [ -n "$CSV_FILE" ]; then
-  echo "benchmark,language,run,time_ms" > "$CSV_FILE"
+  echo "benchmark,language,run,time_ms,tags" > "$CSV_FILE"
   for row in "${RESULTS[@]}"; do
-    echo "$row" >> "$CSV_FILE"
+    bench="${row%%,*}"
+    echo "$row,${TAGS[$bench]//,/;}" >> "$CSV_FILE"
   done
   echo "Results written to $CSV_FILE"
 fi
@@ -167,27 +202,38 @@ for lang in "${LANGS[@]}"; do
 done
 echo ""
 
-for bench in "${BENCHMARKS[@]}"; do
-  printf "%-14s" "$bench"
-  for lang in "${LANGS[@]}"; do
-    # Collect times for this bench+lang
-    times=()
-    for row in "${RESULTS[@]}"; do
-      IFS=',' read -r rb rl rr rt <<< "$row"
-      if [ "$rb" = "$bench" ] && [ "$rl" = "$lang" ] && [ "$rt" != "ERROR" ]; then
-        times+=("$rt")
+# Grouped by tag; a benchmark with several tags appears under each
+for group in "compute-bound" "memory-bound" "gc"; do
+  header_printed=false
+  for bench in "${BENCHMARKS[@]}"; do
+    if [[ ",${TAGS[$bench]}," != *",$group,"* ]]; then
+      continue
+    fi
+    if ! $header_printed; then
+      echo "[$group]"
+      header_printed=true
+    fi
+    printf "%-14s" "$bench"
+    for lang in "${LANGS[@]}"; do
+      # Collect times for this bench+lang
+      times=()
+      for row in "${RESULTS[@]}"; do
+        IFS=',' read -r rb rl rr rt <<< "$row"
+        if [ "$rb" = "$bench" ] && [ "$rl" = "$lang" ] && [ "$rt" != "ERROR" ] && [ "$rt" != "WRONG" ]; then
+          times+=("$rt")
+        fi
+      done
+      if [ ${#times[@]} -eq 0 ]; then
+        printf "%-12s" "-"
+      else
+        # Sort and take median
+        sorted=($(printf '%s\n' "${times[@]}" | sort -n))
+        mid=$(( ${#sorted[@]} / 2 ))
+        printf "%-12s" "${sorted[$mid]}"
       fi
     done
-    if [ ${#times[@]} -eq 0 ]; then
-      printf "%-12s" "-"
-    else
-      # Sort and take median
-      sorted=($(printf '%s\n' "${times[@]}" | sort -n))
-      mid=$(( ${#sorted[@]} / 2 ))
-      printf "%-12s" "${sorted[$mid]}"
-    fi
+    echo ""
   done
-  echo ""
 done
 
 echo ""
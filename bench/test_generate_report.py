@@ -0,0 +1,135 @@
+#!/usr/bin/env python3
+"""Tests for bench/generate_report.py.
+
+Run with: python3 -m unittest bench/test_generate_report.py
+"""
+
+import sys
+import unittest
+from pathlib import Path
+
+sys.path.insert(0, str(Path(__file__).resolve().parent))
+
+import generate_report  # noqa: E402
+
+
+def rows(bench: str, lang: str, *times: float) -> list[dict]:
+    return [
+        {"benchmark": bench, "language": lang, "run": str(i), "time_ms": t}
+        for i, t in enumerate(times, 1)
+    ]
+
+
+class CompareTests(unittest.TestCase):
+    def test_ratio_is_median_over_baseline_median(self):
+        data = generate_report.aggregate(
+            rows("fib", "go", 100, 110, 90)
+            + rows("fib", "lumen", 230, 240, 220)
+            + rows("fib", "rust", 50, 49, 51)
+        )
+        got = {c["language"]: c for c in generate_report.compare(data)}
+        self.assertEqual(set(got), {"lumen", "rust"})
+        self.assertAlmostEqual(got["lumen"]["ratio"], 2.3)
+        self.assertEqual(got["lumen"]["summary"], "2.3x slower")
+        self.assertAlmostEqual(got["rust"]["ratio"], 0.5)
+        self.assertEqual(got["rust"]["summary"], "2.0x faster")
+        self.assertEqual(got["lumen"]["baseline"], "go")
+
+    def test_other_baseline(self):
+        data = generate_report.aggregate(rows("fib", "go", 100) + rows("fib", "c", 25))
+        (c,) = generate_report.compare(data, baseline="c")
+        self.assertEqual((c["language"], c["ratio"], c["summary"]), ("go", 4.0, "4.0x slower"))
+
+    def test_missing_baseline_has_no_ratio(self):
+        data = generate_report.aggregate(
+            rows("fib", "go", 100)
+            + rows("fib", "lumen", 300)
+            + rows("tree", "lumen", 80)
+            + rows("tree", "python", 400)
+        )
+        tree = [c for c in generate_report.compare(data) if c["benchmark"] == "tree"]
+        self.assertEqual([c["language"] for c in tree], ["lumen", "python"])
+        for c in tree:
+            self.assertIsNone(c["ratio"])
+            self.assertEqual(c["confidence"], "unknown")
+            self.assertEqual(c["summary"], "no go result")
+        # Benchmarks without a baseline stay out of the headline mean.
+        comparisons = generate_report.compare(data)
+        self.assertAlmostEqual(generate_report.geometric_mean_ratio(comparisons, "lumen"), 3.0)
+
+    def test_confidence_from_stdev_overlap(self):
+        data = generate_report.aggregate(
+            rows("fib", "go", 100, 102, 98)
+            + rows("fib", "lumen", 300, 310, 290)
+            + rows("fib", "rust", 99, 104, 101)
+            + rows("fib", "zig", 97)
+        )
+        got = {c["language"]: c["confidence"] for c in generate_report.compare(data)}
+        self.assertEqual(got, {"lumen": "high", "rust": "low", "zig": "unknown"})
+
+    def test_describe_ratio(self):
+        describe = generate_report.describe_ratio
+        self.assertEqual(describe(1.04, "go"), "same speed")
+        self.assertEqual(describe(1.25, "go"), "1.2x slower")
+        self.assertEqual(describe(0.25, "go"), "4.0x faster")
+        self.assertEqual(describe(None, "c"), "no c result")
+
+    def test_markdown_headline_and_noise_marker(self):
+        data = generate_report.aggregate(
+            rows("fib", "go", 100, 102, 98)
+            + rows("fib", "lumen", 200, 210, 190)
+            + rows("tree", "go", 10, 11, 9)
+            + rows("tree", "lumen", 80, 82, 78)
+            + rows("tree", "rust", 10, 12, 8)
+        )
+        report = generate_report.generate_markdown(data, "results.csv")
+        self.assertIn(
+            "**lumen: 4.0x slower than go** (geometric mean of medians over 2 benchmarks)",
+            report,
+        )
+        self.assertIn("## Relative Performance (vs go baseline)", report)
+        self.assertIn("| fib | 2.0x slower | - |", report)
+        self.assertIn("| tree (unreliable) | 8.0x slower | ~same speed |", report)
+
+
+class FlakyTests(unittest.TestCase):
+    def data(self) -> dict:
+        return generate_report.aggregate(
+            # cv ≈ 0.015: steady
+            rows("fib", "go", 100, 102, 98, 101, 99)
+            + rows("fib", "lumen", 300, 305, 295, 302, 298)
+            # cv ≈ 0.51 for lumen only: one noisy language flags the benchmark
+            + rows("tree", "go", 50, 51, 49)
+            + rows("tree", "lumen", 80, 200, 90, 85)
+            # cv ≈ 0.075 for both: noisy only under a tighter threshold
+            + rows("sort", "go", 100, 110, 95)
+            + rows("sort", "c", 40, 44, 38)
+            # A single run has no variance to judge
+            + rows("nbody", "go", 500)
+        )
+
+    def test_flags_high_variance_benchmarks(self):
+        self.assertEqual(generate_report.detect_flaky(self.data()), ["tree"])
+
+    def test_threshold_controls_what_is_flagged(self):
+        data = self.data()
+        self.assertEqual(generate_report.detect_flaky(data, 0.05), ["sort", "tree"])
+        self.assertEqual(generate_report.detect_flaky(data, 0.01), ["fib", "sort", "tree"])
+        self.assertEqual(generate_report.detect_flaky(data, 1.0), [])
+
+    def test_report_marks_unreliable_benchmarks(self):
+        report = generate_report.generate_markdown(self.data(), "results.csv")
+        self.assertIn("| tree (unreliable) |", report)
+        self.assertIn("### tree (unreliable)", report)
+        self.assertNotIn("fib (unreliable)", report)
+        self.assertIn("## Unreliable Benchmarks", report)
+        self.assertIn("| tree | lumen | 51% |", report)
+
+    def test_steady_report_has_no_unreliable_section(self):
+        data = generate_report.aggregate(rows("fib", "go", 100, 101, 99))
+        report = generate_report.generate_markdown(data, "results.csv")
+        self.assertNotIn("Unreliable", report)
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,90 @@
+//! Criterion benchmarks for the VM's record field-read inline caches.
+//!
+//! Runs the n-body energy sum over a list of `Body` records, seven field
+//! reads per body and six per pair, with the caches on and off, so
+//! `field_reads/cached/nbody_energy` and `field_reads/uncached/nbody_energy`
+//! sit side by side in one report:
+//!
+//! ```text
+//! cargo bench -p lumen-bench --bench field_cache
+//! ```
+
+use criterion::{black_box, criterion_group, criterion_main, BenchmarkId, Criterion};
+use lumen_compiler::compiler::lir::LirModule;
+
+const NBODY_ENERGY: &str = r#"
+record Body
+  x: Float
+  y: Float
+  z: Float
+  vx: Float
+  vy: Float
+  vz: Float
+  mass: Float
+end
+
+cell energy(bodies: list[Body]) -> Float
+  var e = 0.0
+  var i = 0
+  while i < 5
+    let b = bodies[i]
+    e = e + 0.5 * b.mass * (b.vx * b.vx + b.vy * b.vy + b.vz * b.vz)
+    var j = i + 1
+    while j < 5
+      let o = bodies[j]
+      let dx = b.x - o.x
+      let dy = b.y - o.y
+      let dz = b.z - o.z
+      e = e - b.mass * o.mass / sqrt(dx * dx + dy * dy + dz * dz)
+      j = j + 1
+    end
+    i = i + 1
+  end
+  return e
+end
+
+cell main() -> Float
+  let pi = 3.141592653589793
+  let sm = 4.0 * pi * pi
+  let dpy = 365.24
+  let bodies = [
+    Body(x: 0.0, y: 0.0, z: 0.0, vx: 0.0, vy: 0.0, vz: 0.0, mass: sm),
+    Body(x: 4.84143144246472090, y: 0.0 - 1.16032004402742839, z: 0.0 - 0.103622044471123109, vx: 1.66007664274403694e-03 * dpy, vy: 7.69901118419740425e-03 * dpy, vz: 0.0 - 6.90460016972063023e-05 * dpy, mass: 9.54791938424326609e-04 * sm),
+    Body(x: 8.34336671824457987, y: 4.12479856412430479, z: 0.0 - 0.403523417114321381, vx: 0.0 - 2.76742510726862411e-03 * dpy, vy: 4.99852801234917238e-03 * dpy, vz: 2.30417297573763929e-05 * dpy, mass: 2.85885980666130812e-04 * sm),
+    Body(x: 12.8943695621391310, y: 0.0 - 15.1111514016986312, z: 0.0 - 0.223307578892655734, vx: 2.96460137564761618e-03 * dpy, vy: 2.37847173959480950e-03 * dpy, vz: 0.0 - 2.96589568540237556e-05 * dpy, mass: 4.36624404335156298e-05 * sm),
+    Body(x: 15.3796971148509165, y: 0.0 - 25.9193146099879641, z: 0.179258772950371181, vx: 2.68067772490389322e-03 * dpy, vy: 1.62824170038242295e-03 * dpy, vz: 0.0 - 9.51592254519715870e-05 * dpy, mass: 5.15138902046611451e-05 * sm)
+  ]
+  var total = 0.0
+  for s in range(0, 2000)
+    total = total + energy(bodies)
+  end
+  return total
+end
+"#;
+
+fn run_program(module: &LirModule, cached: bool) {
+    let mut vm = lumen_vm::vm::VM::new();
+    vm.set_field_cache(cached);
+    vm.load(module.clone());
+    vm.execute("main", vec![]).expect("main should execute");
+}
+
+fn bench_field_reads(c: &mut Criterion) {
+    let module = lumen_compiler::compile_raw(NBODY_ENERGY).expect("nbody program should compile");
+
+    let mut group = c.benchmark_group("field_reads");
+    group.sample_size(20);
+    for (label, cached) in [("cached", true), ("uncached", false)] {
+        group.bench_with_input(
+            BenchmarkId::new(label, "nbody_energy"),
+            &module,
+            |bench, m| {
+                bench.iter(|| run_program(black_box(m), cached));
+            },
+        );
+    }
+    group.finish();
+}
+
+criterion_group!(benches, bench_field_reads);
+criterion_main!(benches);
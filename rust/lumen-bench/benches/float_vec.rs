@@ -0,0 +1,181 @@
+//! Criterion benchmarks for the bulk float64 kernels behind `std.math`'s
+//! `vec_add`, `vec_mul`, and `vec_triad`.
+//!
+//! `triad/kernel` measures the STREAM triad `dst[i] = a[i] + s * b[i]` on
+//! raw slices, scalar loop against the dispatching kernel (AVX where the CPU
+//! has it), reporting bytes moved per second. `triad/lumen` runs the same
+//! triad as a Lumen loop and as one `float_vec_triad` call, which is where a
+//! program sees the difference.
+//!
+//! `rsqrt/kernel` and `nbody_mag/lumen` do the same for `1 / sqrt(x)`: the
+//! latter computes the n-body magnitude `dt / (d2 * sqrt(d2))` for every pair
+//! distance in a loop, and as `dt * r * r * r` with `r` from one
+//! `float_vec_rsqrt` call and the products from `float_vec_mul`.
+//!
+//! ```text
+//! cargo bench -p lumen-bench --bench float_vec
+//! ```
+
+use criterion::{black_box, criterion_group, criterion_main, BenchmarkId, Criterion, Throughput};
+use lumen_compiler::compiler::lir::LirModule;
+use lumen_vm::vecops;
+
+fn inputs(n: usize) -> (Vec<f64>, Vec<f64>) {
+    let a = (0..n).map(|i| i as f64 * 0.5).collect();
+    let b = (0..n).map(|i| 1.0 / (i as f64 + 1.0)).collect();
+    (a, b)
+}
+
+fn bench_triad_kernel(c: &mut Criterion) {
+    let mut group = c.benchmark_group("triad/kernel");
+    for n in [1 << 10, 1 << 16, 1 << 22] {
+        let (a, b) = inputs(n);
+        let mut dst = vec![0.0; n];
+        // Two reads and one write of eight bytes per element.
+        group.throughput(Throughput::Bytes((24 * n) as u64));
+        group.bench_with_input(BenchmarkId::new("scalar", n), &n, |bench, _| {
+            bench.iter(|| vecops::scalar::triad(black_box(&mut dst), &a, &b, black_box(3.0)));
+        });
+        group.bench_with_input(
+            BenchmarkId::new(vecops::kernel_name(), n),
+            &n,
+            |bench, _| {
+                bench.iter(|| vecops::triad(black_box(&mut dst), &a, &b, black_box(3.0)));
+            },
+        );
+    }
+    group.finish();
+}
+
+fn bench_rsqrt_kernel(c: &mut Criterion) {
+    let mut group = c.benchmark_group("rsqrt/kernel");
+    for n in [1 << 10, 1 << 16] {
+        let (a, _) = inputs(n);
+        let a: Vec<f64> = a.iter().map(|x| x + 1.0).collect();
+        let mut dst = vec![0.0; n];
+        group.throughput(Throughput::Elements(n as u64));
+        group.bench_with_input(BenchmarkId::new("scalar", n), &n, |bench, _| {
+            bench.iter(|| vecops::scalar::rsqrt(black_box(&mut dst), &a));
+        });
+        group.bench_with_input(
+            BenchmarkId::new(vecops::kernel_name(), n),
+            &n,
+            |bench, _| {
+                bench.iter(|| vecops::rsqrt(black_box(&mut dst), &a));
+            },
+        );
+    }
+    group.finish();
+}
+
+const TRIAD_LOOP: &str = r#"
+cell main() -> Float
+  let n = 10000
+  let mut a = []
+  let mut b = []
+  let mut dst = []
+  for i in range(0, n)
+    a = append(a, float(i) * 0.5)
+    b = append(b, 1.0 / float(i + 1))
+    dst = append(dst, 0.0)
+  end
+  for i in range(0, n)
+    dst[i] = a[i] + 3.0 * b[i]
+  end
+  return dst[n - 1]
+end
+"#;
+
+const TRIAD_BUILTIN: &str = r#"
+cell main() -> Float
+  let n = 10000
+  let mut a = []
+  let mut b = []
+  let mut dst = []
+  for i in range(0, n)
+    a = append(a, float(i) * 0.5)
+    b = append(b, 1.0 / float(i + 1))
+    dst = append(dst, 0.0)
+  end
+  dst = float_vec_triad(dst, a, b, 3.0)
+  return dst[n - 1]
+end
+"#;
+
+const NBODY_MAG_LOOP: &str = r#"
+cell main() -> Float
+  let n = 10000
+  let dt = 0.01
+  let mut d2 = []
+  let mut mag = []
+  for i in range(0, n)
+    d2 = append(d2, 1.0 + float(i) * 0.25)
+    mag = append(mag, 0.0)
+  end
+  for i in range(0, n)
+    let dist = sqrt(d2[i])
+    mag[i] = dt / (d2[i] * dist)
+  end
+  return mag[n - 1]
+end
+"#;
+
+const NBODY_MAG_BUILTIN: &str = r#"
+cell main() -> Float
+  let n = 10000
+  let dt = 0.01
+  let mut d2 = []
+  let mut mag = []
+  let mut zeros = []
+  for i in range(0, n)
+    d2 = append(d2, 1.0 + float(i) * 0.25)
+    mag = append(mag, 0.0)
+    zeros = append(zeros, 0.0)
+  end
+  let r = float_vec_rsqrt(mag, d2)
+  mag = float_vec_mul(mag, r, r)
+  mag = float_vec_mul(mag, mag, r)
+  mag = float_vec_triad(mag, zeros, mag, dt)
+  return mag[n - 1]
+end
+"#;
+
+fn run_program(module: &LirModule) {
+    let mut vm = lumen_vm::vm::VM::new();
+    vm.load(module.clone());
+    vm.execute("main", vec![]).expect("main should execute");
+}
+
+fn bench_triad_lumen(c: &mut Criterion) {
+    let mut group = c.benchmark_group("triad/lumen");
+    group.sample_size(20);
+    for (label, source) in [("loop", TRIAD_LOOP), ("builtin", TRIAD_BUILTIN)] {
+        let module = lumen_compiler::compile_raw(source).expect("triad program should compile");
+        group.bench_with_input(BenchmarkId::new("run", label), &module, |bench, m| {
+            bench.iter(|| run_program(black_box(m)));
+        });
+    }
+    group.finish();
+}
+
+fn bench_nbody_mag_lumen(c: &mut Criterion) {
+    let mut group = c.benchmark_group("nbody_mag/lumen");
+    group.sample_size(20);
+    for (label, source) in [("loop", NBODY_MAG_LOOP), ("builtin", NBODY_MAG_BUILTIN)] {
+        let module =
+            lumen_compiler::compile_raw(source).expect("nbody program should compile");
+        group.bench_with_input(BenchmarkId::new("run", label), &module, |bench, m| {
+            bench.iter(|| run_program(black_box(m)));
+        });
+    }
+    group.finish();
+}
+
+criterion_group!(
+    benches,
+    bench_triad_kernel,
+    bench_triad_lumen,
+    bench_rsqrt_kernel,
+    bench_nbody_mag_lumen
+);
+criterion_main!(benches);
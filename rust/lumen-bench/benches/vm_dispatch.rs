@@ -0,0 +1,96 @@
+//! Criterion benchmarks for the VM's instruction dispatch.
+//!
+//! Runs the call-heavy cross-language programs (recursive fibonacci and the
+//! binary-tree checksum) end to end in the interpreter, once per dispatch
+//! strategy, so `dispatch/match/<program>` and `dispatch/table/<program>`
+//! sit side by side in one report:
+//!
+//! ```text
+//! cargo bench -p lumen-bench --bench vm_dispatch
+//! ```
+
+use criterion::{black_box, criterion_group, criterion_main, BenchmarkId, Criterion};
+use lumen_compiler::compiler::lir::LirModule;
+use lumen_vm::vm::DispatchMode;
+use std::fs;
+use std::path::PathBuf;
+
+/// Locate bench/cross-language. Criterion runs from the crate root,
+/// so we check a few relative paths.
+fn cross_language_dir() -> PathBuf {
+    let candidates = [
+        PathBuf::from("../../bench/cross-language"),
+        PathBuf::from("bench/cross-language"),
+        PathBuf::from("../bench/cross-language"),
+    ];
+    for c in &candidates {
+        if c.is_dir() {
+            return c.clone();
+        }
+    }
+    PathBuf::from("../../bench/cross-language")
+}
+
+/// Compile a cross-language program, with each `(from, to)` rewrite applied
+/// to its source first. Returns None if it is missing.
+fn load_program(name: &str, rewrites: &[(&str, &str)]) -> Option<LirModule> {
+    let mut source = fs::read_to_string(cross_language_dir().join(name)).ok()?;
+    for &(from, to) in rewrites {
+        assert!(
+            source.contains(from),
+            "{} no longer contains {}",
+            name,
+            from
+        );
+        source = source.replace(from, to);
+    }
+    Some(lumen_compiler::compile_raw(&source).expect("benchmark program should compile"))
+}
+
+fn run_program(module: &LirModule, args: &[&str], mode: DispatchMode) -> Vec<String> {
+    let mut vm = lumen_vm::vm::VM::new();
+    vm.set_dispatch_mode(mode);
+    vm.load(module.clone());
+    vm.set_program_args(args.iter().copied());
+    vm.execute("main", vec![]).expect("main should execute");
+    vm.output
+}
+
+fn bench_dispatch(c: &mut Criterion) {
+    // tree.lm takes no arguments, so its depth is set in the source
+    let programs: [(&str, &str, &[&str], &[(&str, &str)]); 2] = [
+        ("fibonacci", "fibonacci/fib.lm", &["25"], &[]),
+        (
+            "tree",
+            "tree/tree.lm",
+            &[],
+            &[("build_tree(18)", "build_tree(14)")],
+        ),
+    ];
+
+    let mut group = c.benchmark_group("dispatch");
+    group.sample_size(20);
+
+    for (label, file, args, rewrites) in &programs {
+        let module = match load_program(file, rewrites) {
+            Some(m) => m,
+            None => {
+                eprintln!("Skipping dispatch/{}: {} not found", label, file);
+                continue;
+            }
+        };
+        for (strategy, mode) in [
+            ("match", DispatchMode::Match),
+            ("table", DispatchMode::Table),
+        ] {
+            group.bench_with_input(BenchmarkId::new(strategy, label), &module, |b, m| {
+                b.iter(|| run_program(black_box(m), args, mode));
+            });
+        }
+    }
+
+    group.finish();
+}
+
+criterion_group!(benches, bench_dispatch);
+criterion_main!(benches);
@@ -0,0 +1,3 @@
+//! Shared pieces of the Lumen benchmark harness.
+
+pub mod manifest;
@@ -0,0 +1,177 @@
+//! The cross-language benchmark manifest, `bench/cross-language/bench.toml`.
+//!
+//! Each table names a benchmark directory and declares its default problem
+//! size, the line of output every correct run prints, and the tags the
+//! runner groups results by.
+
+use serde::Deserialize;
+use std::collections::BTreeMap;
+use std::fs;
+use std::path::Path;
+
+/// Tags a benchmark may carry.
+pub const KNOWN_TAGS: &[&str] = &["compute-bound", "memory-bound", "gc"];
+
+/// Every benchmark in the manifest, keyed by directory name.
+#[derive(Debug, Clone, PartialEq, Deserialize)]
+#[serde(transparent)]
+pub struct Manifest {
+    pub benchmarks: BTreeMap<String, Benchmark>,
+}
+
+/// One benchmark's manifest entry.
+#[derive(Debug, Clone, PartialEq, Deserialize)]
+#[serde(deny_unknown_fields)]
+pub struct Benchmark {
+    /// File name, without extension, of each language's implementation.
+    pub file: String,
+    /// Problem size every implementation uses by default.
+    pub size: u64,
+    /// Starting value of the benchmark's deterministic generator, if any.
+    #[serde(default)]
+    pub seed: Option<u64>,
+    /// A line of stdout every correct run prints.
+    pub checksum: String,
+    pub tags: Vec<String>,
+}
+
+impl Manifest {
+    /// Names of the benchmarks carrying `tag`, in name order.
+    pub fn tagged(&self, tag: &str) -> Vec<&str> {
+        self.benchmarks
+            .iter()
+            .filter(|(_, b)| b.tags.iter().any(|t| t == tag))
+            .map(|(name, _)| name.as_str())
+            .collect()
+    }
+}
+
+/// Errors from reading or validating a manifest.
+#[derive(Debug, Clone, PartialEq)]
+pub enum ManifestError {
+    /// The file could not be read.
+    Io(String),
+    /// The file is not a valid manifest.
+    Parse(String),
+    /// An entry is well-formed but unusable.
+    Invalid { benchmark: String, message: String },
+}
+
+impl std::fmt::Display for ManifestError {
+    fn fmt(&self, f: &mut std::fmt::Formatter<'_>) -> std::fmt::Result {
+        match self {
+            Self::Io(e) => write!(f, "I/O error: {}", e),
+            Self::Parse(e) => write!(f, "invalid manifest: {}", e),
+            Self::Invalid { benchmark, message } => {
+                write!(f, "benchmark '{}': {}", benchmark, message)
+            }
+        }
+    }
+}
+
+impl std::error::Error for ManifestError {}
+
+/// Read and validate the manifest at `path`.
+pub fn load_manifest(path: &Path) -> Result<Manifest, ManifestError> {
+    let content = fs::read_to_string(path)
+        .map_err(|e| ManifestError::Io(format!("cannot read {}: {}", path.display(), e)))?;
+    parse_manifest(&content)
+}
+
+/// Parse and validate manifest text.
+pub fn parse_manifest(content: &str) -> Result<Manifest, ManifestError> {
+    let manifest: Manifest =
+        toml::from_str(content).map_err(|e| ManifestError::Parse(e.to_string()))?;
+    for (name, bench) in &manifest.benchmarks {
+        let invalid = |message: String| ManifestError::Invalid {
+            benchmark: name.clone(),
+            message,
+        };
+        if bench.file.is_empty() {
+            return Err(invalid("file is empty".to_string()));
+        }
+        if bench.checksum.trim().is_empty() {
+            return Err(invalid("checksum is empty".to_string()));
+        }
+        if bench.checksum.contains('\n') {
+            return Err(invalid("checksum must be a single line".to_string()));
+        }
+        if bench.tags.is_empty() {
+            return Err(invalid("needs at least one tag".to_string()));
+        }
+        if let Some(tag) = bench
+            .tags
+            .iter()
+            .find(|t| !KNOWN_TAGS.contains(&t.as_str()))
+        {
+            return Err(invalid(format!(
+                "unknown tag '{}' (expected one of {})",
+                tag,
+                KNOWN_TAGS.join(", ")
+            )));
+        }
+    }
+    Ok(manifest)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn parses_entries_and_groups_by_tag() {
+        let manifest = parse_manifest(
+            r#"
+[sort]
+file = "sort"
+size = 1000
+seed = 42
+checksum = "sort(1000) sorted=true"
+tags = ["memory-bound", "gc"]
+
+[fibonacci]
+file = "fib"
+size = 35
+checksum = "fib(35) = 9227465"
+tags = ["compute-bound"]
+"#,
+        )
+        .unwrap();
+        assert_eq!(manifest.benchmarks["sort"].seed, Some(42));
+        assert_eq!(manifest.benchmarks["fibonacci"].seed, None);
+        assert_eq!(manifest.benchmarks["fibonacci"].file, "fib");
+        assert_eq!(manifest.tagged("gc"), ["sort"]);
+        assert_eq!(manifest.tagged("compute-bound"), ["fibonacci"]);
+    }
+
+    #[test]
+    fn rejects_unknown_tags_and_fields() {
+        let err = parse_manifest(
+            "[tree]\nfile = \"tree\"\nsize = 18\nchecksum = \"Checksum: 262144\"\ntags = [\"io\"]\n",
+        )
+        .unwrap_err();
+        assert_eq!(
+            err.to_string(),
+            "benchmark 'tree': unknown tag 'io' (expected one of compute-bound, memory-bound, gc)"
+        );
+
+        let err = parse_manifest(
+            "[tree]\nfile = \"tree\"\nsize = 18\nchecksum = \"x\"\ntags = [\"gc\"]\nruns = 3\n",
+        )
+        .unwrap_err();
+        assert!(matches!(err, ManifestError::Parse(_)), "{:?}", err);
+    }
+
+    #[test]
+    fn rejects_missing_checksum() {
+        let err =
+            parse_manifest("[tree]\nfile = \"tree\"\nsize = 18\ntags = [\"gc\"]\n").unwrap_err();
+        assert!(matches!(err, ManifestError::Parse(_)), "{:?}", err);
+
+        let err = parse_manifest(
+            "[tree]\nfile = \"tree\"\nsize = 18\nchecksum = \" \"\ntags = [\"gc\"]\n",
+        )
+        .unwrap_err();
+        assert_eq!(err.to_string(), "benchmark 'tree': checksum is empty");
+    }
+}
@@ -0,0 +1,63 @@
+//! Checks `bench/cross-language/bench.toml` against the benchmark tree.
+
+use lumen_bench::manifest::{load_manifest, Manifest};
+use std::collections::BTreeSet;
+use std::fs;
+use std::path::PathBuf;
+
+fn cross_language_dir() -> PathBuf {
+    PathBuf::from(env!("CARGO_MANIFEST_DIR")).join("../../bench/cross-language")
+}
+
+fn manifest() -> Manifest {
+    load_manifest(&cross_language_dir().join("bench.toml")).expect("bench.toml should load")
+}
+
+#[test]
+fn every_benchmark_directory_has_a_manifest_entry() {
+    let dirs: BTreeSet<String> = fs::read_dir(cross_language_dir())
+        .expect("cross-language directory should exist")
+        .map(|entry| entry.expect("directory entry"))
+        .filter(|entry| entry.path().is_dir())
+        .map(|entry| entry.file_name().to_string_lossy().into_owned())
+        .collect();
+    let manifest = manifest();
+    let entries: BTreeSet<String> = manifest.benchmarks.keys().cloned().collect();
+    assert_eq!(
+        dirs, entries,
+        "benchmark directories and manifest entries differ"
+    );
+
+    for (name, bench) in &manifest.benchmarks {
+        let lumen = cross_language_dir()
+            .join(name)
+            .join(format!("{}.lm", bench.file));
+        assert!(lumen.is_file(), "{}: missing {}", name, lumen.display());
+    }
+}
+
+#[test]
+#[ignore = "runs every benchmark at full size; use --ignored"]
+fn declared_checksums_match_lumen_runs() {
+    // Run with: cargo test -p lumen-bench --release --test manifest -- --ignored
+    for (name, bench) in &manifest().benchmarks {
+        let path = cross_language_dir()
+            .join(name)
+            .join(format!("{}.lm", bench.file));
+        let source = fs::read_to_string(&path)
+            .unwrap_or_else(|e| panic!("cannot read {}: {}", path.display(), e));
+        let module = lumen_compiler::compile_raw(&source)
+            .unwrap_or_else(|e| panic!("{} should compile: {:?}", name, e));
+        let mut vm = lumen_vm::vm::VM::new();
+        vm.load(module);
+        vm.execute("main", vec![])
+            .unwrap_or_else(|e| panic!("{} should run: {}", name, e));
+        assert!(
+            vm.output.iter().any(|line| line == &bench.checksum),
+            "{}: expected a line {:?}, got {:?}",
+            name,
+            bench.checksum,
+            vm.output
+        );
+    }
+}
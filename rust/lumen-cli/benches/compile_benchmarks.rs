@@ -90,7 +90,7 @@ cell status_label(s: Status) -> String
 end
 
 cell count_complete(tasks: list[Task]) -> Int
-    let count = 0
+    var count = 0
     for task in tasks
         match task.status
             Status.Complete(result:) -> count = count + 1
@@ -101,7 +101,7 @@ cell count_complete(tasks: list[Task]) -> Int
 end
 
 cell make_tasks(n: Int) -> list[Task]
-    let tasks: list[Task] = []
+    var tasks: list[Task] = []
     for i in 0..n
         let status = if i % 3 == 0
             Status.Complete(result: "ok")
@@ -1,12 +1,14 @@
 //! Lumen CLI — command-line interface for the Lumen language.
 
 use lumen_cli::{
-    ci_output, colors, config, doc, error_chain, fmt, lang_ref, lint, module_resolver, repl,
-    test_cmd,
+    binary_cache, ci_output, colors, config, doc, error_chain, fmt, lang_ref, lint,
+    module_resolver, repl, test_cmd,
 };
 
 use clap::{Parser as ClapParser, Subcommand, ValueEnum};
 use colors::{bold, cyan, gray, green, red, status_label, yellow};
+use lumen_compiler::compiler::incremental::CellStore;
+use lumen_compiler::compiler::opt_report::OptReport;
 use std::cell::RefCell;
 use std::path::{Path, PathBuf};
 use std::sync::{Arc, Mutex};
@@ -16,8 +18,15 @@ use std::sync::{Arc, Mutex};
 // ---------------------------------------------------------------------------
 /// Exit code when any error occurs (compile error, IO error, etc.).
 const EXIT_ERROR: i32 = 1;
+/// Exit code when a Lumen program calls `panic` outside any `recover` boundary.
+const EXIT_PANIC: i32 = 2;
 /// Exit code when an unexpected internal panic is caught.
 const EXIT_INTERNAL_ERROR: i32 = 101;
+/// Exit code when Ctrl+C stops a running program (128 + SIGINT).
+const EXIT_INTERRUPTED: i32 = 130;
+/// Exit code when SIGTERM stops a running program that does not handle it
+/// (128 + SIGTERM).
+const EXIT_TERMINATED: i32 = 143;
 
 // ---------------------------------------------------------------------------
 // Panic hook — converts raw Rust panics into user-friendly messages.
@@ -58,10 +67,44 @@ fn install_panic_hook() {
     }));
 }
 
+// ---------------------------------------------------------------------------
+// SIGTERM forwarding — hands SIGTERM to the running VM, as the Ctrl+C
+// handler in `cmd_run` does for SIGINT, so `std.os.signal` handlers see it.
+// A second SIGTERM exits at once.
+// ---------------------------------------------------------------------------
+#[cfg(unix)]
+static SIGTERM_TARGET: std::sync::OnceLock<lumen_vm::vm::InterruptHandle> =
+    std::sync::OnceLock::new();
+#[cfg(unix)]
+static SIGTERM_RECEIVED: std::sync::atomic::AtomicBool = std::sync::atomic::AtomicBool::new(false);
+
+#[cfg(unix)]
+extern "C" fn on_sigterm(_: libc::c_int) {
+    // Only atomics here: this runs inside the signal handler
+    if SIGTERM_RECEIVED.swap(true, std::sync::atomic::Ordering::SeqCst) {
+        unsafe { libc::_exit(EXIT_TERMINATED) };
+    }
+    if let Some(target) = SIGTERM_TARGET.get() {
+        target.raise(lumen_runtime::signal::Signal::Terminate);
+    }
+}
+
+#[cfg(unix)]
+fn forward_sigterm(target: lumen_vm::vm::InterruptHandle) {
+    if SIGTERM_TARGET.set(target).is_ok() {
+        let handler = on_sigterm as extern "C" fn(libc::c_int);
+        unsafe { libc::signal(libc::SIGTERM, handler as libc::sighandler_t) };
+    }
+}
+
+#[cfg(not(unix))]
+fn forward_sigterm(_target: lumen_vm::vm::InterruptHandle) {}
+
 #[derive(ClapParser)]
 #[command(
     name = "lumen",
     version,
+    long_version = lumen_vm::build_info::LONG_VERSION,
     about = "The Lumen programming language — statically typed, AI-native systems",
     long_about = "Lumen is a statically typed programming language for AI-native systems.\n\n\
                   Learn more at: https://github.com/alliecatowo/lumen",
@@ -124,6 +167,34 @@ enum Commands {
         /// Default is 0 meaning JIT is always attempted immediately.
         #[arg(long, default_value = "0")]
         jit_threshold: u32,
+
+        /// Fuse float multiplies and adds into FMA in JIT-compiled code, on
+        /// CPUs that have it. Faster, but results may differ in the last bit
+        /// from the interpreter and from one machine to another.
+        #[arg(long)]
+        fma: bool,
+
+        /// Reassociate float arithmetic with constants, divide by
+        /// multiplying with the reciprocal, and fuse multiply-adds as `--fma`
+        /// does. Faster, but results may differ in the last bits from the
+        /// program as written.
+        #[arg(long = "ffast-math")]
+        fast_math: bool,
+
+        /// Print heap allocations by source line to stderr after the run.
+        /// Turns the JIT off, since native code does not report allocations.
+        #[arg(long)]
+        alloc_profile: bool,
+
+        /// Maximum call depth, in frames. A program that calls deeper stops
+        /// with a stack overflow error and its stack trace.
+        #[arg(long = "stack-size", default_value_t = lumen_vm::vm::DEFAULT_MAX_CALL_DEPTH)]
+        stack_size: usize,
+
+        /// Arguments passed to the program, available through `args()`.
+        /// Place them after `--` when they start with a dash.
+        #[arg(trailing_var_arg = true, allow_hyphen_values = true)]
+        args: Vec<String>,
     },
     /// Compile a `.lm`, `.lumen`, `.lm.md`, or `.lumen.md` file to LIR JSON
     Emit {
@@ -155,9 +226,18 @@ enum Commands {
     Fmt {
         /// Files to format (or stdin)
         files: Vec<PathBuf>,
-        /// Check mode: exit 1 if files would change
+        /// Check mode: print a diff and exit 1 if files would change
         #[arg(long)]
         check: bool,
+        /// Spaces per indentation level
+        #[arg(long, default_value_t = 2)]
+        indent: usize,
+        /// Indent with tabs instead of spaces
+        #[arg(long)]
+        use_tabs: bool,
+        /// Column limit before argument lists are wrapped
+        #[arg(long, default_value_t = 100)]
+        max_width: usize,
     },
     /// Generate documentation from .lm.md files
     Doc {
@@ -202,9 +282,22 @@ enum Commands {
         path: PathBuf,
     },
     /// Build commands
+    #[command(args_conflicts_with_subcommands = true)]
     Build {
         #[command(subcommand)]
-        sub: BuildCommands,
+        sub: Option<BuildCommands>,
+        /// Print, for each cell, the optimizations that fired and the ones
+        /// that were blocked and why
+        #[arg(long)]
+        opt_report: bool,
+        /// Print each cell's control-flow graph in Graphviz DOT format
+        #[arg(long)]
+        emit_cfg: bool,
+        /// Where `--emit-cfg` writes the graph (default: stdout)
+        #[arg(short, long)]
+        output: Option<PathBuf>,
+        /// Source file to report on or graph
+        file: Option<PathBuf>,
     },
     /// Watch files and re-check on changes
     Watch {
@@ -458,7 +551,40 @@ fn dispatch_command(command: Commands) {
             trace_dir,
             allow_unstable,
             jit_threshold,
-        } => cmd_run(&file, &cell, trace_dir, allow_unstable, jit_threshold),
+            fma,
+            fast_math,
+            alloc_profile,
+            stack_size,
+            args,
+        } => {
+            // Native code and closures called from builtins recurse on the
+            // thread's own stack, so give the run a stack sized for the
+            // depth limit rather than the main thread's default.
+            let runner = std::thread::Builder::new()
+                .name("lumen-run".to_string())
+                .stack_size(lumen_vm::vm::native_stack_size(stack_size))
+                .spawn(move || {
+                    cmd_run(
+                        &file,
+                        &cell,
+                        trace_dir,
+                        allow_unstable,
+                        jit_threshold,
+                        fma,
+                        fast_math,
+                        alloc_profile,
+                        stack_size,
+                        args,
+                    )
+                })
+                .unwrap_or_else(|e| {
+                    eprintln!("{} cannot start the program thread: {}", red("error:"), e);
+                    std::process::exit(EXIT_ERROR);
+                });
+            if let Err(panic) = runner.join() {
+                std::panic::resume_unwind(panic);
+            }
+        }
         Commands::Emit {
             file,
             output,
@@ -476,7 +602,21 @@ fn dispatch_command(command: Commands) {
             CacheCommands::Clear { cache_dir } => cmd_cache_clear(&cache_dir),
         },
         Commands::Repl => repl::run_repl(),
-        Commands::Fmt { files, check } => cmd_fmt(files, check),
+        Commands::Fmt {
+            files,
+            check,
+            indent,
+            use_tabs,
+            max_width,
+        } => cmd_fmt(
+            files,
+            check,
+            fmt::FormatOptions {
+                indent,
+                use_tabs,
+                max_width,
+            },
+        ),
         Commands::Doc {
             path,
             format,
@@ -490,8 +630,23 @@ fn dispatch_command(command: Commands) {
             verbose,
         } => cmd_test(path, filter, verbose),
         Commands::Ci { path } => cmd_ci(path),
-        Commands::Build { sub } => match sub {
-            BuildCommands::Wasm { target, release } => cmd_build_wasm(&target, release),
+        Commands::Build {
+            sub,
+            opt_report,
+            emit_cfg,
+            output,
+            file,
+        } => match (sub, file) {
+            (Some(BuildCommands::Wasm { target, release }), _) => cmd_build_wasm(&target, release),
+            (None, Some(file)) if opt_report => cmd_build_opt_report(&file),
+            (None, Some(file)) if emit_cfg => cmd_build_emit_cfg(&file, output),
+            _ => {
+                eprintln!(
+                    "{} expected a build subcommand, --opt-report <file>, or --emit-cfg <file>",
+                    red("error:")
+                );
+                std::process::exit(EXIT_ERROR);
+            }
         },
         Commands::Watch { path, interval } => cmd_watch(&path, interval),
         Commands::Migrate { edition, files } => cmd_migrate(&edition, &files),
@@ -695,7 +850,7 @@ fn gate_check_sources(files: &[PathBuf]) -> bool {
         };
 
         let filename = file.display().to_string();
-        if let Err(e) = compile_source_file(file, &source, false) {
+        if let Err(e) = compile_source_file(file, &source, false, false) {
             let formatted = lumen_compiler::format_error(&e, &source, &filename);
             eprint!("{}", formatted);
             failures += 1;
@@ -862,6 +1017,22 @@ fn compile_source_file(
     path: &Path,
     source: &str,
     allow_unstable: bool,
+    fast_math: bool,
+) -> Result<lumen_compiler::compiler::lir::LirModule, lumen_compiler::CompileError> {
+    let opts = lumen_compiler::CompileOptions {
+        allow_unstable,
+        fast_math,
+        ..Default::default()
+    };
+    compile_source_file_with_options(path, source, opts)
+}
+
+/// `compile_source_file` with `opts`, resolving imports next to `path` and
+/// in its project, and keeping lowered cells in the project's cache.
+fn compile_source_file_with_options(
+    path: &Path,
+    source: &str,
+    mut opts: lumen_compiler::CompileOptions,
 ) -> Result<lumen_compiler::compiler::lir::LirModule, lumen_compiler::CompileError> {
     let source_dir = path
         .parent()
@@ -870,6 +1041,13 @@ fn compile_source_file(
     let mut resolver = module_resolver::ModuleResolver::new(source_dir.clone());
 
     if let Some(project_root) = find_project_root(&source_dir) {
+        // Lowered cells are kept with the project's other caches, so a
+        // rebuild only lowers the cells that changed
+        let cells_dir = project_root.join(".lumen").join("cache").join("cells");
+        if let Ok(cache) = binary_cache::BinaryCache::new(cells_dir) {
+            let cache: Arc<Mutex<dyn CellStore>> = Arc::new(Mutex::new(cache));
+            opts.cell_store = Some(cache);
+        }
         let src_dir = project_root.join("src");
         if src_dir.is_dir() && src_dir != source_dir {
             resolver.add_root(src_dir);
@@ -881,11 +1059,6 @@ fn compile_source_file(
 
     let resolver = RefCell::new(resolver);
     let resolve_import = |module_path: &str| resolver.borrow_mut().resolve(module_path);
-
-    let opts = lumen_compiler::CompileOptions {
-        allow_unstable,
-        ..Default::default()
-    };
     lumen_compiler::compile_with_imports_and_options(source, &resolve_import, &opts)
 }
 
@@ -909,7 +1082,7 @@ fn cmd_check(file: &PathBuf, output_format: &str, allow_unstable: bool) {
         ci_output::OutputFormat::Text => {
             // Original human-readable output.
             println!("{} {}", status_label("Checking"), bold(&filename));
-            match compile_source_file(file, &source, allow_unstable) {
+            match compile_source_file(file, &source, allow_unstable, false) {
                 Ok(_module) => {
                     let elapsed = start.elapsed();
                     println!(
@@ -929,7 +1102,7 @@ fn cmd_check(file: &PathBuf, output_format: &str, allow_unstable: bool) {
             let mut report = ci_output::CheckReport::new("lumen-check");
             let file_start = std::time::Instant::now();
 
-            let result = match compile_source_file(file, &source, allow_unstable) {
+            let result = match compile_source_file(file, &source, allow_unstable, false) {
                 Ok(_module) => ci_output::FileCheckResult {
                     file: filename.clone(),
                     passed: true,
@@ -1099,7 +1272,7 @@ fn run_watch_check(files: &[PathBuf]) {
         };
 
         let filename = file.display().to_string();
-        if let Err(e) = compile_source_file(file, &source, false) {
+        if let Err(e) = compile_source_file(file, &source, false, false) {
             let formatted = lumen_compiler::format_error(&e, &source, &filename);
             eprint!("{}", formatted);
             errors += 1;
@@ -1152,13 +1325,18 @@ fn cmd_run(
     trace_dir: Option<PathBuf>,
     allow_unstable: bool,
     jit_threshold: u32,
+    fma: bool,
+    fast_math: bool,
+    alloc_profile: bool,
+    stack_size: usize,
+    args: Vec<String>,
 ) {
     let source = read_source(file);
     let filename = file.display().to_string();
 
     println!("{} {}", status_label("Compiling"), bold(&filename));
     let start = std::time::Instant::now();
-    let module = match compile_source_file(file, &source, allow_unstable) {
+    let module = match compile_source_file(file, &source, allow_unstable, fast_math) {
         Ok(m) => m,
         Err(e) => {
             let chain = error_chain::ErrorChain::new("compilation failed")
@@ -1202,7 +1380,30 @@ fn cmd_run(
     // Enable tiered JIT: with --jit-threshold=0 (default), eligible cells are
     // compiled to native code on their very first call. Use a higher value to
     // defer compilation to only hot cells.
-    vm.enable_jit(jit_threshold as u64);
+    if alloc_profile {
+        vm.enable_alloc_profile();
+    } else {
+        vm.enable_jit_with_config(lumen_vm::jit_tier::JitTierConfig {
+            hot_threshold: jit_threshold as u64,
+            fuse_multiply_add: fma || fast_math,
+            ..Default::default()
+        });
+    }
+    vm.set_max_call_depth(stack_size);
+    // The first Ctrl+C reaches the program at its next loop iteration or
+    // call, and stops it unless it handles SIGINT; a second one exits at
+    // once, for code that never reaches a safepoint
+    let interrupt = vm.interrupt_handle();
+    let interrupted = std::sync::atomic::AtomicBool::new(false);
+    let _ = ctrlc::set_handler(move || {
+        if interrupted.swap(true, std::sync::atomic::Ordering::SeqCst) {
+            std::process::exit(EXIT_INTERRUPTED);
+        }
+        interrupt.interrupt();
+    });
+    forward_sigterm(vm.interrupt_handle());
+    vm.set_program_args(args);
+    vm.set_source_name(filename.clone());
     if let Some(run_id) = trace_run_id.as_ref() {
         vm.set_trace_id(run_id.clone());
     }
@@ -1247,8 +1448,19 @@ fn cmd_run(
             }
         }));
     }
-    vm.load(module);
-    match vm.execute(cell, vec![]) {
+    // Cells may come from the on-disk cache, so check the module before
+    // running any of it
+    if let Err(e) = vm.load_verified(module) {
+        eprintln!("{} {}", red("error:"), e);
+        std::process::exit(EXIT_ERROR);
+    }
+    let outcome = vm.execute(cell, vec![]);
+    // Program output is buffered; write it out before any status lines.
+    let _ = vm.flush_stdout();
+    if alloc_profile {
+        eprint!("{}", vm.alloc_profile().report());
+    }
+    match outcome {
         Ok(result) => {
             let elapsed = start.elapsed();
             if let Some(trace_store) = trace_store.as_ref() {
@@ -1283,7 +1495,22 @@ fn cmd_run(
             }
             let chain = error_chain::chain_from_error(&e);
             eprintln!("{}", chain.format_with_prefix(&red("✗ Error:")));
-            std::process::exit(EXIT_ERROR);
+            if e.is_stack_overflow() {
+                eprintln!(
+                    "  hint: make the recursion a tail call, or raise the limit with {}",
+                    cyan(&format!("--stack-size {}", stack_size.saturating_mul(2)))
+                );
+            }
+            let code = if e.is_panic() {
+                EXIT_PANIC
+            } else if e.is_interrupted() {
+                EXIT_INTERRUPTED
+            } else if e.is_terminated() {
+                EXIT_TERMINATED
+            } else {
+                EXIT_ERROR
+            };
+            std::process::exit(code);
         }
     }
 }
@@ -1301,7 +1528,7 @@ fn cmd_emit(file: &PathBuf, output: Option<PathBuf>, allow_unstable: bool) {
     let filename = file.display().to_string();
 
     println!("{} {}", status_label("Compiling"), filename);
-    let module = match compile_source_file(file, &source, allow_unstable) {
+    let module = match compile_source_file(file, &source, allow_unstable, false) {
         Ok(m) => m,
         Err(e) => {
             let chain = error_chain::ErrorChain::new("compilation failed")
@@ -1336,6 +1563,86 @@ fn cmd_emit(file: &PathBuf, output: Option<PathBuf>, allow_unstable: bool) {
     }
 }
 
+fn cmd_build_opt_report(file: &PathBuf) {
+    let source = read_source(file);
+    let filename = file.display().to_string();
+
+    let report = Arc::new(Mutex::new(OptReport::default()));
+    let opts = lumen_compiler::CompileOptions {
+        opt_report: Some(Arc::clone(&report)),
+        ..Default::default()
+    };
+    let module = match compile_source_file_with_options(file, &source, opts) {
+        Ok(m) => m,
+        Err(e) => {
+            let chain = error_chain::ErrorChain::new("compilation failed")
+                .caused_by(format!("in file '{}'", filename));
+            eprintln!("{}", chain.format_with_prefix(&red("✗")));
+            let formatted = lumen_compiler::format_error(&e, &source, &filename);
+            eprint!("{}", formatted);
+            std::process::exit(EXIT_ERROR);
+        }
+    };
+
+    let mut report = report.lock().unwrap_or_else(|e| e.into_inner()).clone();
+    note_jit_blockers(&module, &mut report);
+
+    println!(
+        "{} optimization report for {}",
+        status_label("Reporting"),
+        bold(&filename)
+    );
+    print!("{}", report);
+}
+
+fn cmd_build_emit_cfg(file: &PathBuf, output: Option<PathBuf>) {
+    let source = read_source(file);
+    let filename = file.display().to_string();
+
+    let module = match compile_source_file(file, &source, false, false) {
+        Ok(m) => m,
+        Err(e) => {
+            let chain = error_chain::ErrorChain::new("compilation failed")
+                .caused_by(format!("in file '{}'", filename));
+            eprintln!("{}", chain.format_with_prefix(&red("✗")));
+            let formatted = lumen_compiler::format_error(&e, &source, &filename);
+            eprint!("{}", formatted);
+            std::process::exit(EXIT_ERROR);
+        }
+    };
+
+    let dot = lumen_compiler::compiler::cfg::module_to_dot(&module);
+    match output {
+        Some(out_path) => {
+            std::fs::write(&out_path, &dot).unwrap_or_else(|e| {
+                eprintln!(
+                    "{} writing to '{}': {}",
+                    red("error:"),
+                    out_path.display(),
+                    e
+                );
+                std::process::exit(EXIT_ERROR);
+            });
+            println!(
+                "{} control-flow graph to {}",
+                status_label("Emitting"),
+                out_path.display()
+            );
+        }
+        // Nothing else goes to stdout, so it can be piped into `dot`
+        None => print!("{}", dot),
+    }
+}
+
+/// Add the JIT's notes on `module` to `report`.
+#[cfg(feature = "jit")]
+fn note_jit_blockers(module: &lumen_compiler::compiler::lir::LirModule, report: &mut OptReport) {
+    lumen_codegen::jit::note_jit_blockers(module, report);
+}
+
+#[cfg(not(feature = "jit"))]
+fn note_jit_blockers(_module: &lumen_compiler::compiler::lir::LirModule, _report: &mut OptReport) {}
+
 fn cmd_trace_show(run_id: &str, trace_dir: &Path, format: TraceShowFormat, verify_chain: bool) {
     let path = trace_dir.join(format!("{}.jsonl", run_id));
     match read_trace_events(&path) {
@@ -1489,7 +1796,7 @@ fn cmd_cache_clear(cache_dir: &PathBuf) {
     }
 }
 
-fn cmd_fmt(files: Vec<PathBuf>, check: bool) {
+fn cmd_fmt(files: Vec<PathBuf>, check: bool, options: fmt::FormatOptions) {
     if files.is_empty() {
         eprintln!("{} no files specified", red("✗ Error:"));
         std::process::exit(EXIT_ERROR);
@@ -1504,7 +1811,7 @@ fn cmd_fmt(files: Vec<PathBuf>, check: bool) {
     );
 
     let start = std::time::Instant::now();
-    match fmt::format_files(&files, check) {
+    match fmt::format_files(&files, check, &options) {
         Ok((needs_formatting, reformatted_count)) => {
             let elapsed = start.elapsed();
             if check {
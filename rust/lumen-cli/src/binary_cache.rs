@@ -12,12 +12,16 @@
 //! +-- artifacts/
 //!     +-- <hex_key>.bin    # Compiled artifact blobs
 //! ```
+//!
+//! A `BinaryCache` is also a [`CellStore`]: incremental builds keep each
+//! lowered cell in it under the cell's fingerprint.
 
 use std::collections::HashMap;
 use std::fmt;
 use std::path::{Path, PathBuf};
 use std::time::{SystemTime, UNIX_EPOCH};
 
+use lumen_compiler::compiler::incremental::{CellStore, CompiledCell};
 use sha2::{Digest, Sha256};
 
 // =============================================================================
@@ -423,6 +427,41 @@ impl BinaryCache {
     }
 }
 
+// =============================================================================
+// Cell store
+// =============================================================================
+
+/// Target recorded in the key of a cached cell.
+const CELL_TARGET: &str = "lir-cell";
+
+fn cell_key(fingerprint: &str) -> CacheKey {
+    CacheKey {
+        source_hash: fingerprint.to_string(),
+        compiler_version: env!("CARGO_PKG_VERSION").to_string(),
+        target: CELL_TARGET.to_string(),
+        optimization_level: OptLevel::Debug,
+    }
+}
+
+impl CellStore for BinaryCache {
+    fn get(&mut self, key: &str) -> Option<CompiledCell> {
+        let entry = BinaryCache::get(self, &cell_key(key))?;
+        let data = std::fs::read(self.cache_dir.join(&entry.artifact_path)).ok()?;
+        serde_json::from_slice(&data).ok()
+    }
+
+    /// A cell that cannot be written is lowered again next build, so write
+    /// errors are dropped rather than failing the build.
+    fn put(&mut self, key: &str, cell: &CompiledCell) {
+        let Ok(data) = serde_json::to_vec(cell) else {
+            return;
+        };
+        if BinaryCache::put(self, cell_key(key), &data).is_ok() {
+            let _ = self.save_index();
+        }
+    }
+}
+
 // =============================================================================
 // Helpers
 // =============================================================================
@@ -503,4 +542,37 @@ mod tests {
         let result = "turbo".parse::<OptLevel>();
         assert!(result.is_err());
     }
+
+    #[test]
+    fn test_cell_store_round_trip_across_instances() {
+        use lumen_compiler::compiler::lir::LirCell;
+
+        let dir = std::env::temp_dir().join(format!("lumen-cell-store-{}", std::process::id()));
+        let _ = std::fs::remove_dir_all(&dir);
+        let cell = CompiledCell {
+            cell: LirCell {
+                name: "area".into(),
+                params: vec![],
+                returns: Some("Int".into()),
+                registers: 1,
+                constants: vec![],
+                instructions: vec![],
+                effect_handler_metas: vec![],
+            },
+            call_lines: vec![3],
+            alloc_lines: vec![],
+            strings: vec![("area".into(), 0)],
+        };
+
+        let mut cache = BinaryCache::new(dir.clone()).unwrap();
+        assert!(CellStore::get(&mut cache, "fingerprint").is_none());
+        CellStore::put(&mut cache, "fingerprint", &cell);
+
+        let mut reopened = BinaryCache::new(dir.clone()).unwrap();
+        let hit = CellStore::get(&mut reopened, "fingerprint").expect("cell should be cached");
+        assert_eq!(hit.cell.name, "area");
+        assert_eq!(hit.call_lines, vec![3]);
+        assert!(CellStore::get(&mut reopened, "other").is_none());
+        let _ = std::fs::remove_dir_all(&dir);
+    }
 }
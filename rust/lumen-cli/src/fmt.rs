@@ -12,7 +12,32 @@ use lumen_compiler::compiler::ast::*;
 use lumen_compiler::markdown::extract::extract_blocks;
 use std::path::PathBuf;
 
-const INDENT_SPACES: usize = 2;
+/// Layout settings for the formatter.
+///
+/// The default style is two-space indentation with no tabs and a 100-column
+/// limit. A call (including a record constructor such as `Body(x: ..., y: ...)`)
+/// whose one-line form would run past `max_width`, counting its indentation,
+/// is wrapped with one argument per line, indented one level deeper than
+/// the line it starts on. A tab counts as `indent` columns.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub struct FormatOptions {
+    /// Spaces per indentation level (ignored when `use_tabs` is set)
+    pub indent: usize,
+    /// Indent with one tab per level instead of spaces
+    pub use_tabs: bool,
+    /// Column limit used to decide when to wrap argument lists
+    pub max_width: usize,
+}
+
+impl Default for FormatOptions {
+    fn default() -> Self {
+        Self {
+            indent: 2,
+            use_tabs: false,
+            max_width: 100,
+        }
+    }
+}
 
 /// ANSI color codes for CLI output
 const GREEN: &str = "\x1b[32m";
@@ -22,6 +47,11 @@ const RESET: &str = "\x1b[0m";
 
 /// Format a complete .lm.md file
 pub fn format_file(content: &str) -> String {
+    format_file_with_options(content, &FormatOptions::default())
+}
+
+/// Format a complete .lm.md file with explicit layout options
+pub fn format_file_with_options(content: &str, options: &FormatOptions) -> String {
     let mut output = String::new();
     let mut in_code_block = false;
     let mut code_block = String::new();
@@ -37,7 +67,7 @@ pub fn format_file(content: &str) -> String {
             code_block.clear();
         } else if in_code_block && trimmed.starts_with("```") {
             // End of code block — format and emit
-            let formatted = format_lumen_code(&code_block);
+            let formatted = format_lumen_code_with_options(&code_block, options);
             output.push_str(&formatted);
             if !formatted.is_empty() && !formatted.ends_with('\n') {
                 output.push('\n');
@@ -68,6 +98,11 @@ pub fn format_file(content: &str) -> String {
 /// - Maintains blank lines around markdown blocks
 /// - Keeps docstrings attached to their declarations (no added blank line)
 pub fn format_lm_source(content: &str) -> String {
+    format_lm_source_with_options(content, &FormatOptions::default())
+}
+
+/// Format a .lm/.lumen file with explicit layout options
+pub fn format_lm_source_with_options(content: &str, options: &FormatOptions) -> String {
     let lines: Vec<&str> = content.lines().collect();
     let mut output = String::new();
     let mut i = 0;
@@ -128,7 +163,7 @@ pub fn format_lm_source(content: &str) -> String {
                     .iter()
                     .map(|l| format!("{}\n", l))
                     .collect();
-                let formatted = format_lumen_code(actual_code.trim());
+                let formatted = format_lumen_code_with_options(actual_code.trim(), options);
                 output.push_str(&formatted);
             }
 
@@ -144,6 +179,11 @@ pub fn format_lm_source(content: &str) -> String {
 
 /// Format Lumen code using AST-based pretty printing
 pub fn format_lumen_code(code: &str) -> String {
+    format_lumen_code_with_options(code, &FormatOptions::default())
+}
+
+/// Format Lumen code with explicit layout options
+pub fn format_lumen_code_with_options(code: &str, options: &FormatOptions) -> String {
     if code.trim().is_empty() {
         return String::new();
     }
@@ -182,7 +222,7 @@ pub fn format_lumen_code(code: &str) -> String {
     }
 
     // Pretty-print the AST
-    let mut formatter = Formatter::new();
+    let mut formatter = Formatter::new(*options);
     formatter.fmt_program(&program);
 
     let mut result = formatter.output;
@@ -201,18 +241,39 @@ pub fn format_lumen_code(code: &str) -> String {
 struct Formatter {
     output: String,
     indent: usize,
+    options: FormatOptions,
 }
 
 impl Formatter {
-    fn new() -> Self {
+    fn new(options: FormatOptions) -> Self {
         Self {
             output: String::new(),
             indent: 0,
+            options,
         }
     }
 
     fn indent_str(&self) -> String {
-        " ".repeat(self.indent * INDENT_SPACES)
+        if self.options.use_tabs {
+            "\t".repeat(self.indent)
+        } else {
+            " ".repeat(self.indent * self.options.indent)
+        }
+    }
+
+    /// Whether `text` fits on one line at the current indentation.
+    fn fits(&self, text: &str) -> bool {
+        !text.contains('\n')
+            && self.indent * self.options.indent + text.chars().count() <= self.options.max_width
+    }
+
+    /// A formatter one indentation level deeper, for wrapped arguments.
+    fn nested(&self) -> Formatter {
+        Formatter {
+            output: String::new(),
+            indent: self.indent + 1,
+            options: self.options,
+        }
     }
 
     fn push_indent(&mut self) {
@@ -271,8 +332,28 @@ impl Formatter {
         if cell.is_pub {
             header.push_str("pub ");
         }
+        if cell.is_const {
+            header.push_str("const ");
+        }
         header.push_str("cell ");
-        header.push_str(&cell.name);
+        let params = match (cell.receiver, cell.params.first()) {
+            (Some(receiver), Some(recv)) => {
+                header.push('(');
+                if receiver == Receiver::Mut {
+                    header.push_str("mut ");
+                }
+                header.push_str(&recv.name);
+                header.push_str(": ");
+                header.push_str(&self.fmt_type(&recv.ty));
+                header.push_str(") ");
+                header.push_str(cell.name.rsplit('.').next().unwrap_or(&cell.name));
+                &cell.params[1..]
+            }
+            _ => {
+                header.push_str(&cell.name);
+                &cell.params[..]
+            }
+        };
 
         if !cell.generic_params.is_empty() {
             header.push('[');
@@ -291,7 +372,7 @@ impl Formatter {
 
         // Build parameter list
         let mut params_str = String::new();
-        for (i, param) in cell.params.iter().enumerate() {
+        for (i, param) in params.iter().enumerate() {
             if i > 0 {
                 params_str.push_str(", ");
             }
@@ -321,7 +402,7 @@ impl Formatter {
 
         if one_line.len() <= 100 {
             self.writeln(&one_line);
-        } else if cell.params.len() <= 3 {
+        } else if params.len() <= 3 {
             // Short param list — keep on one line even if slightly long
             self.writeln(&one_line);
         } else {
@@ -329,7 +410,7 @@ impl Formatter {
             header.push('(');
             self.writeln(header.trim_end());
             self.push_indent();
-            for (i, param) in cell.params.iter().enumerate() {
+            for (i, param) in params.iter().enumerate() {
                 let mut line = param.name.clone();
                 line.push_str(": ");
                 line.push_str(&self.fmt_type(&param.ty));
@@ -337,7 +418,7 @@ impl Formatter {
                     line.push_str(" = ");
                     line.push_str(&self.fmt_expr(default));
                 }
-                if i < cell.params.len() - 1 {
+                if i < params.len() - 1 {
                     line.push(',');
                 }
                 self.writeln(&line);
@@ -718,11 +799,24 @@ impl Formatter {
         self.writeln("end");
     }
 
+    /// A single-line statement without indentation or newline, as used in
+    /// a three-clause `for` header.
+    fn fmt_stmt_inline(&self, stmt: &Stmt) -> String {
+        let mut f = Formatter::new(FormatOptions {
+            max_width: usize::MAX,
+            ..self.options
+        });
+        f.fmt_stmt(stmt);
+        f.output.trim().to_string()
+    }
+
     fn fmt_stmt(&mut self, stmt: &Stmt) {
         match stmt {
             Stmt::Let(s) => {
                 let mut line = String::new();
-                if s.mutable {
+                if s.is_var {
+                    line.push_str("var ");
+                } else if s.mutable {
                     line.push_str("let mut ");
                 } else {
                     line.push_str("let ");
@@ -785,6 +879,49 @@ impl Formatter {
                 self.pop_indent();
                 self.writeln("end");
             }
+            Stmt::Match(s) if s.is_switch => {
+                self.writeln(&format!("switch {}", self.fmt_expr(&s.subject)));
+                self.push_indent();
+                let case_count = s.arms.len().saturating_sub(1);
+                for (idx, arm) in s.arms.iter().enumerate() {
+                    let header = if idx < case_count {
+                        let values = match &arm.pattern {
+                            Pattern::Or { patterns, .. } => patterns
+                                .iter()
+                                .map(|p| self.fmt_pattern(p))
+                                .collect::<Vec<_>>()
+                                .join(", "),
+                            other => self.fmt_pattern(other),
+                        };
+                        format!("case {}", values)
+                    } else if arm.body.is_empty() {
+                        // An omitted `default` parses to an empty wildcard arm.
+                        continue;
+                    } else {
+                        "default".to_string()
+                    };
+                    let short = match arm.body.as_slice() {
+                        [Stmt::Return(r)] => Some(format!("return {}", self.fmt_expr(&r.value))),
+                        [Stmt::Expr(e)] => Some(self.fmt_expr(&e.expr)),
+                        _ => None,
+                    };
+                    match short {
+                        Some(stmt) if stmt.len() < 40 => {
+                            self.writeln(&format!("{} -> {}", header, stmt));
+                        }
+                        _ => {
+                            self.writeln(&header);
+                            self.push_indent();
+                            for stmt in &arm.body {
+                                self.fmt_stmt(stmt);
+                            }
+                            self.pop_indent();
+                        }
+                    }
+                }
+                self.pop_indent();
+                self.writeln("end");
+            }
             Stmt::Match(s) => {
                 self.writeln(&format!("match {}", self.fmt_expr(&s.subject)));
                 self.push_indent();
@@ -840,11 +977,20 @@ impl Formatter {
                 } else {
                     String::new()
                 };
-                self.writeln(&format!(
-                    "while {}{}",
-                    label_str,
-                    self.fmt_expr(&s.condition)
-                ));
+                match (&s.init, &s.post) {
+                    (Some(init), Some(post)) => self.writeln(&format!(
+                        "for {}{}; {}; {}",
+                        label_str,
+                        self.fmt_stmt_inline(init),
+                        self.fmt_expr(&s.condition),
+                        self.fmt_stmt_inline(post)
+                    )),
+                    _ => self.writeln(&format!(
+                        "while {}{}",
+                        label_str,
+                        self.fmt_expr(&s.condition)
+                    )),
+                }
                 self.push_indent();
                 for stmt in &s.body {
                     self.fmt_stmt(stmt);
@@ -961,6 +1107,9 @@ impl Formatter {
                 result.push(']');
                 result
             }
+            Expr::ListRepeat(value, count, _) => {
+                format!("[{}; {}]", self.fmt_expr(value), self.fmt_expr(count))
+            }
             Expr::MapLit(pairs, _) => {
                 let mut result = String::from("{");
                 for (i, (k, v)) in pairs.iter().enumerate() {
@@ -1003,24 +1152,31 @@ impl Formatter {
                 format!("{}{}", op_str, self.fmt_expr(expr))
             }
             Expr::Call(func, args, _) => {
-                let mut result = self.fmt_expr(func);
-                result.push('(');
-                for (i, arg) in args.iter().enumerate() {
-                    if i > 0 {
-                        result.push_str(", ");
-                    }
-                    match arg {
-                        CallArg::Positional(e) => result.push_str(&self.fmt_expr(e)),
-                        CallArg::Named(name, e, _) => {
-                            result.push_str(name);
-                            result.push_str(": ");
-                            result.push_str(&self.fmt_expr(e));
-                        }
-                        CallArg::Role(_, _, _) => {} // Handled separately in ToolCall
-                    }
+                let callee = self.fmt_expr(func);
+                let is_record_ctor =
+                    matches!(func.as_ref(), Expr::Ident(n, _) if n.starts_with(char::is_uppercase));
+                let flat: Vec<String> = args
+                    .iter()
+                    .filter_map(|arg| self.fmt_call_arg(arg, is_record_ctor))
+                    .collect();
+                let one_line = format!("{}({})", callee, flat.join(", "));
+                if flat.is_empty() || self.fits(&one_line) {
+                    return one_line;
                 }
-                result.push(')');
-                result
+                // Too wide: one argument per line, one level deeper.
+                let inner = self.nested();
+                let pad = inner.indent_str();
+                let wrapped: Vec<String> = args
+                    .iter()
+                    .filter_map(|arg| inner.fmt_call_arg(arg, is_record_ctor))
+                    .map(|arg| format!("{}{}", pad, arg))
+                    .collect();
+                format!(
+                    "{}(\n{}\n{})",
+                    callee,
+                    wrapped.join(",\n"),
+                    self.indent_str()
+                )
             }
             Expr::ToolCall(func, args, _) => {
                 let mut result = self.fmt_expr(func);
@@ -1304,6 +1460,18 @@ impl Formatter {
         }
     }
 
+    fn fmt_call_arg(&self, arg: &CallArg, is_record_ctor: bool) -> Option<String> {
+        match arg {
+            // Record update: `Body(..b, vx: 0.0)`
+            CallArg::Positional(Expr::SpreadExpr(src, _)) if is_record_ctor => {
+                Some(format!("..{}", self.fmt_expr(src)))
+            }
+            CallArg::Positional(e) => Some(self.fmt_expr(e)),
+            CallArg::Named(name, e, _) => Some(format!("{}: {}", name, self.fmt_expr(e))),
+            CallArg::Role(_, _, _) => None, // Handled separately in ToolCall
+        }
+    }
+
     fn fmt_type(&self, ty: &TypeExpr) -> String {
         match ty {
             TypeExpr::Named(name, _) => name.clone(),
@@ -1479,9 +1647,240 @@ fn escape_string(s: &str) -> String {
     result
 }
 
+/// Format `content` as the kind of file `path` names.
+fn format_for_path(path: &std::path::Path, content: &str, options: &FormatOptions) -> String {
+    let is_lm_md = path
+        .to_str()
+        .map(|s| s.ends_with(".lm.md"))
+        .unwrap_or(false);
+    if is_lm_md {
+        format_file_with_options(content, options)
+    } else {
+        format_lm_source_with_options(content, options)
+    }
+}
+
+/// A file whose contents differ from their canonical formatting.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct UnformattedFile {
+    pub path: PathBuf,
+    /// Unified diff from the file's contents to the formatted version
+    pub diff: String,
+}
+
+/// Result of checking files without rewriting them.
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub struct FormatCheck {
+    pub unformatted: Vec<UnformattedFile>,
+}
+
+impl FormatCheck {
+    /// Process exit code for `lumen fmt --check`: 0 when every file is
+    /// already formatted, 1 otherwise.
+    pub fn exit_code(&self) -> i32 {
+        if self.unformatted.is_empty() {
+            0
+        } else {
+            1
+        }
+    }
+}
+
+/// Check files against their canonical formatting without writing them.
+pub fn check_files(files: &[PathBuf], options: &FormatOptions) -> Result<FormatCheck, String> {
+    let mut check = FormatCheck::default();
+    for file in files {
+        let content = std::fs::read_to_string(file)
+            .map_err(|e| format!("error reading '{}': {}", file.display(), e))?;
+        let formatted = format_for_path(file, &content, options);
+        if content != formatted {
+            check.unformatted.push(UnformattedFile {
+                path: file.clone(),
+                diff: unified_diff(&file.display().to_string(), &content, &formatted),
+            });
+        }
+    }
+    Ok(check)
+}
+
+/// Unified diff (three lines of context) turning `original` into `formatted`,
+/// with `path` in the `---`/`+++` headers. Empty when the two are equal.
+pub fn unified_diff(path: &str, original: &str, formatted: &str) -> String {
+    const CONTEXT: usize = 3;
+
+    let old: Vec<&str> = original.split_inclusive('\n').collect();
+    let new: Vec<&str> = formatted.split_inclusive('\n').collect();
+    let ops = diff_lines(&old, &new);
+    if ops.iter().all(|op| matches!(op, DiffOp::Equal(..))) {
+        return String::new();
+    }
+
+    let mut out = format!("--- {}\n+++ {} (formatted)\n", path, path);
+    let changed: Vec<usize> = ops
+        .iter()
+        .enumerate()
+        .filter(|(_, op)| !matches!(op, DiffOp::Equal(..)))
+        .map(|(i, _)| i)
+        .collect();
+
+    // Group changes whose context windows touch into one hunk.
+    let mut start = 0;
+    while start < changed.len() {
+        let mut end = start;
+        while end + 1 < changed.len() && changed[end + 1] - changed[end] <= 2 * CONTEXT + 1 {
+            end += 1;
+        }
+        let lo = changed[start].saturating_sub(CONTEXT);
+        let hi = (changed[end] + CONTEXT + 1).min(ops.len());
+        let hunk = &ops[lo..hi];
+
+        let (mut old_start, mut new_start) = match ops[lo] {
+            DiffOp::Equal(o, n) => (o, n),
+            DiffOp::Delete(o) => (o, new_line_at(&ops, lo)),
+            DiffOp::Insert(n) => (old_line_at(&ops, lo), n),
+        };
+        let old_len = hunk
+            .iter()
+            .filter(|op| !matches!(op, DiffOp::Insert(_)))
+            .count();
+        let new_len = hunk
+            .iter()
+            .filter(|op| !matches!(op, DiffOp::Delete(_)))
+            .count();
+        // An empty side is numbered by the line before it, as in GNU diff.
+        if old_len > 0 {
+            old_start += 1;
+        }
+        if new_len > 0 {
+            new_start += 1;
+        }
+        out.push_str(&format!(
+            "@@ -{} +{} @@\n",
+            hunk_range(old_start, old_len),
+            hunk_range(new_start, new_len)
+        ));
+        for op in hunk {
+            let (marker, line) = match *op {
+                DiffOp::Equal(o, _) => (' ', old[o]),
+                DiffOp::Delete(o) => ('-', old[o]),
+                DiffOp::Insert(n) => ('+', new[n]),
+            };
+            out.push(marker);
+            out.push_str(line);
+            if !line.ends_with('\n') {
+                out.push_str("\n\\ No newline at end of file\n");
+            }
+        }
+        start = end + 1;
+    }
+    out
+}
+
+fn hunk_range(start: usize, len: usize) -> String {
+    if len == 1 {
+        start.to_string()
+    } else {
+        format!("{},{}", start, len)
+    }
+}
+
+/// One line of an edit script, by index into the old and/or new lines.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+enum DiffOp {
+    Equal(usize, usize),
+    Delete(usize),
+    Insert(usize),
+}
+
+/// Index of the next old line at position `at` of the edit script.
+fn old_line_at(ops: &[DiffOp], at: usize) -> usize {
+    ops[..at]
+        .iter()
+        .filter(|op| !matches!(op, DiffOp::Insert(_)))
+        .count()
+}
+
+/// Index of the next new line at position `at` of the edit script.
+fn new_line_at(ops: &[DiffOp], at: usize) -> usize {
+    ops[..at]
+        .iter()
+        .filter(|op| !matches!(op, DiffOp::Delete(_)))
+        .count()
+}
+
+/// Shortest edit script between two line lists, via a longest common
+/// subsequence table over the lines between the common prefix and suffix.
+fn diff_lines(old: &[&str], new: &[&str]) -> Vec<DiffOp> {
+    let prefix = old.iter().zip(new).take_while(|(a, b)| a == b).count();
+    let suffix = old[prefix..]
+        .iter()
+        .rev()
+        .zip(new[prefix..].iter().rev())
+        .take_while(|(a, b)| a == b)
+        .count();
+    let (a, b) = (
+        &old[prefix..old.len() - suffix],
+        &new[prefix..new.len() - suffix],
+    );
+
+    // lcs[i][j] = length of the LCS of a[i..] and b[j..]
+    let mut lcs = vec![vec![0u32; b.len() + 1]; a.len() + 1];
+    for i in (0..a.len()).rev() {
+        for j in (0..b.len()).rev() {
+            lcs[i][j] = if a[i] == b[j] {
+                lcs[i + 1][j + 1] + 1
+            } else {
+                lcs[i + 1][j].max(lcs[i][j + 1])
+            };
+        }
+    }
+
+    let mut ops: Vec<DiffOp> = (0..prefix).map(|i| DiffOp::Equal(i, i)).collect();
+    let (mut i, mut j) = (0, 0);
+    while i < a.len() || j < b.len() {
+        if i < a.len() && j < b.len() && a[i] == b[j] {
+            ops.push(DiffOp::Equal(prefix + i, prefix + j));
+            i += 1;
+            j += 1;
+        } else if j == b.len() || (i < a.len() && lcs[i + 1][j] >= lcs[i][j + 1]) {
+            ops.push(DiffOp::Delete(prefix + i));
+            i += 1;
+        } else {
+            ops.push(DiffOp::Insert(prefix + j));
+            j += 1;
+        }
+    }
+    let (old_tail, new_tail) = (old.len() - suffix, new.len() - suffix);
+    ops.extend((0..suffix).map(|k| DiffOp::Equal(old_tail + k, new_tail + k)));
+    ops
+}
+
 /// Format files in place or check if they need formatting
 /// Returns (needs_formatting, reformatted_count)
-pub fn format_files(files: &[PathBuf], check_mode: bool) -> Result<(bool, usize), String> {
+///
+/// In check mode nothing is written: each file that would change is listed
+/// with a unified diff of the change.
+pub fn format_files(
+    files: &[PathBuf],
+    check_mode: bool,
+    options: &FormatOptions,
+) -> Result<(bool, usize), String> {
+    if check_mode {
+        let check = check_files(files, options)?;
+        for file in &check.unformatted {
+            println!(
+                "  {}✗{} {}{}{} (would reformat)",
+                YELLOW,
+                RESET,
+                BOLD,
+                file.path.display(),
+                RESET
+            );
+            print!("{}", file.diff);
+        }
+        return Ok((!check.unformatted.is_empty(), check.unformatted.len()));
+    }
+
     let mut needs_formatting = false;
     let mut reformatted_count = 0;
 
@@ -1489,42 +1888,22 @@ pub fn format_files(files: &[PathBuf], check_mode: bool) -> Result<(bool, usize)
         let content = std::fs::read_to_string(file)
             .map_err(|e| format!("error reading '{}': {}", file.display(), e))?;
 
-        let is_lm_md = file
-            .to_str()
-            .map(|s| s.ends_with(".lm.md"))
-            .unwrap_or(false);
-
-        let formatted = if is_lm_md {
-            format_file(&content)
-        } else {
-            format_lm_source(&content)
-        };
+        let formatted = format_for_path(file, &content, options);
 
         if content != formatted {
             needs_formatting = true;
             reformatted_count += 1;
-            if check_mode {
-                println!(
-                    "  {}✗{} {}{}{} (would reformat)",
-                    YELLOW,
-                    RESET,
-                    BOLD,
-                    file.display(),
-                    RESET
-                );
-            } else {
-                std::fs::write(file, &formatted)
-                    .map_err(|e| format!("error writing '{}': {}", file.display(), e))?;
-                println!(
-                    "  {}✓{} {}{}{} (reformatted)",
-                    GREEN,
-                    RESET,
-                    BOLD,
-                    file.display(),
-                    RESET
-                );
-            }
-        } else if !check_mode {
+            std::fs::write(file, &formatted)
+                .map_err(|e| format!("error writing '{}': {}", file.display(), e))?;
+            println!(
+                "  {}✓{} {}{}{} (reformatted)",
+                GREEN,
+                RESET,
+                BOLD,
+                file.display(),
+                RESET
+            );
+        } else {
             println!(
                 "  {}✓{} {}{}{} (unchanged)",
                 GREEN,
@@ -1617,6 +1996,143 @@ end"#;
         assert!(output.contains("    let msg = \"one\""));
     }
 
+    #[test]
+    fn test_switch_keeps_switch_syntax() {
+        let input = r#"cell name(x: Int) -> String
+  switch x
+    case 1,   2 -> return "small"
+    case 3
+      let msg = "three"
+      return msg
+  end
+  return "other"
+end"#;
+        let output = format_lumen_code(input);
+        let lines: Vec<_> = output.lines().collect();
+        assert_eq!(lines[1], "  switch x");
+        assert_eq!(lines[2], "    case 1, 2 -> return \"small\"");
+        assert_eq!(lines[3], "    case 3");
+        assert_eq!(lines[4], "      let msg = \"three\"");
+        assert_eq!(lines[6], "  end");
+        assert!(!output.contains("default"), "no default was written");
+    }
+
+    #[test]
+    fn test_method_receivers_keep_receiver_syntax() {
+        let input = r#"cell (b: Body) speed() -> Float
+  return b.v
+end
+
+cell (mut b: Body) push(dv:Float)
+  b.v = b.v + dv
+end"#;
+        let output = format_lumen_code(input);
+        let lines: Vec<_> = output.lines().collect();
+        assert_eq!(lines[0], "cell (b: Body) speed() -> Float");
+        assert!(
+            output.contains("cell (mut b: Body) push(dv: Float)"),
+            "got: {}",
+            output
+        );
+    }
+
+    #[test]
+    fn test_const_cell_keeps_const_keyword() {
+        let input = "pub const cell square(x:Float) -> Float\n  return x * x\nend";
+        let output = format_lumen_code(input);
+        assert_eq!(
+            output.lines().next(),
+            Some("pub const cell square(x: Float) -> Float")
+        );
+    }
+
+    #[test]
+    fn test_list_repeat_literal_round_trips() {
+        let input = "cell main() -> list[Bool]\n  let flags = [false;n+1]\n  return flags\nend";
+        let output = format_lumen_code(input);
+        assert!(
+            output.contains("let flags = [false; n + 1]"),
+            "unexpected output: {}",
+            output
+        );
+    }
+
+    #[test]
+    fn test_record_spread_round_trips() {
+        let input = "cell main(b: Body) -> Body\n  return Body(..b,vx: 0.0)\nend";
+        let output = format_lumen_code(input);
+        assert!(
+            output.contains("return Body(..b, vx: 0.0)"),
+            "unexpected output: {}",
+            output
+        );
+    }
+
+    #[test]
+    fn test_three_clause_for_round_trips() {
+        let input = "cell main() -> Int\n  let mut s = 0\n  for let mut i = 0;i < 3;i++\n    s += i\n  end\n  return s\nend";
+        let output = format_lumen_code(input);
+        assert!(
+            output.contains("  for let mut i = 0; i < 3; i += 1\n    s += i\n  end"),
+            "unexpected output: {}",
+            output
+        );
+    }
+
+    const LONG_BODY: &str = "cell jupiter() -> Body\n  return Body(x: 4.84, y: -1.16, z: -0.1, vx: 0.6, vy: 2.8, vz: -0.02, mass: 0.03)\nend";
+
+    #[test]
+    fn test_long_record_literal_fits_default_width() {
+        let output = format_lumen_code(LONG_BODY);
+        assert!(
+            output.contains(
+                "  return Body(x: 4.84, y: -1.16, z: -0.1, vx: 0.6, vy: 2.8, vz: -0.02, mass: 0.03)\n"
+            ),
+            "unexpected output: {}",
+            output
+        );
+    }
+
+    #[test]
+    fn test_long_record_literal_wraps_past_max_width() {
+        let options = FormatOptions {
+            max_width: 60,
+            ..FormatOptions::default()
+        };
+        let output = format_lumen_code_with_options(LONG_BODY, &options);
+        let expected = "  return Body(\n    x: 4.84,\n    y: -1.16,\n    z: -0.1,\n    vx: 0.6,\n    vy: 2.8,\n    vz: -0.02,\n    mass: 0.03\n  )\n";
+        assert!(output.contains(expected), "unexpected output: {}", output);
+
+        // Re-formatting the wrapped form is stable.
+        assert_eq!(format_lumen_code_with_options(&output, &options), output);
+    }
+
+    #[test]
+    fn test_indent_width_and_tabs() {
+        let input = "cell main() -> Int\n  let xs = [Point(x: 1, y: 2), Point(x: 3, y: 4)]\n  return len(xs)\nend";
+        let four = FormatOptions {
+            indent: 4,
+            ..FormatOptions::default()
+        };
+        let output = format_lumen_code_with_options(input, &four);
+        assert!(
+            output.contains("    return len(xs)\n"),
+            "unexpected output: {}",
+            output
+        );
+
+        let tabs = FormatOptions {
+            use_tabs: true,
+            ..FormatOptions::default()
+        };
+        let output = format_lumen_code_with_options(LONG_BODY, &tabs);
+        assert!(
+            output.contains("\treturn Body(x: 4.84,"),
+            "unexpected output: {}",
+            output
+        );
+    }
+
     #[test]
     fn test_markdown_preservation() {
         let input = r#"# Hello
@@ -1681,7 +2197,7 @@ end"#;
     #[test]
     fn test_while_loop() {
         let input = r#"cell count() -> Int
-  let i = 0
+  var i = 0
   while i < 10
     i = i + 1
   end
@@ -1695,7 +2211,7 @@ end"#;
     #[test]
     fn test_for_loop() {
         let input = r#"cell sum(items: list[Int]) -> Int
-  let total = 0
+  var total = 0
   for x in items
     total = total + x
   end
@@ -1709,7 +2225,7 @@ end"#;
     #[test]
     fn test_compound_assignment() {
         let input = r#"cell calc() -> Int
-  let x = 10
+  var x = 10
   x += 5
   x -= 3
   x *= 2
@@ -2004,4 +2520,65 @@ end
         assert!(output.contains("```markdown"), "info string preserved");
         assert!(output.contains("# Title"), "content preserved");
     }
+
+    #[test]
+    fn test_unified_diff_matches_diff_u() {
+        let original: String = (1..=12).map(|i| format!("line {}\n", i)).collect();
+        let formatted = original
+            .replace("line 2\n", "line two\n")
+            .replace("line 12\n", "line 12");
+        let diff = unified_diff("x.lm", &original, &formatted);
+        assert_eq!(
+            diff,
+            "\
+--- x.lm
++++ x.lm (formatted)
+@@ -1,5 +1,5 @@
+ line 1
+-line 2
++line two
+ line 3
+ line 4
+ line 5
+@@ -9,4 +9,4 @@
+ line 9
+ line 10
+ line 11
+-line 12
++line 12
+\\ No newline at end of file
+"
+        );
+        assert_eq!(unified_diff("x.lm", &original, &original), "");
+    }
+
+    #[test]
+    fn test_check_files_reports_diff_without_writing() {
+        let dir = std::env::temp_dir().join(format!("lumen_fmt_check_{}", std::process::id()));
+        std::fs::create_dir_all(&dir).unwrap();
+        let path = dir.join("main.lm");
+        let messy = "cell main() -> Int\nreturn 42\nend\n";
+        std::fs::write(&path, messy).unwrap();
+        let options = FormatOptions::default();
+
+        let check = check_files(std::slice::from_ref(&path), &options).unwrap();
+        assert_eq!(check.exit_code(), 1);
+        assert_eq!(check.unformatted.len(), 1);
+        let diff = &check.unformatted[0].diff;
+        assert!(
+            diff.starts_with(&format!("--- {}\n", path.display())),
+            "{}",
+            diff
+        );
+        assert!(diff.contains("\n-return 42\n"), "{}", diff);
+        assert!(diff.contains("\n+  return 42\n"), "{}", diff);
+        assert_eq!(std::fs::read_to_string(&path).unwrap(), messy);
+
+        std::fs::write(&path, format_lm_source_with_options(messy, &options)).unwrap();
+        let check = check_files(std::slice::from_ref(&path), &options).unwrap();
+        assert_eq!(check.exit_code(), 0);
+        assert!(check.unformatted.is_empty());
+
+        std::fs::remove_dir_all(&dir).unwrap();
+    }
 }
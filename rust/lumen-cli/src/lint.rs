@@ -1,8 +1,9 @@
 //! Lumen linter — style and correctness checks beyond type checking
 //!
-//! Implements 10 lint rules:
+//! Implements 12 lint rules:
 //! - Style: unused-variable, naming-convention, empty-block, redundant-return, long-cell, missing-type-annotation
-//! - Correctness: unreachable-code, infinite-loop, unused-import, shadowed-builtin
+//! - Correctness: unreachable-code, infinite-loop, unused-import, shadowed-builtin, unused-result,
+//!   shadowed-variable
 
 use lumen_compiler::compiler::ast::*;
 use lumen_compiler::markdown::extract::extract_blocks;
@@ -94,6 +95,8 @@ struct Linter {
     warnings: Vec<LintWarning>,
     filename: String,
     builtins: HashSet<String>,
+    /// Callees whose value is a `result`; dropping it drops the error
+    result_calls: HashSet<String>,
 }
 
 impl Linter {
@@ -134,10 +137,32 @@ impl Linter {
             builtins.insert(name.to_string());
         }
 
+        let mut result_calls = HashSet::new();
+        for name in &[
+            "parse_int",
+            "parse_int_radix",
+            "parse_float",
+            "fs_read",
+            "fs_read_chunk",
+            "fs_write",
+            "fs_append",
+            "regex_compile",
+            "hash_new",
+            "utf8_decode",
+            "base64_to_bytes",
+            "hex_to_bytes",
+            "csv_parse_record",
+            "json_unmarshal",
+            "recover",
+        ] {
+            result_calls.insert(name.to_string());
+        }
+
         Self {
             warnings: Vec::new(),
             filename: filename.to_string(),
             builtins,
+            result_calls,
         }
     }
 
@@ -146,6 +171,18 @@ impl Linter {
     }
 
     fn lint_program(&mut self, program: &Program) {
+        // Cells in this file decide whether their call returns a result,
+        // including cells that shadow a builtin
+        for item in &program.items {
+            if let Item::Cell(cell) = item {
+                if matches!(cell.return_type, Some(TypeExpr::Result(_, _, _))) {
+                    self.result_calls.insert(cell.name.clone());
+                } else {
+                    self.result_calls.remove(&cell.name);
+                }
+            }
+        }
+
         // Check unused imports
         self.check_unused_imports(program);
 
@@ -198,12 +235,27 @@ impl Linter {
             }
         }
 
+        // Check for inner bindings that shadow an outer one of the same type
+        let params = cell
+            .params
+            .iter()
+            .map(|p| (p.name.clone(), declared_type(&p.ty)))
+            .collect();
+        self.check_shadowing(&cell.body, &mut vec![params]);
+
         // Check cell body
         self.check_unreachable(&cell.body);
         for stmt in &cell.body {
             self.check_stmt(stmt);
         }
 
+        // A trailing expression is the cell's implicit return value
+        let body = match cell.body.last() {
+            Some(Stmt::Expr(_)) => &cell.body[..cell.body.len() - 1],
+            _ => &cell.body[..],
+        };
+        self.check_unused_results(body);
+
         // Check for redundant return
         if let Some(last_stmt) = cell.body.last() {
             if matches!(last_stmt, Stmt::Return(_)) {
@@ -251,8 +303,14 @@ impl Linter {
                     self.collect_vars(&for_stmt.body, defined, used);
                 }
                 Stmt::While(while_stmt) => {
+                    if let Some(init) = &while_stmt.init {
+                        self.collect_vars(std::slice::from_ref(init.as_ref()), defined, used);
+                    }
                     self.collect_expr_vars(&while_stmt.condition, used);
                     self.collect_vars(&while_stmt.body, defined, used);
+                    if let Some(post) = &while_stmt.post {
+                        self.collect_vars(std::slice::from_ref(post.as_ref()), defined, used);
+                    }
                 }
                 Stmt::Loop(loop_stmt) => {
                     self.collect_vars(&loop_stmt.body, defined, used);
@@ -308,6 +366,10 @@ impl Linter {
                     self.collect_expr_vars(item, used);
                 }
             }
+            Expr::ListRepeat(value, count, _) => {
+                self.collect_expr_vars(value, used);
+                self.collect_expr_vars(count, used);
+            }
             Expr::RecordLit(_, fields, _) => {
                 for (_, val) in fields {
                     self.collect_expr_vars(val, used);
@@ -392,6 +454,130 @@ impl Linter {
         }
     }
 
+    /// Walk a block with the bindings of every enclosing block in `scopes`.
+    /// A binding's type is known when it is annotated or its value is a
+    /// literal; rebinding a name in the same block is not shadowing.
+    fn check_shadowing(
+        &mut self,
+        block: &[Stmt],
+        scopes: &mut Vec<HashMap<String, Option<String>>>,
+    ) {
+        scopes.push(HashMap::new());
+        for stmt in block {
+            match stmt {
+                Stmt::Let(let_stmt) if let_stmt.pattern.is_none() => {
+                    let ty = match &let_stmt.ty {
+                        Some(ty) => declared_type(ty),
+                        None => literal_type(&let_stmt.value),
+                    };
+                    self.check_shadowed(&let_stmt.name, &ty, let_stmt.span.line, scopes);
+                    if let Some(scope) = scopes.last_mut() {
+                        scope.insert(let_stmt.name.clone(), ty);
+                    }
+                }
+                Stmt::If(if_stmt) => {
+                    self.check_shadowing(&if_stmt.then_body, scopes);
+                    if let Some(else_body) = &if_stmt.else_body {
+                        self.check_shadowing(else_body, scopes);
+                    }
+                }
+                Stmt::For(for_stmt) => {
+                    let ty = match for_stmt.iter {
+                        Expr::RangeExpr { .. } => Some("Int".to_string()),
+                        _ => None,
+                    };
+                    self.check_shadowed(&for_stmt.var, &ty, for_stmt.span.line, scopes);
+                    scopes.push(HashMap::from([(for_stmt.var.clone(), ty)]));
+                    self.check_shadowing(&for_stmt.body, scopes);
+                    scopes.pop();
+                }
+                Stmt::While(while_stmt) => self.check_shadowing(&while_stmt.body, scopes),
+                Stmt::Loop(loop_stmt) => self.check_shadowing(&loop_stmt.body, scopes),
+                Stmt::Match(match_stmt) => {
+                    for arm in &match_stmt.arms {
+                        self.check_shadowing(&arm.body, scopes);
+                    }
+                }
+                _ => {}
+            }
+        }
+        scopes.pop();
+    }
+
+    fn check_shadowed(
+        &mut self,
+        name: &str,
+        ty: &Option<String>,
+        line: usize,
+        scopes: &[HashMap<String, Option<String>>],
+    ) {
+        let Some((current, enclosing)) = scopes.split_last() else {
+            return;
+        };
+        if ty.is_none() || name.starts_with('_') || current.contains_key(name) {
+            return;
+        }
+        if enclosing.iter().rev().find_map(|scope| scope.get(name)) == Some(ty) {
+            self.warn(LintWarning::new(
+                "shadowed-variable",
+                Severity::Warning,
+                format!(
+                    "'{}' shadows an outer binding of the same type ({})",
+                    name,
+                    ty.as_deref().unwrap_or_default()
+                ),
+                &self.filename,
+                line,
+                Some(format!(
+                    "to update the outer variable, declare it with var and assign: {} = ...",
+                    name
+                )),
+            ));
+        }
+    }
+
+    fn check_unused_results(&mut self, block: &[Stmt]) {
+        for stmt in block {
+            match stmt {
+                Stmt::Expr(expr_stmt) => {
+                    if let Expr::Call(func, _, _) = &expr_stmt.expr {
+                        if let Expr::Ident(name, _) = func.as_ref() {
+                            if self.result_calls.contains(name) {
+                                self.warn(LintWarning::new(
+                                    "unused-result",
+                                    Severity::Warning,
+                                    format!("result of '{}' is ignored", name),
+                                    &self.filename,
+                                    expr_stmt.span.line,
+                                    Some(format!(
+                                        "handle the error, propagate it with '?', or discard it explicitly: _ = {}(...)",
+                                        name
+                                    )),
+                                ));
+                            }
+                        }
+                    }
+                }
+                Stmt::If(if_stmt) => {
+                    self.check_unused_results(&if_stmt.then_body);
+                    if let Some(else_body) = &if_stmt.else_body {
+                        self.check_unused_results(else_body);
+                    }
+                }
+                Stmt::For(for_stmt) => self.check_unused_results(&for_stmt.body),
+                Stmt::While(while_stmt) => self.check_unused_results(&while_stmt.body),
+                Stmt::Loop(loop_stmt) => self.check_unused_results(&loop_stmt.body),
+                Stmt::Match(match_stmt) => {
+                    for arm in &match_stmt.arms {
+                        self.check_unused_results(&arm.body);
+                    }
+                }
+                Stmt::Defer(defer_stmt) => self.check_unused_results(&defer_stmt.body),
+                _ => {}
+            }
+        }
+    }
+
     fn check_empty_block(&mut self, block: &[Stmt], line: usize, kind: &str) {
         if block.is_empty() {
             self.warn(LintWarning::new(
@@ -619,6 +805,27 @@ impl Linter {
 }
 
 // Helper functions for naming conventions
+/// Name of a simple declared type; None for compound types.
+fn declared_type(ty: &TypeExpr) -> Option<String> {
+    match ty {
+        TypeExpr::Named(name, _) => Some(name.clone()),
+        _ => None,
+    }
+}
+
+/// Type of a literal initializer; None when it takes inference to know.
+fn literal_type(expr: &Expr) -> Option<String> {
+    let name = match expr {
+        Expr::IntLit(_, _) => "Int",
+        Expr::FloatLit(_, _) => "Float",
+        Expr::StringLit(_, _) | Expr::StringInterp(_, _) | Expr::RawStringLit(_, _) => "String",
+        Expr::BoolLit(_, _) => "Bool",
+        Expr::RecordLit(name, _, _) => name.as_str(),
+        _ => return None,
+    };
+    Some(name.to_string())
+}
+
 fn is_snake_case(s: &str) -> bool {
     s.chars()
         .all(|c| c.is_lowercase() || c.is_numeric() || c == '_')
@@ -845,6 +1052,38 @@ end
         assert!(warnings.iter().any(|w| w.rule == "shadowed-builtin"));
     }
 
+    #[test]
+    fn test_shadowed_variable() {
+        let source = r#"
+```lumen
+cell test(n: Int) -> Int
+  var total = 0
+  for i in 0..n
+    let total = 0
+    let label = "row"
+    for i in 0..3
+      let label = 4
+    end
+  end
+  total
+end
+```
+"#;
+        let warnings = lint_file(source, "test.lm.md");
+        let shadowed: Vec<(usize, &str)> = warnings
+            .iter()
+            .filter(|w| w.rule == "shadowed-variable")
+            .map(|w| (w.line, w.message.as_str()))
+            .collect();
+        assert_eq!(
+            shadowed,
+            vec![
+                (4, "'total' shadows an outer binding of the same type (Int)"),
+                (6, "'i' shadows an outer binding of the same type (Int)"),
+            ]
+        );
+    }
+
     #[test]
     fn test_redundant_return() {
         let source = r#"
@@ -874,4 +1113,59 @@ end
         let warnings = lint_file(source, "test.lm.md");
         assert!(warnings.iter().any(|w| w.rule == "infinite-loop"));
     }
+
+    #[test]
+    fn test_unused_result() {
+        let source = r#"
+```lumen
+cell save(path: String) -> result[Int, String]
+  return ok(1)
+end
+
+cell main() -> Int
+  save("out.txt")
+  for i in 0..3
+    fs_write("log.txt", "x")
+  end
+  42
+end
+```
+"#;
+        let warnings = lint_file(source, "test.lm.md");
+        let ignored: Vec<usize> = warnings
+            .iter()
+            .filter(|w| w.rule == "unused-result")
+            .map(|w| w.line)
+            .collect();
+        assert_eq!(ignored, vec![6, 8]);
+    }
+
+    #[test]
+    fn test_unused_result_silenced_by_discard() {
+        let source = r#"
+```lumen
+cell save(path: String) -> result[Int, String]
+  return ok(1)
+end
+
+cell log_line(msg: String) -> Int
+  print(msg)
+  return 0
+end
+
+cell main() -> result[Int, String]
+  _ = save("out.txt")
+  let n = save("out.txt")?
+  log_line("saved")
+  save("again.txt")
+end
+```
+"#;
+        let warnings = lint_file(source, "test.lm.md");
+        assert!(
+            !warnings.iter().any(|w| w.rule == "unused-result"),
+            "unexpected warnings: {:?}",
+            warnings
+        );
+    }
 }
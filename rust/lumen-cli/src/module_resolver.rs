@@ -4,6 +4,8 @@ use std::cell::RefCell;
 use std::collections::HashMap;
 use std::path::{Path, PathBuf};
 
+use lumen_runtime::path::{self as lexical_path, Style};
+
 /// Compile a source file with import resolution.
 pub fn compile_source_file(
     path: &Path,
@@ -82,8 +84,10 @@ impl ModuleResolver {
             return Some(cached.clone());
         }
 
-        // Convert module.path.notation to filesystem path
-        let fs_path = module_path.replace('.', "/");
+        // Convert module.path.notation to a filesystem path with this
+        // platform's separator
+        let segments: Vec<&str> = module_path.split('.').collect();
+        let fs_path = lexical_path::join(&segments, Style::native());
 
         for root in &self.search_roots {
             let candidates = [
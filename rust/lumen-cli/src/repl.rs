@@ -34,7 +34,7 @@ fn gray(s: &str) -> String {
 
 /// Keywords that open a block and require a matching `end`.
 const BLOCK_OPENERS: &[&str] = &[
-    "cell", "if", "while", "for", "match", "record", "enum", "loop",
+    "cell", "if", "while", "for", "match", "switch", "record", "enum", "loop",
 ];
 
 /// Keywords that start a top-level item definition (not an expression).
@@ -82,6 +82,7 @@ const KEYWORDS: &[&str] = &[
     "while",
     "for",
     "match",
+    "switch",
     "loop",
     "break",
     "continue",
@@ -741,7 +742,8 @@ fn needs_more_input(input: &str) -> bool {
 
 /// Keywords that start a statement (not a bare expression).
 const STMT_KEYWORDS: &[&str] = &[
-    "let", "if", "while", "for", "match", "return", "halt", "loop", "break", "continue", "emit",
+    "let", "if", "while", "for", "match", "switch", "return", "halt", "loop", "break", "continue",
+    "emit",
 ];
 
 /// Check if the input looks like a top-level item definition.
@@ -815,7 +817,10 @@ fn eval_input(input: &str, session_state: &mut SessionState) {
     vm.set_provider_registry(registry);
     vm.load(module);
 
-    match vm.execute(&entry, vec![]) {
+    let outcome = vm.execute(&entry, vec![]);
+    let _ = vm.flush_stdout();
+
+    match outcome {
         Ok(result) => {
             // Don't print Null for side-effect-only statements
             if !matches!(result, Value::Null) {
@@ -847,7 +852,10 @@ fn cmd_type(expr: &str, session_state: &SessionState) {
     vm.set_provider_registry(registry);
     vm.load(module);
 
-    match vm.execute("main", vec![]) {
+    let outcome = vm.execute("main", vec![]);
+    let _ = vm.flush_stdout();
+
+    match outcome {
         Ok(result) => println!("{}", cyan(value_type_name(&result))),
         Err(e) => eprintln!("{} {}", red("Error:"), e),
     }
@@ -886,7 +894,10 @@ fn cmd_load(path: &str) {
     vm.set_provider_registry(registry);
     vm.load(module);
 
-    match vm.execute(&entry, vec![]) {
+    let outcome = vm.execute(&entry, vec![]);
+    let _ = vm.flush_stdout();
+
+    match outcome {
         Ok(result) => {
             if !matches!(result, Value::Null) {
                 let type_name = value_type_name(&result);
@@ -1163,7 +1174,10 @@ fn cmd_time(expr: &str, session_state: &SessionState) {
     vm.load(module);
 
     let exec_start = Instant::now();
-    match vm.execute("main", vec![]) {
+    let outcome = vm.execute("main", vec![]);
+    let _ = vm.flush_stdout();
+
+    match outcome {
         Ok(result) => {
             let exec_time = exec_start.elapsed();
             let total_time = compile_start.elapsed();
@@ -1204,6 +1218,7 @@ fn value_type_name(val: &Value) -> &'static str {
         Value::Closure(_) => "Closure",
         Value::TraceRef(_) => "TraceRef",
         Value::Future(_) => "Future",
+        Value::Weak(_) => "Weak",
     }
 }
 
@@ -14,12 +14,14 @@ use std::collections::{BTreeSet, HashMap, HashSet};
 
 use cranelift_codegen::ir::condcodes::{FloatCC, IntCC};
 use cranelift_codegen::ir::{types, AbiParam, InstBuilder, Type as ClifType};
+use cranelift_codegen::isa::TargetIsa;
 use cranelift_codegen::Context;
 use cranelift_frontend::{FunctionBuilder, FunctionBuilderContext, Variable};
 use cranelift_jit::{JITBuilder, JITModule};
 use cranelift_module::{FuncId, Linkage, Module};
 
 use lumen_compiler::compiler::lir::{Constant, Instruction, LirCell, LirModule, OpCode};
+use lumen_compiler::compiler::opt_report::{OptNote, OptReport, Optimization};
 
 use crate::emit::CodegenError;
 use crate::types::lir_type_str_to_cl_type;
@@ -249,6 +251,15 @@ pub struct CodegenSettings {
     /// Optional target triple (e.g. `"x86_64-unknown-linux-gnu"`).
     /// If `None`, the host platform is used.
     pub target: Option<String>,
+    /// Fuse a float multiply followed by an add or subtract of its product
+    /// into one fused multiply-add, on targets with a hardware FMA.
+    ///
+    /// A fused multiply-add rounds once where the separate operations round
+    /// twice, so results can differ from the interpreter's in the last bit,
+    /// and a cell's results would depend on whether it was compiled and on
+    /// the host CPU. Off by default so results match the interpreter bit for
+    /// bit.
+    pub fuse_multiply_add: bool,
 }
 
 impl Default for CodegenSettings {
@@ -256,6 +267,7 @@ impl Default for CodegenSettings {
         Self {
             opt_level: OptLevel::Speed,
             target: None,
+            fuse_multiply_add: false,
         }
     }
 }
@@ -275,6 +287,8 @@ pub struct JitStats {
     pub cache_size: usize,
     /// Number of JIT executions performed.
     pub executions: u64,
+    /// Number of multiply/add pairs fused into a fused multiply-add.
+    pub fused_multiply_adds: u64,
 }
 
 // ---------------------------------------------------------------------------
@@ -348,7 +362,6 @@ pub struct JitEngine {
     /// Cached compiled function pointers keyed by cell name.
     cache: HashMap<String, CompiledFunction>,
     /// Settings for on-demand compilation.
-    #[allow(dead_code)]
     codegen_settings: CodegenSettings,
     /// Compilation statistics.
     stats: JitStats,
@@ -399,9 +412,10 @@ impl JitEngine {
 
         let mut jit_module = JITModule::new(builder);
         let pointer_type = jit_module.isa().pointer_type();
+        let fuse_fma = self.codegen_settings.fuse_multiply_add && isa_has_fma(jit_module.isa());
 
         // Lower all cells into the JIT module.
-        let lowered = lower_module_jit(&mut jit_module, module, pointer_type)?;
+        let lowered = lower_module_jit(&mut jit_module, module, pointer_type, fuse_fma)?;
 
         // Finalize all definitions so we can retrieve function pointers.
         jit_module
@@ -420,6 +434,7 @@ impl JitEngine {
                 },
             );
             self.stats.cells_compiled += 1;
+            self.stats.fused_multiply_adds += func.fused_multiply_adds as u64;
         }
         self.stats.cache_size = self.cache.len();
 
@@ -615,9 +630,14 @@ impl JitEngine {
 /// NewList, etc.) are filtered out before compilation so we never emit traps
 /// for unsupported operations.
 fn is_cell_jit_compilable(cell: &LirCell) -> bool {
-    cell.instructions.iter().all(|instr| {
-        matches!(
-            instr.op,
+    unsupported_opcode(cell).is_none()
+}
+
+/// The first opcode in `cell` that the JIT cannot compile, if any.
+pub fn unsupported_opcode(cell: &LirCell) -> Option<OpCode> {
+    cell.instructions.iter().map(|instr| instr.op).find(|&op| {
+        !matches!(
+            op,
             OpCode::LoadK
                 | OpCode::LoadBool
                 | OpCode::LoadInt
@@ -661,6 +681,27 @@ fn is_cell_jit_compilable(cell: &LirCell) -> bool {
     })
 }
 
+/// Add a JIT note for each cell of `module` to `report`: whether the cell
+/// can run as native code once it is hot, or which opcode keeps it in the
+/// interpreter.
+pub fn note_jit_blockers(module: &LirModule, report: &mut OptReport) {
+    for cell in &module.cells {
+        let note = match unsupported_opcode(cell) {
+            Some(op) => OptNote::blocked(
+                Optimization::Jit,
+                None,
+                format!("uses {:?}, which the JIT does not compile", op),
+            ),
+            None => OptNote::fired(
+                Optimization::Jit,
+                None,
+                "every instruction compiles to native code once the cell is hot",
+            ),
+        };
+        report.note(&cell.name, note);
+    }
+}
+
 // ---------------------------------------------------------------------------
 // JIT-specific lowering (mirrors lower.rs but targets JITModule)
 // ---------------------------------------------------------------------------
@@ -675,6 +716,7 @@ struct JitLoweredFunction {
     func_id: FuncId,
     param_count: usize,
     returns_string: bool,
+    fused_multiply_adds: usize,
 }
 
 /// Lower an entire LIR module into Cranelift IR inside the given `JITModule`.
@@ -684,6 +726,7 @@ fn lower_module_jit(
     module: &mut JITModule,
     lir: &LirModule,
     pointer_type: ClifType,
+    fuse_fma: bool,
 ) -> Result<JitLoweredModule, CodegenError> {
     let mut fb_ctx = FunctionBuilderContext::new();
 
@@ -741,7 +784,15 @@ fn lower_module_jit(
 
     for cell in &compilable_cells {
         let func_id = func_ids[&cell.name];
-        lower_cell_jit(module, cell, &mut fb_ctx, pointer_type, func_id, &func_ids)?;
+        let fused_multiply_adds = lower_cell_jit(
+            module,
+            cell,
+            &mut fb_ctx,
+            pointer_type,
+            func_id,
+            &func_ids,
+            fuse_fma,
+        )?;
         let ret_is_string = cell
             .returns
             .as_deref()
@@ -752,6 +803,7 @@ fn lower_module_jit(
             func_id,
             param_count: cell.params.len(),
             returns_string: ret_is_string,
+            fused_multiply_adds,
         });
     }
 
@@ -832,6 +884,7 @@ fn find_callee_name(
 // Per-cell lowering (JIT variant)
 // ---------------------------------------------------------------------------
 
+/// Lower one cell, returning how many multiply/add pairs were fused.
 fn lower_cell_jit(
     module: &mut JITModule,
     cell: &LirCell,
@@ -839,7 +892,8 @@ fn lower_cell_jit(
     pointer_type: ClifType,
     func_id: FuncId,
     func_ids: &HashMap<String, FuncId>,
-) -> Result<(), CodegenError> {
+    fuse_fma: bool,
+) -> Result<usize, CodegenError> {
     let mut sig = module.make_signature();
     for param in &cell.params {
         let param_ty = lir_type_str_to_cl_type(&param.ty, pointer_type);
@@ -1170,6 +1224,15 @@ fn lower_cell_jit(
 
     let mut terminated = false;
     let mut pending_test: Option<u8> = None;
+    // Register and operands of a float Mul, for an Add or Sub right after it
+    // to fuse with. The Mul is still emitted; it is dead code once every use
+    // of its register is fused.
+    let mut pending_product: Option<(
+        u8,
+        cranelift_codegen::ir::Value,
+        cranelift_codegen::ir::Value,
+    )> = None;
+    let mut fused = 0;
 
     for (pc, inst) in cell.instructions.iter().enumerate() {
         if let Some(&target_block) = block_map.get(&pc) {
@@ -1184,6 +1247,11 @@ fn lower_cell_jit(
             continue;
         }
 
+        // A block can be entered from elsewhere, so only fuse within one
+        let product = pending_product
+            .take()
+            .filter(|_| fuse_fma && !block_map.contains_key(&pc));
+
         match inst.op {
             OpCode::LoadK => {
                 let a = inst.a;
@@ -1345,7 +1413,17 @@ fn lower_cell_jit(
                     var_types.insert(inst.a as u32, JitVarType::Str);
                     def_var(&mut builder, &vars, inst.a, result);
                 } else if lhs_ty == JitVarType::Float || rhs_ty == JitVarType::Float {
-                    let res = builder.ins().fadd(lhs, rhs);
+                    let res = match product {
+                        Some((reg, x, y)) if reg == inst.c => {
+                            fused += 1;
+                            builder.ins().fma(x, y, lhs)
+                        }
+                        Some((reg, x, y)) if reg == inst.b => {
+                            fused += 1;
+                            builder.ins().fma(x, y, rhs)
+                        }
+                        _ => builder.ins().fadd(lhs, rhs),
+                    };
                     var_types.insert(inst.a as u32, JitVarType::Float);
                     def_var(&mut builder, &vars, inst.a, res);
                 } else {
@@ -1359,7 +1437,20 @@ fn lower_cell_jit(
                 let rhs = use_var(&mut builder, &vars, inst.c);
                 let is_float = is_float_op_jvt(&var_types, inst.b, inst.c);
                 let res = if is_float {
-                    builder.ins().fsub(lhs, rhs)
+                    match product {
+                        // lhs - x*y == (-x)*y + lhs, exactly
+                        Some((reg, x, y)) if reg == inst.c => {
+                            fused += 1;
+                            let neg_x = builder.ins().fneg(x);
+                            builder.ins().fma(neg_x, y, lhs)
+                        }
+                        Some((reg, x, y)) if reg == inst.b => {
+                            fused += 1;
+                            let neg_rhs = builder.ins().fneg(rhs);
+                            builder.ins().fma(x, y, neg_rhs)
+                        }
+                        _ => builder.ins().fsub(lhs, rhs),
+                    }
                 } else {
                     builder.ins().isub(lhs, rhs)
                 };
@@ -1378,6 +1469,7 @@ fn lower_cell_jit(
                 let rhs = use_var(&mut builder, &vars, inst.c);
                 let is_float = is_float_op_jvt(&var_types, inst.b, inst.c);
                 let res = if is_float {
+                    pending_product = Some((inst.a, lhs, rhs));
                     builder.ins().fmul(lhs, rhs)
                 } else {
                     builder.ins().imul(lhs, rhs)
@@ -1833,7 +1925,19 @@ fn lower_cell_jit(
         .define_function(func_id, &mut ctx)
         .map_err(|e| CodegenError::LoweringError(format!("define_function({}): {e}", cell.name)))?;
 
-    Ok(())
+    Ok(fused)
+}
+
+/// Whether `isa` has a hardware fused multiply-add. On x86-64 it is an
+/// optional feature; without it Cranelift lowers `fma` to a libm call.
+/// The other backends Cranelift supports always have one.
+fn isa_has_fma(isa: &dyn TargetIsa) -> bool {
+    if isa.name() != "x64" {
+        return true;
+    }
+    isa.isa_flags()
+        .iter()
+        .any(|flag| flag.name == "has_fma" && flag.as_bool() == Some(true))
 }
 
 // ---------------------------------------------------------------------------
@@ -3239,4 +3343,205 @@ mod tests {
         let s = unsafe { jit_take_string(raw) };
         assert_eq!(s, "hello world");
     }
+
+    // --- Fused multiply-add ------------------------------------------------
+
+    /// `cell name(x: Float, y: Float, acc: Float) -> Float` running `body`.
+    fn float_cell(name: &str, body: Vec<Instruction>) -> LirCell {
+        let param = |name: &str, register| LirParam {
+            name: name.to_string(),
+            ty: "Float".to_string(),
+            register,
+            variadic: false,
+        };
+        LirCell {
+            name: name.to_string(),
+            params: vec![param("x", 0), param("y", 1), param("acc", 2)],
+            returns: Some("Float".to_string()),
+            registers: 3,
+            constants: vec![],
+            instructions: body,
+            effect_handler_metas: Vec::new(),
+        }
+    }
+
+    fn call_float3(engine: &JitEngine, name: &str, x: f64, y: f64, acc: f64) -> f64 {
+        let fn_ptr = engine.cache[name].fn_ptr;
+        let code_fn: extern "C" fn(f64, f64, f64) -> f64 = unsafe { std::mem::transmute(fn_ptr) };
+        code_fn(x, y, acc)
+    }
+
+    fn multiply_accumulate_module() -> LirModule {
+        make_module_with_cells(vec![
+            // acc + x * y
+            float_cell(
+                "madd",
+                vec![
+                    Instruction::abc(OpCode::Mul, 0, 0, 1),
+                    Instruction::abc(OpCode::Add, 2, 2, 0),
+                    Instruction::abc(OpCode::Return, 2, 1, 0),
+                ],
+            ),
+            // acc - x * y, as in `vx -= dx * mass`
+            float_cell(
+                "msub",
+                vec![
+                    Instruction::abc(OpCode::Mul, 0, 0, 1),
+                    Instruction::abc(OpCode::Sub, 2, 2, 0),
+                    Instruction::abc(OpCode::Return, 2, 1, 0),
+                ],
+            ),
+        ])
+    }
+
+    // 0.1 * 10.0 rounds to exactly 1.0, so only a fused operation sees the
+    // representation error of 0.1
+    const X: f64 = 0.1;
+    const Y: f64 = 10.0;
+    const ACC: f64 = -1.0;
+
+    fn fma_settings() -> CodegenSettings {
+        CodegenSettings {
+            fuse_multiply_add: true,
+            ..CodegenSettings::default()
+        }
+    }
+
+    #[test]
+    fn jit_fuses_multiply_add_on_fma_hardware() {
+        let lir = multiply_accumulate_module();
+        let mut engine = JitEngine::new(fma_settings(), 0);
+        engine
+            .compile_module(&lir)
+            .expect("JIT compile should succeed");
+
+        if !std::is_x86_feature_detected!("fma") {
+            assert_eq!(engine.stats().fused_multiply_adds, 0);
+            return;
+        }
+        assert_eq!(engine.stats().fused_multiply_adds, 2);
+
+        let madd = call_float3(&engine, "madd", X, Y, ACC);
+        assert_eq!(madd.to_bits(), X.mul_add(Y, ACC).to_bits());
+        assert_ne!(madd, X * Y + ACC);
+        let msub = call_float3(&engine, "msub", X, Y, -ACC);
+        assert_eq!(msub.to_bits(), (-X).mul_add(Y, -ACC).to_bits());
+
+        // Within rounding of the separate operations
+        for &(x, y, acc) in &[(X, Y, ACC), (1.5, -2.25, 3.0), (1e10, 3e-7, -1e3)] {
+            let tolerance = 4.0 * f64::EPSILON * (x * y).abs().max(acc.abs());
+            assert!((call_float3(&engine, "madd", x, y, acc) - (acc + x * y)).abs() <= tolerance);
+            assert!((call_float3(&engine, "msub", x, y, acc) - (acc - x * y)).abs() <= tolerance);
+        }
+    }
+
+    #[test]
+    fn jit_matches_the_interpreter_bit_for_bit_by_default() {
+        let lir = multiply_accumulate_module();
+        let mut engine = JitEngine::new(CodegenSettings::default(), 0);
+        engine
+            .compile_module(&lir)
+            .expect("JIT compile should succeed");
+
+        // Nothing is fused, with or without hardware FMA, so each cell
+        // rounds the product and then the sum as the interpreter's `Mul` and
+        // `Add` do
+        assert_eq!(engine.stats().fused_multiply_adds, 0);
+        for &(x, y, acc) in &[(X, Y, ACC), (1.5, -2.25, 3.0), (1e10, 3e-7, -1e3)] {
+            assert_eq!(
+                call_float3(&engine, "madd", x, y, acc).to_bits(),
+                (acc + x * y).to_bits()
+            );
+            assert_eq!(
+                call_float3(&engine, "msub", x, y, acc).to_bits(),
+                (acc - x * y).to_bits()
+            );
+        }
+        assert_eq!(
+            call_float3(&engine, "madd", X, Y, ACC).to_bits(),
+            0.0f64.to_bits()
+        );
+    }
+
+    #[test]
+    fn jit_fma_keeps_the_product_for_later_uses() {
+        // acc + x*y + x*y: the first Add fuses, the second reads the product
+        let lir = make_module_with_cells(vec![float_cell(
+            "twice",
+            vec![
+                Instruction::abc(OpCode::Mul, 0, 0, 1),
+                Instruction::abc(OpCode::Add, 2, 2, 0),
+                Instruction::abc(OpCode::Add, 2, 2, 0),
+                Instruction::abc(OpCode::Return, 2, 1, 0),
+            ],
+        )]);
+        let mut engine = JitEngine::new(fma_settings(), 0);
+        engine
+            .compile_module(&lir)
+            .expect("JIT compile should succeed");
+
+        let fused = std::is_x86_feature_detected!("fma");
+        assert_eq!(engine.stats().fused_multiply_adds, fused as u64);
+        let first = if fused {
+            X.mul_add(Y, ACC)
+        } else {
+            X * Y + ACC
+        };
+        assert_eq!(
+            call_float3(&engine, "twice", X, Y, ACC).to_bits(),
+            (first + X * Y).to_bits()
+        );
+    }
+
+    #[test]
+    fn opt_report_names_the_opcode_that_keeps_a_loop_out_of_the_jit() {
+        use lumen_compiler::{compile_raw_with_options, CompileOptions};
+        use std::sync::{Arc, Mutex};
+
+        let source = r#"
+cell sum_every_other(xs: list[Int], n: Int) -> Int
+  var total = 0
+  var i = 0
+  while i < n
+    total = total + xs[i]
+    i = i + 2
+  end
+  return total
+end
+
+cell count_up(n: Int) -> Int
+  var i = 0
+  while i < n
+    i = i + 1
+  end
+  return i
+end
+"#;
+        let report = Arc::new(Mutex::new(OptReport::default()));
+        let options = CompileOptions {
+            opt_report: Some(Arc::clone(&report)),
+            ..Default::default()
+        };
+        let module = compile_raw_with_options(source, &options).expect("source should compile");
+        let mut report = report.lock().unwrap().clone();
+        note_jit_blockers(&module, &mut report);
+
+        let jit_note = |cell: &str| {
+            report
+                .cell(cell)
+                .and_then(|c| c.notes.iter().find(|n| n.optimization == Optimization::Jit))
+                .cloned()
+                .unwrap_or_else(|| panic!("no JIT note for {}:\n{}", cell, report))
+        };
+        let blocked = jit_note("sum_every_other");
+        assert!(blocked.blocked);
+        assert_eq!(
+            blocked.detail,
+            "uses GetIndex, which the JIT does not compile"
+        );
+        assert!(!jit_note("count_up").blocked, "{}", report);
+        assert!(report
+            .to_string()
+            .contains("JIT compilation blocked: uses GetIndex"));
+    }
 }
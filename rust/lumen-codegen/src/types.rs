@@ -36,6 +36,8 @@ pub fn lumen_type_to_cl_type(ty: &LumenType, pointer_type: ClifType) -> ClifType
         | LumenType::Fn(_, _)
         | LumenType::Generic(_)
         | LumenType::TypeRef(_, _)
+        | LumenType::Trait(_)
+        | LumenType::Never
         | LumenType::Any => pointer_type,
     }
 }
@@ -454,11 +454,15 @@ mod tests {
                     name: "x".to_string(),
                     ty: "Float".to_string(),
                     constraints: vec![],
+                    default: None,
+                    json: None,
                 },
                 LirField {
                     name: "y".to_string(),
                     ty: "Float".to_string(),
                     constraints: vec![],
+                    default: None,
+                    json: None,
                 },
             ],
             variants: vec![],
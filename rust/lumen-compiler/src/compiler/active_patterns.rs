@@ -281,7 +281,7 @@ fn expr_references_pattern(expr: &super::ast::Expr, pattern_name: &str) -> bool
         Expr::TryExpr(inner, _) | Expr::NullAssert(inner, _) => {
             expr_references_pattern(inner, pattern_name)
         }
-        Expr::NullCoalesce(lhs, rhs, _) => {
+        Expr::NullCoalesce(lhs, rhs, _) | Expr::ListRepeat(lhs, rhs, _) => {
             expr_references_pattern(lhs, pattern_name) || expr_references_pattern(rhs, pattern_name)
         }
         Expr::NullSafeAccess(inner, _, _) => expr_references_pattern(inner, pattern_name),
@@ -129,6 +129,9 @@ pub struct RecordDef {
     pub span: Span,
     pub doc: Option<String>,
     pub deprecated: Option<String>,
+    /// Declared `@packed`: fields keep declaration order with no padding.
+    #[serde(default)]
+    pub packed: bool,
 }
 
 #[derive(Debug, Clone, Serialize, Deserialize)]
@@ -138,6 +141,25 @@ pub struct FieldDef {
     pub default_value: Option<Expr>,
     pub constraint: Option<Expr>,
     pub span: Span,
+    /// `@name(...)` lines written just before the field, such as `@json("id")`
+    #[serde(default)]
+    pub attributes: Vec<FieldAttribute>,
+}
+
+/// An attribute on a record field.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct FieldAttribute {
+    pub name: String,
+    pub args: Vec<AttributeArg>,
+    pub span: Span,
+}
+
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub enum AttributeArg {
+    /// A string literal, such as a renamed key
+    Str(String),
+    /// A bare identifier, such as an option name
+    Ident(String),
 }
 
 // ── Enums ──
@@ -174,11 +196,45 @@ pub struct CellDef {
     pub is_pub: bool,
     pub is_async: bool,
     pub is_extern: bool,
+    /// Declared `const cell`: pure, and callable when initializing a `const`.
+    pub is_const: bool,
     pub must_use: bool,
     pub where_clauses: Vec<Expr>,
     pub span: Span,
     pub doc: Option<String>,
     pub deprecated: Option<String>,
+    /// Set for methods declared as `cell (b: Body) name(...)`; the receiver is `params[0]`.
+    pub receiver: Option<Receiver>,
+    /// Type arguments of each `@specialize(...)` on a generic cell, in
+    /// `generic_params` order.
+    #[serde(default)]
+    pub specializations: Vec<Vec<TypeExpr>>,
+    /// Declared `@tailcall`: every call the cell makes to itself must be a
+    /// tail call.
+    #[serde(default)]
+    pub tailcall: bool,
+    /// Declared `@memoize` or `@memoize(n)`: calls with arguments seen
+    /// before return the cached result.
+    #[serde(default)]
+    pub memoize: Option<Memoize>,
+}
+
+/// Which results a `@memoize` cell keeps.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+pub enum Memoize {
+    /// `@memoize`: every result.
+    Unbounded,
+    /// `@memoize(n)`: the `n` most recently used.
+    Lru(u64),
+}
+
+/// How a method takes its receiver.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+pub enum Receiver {
+    /// `(b: Body)`: the method sees a copy and may not modify it.
+    Value,
+    /// `(mut b: Body)`: changes to the receiver are written back to the caller's variable.
+    Mut,
 }
 
 #[derive(Debug, Clone, Serialize, Deserialize)]
@@ -400,6 +456,9 @@ impl Stmt {
 pub struct LetStmt {
     pub name: String,
     pub mutable: bool,
+    /// Written `var x` rather than `let mut x`; both bind mutably.
+    #[serde(default)]
+    pub is_var: bool,
     pub pattern: Option<Pattern>,
     pub ty: Option<TypeExpr>,
     pub value: Expr,
@@ -429,6 +488,9 @@ pub struct ForStmt {
 pub struct MatchStmt {
     pub subject: Expr,
     pub arms: Vec<MatchArm>,
+    /// Written as `switch`: arms are literal cases and the last arm is the
+    /// (possibly empty) wildcard `default`.
+    pub is_switch: bool,
     pub span: Span,
 }
 
@@ -457,7 +519,8 @@ pub enum Pattern {
     },
     /// Or: pattern1 | pattern2
     Or { patterns: Vec<Pattern>, span: Span },
-    /// List destructure: [a, b, ...rest]
+    /// List destructure: [a, b, ...rest] or [a, b, rest..]; a bare `..`
+    /// gives the rest the name `_`, which binds nothing
     ListDestructure {
         elements: Vec<Pattern>,
         rest: Option<String>,
@@ -557,11 +620,27 @@ pub struct AssignStmt {
 #[derive(Debug, Clone, Serialize, Deserialize)]
 pub struct WhileStmt {
     pub label: Option<String>,
+    /// Runs once before the first test; set by a three-clause
+    /// `for init; cond; post`, whose `let` bindings are scoped to the loop.
+    pub init: Option<Box<Stmt>>,
     pub condition: Expr,
     pub body: Vec<Stmt>,
+    /// Runs after each iteration, including one ended by `continue`.
+    pub post: Option<Box<Stmt>>,
     pub span: Span,
 }
 
+impl WhileStmt {
+    /// `init`, `body`, then `post`, for passes that walk every statement.
+    pub fn all_stmts(&self) -> impl Iterator<Item = &Stmt> {
+        self.init
+            .iter()
+            .map(|s| s.as_ref())
+            .chain(self.body.iter())
+            .chain(self.post.iter().map(|s| s.as_ref()))
+    }
+}
+
 #[derive(Debug, Clone, Serialize, Deserialize)]
 pub struct LoopStmt {
     pub label: Option<String>,
@@ -656,6 +735,8 @@ pub enum Expr {
     Ident(String, Span),
     /// List literal: [a, b, c]
     ListLit(Vec<Expr>, Span),
+    /// Repeated-fill list literal: [value; count]
+    ListRepeat(Box<Expr>, Box<Expr>, Span),
     /// Map literal: {"key": value, ...}
     MapLit(Vec<(Expr, Expr)>, Span),
     /// Record literal: TypeName(field1: val1, field2: val2)
@@ -798,6 +879,7 @@ impl Expr {
             | Expr::BytesLit(_, s)
             | Expr::Ident(_, s)
             | Expr::ListLit(_, s)
+            | Expr::ListRepeat(_, _, s)
             | Expr::MapLit(_, s)
             | Expr::RecordLit(_, _, s)
             | Expr::BinOp(_, _, _, s)
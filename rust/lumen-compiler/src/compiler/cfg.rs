@@ -0,0 +1,321 @@
+//! Control-flow graphs of lowered cells, for `lumen build --emit-cfg`.
+//!
+//! A cell's instructions are split into basic blocks: straight-line runs
+//! that are only entered at the top and only branch at the bottom. The
+//! edges between them follow the VM's own reading of each branch, so a
+//! `Test` that skips the `Jmp` after it, a `ForLoop` jumping back to its
+//! body, and a `JmpTable` entry all show up as edges. Effect handler code,
+//! which is entered from a `perform` rather than a branch, is joined to
+//! its `HandlePush` by a dashed edge.
+//!
+//! [`module_to_dot`] writes every cell of a module as one Graphviz graph,
+//! with a cluster per cell.
+
+use std::collections::BTreeSet;
+
+use crate::compiler::lir::{Instruction, LirCell, LirModule, OpCode};
+
+/// How control gets from one block to the next.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum EdgeKind {
+    /// Running on into the instruction that follows.
+    Next,
+    /// A jump, or a skip over the next instruction.
+    Jump,
+    /// From a `HandlePush` to the handler it installs.
+    Handler,
+}
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub struct Edge {
+    /// Index of the block control moves to.
+    pub to: usize,
+    pub kind: EdgeKind,
+}
+
+/// A straight-line run of instructions, `start..end`.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct BasicBlock {
+    pub start: usize,
+    pub end: usize,
+    pub successors: Vec<Edge>,
+}
+
+/// The basic blocks of one cell, in instruction order.
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub struct Cfg {
+    pub blocks: Vec<BasicBlock>,
+}
+
+impl Cfg {
+    /// Split `instructions` into blocks and link them.
+    pub fn build(instructions: &[Instruction]) -> Self {
+        let n = instructions.len();
+        let exits: Vec<Vec<(usize, EdgeKind)>> = instructions
+            .iter()
+            .enumerate()
+            .map(|(pc, inst)| successors(pc, inst, n))
+            .collect();
+
+        let mut starts = BTreeSet::new();
+        if n > 0 {
+            starts.insert(0);
+        }
+        for (pc, succs) in exits.iter().enumerate() {
+            if succs.as_slice() == [(pc + 1, EdgeKind::Next)] {
+                continue;
+            }
+            starts.extend(succs.iter().map(|&(to, _)| to));
+            if pc + 1 < n {
+                starts.insert(pc + 1);
+            }
+        }
+
+        let starts: Vec<usize> = starts.into_iter().collect();
+        let block_of = |pc: usize| starts.partition_point(|&s| s <= pc) - 1;
+        let blocks = starts
+            .iter()
+            .enumerate()
+            .map(|(i, &start)| {
+                let end = starts.get(i + 1).copied().unwrap_or(n);
+                let mut edges: Vec<Edge> = Vec::new();
+                for &(to, kind) in &exits[end - 1] {
+                    let edge = Edge {
+                        to: block_of(to),
+                        kind,
+                    };
+                    if !edges.contains(&edge) {
+                        edges.push(edge);
+                    }
+                }
+                BasicBlock {
+                    start,
+                    end,
+                    successors: edges,
+                }
+            })
+            .collect();
+        Cfg { blocks }
+    }
+
+    pub fn edge_count(&self) -> usize {
+        self.blocks.iter().map(|b| b.successors.len()).sum()
+    }
+
+    /// For each block, the blocks with an edge into it.
+    pub fn predecessors(&self) -> Vec<Vec<usize>> {
+        let mut preds = vec![Vec::new(); self.blocks.len()];
+        for (i, block) in self.blocks.iter().enumerate() {
+            for edge in &block.successors {
+                preds[edge.to].push(i);
+            }
+        }
+        preds
+    }
+}
+
+/// Where control can go after the instruction at `pc`, leaving out
+/// targets outside the cell's `n` instructions.
+fn successors(pc: usize, inst: &Instruction, n: usize) -> Vec<(usize, EdgeKind)> {
+    let next = pc as i64 + 1;
+    let targets: Vec<(i64, EdgeKind)> = match inst.op {
+        OpCode::Jmp | OpCode::Break | OpCode::Continue => {
+            vec![(next + inst.sax_val() as i64, EdgeKind::Jump)]
+        }
+        OpCode::Return | OpCode::Halt | OpCode::TailCall => vec![],
+        OpCode::Test | OpCode::IsVariant => {
+            vec![(next, EdgeKind::Next), (next + 1, EdgeKind::Jump)]
+        }
+        OpCode::LoadBool if inst.c != 0 => vec![(next + 1, EdgeKind::Jump)],
+        OpCode::Loop => vec![
+            (next, EdgeKind::Next),
+            (next + inst.sbx() as i64, EdgeKind::Jump),
+        ],
+        OpCode::ForPrep => vec![
+            (next, EdgeKind::Next),
+            (next + inst.bx() as i64, EdgeKind::Jump),
+        ],
+        OpCode::ForLoop => vec![
+            (next, EdgeKind::Next),
+            (next - inst.bx() as i64, EdgeKind::Jump),
+        ],
+        OpCode::JmpTable => (0..=inst.b as i64)
+            .map(|i| {
+                let kind = if i == 0 {
+                    EdgeKind::Next
+                } else {
+                    EdgeKind::Jump
+                };
+                (next + i, kind)
+            })
+            .collect(),
+        OpCode::HandlePush => vec![
+            (next, EdgeKind::Next),
+            (pc as i64 + inst.bx() as i64, EdgeKind::Handler),
+        ],
+        _ => vec![(next, EdgeKind::Next)],
+    };
+    targets
+        .into_iter()
+        .filter(|&(to, _)| to >= 0 && (to as usize) < n)
+        .map(|(to, kind)| (to as usize, kind))
+        .collect()
+}
+
+/// One instruction as it appears in a block: its operands, and for a
+/// branch the instruction it goes to.
+fn describe(pc: usize, inst: &Instruction) -> String {
+    let op = format!("{:?}", inst.op);
+    let target = match inst.op {
+        OpCode::Jmp | OpCode::Break | OpCode::Continue => {
+            Some(pc as i64 + 1 + inst.sax_val() as i64)
+        }
+        OpCode::Loop => Some(pc as i64 + 1 + inst.sbx() as i64),
+        OpCode::ForPrep => Some(pc as i64 + 1 + inst.bx() as i64),
+        OpCode::ForLoop => Some(pc as i64 + 1 - inst.bx() as i64),
+        OpCode::HandlePush => Some(pc as i64 + inst.bx() as i64),
+        _ => None,
+    };
+    match target {
+        Some(to) if inst.op == OpCode::Jmp => format!("{:>4}  {:<10} -> {}", pc, op, to),
+        Some(to) => format!("{:>4}  {:<10} {} -> {}", pc, op, inst.a, to),
+        None => format!("{:>4}  {:<10} {} {} {}", pc, op, inst.a, inst.b, inst.c),
+    }
+}
+
+/// Quote `s` as a DOT string.
+fn quote(s: &str) -> String {
+    format!("\"{}\"", s.replace('\\', "\\\\").replace('"', "\\\""))
+}
+
+/// Write `cell`'s graph into `out` as a cluster whose node names start
+/// with `prefix`.
+fn write_cell(out: &mut String, prefix: &str, cell: &LirCell) {
+    let cfg = Cfg::build(&cell.instructions);
+    out.push_str(&format!("  subgraph cluster_{} {{\n", prefix));
+    out.push_str(&format!("    label={};\n", quote(&cell.name)));
+    for (i, block) in cfg.blocks.iter().enumerate() {
+        let mut label = format!("b{}\\l", i);
+        for pc in block.start..block.end {
+            label.push_str(&describe(pc, &cell.instructions[pc]));
+            label.push_str("\\l");
+        }
+        out.push_str(&format!("    {}_b{} [label=\"{}\"];\n", prefix, i, label));
+    }
+    for (i, block) in cfg.blocks.iter().enumerate() {
+        for edge in &block.successors {
+            let style = match edge.kind {
+                EdgeKind::Handler => " [style=dashed, label=\"handler\"]",
+                EdgeKind::Next | EdgeKind::Jump => "",
+            };
+            out.push_str(&format!(
+                "    {p}_b{} -> {p}_b{}{};\n",
+                i,
+                edge.to,
+                style,
+                p = prefix
+            ));
+        }
+    }
+    out.push_str("  }\n");
+}
+
+/// Every cell's control-flow graph as a Graphviz DOT `digraph`.
+pub fn module_to_dot(module: &LirModule) -> String {
+    let mut out = String::from("digraph cfg {\n");
+    out.push_str("  node [shape=box, fontname=\"monospace\"];\n");
+    for (i, cell) in module.cells.iter().enumerate() {
+        write_cell(&mut out, &format!("c{}", i), cell);
+    }
+    out.push_str("}\n");
+    out
+}
+
+// ---------------------------------------------------------------------------
+// Tests
+// ---------------------------------------------------------------------------
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn edges(cfg: &Cfg) -> Vec<(usize, usize)> {
+        cfg.blocks
+            .iter()
+            .enumerate()
+            .flat_map(|(i, b)| b.successors.iter().map(move |e| (i, e.to)))
+            .collect()
+    }
+
+    #[test]
+    fn for_loop_jumps_back_to_its_body() {
+        //  0: ForPrep  r0 +3   (→ 4 when empty)
+        //  1: Add      r4 r4 r3
+        //  2: Nop
+        //  3: ForLoop  r0 -3   (→ 1 while items remain)
+        //  4: Return   r4
+        let cfg = Cfg::build(&[
+            Instruction::abx(OpCode::ForPrep, 0, 3),
+            Instruction::abc(OpCode::Add, 4, 4, 3),
+            Instruction::abc(OpCode::Nop, 0, 0, 0),
+            Instruction::abx(OpCode::ForLoop, 0, 3),
+            Instruction::abc(OpCode::Return, 4, 1, 0),
+        ]);
+        let spans: Vec<(usize, usize)> = cfg.blocks.iter().map(|b| (b.start, b.end)).collect();
+        assert_eq!(spans, vec![(0, 1), (1, 4), (4, 5)]);
+        assert_eq!(edges(&cfg), vec![(0, 1), (0, 2), (1, 2), (1, 1)]);
+    }
+
+    #[test]
+    fn jump_table_entries_and_skips_are_edges() {
+        //  0: JmpTable r0 2 r1
+        //  1: Jmp      +2      (→ 4)
+        //  2: Jmp      +2      (→ 5)
+        //  3: LoadBool r2 1 1  (skips 4)
+        //  4: Halt     r2
+        //  5: Return   r2
+        let cfg = Cfg::build(&[
+            Instruction::abc(OpCode::JmpTable, 0, 2, 1),
+            Instruction::sax(OpCode::Jmp, 2),
+            Instruction::sax(OpCode::Jmp, 2),
+            Instruction::abc(OpCode::LoadBool, 2, 1, 1),
+            Instruction::abc(OpCode::Halt, 2, 0, 0),
+            Instruction::abc(OpCode::Return, 2, 1, 0),
+        ]);
+        assert_eq!(cfg.blocks.len(), 6);
+        assert_eq!(
+            edges(&cfg),
+            vec![(0, 1), (0, 2), (0, 3), (1, 4), (2, 5), (3, 5)]
+        );
+        assert_eq!(cfg.blocks[3].successors[0].kind, EdgeKind::Jump);
+    }
+
+    #[test]
+    fn handler_code_hangs_off_its_push() {
+        //  0: HandlePush 0 +3  (handler at 3)
+        //  1: HandlePop
+        //  2: Return   r0
+        //  3: Resume   r1
+        let cfg = Cfg::build(&[
+            Instruction::abx(OpCode::HandlePush, 0, 3),
+            Instruction::abc(OpCode::HandlePop, 0, 0, 0),
+            Instruction::abc(OpCode::Return, 0, 1, 0),
+            Instruction::abc(OpCode::Resume, 1, 0, 0),
+        ]);
+        assert_eq!(cfg.blocks.len(), 3);
+        assert_eq!(
+            cfg.blocks[0].successors,
+            vec![
+                Edge {
+                    to: 1,
+                    kind: EdgeKind::Next
+                },
+                Edge {
+                    to: 2,
+                    kind: EdgeKind::Handler
+                },
+            ]
+        );
+        assert!(Cfg::build(&[]).blocks.is_empty());
+    }
+}
@@ -0,0 +1,674 @@
+//! Compile-time evaluation of constants.
+//!
+//! `comptime` expressions and `const` initializers are reduced here to
+//! literal values. A `const` initializer may call `const cell`s, whose bodies
+//! are restricted to pure operations: immutable `let`, `if`, `return`,
+//! arithmetic on scalars, other constants and `const cell`s, and the pure math
+//! builtins. Anything that performs I/O or mutates state is rejected; pure
+//! forms the evaluator cannot reduce (list literals, field access, ...) are
+//! left for the runtime.
+
+use crate::compiler::ast::*;
+use crate::compiler::typecheck::is_builtin_function;
+use num_bigint::BigInt;
+use std::collections::{HashMap, HashSet};
+
+/// Nested `const cell` calls allowed in one evaluation before giving up.
+const MAX_CONST_CALL_DEPTH: usize = 256;
+
+/// Result of compile-time constant evaluation.
+#[derive(Debug, Clone)]
+pub enum ConstValue {
+    Int(i64),
+    BigInt(BigInt),
+    Float(f64),
+    String(String),
+    Bool(bool),
+    Null,
+}
+
+/// A constant or `const cell` that uses an operation not allowed at compile time.
+#[derive(Debug, Clone)]
+pub struct ConstEvalError {
+    pub name: String,
+    pub reason: String,
+    pub line: usize,
+}
+
+/// Attempt to evaluate an expression at compile time.
+///
+/// Returns `Some(ConstValue)` if the expression can be fully reduced to a
+/// constant, `None` otherwise (e.g. when it references variables or calls).
+pub fn try_const_eval(expr: &Expr) -> Option<ConstValue> {
+    match expr {
+        // Leaf literals
+        Expr::IntLit(n, _) => Some(ConstValue::Int(*n)),
+        Expr::BigIntLit(n, _) => Some(ConstValue::BigInt(n.clone())),
+        Expr::FloatLit(f, _) => Some(ConstValue::Float(*f)),
+        Expr::StringLit(s, _) | Expr::RawStringLit(s, _) => Some(ConstValue::String(s.clone())),
+        Expr::BoolLit(b, _) => Some(ConstValue::Bool(*b)),
+        Expr::NullLit(_) => Some(ConstValue::Null),
+
+        Expr::UnaryOp(op, inner, _) => fold_unary(op, try_const_eval(inner)?),
+        Expr::BinOp(lhs, op, rhs, _) => fold_binop(try_const_eval(lhs)?, op, try_const_eval(rhs)?),
+
+        // Block expressions: evaluate if the block is a single expression statement
+        Expr::BlockExpr(stmts, _) => {
+            if stmts.len() == 1 {
+                if let Stmt::Expr(ExprStmt { expr: inner, .. }) = &stmts[0] {
+                    return try_const_eval(inner);
+                }
+                if let Stmt::Return(ReturnStmt { value: inner, .. }) = &stmts[0] {
+                    return try_const_eval(inner);
+                }
+            }
+            None
+        }
+
+        // Nested comptime just recurses
+        Expr::ComptimeExpr(inner, _) => try_const_eval(inner),
+
+        // Anything else (variables, calls, etc.) is not a compile-time constant
+        _ => None,
+    }
+}
+
+fn fold_unary(op: &UnaryOp, val: ConstValue) -> Option<ConstValue> {
+    match (op, val) {
+        (UnaryOp::Neg, ConstValue::Int(n)) => Some(ConstValue::Int(n.wrapping_neg())),
+        (UnaryOp::Neg, ConstValue::Float(f)) => Some(ConstValue::Float(-f)),
+        (UnaryOp::Neg, ConstValue::BigInt(n)) => {
+            let negated = -n;
+            // If the negated BigInt fits in i64, fold to Int
+            use num_traits::ToPrimitive;
+            if let Some(i) = negated.to_i64() {
+                Some(ConstValue::Int(i))
+            } else {
+                Some(ConstValue::BigInt(negated))
+            }
+        }
+        (UnaryOp::Not, ConstValue::Bool(b)) => Some(ConstValue::Bool(!b)),
+        (UnaryOp::BitNot, ConstValue::Int(n)) => Some(ConstValue::Int(!n)),
+        _ => None,
+    }
+}
+
+fn is_comparison(op: &BinOp) -> bool {
+    matches!(
+        op,
+        BinOp::Eq | BinOp::NotEq | BinOp::Lt | BinOp::LtEq | BinOp::Gt | BinOp::GtEq
+    )
+}
+
+fn fold_binop(l: ConstValue, op: &BinOp, r: ConstValue) -> Option<ConstValue> {
+    match (l, op, r) {
+        // Int arithmetic
+        (ConstValue::Int(a), BinOp::Add, ConstValue::Int(b)) => {
+            Some(ConstValue::Int(a.wrapping_add(b)))
+        }
+        (ConstValue::Int(a), BinOp::Sub, ConstValue::Int(b)) => {
+            Some(ConstValue::Int(a.wrapping_sub(b)))
+        }
+        (ConstValue::Int(a), BinOp::Mul, ConstValue::Int(b)) => {
+            Some(ConstValue::Int(a.wrapping_mul(b)))
+        }
+        (ConstValue::Int(a), BinOp::Div, ConstValue::Int(b)) if b != 0 => {
+            Some(ConstValue::Int(a / b))
+        }
+        (ConstValue::Int(a), BinOp::FloorDiv, ConstValue::Int(b)) if b != 0 => {
+            Some(ConstValue::Int(a.div_euclid(b)))
+        }
+        (ConstValue::Int(a), BinOp::Mod, ConstValue::Int(b)) if b != 0 => {
+            Some(ConstValue::Int(a.rem_euclid(b)))
+        }
+        (ConstValue::Int(a), BinOp::Pow, ConstValue::Int(b)) if b >= 0 => {
+            Some(ConstValue::Int(a.wrapping_pow(b as u32)))
+        }
+        (ConstValue::Int(a), BinOp::BitAnd, ConstValue::Int(b)) => Some(ConstValue::Int(a & b)),
+        (ConstValue::Int(a), BinOp::BitOr, ConstValue::Int(b)) => Some(ConstValue::Int(a | b)),
+        (ConstValue::Int(a), BinOp::BitXor, ConstValue::Int(b)) => Some(ConstValue::Int(a ^ b)),
+        (ConstValue::Int(a), BinOp::Shl, ConstValue::Int(b)) if (0..64).contains(&b) => {
+            Some(ConstValue::Int(a.wrapping_shl(b as u32)))
+        }
+        (ConstValue::Int(a), BinOp::Shr, ConstValue::Int(b)) if (0..64).contains(&b) => {
+            Some(ConstValue::Int(a.wrapping_shr(b as u32)))
+        }
+
+        // Float arithmetic
+        (ConstValue::Float(a), BinOp::Add, ConstValue::Float(b)) => Some(ConstValue::Float(a + b)),
+        (ConstValue::Float(a), BinOp::Sub, ConstValue::Float(b)) => Some(ConstValue::Float(a - b)),
+        (ConstValue::Float(a), BinOp::Mul, ConstValue::Float(b)) => Some(ConstValue::Float(a * b)),
+        (ConstValue::Float(a), BinOp::Div, ConstValue::Float(b)) if b != 0.0 => {
+            Some(ConstValue::Float(a / b))
+        }
+        (ConstValue::Float(a), BinOp::Pow, ConstValue::Float(b)) => {
+            Some(ConstValue::Float(a.powf(b)))
+        }
+
+        // Mixed int/float promotion
+        (ConstValue::Int(a), BinOp::Add, ConstValue::Float(b)) => {
+            Some(ConstValue::Float(a as f64 + b))
+        }
+        (ConstValue::Float(a), BinOp::Add, ConstValue::Int(b)) => {
+            Some(ConstValue::Float(a + b as f64))
+        }
+        (ConstValue::Int(a), BinOp::Sub, ConstValue::Float(b)) => {
+            Some(ConstValue::Float(a as f64 - b))
+        }
+        (ConstValue::Float(a), BinOp::Sub, ConstValue::Int(b)) => {
+            Some(ConstValue::Float(a - b as f64))
+        }
+        (ConstValue::Int(a), BinOp::Mul, ConstValue::Float(b)) => {
+            Some(ConstValue::Float(a as f64 * b))
+        }
+        (ConstValue::Float(a), BinOp::Mul, ConstValue::Int(b)) => {
+            Some(ConstValue::Float(a * b as f64))
+        }
+        (ConstValue::Int(a), BinOp::Div, ConstValue::Float(b)) if b != 0.0 => {
+            Some(ConstValue::Float(a as f64 / b))
+        }
+        (ConstValue::Float(a), BinOp::Div, ConstValue::Int(b)) if b != 0 => {
+            Some(ConstValue::Float(a / b as f64))
+        }
+
+        // String concatenation
+        (ConstValue::String(a), BinOp::Add, ConstValue::String(b)) => {
+            Some(ConstValue::String(format!("{}{}", a, b)))
+        }
+        (ConstValue::String(a), BinOp::Concat, ConstValue::String(b)) => {
+            Some(ConstValue::String(format!("{}{}", a, b)))
+        }
+
+        // Comparison (Int)
+        (ConstValue::Int(a), BinOp::Eq, ConstValue::Int(b)) => Some(ConstValue::Bool(a == b)),
+        (ConstValue::Int(a), BinOp::NotEq, ConstValue::Int(b)) => Some(ConstValue::Bool(a != b)),
+        (ConstValue::Int(a), BinOp::Lt, ConstValue::Int(b)) => Some(ConstValue::Bool(a < b)),
+        (ConstValue::Int(a), BinOp::LtEq, ConstValue::Int(b)) => Some(ConstValue::Bool(a <= b)),
+        (ConstValue::Int(a), BinOp::Gt, ConstValue::Int(b)) => Some(ConstValue::Bool(a > b)),
+        (ConstValue::Int(a), BinOp::GtEq, ConstValue::Int(b)) => Some(ConstValue::Bool(a >= b)),
+
+        // Boolean logic
+        (ConstValue::Bool(a), BinOp::And, ConstValue::Bool(b)) => Some(ConstValue::Bool(a && b)),
+        (ConstValue::Bool(a), BinOp::Or, ConstValue::Bool(b)) => Some(ConstValue::Bool(a || b)),
+
+        // Spaceship (Int)
+        (ConstValue::Int(a), BinOp::Spaceship, ConstValue::Int(b)) => {
+            Some(ConstValue::Int(if a < b {
+                -1
+            } else if a == b {
+                0
+            } else {
+                1
+            }))
+        }
+
+        // Comparison (Float, mixed)
+        (ConstValue::Float(a), BinOp::Lt, ConstValue::Float(b)) => Some(ConstValue::Bool(a < b)),
+        (ConstValue::Float(a), BinOp::LtEq, ConstValue::Float(b)) => Some(ConstValue::Bool(a <= b)),
+        (ConstValue::Float(a), BinOp::Gt, ConstValue::Float(b)) => Some(ConstValue::Bool(a > b)),
+        (ConstValue::Float(a), BinOp::GtEq, ConstValue::Float(b)) => Some(ConstValue::Bool(a >= b)),
+        (ConstValue::Float(a), BinOp::Eq, ConstValue::Float(b)) => Some(ConstValue::Bool(a == b)),
+        (ConstValue::Float(a), BinOp::NotEq, ConstValue::Float(b)) => {
+            Some(ConstValue::Bool(a != b))
+        }
+        (ConstValue::Int(a), cmp, ConstValue::Float(b)) if is_comparison(cmp) => {
+            fold_binop(ConstValue::Float(a as f64), cmp, ConstValue::Float(b))
+        }
+        (ConstValue::Float(a), cmp, ConstValue::Int(b)) if is_comparison(cmp) => {
+            fold_binop(ConstValue::Float(a), cmp, ConstValue::Float(b as f64))
+        }
+
+        // Equality (String, Bool)
+        (ConstValue::String(a), BinOp::Eq, ConstValue::String(b)) => Some(ConstValue::Bool(a == b)),
+        (ConstValue::String(a), BinOp::NotEq, ConstValue::String(b)) => {
+            Some(ConstValue::Bool(a != b))
+        }
+        (ConstValue::Bool(a), BinOp::Eq, ConstValue::Bool(b)) => Some(ConstValue::Bool(a == b)),
+        (ConstValue::Bool(a), BinOp::NotEq, ConstValue::Bool(b)) => Some(ConstValue::Bool(a != b)),
+
+        _ => None,
+    }
+}
+
+/// Evaluate every top-level `const` at compile time and check that each
+/// `const cell` body is pure.
+///
+/// Returns the values of the constants that reduced to a literal; the others
+/// keep their initializer, which is inlined and runs at each use.
+pub fn evaluate_consts(program: &Program) -> (HashMap<String, ConstValue>, Vec<ConstEvalError>) {
+    let mut eval = ConstEvaluator::new(program);
+    for item in &program.items {
+        if let Item::ConstDecl(c) = item {
+            eval.eval_const(&c.name);
+        }
+    }
+    let values = eval
+        .values
+        .into_iter()
+        .filter_map(|(name, value)| Some((name, value?)))
+        .collect();
+    (values, eval.errors)
+}
+
+/// Why an expression did not reduce to a value.
+enum Stop {
+    /// Pure, but not reducible at compile time; the runtime evaluates it.
+    Runtime,
+    /// Not allowed in a const context: the reason and its line.
+    Impure(String, usize),
+}
+
+type Env = HashMap<String, ConstValue>;
+
+struct ConstEvaluator<'a> {
+    consts: HashMap<&'a str, &'a Expr>,
+    cells: HashMap<&'a str, &'a CellDef>,
+    /// `const cell`s whose bodies passed the purity check.
+    pure_cells: HashSet<&'a str>,
+    /// Evaluated constants; `None` for those left to the runtime.
+    values: HashMap<String, Option<ConstValue>>,
+    in_progress: HashSet<String>,
+    errors: Vec<ConstEvalError>,
+    depth: usize,
+}
+
+impl<'a> ConstEvaluator<'a> {
+    fn new(program: &'a Program) -> Self {
+        let mut eval = Self {
+            consts: HashMap::new(),
+            cells: HashMap::new(),
+            pure_cells: HashSet::new(),
+            values: HashMap::new(),
+            in_progress: HashSet::new(),
+            errors: Vec::new(),
+            depth: 0,
+        };
+        for item in &program.items {
+            match item {
+                Item::ConstDecl(c) => {
+                    eval.consts.insert(&c.name, &c.value);
+                }
+                Item::Cell(cell) => {
+                    eval.cells.insert(&cell.name, cell);
+                }
+                _ => {}
+            }
+        }
+        for item in &program.items {
+            let Item::Cell(cell) = item else { continue };
+            if !cell.is_const {
+                continue;
+            }
+            match eval.check_const_cell(cell) {
+                Ok(()) => {
+                    eval.pure_cells.insert(&cell.name);
+                }
+                Err((reason, line)) => eval.errors.push(ConstEvalError {
+                    name: cell.name.clone(),
+                    reason,
+                    line,
+                }),
+            }
+        }
+        eval
+    }
+
+    fn eval_const(&mut self, name: &str) -> Option<ConstValue> {
+        if let Some(value) = self.values.get(name) {
+            return value.clone();
+        }
+        let expr = *self.consts.get(name)?;
+        if !self.in_progress.insert(name.to_string()) {
+            return None;
+        }
+        let value = match self.eval_expr(expr, &Env::new()) {
+            Ok(value) => Some(value),
+            Err(Stop::Runtime) => None,
+            Err(Stop::Impure(reason, line)) => {
+                self.errors.push(ConstEvalError {
+                    name: name.to_string(),
+                    reason,
+                    line,
+                });
+                None
+            }
+        };
+        self.in_progress.remove(name);
+        self.values.insert(name.to_string(), value.clone());
+        value
+    }
+
+    fn eval_expr(&mut self, expr: &'a Expr, env: &Env) -> Result<ConstValue, Stop> {
+        match expr {
+            Expr::Ident(name, _) => {
+                if let Some(value) = env.get(name) {
+                    Ok(value.clone())
+                } else if self.consts.contains_key(name.as_str()) {
+                    self.eval_const(name).ok_or(Stop::Runtime)
+                } else {
+                    builtin_math_constant(name).ok_or(Stop::Runtime)
+                }
+            }
+            Expr::UnaryOp(op, inner, _) => {
+                let value = self.eval_expr(inner, env)?;
+                fold_unary(op, value).ok_or(Stop::Runtime)
+            }
+            Expr::BinOp(lhs, op, rhs, _) => {
+                let l = self.eval_expr(lhs, env);
+                let r = self.eval_expr(rhs, env);
+                match (l, r) {
+                    (Ok(l), Ok(r)) => fold_binop(l, op, r).ok_or(Stop::Runtime),
+                    (Err(impure @ Stop::Impure(..)), _) | (_, Err(impure @ Stop::Impure(..))) => {
+                        Err(impure)
+                    }
+                    _ => Err(Stop::Runtime),
+                }
+            }
+            Expr::IfExpr {
+                cond,
+                then_val,
+                else_val,
+                ..
+            } => match self.eval_expr(cond, env)? {
+                ConstValue::Bool(true) => self.eval_expr(then_val, env),
+                ConstValue::Bool(false) => self.eval_expr(else_val, env),
+                _ => Err(Stop::Runtime),
+            },
+            Expr::Call(callee, args, span) => {
+                let mut values = Vec::new();
+                let mut runtime = false;
+                for arg in args {
+                    let (CallArg::Positional(e) | CallArg::Named(_, e, _) | CallArg::Role(_, e, _)) =
+                        arg;
+                    match self.eval_expr(e, env) {
+                        Ok(value) => values.push(value),
+                        Err(Stop::Runtime) => runtime = true,
+                        Err(impure) => return Err(impure),
+                    }
+                }
+                let Expr::Ident(name, _) = callee.as_ref() else {
+                    return Err(Stop::Runtime);
+                };
+                if let Err(reason) = self.check_callee(name) {
+                    return Err(Stop::Impure(reason, span.line));
+                }
+                if runtime || args.iter().any(|a| !matches!(a, CallArg::Positional(_))) {
+                    return Err(Stop::Runtime);
+                }
+                match self.cells.get(name.as_str()).copied() {
+                    Some(cell) => self.call_const_cell(cell, values, span.line),
+                    None => eval_pure_builtin(name, &values).ok_or(Stop::Runtime),
+                }
+            }
+            Expr::ListLit(items, _) | Expr::TupleLit(items, _) | Expr::SetLit(items, _) => {
+                // Not reducible, but an impure element still disqualifies the constant.
+                for item in items {
+                    if let Err(impure @ Stop::Impure(..)) = self.eval_expr(item, env) {
+                        return Err(impure);
+                    }
+                }
+                Err(Stop::Runtime)
+            }
+            Expr::ListRepeat(value, count, _) => {
+                for part in [value, count] {
+                    if let Err(impure @ Stop::Impure(..)) = self.eval_expr(part, env) {
+                        return Err(impure);
+                    }
+                }
+                Err(Stop::Runtime)
+            }
+            Expr::ComptimeExpr(inner, _) => self.eval_expr(inner, env),
+            _ => try_const_eval(expr).ok_or(Stop::Runtime),
+        }
+    }
+
+    fn call_const_cell(
+        &mut self,
+        cell: &'a CellDef,
+        args: Vec<ConstValue>,
+        line: usize,
+    ) -> Result<ConstValue, Stop> {
+        if !self.pure_cells.contains(cell.name.as_str()) || args.len() != cell.params.len() {
+            return Err(Stop::Runtime);
+        }
+        if self.depth >= MAX_CONST_CALL_DEPTH {
+            return Err(Stop::Impure(
+                format!(
+                    "more than {} nested `const cell` calls",
+                    MAX_CONST_CALL_DEPTH
+                ),
+                line,
+            ));
+        }
+        let mut env: Env = cell
+            .params
+            .iter()
+            .map(|p| p.name.clone())
+            .zip(args)
+            .collect();
+        self.depth += 1;
+        let result = self.exec_body(&cell.body, &mut env, true);
+        self.depth -= 1;
+        Ok(result?.unwrap_or(ConstValue::Null))
+    }
+
+    /// Run a `const cell` body; `Some` once a `return` (or the trailing
+    /// expression of the cell) produces the result.
+    fn exec_body(
+        &mut self,
+        body: &'a [Stmt],
+        env: &mut Env,
+        is_cell_body: bool,
+    ) -> Result<Option<ConstValue>, Stop> {
+        for (i, stmt) in body.iter().enumerate() {
+            match stmt {
+                Stmt::Let(ls) if ls.pattern.is_none() => {
+                    let value = self.eval_expr(&ls.value, env)?;
+                    env.insert(ls.name.clone(), value);
+                }
+                Stmt::If(ifs) => {
+                    let branch = match self.eval_expr(&ifs.condition, env)? {
+                        ConstValue::Bool(true) => Some(&ifs.then_body),
+                        ConstValue::Bool(false) => ifs.else_body.as_ref(),
+                        _ => return Err(Stop::Runtime),
+                    };
+                    if let Some(branch) = branch {
+                        let mut scope = env.clone();
+                        if let Some(value) = self.exec_body(branch, &mut scope, false)? {
+                            return Ok(Some(value));
+                        }
+                    }
+                }
+                Stmt::Return(ret) => return self.eval_expr(&ret.value, env).map(Some),
+                Stmt::Expr(es) => {
+                    let value = self.eval_expr(&es.expr, env)?;
+                    if is_cell_body && i + 1 == body.len() {
+                        return Ok(Some(value));
+                    }
+                }
+                _ => return Err(Stop::Runtime),
+            }
+        }
+        Ok(None)
+    }
+
+    /// Check that `name` may be called in a const context.
+    fn check_callee(&self, name: &str) -> Result<(), String> {
+        match self.cells.get(name) {
+            Some(cell) if cell.is_const => Ok(()),
+            Some(_) => Err(format!("calls '{}', which is not a `const cell`", name)),
+            None if is_pure_builtin(name) => Ok(()),
+            None if is_builtin_function(name) => Err(format!(
+                "calls '{}', which is not pure; only `const cell`s and math builtins are allowed",
+                name
+            )),
+            // Imported or unknown: left to the runtime.
+            None => Ok(()),
+        }
+    }
+
+    /// Reject statements and calls in a `const cell` body that are not pure.
+    fn check_const_cell(&self, cell: &CellDef) -> Result<(), (String, usize)> {
+        if let Some(effect) = cell.effects.first() {
+            return Err((format!("declares effect '{}'", effect), cell.span.line));
+        }
+        self.check_stmts(&cell.body)
+    }
+
+    fn check_stmts(&self, stmts: &[Stmt]) -> Result<(), (String, usize)> {
+        for stmt in stmts {
+            let line = stmt.span().line;
+            match stmt {
+                Stmt::Let(ls) if ls.mutable => {
+                    return Err(("`let mut` declares mutable state".to_string(), line));
+                }
+                Stmt::Let(ls) => self.check_expr(&ls.value)?,
+                Stmt::If(ifs) => {
+                    self.check_expr(&ifs.condition)?;
+                    self.check_stmts(&ifs.then_body)?;
+                    if let Some(else_body) = &ifs.else_body {
+                        self.check_stmts(else_body)?;
+                    }
+                }
+                Stmt::Return(ret) => self.check_expr(&ret.value)?,
+                Stmt::Expr(es) => self.check_expr(&es.expr)?,
+                Stmt::Assign(_) | Stmt::CompoundAssign(_) => {
+                    return Err(("assignment mutates state".to_string(), line));
+                }
+                Stmt::For(_) | Stmt::While(_) | Stmt::Loop(_) => {
+                    return Err(("loops are not allowed; use recursion".to_string(), line));
+                }
+                _ => {
+                    return Err((
+                        "this statement is not supported in a `const cell`".to_string(),
+                        line,
+                    ));
+                }
+            }
+        }
+        Ok(())
+    }
+
+    fn check_expr(&self, expr: &Expr) -> Result<(), (String, usize)> {
+        match expr {
+            Expr::UnaryOp(_, inner, _) | Expr::ComptimeExpr(inner, _) => self.check_expr(inner),
+            Expr::BinOp(lhs, _, rhs, _) => {
+                self.check_expr(lhs)?;
+                self.check_expr(rhs)
+            }
+            Expr::IfExpr {
+                cond,
+                then_val,
+                else_val,
+                ..
+            } => {
+                self.check_expr(cond)?;
+                self.check_expr(then_val)?;
+                self.check_expr(else_val)
+            }
+            Expr::Call(callee, args, span) => {
+                let Expr::Ident(name, _) = callee.as_ref() else {
+                    return Err((
+                        "only `const cell`s and math builtins can be called".to_string(),
+                        span.line,
+                    ));
+                };
+                self.check_callee(name)
+                    .map_err(|reason| (reason, span.line))?;
+                for arg in args {
+                    let (CallArg::Positional(e) | CallArg::Named(_, e, _) | CallArg::Role(_, e, _)) =
+                        arg;
+                    self.check_expr(e)?;
+                }
+                Ok(())
+            }
+            Expr::ListLit(items, _) | Expr::TupleLit(items, _) | Expr::SetLit(items, _) => {
+                items.iter().try_for_each(|item| self.check_expr(item))
+            }
+            Expr::ListRepeat(value, count, _) => {
+                self.check_expr(value)?;
+                self.check_expr(count)
+            }
+            Expr::ToolCall(_, _, span) | Expr::Perform { span, .. } => Err((
+                "tool calls and effects are not allowed".to_string(),
+                span.line,
+            )),
+            _ => Ok(()),
+        }
+    }
+}
+
+fn builtin_math_constant(name: &str) -> Option<ConstValue> {
+    match name {
+        "PI" => Some(ConstValue::Float(std::f64::consts::PI)),
+        "E" => Some(ConstValue::Float(std::f64::consts::E)),
+        "TAU" => Some(ConstValue::Float(std::f64::consts::TAU)),
+        "INFINITY" => Some(ConstValue::Float(f64::INFINITY)),
+        "NAN" => Some(ConstValue::Float(f64::NAN)),
+        "MAX_INT" => Some(ConstValue::Int(i64::MAX)),
+        "MIN_INT" => Some(ConstValue::Int(i64::MIN)),
+        _ => None,
+    }
+}
+
+fn is_pure_builtin(name: &str) -> bool {
+    matches!(
+        name,
+        "abs"
+            | "min"
+            | "max"
+            | "clamp"
+            | "sqrt"
+            | "pow"
+            | "floor"
+            | "ceil"
+            | "round"
+            | "log"
+            | "log2"
+            | "log10"
+            | "sin"
+            | "cos"
+            | "to_int"
+            | "int"
+            | "to_float"
+            | "float"
+    )
+}
+
+/// Fold a pure math builtin, matching the VM intrinsic for Int and Float
+/// arguments.
+fn eval_pure_builtin(name: &str, args: &[ConstValue]) -> Option<ConstValue> {
+    use ConstValue::{Float, Int};
+    let float = |v: &ConstValue| match v {
+        Int(n) => Some(*n as f64),
+        Float(f) => Some(*f),
+        _ => None,
+    };
+    match (name, args) {
+        ("abs", [Int(n)]) => n.checked_abs().map(Int),
+        ("abs", [Float(f)]) => Some(Float(f.abs())),
+        ("min", [Int(a), Int(b)]) => Some(Int(*a.min(b))),
+        ("min", [Float(a), Float(b)]) => Some(Float(a.min(*b))),
+        ("max", [Int(a), Int(b)]) => Some(Int(*a.max(b))),
+        ("max", [Float(a), Float(b)]) => Some(Float(a.max(*b))),
+        ("clamp", [Int(v), Int(lo), Int(hi)]) => Some(Int(*v.max(lo).min(hi))),
+        ("clamp", [Float(v), Float(lo), Float(hi)]) => Some(Float(v.max(*lo).min(*hi))),
+        ("pow", [Int(x), Int(y)]) if *y >= 0 => x.checked_pow(u32::try_from(*y).ok()?).map(Int),
+        ("pow", [Int(x), Int(y)]) => Some(Float((*x as f64).powf(*y as f64))),
+        ("pow", [x, y]) => Some(Float(float(x)?.powf(float(y)?))),
+        ("floor" | "ceil" | "round", [Int(n)]) => Some(Int(*n)),
+        ("floor", [Float(f)]) => Some(Float(f.floor())),
+        ("ceil", [Float(f)]) => Some(Float(f.ceil())),
+        ("round", [Float(f)]) => Some(Float(f.round())),
+        ("sqrt", [x]) => Some(Float(float(x)?.sqrt())),
+        ("log", [x]) => Some(Float(float(x)?.ln())),
+        ("log2", [x]) => Some(Float(float(x)?.log2())),
+        ("log10", [x]) => Some(Float(float(x)?.log10())),
+        ("sin", [x]) => Some(Float(float(x)?.sin())),
+        ("cos", [x]) => Some(Float(float(x)?.cos())),
+        ("to_int" | "int", [Int(n)]) => Some(Int(*n)),
+        ("to_int" | "int", [Float(f)]) => Some(Int(*f as i64)),
+        ("to_float" | "float", [x]) => Some(Float(float(x)?)),
+        _ => None,
+    }
+}
@@ -0,0 +1,331 @@
+//! Dominator trees over a cell's control-flow graph.
+//!
+//! Block `a` dominates block `b` when every path from the entry to `b`
+//! goes through `a`. Loop-invariant code motion and value numbering both
+//! lean on this: a value computed in `a` is available in every block `a`
+//! dominates, and a back edge is one whose target dominates its source.
+//!
+//! The tree is computed with the Lengauer-Tarjan algorithm, which first
+//! finds each block's semidominator from a depth-first numbering and then
+//! corrects it into the immediate dominator. Blocks the entry cannot reach
+//! have no dominators at all.
+//!
+//! ```rust
+//! use lumen_compiler::compiler::cfg::Cfg;
+//! use lumen_compiler::compiler::dominators::DominatorTree;
+//! use lumen_compiler::compiler::lir::{Instruction, OpCode};
+//!
+//! // 0: Test r0; 1: Jmp +1; 2: LoadInt r1 1; 3: Return r1
+//! let cfg = Cfg::build(&[
+//!     Instruction::abc(OpCode::Test, 0, 0, 0),
+//!     Instruction::sax(OpCode::Jmp, 1),
+//!     Instruction::abc(OpCode::LoadInt, 1, 0, 1),
+//!     Instruction::abc(OpCode::Return, 1, 1, 0),
+//! ]);
+//! let dom = DominatorTree::compute(&cfg);
+//! assert_eq!(dom.idom(3), Some(0));
+//! assert!(!dom.dominates(2, 3));
+//! ```
+
+use crate::compiler::cfg::Cfg;
+
+/// No DFS number: an unvisited block, or a node with no forest ancestor.
+const NONE: usize = usize::MAX;
+
+/// The immediate dominator of every block of a [`Cfg`], rooted at block 0.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct DominatorTree {
+    /// `idom[b]`: the immediate dominator of `b`, the entry's own index
+    /// for the entry, and `None` for a block the entry cannot reach.
+    idom: Vec<Option<usize>>,
+}
+
+impl DominatorTree {
+    pub fn compute(cfg: &Cfg) -> Self {
+        let n = cfg.blocks.len();
+        let mut idom = vec![None; n];
+        if n == 0 {
+            return DominatorTree { idom };
+        }
+        let preds = cfg.predecessors();
+
+        // Depth-first numbering. From here on nodes are DFS numbers, and
+        // `vertex` maps them back to blocks.
+        let mut dfnum = vec![NONE; n];
+        let mut vertex = Vec::with_capacity(n);
+        let mut parent = Vec::with_capacity(n);
+        let mut stack = vec![(0, NONE)];
+        while let Some((block, from)) = stack.pop() {
+            if dfnum[block] != NONE {
+                continue;
+            }
+            dfnum[block] = vertex.len();
+            vertex.push(block);
+            parent.push(from);
+            for edge in cfg.blocks[block].successors.iter().rev() {
+                if dfnum[edge.to] == NONE {
+                    stack.push((edge.to, dfnum[block]));
+                }
+            }
+        }
+
+        let reached = vertex.len();
+        let mut semi: Vec<usize> = (0..reached).collect();
+        let mut label: Vec<usize> = (0..reached).collect();
+        let mut ancestor = vec![NONE; reached];
+        let mut dom = vec![NONE; reached];
+        let mut bucket: Vec<Vec<usize>> = vec![Vec::new(); reached];
+
+        for w in (1..reached).rev() {
+            for &p in &preds[vertex[w]] {
+                let v = dfnum[p];
+                if v == NONE {
+                    continue;
+                }
+                let u = eval(v, &mut ancestor, &mut label, &semi);
+                if semi[u] < semi[w] {
+                    semi[w] = semi[u];
+                }
+            }
+            bucket[semi[w]].push(w);
+            let p = parent[w];
+            ancestor[w] = p;
+            for v in std::mem::take(&mut bucket[p]) {
+                let u = eval(v, &mut ancestor, &mut label, &semi);
+                dom[v] = if semi[u] < semi[v] { u } else { p };
+            }
+        }
+        for w in 1..reached {
+            if dom[w] != semi[w] {
+                dom[w] = dom[dom[w]];
+            }
+        }
+
+        idom[0] = Some(0);
+        for w in 1..reached {
+            idom[vertex[w]] = Some(vertex[dom[w]]);
+        }
+        DominatorTree { idom }
+    }
+
+    /// The immediate dominator of `block`, or `None` for the entry and
+    /// for unreachable blocks.
+    pub fn idom(&self, block: usize) -> Option<usize> {
+        self.idom[block].filter(|&d| d != block)
+    }
+
+    pub fn is_reachable(&self, block: usize) -> bool {
+        self.idom[block].is_some()
+    }
+
+    /// Whether every path from the entry to `b` passes through `a`. A
+    /// reachable block dominates itself.
+    pub fn dominates(&self, a: usize, b: usize) -> bool {
+        if !self.is_reachable(b) {
+            return false;
+        }
+        let mut block = b;
+        loop {
+            if block == a {
+                return true;
+            }
+            match self.idom(block) {
+                Some(up) => block = up,
+                None => return false,
+            }
+        }
+    }
+
+    /// The blocks `block` immediately dominates, in block order.
+    pub fn children(&self, block: usize) -> Vec<usize> {
+        (0..self.idom.len())
+            .filter(|&b| self.idom(b) == Some(block))
+            .collect()
+    }
+}
+
+/// The node with the smallest semidominator on the forest path from `v`
+/// up to (not including) its root, compressing the path on the way.
+fn eval(v: usize, ancestor: &mut [usize], label: &mut [usize], semi: &[usize]) -> usize {
+    if ancestor[v] == NONE {
+        return v;
+    }
+    // Nodes whose ancestor is not yet a root, nearest to `v` first
+    let mut path = Vec::new();
+    let mut x = v;
+    while ancestor[ancestor[x]] != NONE {
+        path.push(x);
+        x = ancestor[x];
+    }
+    while let Some(x) = path.pop() {
+        let a = ancestor[x];
+        if semi[label[a]] < semi[label[x]] {
+            label[x] = label[a];
+        }
+        ancestor[x] = ancestor[a];
+    }
+    label[v]
+}
+
+// ---------------------------------------------------------------------------
+// Tests
+// ---------------------------------------------------------------------------
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::compiler::cfg::{BasicBlock, Edge, EdgeKind};
+
+    /// A CFG with `n` empty blocks and the given edges.
+    fn graph(n: usize, edges: &[(usize, usize)]) -> Cfg {
+        let mut blocks: Vec<BasicBlock> = (0..n)
+            .map(|i| BasicBlock {
+                start: i,
+                end: i + 1,
+                successors: Vec::new(),
+            })
+            .collect();
+        for &(from, to) in edges {
+            blocks[from].successors.push(Edge {
+                to,
+                kind: EdgeKind::Jump,
+            });
+        }
+        Cfg { blocks }
+    }
+
+    fn idoms(dom: &DominatorTree, n: usize) -> Vec<Option<usize>> {
+        (0..n).map(|b| dom.idom(b)).collect()
+    }
+
+    /// `a` dominates `b` by definition: with `a` taken out, the entry can
+    /// no longer reach `b`.
+    fn dominates_by_search(cfg: &Cfg, a: usize, b: usize) -> bool {
+        let mut seen = vec![false; cfg.blocks.len()];
+        let mut stack = vec![0];
+        while let Some(block) = stack.pop() {
+            if seen[block] || (block == a && a != b) {
+                continue;
+            }
+            seen[block] = true;
+            stack.extend(cfg.blocks[block].successors.iter().map(|e| e.to));
+        }
+        if a == b {
+            return seen[b];
+        }
+        let mut reachable = vec![false; cfg.blocks.len()];
+        let mut stack = vec![0];
+        while let Some(block) = stack.pop() {
+            if !reachable[block] {
+                reachable[block] = true;
+                stack.extend(cfg.blocks[block].successors.iter().map(|e| e.to));
+            }
+        }
+        reachable[b] && !seen[b]
+    }
+
+    #[test]
+    fn diamond_joins_at_the_entry() {
+        //   0
+        //  / \
+        // 1   2
+        //  \ /
+        //   3
+        let cfg = graph(4, &[(0, 1), (0, 2), (1, 3), (2, 3)]);
+        let dom = DominatorTree::compute(&cfg);
+        assert_eq!(idoms(&dom, 4), vec![None, Some(0), Some(0), Some(0)]);
+        assert!(dom.dominates(0, 3));
+        assert!(!dom.dominates(1, 3));
+        assert!(!dom.dominates(2, 3));
+        assert!(dom.dominates(3, 3));
+        assert_eq!(dom.children(0), vec![1, 2, 3]);
+    }
+
+    #[test]
+    fn loop_header_dominates_its_body_and_exit() {
+        // 0 -> 1 (header) -> 2 -> 3 -> back to 1; 1 -> 4 (exit);
+        // 2 -> 5 -> 3 is a second way through the body
+        let cfg = graph(6, &[(0, 1), (1, 2), (1, 4), (2, 3), (2, 5), (5, 3), (3, 1)]);
+        let dom = DominatorTree::compute(&cfg);
+        assert_eq!(
+            idoms(&dom, 6),
+            vec![None, Some(0), Some(1), Some(2), Some(1), Some(2)]
+        );
+        // The back edge 3 -> 1 goes to a block dominating its source
+        assert!(dom.dominates(1, 3));
+        assert!(!dom.dominates(5, 3));
+        assert!(!dom.dominates(3, 4));
+    }
+
+    #[test]
+    fn lengauer_tarjan_paper_example() {
+        // The graph from Lengauer and Tarjan's paper, R = 0 and A..L = 1..12
+        let (r, a, b, c, d, e, f, g, h, i, j, k, l) = (0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12);
+        let cfg = graph(
+            13,
+            &[
+                (r, a),
+                (r, b),
+                (r, c),
+                (a, d),
+                (b, a),
+                (b, d),
+                (b, e),
+                (c, f),
+                (c, g),
+                (d, l),
+                (e, h),
+                (f, i),
+                (g, i),
+                (g, j),
+                (h, e),
+                (h, k),
+                (i, k),
+                (j, i),
+                (k, i),
+                (k, r),
+                (l, h),
+            ],
+        );
+        let dom = DominatorTree::compute(&cfg);
+        let want = [
+            None,
+            Some(r),
+            Some(r),
+            Some(r),
+            Some(r),
+            Some(r),
+            Some(c),
+            Some(c),
+            Some(r),
+            Some(r),
+            Some(g),
+            Some(r),
+            Some(d),
+        ];
+        assert_eq!(idoms(&dom, 13), want);
+        for x in 0..13 {
+            for y in 0..13 {
+                assert_eq!(
+                    dom.dominates(x, y),
+                    dominates_by_search(&cfg, x, y),
+                    "does {} dominate {}?",
+                    x,
+                    y
+                );
+            }
+        }
+    }
+
+    #[test]
+    fn unreachable_blocks_have_no_dominators() {
+        let cfg = graph(3, &[(0, 1), (2, 1)]);
+        let dom = DominatorTree::compute(&cfg);
+        assert_eq!(dom.idom(1), Some(0));
+        assert!(!dom.is_reachable(2));
+        assert_eq!(dom.idom(2), None);
+        assert!(!dom.dominates(2, 2));
+        assert!(!dom.dominates(0, 2));
+        assert!(DominatorTree::compute(&Cfg::default()).idom.is_empty());
+    }
+}
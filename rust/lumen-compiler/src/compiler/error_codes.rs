@@ -93,6 +93,11 @@ fn type_error_code(e: &TypeError) -> &'static str {
         TypeError::ImmutableAssign { .. } => "E0207",
         TypeError::IncompleteMatch { .. } => "E0208",
         TypeError::MustUseIgnored { .. } => "E0209",
+        TypeError::BadReceiver { .. } => "E0210",
+        TypeError::TraitNotImplemented { .. } => "E0211",
+        TypeError::OperatorNotImplemented { .. } => "E0212",
+        TypeError::NotConst { .. } => "E0213",
+        TypeError::InvalidSpread { .. } => "E0214",
     }
 }
 
@@ -226,9 +231,14 @@ pub fn error_doc(code: &str) -> &'static str {
         "E0204" => "A field was accessed on a record that does not have that field. Check the field name or the record definition.",
         "E0205" => "A type name used in a type annotation is not defined. Ensure the type is declared or imported before use.",
         "E0206" => "A cell with a return type does not have a return statement on every code path. Add a return or ensure all branches return.",
-        "E0207" => "An assignment was made to an immutable variable. Declare the variable with 'let mut' to allow reassignment.",
+        "E0207" => "An assignment was made to an immutable variable. Declare the variable with 'var' instead of 'let' to allow reassignment.",
         "E0208" => "A match expression does not cover all variants of the matched enum. Add the missing arms or use a wildcard '_' pattern.",
         "E0209" => "The return value of a @must_use cell was discarded. Assign the result to a variable or use it in an expression.",
+        "E0210" => "A method's receiver is used in a way its declaration does not allow. A `mut` receiver must be a variable, not a temporary.",
+        "E0211" => "A value is used where a trait is required, but its type has no `impl` of that trait. Add an `impl Trait for Type` block or pass a type that implements it.",
+        "E0212" => "An arithmetic operator was applied to a user type that does not overload it. Implement the operator's trait (`Add`, `Sub`, `Mul`, `Div`) for the type; `%` and `//` cannot be overloaded.",
+        "E0213" => "A `const` initializer or `const cell` uses an operation that cannot run at compile time. Only `const cell`s, math builtins, and immutable `let` may appear; I/O, `let mut`, assignment, and loops are rejected.",
+        "E0214" => "A record constructor's `..source` argument is not a value of the record being built, or the constructor has more than one. The source supplies every field not given explicitly, so it must have the same record type.",
 
         // Constraint
         "E0300" => "A field constraint (where clause) is invalid. Ensure the constraint expression is well-formed and uses supported operations.",
@@ -254,7 +264,8 @@ pub fn all_error_codes() -> Vec<(&'static str, &'static str)> {
         "E0106", "E0107", "E0108", "E0109", "E0110", "E0111", "E0112", "E0113", "E0114", "E0115",
         "E0116", "E0117", "E0118", "E0119", "E0120", "E0121", "E0122", "E0123", "E0124", "E0125",
         "E0126", "E0127", "E0200", "E0201", "E0202", "E0203", "E0204", "E0205", "E0206", "E0207",
-        "E0208", "E0209", "E0300", "E0400", "E0401", "E0402", "E0403", "E0500",
+        "E0208", "E0209", "E0210", "E0211", "E0212", "E0213", "E0214", "E0300", "E0400", "E0401",
+        "E0402", "E0403", "E0500",
     ];
     codes.iter().map(|&c| (c, error_doc(c))).collect()
 }
@@ -0,0 +1,427 @@
+//! Float rewrites enabled by `CompileOptions::fast_math`.
+//!
+//! IEEE arithmetic is not associative: `(x * 0.1) * 3.0` and `x * 0.3`
+//! usually differ in the last bit, so by default every float expression is
+//! evaluated exactly as written. With fast math on, chains of float
+//! constants are folded into one operand and division by a constant becomes
+//! multiplication by its reciprocal:
+//!
+//! - `(x * c1) * c2` becomes `x * (c1 * c2)`, in any operand order
+//! - `(x + c1) + c2` and the `-` forms become `x + (c1 + c2)` or
+//!   `x - (c1 + c2)`
+//! - `x / c` becomes `x * (1 / c)`
+//!
+//! Only float literals are folded, so integer arithmetic is untouched and
+//! every rewrite keeps the type of the expression. A result can move by
+//! a few units in the last place, which adds up over a long simulation.
+//! A fold whose constant would overflow or underflow is skipped.
+
+use crate::compiler::ast::*;
+
+/// Apply the fast-math rewrites to every cell body in `program`.
+pub fn rewrite(program: &mut Program) {
+    for item in &mut program.items {
+        let cells = match item {
+            Item::Cell(cell) => std::slice::from_mut(cell),
+            Item::Agent(a) => a.cells.as_mut_slice(),
+            Item::Process(p) => p.cells.as_mut_slice(),
+            Item::Handler(h) => h.handles.as_mut_slice(),
+            Item::Impl(i) => i.cells.as_mut_slice(),
+            _ => continue,
+        };
+        for cell in cells {
+            for param in &mut cell.params {
+                if let Some(default) = &mut param.default_value {
+                    expr(default);
+                }
+            }
+            stmts(&mut cell.body);
+        }
+    }
+}
+
+fn stmts(body: &mut [Stmt]) {
+    body.iter_mut().for_each(stmt);
+}
+
+fn stmt(stmt: &mut Stmt) {
+    match stmt {
+        Stmt::Let(s) => expr(&mut s.value),
+        Stmt::If(s) => {
+            expr(&mut s.condition);
+            stmts(&mut s.then_body);
+            if let Some(body) = &mut s.else_body {
+                stmts(body);
+            }
+        }
+        Stmt::For(s) => {
+            expr(&mut s.iter);
+            if let Some(filter) = &mut s.filter {
+                expr(filter);
+            }
+            stmts(&mut s.body);
+        }
+        Stmt::Match(s) => {
+            expr(&mut s.subject);
+            for arm in &mut s.arms {
+                stmts(&mut arm.body);
+            }
+        }
+        Stmt::Return(s) => expr(&mut s.value),
+        Stmt::Halt(s) => expr(&mut s.message),
+        Stmt::Assign(s) => {
+            target(&mut s.target);
+            expr(&mut s.value);
+        }
+        Stmt::CompoundAssign(s) => {
+            target(&mut s.target);
+            expr(&mut s.value);
+        }
+        Stmt::Expr(s) => expr(&mut s.expr),
+        Stmt::While(s) => {
+            if let Some(init) = &mut s.init {
+                self::stmt(init);
+            }
+            expr(&mut s.condition);
+            stmts(&mut s.body);
+            if let Some(post) = &mut s.post {
+                self::stmt(post);
+            }
+        }
+        Stmt::Loop(s) => stmts(&mut s.body),
+        Stmt::Emit(s) => expr(&mut s.value),
+        Stmt::Defer(s) => stmts(&mut s.body),
+        Stmt::Yield(s) => expr(&mut s.value),
+        Stmt::LocalCell(c) => stmts(&mut c.body),
+        Stmt::Break(s) => {
+            if let Some(value) = &mut s.value {
+                expr(value);
+            }
+        }
+        Stmt::Continue(_) | Stmt::LocalRecord(_) | Stmt::LocalEnum(_) => {}
+    }
+}
+
+fn target(target: &mut AssignTarget) {
+    match target {
+        AssignTarget::Variable(_) => {}
+        AssignTarget::Index(base, index) => {
+            expr(base);
+            expr(index);
+        }
+        AssignTarget::Field(base, _) => expr(base),
+    }
+}
+
+fn expr(e: &mut Expr) {
+    match e {
+        Expr::IntLit(..)
+        | Expr::BigIntLit(..)
+        | Expr::FloatLit(..)
+        | Expr::StringLit(..)
+        | Expr::BoolLit(..)
+        | Expr::NullLit(..)
+        | Expr::RawStringLit(..)
+        | Expr::BytesLit(..)
+        | Expr::Ident(..) => {}
+        Expr::Perform { args, .. } => args.iter_mut().for_each(expr),
+        Expr::StringInterp(segments, _) => {
+            for segment in segments {
+                match segment {
+                    StringSegment::Interpolation(e)
+                    | StringSegment::FormattedInterpolation(e, _) => expr(e),
+                    StringSegment::Literal(_) => {}
+                }
+            }
+        }
+        Expr::ListLit(elems, _) | Expr::TupleLit(elems, _) | Expr::SetLit(elems, _) => {
+            elems.iter_mut().for_each(expr)
+        }
+        Expr::MapLit(pairs, _) => {
+            for (k, v) in pairs {
+                expr(k);
+                expr(v);
+            }
+        }
+        Expr::RecordLit(_, fields, _) => fields.iter_mut().for_each(|(_, e)| expr(e)),
+        Expr::BinOp(a, _, b, _) => {
+            expr(a);
+            expr(b);
+            fold(e);
+        }
+        Expr::ListRepeat(a, b, _)
+        | Expr::IndexAccess(a, b, _)
+        | Expr::NullCoalesce(a, b, _)
+        | Expr::NullSafeIndex(a, b, _)
+        | Expr::Pipe {
+            left: a, right: b, ..
+        } => {
+            expr(a);
+            expr(b);
+        }
+        Expr::UnaryOp(_, e, _)
+        | Expr::DotAccess(e, _, _)
+        | Expr::RoleBlock(_, e, _)
+        | Expr::ExpectSchema(e, _, _)
+        | Expr::TryExpr(e, _)
+        | Expr::NullSafeAccess(e, _, _)
+        | Expr::NullAssert(e, _)
+        | Expr::SpreadExpr(e, _)
+        | Expr::AwaitExpr(e, _)
+        | Expr::IsType { expr: e, .. }
+        | Expr::TypeCast { expr: e, .. }
+        | Expr::ComptimeExpr(e, _)
+        | Expr::ResumeExpr(e, _) => expr(e),
+        Expr::ToolCall(callee, call_args, _) | Expr::Call(callee, call_args, _) => {
+            expr(callee);
+            args(call_args);
+        }
+        Expr::Lambda { body, .. } => match body {
+            LambdaBody::Expr(e) => expr(e),
+            LambdaBody::Block(body) => stmts(body),
+        },
+        Expr::RangeExpr {
+            start, end, step, ..
+        } => {
+            for e in [start, end, step].into_iter().flatten() {
+                expr(e);
+            }
+        }
+        Expr::TryElse {
+            expr: inner,
+            handler,
+            ..
+        } => {
+            expr(inner);
+            expr(handler);
+        }
+        Expr::IfExpr {
+            cond,
+            then_val,
+            else_val,
+            ..
+        } => {
+            expr(cond);
+            expr(then_val);
+            expr(else_val);
+        }
+        Expr::Comprehension {
+            body,
+            iter,
+            extra_clauses,
+            condition,
+            ..
+        } => {
+            expr(iter);
+            for clause in extra_clauses.iter_mut() {
+                expr(&mut clause.iter);
+            }
+            if let Some(cond) = condition {
+                expr(cond);
+            }
+            expr(body);
+        }
+        Expr::MatchExpr { subject, arms, .. } => {
+            expr(subject);
+            for arm in arms {
+                stmts(&mut arm.body);
+            }
+        }
+        Expr::BlockExpr(body, _) => stmts(body),
+        Expr::WhenExpr {
+            arms, else_body, ..
+        } => {
+            for arm in arms {
+                expr(&mut arm.condition);
+                expr(&mut arm.body);
+            }
+            if let Some(e) = else_body {
+                expr(e);
+            }
+        }
+        Expr::HandleExpr { body, handlers, .. } => {
+            stmts(body);
+            for handler in handlers {
+                stmts(&mut handler.body);
+            }
+        }
+    }
+}
+
+fn args(call_args: &mut [CallArg]) {
+    for arg in call_args {
+        match arg {
+            CallArg::Positional(e) | CallArg::Named(_, e, _) | CallArg::Role(_, e, _) => expr(e),
+        }
+    }
+}
+
+fn float(e: &Expr) -> Option<f64> {
+    match e {
+        Expr::FloatLit(v, _) => Some(*v),
+        _ => None,
+    }
+}
+
+/// The operand and constant of `x * c` or `c * x`.
+fn scaled(e: &mut Expr) -> Option<(&mut Expr, f64)> {
+    let Expr::BinOp(lhs, BinOp::Mul, rhs, _) = e else {
+        return None;
+    };
+    match (float(lhs), float(rhs)) {
+        (None, Some(c)) => Some((lhs.as_mut(), c)),
+        (Some(c), None) => Some((rhs.as_mut(), c)),
+        _ => None,
+    }
+}
+
+/// The operand and signed constant of `x + c` or `x - c`.
+fn offset(e: &mut Expr) -> Option<(&mut Expr, f64)> {
+    let Expr::BinOp(lhs, op @ (BinOp::Add | BinOp::Sub), rhs, _) = e else {
+        return None;
+    };
+    let c = float(rhs)?;
+    if float(lhs).is_some() {
+        return None;
+    }
+    Some((lhs.as_mut(), if *op == BinOp::Sub { -c } else { c }))
+}
+
+/// Rewrite the binary operation `e`, whose operands are already rewritten.
+fn fold(e: &mut Expr) {
+    let Expr::BinOp(lhs, op, rhs, span) = e else {
+        return;
+    };
+    let span = *span;
+    if *op == BinOp::Div {
+        let Some(c) = float(rhs) else { return };
+        let reciprocal = 1.0 / c;
+        if !reciprocal.is_normal() {
+            return;
+        }
+        let rhs_span = rhs.span();
+        *op = BinOp::Mul;
+        **rhs = Expr::FloatLit(reciprocal, rhs_span);
+    }
+    let folded = match op {
+        BinOp::Mul => {
+            let (inner, c2) = match (float(lhs), float(rhs)) {
+                (None, Some(c)) => (lhs.as_mut(), c),
+                (Some(c), None) => (rhs.as_mut(), c),
+                _ => return,
+            };
+            let Some((x, c1)) = scaled(inner) else { return };
+            let k = c1 * c2;
+            if !k.is_normal() {
+                return;
+            }
+            let x = std::mem::replace(x, Expr::NullLit(span));
+            Expr::BinOp(
+                Box::new(x),
+                BinOp::Mul,
+                Box::new(Expr::FloatLit(k, span)),
+                span,
+            )
+        }
+        BinOp::Add | BinOp::Sub => {
+            let Some(c2) = float(rhs) else { return };
+            let c2 = if *op == BinOp::Sub { -c2 } else { c2 };
+            let Some((x, c1)) = offset(lhs) else { return };
+            let k = c1 + c2;
+            if !k.is_finite() {
+                return;
+            }
+            let x = std::mem::replace(x, Expr::NullLit(span));
+            let (op, k) = if k < 0.0 {
+                (BinOp::Sub, -k)
+            } else {
+                (BinOp::Add, k)
+            };
+            Expr::BinOp(Box::new(x), op, Box::new(Expr::FloatLit(k, span)), span)
+        }
+        _ => return,
+    };
+    *e = folded;
+}
+
+// ---------------------------------------------------------------------------
+// Tests
+// ---------------------------------------------------------------------------
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::compiler::lexer::Lexer;
+    use crate::compiler::parser::Parser;
+
+    /// The expression `f` returns, after rewriting `cell f(x: Float) -> Float`
+    /// with body `return <body>`.
+    fn rewritten(body: &str) -> Expr {
+        let src = format!("cell f(x: Float) -> Float\n  return {}\nend", body);
+        let mut lexer = Lexer::new(&src, 1, 0);
+        let tokens = lexer.tokenize().unwrap();
+        let mut parser = Parser::new(tokens);
+        let mut program = parser.parse_program(vec![]).unwrap();
+        rewrite(&mut program);
+        match &program.items[0] {
+            Item::Cell(cell) => match cell.body.last() {
+                Some(Stmt::Return(ret)) => ret.value.clone(),
+                other => panic!("last statement is not a return: {:?}", other),
+            },
+            other => panic!("not a cell: {:?}", other),
+        }
+    }
+
+    /// `x <op> <constant>` as (op, constant).
+    fn x_op_const(e: &Expr) -> (BinOp, f64) {
+        match e {
+            Expr::BinOp(lhs, op, rhs, _) => match (lhs.as_ref(), rhs.as_ref()) {
+                (Expr::Ident(name, _), Expr::FloatLit(c, _)) if name == "x" => (*op, *c),
+                _ => panic!("not `x <op> <constant>`: {:?}", e),
+            },
+            _ => panic!("not a binary operation: {:?}", e),
+        }
+    }
+
+    #[test]
+    fn folds_constant_products() {
+        assert_eq!(x_op_const(&rewritten("(x * 0.5) * 4.0")), (BinOp::Mul, 2.0));
+        assert_eq!(x_op_const(&rewritten("4.0 * (0.5 * x)")), (BinOp::Mul, 2.0));
+        assert_eq!(
+            x_op_const(&rewritten("x * 0.1 * 3.0")),
+            (BinOp::Mul, 0.1 * 3.0)
+        );
+    }
+
+    #[test]
+    fn folds_constant_offsets() {
+        assert_eq!(x_op_const(&rewritten("(x + 1.5) + 2.0")), (BinOp::Add, 3.5));
+        assert_eq!(x_op_const(&rewritten("x - 1.5 - 2.0")), (BinOp::Sub, 3.5));
+        assert_eq!(x_op_const(&rewritten("x + 1.0 - 3.0")), (BinOp::Sub, 2.0));
+    }
+
+    #[test]
+    fn divides_by_multiplying_with_the_reciprocal() {
+        assert_eq!(x_op_const(&rewritten("x / 4.0")), (BinOp::Mul, 0.25));
+        assert_eq!(x_op_const(&rewritten("x / 2.0 * 3.0")), (BinOp::Mul, 1.5));
+    }
+
+    #[test]
+    fn leaves_other_expressions_alone() {
+        // Integer constants, division by zero, and an overflowing product
+        assert!(matches!(
+            rewritten("(x * 2) * 3"),
+            Expr::BinOp(_, BinOp::Mul, ref rhs, _) if matches!(**rhs, Expr::IntLit(3, _))
+        ));
+        assert_eq!(x_op_const(&rewritten("x / 0.0")), (BinOp::Div, 0.0));
+        assert!(matches!(
+            rewritten("(x * 1e300) * 1e300"),
+            Expr::BinOp(ref lhs, BinOp::Mul, _, _) if matches!(**lhs, Expr::BinOp(..))
+        ));
+        // A constant on the left of `+` may be a string prefix
+        assert!(matches!(
+            rewritten("2.0 + (x + 1.0)"),
+            Expr::BinOp(ref lhs, BinOp::Add, _, _) if matches!(**lhs, Expr::FloatLit(..))
+        ));
+    }
+}
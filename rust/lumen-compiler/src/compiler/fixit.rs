@@ -385,9 +385,9 @@ fn suggest_type(error: &TypeError, source: &str) -> Vec<FixitHint> {
         }
 
         TypeError::ImmutableAssign { name, line } => vec![FixitHint {
-            message: format!("Declare '{}' as mutable: `let mut {}`", name, name),
+            message: format!("Declare '{}' as mutable: `var {}`", name, name),
             span: span_at(*line, 1, 0),
-            replacement: format!("let mut {}", name),
+            replacement: format!("var {}", name),
             kind: FixitKind::Replace,
         }],
 
@@ -608,7 +608,7 @@ mod tests {
         }]);
         let hints = suggest_fixit(&err, "");
         assert!(!hints.is_empty());
-        assert!(hints[0].message.contains("let mut"));
+        assert!(hints[0].message.contains("var counter"));
     }
 
     // ── suggest_fixit on ownership UseAfterMove ────────────────────
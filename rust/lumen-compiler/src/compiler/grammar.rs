@@ -431,6 +431,7 @@ mod tests {
                             default_value: None,
                             constraint: None,
                             span: dummy_span(),
+                            attributes: vec![],
                         },
                         FieldDef {
                             name: "y".into(),
@@ -438,12 +439,14 @@ mod tests {
                             default_value: None,
                             constraint: None,
                             span: dummy_span(),
+                            attributes: vec![],
                         },
                     ],
                     is_pub: false,
                     span: dummy_span(),
                     doc: None,
                     deprecated: None,
+                    packed: false,
                 }),
                 generic_params: vec![],
             },
@@ -526,11 +529,13 @@ mod tests {
                         default_value: None,
                         constraint: None,
                         span: dummy_span(),
+                        attributes: vec![],
                     }],
                     is_pub: false,
                     span: dummy_span(),
                     doc: None,
                     deprecated: None,
+                    packed: false,
                 }),
                 generic_params: vec![],
             },
@@ -548,6 +553,7 @@ mod tests {
                             default_value: None,
                             constraint: None,
                             span: dummy_span(),
+                            attributes: vec![],
                         },
                         FieldDef {
                             name: "h".into(),
@@ -555,12 +561,14 @@ mod tests {
                             default_value: None,
                             constraint: None,
                             span: dummy_span(),
+                            attributes: vec![],
                         },
                     ],
                     is_pub: false,
                     span: dummy_span(),
                     doc: None,
                     deprecated: None,
+                    packed: false,
                 }),
                 generic_params: vec![],
             },
@@ -0,0 +1,542 @@
+//! Global value numbering: computing each value once on every path.
+//!
+//! Every value a cell computes gets a number, and registers holding the
+//! same number hold equal values. A computation whose operation and operand
+//! numbers were already seen in a block that dominates it is redundant: it
+//! becomes a `Move` from a register still holding the earlier result, so
+//! `dx * dx` written twice in a loop body is multiplied once and `m[i][j]`
+//! read twice is indexed once.
+//!
+//! Registers are reused, so a number only stays with a register until the
+//! register is written. Entering a block, every register that some path
+//! from its immediate dominator might write is forgotten, loop back edges
+//! included.
+//!
+//! The pass is deliberately literal:
+//!
+//! - Only computations that do nothing but produce a result are numbered:
+//!   arithmetic, comparisons, and reads by index, field, or tuple slot.
+//!   Calls, intrinsics, and effects are never removed, and an instruction
+//!   the pass does not model forgets every register.
+//! - Collections are values, so a store such as `xs[i] = v` writes a new
+//!   value to `xs`'s register, and reading `xs[i]` after it is a different
+//!   computation from reading it before.
+//! - An operator a user type overloads may run user code, so it is not
+//!   numbered at all.
+//! - Float arithmetic is never reassociated, no operands are swapped, and
+//!   no identity such as `x - x == 0` is assumed. Constants are told apart
+//!   by their pool slot, and `0.0` and `-0.0` never share one.
+//!
+//! Cells that install effect handlers are skipped, since a handler is
+//! entered from a `perform` rather than through the control-flow graph.
+
+use std::collections::HashMap;
+
+use crate::compiler::cfg::Cfg;
+use crate::compiler::dominators::DominatorTree;
+use crate::compiler::lir::{Instruction, IntrinsicId, OpCode};
+
+/// A value number.
+type Vn = u32;
+
+/// What a value is computed from. Opcodes are kept as their byte, since
+/// `OpCode` is not `Hash`.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash)]
+enum Key {
+    /// `LoadK` of a constant-pool slot
+    Constant(u16),
+    /// `LoadInt` of its raw operand
+    Int(u16),
+    /// `LoadBool` of its value
+    Bool(u8),
+    Unary(u8, Vn),
+    Binary(u8, Vn, Vn),
+    /// `GetField` or `GetTuple`, whose `c` is a field name or slot
+    Immediate(u8, Vn, u8),
+}
+
+/// What is known at one point of a cell.
+#[derive(Clone)]
+struct State {
+    /// The number of the value in each register, if known
+    regs: Vec<Option<Vn>>,
+    /// The number given to each computation seen on the way here
+    values: HashMap<Key, Vn>,
+}
+
+impl State {
+    fn new() -> Self {
+        State {
+            regs: vec![None; 256],
+            values: HashMap::new(),
+        }
+    }
+
+    /// The number of the value in `reg`, giving it a new one if nothing is
+    /// known about it.
+    fn read(&mut self, reg: u8, next: &mut Vn) -> Vn {
+        *self.regs[reg as usize].get_or_insert_with(|| fresh(next))
+    }
+
+    /// Some register holding value `vn`.
+    fn holder(&self, vn: Vn) -> Option<u8> {
+        self.regs
+            .iter()
+            .position(|&r| r == Some(vn))
+            .map(|r| r as u8)
+    }
+
+    /// Forget the registers in `written`, or all of them for `None`.
+    fn forget(&mut self, written: &Option<Vec<u8>>) {
+        match written {
+            Some(regs) => {
+                for &r in regs {
+                    self.regs[r as usize] = None;
+                }
+            }
+            None => self.regs.fill(None),
+        }
+    }
+}
+
+fn fresh(next: &mut Vn) -> Vn {
+    *next += 1;
+    *next
+}
+
+/// Post-lowering pass: turn computations already made on every path to
+/// them into a `Move` of the earlier result. `overloaded` lists the
+/// operators some user type in the module overloads. Returns the number of
+/// computations removed.
+pub fn eliminate_redundant_computations(
+    instrs: &mut [Instruction],
+    overloaded: &[OpCode],
+) -> usize {
+    if instrs.is_empty() || instrs.iter().any(|i| i.op == OpCode::HandlePush) {
+        return 0;
+    }
+    let cfg = Cfg::build(instrs);
+    let dom = DominatorTree::compute(&cfg);
+    let preds = cfg.predecessors();
+    // The registers each block writes, or `None` if it may write any
+    let block_writes: Vec<Option<Vec<u8>>> = cfg
+        .blocks
+        .iter()
+        .map(|b| {
+            let mut regs = Vec::new();
+            for inst in &instrs[b.start..b.end] {
+                regs.extend(written(inst)?);
+            }
+            Some(regs)
+        })
+        .collect();
+
+    let mut next: Vn = 0;
+    let mut removed = 0;
+    let mut exits: Vec<Option<State>> = vec![None; cfg.blocks.len()];
+    // Dominator-tree preorder, so a block's immediate dominator is done
+    // before it
+    let mut order = vec![0];
+    while let Some(block) = order.pop() {
+        order.extend(dom.children(block).into_iter().rev());
+        let mut state = match dom.idom(block) {
+            Some(idom) => {
+                let mut state = exits[idom].clone().unwrap_or_else(State::new);
+                for between in blocks_between(&preds, idom, block) {
+                    state.forget(&block_writes[between]);
+                }
+                state
+            }
+            None => State::new(),
+        };
+
+        for pc in cfg.blocks[block].start..cfg.blocks[block].end {
+            let inst = instrs[pc];
+            let Some(key) = key(&inst, &mut state, &mut next, overloaded) else {
+                match inst.op {
+                    OpCode::Move => {
+                        let vn = state.read(inst.b, &mut next);
+                        state.regs[inst.a as usize] = Some(vn);
+                    }
+                    OpCode::MoveOwn => {
+                        let vn = state.read(inst.b, &mut next);
+                        state.regs[inst.b as usize] = None;
+                        state.regs[inst.a as usize] = Some(vn);
+                    }
+                    _ => state.forget(&written(&inst)),
+                }
+                continue;
+            };
+            let vn = match state.values.get(&key) {
+                Some(&vn) => {
+                    let is_load =
+                        matches!(inst.op, OpCode::LoadK | OpCode::LoadInt | OpCode::LoadBool);
+                    if !is_load && state.regs[inst.a as usize] != Some(vn) {
+                        if let Some(from) = state.holder(vn) {
+                            instrs[pc] = Instruction::abc(OpCode::Move, inst.a, from, 0);
+                            removed += 1;
+                        }
+                    }
+                    vn
+                }
+                None => {
+                    let vn = fresh(&mut next);
+                    state.values.insert(key, vn);
+                    vn
+                }
+            };
+            state.regs[inst.a as usize] = Some(vn);
+        }
+        exits[block] = Some(state);
+    }
+    removed
+}
+
+/// The key of a numbered computation, reading its operands' numbers from
+/// `state`; `None` for anything else.
+fn key(inst: &Instruction, state: &mut State, next: &mut Vn, overloaded: &[OpCode]) -> Option<Key> {
+    let op = inst.op;
+    if overloaded.contains(&op) {
+        return None;
+    }
+    let key = match op {
+        OpCode::LoadK => Key::Constant(inst.bx()),
+        OpCode::LoadInt => Key::Int(inst.bx()),
+        // A `LoadBool` that skips is a branch
+        OpCode::LoadBool if inst.c == 0 => Key::Bool(inst.b),
+        OpCode::Neg | OpCode::Not | OpCode::BitNot => {
+            Key::Unary(op as u8, state.read(inst.b, next))
+        }
+        OpCode::Add
+        | OpCode::Sub
+        | OpCode::Mul
+        | OpCode::Div
+        | OpCode::Mod
+        | OpCode::Pow
+        | OpCode::FloorDiv
+        | OpCode::Concat
+        | OpCode::BitOr
+        | OpCode::BitAnd
+        | OpCode::BitXor
+        | OpCode::Shl
+        | OpCode::Shr
+        | OpCode::Eq
+        | OpCode::Lt
+        | OpCode::Le
+        | OpCode::GetIndex => {
+            let b = state.read(inst.b, next);
+            Key::Binary(op as u8, b, state.read(inst.c, next))
+        }
+        OpCode::GetField | OpCode::GetTuple => {
+            Key::Immediate(op as u8, state.read(inst.b, next), inst.c)
+        }
+        _ => return None,
+    };
+    Some(key)
+}
+
+/// The registers `inst` writes, or `None` if it may write any of them.
+fn written(inst: &Instruction) -> Option<Vec<u8>> {
+    let a = inst.a;
+    let regs = match inst.op {
+        OpCode::Nop
+        | OpCode::Jmp
+        | OpCode::Break
+        | OpCode::Continue
+        | OpCode::Return
+        | OpCode::Halt
+        | OpCode::Test
+        | OpCode::IsVariant
+        | OpCode::JmpTable
+        | OpCode::HandlePop => vec![],
+        OpCode::LoadNil => (a..=a.saturating_add(inst.b)).collect(),
+        OpCode::MoveOwn => vec![a, inst.b],
+        // The iterator's index and end, then the bound variable
+        OpCode::ForPrep => vec![a.wrapping_add(1), a.wrapping_add(2)],
+        OpCode::ForLoop => vec![a.wrapping_add(1), a.wrapping_add(3)],
+        OpCode::ForIn => vec![a, a.wrapping_add(1), inst.c],
+        // IterNext also advances its `[iter, cursor, more]` block at `c`
+        OpCode::Intrinsic if inst.b == IntrinsicId::IterNext as u8 => {
+            let mut regs: Vec<u8> = (inst.c..=inst.c.saturating_add(2)).collect();
+            regs.push(a);
+            regs
+        }
+        // Append takes its list and item out of the block at `c`, leaving
+        // both null
+        OpCode::Intrinsic if inst.b == IntrinsicId::Append as u8 => {
+            vec![inst.c, inst.c.wrapping_add(1), a]
+        }
+        // The result lands in the callee register; arguments above it are
+        // clobbered
+        OpCode::Call => (a..=u8::MAX).collect(),
+        OpCode::Move
+        | OpCode::LoadK
+        | OpCode::LoadBool
+        | OpCode::LoadInt
+        | OpCode::NewList
+        | OpCode::NewMap
+        | OpCode::NewRecord
+        | OpCode::NewUnion
+        | OpCode::NewTuple
+        | OpCode::NewSet
+        | OpCode::GetField
+        | OpCode::GetIndex
+        | OpCode::GetTuple
+        | OpCode::SetField
+        | OpCode::SetIndex
+        | OpCode::Append
+        | OpCode::Add
+        | OpCode::Sub
+        | OpCode::Mul
+        | OpCode::Div
+        | OpCode::Mod
+        | OpCode::Pow
+        | OpCode::Neg
+        | OpCode::Concat
+        | OpCode::FloorDiv
+        | OpCode::BitOr
+        | OpCode::BitAnd
+        | OpCode::BitXor
+        | OpCode::BitNot
+        | OpCode::Shl
+        | OpCode::Shr
+        | OpCode::Eq
+        | OpCode::Lt
+        | OpCode::Le
+        | OpCode::Not
+        | OpCode::And
+        | OpCode::Or
+        | OpCode::In
+        | OpCode::Is
+        | OpCode::NullCo
+        | OpCode::Loop
+        | OpCode::Intrinsic
+        | OpCode::Closure
+        | OpCode::GetUpval
+        | OpCode::Unbox => vec![a],
+        _ => return None,
+    };
+    Some(regs)
+}
+
+/// The blocks on some path from `from` to `to` that does not go through
+/// `from` again, not counting `from` itself. `to` is among them when it is
+/// on a cycle that avoids `from`.
+fn blocks_between(preds: &[Vec<usize>], from: usize, to: usize) -> Vec<usize> {
+    let mut seen = vec![false; preds.len()];
+    let mut stack: Vec<usize> = preds[to].clone();
+    let mut between = Vec::new();
+    while let Some(block) = stack.pop() {
+        if block == from || seen[block] {
+            continue;
+        }
+        seen[block] = true;
+        between.push(block);
+        stack.extend(&preds[block]);
+    }
+    between
+}
+
+// ---------------------------------------------------------------------------
+// Tests
+// ---------------------------------------------------------------------------
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn ops(instrs: &[Instruction]) -> Vec<OpCode> {
+        instrs.iter().map(|i| i.op).collect()
+    }
+
+    /// An instruction's opcode and operands, since `Instruction` is not
+    /// `PartialEq`.
+    fn parts(instrs: &[Instruction]) -> Vec<(OpCode, u8, u8, u8)> {
+        instrs.iter().map(|i| (i.op, i.a, i.b, i.c)).collect()
+    }
+
+    fn is_move(inst: &Instruction, to: u8, from: u8) -> bool {
+        inst.op == OpCode::Move && inst.a == to && inst.b == from
+    }
+
+    #[test]
+    fn repeated_product_is_computed_once() {
+        //  0: Mul r2 r0 r0
+        //  1: Mul r3 r0 r0
+        //  2: Add r4 r2 r3
+        //  3: Return r4
+        let mut instrs = vec![
+            Instruction::abc(OpCode::Mul, 2, 0, 0),
+            Instruction::abc(OpCode::Mul, 3, 0, 0),
+            Instruction::abc(OpCode::Add, 4, 2, 3),
+            Instruction::abc(OpCode::Return, 4, 1, 0),
+        ];
+        assert_eq!(eliminate_redundant_computations(&mut instrs, &[]), 1);
+        assert!(is_move(&instrs[1], 3, 2), "{:?}", instrs);
+        assert_eq!(instrs[2].op, OpCode::Add);
+    }
+
+    #[test]
+    fn read_after_a_store_is_kept() {
+        //  0: GetIndex r3 r0 r1
+        //  1: SetIndex r0 r1 r2
+        //  2: GetIndex r4 r0 r1
+        //  3: Return r4
+        let mut instrs = vec![
+            Instruction::abc(OpCode::GetIndex, 3, 0, 1),
+            Instruction::abc(OpCode::SetIndex, 0, 1, 2),
+            Instruction::abc(OpCode::GetIndex, 4, 0, 1),
+            Instruction::abc(OpCode::Return, 4, 1, 0),
+        ];
+        let before = parts(&instrs);
+        assert_eq!(eliminate_redundant_computations(&mut instrs, &[]), 0);
+        assert_eq!(parts(&instrs), before);
+    }
+
+    #[test]
+    fn overwritten_result_is_not_reused() {
+        //  0: Add r2 r0 r1
+        //  1: LoadInt r2 7
+        //  2: Add r3 r0 r1   (r2 no longer holds `r0 + r1`)
+        //  3: Add r4 r0 r1   (but r3 does)
+        let mut instrs = vec![
+            Instruction::abc(OpCode::Add, 2, 0, 1),
+            Instruction::abx(OpCode::LoadInt, 2, 7),
+            Instruction::abc(OpCode::Add, 3, 0, 1),
+            Instruction::abc(OpCode::Add, 4, 0, 1),
+            Instruction::abc(OpCode::Return, 4, 1, 0),
+        ];
+        assert_eq!(eliminate_redundant_computations(&mut instrs, &[]), 1);
+        assert_eq!(
+            ops(&instrs[..4]),
+            vec![OpCode::Add, OpCode::LoadInt, OpCode::Add, OpCode::Move]
+        );
+        assert!(is_move(&instrs[3], 4, 3), "{:?}", instrs);
+    }
+
+    #[test]
+    fn arguments_taken_by_append_are_not_reused() {
+        //  0: Add r2 r0 r1
+        //  1: Move r4 r2
+        //  2: LoadInt r2 7
+        //  3: Intrinsic r5 Append r3   (takes r3 and r4, leaving them null)
+        //  4: Add r6 r0 r1             (no register holds `r0 + r1` now)
+        let mut instrs = vec![
+            Instruction::abc(OpCode::Add, 2, 0, 1),
+            Instruction::abc(OpCode::Move, 4, 2, 0),
+            Instruction::abx(OpCode::LoadInt, 2, 7),
+            Instruction::abc(OpCode::Intrinsic, 5, IntrinsicId::Append as u8, 3),
+            Instruction::abc(OpCode::Add, 6, 0, 1),
+            Instruction::abc(OpCode::Return, 6, 1, 0),
+        ];
+        let before = parts(&instrs);
+        assert_eq!(eliminate_redundant_computations(&mut instrs, &[]), 0);
+        assert_eq!(parts(&instrs), before);
+    }
+
+    #[test]
+    fn different_constants_are_different_values() {
+        // 0.0 and -0.0 sit in different pool slots, so `x + 0.0` and
+        // `x + -0.0` are different computations
+        //  0: LoadK r1 k0
+        //  1: LoadK r2 k1
+        //  2: Add r3 r0 r1
+        //  3: Add r4 r0 r2
+        let mut instrs = vec![
+            Instruction::abx(OpCode::LoadK, 1, 0),
+            Instruction::abx(OpCode::LoadK, 2, 1),
+            Instruction::abc(OpCode::Add, 3, 0, 1),
+            Instruction::abc(OpCode::Add, 4, 0, 2),
+            Instruction::abc(OpCode::Return, 4, 1, 0),
+        ];
+        assert_eq!(eliminate_redundant_computations(&mut instrs, &[]), 0);
+
+        // The same slot loaded twice is the same value
+        instrs[1] = Instruction::abx(OpCode::LoadK, 2, 0);
+        assert_eq!(eliminate_redundant_computations(&mut instrs, &[]), 1);
+        assert!(is_move(&instrs[3], 4, 3), "{:?}", instrs);
+    }
+
+    #[test]
+    fn operands_are_not_swapped_and_overloads_are_kept() {
+        //  0: Add r2 r0 r1
+        //  1: Add r3 r1 r0
+        //  2: Add r4 r0 r1
+        let mut instrs = vec![
+            Instruction::abc(OpCode::Add, 2, 0, 1),
+            Instruction::abc(OpCode::Add, 3, 1, 0),
+            Instruction::abc(OpCode::Add, 4, 0, 1),
+            Instruction::abc(OpCode::Return, 4, 1, 0),
+        ];
+        let before = parts(&instrs);
+        assert_eq!(
+            eliminate_redundant_computations(&mut instrs, &[OpCode::Add]),
+            0
+        );
+        assert_eq!(parts(&instrs), before);
+        assert_eq!(eliminate_redundant_computations(&mut instrs, &[]), 1);
+        assert_eq!(
+            ops(&instrs[..3]),
+            vec![OpCode::Add, OpCode::Add, OpCode::Move]
+        );
+    }
+
+    #[test]
+    fn join_reuses_values_from_a_dominator_only() {
+        //  0: Mul r2 r0 r0
+        //  1: Test r1 0
+        //  2: Jmp +2           (→ 5)
+        //  3: Sub r3 r0 r0     (only on one branch)
+        //  4: Jmp +0
+        //  5: Mul r4 r0 r0     (the entry's product reaches here)
+        //  6: Sub r5 r0 r0     (the branch's difference may not)
+        //  7: Return r5
+        let mut instrs = vec![
+            Instruction::abc(OpCode::Mul, 2, 0, 0),
+            Instruction::abc(OpCode::Test, 1, 0, 0),
+            Instruction::sax(OpCode::Jmp, 2),
+            Instruction::abc(OpCode::Sub, 3, 0, 0),
+            Instruction::sax(OpCode::Jmp, 0),
+            Instruction::abc(OpCode::Mul, 4, 0, 0),
+            Instruction::abc(OpCode::Sub, 5, 0, 0),
+            Instruction::abc(OpCode::Return, 5, 1, 0),
+        ];
+        assert_eq!(eliminate_redundant_computations(&mut instrs, &[]), 1);
+        assert!(is_move(&instrs[5], 4, 2), "{:?}", instrs);
+        assert_eq!(instrs[6].op, OpCode::Sub);
+    }
+
+    #[test]
+    fn register_written_in_a_loop_is_forgotten_at_its_header() {
+        //  0: Add r2 r0 r1
+        //  1: Add r3 r0 r1     (loop header: r0 changes below)
+        //  2: Mul r4 r1 r1     (r1 does not, so this is loop-invariant...)
+        //  3: Mul r5 r1 r1     (...and repeated)
+        //  4: Add r0 r0 r3
+        //  5: Jmp -5           (→ 1)
+        let mut instrs = vec![
+            Instruction::abc(OpCode::Add, 2, 0, 1),
+            Instruction::abc(OpCode::Add, 3, 0, 1),
+            Instruction::abc(OpCode::Mul, 4, 1, 1),
+            Instruction::abc(OpCode::Mul, 5, 1, 1),
+            Instruction::abc(OpCode::Add, 0, 0, 3),
+            Instruction::sax(OpCode::Jmp, -5),
+        ];
+        assert_eq!(eliminate_redundant_computations(&mut instrs, &[]), 1);
+        assert_eq!(instrs[1].op, OpCode::Add);
+        assert!(is_move(&instrs[3], 5, 4), "{:?}", instrs);
+    }
+
+    #[test]
+    fn cells_with_handlers_are_left_alone() {
+        let mut instrs = vec![
+            Instruction::abx(OpCode::HandlePush, 0, 4),
+            Instruction::abc(OpCode::Mul, 2, 0, 0),
+            Instruction::abc(OpCode::Mul, 3, 0, 0),
+            Instruction::abc(OpCode::Return, 3, 1, 0),
+            Instruction::abc(OpCode::Resume, 1, 0, 0),
+        ];
+        assert_eq!(eliminate_redundant_computations(&mut instrs, &[]), 0);
+        assert_eq!(eliminate_redundant_computations(&mut [], &[]), 0);
+    }
+}
@@ -0,0 +1,235 @@
+//! Incremental compilation at cell granularity.
+//!
+//! Lowering a cell reads its own definition, and the rest of the program only
+//! through declarations: signatures, types, tools, effects, and constants. So
+//! each cell is keyed by a fingerprint of its definition, of the program's
+//! interface (every item with cell bodies and source positions left out), and
+//! of the constants it reads. A cell whose key is already in the
+//! [`CellStore`] is taken from there instead of being lowered again.
+//!
+//! Lumen does not inline one cell's code into another, so editing a body
+//! recompiles only that cell. Where the compiler does fold a body into other
+//! cells, the keys follow it: a `const cell` is evaluated into the constants
+//! that call it, so the cells reading those constants recompile when a value
+//! changes, and a copy made by `@specialize` is keyed by the generic body it
+//! was copied from. Editing a signature or any other declaration changes the
+//! interface and recompiles every cell.
+//!
+//! A cell's LIR records the source lines of its calls, so its key includes
+//! its line numbers, and a cell recompiles when an edit above it adds or
+//! removes lines. Byte offsets and columns are not part of the key. Its
+//! instructions also name module strings by index, so a stored cell is
+//! lowered again when an edit elsewhere shifts those indices.
+
+use crate::compiler::ast::{Item, Program};
+use crate::compiler::const_eval::ConstValue;
+use crate::compiler::lir::LirCell;
+use serde::{Deserialize, Serialize};
+use serde_json::Value as Json;
+use sha2::{Digest, Sha256};
+use std::collections::{BTreeMap, HashMap};
+
+/// A lowered cell as kept between builds.
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct CompiledCell {
+    pub cell: LirCell,
+    /// Source line of each call in the cell, as for the `call_lines` addon
+    pub call_lines: Vec<usize>,
+    /// Source line of each allocation in the cell, as for the `alloc_lines`
+    /// addon
+    #[serde(default)]
+    pub alloc_lines: Vec<usize>,
+    /// Module strings the cell interned, with the index each had
+    pub strings: Vec<(String, u16)>,
+}
+
+/// Storage for lowered cells, addressed by fingerprint.
+pub trait CellStore: std::fmt::Debug + Send {
+    fn get(&mut self, key: &str) -> Option<CompiledCell>;
+    fn put(&mut self, key: &str, cell: &CompiledCell);
+}
+
+/// A [`CellStore`] in memory, which records the cells it hands back and the
+/// cells it is given, so a build can be inspected.
+#[derive(Debug, Default)]
+pub struct MemoryCellStore {
+    cells: HashMap<String, CompiledCell>,
+    /// Names of cells taken from the store
+    pub reused: Vec<String>,
+    /// Names of cells lowered and stored
+    pub compiled: Vec<String>,
+}
+
+impl MemoryCellStore {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Forget which cells were reused and compiled, keeping the cells.
+    pub fn clear_log(&mut self) {
+        self.reused.clear();
+        self.compiled.clear();
+    }
+
+    pub fn len(&self) -> usize {
+        self.cells.len()
+    }
+
+    pub fn is_empty(&self) -> bool {
+        self.cells.is_empty()
+    }
+}
+
+impl CellStore for MemoryCellStore {
+    fn get(&mut self, key: &str) -> Option<CompiledCell> {
+        let hit = self.cells.get(key).cloned()?;
+        self.reused.push(hit.cell.name.clone());
+        Some(hit)
+    }
+
+    fn put(&mut self, key: &str, cell: &CompiledCell) {
+        self.compiled.push(cell.cell.name.clone());
+        self.cells.insert(key.to_string(), cell.clone());
+    }
+}
+
+/// The fingerprint of every top-level cell in `program`, by cell name.
+pub fn cell_keys(
+    program: &Program,
+    const_values: &HashMap<String, ConstValue>,
+) -> HashMap<String, String> {
+    let mut interface = Sha256::new();
+    interface.update(env!("CARGO_PKG_VERSION").as_bytes());
+    for d in &program.directives {
+        interface.update(fingerprint_json(d, false).as_bytes());
+    }
+    let mut consts = BTreeMap::new();
+    for item in &program.items {
+        match item {
+            // Cells lowered from a constant's initializer read it directly
+            Item::ConstDecl(c) => {
+                let mut entry = fingerprint_json(c, false);
+                if let Some(value) = const_values.get(&c.name) {
+                    entry.push_str(&format!("={:?}", value));
+                }
+                consts.insert(c.name.as_str(), entry);
+            }
+            Item::Cell(c) => {
+                let mut sig = c.clone();
+                sig.body.clear();
+                interface.update(fingerprint_json(&sig, false).as_bytes());
+            }
+            other => interface.update(fingerprint_json(other, false).as_bytes()),
+        }
+    }
+    let interface = interface.finalize();
+
+    let mut keys = HashMap::new();
+    for item in &program.items {
+        let Item::Cell(c) = item else {
+            continue;
+        };
+        let def = serde_json::to_value(c).unwrap_or(Json::Null);
+        let mut names = Vec::new();
+        collect_strings(&def, &mut names);
+        names.sort();
+        names.dedup();
+
+        let mut key = Sha256::new();
+        key.update(interface);
+        key.update(normalize(def, true).to_string().as_bytes());
+        for name in names {
+            if let Some(entry) = consts.get(name.as_str()) {
+                key.update(entry.as_bytes());
+            }
+        }
+        keys.insert(c.name.clone(), format!("{:x}", key.finalize()));
+    }
+    keys
+}
+
+fn fingerprint_json<T: Serialize>(value: &T, keep_lines: bool) -> String {
+    normalize(
+        serde_json::to_value(value).unwrap_or(Json::Null),
+        keep_lines,
+    )
+    .to_string()
+}
+
+/// Replace each span with its line, or drop it, so that text moving
+/// elsewhere in the file does not change the fingerprint.
+fn normalize(value: Json, keep_lines: bool) -> Json {
+    match value {
+        Json::Object(map) if is_span(&map) => match map.get("line") {
+            Some(line) if keep_lines => line.clone(),
+            _ => Json::Null,
+        },
+        Json::Object(map) => Json::Object(
+            map.into_iter()
+                .map(|(k, v)| (k, normalize(v, keep_lines)))
+                .collect(),
+        ),
+        Json::Array(items) => Json::Array(
+            items
+                .into_iter()
+                .map(|v| normalize(v, keep_lines))
+                .collect(),
+        ),
+        other => other,
+    }
+}
+
+fn is_span(map: &serde_json::Map<String, Json>) -> bool {
+    map.len() == 4
+        && ["start", "end", "line", "col"]
+            .iter()
+            .all(|k| map.contains_key(*k))
+}
+
+/// Every string in `value`: identifiers among them name what a cell reads.
+fn collect_strings(value: &Json, out: &mut Vec<String>) {
+    match value {
+        Json::String(s) => out.push(s.clone()),
+        Json::Array(items) => items.iter().for_each(|v| collect_strings(v, out)),
+        Json::Object(map) => map.values().for_each(|v| collect_strings(v, out)),
+        _ => {}
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::compiler::const_eval::evaluate_consts;
+    use crate::compiler::lexer::Lexer;
+    use crate::compiler::parser::Parser;
+
+    fn keys(src: &str) -> HashMap<String, String> {
+        let tokens = Lexer::new(src, 1, 0).tokenize().expect("lex");
+        let program = Parser::new(tokens).parse_program(vec![]).expect("parse");
+        cell_keys(&program, &evaluate_consts(&program).0)
+    }
+
+    #[test]
+    fn keys_ignore_byte_offsets_but_not_lines() {
+        let base = keys("cell a() -> Int\n  return 1\nend\n\ncell b() -> Int\n  return 2\nend\n");
+        // `a` gets longer on the same line: `b` moves in bytes only
+        let wider =
+            keys("cell a() -> Int\n  return 100\nend\n\ncell b() -> Int\n  return 2\nend\n");
+        assert_ne!(base["a"], wider["a"]);
+        assert_eq!(base["b"], wider["b"]);
+        // `a` gains a line, so `b` starts one line lower
+        let taller = keys(
+            "cell a() -> Int\n  let x = 1\n  return x\nend\n\ncell b() -> Int\n  return 2\nend\n",
+        );
+        assert_ne!(base["b"], taller["b"]);
+    }
+
+    #[test]
+    fn signature_change_rekeys_every_cell() {
+        let base =
+            keys("cell a(x: Int) -> Int\n  return x\nend\n\ncell b() -> Int\n  return 2\nend\n");
+        let changed =
+            keys("cell a(x: Float) -> Int\n  return 1\nend\n\ncell b() -> Int\n  return 2\nend\n");
+        assert_ne!(base["b"], changed["b"]);
+    }
+}
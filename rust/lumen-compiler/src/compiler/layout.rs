@@ -0,0 +1,186 @@
+//! Native layout of records.
+//!
+//! The VM keeps record fields by name, but compiled code and foreign calls see
+//! a record as a block of memory. Each field sits at an offset that is a
+//! multiple of its alignment, and the record's size is rounded up to its
+//! largest field alignment. By default fields are placed in order of
+//! decreasing alignment, which removes all padding between fields of the
+//! builtin types; a `@packed` record keeps declaration order, aligns every
+//! field to one byte and has no padding at all.
+//!
+//! `Int` and `Float` are 8 bytes, `Bool` is 1 byte and `Null` is empty. Every
+//! other type — strings, collections, records, enums — is held by an 8-byte
+//! reference.
+
+use crate::compiler::ast::{RecordDef, TypeExpr};
+use crate::compiler::resolve::{SymbolTable, TypeInfoKind};
+
+/// Size and alignment of a reference to heap data.
+const REFERENCE_SIZE: usize = 8;
+
+/// Where one field lives inside a record.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct FieldLayout {
+    pub name: String,
+    pub offset: usize,
+    pub size: usize,
+}
+
+/// Memory layout of a record, fields in the order they are stored.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct RecordLayout {
+    pub size: usize,
+    pub align: usize,
+    pub fields: Vec<FieldLayout>,
+}
+
+/// Lay out the fields of `def`.
+pub fn record_layout(def: &RecordDef, symbols: &SymbolTable) -> RecordLayout {
+    let mut fields: Vec<(&str, usize, usize)> = def
+        .fields
+        .iter()
+        .map(|f| {
+            let (size, align) = field_size_align(&f.ty, symbols);
+            (f.name.as_str(), size, if def.packed { 1 } else { align })
+        })
+        .collect();
+    if !def.packed {
+        // Stable, so fields of equal alignment keep declaration order
+        fields.sort_by(|a, b| b.2.cmp(&a.2));
+    }
+
+    let mut offset = 0;
+    let mut align = 1;
+    let mut placed = Vec::with_capacity(fields.len());
+    for (name, size, field_align) in fields {
+        offset = round_up(offset, field_align);
+        placed.push(FieldLayout {
+            name: name.to_string(),
+            offset,
+            size,
+        });
+        offset += size;
+        align = align.max(field_align);
+    }
+    RecordLayout {
+        size: round_up(offset, align),
+        align,
+        fields: placed,
+    }
+}
+
+/// Size and alignment of the type named `name`, as reported by `sizeof` and
+/// `alignof`. Returns None when no such type exists.
+pub fn type_size_align(name: &str, symbols: &SymbolTable) -> Option<(usize, usize)> {
+    if let Some(scalar) = scalar_size_align(name) {
+        return Some(scalar);
+    }
+    if let Some(target) = symbols.type_aliases.get(name) {
+        return Some(field_size_align(target, symbols));
+    }
+    match &symbols.types.get(name)?.kind {
+        TypeInfoKind::Record(def) => {
+            let layout = record_layout(def, symbols);
+            Some((layout.size, layout.align))
+        }
+        TypeInfoKind::Enum(_) | TypeInfoKind::Builtin => Some((REFERENCE_SIZE, REFERENCE_SIZE)),
+    }
+}
+
+fn field_size_align(ty: &TypeExpr, symbols: &SymbolTable) -> (usize, usize) {
+    match ty {
+        TypeExpr::Named(name, _) => {
+            if let Some(scalar) = scalar_size_align(name) {
+                scalar
+            } else if let Some(target) = symbols.type_aliases.get(name) {
+                field_size_align(target, symbols)
+            } else {
+                (REFERENCE_SIZE, REFERENCE_SIZE)
+            }
+        }
+        _ => (REFERENCE_SIZE, REFERENCE_SIZE),
+    }
+}
+
+fn scalar_size_align(name: &str) -> Option<(usize, usize)> {
+    match name {
+        "Int" | "Float" => Some((8, 8)),
+        "Bool" => Some((1, 1)),
+        "Null" => Some((0, 1)),
+        _ => None,
+    }
+}
+
+fn round_up(offset: usize, align: usize) -> usize {
+    offset.div_ceil(align) * align
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::compiler::lexer::Lexer;
+    use crate::compiler::parser::Parser;
+    use crate::compiler::resolve::resolve;
+
+    fn symbols_for(src: &str) -> SymbolTable {
+        let mut lexer = Lexer::new(src, 1, 0);
+        let tokens = lexer.tokenize().unwrap();
+        let mut parser = Parser::new(tokens);
+        let program = parser.parse_program(vec![]).unwrap();
+        resolve(&program).unwrap()
+    }
+
+    fn layout_of(src: &str, name: &str) -> RecordLayout {
+        let symbols = symbols_for(src);
+        match &symbols.types[name].kind {
+            TypeInfoKind::Record(def) => record_layout(def, &symbols),
+            _ => panic!("{} is not a record", name),
+        }
+    }
+
+    #[test]
+    fn test_float_record_has_no_padding() {
+        let symbols = symbols_for(
+            "record Body\n  x: Float\n  y: Float\n  z: Float\n  vx: Float\n  vy: Float\n  vz: Float\n  mass: Float\nend",
+        );
+        assert_eq!(type_size_align("Body", &symbols), Some((56, 8)));
+    }
+
+    #[test]
+    fn test_default_layout_orders_by_alignment() {
+        let layout = layout_of(
+            "record Flags\n  a: Bool\n  n: Int\n  b: Bool\nend",
+            "Flags",
+        );
+        let order: Vec<(&str, usize)> = layout
+            .fields
+            .iter()
+            .map(|f| (f.name.as_str(), f.offset))
+            .collect();
+        assert_eq!(order, vec![("n", 0), ("a", 8), ("b", 9)]);
+        assert_eq!((layout.size, layout.align), (16, 8));
+    }
+
+    #[test]
+    fn test_packed_layout_keeps_order_without_padding() {
+        let layout = layout_of(
+            "@packed\nrecord Flags\n  a: Bool\n  n: Int\n  b: Bool\nend",
+            "Flags",
+        );
+        let order: Vec<(&str, usize)> = layout
+            .fields
+            .iter()
+            .map(|f| (f.name.as_str(), f.offset))
+            .collect();
+        assert_eq!(order, vec![("a", 0), ("n", 1), ("b", 9)]);
+        assert_eq!((layout.size, layout.align), (10, 1));
+    }
+
+    #[test]
+    fn test_references_and_scalars() {
+        let symbols = symbols_for("record Named\n  name: String\n  tags: list[String]\nend");
+        assert_eq!(type_size_align("Named", &symbols), Some((16, 8)));
+        assert_eq!(type_size_align("Bool", &symbols), Some((1, 1)));
+        assert_eq!(type_size_align("Missing", &symbols), None);
+    }
+}
@@ -1022,6 +1022,7 @@ impl Lexer {
             "perform" => TokenKind::Perform,
             "handle" => TokenKind::Handle,
             "resume" => TokenKind::Resume,
+            "switch" => TokenKind::Switch,
             // Type keywords
             "bool" => TokenKind::Bool,
             "int" => TokenKind::Int_,
@@ -1387,6 +1388,11 @@ impl Lexer {
                                 self.span_from(so, sl, sc),
                             ));
                         }
+                        // `||` is an alias for `or`
+                        Some('|') => {
+                            self.advance();
+                            tokens.push(Token::new(TokenKind::Or, self.span_from(so, sl, sc)));
+                        }
                         Some('=') => {
                             self.advance();
                             tokens.push(Token::new(
@@ -1406,6 +1412,10 @@ impl Lexer {
                     if self.current() == Some('=') {
                         self.advance();
                         tokens.push(Token::new(TokenKind::AmpAssign, self.span_from(so, sl, sc)));
+                    } else if self.current() == Some('&') {
+                        // `&&` is an alias for `and`
+                        self.advance();
+                        tokens.push(Token::new(TokenKind::And, self.span_from(so, sl, sc)));
                     } else {
                         tokens.push(Token::new(TokenKind::Ampersand, self.span_from(so, sl, sc)));
                     }
@@ -1646,6 +1656,16 @@ mod tests {
         assert!(matches!(&tokens[3].kind, TokenKind::SlashAssign));
     }
 
+    #[test]
+    fn test_lex_symbolic_logical_operators() {
+        let mut lexer = Lexer::new("a && b || c & d | e", 1, 0);
+        let tokens = lexer.tokenize().unwrap();
+        assert!(matches!(&tokens[1].kind, TokenKind::And));
+        assert!(matches!(&tokens[3].kind, TokenKind::Or));
+        assert!(matches!(&tokens[5].kind, TokenKind::Ampersand));
+        assert!(matches!(&tokens[7].kind, TokenKind::Pipe));
+    }
+
     #[test]
     fn test_lex_new_operators() {
         let mut lexer = Lexer::new("** .. ..= |> >> ?? ?. ! ? ... => ++ & ~ ^", 1, 0);
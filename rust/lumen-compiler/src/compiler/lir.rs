@@ -88,6 +88,7 @@ pub enum OpCode {
     ForIn = 0x48,    // A, B, C: for-in iterator step
     Break = 0x49,    // Ax: break from enclosing loop
     Continue = 0x4A, // Ax: continue to next iteration
+    JmpTable = 0x4B, // A, B, C: take Jmp number R[A]-R[C] of the B that follow, else skip them
 
     // Intrinsics
     Intrinsic = 0x50, // A, B, C: A = intrinsic[B](args at C)
@@ -274,6 +275,26 @@ pub enum IntrinsicId {
     Args = 135,
     SetEnv = 136,
     EnvVars = 137,
+    // for-loop iteration protocol
+    IterNext = 138,
+    RangeCount = 139,
+    RangeStep = 140,
+    // repeated-fill list literal `[value; count]`
+    ListRepeat = 141,
+    // bucket of a string `switch` subject
+    SwitchHash = 142,
+}
+
+/// The slot of `s` in a string `switch` table with `buckets` slots: FNV-1a
+/// over its UTF-8 bytes. Lowering places each case with this and the VM's
+/// `SwitchHash` looks the subject up with it, so the two must agree.
+pub fn switch_bucket(s: &str, buckets: u64) -> u64 {
+    let mut hash: u64 = 0xcbf2_9ce4_8422_2325;
+    for &byte in s.as_bytes() {
+        hash ^= byte as u64;
+        hash = hash.wrapping_mul(0x0100_0000_01b3);
+    }
+    hash % buckets.max(1)
 }
 
 /// A 32-bit instruction
@@ -491,6 +512,23 @@ pub struct LirField {
     #[serde(rename = "type")]
     pub ty: String,
     pub constraints: Vec<String>,
+    /// The default value, when it is a literal
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub default: Option<Constant>,
+    /// The field's `@json(...)` attribute
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub json: Option<JsonField>,
+}
+
+/// How `json_marshal` writes a field and `json_unmarshal` reads it.
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+pub struct JsonField {
+    /// The object key, when it is not the field name
+    pub key: Option<String>,
+    /// Never written; read back as the field's default
+    pub skip: bool,
+    /// Not written when null or an empty string, list, set, or map
+    pub omit_empty: bool,
 }
 
 #[derive(Debug, Clone, Serialize, Deserialize)]
@@ -632,11 +670,17 @@ impl LirModule {
     /// Merge another module's definitions into this module.
     ///
     /// This is used during import resolution to link imported modules into the main module.
-    /// String table entries are deduplicated. Other items (cells, types, etc.) are appended,
+    /// String table entries are deduplicated, and the string and closure operands of merged
+    /// cells are renumbered to match. Other items (cells, types, etc.) are appended,
     /// assuming no name conflicts (the resolver should have already checked this).
-    pub fn merge(&mut self, other: &LirModule) {
+    ///
+    /// `GetField` and `SetField` hold their field name's string index in a single byte.
+    /// If a renumbered index no longer fits, the merge fails and leaves `self` as it was.
+    pub fn merge(&mut self, other: &LirModule) -> Result<(), String> {
         use std::collections::HashMap;
 
+        let first_string = self.strings.len();
+        let first_type = self.types.len();
         // Build a map from old string indices in `other` to new indices in `self`
         let mut string_remap: HashMap<usize, usize> = HashMap::new();
         for (old_idx, s) in other.strings.iter().enumerate() {
@@ -655,11 +699,74 @@ impl LirModule {
             }
         }
 
-        // Merge cells (no string remapping needed for simple names)
-        for cell in &other.cells {
-            if !self.cells.iter().any(|c| c.name == cell.name) {
-                self.cells.push(cell.clone());
+        // Merge cells, pointing string-table and closure operands at this
+        // module's tables. Lambda names are only unique within a module, so
+        // an imported lambda that collides is renamed rather than dropped.
+        let mut cell_remap: HashMap<usize, usize> = HashMap::new();
+        let first_merged = self.cells.len();
+        for (old_idx, cell) in other.cells.iter().enumerate() {
+            let is_lambda = cell.name.starts_with("<lambda/");
+            if let Some(existing_idx) = self.cells.iter().position(|c| c.name == cell.name) {
+                if !is_lambda {
+                    cell_remap.insert(old_idx, existing_idx);
+                    continue;
+                }
+            }
+            let mut cell = cell.clone();
+            if is_lambda {
+                let mut n = self.cells.len();
+                while self
+                    .cells
+                    .iter()
+                    .any(|c| c.name == format!("<lambda/{}>", n))
+                {
+                    n += 1;
+                }
+                cell.name = format!("<lambda/{}>", n);
             }
+            cell_remap.insert(old_idx, self.cells.len());
+            self.cells.push(cell);
+        }
+        let remap = |idx: usize| string_remap.get(&idx).copied().unwrap_or(idx);
+        let mut overflow = None;
+        'cells: for cell in &mut self.cells[first_merged..] {
+            for instr in &mut cell.instructions {
+                let fits = match instr.op {
+                    OpCode::NewRecord | OpCode::IsVariant | OpCode::Schema => {
+                        let idx = remap(instr.bx() as usize);
+                        u16::try_from(idx)
+                            .map(|idx| *instr = Instruction::abx(instr.op, instr.a, idx))
+                            .is_ok()
+                    }
+                    OpCode::SetField => u8::try_from(remap(instr.b as usize))
+                        .map(|idx| instr.b = idx)
+                        .is_ok(),
+                    OpCode::GetField => u8::try_from(remap(instr.c as usize))
+                        .map(|idx| instr.c = idx)
+                        .is_ok(),
+                    OpCode::Closure => {
+                        let bx = instr.bx() as usize;
+                        let idx = cell_remap.get(&bx).copied().unwrap_or(bx);
+                        u16::try_from(idx)
+                            .map(|idx| *instr = Instruction::abx(OpCode::Closure, instr.a, idx))
+                            .is_ok()
+                    }
+                    _ => true,
+                };
+                if !fits {
+                    overflow = Some(format!(
+                        "cannot link cell '{}': its {:?} operand does not fit after merging string and cell tables",
+                        cell.name, instr.op
+                    ));
+                    break 'cells;
+                }
+            }
+        }
+        if let Some(message) = overflow {
+            self.cells.truncate(first_merged);
+            self.strings.truncate(first_string);
+            self.types.truncate(first_type);
+            return Err(message);
         }
 
         // Merge tools
@@ -714,5 +821,6 @@ impl LirModule {
                 self.handlers.push(handler.clone());
             }
         }
+        Ok(())
     }
 }
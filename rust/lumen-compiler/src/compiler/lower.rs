@@ -1,10 +1,18 @@
 //! AST → LIR lowering. Converts typed AST to LIR instructions.
 
 use crate::compiler::ast::*;
+use crate::compiler::const_eval::{evaluate_consts, try_const_eval, ConstValue};
+use crate::compiler::gvn;
+use crate::compiler::incremental::{self, CellStore, CompiledCell};
+use crate::compiler::layout::type_size_align;
 use crate::compiler::lir::*;
+use crate::compiler::memoize;
+use crate::compiler::opt_report::{CellReport, OptNote, OptReport, Optimization};
 use crate::compiler::regalloc::RegAlloc;
-use crate::compiler::resolve::SymbolTable;
+use crate::compiler::resolve::{SymbolTable, OPERATOR_TRAITS};
+use crate::compiler::specialize;
 use crate::compiler::tokens::Span;
+use crate::compiler::typecheck;
 use num_bigint::BigInt;
 use sha2::{Digest, Sha256};
 use std::collections::HashMap;
@@ -463,6 +471,7 @@ fn is_block_boundary(op: OpCode) -> bool {
             | OpCode::Halt
             | OpCode::Break
             | OpCode::Continue
+            | OpCode::JmpTable
             | OpCode::Loop
             | OpCode::ForPrep
             | OpCode::ForLoop
@@ -564,6 +573,8 @@ fn instr_reads_reg(instr: &Instruction, reg: u8) -> bool {
         OpCode::Eq | OpCode::Lt | OpCode::Le => b == reg || c == reg,
         // Test reads field a
         OpCode::Test => a == reg,
+        // JmpTable reads the index (a) and the table's low bound (c)
+        OpCode::JmpTable => a == reg || c == reg,
         // Return reads field a
         OpCode::Return => a == reg,
         // SetField reads a, b, c
@@ -606,6 +617,60 @@ fn instr_reads_reg(instr: &Instruction, reg: u8) -> bool {
     }
 }
 
+/// Whether the instruction might read the given register. Unlike
+/// `instr_reads_reg`, an opcode whose operands are not modelled there counts
+/// as reading every register it names, and an intrinsic as reading its whole
+/// argument block.
+fn instr_may_read_reg(instr: &Instruction, reg: u8) -> bool {
+    match instr.op {
+        // Jump offsets and constants, not registers
+        OpCode::Jmp
+        | OpCode::Break
+        | OpCode::Continue
+        | OpCode::Nop
+        | OpCode::LoadK
+        | OpCode::LoadNil
+        | OpCode::LoadBool
+        | OpCode::LoadInt => false,
+        OpCode::Intrinsic => reg >= instr.c,
+        _ => instr_reads_reg(instr, reg) || instr.a == reg || instr.b == reg || instr.c == reg,
+    }
+}
+
+/// The register of `y` when `stmt` is `x = y` and `y` is bound by one of
+/// the `let`s in `earlier`.
+fn moved_binding(stmt: &Stmt, earlier: &[Stmt], ra: &RegAlloc) -> Option<u8> {
+    fn binds(pattern: &Pattern, name: &str) -> bool {
+        match pattern {
+            Pattern::Ident(n, _) => n == name,
+            Pattern::TupleDestructure { elements, .. } => elements.iter().any(|p| binds(p, name)),
+            _ => false,
+        }
+    }
+
+    let Stmt::Assign(asgn) = stmt else {
+        return None;
+    };
+    let (AssignTarget::Variable(target), Expr::Ident(name, _)) = (&asgn.target, &asgn.value) else {
+        return None;
+    };
+    if target == name || target.contains('.') {
+        return None;
+    }
+    let bound_here = earlier.iter().any(|s| match s {
+        Stmt::Let(ls) => match &ls.pattern {
+            Some(pattern) => binds(pattern, name),
+            None => ls.name == *name,
+        },
+        _ => false,
+    });
+    if bound_here {
+        ra.lookup(name)
+    } else {
+        None
+    }
+}
+
 /// Post-lowering pass: hoist loop-invariant `LoadK`, `LoadBool`, and `LoadInt`
 /// instructions out of loops.
 ///
@@ -620,9 +685,11 @@ fn instr_reads_reg(instr: &Instruction, reg: u8) -> bool {
 /// the loop header and the original slot is replaced with `Nop`.  All jump
 /// offsets referencing instructions at or after the insertion point are adjusted
 /// to account for the newly inserted instructions.
-fn hoist_loop_invariants(instrs: &mut Vec<Instruction>) {
+///
+/// Returns the number of instructions moved.
+fn hoist_loop_invariants(instrs: &mut Vec<Instruction>) -> usize {
     if instrs.len() < 3 {
-        return;
+        return 0;
     }
 
     // Collect loops: each backward Jmp defines a loop [header .. back_edge].
@@ -659,6 +726,7 @@ fn hoist_loop_invariants(instrs: &mut Vec<Instruction>) {
     // don't shift the indices of earlier loops.
     loops.sort_by(|a, b| b.header.cmp(&a.header));
 
+    let mut moved = 0;
     for region in &loops {
         let header = region.header;
         let back_edge = region.back_edge;
@@ -690,6 +758,14 @@ fn hoist_loop_invariants(instrs: &mut Vec<Instruction>) {
                     other_writes = true;
                     break;
                 }
+                // IterNext also advances its `[iter, cursor, more]` block at `c`
+                if i2.op == OpCode::Intrinsic
+                    && i2.b == IntrinsicId::IterNext as u8
+                    && (i2.c..=i2.c.saturating_add(2)).contains(&dest)
+                {
+                    other_writes = true;
+                    break;
+                }
             }
             if !other_writes {
                 hoistable.push((pc, inst));
@@ -701,6 +777,7 @@ fn hoist_loop_invariants(instrs: &mut Vec<Instruction>) {
         }
 
         let n = hoistable.len();
+        moved += n;
 
         // Replace originals with Nop.
         for &(pc, _) in &hoistable {
@@ -760,6 +837,38 @@ fn hoist_loop_invariants(instrs: &mut Vec<Instruction>) {
             instrs.insert(insert_point + idx, inst);
         }
     }
+    moved
+}
+
+/// Number of `LoadK`, `LoadBool`, and `LoadInt` instructions between a
+/// backward jump and its target, for the optimization report: the ones
+/// [`hoist_loop_invariants`] had to leave in place.
+fn constant_loads_in_loops(instrs: &[Instruction]) -> usize {
+    let mut in_loop = vec![false; instrs.len()];
+    for (pc, inst) in instrs.iter().enumerate() {
+        if matches!(inst.op, OpCode::Jmp | OpCode::Break | OpCode::Continue) {
+            let target = pc as i32 + 1 + inst.sax_val();
+            if target >= 0 && (target as usize) < pc {
+                in_loop[target as usize..=pc].fill(true);
+            }
+        }
+    }
+    instrs
+        .iter()
+        .zip(&in_loop)
+        .filter(|&(inst, &in_loop)| {
+            in_loop && matches!(inst.op, OpCode::LoadK | OpCode::LoadBool | OpCode::LoadInt)
+        })
+        .count()
+}
+
+/// "1 constant load" or "n constant loads".
+fn constant_loads(n: usize) -> String {
+    if n == 1 {
+        "1 constant load".to_string()
+    } else {
+        format!("{} constant loads", n)
+    }
 }
 
 /// Peephole pass: eliminate redundant `Eq(bool, true)` comparisons.
@@ -924,6 +1033,264 @@ fn strip_nops(instrs: &mut Vec<Instruction>) {
     instrs.retain(|i| i.op != OpCode::Nop);
 }
 
+/// A constant-pool entry compared by type and exact value.
+#[derive(PartialEq, Eq, Hash)]
+enum ConstantKey<'a> {
+    Null,
+    Bool(bool),
+    Int(i64),
+    BigInt(&'a BigInt),
+    /// The bit pattern, so `0.0` and `-0.0` stay apart and a NaN equals
+    /// only a NaN with the same payload
+    Float(u64),
+    String(&'a str),
+}
+
+impl<'a> ConstantKey<'a> {
+    fn of(constant: &'a Constant) -> Self {
+        match constant {
+            Constant::Null => ConstantKey::Null,
+            Constant::Bool(b) => ConstantKey::Bool(*b),
+            Constant::Int(n) => ConstantKey::Int(*n),
+            Constant::BigInt(n) => ConstantKey::BigInt(n),
+            Constant::Float(f) => ConstantKey::Float(f.to_bits()),
+            Constant::String(s) => ConstantKey::String(s),
+        }
+    }
+}
+
+/// Post-lowering pass: give equal constants a single constant-pool slot.
+///
+/// Lowering adds a constant for every literal it meets, so a string used in
+/// a loop body twice, or `0.5` written throughout a physics kernel, would
+/// otherwise fill several slots. Constants are equal when they have the same
+/// type and value (see [`ConstantKey`]); `1` and `1.0` never share. Surviving
+/// entries keep their first-use order, and `LoadK` and `Perform` — the only
+/// instructions that name a constant — are pointed at them.
+fn dedup_constants(constants: &mut Vec<Constant>, instrs: &mut [Instruction]) {
+    let mut slots: HashMap<ConstantKey, u16> = HashMap::new();
+    let remap: Vec<u16> = constants
+        .iter()
+        .map(|c| {
+            let next = slots.len() as u16;
+            *slots.entry(ConstantKey::of(c)).or_insert(next)
+        })
+        .collect();
+    if slots.len() == constants.len() {
+        return;
+    }
+
+    // First uses got slots in order, so each is kept exactly when its slot
+    // is the next one to fill
+    for (old, c) in std::mem::take(constants).into_iter().enumerate() {
+        if remap[old] as usize == constants.len() {
+            constants.push(c);
+        }
+    }
+    let slot = |old: usize| remap.get(old).copied().unwrap_or(old as u16);
+    for instr in instrs.iter_mut() {
+        match instr.op {
+            OpCode::LoadK => {
+                *instr = Instruction::abx(OpCode::LoadK, instr.a, slot(instr.bx() as usize))
+            }
+            OpCode::Perform => {
+                instr.b = slot(instr.b as usize) as u8;
+                instr.c = slot(instr.c as usize) as u8;
+            }
+            _ => {}
+        }
+    }
+}
+
+/// The operators some record type in `symbols` overloads with a `T.add`,
+/// `T.eq`, ... cell. The VM calls that cell when the left operand is such
+/// a record, so value numbering must leave these operators alone.
+fn overloaded_operators(symbols: &SymbolTable) -> Vec<OpCode> {
+    OPERATOR_TRAITS
+        .iter()
+        .filter(|&&(_, method, _)| {
+            let suffix = format!(".{}", method);
+            symbols.cells.keys().any(|name| name.ends_with(&suffix))
+        })
+        .filter_map(|&(trait_name, _, _)| match trait_name {
+            "Add" => Some(OpCode::Add),
+            "Sub" => Some(OpCode::Sub),
+            "Mul" => Some(OpCode::Mul),
+            "Div" => Some(OpCode::Div),
+            "Eq" => Some(OpCode::Eq),
+            _ => None,
+        })
+        .collect()
+}
+
+/// `p.x.y = v` parses as an assignment to the dotted name `"p.x.y"`. Rewrite
+/// it as the index target `p["x"]["y"]` so the store goes through each
+/// enclosing record and is written back to `p`.
+fn dotted_index_target(name: &str, span: Span) -> AssignTarget {
+    let mut parts = name.split('.');
+    let mut base = Expr::Ident(parts.next().unwrap_or_default().to_string(), span);
+    let mut field = parts.next().unwrap_or_default();
+    for next in parts {
+        let key = Expr::StringLit(field.to_string(), span);
+        base = Expr::IndexAccess(Box::new(base), Box::new(key), span);
+        field = next;
+    }
+    AssignTarget::Index(
+        Box::new(base),
+        Box::new(Expr::StringLit(field.to_string(), span)),
+    )
+}
+
+/// Make a `mut`-receiver method return the tuple `(result, receiver)` so the
+/// caller can write the updated receiver back. Every `Return` becomes a `Jmp`
+/// to an epilogue appended after the body that packs the tuple, so no jump in
+/// the body moves. A `TailCall` would bypass the epilogue, so it becomes a
+/// plain `Call` inside its own epilogue.
+fn return_receiver_with_results(instrs: &mut Vec<Instruction>, receiver: u8, tuple: u8) {
+    fn push_epilogue(instrs: &mut Vec<Instruction>, value: u8, receiver: u8, tuple: u8) {
+        instrs.push(Instruction::abc(OpCode::Move, tuple + 1, value, 0));
+        instrs.push(Instruction::abc(OpCode::Move, tuple + 2, receiver, 0));
+        instrs.push(Instruction::abc(OpCode::NewTuple, tuple, 2, 0));
+        instrs.push(Instruction::abc(OpCode::Return, tuple, 1, 0));
+    }
+
+    let mut epilogues: HashMap<u8, usize> = HashMap::new();
+    for pc in 0..instrs.len() {
+        let inst = instrs[pc];
+        let target = match inst.op {
+            OpCode::Return => match epilogues.get(&inst.a) {
+                Some(&start) => start,
+                None => {
+                    let start = instrs.len();
+                    push_epilogue(instrs, inst.a, receiver, tuple);
+                    epilogues.insert(inst.a, start);
+                    start
+                }
+            },
+            OpCode::TailCall => {
+                let start = instrs.len();
+                instrs.push(Instruction::abc(OpCode::Call, inst.a, inst.b, 1));
+                push_epilogue(instrs, inst.a, receiver, tuple);
+                start
+            }
+            _ => continue,
+        };
+        instrs[pc] = Instruction::sax(OpCode::Jmp, (target - pc - 1) as i32);
+    }
+}
+
+/// Fewest distinct integer cases for which a jump table beats a compare chain.
+const JUMP_TABLE_MIN_CASES: usize = 4;
+
+/// Plan a `JmpTable` for match arms that are all integer literals (or
+/// alternatives of them), optionally ending in a wildcard arm.
+///
+/// Returns the lowest case value and, for each value from there up, the arm
+/// it selects; gaps select the wildcard arm, or `None` if there is none.
+/// Returns `None` when the cases are too few or too sparse for a table.
+fn plan_int_jump_table(arms: &[MatchArm]) -> Option<(i64, Vec<Option<usize>>)> {
+    let mut cases: Vec<(i64, usize)> = Vec::new();
+    let mut default_arm = None;
+    for (idx, arm) in arms.iter().enumerate() {
+        let literals = match &arm.pattern {
+            Pattern::Wildcard(_) if idx + 1 == arms.len() => {
+                default_arm = Some(idx);
+                continue;
+            }
+            Pattern::Or { patterns, .. } => patterns.as_slice(),
+            single @ Pattern::Literal(_) => std::slice::from_ref(single),
+            _ => return None,
+        };
+        for lit in literals {
+            let Pattern::Literal(expr) = lit else {
+                return None;
+            };
+            let Some(ConstValue::Int(n)) = try_const_eval(expr) else {
+                return None;
+            };
+            // The first arm listing a value wins, as in the compare chain.
+            if !cases.iter().any(|&(v, _)| v == n) {
+                cases.push((n, idx));
+            }
+        }
+    }
+    if cases.len() < JUMP_TABLE_MIN_CASES {
+        return None;
+    }
+    let low = cases.iter().map(|&(v, _)| v).min()?;
+    let high = cases.iter().map(|&(v, _)| v).max()?;
+    let span = high.checked_sub(low)?.checked_add(1)?;
+    if span > u8::MAX as i64 || span > 2 * cases.len() as i64 {
+        return None;
+    }
+    let mut slots = vec![default_arm; span as usize];
+    for (v, arm) in cases {
+        slots[(v - low) as usize] = Some(arm);
+    }
+    Some((low, slots))
+}
+
+/// Plan a hashed `JmpTable` for match arms that are all string literals (or
+/// alternatives of them), optionally ending in a wildcard arm.
+///
+/// Each case goes in slot `switch_bucket(case, slots)`. A slot selects the
+/// first arm with a case in it, and dispatch lands on that arm's compare, so
+/// a subject that hashes there but matches nothing, or matches a later arm
+/// sharing the slot, carries on down the chain from that arm. Arms before it
+/// have no case in the slot and so cannot match. Empty slots select the
+/// wildcard arm, or `None` if there is none. The slot count is the one in
+/// `cases..=2 * cases` that leaves the fewest slots shared between arms.
+fn plan_string_jump_table(arms: &[MatchArm]) -> Option<Vec<Option<usize>>> {
+    let mut cases: Vec<(String, usize)> = Vec::new();
+    let mut default_arm = None;
+    for (idx, arm) in arms.iter().enumerate() {
+        let literals = match &arm.pattern {
+            Pattern::Wildcard(_) if idx + 1 == arms.len() => {
+                default_arm = Some(idx);
+                continue;
+            }
+            Pattern::Or { patterns, .. } => patterns.as_slice(),
+            single @ Pattern::Literal(_) => std::slice::from_ref(single),
+            _ => return None,
+        };
+        for lit in literals {
+            let Pattern::Literal(expr) = lit else {
+                return None;
+            };
+            let Some(ConstValue::String(s)) = try_const_eval(expr) else {
+                return None;
+            };
+            if !cases.iter().any(|(v, _)| *v == s) {
+                cases.push((s, idx));
+            }
+        }
+    }
+    if cases.len() < JUMP_TABLE_MIN_CASES || cases.len() > u8::MAX as usize {
+        return None;
+    }
+    let fill = |buckets: usize| {
+        let mut slots: Vec<Option<usize>> = vec![None; buckets];
+        let mut shared = 0;
+        for (case, arm) in &cases {
+            let slot = &mut slots[switch_bucket(case, buckets as u64) as usize];
+            match *slot {
+                None => *slot = Some(*arm),
+                Some(first) if first != *arm => shared += 1,
+                Some(_) => {}
+            }
+        }
+        (shared, slots)
+    };
+    let most = (2 * cases.len()).min(u8::MAX as usize);
+    let (_, mut slots) = (cases.len()..=most)
+        .map(fill)
+        .min_by_key(|(shared, _)| *shared)?;
+    for slot in slots.iter_mut().filter(|slot| slot.is_none()) {
+        *slot = default_arm;
+    }
+    Some(slots)
+}
+
 /// Recursively scan a cell body for local definitions and lift them to module
 /// level. Local records/enums become LIR types; local cells become LIR cells.
 fn lift_local_defs(body: &[Stmt], module: &mut LirModule, lowerer: &mut Lowerer) {
@@ -960,8 +1327,67 @@ fn lift_local_defs(body: &[Stmt], module: &mut LirModule, lowerer: &mut Lowerer)
     }
 }
 
+/// Whether some method name takes a `mut` receiver on one type and a value
+/// receiver on another.
+fn has_mixed_receivers(symbols: &SymbolTable) -> bool {
+    let mut kinds: HashMap<&str, Receiver> = HashMap::new();
+    for (name, ci) in &symbols.cells {
+        let (Some(receiver), Some((_, method))) = (ci.receiver, name.rsplit_once('.')) else {
+            continue;
+        };
+        if *kinds.entry(method).or_insert(receiver) != receiver {
+            return true;
+        }
+    }
+    false
+}
+
 /// Lower an entire program to a LIR module.
 pub fn lower(program: &Program, symbols: &SymbolTable, source: &str) -> LirModule {
+    lower_program(program, symbols, source, None, None)
+}
+
+/// Lower a program, taking each top-level cell from `store` when its
+/// fingerprint is there and putting the cells it lowers into it.
+pub fn lower_with_store(
+    program: &Program,
+    symbols: &SymbolTable,
+    source: &str,
+    store: &mut dyn CellStore,
+) -> LirModule {
+    lower_program(program, symbols, source, Some(store), None)
+}
+
+/// Lower a program and note, for each cell, the optimizations that fired
+/// and the ones that were blocked.
+pub fn lower_with_report(
+    program: &Program,
+    symbols: &SymbolTable,
+    source: &str,
+) -> (LirModule, OptReport) {
+    let mut report = OptReport::default();
+    let module = lower_program(program, symbols, source, None, Some(&mut report));
+    (module, report)
+}
+
+fn lower_program(
+    program: &Program,
+    symbols: &SymbolTable,
+    source: &str,
+    mut store: Option<&mut dyn CellStore>,
+    report: Option<&mut OptReport>,
+) -> LirModule {
+    // Only a method name some types declare `mut` and others not needs the
+    // receiver types; specialized copies keep the spans of their originals
+    let method_receivers = if has_mixed_receivers(symbols) {
+        typecheck::method_receivers(program, symbols)
+    } else {
+        HashMap::new()
+    };
+    let expanded = specialize::expand(program, symbols);
+    let program = expanded.as_ref().unwrap_or(program);
+    let memoized = memoize::expand(program);
+    let program = memoized.as_ref().unwrap_or(program);
     let doc_hash = format!("sha256:{:x}", Sha256::digest(source.as_bytes()));
     let mut module = LirModule::new(doc_hash);
     let mut lowerer = Lowerer::new(
@@ -969,6 +1395,16 @@ pub fn lower(program: &Program, symbols: &SymbolTable, source: &str) -> LirModul
         collect_effect_tool_bindings(program),
         collect_effect_handler_cells(program),
     );
+    lowerer.const_values = evaluate_consts(program).0;
+    lowerer.method_receivers = method_receivers;
+    if report.is_some() {
+        lowerer.opt_report = Some(OptReport::default());
+    }
+    let cell_keys = if store.is_some() {
+        incremental::cell_keys(program, &lowerer.const_values)
+    } else {
+        HashMap::new()
+    };
 
     for d in &program.directives {
         let name = match &d.value {
@@ -999,7 +1435,13 @@ pub fn lower(program: &Program, symbols: &SymbolTable, source: &str) -> LirModul
         match item {
             Item::Record(r) => module.types.push(lowerer.lower_record(r)),
             Item::Enum(e) => module.types.push(lowerer.lower_enum(e)),
-            Item::Cell(c) => module.cells.push(lowerer.lower_cell(c)),
+            Item::Cell(c) => {
+                let cell = match (store.as_deref_mut(), cell_keys.get(&c.name)) {
+                    (Some(store), Some(key)) => lowerer.lower_cell_cached(c, key, store),
+                    _ => lowerer.lower_cell(c),
+                };
+                module.cells.push(cell);
+            }
             Item::Agent(a) => {
                 module.types.push(lowerer.lower_agent_type(a));
                 module.cells.push(lowerer.lower_agent_constructor(a));
@@ -1120,6 +1562,7 @@ pub fn lower(program: &Program, symbols: &SymbolTable, source: &str) -> LirModul
                     let mut body = vec![Stmt::Let(LetStmt {
                         name: value_name.clone(),
                         mutable: true,
+                        is_var: false,
                         pattern: None,
                         ty: Some(TypeExpr::Named("Any".to_string(), span)),
                         value: Expr::Ident("input".to_string(), span),
@@ -1167,11 +1610,16 @@ pub fn lower(program: &Program, symbols: &SymbolTable, source: &str) -> LirModul
                         is_pub: false,
                         is_async: false,
                         is_extern: false,
+                        is_const: false,
                         must_use: false,
                         where_clauses: vec![],
                         span,
                         doc: None,
                         deprecated: None,
+                        receiver: None,
+                        specializations: vec![],
+                        tailcall: false,
+                        memoize: None,
                     };
                     module.cells.push(lowerer.lower_cell(&generated));
                 }
@@ -1285,214 +1733,111 @@ pub fn lower(program: &Program, symbols: &SymbolTable, source: &str) -> LirModul
     }
     module.cells.append(&mut lowerer.lambda_cells);
 
+    for (kind, cells) in [
+        ("call_lines", &lowerer.call_lines),
+        ("alloc_lines", &lowerer.alloc_lines),
+    ] {
+        for (cell, lines) in cells {
+            if lines.is_empty() {
+                continue;
+            }
+            let lines: Vec<String> = lines.iter().map(|l| l.to_string()).collect();
+            module.addons.push(LirAddon {
+                kind: kind.into(),
+                name: Some(format!("{}={}", cell, lines.join(","))),
+            });
+        }
+    }
+
     // Collect string table
     module.strings = lowerer.strings;
+    if let (Some(report), Some(notes)) = (report, lowerer.opt_report) {
+        *report = notes;
+    }
     module
 }
 
-/// Result of compile-time constant evaluation for `comptime` expressions.
-#[derive(Debug, Clone)]
-enum ConstValue {
-    Int(i64),
-    BigInt(BigInt),
-    Float(f64),
-    String(String),
-    Bool(bool),
-    Null,
+/// Source line of each `Call`, `TailCall` and `Intrinsic` in `instrs`, in
+/// order: the line of the last statement that started at or before it.
+fn call_lines(instrs: &[Instruction], marks: &[(usize, usize)]) -> Vec<usize> {
+    site_lines(instrs, marks, |op| {
+        matches!(op, OpCode::Call | OpCode::TailCall | OpCode::Intrinsic)
+    })
 }
 
-/// Attempt to evaluate an expression at compile time.
-///
-/// Returns `Some(ConstValue)` if the expression can be fully reduced to a
-/// constant, `None` otherwise (e.g. when it references variables or calls).
-fn try_const_eval(expr: &Expr) -> Option<ConstValue> {
-    match expr {
-        // Leaf literals
-        Expr::IntLit(n, _) => Some(ConstValue::Int(*n)),
-        Expr::BigIntLit(n, _) => Some(ConstValue::BigInt(n.clone())),
-        Expr::FloatLit(f, _) => Some(ConstValue::Float(*f)),
-        Expr::StringLit(s, _) | Expr::RawStringLit(s, _) => Some(ConstValue::String(s.clone())),
-        Expr::BoolLit(b, _) => Some(ConstValue::Bool(*b)),
-        Expr::NullLit(_) => Some(ConstValue::Null),
-
-        // Unary operators
-        Expr::UnaryOp(op, inner, _) => {
-            let val = try_const_eval(inner)?;
-            match (op, val) {
-                (UnaryOp::Neg, ConstValue::Int(n)) => Some(ConstValue::Int(n.wrapping_neg())),
-                (UnaryOp::Neg, ConstValue::Float(f)) => Some(ConstValue::Float(-f)),
-                (UnaryOp::Neg, ConstValue::BigInt(n)) => {
-                    let negated = -n;
-                    // If the negated BigInt fits in i64, fold to Int
-                    use num_traits::ToPrimitive;
-                    if let Some(i) = negated.to_i64() {
-                        Some(ConstValue::Int(i))
-                    } else {
-                        Some(ConstValue::BigInt(negated))
-                    }
-                }
-                (UnaryOp::Not, ConstValue::Bool(b)) => Some(ConstValue::Bool(!b)),
-                (UnaryOp::BitNot, ConstValue::Int(n)) => Some(ConstValue::Int(!n)),
-                _ => None,
-            }
-        }
-
-        // Binary operators
-        Expr::BinOp(lhs, op, rhs, _) => {
-            let l = try_const_eval(lhs)?;
-            let r = try_const_eval(rhs)?;
-            match (l, op, r) {
-                // Int arithmetic
-                (ConstValue::Int(a), BinOp::Add, ConstValue::Int(b)) => {
-                    Some(ConstValue::Int(a.wrapping_add(b)))
-                }
-                (ConstValue::Int(a), BinOp::Sub, ConstValue::Int(b)) => {
-                    Some(ConstValue::Int(a.wrapping_sub(b)))
-                }
-                (ConstValue::Int(a), BinOp::Mul, ConstValue::Int(b)) => {
-                    Some(ConstValue::Int(a.wrapping_mul(b)))
-                }
-                (ConstValue::Int(a), BinOp::Div, ConstValue::Int(b)) if b != 0 => {
-                    Some(ConstValue::Int(a / b))
-                }
-                (ConstValue::Int(a), BinOp::FloorDiv, ConstValue::Int(b)) if b != 0 => {
-                    Some(ConstValue::Int(a.div_euclid(b)))
-                }
-                (ConstValue::Int(a), BinOp::Mod, ConstValue::Int(b)) if b != 0 => {
-                    Some(ConstValue::Int(a.rem_euclid(b)))
-                }
-                (ConstValue::Int(a), BinOp::Pow, ConstValue::Int(b)) if b >= 0 => {
-                    Some(ConstValue::Int(a.wrapping_pow(b as u32)))
-                }
-                (ConstValue::Int(a), BinOp::BitAnd, ConstValue::Int(b)) => {
-                    Some(ConstValue::Int(a & b))
-                }
-                (ConstValue::Int(a), BinOp::BitOr, ConstValue::Int(b)) => {
-                    Some(ConstValue::Int(a | b))
-                }
-                (ConstValue::Int(a), BinOp::BitXor, ConstValue::Int(b)) => {
-                    Some(ConstValue::Int(a ^ b))
-                }
-                (ConstValue::Int(a), BinOp::Shl, ConstValue::Int(b)) if (0..64).contains(&b) => {
-                    Some(ConstValue::Int(a.wrapping_shl(b as u32)))
-                }
-                (ConstValue::Int(a), BinOp::Shr, ConstValue::Int(b)) if (0..64).contains(&b) => {
-                    Some(ConstValue::Int(a.wrapping_shr(b as u32)))
-                }
-
-                // Float arithmetic
-                (ConstValue::Float(a), BinOp::Add, ConstValue::Float(b)) => {
-                    Some(ConstValue::Float(a + b))
-                }
-                (ConstValue::Float(a), BinOp::Sub, ConstValue::Float(b)) => {
-                    Some(ConstValue::Float(a - b))
-                }
-                (ConstValue::Float(a), BinOp::Mul, ConstValue::Float(b)) => {
-                    Some(ConstValue::Float(a * b))
-                }
-                (ConstValue::Float(a), BinOp::Div, ConstValue::Float(b)) if b != 0.0 => {
-                    Some(ConstValue::Float(a / b))
-                }
-                (ConstValue::Float(a), BinOp::Pow, ConstValue::Float(b)) => {
-                    Some(ConstValue::Float(a.powf(b)))
-                }
-
-                // Mixed int/float promotion
-                (ConstValue::Int(a), BinOp::Add, ConstValue::Float(b)) => {
-                    Some(ConstValue::Float(a as f64 + b))
-                }
-                (ConstValue::Float(a), BinOp::Add, ConstValue::Int(b)) => {
-                    Some(ConstValue::Float(a + b as f64))
-                }
-                (ConstValue::Int(a), BinOp::Sub, ConstValue::Float(b)) => {
-                    Some(ConstValue::Float(a as f64 - b))
-                }
-                (ConstValue::Float(a), BinOp::Sub, ConstValue::Int(b)) => {
-                    Some(ConstValue::Float(a - b as f64))
-                }
-                (ConstValue::Int(a), BinOp::Mul, ConstValue::Float(b)) => {
-                    Some(ConstValue::Float(a as f64 * b))
-                }
-                (ConstValue::Float(a), BinOp::Mul, ConstValue::Int(b)) => {
-                    Some(ConstValue::Float(a * b as f64))
-                }
-                (ConstValue::Int(a), BinOp::Div, ConstValue::Float(b)) if b != 0.0 => {
-                    Some(ConstValue::Float(a as f64 / b))
-                }
-                (ConstValue::Float(a), BinOp::Div, ConstValue::Int(b)) if b != 0 => {
-                    Some(ConstValue::Float(a / b as f64))
-                }
-
-                // String concatenation
-                (ConstValue::String(a), BinOp::Add, ConstValue::String(b)) => {
-                    Some(ConstValue::String(format!("{}{}", a, b)))
-                }
-                (ConstValue::String(a), BinOp::Concat, ConstValue::String(b)) => {
-                    Some(ConstValue::String(format!("{}{}", a, b)))
-                }
-
-                // Comparison (Int)
-                (ConstValue::Int(a), BinOp::Eq, ConstValue::Int(b)) => {
-                    Some(ConstValue::Bool(a == b))
-                }
-                (ConstValue::Int(a), BinOp::NotEq, ConstValue::Int(b)) => {
-                    Some(ConstValue::Bool(a != b))
-                }
-                (ConstValue::Int(a), BinOp::Lt, ConstValue::Int(b)) => {
-                    Some(ConstValue::Bool(a < b))
-                }
-                (ConstValue::Int(a), BinOp::LtEq, ConstValue::Int(b)) => {
-                    Some(ConstValue::Bool(a <= b))
-                }
-                (ConstValue::Int(a), BinOp::Gt, ConstValue::Int(b)) => {
-                    Some(ConstValue::Bool(a > b))
-                }
-                (ConstValue::Int(a), BinOp::GtEq, ConstValue::Int(b)) => {
-                    Some(ConstValue::Bool(a >= b))
-                }
-
-                // Boolean logic
-                (ConstValue::Bool(a), BinOp::And, ConstValue::Bool(b)) => {
-                    Some(ConstValue::Bool(a && b))
-                }
-                (ConstValue::Bool(a), BinOp::Or, ConstValue::Bool(b)) => {
-                    Some(ConstValue::Bool(a || b))
-                }
-
-                // Spaceship (Int)
-                (ConstValue::Int(a), BinOp::Spaceship, ConstValue::Int(b)) => {
-                    Some(ConstValue::Int(if a < b {
-                        -1
-                    } else if a == b {
-                        0
-                    } else {
-                        1
-                    }))
-                }
+/// Source line of each instruction in `instrs` that allocates a list, map,
+/// record, tuple, or set, in order, for the allocation profiler.
+fn alloc_lines(instrs: &[Instruction], marks: &[(usize, usize)]) -> Vec<usize> {
+    site_lines(instrs, marks, |op| {
+        matches!(
+            op,
+            OpCode::NewList
+                | OpCode::NewMap
+                | OpCode::NewRecord
+                | OpCode::NewTuple
+                | OpCode::NewSet
+        )
+    })
+}
 
-                _ => None,
-            }
+fn site_lines(
+    instrs: &[Instruction],
+    marks: &[(usize, usize)],
+    is_site: fn(OpCode) -> bool,
+) -> Vec<usize> {
+    let mut lines = Vec::new();
+    let mut mark = 0;
+    for (pos, instr) in instrs.iter().enumerate() {
+        if !is_site(instr.op) {
+            continue;
         }
-
-        // Block expressions: evaluate if the block is a single expression statement
-        Expr::BlockExpr(stmts, _) => {
-            if stmts.len() == 1 {
-                if let Stmt::Expr(ExprStmt { expr: inner, .. }) = &stmts[0] {
-                    return try_const_eval(inner);
-                }
-                if let Stmt::Return(ReturnStmt { value: inner, .. }) = &stmts[0] {
-                    return try_const_eval(inner);
-                }
-            }
-            None
+        while mark + 1 < marks.len() && marks[mark + 1].0 <= pos {
+            mark += 1;
         }
+        lines.push(marks.get(mark).map_or(0, |m| m.1));
+    }
+    lines
+}
 
-        // Nested comptime just recurses
-        Expr::ComptimeExpr(inner, _) => try_const_eval(inner),
+/// Load a compile-time constant into `dest`.
+fn emit_const_value(
+    val: ConstValue,
+    dest: u8,
+    consts: &mut Vec<Constant>,
+    instrs: &mut Vec<Instruction>,
+) {
+    let constant = match val {
+        ConstValue::Int(n) => Constant::Int(n),
+        ConstValue::BigInt(n) => Constant::BigInt(n),
+        ConstValue::Float(f) => Constant::Float(f),
+        ConstValue::String(s) => Constant::String(s),
+        ConstValue::Bool(b) => {
+            instrs.push(Instruction::abc(
+                OpCode::LoadBool,
+                dest,
+                if b { 1 } else { 0 },
+                0,
+            ));
+            return;
+        }
+        ConstValue::Null => {
+            instrs.push(Instruction::abc(OpCode::LoadNil, dest, 0, 0));
+            return;
+        }
+    };
+    let kidx = consts.len() as u16;
+    consts.push(constant);
+    instrs.push(Instruction::abx(OpCode::LoadK, dest, kidx));
+}
 
-        // Anything else (variables, calls, etc.) is not a compile-time constant
-        _ => None,
-    }
+/// A `for` loop header emitted by `lower_for_head`, closed by `lower_for_close`
+struct ForHead {
+    loop_start: usize,
+    exit_jmp: usize,
+    elem_reg: u8,
+    /// Base of the `[value, end, step, inclusive, index, count, one]` block
+    /// for a counted range loop; None for loops driven by `IterNext`
+    counter: Option<u8>,
 }
 
 /// Tracks a loop for break/continue patching
@@ -1500,9 +1845,9 @@ struct LoopContext {
     label: Option<String>,
     break_jumps: Vec<usize>,
     /// Indices of continue Jmp instructions that need forward-patching.
-    /// For for-loops, these jump to the iterator-advance (idx += 1) section
-    /// rather than to `start` (which would skip the increment and cause
-    /// infinite loops).
+    /// For for-loops, these jump to the advance section emitted by
+    /// `lower_for_close` rather than to `start` (which would skip a counted
+    /// range's increment and cause infinite loops).
     continue_jumps: Vec<usize>,
 }
 
@@ -1519,6 +1864,24 @@ struct Lowerer<'a> {
     /// Accumulated effect handler metadata for the current cell being lowered.
     /// Each entry corresponds to one HandlePush instruction emitted.
     effect_handler_metas: Vec<LirEffectHandlerMeta>,
+    /// Top-level constants reduced at compile time; loaded instead of inlining their initializer
+    const_values: HashMap<String, ConstValue>,
+    /// `(instruction index, source line)` at the start of each statement of
+    /// the cell being lowered
+    line_marks: Vec<(usize, usize)>,
+    /// Source line of each call in each lowered cell, for `backtrace()`
+    call_lines: Vec<(String, Vec<usize>)>,
+    /// Source line of each allocation in each lowered cell
+    alloc_lines: Vec<(String, Vec<usize>)>,
+    /// Strings interned while lowering a cell for a [`CellStore`], in order
+    interned: Option<Vec<String>>,
+    /// Optimization notes for the cell being lowered
+    opt_notes: Vec<OptNote>,
+    /// Notes of each lowered cell, when an optimization report was asked for
+    opt_report: Option<OptReport>,
+    /// Whether each method call the typechecker resolved takes a `mut`
+    /// receiver, by the byte range of the call
+    method_receivers: HashMap<(usize, usize), bool>,
 }
 
 impl<'a> Lowerer<'a> {
@@ -1544,10 +1907,23 @@ impl<'a> Lowerer<'a> {
             lambda_cells: Vec::new(),
             defer_stack: Vec::new(),
             effect_handler_metas: Vec::new(),
+            const_values: HashMap::new(),
+            line_marks: Vec::new(),
+            call_lines: Vec::new(),
+            alloc_lines: Vec::new(),
+            interned: None,
+            opt_notes: Vec::new(),
+            opt_report: None,
+            method_receivers: HashMap::new(),
         }
     }
 
     fn intern_string(&mut self, s: &str) -> u16 {
+        if let Some(interned) = &mut self.interned {
+            if !interned.iter().any(|x| x == s) {
+                interned.push(s.to_string());
+            }
+        }
         if let Some(idx) = self.strings.iter().position(|x| x == s) {
             idx as u16
         } else {
@@ -1640,6 +2016,45 @@ impl<'a> Lowerer<'a> {
         result_reg
     }
 
+    /// Whether the call `obj.method(...)` at `call` goes to a `mut`-receiver
+    /// method. The typechecker knows the receiver type; a call it could not
+    /// resolve writes back when any method of that name takes `mut`.
+    fn is_mut_receiver_call(&self, method: &str, call: &Span) -> bool {
+        if let Some(&is_mut) = self.method_receivers.get(&(call.start, call.end)) {
+            return is_mut;
+        }
+        self.symbols.cells.iter().any(|(name, ci)| {
+            ci.receiver == Some(Receiver::Mut)
+                && name.rsplit_once('.').map(|(_, m)| m) == Some(method)
+        })
+    }
+
+    /// The cell and arguments of `expr` when it is a direct call to a
+    /// user-defined cell, the only kind of call that can become a TailCall.
+    fn tail_call_target<'e>(&self, expr: &'e Expr) -> Option<(&'e str, &'e [CallArg])> {
+        let Expr::Call(callee, args, _) = expr else {
+            return None;
+        };
+        let Expr::Ident(name, _) = callee.as_ref() else {
+            return None;
+        };
+        let is_user_cell = self.symbols.cells.contains_key(name);
+        let is_tool = self.tool_indices.contains_key(name);
+        let is_type = self.symbols.types.contains_key(name);
+        let is_agent = self.symbols.agents.contains_key(name);
+        let is_process = self.symbols.processes.values().any(|p| p.name == *name);
+        let is_result = name == "ok" || name == "err";
+        (is_user_cell && !is_tool && !is_type && !is_agent && !is_process && !is_result)
+            .then_some((name.as_str(), args.as_slice()))
+    }
+
+    /// Record `note` for the cell being lowered, if a report was asked for.
+    fn note(&mut self, note: OptNote) {
+        if self.opt_report.is_some() {
+            self.opt_notes.push(note);
+        }
+    }
+
     /// Emit a tail call: sets up callee and args in consecutive registers,
     /// then emits TailCall instead of Call+Return.
     fn emit_tail_call_with_regs(
@@ -1738,6 +2153,8 @@ impl<'a> Lowerer<'a> {
                         } else {
                             vec![]
                         },
+                        default: f.default_value.as_ref().and_then(literal_constant),
+                        json: json_field(f),
                     }
                 })
                 .collect(),
@@ -1779,6 +2196,8 @@ impl<'a> Lowerer<'a> {
                         name: c.name.clone(),
                         ty: "String".to_string(),
                         constraints: vec![],
+                        default: None,
+                        json: None,
                     }
                 })
                 .collect(),
@@ -1831,6 +2250,8 @@ impl<'a> Lowerer<'a> {
                         name: (*name).to_string(),
                         ty: "String".to_string(),
                         constraints: vec![],
+                        default: None,
+                        json: None,
                     }
                 })
                 .collect(),
@@ -1960,6 +2381,83 @@ impl<'a> Lowerer<'a> {
         }
     }
 
+    /// Take `cell` from `store` under `key`, or lower it and store it.
+    ///
+    /// Instructions refer to the module string table by index, so a stored
+    /// cell is only reused when its strings land at the same indices again.
+    /// A cell that creates lambdas is always lowered: their indices depend
+    /// on every cell lowered before it.
+    fn lower_cell_cached(
+        &mut self,
+        cell: &CellDef,
+        key: &str,
+        store: &mut dyn CellStore,
+    ) -> LirCell {
+        if let Some(hit) = store.get(key) {
+            if self.reintern(&hit.strings) {
+                self.call_lines.push((cell.name.clone(), hit.call_lines));
+                self.alloc_lines.push((cell.name.clone(), hit.alloc_lines));
+                return hit.cell;
+            }
+        }
+        let lambdas = self.lambda_cells.len();
+        let outer = self.interned.replace(Vec::new());
+        let lowered = self.lower_cell(cell);
+        let interned = std::mem::replace(&mut self.interned, outer).unwrap_or_default();
+        if self.lambda_cells.len() == lambdas {
+            let strings = interned
+                .into_iter()
+                .map(|s| {
+                    let idx = self.strings.iter().position(|x| *x == s).unwrap_or(0) as u16;
+                    (s, idx)
+                })
+                .collect();
+            let lines_of = |cells: &[(String, Vec<usize>)]| {
+                cells
+                    .iter()
+                    .rev()
+                    .find(|(name, _)| *name == cell.name)
+                    .map(|(_, lines)| lines.clone())
+                    .unwrap_or_default()
+            };
+            let call_lines = lines_of(&self.call_lines);
+            let alloc_lines = lines_of(&self.alloc_lines);
+            store.put(
+                key,
+                &CompiledCell {
+                    cell: lowered.clone(),
+                    call_lines,
+                    alloc_lines,
+                    strings,
+                },
+            );
+        }
+        lowered
+    }
+
+    /// Intern `strings` if each lands at its recorded index; otherwise leave
+    /// the table as it is.
+    fn reintern(&mut self, strings: &[(String, u16)]) -> bool {
+        let mut added: Vec<&str> = Vec::new();
+        for (s, idx) in strings {
+            let at = match self.strings.iter().position(|x| x == s) {
+                Some(at) => at,
+                None => match added.iter().position(|x| *x == s.as_str()) {
+                    Some(at) => self.strings.len() + at,
+                    None => {
+                        added.push(s);
+                        self.strings.len() + added.len() - 1
+                    }
+                },
+            };
+            if at != *idx as usize {
+                return false;
+            }
+        }
+        self.strings.extend(added.into_iter().map(String::from));
+        true
+    }
+
     fn lower_cell(&mut self, cell: &CellDef) -> LirCell {
         self.intern_string(&cell.name);
         let mut ra = RegAlloc::new(&cell.name);
@@ -1970,6 +2468,8 @@ impl<'a> Lowerer<'a> {
         let saved_defers = std::mem::take(&mut self.defer_stack);
         // Save and reset effect handler metas for this cell scope
         let saved_metas = std::mem::take(&mut self.effect_handler_metas);
+        let saved_marks = std::mem::replace(&mut self.line_marks, vec![(0, cell.span.line)]);
+        let saved_notes = std::mem::take(&mut self.opt_notes);
 
         // Allocate param registers
         let params: Vec<LirParam> = cell
@@ -1991,9 +2491,17 @@ impl<'a> Lowerer<'a> {
         let body_len = cell.body.len();
         for (idx, stmt) in cell.body.iter().enumerate() {
             let is_last = idx == body_len - 1;
+            self.line_marks.push((instructions.len(), stmt.span().line));
             // Implicit return: if last statement is an expression and cell has a return type
             if is_last && has_return_type {
                 if let Stmt::Expr(es) = stmt {
+                    if self.tail_call_target(&es.expr).is_some() {
+                        self.note(OptNote::blocked(
+                            Optimization::TailCall,
+                            Some(es.span.line),
+                            "the call is an implicit return; write `return` in front of it",
+                        ));
+                    }
                     let val_reg =
                         self.lower_expr(&es.expr, &mut ra, &mut constants, &mut instructions);
                     // Emit accumulated defer blocks in LIFO order before return
@@ -2065,17 +2573,78 @@ impl<'a> Lowerer<'a> {
         self.defer_stack = saved_defers;
         let effect_handler_metas = std::mem::replace(&mut self.effect_handler_metas, saved_metas);
 
+        // A `mut` receiver travels back to the caller alongside the result
+        let mut returns = cell.return_type.as_ref().map(format_type_expr);
+        if cell.receiver == Some(Receiver::Mut) {
+            let tuple_reg = ra.alloc_block(3);
+            return_receiver_with_results(&mut instructions, params[0].register, tuple_reg);
+            returns = Some(format!(
+                "({}, {})",
+                returns.as_deref().unwrap_or("Null"),
+                params[0].ty
+            ));
+        }
+
+        // The passes below keep calls in order, so lines are matched to them now
+        let marks = std::mem::replace(&mut self.line_marks, saved_marks);
+        self.call_lines
+            .push((cell.name.clone(), call_lines(&instructions, &marks)));
+        self.alloc_lines
+            .push((cell.name.clone(), alloc_lines(&instructions, &marks)));
+
         // Peephole optimizations
-        hoist_loop_invariants(&mut instructions);
+        let hoisted = hoist_loop_invariants(&mut instructions);
         eliminate_redundant_moves(&mut instructions);
         optimize_move_own(&mut instructions);
         eliminate_redundant_bool_eq(&mut instructions);
         strip_nops(&mut instructions);
+        dedup_constants(&mut constants, &mut instructions);
+        let reused = gvn::eliminate_redundant_computations(
+            &mut instructions,
+            &overloaded_operators(self.symbols),
+        );
+
+        let mut notes = std::mem::replace(&mut self.opt_notes, saved_notes);
+        if let Some(report) = &mut self.opt_report {
+            if hoisted > 0 {
+                notes.push(OptNote::fired(
+                    Optimization::ConstantHoisting,
+                    None,
+                    format!("{} moved out of loops", constant_loads(hoisted)),
+                ));
+            }
+            if reused > 0 {
+                notes.push(OptNote::fired(
+                    Optimization::ValueNumbering,
+                    None,
+                    if reused == 1 {
+                        "1 repeated computation reuses an earlier result".to_string()
+                    } else {
+                        format!("{} repeated computations reuse earlier results", reused)
+                    },
+                ));
+            }
+            let kept = constant_loads_in_loops(&instructions);
+            if kept > 0 {
+                notes.push(OptNote::blocked(
+                    Optimization::ConstantHoisting,
+                    None,
+                    format!(
+                        "{} left in loops: the register is written again inside the loop",
+                        constant_loads(kept)
+                    ),
+                ));
+            }
+            report.cells.push(CellReport {
+                cell: cell.name.clone(),
+                notes,
+            });
+        }
 
         LirCell {
             name: cell.name.clone(),
             params,
-            returns: cell.return_type.as_ref().map(format_type_expr),
+            returns,
             registers: ra.max_regs(),
             constants,
             instructions,
@@ -2090,11 +2659,20 @@ impl<'a> Lowerer<'a> {
         consts: &mut Vec<Constant>,
         instrs: &mut Vec<Instruction>,
     ) {
+        self.line_marks.push((instrs.len(), stmt.span().line));
         match stmt {
             Stmt::Let(ls) => {
                 let val_reg = self.lower_expr(&ls.value, ra, consts, instrs);
                 if let Some(ref pattern) = ls.pattern {
                     self.lower_let_pattern(pattern, val_reg, ra, consts, instrs);
+                    // Drop the tuple a call returned once it is taken apart,
+                    // so the values bound from it are their only owners
+                    if matches!(ls.value, Expr::Call(..))
+                        && matches!(pattern, Pattern::TupleDestructure { .. })
+                        && !ra.is_bound(val_reg)
+                    {
+                        instrs.push(Instruction::abc(OpCode::LoadNil, val_reg, 0, 0));
+                    }
                 } else {
                     let dest = ra.alloc_named(&ls.name);
                     if dest != val_reg {
@@ -2124,9 +2702,7 @@ impl<'a> Lowerer<'a> {
                 let jmp_idx = instrs.len();
                 instrs.push(Instruction::sax(OpCode::Jmp, 0)); // Jump to else/end
 
-                for s in &ifs.then_body {
-                    self.lower_stmt(s, ra, consts, instrs);
-                }
+                self.lower_block(&ifs.then_body, ra, consts, instrs);
 
                 if let Some(ref else_body) = ifs.else_body {
                     let else_jmp_idx = instrs.len();
@@ -2136,9 +2712,7 @@ impl<'a> Lowerer<'a> {
                     let offset = (else_start - jmp_idx - 1) as i32;
                     instrs[jmp_idx] = Instruction::sax(OpCode::Jmp, offset);
 
-                    for s in else_body {
-                        self.lower_stmt(s, ra, consts, instrs);
-                    }
+                    self.lower_block(else_body, ra, consts, instrs);
 
                     let after_else = instrs.len();
                     let else_offset = (after_else - else_jmp_idx - 1) as i32;
@@ -2152,44 +2726,9 @@ impl<'a> Lowerer<'a> {
                 ra.free_statement_temps();
             }
             Stmt::For(fs) => {
-                let iter_reg = self.lower_expr(&fs.iter, ra, consts, instrs);
-                let idx_reg = ra.alloc_temp();
-                let len_reg = ra.alloc_temp();
-                let elem_reg = ra.alloc_named(&fs.var);
-
-                // idx = 0
-                let zero_idx = consts.len() as u16;
-                consts.push(Constant::Int(0));
-                instrs.push(Instruction::abx(OpCode::LoadK, idx_reg, zero_idx));
-                // len = length(iter)
-                instrs.push(Instruction::abc(
-                    OpCode::Intrinsic,
-                    len_reg,
-                    IntrinsicId::Length as u8,
-                    iter_reg,
-                ));
-
-                let loop_start = instrs.len();
-                // if idx >= len, break
-                // Lt evaluates to True if idx < len.
-                // We want to Continue (Skip Break) if idx < len.
-                // Break if Not (idx < len).
-                let lt_reg = ra.alloc_temp();
-                instrs.push(Instruction::abc(OpCode::Lt, lt_reg, idx_reg, len_reg));
-
-                // If lt_reg is True, Skip Next (Break).
-                // Test(lt, 0): Skip if True.
-                instrs.push(Instruction::abc(OpCode::Test, lt_reg, 0, 0));
-                let break_jmp = instrs.len();
-                instrs.push(Instruction::sax(OpCode::Jmp, 0)); // placeholder
-
-                // elem = iter[idx]
-                instrs.push(Instruction::abc(
-                    OpCode::GetIndex,
-                    elem_reg,
-                    iter_reg,
-                    idx_reg,
-                ));
+                // The loop variable and the body's bindings end with the loop
+                let outer = ra.save_bindings();
+                let head = self.lower_for_head(&fs.var, &fs.iter, ra, consts, instrs);
 
                 self.loop_stack.push(LoopContext {
                     label: fs.label.clone(),
@@ -2204,52 +2743,34 @@ impl<'a> Lowerer<'a> {
                     instrs.push(Instruction::abc(OpCode::LoadBool, true_reg, 1, 0));
                     let cmp_reg = ra.alloc_temp();
                     instrs.push(Instruction::abc(OpCode::Eq, cmp_reg, cond_reg, true_reg));
-                    // If condition is false, skip body (jump to increment)
+                    // If condition is false, skip body (jump to the back-edge)
                     instrs.push(Instruction::abc(OpCode::Test, cmp_reg, 0, 0));
                     let skip_jmp = instrs.len();
                     instrs.push(Instruction::sax(OpCode::Jmp, 0)); // placeholder
 
-                    for s in &fs.body {
-                        self.lower_stmt(s, ra, consts, instrs);
-                    }
+                    self.lower_stmts(&fs.body, ra, consts, instrs);
 
-                    // Patch skip jump to point past the body (to the increment)
+                    // Patch skip jump to point past the body (to the back-edge)
                     let body_end = instrs.len();
                     instrs[skip_jmp] =
                         Instruction::sax(OpCode::Jmp, (body_end - skip_jmp - 1) as i32);
                 } else {
-                    for s in &fs.body {
-                        self.lower_stmt(s, ra, consts, instrs);
-                    }
+                    self.lower_stmts(&fs.body, ra, consts, instrs);
                 }
 
-                // idx = idx + 1
-                // Continue jumps target this increment section (not loop_start)
-                let increment_start = instrs.len();
-                let one_idx = consts.len() as u16;
-                consts.push(Constant::Int(1));
-                let one_reg = ra.alloc_temp();
-                instrs.push(Instruction::abx(OpCode::LoadK, one_reg, one_idx));
-                instrs.push(Instruction::abc(OpCode::Add, idx_reg, idx_reg, one_reg));
-
-                // Jump back to loop start (negative offset)
-                let back_offset = loop_start as i32 - instrs.len() as i32 - 1;
-                instrs.push(Instruction::sax(OpCode::Jmp, back_offset));
-
-                // Patch break jump (forward offset, always positive)
-                let after_loop = instrs.len();
-                let break_offset = (after_loop - break_jmp - 1) as i32;
-                instrs[break_jmp] = Instruction::sax(OpCode::Jmp, break_offset);
+                // Advance, jump back to loop start, and patch the exit jump
+                let (advance_start, after_loop) = self.lower_for_close(&head, instrs);
 
                 // Patch any break jumps from the loop body
                 let ctx = self.loop_stack.pop().unwrap();
                 for bj in ctx.break_jumps {
                     instrs[bj] = Instruction::sax(OpCode::Jmp, (after_loop - bj - 1) as i32);
                 }
-                // Patch continue jumps to the increment section
+                // Patch continue jumps to the advance section
                 for cj in ctx.continue_jumps {
-                    instrs[cj] = Instruction::sax(OpCode::Jmp, (increment_start - cj - 1) as i32);
+                    instrs[cj] = Instruction::sax(OpCode::Jmp, (advance_start - cj - 1) as i32);
                 }
+                ra.restore_bindings(outer);
                 // After for loop completes, free any temps used for iteration
                 ra.free_statement_temps();
             }
@@ -2257,8 +2778,61 @@ impl<'a> Lowerer<'a> {
                 let subj_reg = self.lower_expr(&ms.subject, ra, consts, instrs);
                 let mut end_jumps = Vec::new();
 
+                // Dense integer cases (e.g. a `switch`) dispatch through a jump
+                // table straight to the arm bodies. Values the table does not
+                // cover fall through to the compare chain below. String cases
+                // dispatch on the subject's hash to the compare of the first
+                // arm that could match, skipping the arms before it.
+                let int_table = plan_int_jump_table(&ms.arms);
+                let string_table = if int_table.is_none() {
+                    plan_string_jump_table(&ms.arms)
+                } else {
+                    None
+                };
+                let to_compare = string_table.is_some();
+                let dispatch = match (int_table, string_table) {
+                    (Some((low, slots)), _) => Some((subj_reg, low, slots)),
+                    (None, Some(slots)) => {
+                        let block = ra.alloc_block(2);
+                        instrs.push(Instruction::abc(OpCode::Move, block, subj_reg, 0));
+                        let kidx = consts.len() as u16;
+                        consts.push(Constant::Int(slots.len() as i64));
+                        instrs.push(Instruction::abx(OpCode::LoadK, block + 1, kidx));
+                        let slot_reg = ra.alloc_temp();
+                        instrs.push(Instruction::abc(
+                            OpCode::Intrinsic,
+                            slot_reg,
+                            IntrinsicId::SwitchHash as u8,
+                            block,
+                        ));
+                        Some((slot_reg, 0, slots))
+                    }
+                    (None, None) => None,
+                };
+                let table = dispatch.map(|(index_reg, low, slots)| {
+                    let low_reg = ra.alloc_temp();
+                    let kidx = consts.len() as u16;
+                    consts.push(Constant::Int(low));
+                    instrs.push(Instruction::abx(OpCode::LoadK, low_reg, kidx));
+                    instrs.push(Instruction::abc(
+                        OpCode::JmpTable,
+                        index_reg,
+                        slots.len() as u8,
+                        low_reg,
+                    ));
+                    let first_entry = instrs.len();
+                    for _ in &slots {
+                        instrs.push(Instruction::sax(OpCode::Jmp, 0));
+                    }
+                    (first_entry, slots)
+                });
+                let mut compare_starts = Vec::with_capacity(ms.arms.len());
+                let mut body_starts = Vec::with_capacity(ms.arms.len());
+
                 for arm in &ms.arms {
+                    let outer = ra.save_bindings();
                     let mut fail_jumps = Vec::new();
+                    compare_starts.push(instrs.len());
                     self.lower_match_pattern(
                         &arm.pattern,
                         subj_reg,
@@ -2268,9 +2842,11 @@ impl<'a> Lowerer<'a> {
                         &mut fail_jumps,
                     );
 
+                    body_starts.push(instrs.len());
                     for s in &arm.body {
                         self.lower_stmt(s, ra, consts, instrs);
                     }
+                    ra.restore_bindings(outer);
                     end_jumps.push(instrs.len());
                     instrs.push(Instruction::sax(OpCode::Jmp, 0));
 
@@ -2284,6 +2860,18 @@ impl<'a> Lowerer<'a> {
                 for jmp_idx in end_jumps {
                     instrs[jmp_idx] = Instruction::sax(OpCode::Jmp, (end - jmp_idx - 1) as i32);
                 }
+                if let Some((first_entry, slots)) = table {
+                    let starts = if to_compare {
+                        &compare_starts
+                    } else {
+                        &body_starts
+                    };
+                    for (i, slot) in slots.iter().enumerate() {
+                        let entry = first_entry + i;
+                        let target = slot.map_or(end, |arm| starts[arm]);
+                        instrs[entry] = Instruction::sax(OpCode::Jmp, (target - entry - 1) as i32);
+                    }
+                }
                 // After match statement completes, free any temps used for subject/patterns
                 ra.free_statement_temps();
             }
@@ -2293,39 +2881,27 @@ impl<'a> Lowerer<'a> {
                 // instead of Call+Return.  Only applies to plain cell calls —
                 // intrinsics, tool calls, record/enum constructors are excluded
                 // because they lower to different opcodes.
-                if self.defer_stack.is_empty() {
-                    if let Expr::Call(ref callee, ref args, _) = rs.value {
-                        if let Expr::Ident(ref name, _) = **callee {
-                            let is_user_cell = self.symbols.cells.contains_key(name);
-                            let is_tool = self.tool_indices.contains_key(name);
-                            let is_type = self.symbols.types.contains_key(name);
-                            let is_agent = self.symbols.agents.contains_key(name);
-                            let is_process =
-                                self.symbols.processes.values().any(|p| p.name == *name);
-                            let is_result = name == "ok" || name == "err";
-
-                            if is_user_cell
-                                && !is_tool
-                                && !is_type
-                                && !is_agent
-                                && !is_process
-                                && !is_result
-                            {
-                                let callee_reg = ra.alloc_temp();
-                                let callee_idx = consts.len() as u16;
-                                consts.push(Constant::String(name.to_string()));
-                                instrs.push(Instruction::abx(
-                                    OpCode::LoadK,
-                                    callee_reg,
-                                    callee_idx,
-                                ));
-                                let arg_regs =
-                                    self.lower_call_arg_regs(args, None, ra, consts, instrs);
-                                self.emit_tail_call_with_regs(callee_reg, &arg_regs, ra, instrs);
-                                return;
-                            }
-                        }
+                if let Some((name, args)) = self.tail_call_target(&rs.value) {
+                    let line = Some(rs.span.line);
+                    if self.defer_stack.is_empty() {
+                        self.note(OptNote::fired(
+                            Optimization::TailCall,
+                            line,
+                            format!("`{}` runs in this frame", name),
+                        ));
+                        let callee_reg = ra.alloc_temp();
+                        let callee_idx = consts.len() as u16;
+                        consts.push(Constant::String(name.to_string()));
+                        instrs.push(Instruction::abx(OpCode::LoadK, callee_reg, callee_idx));
+                        let arg_regs = self.lower_call_arg_regs(args, None, ra, consts, instrs);
+                        self.emit_tail_call_with_regs(callee_reg, &arg_regs, ra, instrs);
+                        return;
                     }
+                    self.note(OptNote::blocked(
+                        Optimization::TailCall,
+                        line,
+                        "a defer block runs after the call returns",
+                    ));
                 }
                 let val_reg = self.lower_expr(&rs.value, ra, consts, instrs);
                 // Emit accumulated defer blocks in LIFO order before return
@@ -2337,6 +2913,13 @@ impl<'a> Lowerer<'a> {
                 instrs.push(Instruction::abc(OpCode::Halt, msg_reg, 0, 0));
             }
             Stmt::Assign(asgn) => {
+                // `_ = expr` evaluates expr and discards its value
+                if matches!(&asgn.target, AssignTarget::Variable(name) if name == "_") {
+                    let _ = self.lower_expr(&asgn.value, ra, consts, instrs);
+                    ra.free_statement_temps();
+                    return;
+                }
+
                 // ── Optimization: `x = append(x, elem)` → in-place OpCode::Append ──
                 // When the assignment target is a variable and the RHS is
                 // `append(same_var, elem)`, we can emit the dedicated Append
@@ -2384,7 +2967,15 @@ impl<'a> Lowerer<'a> {
                 }
 
                 let val_reg = self.lower_expr(&asgn.value, ra, consts, instrs);
-                match &asgn.target {
+                let field_target;
+                let target = match &asgn.target {
+                    AssignTarget::Variable(name) if name.contains('.') => {
+                        field_target = dotted_index_target(name, asgn.span);
+                        &field_target
+                    }
+                    target => target,
+                };
+                match target {
                     AssignTarget::Variable(name) => {
                         if let Some(dest) = ra.lookup(name) {
                             if dest != val_reg {
@@ -2398,7 +2989,8 @@ impl<'a> Lowerer<'a> {
                         }
                     }
                     AssignTarget::Index(base_expr, index_expr) => {
-                        let base_reg = self.lower_expr(base_expr, ra, consts, instrs);
+                        let (base_reg, write_backs) =
+                            self.lower_index_place(base_expr, ra, consts, instrs);
                         let idx_reg = self.lower_expr(index_expr, ra, consts, instrs);
                         instrs.push(Instruction::abc(
                             OpCode::SetIndex,
@@ -2406,6 +2998,7 @@ impl<'a> Lowerer<'a> {
                             idx_reg,
                             val_reg,
                         ));
+                        Self::emit_write_backs(&write_backs, instrs);
                     }
                     AssignTarget::Field(base_expr, field_name) => {
                         let base_reg = self.lower_expr(base_expr, ra, consts, instrs);
@@ -2426,6 +3019,14 @@ impl<'a> Lowerer<'a> {
                 ra.free_statement_temps();
             }
             Stmt::While(ws) => {
+                // A three-clause `for` binds its `let` for the loop only.
+                let scoped = match ws.init.as_deref() {
+                    Some(Stmt::Let(ls)) => Some((ls.name.clone(), ra.lookup(&ls.name))),
+                    _ => None,
+                };
+                if let Some(init) = &ws.init {
+                    self.lower_stmt(init, ra, consts, instrs);
+                }
                 let loop_start = instrs.len();
                 self.loop_stack.push(LoopContext {
                     label: ws.label.clone(),
@@ -2442,8 +3043,13 @@ impl<'a> Lowerer<'a> {
                 let cond_jmp = instrs.len();
                 instrs.push(Instruction::sax(OpCode::Jmp, 0));
 
-                for s in &ws.body {
-                    self.lower_stmt(s, ra, consts, instrs);
+                self.lower_block(&ws.body, ra, consts, instrs);
+
+                // `continue` lands on the post statement, or on the
+                // condition when there is none.
+                let continue_target = instrs.len();
+                if let Some(post) = &ws.post {
+                    self.lower_stmt(post, ra, consts, instrs);
                 }
 
                 let back_offset = loop_start as i32 - instrs.len() as i32 - 1;
@@ -2457,9 +3063,15 @@ impl<'a> Lowerer<'a> {
                 for bj in ctx.break_jumps {
                     instrs[bj] = Instruction::sax(OpCode::Jmp, (after - bj - 1) as i32);
                 }
-                // Patch continue jumps back to loop start (re-evaluate condition)
                 for cj in ctx.continue_jumps {
-                    instrs[cj] = Instruction::sax(OpCode::Jmp, loop_start as i32 - cj as i32 - 1);
+                    instrs[cj] =
+                        Instruction::sax(OpCode::Jmp, continue_target as i32 - cj as i32 - 1);
+                }
+                if let Some((name, prev)) = scoped {
+                    match prev {
+                        Some(reg) => ra.bind(&name, reg),
+                        None => ra.unbind(&name),
+                    }
                 }
                 // After while loop completes, free any temps used for condition
                 ra.free_statement_temps();
@@ -2472,9 +3084,7 @@ impl<'a> Lowerer<'a> {
                     continue_jumps: Vec::new(),
                 });
 
-                for s in &ls.body {
-                    self.lower_stmt(s, ra, consts, instrs);
-                }
+                self.lower_block(&ls.body, ra, consts, instrs);
                 let back_offset = loop_start as i32 - instrs.len() as i32 - 1;
                 instrs.push(Instruction::sax(OpCode::Jmp, back_offset));
 
@@ -2523,7 +3133,8 @@ impl<'a> Lowerer<'a> {
                 instrs.push(Instruction::abc(OpCode::Emit, val_reg, 0, 0));
             }
             Stmt::CompoundAssign(ca) => {
-                let val_reg = self.lower_expr(&ca.value, ra, consts, instrs);
+                // The target location is evaluated once, before the value,
+                // so `xs[next()] += f()` calls `next` exactly once.
                 let opcode = match ca.op {
                     CompoundOp::AddAssign => OpCode::Add,
                     CompoundOp::SubAssign => OpCode::Sub,
@@ -2536,18 +3147,29 @@ impl<'a> Lowerer<'a> {
                     CompoundOp::BitOrAssign => OpCode::BitOr,
                     CompoundOp::BitXorAssign => OpCode::BitXor,
                 };
-                match &ca.target {
+                let field_target;
+                let target = match &ca.target {
+                    AssignTarget::Variable(name) if name.contains('.') => {
+                        field_target = dotted_index_target(name, ca.span);
+                        &field_target
+                    }
+                    target => target,
+                };
+                match target {
                     AssignTarget::Variable(name) => {
                         let target_reg = if let Some(r) = ra.lookup(name) {
                             r
                         } else {
                             ra.alloc_named(name)
                         };
+                        let val_reg = self.lower_expr(&ca.value, ra, consts, instrs);
                         instrs.push(Instruction::abc(opcode, target_reg, target_reg, val_reg));
                     }
                     AssignTarget::Index(base_expr, index_expr) => {
-                        let base_reg = self.lower_expr(base_expr, ra, consts, instrs);
+                        let (base_reg, write_backs) =
+                            self.lower_index_place(base_expr, ra, consts, instrs);
                         let idx_reg = self.lower_expr(index_expr, ra, consts, instrs);
+                        let val_reg = self.lower_expr(&ca.value, ra, consts, instrs);
                         // Load current value, apply op, store back
                         let cur_reg = ra.alloc_temp();
                         instrs.push(Instruction::abc(
@@ -2563,10 +3185,12 @@ impl<'a> Lowerer<'a> {
                             idx_reg,
                             cur_reg,
                         ));
+                        Self::emit_write_backs(&write_backs, instrs);
                     }
                     AssignTarget::Field(base_expr, field_name) => {
                         let base_reg = self.lower_expr(base_expr, ra, consts, instrs);
                         let field_idx = self.intern_string(field_name);
+                        let val_reg = self.lower_expr(&ca.value, ra, consts, instrs);
                         // Load current value, apply op, store back
                         let cur_reg = ra.alloc_temp();
                         instrs.push(Instruction::abc(
@@ -2599,6 +3223,115 @@ impl<'a> Lowerer<'a> {
         }
     }
 
+    /// Lower the container an index store writes into. For a nested target
+    /// such as `m[i][j] = v` or `r.slots[i] = v` the row `m[i]` or the field
+    /// `r.slots` is loaded into a temporary and its slot in the container is
+    /// cleared, so the row is uniquely owned and the store updates it in
+    /// place instead of copying it. The returned write-backs
+    /// `(container, index, element)` put each level back, and must be emitted
+    /// after the store with `emit_write_backs`.
+    fn lower_index_place(
+        &mut self,
+        base_expr: &Expr,
+        ra: &mut RegAlloc,
+        consts: &mut Vec<Constant>,
+        instrs: &mut Vec<Instruction>,
+    ) -> (u8, Vec<(u8, u8, u8)>) {
+        let (outer_reg, mut write_backs, idx_reg) = match base_expr {
+            Expr::IndexAccess(outer, index, _) => {
+                let (outer_reg, write_backs) = self.lower_index_place(outer, ra, consts, instrs);
+                let idx_reg = self.lower_expr(index, ra, consts, instrs);
+                (outer_reg, write_backs, idx_reg)
+            }
+            // A field is read and written as an index by its name
+            Expr::DotAccess(outer, field, _) => {
+                let (outer_reg, write_backs) = self.lower_index_place(outer, ra, consts, instrs);
+                let key_reg = self.push_const_string(field, ra, consts, instrs);
+                (outer_reg, write_backs, key_reg)
+            }
+            _ => return (self.lower_expr(base_expr, ra, consts, instrs), Vec::new()),
+        };
+        let elem_reg = ra.alloc_temp();
+        instrs.push(Instruction::abc(
+            OpCode::GetIndex,
+            elem_reg,
+            outer_reg,
+            idx_reg,
+        ));
+        let nil_reg = ra.alloc_temp();
+        instrs.push(Instruction::abc(OpCode::LoadNil, nil_reg, 0, 0));
+        instrs.push(Instruction::abc(
+            OpCode::SetIndex,
+            outer_reg,
+            idx_reg,
+            nil_reg,
+        ));
+        write_backs.push((outer_reg, idx_reg, elem_reg));
+        (elem_reg, write_backs)
+    }
+
+    /// Store each level of a nested index place back into its container,
+    /// innermost first.
+    fn emit_write_backs(write_backs: &[(u8, u8, u8)], instrs: &mut Vec<Instruction>) {
+        for &(container, idx, elem) in write_backs.iter().rev() {
+            instrs.push(Instruction::abc(OpCode::SetIndex, container, idx, elem));
+        }
+    }
+
+    /// Lower a nested block. A `let` inside it may shadow an outer name; the
+    /// outer binding is visible again once the block ends.
+    fn lower_block(
+        &mut self,
+        body: &[Stmt],
+        ra: &mut RegAlloc,
+        consts: &mut Vec<Constant>,
+        instrs: &mut Vec<Instruction>,
+    ) {
+        let outer = ra.save_bindings();
+        self.lower_stmts(body, ra, consts, instrs);
+        ra.restore_bindings(outer);
+    }
+
+    /// Lower the statements of a block. `x = y`, where `y` is bound by a
+    /// `let` of the block and nothing after it in the block reads `y`, moves
+    /// the value out of `y` rather than sharing it. That keeps `x` the only
+    /// owner in the loop idiom `let (next, ok) = push(r, v)` then `r = next`,
+    /// so the next store into `r` updates it in place.
+    fn lower_stmts(
+        &mut self,
+        body: &[Stmt],
+        ra: &mut RegAlloc,
+        consts: &mut Vec<Constant>,
+        instrs: &mut Vec<Instruction>,
+    ) {
+        let mut moves = Vec::new();
+        for (i, s) in body.iter().enumerate() {
+            let start = instrs.len();
+            self.lower_stmt(s, ra, consts, instrs);
+            let Some(src) = moved_binding(s, &body[..i], ra) else {
+                continue;
+            };
+            // The statement's own copy, if it made one
+            if instrs.len() > start {
+                let pc = instrs.len() - 1;
+                if instrs[pc].op == OpCode::Move && instrs[pc].b == src {
+                    moves.push(pc);
+                }
+            }
+        }
+        // Code after the block cannot name `y`, and its register is never
+        // handed out again
+        for pc in moves {
+            let src = instrs[pc].b;
+            if !instrs[pc + 1..]
+                .iter()
+                .any(|inst| instr_may_read_reg(inst, src))
+            {
+                instrs[pc].op = OpCode::MoveOwn;
+            }
+        }
+    }
+
     /// Lower an if/else statement as a tail expression, storing the result
     /// of whichever branch is taken into `result_reg`.
     fn lower_if_as_tail(
@@ -2711,41 +3444,11 @@ impl<'a> Lowerer<'a> {
         consts: &mut Vec<Constant>,
         instrs: &mut Vec<Instruction>,
     ) {
-        let iter_reg = self.lower_expr(&fs.iter, ra, consts, instrs);
-        let idx_reg = ra.alloc_temp();
-        let len_reg = ra.alloc_temp();
-        let elem_reg = ra.alloc_named(&fs.var);
-
-        // idx = 0
-        let zero_idx = consts.len() as u16;
-        consts.push(Constant::Int(0));
-        instrs.push(Instruction::abx(OpCode::LoadK, idx_reg, zero_idx));
-        // len = length(iter)
-        instrs.push(Instruction::abc(
-            OpCode::Intrinsic,
-            len_reg,
-            IntrinsicId::Length as u8,
-            iter_reg,
-        ));
-
-        let loop_start = instrs.len();
-        let lt_reg = ra.alloc_temp();
-        instrs.push(Instruction::abc(OpCode::Lt, lt_reg, idx_reg, len_reg));
-        instrs.push(Instruction::abc(OpCode::Test, lt_reg, 0, 0));
-        let break_jmp = instrs.len();
-        instrs.push(Instruction::sax(OpCode::Jmp, 0)); // placeholder
-
-        // elem = iter[idx]
-        instrs.push(Instruction::abc(
-            OpCode::GetIndex,
-            elem_reg,
-            iter_reg,
-            idx_reg,
-        ));
+        let head = self.lower_for_head(&fs.var, &fs.iter, ra, consts, instrs);
 
         // Handle tuple destructuring pattern if present
         if let Some(ref pattern) = fs.pattern {
-            self.lower_let_pattern(pattern, elem_reg, ra, consts, instrs);
+            self.lower_let_pattern(pattern, head.elem_reg, ra, consts, instrs);
         }
 
         self.loop_stack.push(LoopContext {
@@ -2792,30 +3495,158 @@ impl<'a> Lowerer<'a> {
             instrs[fj] = Instruction::sax(OpCode::Jmp, (body_end - fj - 1) as i32);
         }
 
-        // idx = idx + 1
-        let increment_start = instrs.len();
-        let one_idx = consts.len() as u16;
-        consts.push(Constant::Int(1));
-        let one_reg = ra.alloc_temp();
-        instrs.push(Instruction::abx(OpCode::LoadK, one_reg, one_idx));
-        instrs.push(Instruction::abc(OpCode::Add, idx_reg, idx_reg, one_reg));
-
-        // Jump back to loop start
-        let back_offset = loop_start as i32 - instrs.len() as i32 - 1;
-        instrs.push(Instruction::sax(OpCode::Jmp, back_offset));
-
-        // Patch break
-        let after_loop = instrs.len();
-        let break_offset = (after_loop - break_jmp - 1) as i32;
-        instrs[break_jmp] = Instruction::sax(OpCode::Jmp, break_offset);
+        let (advance_start, after_loop) = self.lower_for_close(&head, instrs);
 
         let ctx = self.loop_stack.pop().unwrap();
         for bj in ctx.break_jumps {
             instrs[bj] = Instruction::sax(OpCode::Jmp, (after_loop - bj - 1) as i32);
         }
         for cj in ctx.continue_jumps {
-            instrs[cj] = Instruction::sax(OpCode::Jmp, (increment_start - cj - 1) as i32);
+            instrs[cj] = Instruction::sax(OpCode::Jmp, (advance_start - cj - 1) as i32);
+        }
+    }
+
+    /// Emit the head of a `for` loop.
+    ///
+    /// A literal `start..end` range (optionally with `step`) becomes a counted
+    /// loop: `RangeCount` computes the number of values once, and the loop
+    /// steps an Int register without allocating a list.
+    ///
+    /// Any other iterable is copied into a `[iter, cursor, more]` register
+    /// block, and each pass through the loop start runs `IterNext`, which
+    /// stores the next element in the loop variable and clears `more` once
+    /// the iterator is exhausted. Collections advance an index cursor; records
+    /// whose type defines `next` are advanced by calling it.
+    fn lower_for_head(
+        &mut self,
+        var: &str,
+        iter: &Expr,
+        ra: &mut RegAlloc,
+        consts: &mut Vec<Constant>,
+        instrs: &mut Vec<Instruction>,
+    ) -> ForHead {
+        if let Expr::RangeExpr {
+            start: Some(start),
+            end: Some(end),
+            inclusive,
+            step,
+            ..
+        } = iter
+        {
+            let start_src = self.lower_expr(start, ra, consts, instrs);
+            let end_src = self.lower_expr(end, ra, consts, instrs);
+            let step_src = step
+                .as_ref()
+                .map(|st| self.lower_expr(st, ra, consts, instrs));
+
+            let block = ra.alloc_block(7);
+            let (value_reg, end_reg, step_reg, inclusive_reg) =
+                (block, block + 1, block + 2, block + 3);
+            let (index_reg, count_reg, one_reg) = (block + 4, block + 5, block + 6);
+            let elem_reg = ra.alloc_named(var);
+
+            instrs.push(Instruction::abc(OpCode::Move, value_reg, start_src, 0));
+            instrs.push(Instruction::abc(OpCode::Move, end_reg, end_src, 0));
+            let one_idx = consts.len() as u16;
+            consts.push(Constant::Int(1));
+            match step_src {
+                Some(sr) => instrs.push(Instruction::abc(OpCode::Move, step_reg, sr, 0)),
+                None => instrs.push(Instruction::abx(OpCode::LoadK, step_reg, one_idx)),
+            }
+            instrs.push(Instruction::abc(
+                OpCode::LoadBool,
+                inclusive_reg,
+                *inclusive as u8,
+                0,
+            ));
+            let zero_idx = consts.len() as u16;
+            consts.push(Constant::Int(0));
+            instrs.push(Instruction::abx(OpCode::LoadK, index_reg, zero_idx));
+            instrs.push(Instruction::abx(OpCode::LoadK, one_reg, one_idx));
+            // count = number of values in the range (errors on a zero step)
+            instrs.push(Instruction::abc(
+                OpCode::Intrinsic,
+                count_reg,
+                IntrinsicId::RangeCount as u8,
+                block,
+            ));
+
+            let loop_start = instrs.len();
+            // Test(index < count, 0): skip the exit jump while values remain
+            let lt_reg = ra.alloc_temp();
+            instrs.push(Instruction::abc(OpCode::Lt, lt_reg, index_reg, count_reg));
+            instrs.push(Instruction::abc(OpCode::Test, lt_reg, 0, 0));
+            let exit_jmp = instrs.len();
+            instrs.push(Instruction::sax(OpCode::Jmp, 0)); // placeholder
+            instrs.push(Instruction::abc(OpCode::Move, elem_reg, value_reg, 0));
+
+            return ForHead {
+                loop_start,
+                exit_jmp,
+                elem_reg,
+                counter: Some(block),
+            };
+        }
+
+        let src_reg = self.lower_expr(iter, ra, consts, instrs);
+        let iter_reg = ra.alloc_block(3);
+        let cursor_reg = iter_reg + 1;
+        let more_reg = iter_reg + 2;
+        let elem_reg = ra.alloc_named(var);
+
+        // Copy the iterable so advancing a user iterator never rebinds the source
+        instrs.push(Instruction::abc(OpCode::Move, iter_reg, src_reg, 0));
+        // cursor = 0
+        let zero_idx = consts.len() as u16;
+        consts.push(Constant::Int(0));
+        instrs.push(Instruction::abx(OpCode::LoadK, cursor_reg, zero_idx));
+
+        let loop_start = instrs.len();
+        // elem = next(iter); more = whether an element was produced
+        instrs.push(Instruction::abc(
+            OpCode::Intrinsic,
+            elem_reg,
+            IntrinsicId::IterNext as u8,
+            iter_reg,
+        ));
+        // Test(more, 0): skip the exit jump while elements remain
+        instrs.push(Instruction::abc(OpCode::Test, more_reg, 0, 0));
+        let exit_jmp = instrs.len();
+        instrs.push(Instruction::sax(OpCode::Jmp, 0)); // placeholder
+
+        ForHead {
+            loop_start,
+            exit_jmp,
+            elem_reg,
+            counter: None,
+        }
+    }
+
+    /// Close a loop opened by `lower_for_head`: emit the advance section and
+    /// the back-edge, then patch the exit jump. Returns the start of the
+    /// advance section (the `continue` target) and the first instruction
+    /// after the loop.
+    fn lower_for_close(&self, head: &ForHead, instrs: &mut Vec<Instruction>) -> (usize, usize) {
+        let advance_start = instrs.len();
+        if let Some(block) = head.counter {
+            // value += step; index += 1
+            instrs.push(Instruction::abc(OpCode::Add, block, block, block + 2));
+            instrs.push(Instruction::abc(
+                OpCode::Add,
+                block + 4,
+                block + 4,
+                block + 6,
+            ));
         }
+
+        // Jump back to loop start (negative offset)
+        let back_offset = head.loop_start as i32 - instrs.len() as i32 - 1;
+        instrs.push(Instruction::sax(OpCode::Jmp, back_offset));
+
+        let after_loop = instrs.len();
+        instrs[head.exit_jmp] =
+            Instruction::sax(OpCode::Jmp, (after_loop - head.exit_jmp - 1) as i32);
+        (advance_start, after_loop)
     }
 
     /// Emit all accumulated defer blocks in LIFO order (last defer first).
@@ -2997,7 +3828,7 @@ impl<'a> Lowerer<'a> {
                         }
                     }
                 }
-                if let Some(rest_name) = rest {
+                if let Some(rest_name) = rest.as_ref().filter(|r| *r != "_") {
                     // rest = list.drop(elements.len())
                     let list_arg = ra.alloc_temp();
                     instrs.push(Instruction::abc(OpCode::Move, list_arg, value_reg, 0));
@@ -3164,7 +3995,7 @@ impl<'a> Lowerer<'a> {
                     self.lower_match_pattern(elem_pat, elem_reg, ra, consts, instrs, fail_jumps);
                 }
 
-                if let Some(rest_name) = rest {
+                if let Some(rest_name) = rest.as_ref().filter(|r| *r != "_") {
                     let list_arg = ra.alloc_temp();
                     instrs.push(Instruction::abc(OpCode::Move, list_arg, value_reg, 0));
                     let n_kidx = consts.len() as u16;
@@ -3419,6 +4250,10 @@ impl<'a> Lowerer<'a> {
             Expr::Ident(name, _) => {
                 if let Some(reg) = ra.lookup(name) {
                     reg
+                } else if let Some(val) = self.const_values.get(name) {
+                    let dest = ra.alloc_temp();
+                    emit_const_value(val.clone(), dest, consts, instrs);
+                    dest
                 } else if let Some(const_info) = self.symbols.consts.get(name) {
                     if let Some(ref value_expr) = const_info.value {
                         self.lower_expr(value_expr, ra, consts, instrs)
@@ -3551,6 +4386,22 @@ impl<'a> Lowerer<'a> {
                 };
                 dest
             }
+            Expr::ListRepeat(value, count, _) => {
+                // ListRepeat reads [value, count]; the value is evaluated once
+                let value_reg = self.lower_expr(value, ra, consts, instrs);
+                let count_reg = self.lower_expr(count, ra, consts, instrs);
+                let dest = ra.alloc_temp();
+                let arg_block = ra.alloc_block(2);
+                instrs.push(Instruction::abc(OpCode::Move, arg_block, value_reg, 0));
+                instrs.push(Instruction::abc(OpCode::Move, arg_block + 1, count_reg, 0));
+                instrs.push(Instruction::abc(
+                    OpCode::Intrinsic,
+                    dest,
+                    IntrinsicId::ListRepeat as u8,
+                    arg_block,
+                ));
+                dest
+            }
             Expr::MapLit(pairs, _) => {
                 let block_start = ra.alloc_block(1 + (pairs.len() * 2) as u8);
                 let dest = block_start;
@@ -3732,6 +4583,26 @@ impl<'a> Lowerer<'a> {
                     return dest;
                 }
 
+                // `and` / `or` short-circuit: the right operand is only
+                // evaluated when the left one does not decide the result.
+                if matches!(op, BinOp::And | BinOp::Or) {
+                    let is_and = *op == BinOp::And;
+                    let lr = self.lower_expr(lhs, ra, consts, instrs);
+                    let dest = ra.alloc_temp();
+                    instrs.push(Instruction::abc(OpCode::LoadBool, dest, !is_and as u8, 0));
+                    // Test skips the jump when the left operand is truthy (and)
+                    // or falsy (or), i.e. when the right operand matters.
+                    instrs.push(Instruction::abc(OpCode::Test, lr, 0, !is_and as u8));
+                    let skip_jmp = instrs.len();
+                    instrs.push(Instruction::sax(OpCode::Jmp, 0));
+                    let rr = self.lower_expr(rhs, ra, consts, instrs);
+                    let opcode = if is_and { OpCode::And } else { OpCode::Or };
+                    instrs.push(Instruction::abc(opcode, dest, lr, rr));
+                    let end = instrs.len();
+                    instrs[skip_jmp] = Instruction::sax(OpCode::Jmp, (end - skip_jmp - 1) as i32);
+                    return dest;
+                }
+
                 let lr = self.lower_expr(lhs, ra, consts, instrs);
                 let rr = self.lower_expr(rhs, ra, consts, instrs);
                 let dest = ra.alloc_temp();
@@ -3748,8 +4619,7 @@ impl<'a> Lowerer<'a> {
                     BinOp::LtEq => OpCode::Le,
                     BinOp::Gt => OpCode::Lt,   // swap operands
                     BinOp::GtEq => OpCode::Le, // swap operands
-                    BinOp::And => OpCode::And,
-                    BinOp::Or => OpCode::Or,
+                    BinOp::And | BinOp::Or => unreachable!(), // handled above
                     BinOp::Pow => OpCode::Pow,
                     BinOp::Concat => OpCode::Concat,
                     BinOp::In => OpCode::In,
@@ -3783,7 +4653,7 @@ impl<'a> Lowerer<'a> {
                 dest
             }
 
-            Expr::Call(callee, args, _) => {
+            Expr::Call(callee, args, call_span) => {
                 if let Some(effect_path) = effect_operation_name(callee.as_ref()) {
                     if let Some(handler_cell) = self.effect_handler_cells.get(&effect_path).cloned()
                     {
@@ -3812,6 +4682,46 @@ impl<'a> Lowerer<'a> {
                         return self.lower_tool_call(Some(name.as_str()), args, ra, consts, instrs);
                     }
 
+                    // `sizeof`/`alignof` fold to the record's native layout
+                    if (name == "sizeof" || name == "alignof")
+                        && !self.symbols.cells.contains_key(name)
+                    {
+                        if let [CallArg::Positional(Expr::Ident(ty, _))] = args.as_slice() {
+                            if let Some((size, align)) = type_size_align(ty, self.symbols)
+                                .filter(|_| ra.lookup(ty).is_none())
+                            {
+                                let value = if name == "sizeof" { size } else { align };
+                                let dest = ra.alloc_temp();
+                                let idx = consts.len() as u16;
+                                consts.push(Constant::Int(value as i64));
+                                instrs.push(Instruction::abx(OpCode::LoadK, dest, idx));
+                                return dest;
+                            }
+                        }
+                    }
+
+                    // `todo`/`unimplemented` trap when reached; the call's line is
+                    // passed first so the panic message can point at it
+                    if (name == "todo" || name == "unimplemented")
+                        && !self.symbols.cells.contains_key(name)
+                    {
+                        let line_reg = ra.alloc_temp();
+                        let line_idx = consts.len() as u16;
+                        consts.push(Constant::Int(call_span.line as i64));
+                        instrs.push(Instruction::abx(OpCode::LoadK, line_reg, line_idx));
+                        let mut arg_regs = vec![line_reg];
+                        for arg in args {
+                            if let CallArg::Positional(e) | CallArg::Named(_, e, _) = arg {
+                                arg_regs.push(self.lower_expr(e, ra, consts, instrs));
+                            }
+                        }
+                        let callee_reg = ra.alloc_temp();
+                        let callee_idx = consts.len() as u16;
+                        consts.push(Constant::String(name.clone()));
+                        instrs.push(Instruction::abx(OpCode::LoadK, callee_reg, callee_idx));
+                        return self.emit_call_with_regs(callee_reg, &arg_regs, ra, instrs);
+                    }
+
                     let is_agent_ctor = self.symbols.agents.contains_key(name);
                     let is_process_ctor = self.symbols.processes.values().any(|p| p.name == *name);
                     // Check Result/Enum constructors
@@ -3822,11 +4732,23 @@ impl<'a> Lowerer<'a> {
 
                     if is_record && !is_agent_ctor && !is_process_ctor {
                         let dest = ra.alloc_temp();
-                        let type_idx = self.intern_string(name);
-                        instrs.push(Instruction::abx(OpCode::NewRecord, dest, type_idx));
+                        // `Name(..source, field: v)` starts from a copy of the
+                        // source record and overwrites the named fields
+                        let spread_src = args.iter().find_map(|arg| match arg {
+                            CallArg::Positional(Expr::SpreadExpr(inner, _)) => Some(inner),
+                            _ => None,
+                        });
+                        if let Some(src) = spread_src {
+                            let src_reg = self.lower_expr(src, ra, consts, instrs);
+                            instrs.push(Instruction::abc(OpCode::Move, dest, src_reg, 0));
+                        } else {
+                            let type_idx = self.intern_string(name);
+                            instrs.push(Instruction::abx(OpCode::NewRecord, dest, type_idx));
+                        }
 
                         for arg in args {
                             match arg {
+                                CallArg::Positional(Expr::SpreadExpr(_, _)) => {}
                                 CallArg::Named(field, expr, _) => {
                                     let val_reg = self.lower_expr(expr, ra, consts, instrs);
 
@@ -4161,7 +5083,16 @@ impl<'a> Lowerer<'a> {
                                     }
                                 }
                             }
-                            return self.emit_call_with_regs(dest, &arg_regs, ra, instrs);
+                            let result = self.emit_call_with_regs(dest, &arg_regs, ra, instrs);
+                            if !self.is_mut_receiver_call(field, call_span) {
+                                return result;
+                            }
+                            // The method returned (result, receiver): store the
+                            // receiver back into the caller's variable.
+                            let value = ra.alloc_temp();
+                            instrs.push(Instruction::abc(OpCode::GetTuple, value, result, 0));
+                            instrs.push(Instruction::abc(OpCode::GetTuple, obj_reg, result, 1));
+                            return value;
                         } else if let Some(id) = get_intrinsic_id(field) {
                             // Intrinsic method call on non-local: list.len()
                             let obj_reg = self.lower_expr(obj, ra, consts, instrs);
@@ -4314,6 +5245,12 @@ impl<'a> Lowerer<'a> {
                 let ir = self.lower_expr(idx, ra, consts, instrs);
                 let dest = ra.alloc_temp();
                 instrs.push(Instruction::abc(OpCode::GetIndex, dest, or, ir));
+                // A field loaded only to be indexed is dropped straight away,
+                // so a later store into `r.slots[i]` finds the record the
+                // field's only owner and updates it in place
+                if matches!(obj.as_ref(), Expr::DotAccess(..)) {
+                    instrs.push(Instruction::abc(OpCode::LoadNil, or, 0, 0));
+                }
                 dest
             }
             Expr::RoleBlock(name, content, _) => {
@@ -4361,7 +5298,7 @@ impl<'a> Lowerer<'a> {
                 params,
                 return_type,
                 body,
-                ..
+                span: lambda_span,
             } => {
                 // Collect identifiers referenced in the lambda body
                 let mut referenced = Vec::new();
@@ -4430,6 +5367,11 @@ impl<'a> Lowerer<'a> {
 
                 // Save and reset defer stack for lambda scope
                 let saved_defers = std::mem::take(&mut self.defer_stack);
+                let saved_marks = std::mem::replace(
+                    &mut self.line_marks,
+                    vec![(linstrs.len(), lambda_span.line)],
+                );
+                let saved_notes = std::mem::take(&mut self.opt_notes);
 
                 match body {
                     LambdaBody::Expr(e) => {
@@ -4457,6 +5399,18 @@ impl<'a> Lowerer<'a> {
 
                 // Restore defer stack
                 self.defer_stack = saved_defers;
+                let marks = std::mem::replace(&mut self.line_marks, saved_marks);
+                self.call_lines
+                    .push((lambda_name.clone(), call_lines(&linstrs, &marks)));
+                self.alloc_lines
+                    .push((lambda_name.clone(), alloc_lines(&linstrs, &marks)));
+                let notes = std::mem::replace(&mut self.opt_notes, saved_notes);
+                if let Some(report) = &mut self.opt_report {
+                    report.cells.push(CellReport {
+                        cell: lambda_name.clone(),
+                        notes,
+                    });
+                }
 
                 let proto_idx = self.lambda_cells.len() as u16;
                 self.lambda_cells.push(LirCell {
@@ -4525,6 +5479,47 @@ impl<'a> Lowerer<'a> {
                 instrs.push(Instruction::abc(OpCode::NewSet, dest, elems.len() as u8, 0));
                 dest
             }
+            Expr::RangeExpr {
+                start,
+                end,
+                inclusive,
+                step: Some(step),
+                ..
+            } => {
+                // Stepped range: RangeStep reads [start, end, step, inclusive]
+                let bounds: Vec<u8> = [start, end]
+                    .into_iter()
+                    .map(|bound| match bound {
+                        Some(b) => self.lower_expr(b, ra, consts, instrs),
+                        None => {
+                            let r = ra.alloc_temp();
+                            let kidx = consts.len() as u16;
+                            consts.push(Constant::Int(0));
+                            instrs.push(Instruction::abx(OpCode::LoadK, r, kidx));
+                            r
+                        }
+                    })
+                    .collect();
+                let step_src = self.lower_expr(step, ra, consts, instrs);
+                let dest = ra.alloc_temp();
+                let arg_block = ra.alloc_block(4);
+                instrs.push(Instruction::abc(OpCode::Move, arg_block, bounds[0], 0));
+                instrs.push(Instruction::abc(OpCode::Move, arg_block + 1, bounds[1], 0));
+                instrs.push(Instruction::abc(OpCode::Move, arg_block + 2, step_src, 0));
+                instrs.push(Instruction::abc(
+                    OpCode::LoadBool,
+                    arg_block + 3,
+                    *inclusive as u8,
+                    0,
+                ));
+                instrs.push(Instruction::abc(
+                    OpCode::Intrinsic,
+                    dest,
+                    IntrinsicId::RangeStep as u8,
+                    arg_block,
+                ));
+                dest
+            }
             Expr::RangeExpr {
                 start,
                 end,
@@ -4935,40 +5930,16 @@ impl<'a> Lowerer<'a> {
                     });
                 }
 
-                // For each clause, emit: lower iter, init idx, compute len, loop header
-                // Track (loop_start, break_jmp, idx_reg) for each clause to patch later
-                let mut loop_info: Vec<(usize, usize, u8)> = Vec::new();
+                // For each clause, emit the loop header; close them after the body
+                let mut loop_heads: Vec<ForHead> = Vec::new();
                 for clause in &all_clauses {
-                    let iter_reg = self.lower_expr(clause.iter, ra, consts, instrs);
-                    let idx_reg = ra.alloc_temp();
-                    let len_reg = ra.alloc_temp();
-                    let elem_reg = ra.alloc_named(clause.var);
-
-                    let zero_idx = consts.len() as u16;
-                    consts.push(Constant::Int(0));
-                    instrs.push(Instruction::abx(OpCode::LoadK, idx_reg, zero_idx));
-                    instrs.push(Instruction::abc(
-                        OpCode::Intrinsic,
-                        len_reg,
-                        IntrinsicId::Length as u8,
-                        iter_reg,
-                    ));
-
-                    let loop_start = instrs.len();
-                    let lt_reg = ra.alloc_temp();
-                    instrs.push(Instruction::abc(OpCode::Lt, lt_reg, idx_reg, len_reg));
-                    instrs.push(Instruction::abc(OpCode::Test, lt_reg, 0, 0));
-                    let break_jmp = instrs.len();
-                    instrs.push(Instruction::sax(OpCode::Jmp, 0));
-
-                    instrs.push(Instruction::abc(
-                        OpCode::GetIndex,
-                        elem_reg,
-                        iter_reg,
-                        idx_reg,
+                    loop_heads.push(self.lower_for_head(
+                        clause.var,
+                        clause.iter,
+                        ra,
+                        consts,
+                        instrs,
                     ));
-
-                    loop_info.push((loop_start, break_jmp, idx_reg));
                 }
 
                 // Optional condition
@@ -5027,19 +5998,8 @@ impl<'a> Lowerer<'a> {
                 }
 
                 // Close all loops in reverse order (innermost first)
-                for &(loop_start, break_jmp, idx_reg) in loop_info.iter().rev() {
-                    let one_idx = consts.len() as u16;
-                    consts.push(Constant::Int(1));
-                    let one_reg = ra.alloc_temp();
-                    instrs.push(Instruction::abx(OpCode::LoadK, one_reg, one_idx));
-                    instrs.push(Instruction::abc(OpCode::Add, idx_reg, idx_reg, one_reg));
-
-                    let back_offset = loop_start as i32 - instrs.len() as i32 - 1;
-                    instrs.push(Instruction::sax(OpCode::Jmp, back_offset));
-
-                    let after_loop = instrs.len();
-                    instrs[break_jmp] =
-                        Instruction::sax(OpCode::Jmp, (after_loop - break_jmp - 1) as i32);
+                for head in loop_heads.iter().rev() {
+                    self.lower_for_close(head, instrs);
                 }
 
                 // For set comprehensions, convert the list to a set using ToSet intrinsic
@@ -5230,39 +6190,7 @@ impl<'a> Lowerer<'a> {
             Expr::ComptimeExpr(inner, span) => {
                 if let Some(val) = try_const_eval(inner) {
                     let dest = ra.alloc_temp();
-                    match val {
-                        ConstValue::Int(n) => {
-                            let kidx = consts.len() as u16;
-                            consts.push(Constant::Int(n));
-                            instrs.push(Instruction::abx(OpCode::LoadK, dest, kidx));
-                        }
-                        ConstValue::BigInt(n) => {
-                            let kidx = consts.len() as u16;
-                            consts.push(Constant::BigInt(n));
-                            instrs.push(Instruction::abx(OpCode::LoadK, dest, kidx));
-                        }
-                        ConstValue::Float(f) => {
-                            let kidx = consts.len() as u16;
-                            consts.push(Constant::Float(f));
-                            instrs.push(Instruction::abx(OpCode::LoadK, dest, kidx));
-                        }
-                        ConstValue::String(s) => {
-                            let kidx = consts.len() as u16;
-                            consts.push(Constant::String(s));
-                            instrs.push(Instruction::abx(OpCode::LoadK, dest, kidx));
-                        }
-                        ConstValue::Bool(b) => {
-                            instrs.push(Instruction::abc(
-                                OpCode::LoadBool,
-                                dest,
-                                if b { 1 } else { 0 },
-                                0,
-                            ));
-                        }
-                        ConstValue::Null => {
-                            instrs.push(Instruction::abc(OpCode::LoadNil, dest, 0, 0));
-                        }
-                    }
+                    emit_const_value(val, dest, consts, instrs);
                     dest
                 } else {
                     eprintln!(
@@ -5536,7 +6464,7 @@ fn collect_free_idents_expr(expr: &Expr, out: &mut Vec<String>) {
             collect_free_idents_expr(then_val, out);
             collect_free_idents_expr(else_val, out);
         }
-        Expr::NullCoalesce(l, r, _) => {
+        Expr::NullCoalesce(l, r, _) | Expr::ListRepeat(l, r, _) => {
             collect_free_idents_expr(l, out);
             collect_free_idents_expr(r, out);
         }
@@ -5564,13 +6492,18 @@ fn collect_free_idents_expr(expr: &Expr, out: &mut Vec<String>) {
             collect_free_idents_expr(inner, out);
         }
         Expr::RoleBlock(_, content, _) => collect_free_idents_expr(content, out),
-        Expr::RangeExpr { start, end, .. } => {
+        Expr::RangeExpr {
+            start, end, step, ..
+        } => {
             if let Some(s) = start {
                 collect_free_idents_expr(s, out);
             }
             if let Some(e) = end {
                 collect_free_idents_expr(e, out);
             }
+            if let Some(st) = step {
+                collect_free_idents_expr(st, out);
+            }
         }
         Expr::Comprehension {
             body,
@@ -5666,7 +6599,7 @@ fn collect_free_idents_stmt(stmt: &Stmt, out: &mut Vec<String>) {
         }
         Stmt::While(ws) => {
             collect_free_idents_expr(&ws.condition, out);
-            for s in &ws.body {
+            for s in ws.all_stmts() {
                 collect_free_idents_stmt(s, out);
             }
         }
@@ -5765,6 +6698,37 @@ fn format_spec_to_string(spec: &FormatSpec) -> String {
     s
 }
 
+/// The constant an expression is written as, if it is a literal.
+fn literal_constant(expr: &Expr) -> Option<Constant> {
+    match expr {
+        Expr::NullLit(_) => Some(Constant::Null),
+        Expr::BoolLit(b, _) => Some(Constant::Bool(*b)),
+        Expr::IntLit(n, _) => Some(Constant::Int(*n)),
+        Expr::FloatLit(f, _) => Some(Constant::Float(*f)),
+        Expr::StringLit(s, _) => Some(Constant::String(s.clone())),
+        Expr::UnaryOp(UnaryOp::Neg, inner, _) => match literal_constant(inner)? {
+            Constant::Int(n) => Some(Constant::Int(n.checked_neg()?)),
+            Constant::Float(f) => Some(Constant::Float(-f)),
+            _ => None,
+        },
+        _ => None,
+    }
+}
+
+/// The field's `@json(...)` attribute, already checked by the parser.
+fn json_field(f: &FieldDef) -> Option<JsonField> {
+    let attr = f.attributes.iter().find(|a| a.name == "json")?;
+    let mut json = JsonField::default();
+    for arg in &attr.args {
+        match arg {
+            AttributeArg::Str(key) => json.key = Some(key.clone()),
+            AttributeArg::Ident(opt) if opt == "skip" => json.skip = true,
+            AttributeArg::Ident(_) => json.omit_empty = true,
+        }
+    }
+    Some(json)
+}
+
 fn format_type_expr(ty: &TypeExpr) -> String {
     match ty {
         TypeExpr::Named(n, _) => n.clone(),
@@ -5948,7 +6912,7 @@ mod tests {
 
     #[test]
     fn test_while_loop_emits_signed_backward_jump() {
-        let src = "cell loop_test(n: Int) -> Int\n  let x = 0\n  while x < n\n    x = x + 1\n  end\n  return x\nend";
+        let src = "cell loop_test(n: Int) -> Int\n  var x = 0\n  while x < n\n    x = x + 1\n  end\n  return x\nend";
         let module = lower_src(src);
         let instrs = &module.cells[0].instructions;
         // Find all Jmp instructions
@@ -6071,16 +7035,15 @@ mod tests {
 
     #[test]
     fn test_for_loop_emits_iteration() {
-        let src = "cell sum_list(xs: list[Int]) -> Int\n  let total = 0\n  for x in xs\n    total = total + x\n  end\n  return total\nend";
+        let src = "cell sum_list(xs: list[Int]) -> Int\n  var total = 0\n  for x in xs\n    total = total + x\n  end\n  return total\nend";
         let module = lower_src(src);
         let ops: Vec<_> = module.cells[0].instructions.iter().map(|i| i.op).collect();
         assert!(
-            ops.contains(&OpCode::Lt),
-            "for loop should emit Lt for bound check"
-        );
-        assert!(
-            ops.contains(&OpCode::GetIndex),
-            "for loop should emit GetIndex for element access"
+            module.cells[0]
+                .instructions
+                .iter()
+                .any(|i| i.op == OpCode::Intrinsic && i.b == IntrinsicId::IterNext as u8),
+            "for loop should advance with the IterNext intrinsic"
         );
         assert!(ops.contains(&OpCode::Add), "for loop body should emit Add");
         // Should have backward jump
@@ -6091,6 +7054,37 @@ mod tests {
         assert!(has_backward_jmp, "for loop should have backward jump");
     }
 
+    #[test]
+    fn test_assign_moves_a_block_let_nothing_reads_afterwards() {
+        // How the cell copies the first element it took out of a tuple
+        fn copy_of_first_element(module: &LirModule, cell: &str) -> OpCode {
+            let cell = module.cells.iter().find(|c| c.name == cell).unwrap();
+            let bound = cell
+                .instructions
+                .iter()
+                .find(|i| i.op == OpCode::GetTuple && i.c == 0)
+                .unwrap()
+                .a;
+            cell.instructions
+                .iter()
+                .find(|i| matches!(i.op, OpCode::Move | OpCode::MoveOwn) && i.b == bound)
+                .unwrap()
+                .op
+        }
+
+        let pair = "cell pair(n: Int) -> tuple[Int, Int]\n  return (n, n)\nend\n";
+        let module = lower_src(&format!(
+            "{}\ncell moved() -> Int\n  var total = 0\n  for i in range(0, 3)\n    \
+             let (next, _) = pair(i)\n    total = next\n  end\n  return total\nend\n\n\
+             cell kept() -> Int\n  var total = 0\n  for i in range(0, 3)\n    \
+             let (next, _) = pair(i)\n    total = next\n    total = total + next\n  end\n  \
+             return total\nend",
+            pair
+        ));
+        assert_eq!(copy_of_first_element(&module, "moved"), OpCode::MoveOwn);
+        assert_eq!(copy_of_first_element(&module, "kept"), OpCode::Move);
+    }
+
     #[test]
     fn test_intrinsic_sort_maps_correctly() {
         let src = "cell test_sort(xs: list[Int]) -> list[Int]\n  return sort(xs)\nend";
@@ -6131,7 +7125,7 @@ mod tests {
 
     #[test]
     fn test_break_continue_in_while() {
-        let src = "cell test() -> Int\n  let i = 0\n  while true\n    i = i + 1\n    if i > 10\n      break\n    end\n  end\n  return i\nend";
+        let src = "cell test() -> Int\n  var i = 0\n  while true\n    i = i + 1\n    if i > 10\n      break\n    end\n  end\n  return i\nend";
         let module = lower_src(src);
         let instrs = &module.cells[0].instructions;
         // Should have both forward jumps (break) and backward jumps (while loop back)
@@ -6663,4 +7657,82 @@ end"#;
         assert!(has_inf, "constant pool should contain INFINITY");
         assert!(has_nan, "constant pool should contain NAN");
     }
+
+    /// The pool index of every `LoadK` in `cell` whose constant matches `want`.
+    fn loads_of(cell: &LirCell, want: impl Fn(&Constant) -> bool) -> Vec<u16> {
+        cell.instructions
+            .iter()
+            .filter(|i| i.op == OpCode::LoadK && want(&cell.constants[i.bx() as usize]))
+            .map(|i| i.bx())
+            .collect()
+    }
+
+    #[test]
+    fn test_equal_literals_share_a_constant_slot() {
+        let module = lower_src(
+            "cell tag(name: String, n: Int) -> list[String]\n  return [\"x\" + name, name + \"x\", \"x\" + string(n + 7), string(n * 7)]\nend",
+        );
+        let cell = &module.cells[0];
+        let xs = loads_of(cell, |c| matches!(c, Constant::String(s) if s == "x"));
+        assert_eq!(xs.len(), 3, "{:?}", cell.instructions);
+        assert!(xs.iter().all(|&k| k == xs[0]), "{:?}", xs);
+        let sevens = loads_of(cell, |c| matches!(c, Constant::Int(7)));
+        assert_eq!(sevens.len(), 2);
+        assert_eq!(sevens[0], sevens[1]);
+        let strings = cell
+            .constants
+            .iter()
+            .filter(|c| matches!(c, Constant::String(s) if s == "x"))
+            .count();
+        assert_eq!(strings, 1, "{:?}", cell.constants);
+    }
+
+    #[test]
+    fn test_float_and_int_zeros_keep_their_own_slots() {
+        let module = lower_src(
+            "cell zeros(x: Float, n: Int) -> list[Float]\n  return [x + 0.0, x * 0.5, x - 0.0, x / 0.5, float(n + 0)]\nend",
+        );
+        let cell = &module.cells[0];
+        let zero = loads_of(cell, |c| matches!(c, Constant::Float(f) if *f == 0.0));
+        let half = loads_of(cell, |c| matches!(c, Constant::Float(f) if *f == 0.5));
+        let int = loads_of(cell, |c| matches!(c, Constant::Int(0)));
+        assert_eq!(zero.len(), 2, "{:?}", cell.constants);
+        assert_eq!(half.len(), 2, "{:?}", cell.constants);
+        assert_eq!(int.len(), 1, "{:?}", cell.constants);
+        assert_eq!(zero[0], zero[1]);
+        assert_eq!(half[0], half[1]);
+        assert_ne!(zero[0], int[0], "0.0 and 0 must not be merged");
+    }
+
+    #[test]
+    fn test_dedup_constants_remaps_loads_and_performs() {
+        let mut constants = vec![
+            Constant::String("a".into()),
+            Constant::Float(0.0),
+            Constant::String("a".into()),
+            Constant::Float(-0.0),
+            Constant::Float(f64::NAN),
+            Constant::Float(0.0),
+            Constant::Float(f64::NAN),
+        ];
+        let mut instrs = vec![
+            Instruction::abx(OpCode::LoadK, 0, 2),
+            Instruction::abx(OpCode::LoadK, 1, 5),
+            Instruction::abx(OpCode::LoadK, 2, 3),
+            Instruction::abc(OpCode::Perform, 3, 2, 0),
+            Instruction::abx(OpCode::LoadK, 4, 6),
+        ];
+        dedup_constants(&mut constants, &mut instrs);
+        assert_eq!(
+            format!("{:?}", constants),
+            "[String(\"a\"), Float(0.0), Float(-0.0), Float(NaN)]"
+        );
+        let slots: Vec<u16> = instrs
+            .iter()
+            .filter(|i| i.op == OpCode::LoadK)
+            .map(|i| i.bx())
+            .collect();
+        assert_eq!(slots, vec![0, 1, 2, 3]);
+        assert_eq!((instrs[3].b, instrs[3].c), (0, 0));
+    }
 }
@@ -0,0 +1,197 @@
+//! Result caches for `@memoize` cells.
+//!
+//! `@memoize cell fib(n: Int) -> Int` is lowered as two cells. The body
+//! moves to a copy named `fib.uncached`, and `fib` itself becomes
+//!
+//! ```text
+//! cell fib(n: Int) -> Int
+//!   let __memo_key = [n]
+//!   let __memo_hit = __memo_lookup("fib", __memo_key)
+//!   if len(__memo_hit) > 0
+//!     return __memo_hit[0]
+//!   end
+//!   return __memo_store("fib", 0, __memo_key, fib.uncached(n))
+//! end
+//! ```
+//!
+//! Calls still name `fib`, including the recursive ones in the body, so
+//! every call goes through the cache. The VM keeps one cache per cell,
+//! keyed by the argument list; `@memoize(n)` passes `n` in place of the `0`
+//! (no bound), and the cache then drops its least recently used entry when
+//! it would grow past `n`.
+
+use crate::compiler::ast::*;
+
+/// Name of the copy that holds a memoized cell's body.
+pub fn uncached_name(cell: &str) -> String {
+    format!("{}.uncached", cell)
+}
+
+/// `program` with each `@memoize` cell split into a caching cell and its
+/// uncached body. None when no cell is memoized.
+pub fn expand(program: &Program) -> Option<Program> {
+    if !program
+        .items
+        .iter()
+        .any(|item| matches!(item, Item::Cell(c) if c.memoize.is_some()))
+    {
+        return None;
+    }
+    let mut expanded = program.clone();
+    let mut bodies = Vec::new();
+    for item in &mut expanded.items {
+        let Item::Cell(cell) = item else { continue };
+        let Some(memoize) = cell.memoize.take() else {
+            continue;
+        };
+        let mut body = cell.clone();
+        body.name = uncached_name(&cell.name);
+        body.is_pub = false;
+        body.doc = None;
+        // The caching cell checks them before the cache is consulted
+        body.where_clauses.clear();
+        cell.body = caching_body(cell, memoize);
+        bodies.push(Item::Cell(body));
+    }
+    expanded.items.extend(bodies);
+    Some(expanded)
+}
+
+/// The statements of the caching cell that stands in for `cell`.
+fn caching_body(cell: &CellDef, memoize: Memoize) -> Vec<Stmt> {
+    let span = cell.span;
+    let ident = |name: &str| Expr::Ident(name.to_string(), span);
+    let call = |name: &str, args: Vec<Expr>| {
+        Expr::Call(
+            Box::new(ident(name)),
+            args.into_iter().map(CallArg::Positional).collect(),
+            span,
+        )
+    };
+    let bind = |name: &str, value: Expr| {
+        Stmt::Let(LetStmt {
+            name: name.to_string(),
+            mutable: false,
+            is_var: false,
+            pattern: None,
+            ty: None,
+            value,
+            span,
+        })
+    };
+    let ret = |value: Expr| Stmt::Return(ReturnStmt { value, span });
+
+    let cache = Expr::StringLit(cell.name.clone(), span);
+    let capacity = match memoize {
+        Memoize::Unbounded => 0,
+        Memoize::Lru(n) => n as i64,
+    };
+    let args: Vec<Expr> = cell.params.iter().map(|p| ident(&p.name)).collect();
+    vec![
+        bind("__memo_key", Expr::ListLit(args.clone(), span)),
+        bind(
+            "__memo_hit",
+            call("__memo_lookup", vec![cache.clone(), ident("__memo_key")]),
+        ),
+        Stmt::If(IfStmt {
+            condition: Expr::BinOp(
+                Box::new(call("len", vec![ident("__memo_hit")])),
+                BinOp::Gt,
+                Box::new(Expr::IntLit(0, span)),
+                span,
+            ),
+            then_body: vec![ret(Expr::IndexAccess(
+                Box::new(ident("__memo_hit")),
+                Box::new(Expr::IntLit(0, span)),
+                span,
+            ))],
+            else_body: None,
+            span,
+        }),
+        ret(call(
+            "__memo_store",
+            vec![
+                cache,
+                Expr::IntLit(capacity, span),
+                ident("__memo_key"),
+                call(&uncached_name(&cell.name), args),
+            ],
+        )),
+    ]
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::compiler::lexer::Lexer;
+    use crate::compiler::parser::Parser;
+
+    fn expand_src(src: &str) -> Program {
+        let mut lexer = Lexer::new(src, 1, 0);
+        let tokens = lexer.tokenize().unwrap();
+        let mut parser = Parser::new(tokens);
+        let program = parser.parse_program(vec![]).unwrap();
+        expand(&program).expect("program has @memoize cells")
+    }
+
+    fn cell<'p>(program: &'p Program, name: &str) -> &'p CellDef {
+        program
+            .items
+            .iter()
+            .find_map(|item| match item {
+                Item::Cell(c) if c.name == name => Some(c),
+                _ => None,
+            })
+            .unwrap_or_else(|| panic!("no cell named {}", name))
+    }
+
+    fn store_args(cell: &CellDef) -> &[CallArg] {
+        match cell.body.last() {
+            Some(Stmt::Return(ReturnStmt {
+                value: Expr::Call(callee, args, _),
+                ..
+            })) if matches!(callee.as_ref(), Expr::Ident(n, _) if n == "__memo_store") => args,
+            other => panic!(
+                "last statement is not `return __memo_store(...)`: {:?}",
+                other
+            ),
+        }
+    }
+
+    const FIB: &str = "cell fib(n: Int) -> Int\n  if n < 2\n    return n\n  end\n  return fib(n - 1) + fib(n - 2)\nend";
+
+    #[test]
+    fn test_body_moves_to_uncached_copy() {
+        let program = expand_src(&format!("@memoize\n{}", FIB));
+        let wrapper = cell(&program, "fib");
+        let body = cell(&program, "fib.uncached");
+        assert_eq!(wrapper.memoize, None);
+        assert_eq!(body.memoize, None);
+        assert_eq!(wrapper.params.len(), 1);
+        assert_eq!(body.body.len(), 2);
+        let args = store_args(wrapper);
+        assert!(matches!(args[1], CallArg::Positional(Expr::IntLit(0, _))));
+        let CallArg::Positional(Expr::Call(callee, body_args, _)) = &args[3] else {
+            panic!("the result is not a call: {:?}", args[3]);
+        };
+        assert!(matches!(callee.as_ref(), Expr::Ident(n, _) if n == "fib.uncached"));
+        assert_eq!(body_args.len(), 1);
+    }
+
+    #[test]
+    fn test_bound_is_passed_to_the_cache() {
+        let program = expand_src(&format!("@memoize(32)\n{}", FIB));
+        assert!(matches!(
+            store_args(cell(&program, "fib")),
+            [_, CallArg::Positional(Expr::IntLit(32, _)), _, _]
+        ));
+    }
+
+    #[test]
+    fn test_no_memoize_leaves_program_alone() {
+        let mut lexer = Lexer::new(FIB, 1, 0);
+        let tokens = lexer.tokenize().unwrap();
+        let program = Parser::new(tokens).parse_program(vec![]).unwrap();
+        assert!(expand(&program).is_none());
+    }
+}
@@ -1,16 +1,25 @@
 pub mod active_patterns;
 pub mod ast;
+pub mod cfg;
+pub mod const_eval;
 pub mod constraints;
 pub mod docs_as_tests;
+pub mod dominators;
 pub mod emit;
 pub mod error_codes;
+pub mod fast_math;
 pub mod fixit;
 pub mod gadts;
+pub mod incremental;
 pub mod grammar;
+pub mod gvn;
+pub mod layout;
 pub mod lexer;
 pub mod lir;
 pub mod lower;
 pub mod macros;
+pub mod memoize;
+pub mod opt_report;
 pub mod ownership;
 pub mod parity_memory;
 pub mod parser;
@@ -20,6 +29,7 @@ pub mod regalloc;
 pub mod resolve;
 pub mod sandbox;
 pub mod session;
+pub mod specialize;
 pub mod testing_helpers;
 pub mod tokens;
 pub mod typecheck;
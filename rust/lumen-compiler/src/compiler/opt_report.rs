@@ -0,0 +1,192 @@
+//! Optimization report for `lumen build --opt-report`.
+//!
+//! While a cell is lowered, each optimization that looks at it leaves a
+//! note: what it did, or what stopped it. A hot loop that stays slow can
+//! then be reshaped into a form the compiler handles, for example by
+//! moving a `defer` out of a recursive cell or by writing an explicit
+//! `return` in front of a self-call.
+//!
+//! The notes cover the lowering passes. The JIT adds its own notes for
+//! the finished module (see `lumen_codegen::jit::note_jit_blockers`).
+
+use std::fmt;
+
+/// An optimization the report can mention.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum Optimization {
+    /// `return f(..)` running the callee in the caller's frame
+    TailCall,
+    /// Constant loads moved in front of the loop that runs them
+    ConstantHoisting,
+    /// Repeated pure computations reusing the first result
+    ValueNumbering,
+    /// Native code for the whole cell
+    Jit,
+}
+
+impl fmt::Display for Optimization {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        f.write_str(match self {
+            Optimization::TailCall => "tail call",
+            Optimization::ConstantHoisting => "constant hoisting",
+            Optimization::ValueNumbering => "value numbering",
+            Optimization::Jit => "JIT compilation",
+        })
+    }
+}
+
+/// One optimization that fired or was blocked in a cell.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct OptNote {
+    pub optimization: Optimization,
+    /// Whether the optimization was blocked rather than applied.
+    pub blocked: bool,
+    /// The source line the note is about, when it is about one place.
+    pub line: Option<usize>,
+    /// What the optimization did, or why it could not apply.
+    pub detail: String,
+}
+
+impl OptNote {
+    pub fn fired(
+        optimization: Optimization,
+        line: Option<usize>,
+        detail: impl Into<String>,
+    ) -> Self {
+        OptNote {
+            optimization,
+            blocked: false,
+            line,
+            detail: detail.into(),
+        }
+    }
+
+    pub fn blocked(
+        optimization: Optimization,
+        line: Option<usize>,
+        detail: impl Into<String>,
+    ) -> Self {
+        OptNote {
+            optimization,
+            blocked: true,
+            line,
+            detail: detail.into(),
+        }
+    }
+}
+
+impl fmt::Display for OptNote {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        write!(f, "{}", self.optimization)?;
+        if self.blocked {
+            f.write_str(" blocked")?;
+        }
+        if let Some(line) = self.line {
+            write!(f, " at line {}", line)?;
+        }
+        write!(f, ": {}", self.detail)
+    }
+}
+
+/// The notes for one cell, in the order they were made.
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub struct CellReport {
+    pub cell: String,
+    pub notes: Vec<OptNote>,
+}
+
+/// The notes for every lowered cell, in lowering order.
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub struct OptReport {
+    pub cells: Vec<CellReport>,
+}
+
+impl OptReport {
+    /// The report for `cell`, if it was lowered.
+    pub fn cell(&self, cell: &str) -> Option<&CellReport> {
+        self.cells.iter().find(|c| c.cell == cell)
+    }
+
+    /// Add `note` to the report for `cell`, starting one if needed.
+    pub fn note(&mut self, cell: &str, note: OptNote) {
+        match self.cells.iter_mut().find(|c| c.cell == cell) {
+            Some(report) => report.notes.push(note),
+            None => self.cells.push(CellReport {
+                cell: cell.to_string(),
+                notes: vec![note],
+            }),
+        }
+    }
+
+    /// Notes of optimizations that were blocked, over all cells.
+    pub fn blocked(&self) -> impl Iterator<Item = (&str, &OptNote)> {
+        self.cells.iter().flat_map(|c| {
+            c.notes
+                .iter()
+                .filter(|n| n.blocked)
+                .map(move |n| (c.cell.as_str(), n))
+        })
+    }
+}
+
+impl fmt::Display for OptReport {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        for report in &self.cells {
+            writeln!(f, "cell {}", report.cell)?;
+            if report.notes.is_empty() {
+                writeln!(f, "  no optimizations apply")?;
+            }
+            for note in &report.notes {
+                writeln!(f, "  {}", note)?;
+            }
+        }
+        Ok(())
+    }
+}
+
+// ---------------------------------------------------------------------------
+// Tests
+// ---------------------------------------------------------------------------
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn notes_are_grouped_by_cell_in_order() {
+        let mut report = OptReport::default();
+        report.note(
+            "main",
+            OptNote::fired(Optimization::TailCall, Some(3), "`step` runs in this frame"),
+        );
+        report.note(
+            "step",
+            OptNote::blocked(Optimization::Jit, None, "uses GetIndex"),
+        );
+        report.note(
+            "main",
+            OptNote::blocked(
+                Optimization::ConstantHoisting,
+                None,
+                "1 constant load stays in a loop",
+            ),
+        );
+        report.cells.push(CellReport {
+            cell: "idle".to_string(),
+            notes: vec![],
+        });
+
+        assert_eq!(
+            report.to_string(),
+            "cell main\n\
+             \x20 tail call at line 3: `step` runs in this frame\n\
+             \x20 constant hoisting blocked: 1 constant load stays in a loop\n\
+             cell step\n\
+             \x20 JIT compilation blocked: uses GetIndex\n\
+             cell idle\n\
+             \x20 no optimizations apply\n"
+        );
+        let blocked: Vec<&str> = report.blocked().map(|(cell, _)| cell).collect();
+        assert_eq!(blocked, ["main", "step"]);
+    }
+}
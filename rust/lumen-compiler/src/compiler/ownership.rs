@@ -172,6 +172,8 @@ pub fn ownership_mode_for_type(ty: &Type) -> OwnershipMode {
         | Type::Json => OwnershipMode::Copy,
         // `Any` — conservative default: treat as Copy so existing code isn't broken
         Type::Any => OwnershipMode::Copy,
+        // `Never` has no values, so nothing can be moved
+        Type::Never => OwnershipMode::Copy,
         // Enums with no payload are cheap, but enums in general may hold owned data.
         // Treat all enums as Owned for safety.
         Type::Enum(_) => OwnershipMode::Owned,
@@ -181,6 +183,7 @@ pub fn ownership_mode_for_type(ty: &Type) -> OwnershipMode {
         | Type::Set(_)
         | Type::Tuple(_)
         | Type::Record(_)
+        | Type::Trait(_)
         | Type::Fn(_, _)
         | Type::Result(_, _) => OwnershipMode::Owned,
         // Union types: if ALL arms are Copy, the union is Copy; otherwise Owned
@@ -584,11 +587,17 @@ impl<'a> OwnershipChecker<'a> {
                 self.exit_scope();
             }
             Stmt::While(ws) => {
-                self.check_expr(&ws.condition);
                 self.enter_scope();
+                if let Some(init) = &ws.init {
+                    self.check_stmt(init);
+                }
+                self.check_expr(&ws.condition);
                 for s in &ws.body {
                     self.check_stmt(s);
                 }
+                if let Some(post) = &ws.post {
+                    self.check_stmt(post);
+                }
                 self.exit_scope();
             }
             Stmt::Loop(ls) => {
@@ -705,6 +714,10 @@ impl<'a> OwnershipChecker<'a> {
                     self.check_expr(e);
                 }
             }
+            Expr::ListRepeat(value, count, _) => {
+                self.check_expr(value);
+                self.check_expr(count);
+            }
             Expr::MapLit(entries, _) => {
                 for (k, v) in entries {
                     self.check_expr(k);
@@ -1138,6 +1151,7 @@ impl<'a> OwnershipChecker<'a> {
                     .unwrap_or(Type::Any);
                 Type::List(Box::new(inner))
             }
+            Expr::ListRepeat(value, _, _) => Type::List(Box::new(self.infer_expr_type(value))),
             Expr::MapLit(_, _) => Type::Map(Box::new(Type::String), Box::new(Type::Any)),
             Expr::TupleLit(elems, _) => {
                 Type::Tuple(elems.iter().map(|e| self.infer_expr_type(e)).collect())
@@ -54,6 +54,42 @@ pub enum ParseError {
 /// Prevents cascading error spam from a single root cause.
 const MAX_PARSE_ERRORS: usize = 10;
 
+/// `@json` takes an optional key, then the options `skip` and `omit_empty`.
+fn validate_json_attribute(attr: &FieldAttribute) -> Result<(), ParseError> {
+    let malformed = |reason: String| ParseError::MalformedConstruct {
+        construct: "@json attribute".into(),
+        reason,
+        line: attr.span.line,
+        col: attr.span.col,
+    };
+    if attr.args.is_empty() {
+        return Err(malformed("expected a key or an option".into()));
+    }
+    for (i, arg) in attr.args.iter().enumerate() {
+        match arg {
+            AttributeArg::Str(key) if i == 0 => {
+                if key.is_empty() {
+                    return Err(malformed("the key may not be empty".into()));
+                }
+            }
+            AttributeArg::Str(key) => {
+                return Err(malformed(format!(
+                    "the key \"{}\" must come before the options",
+                    key
+                )));
+            }
+            AttributeArg::Ident(opt) if opt == "skip" || opt == "omit_empty" => {}
+            AttributeArg::Ident(opt) => {
+                return Err(malformed(format!(
+                    "unknown option `{}`; expected `skip` or `omit_empty`",
+                    opt
+                )));
+            }
+        }
+    }
+    Ok(())
+}
+
 pub struct Parser {
     tokens: Vec<Token>,
     pos: usize,
@@ -63,6 +99,8 @@ pub struct Parser {
     /// treating them as block terminators.
     block_depth: usize,
     errors: Vec<ParseError>,
+    /// The value of `iota` while a line of a `const` block is parsed
+    iota: Option<i64>,
     /// Language edition for forward-compatible parsing. Default: `"2026"`.
     /// Future editions may alter syntax rules; for now this is threaded
     /// through but does not change parsing behaviour.
@@ -77,6 +115,7 @@ impl Parser {
             bracket_depth: 0,
             block_depth: 0,
             errors: Vec::new(),
+            iota: None,
             edition: "2026".to_string(),
         }
     }
@@ -89,6 +128,7 @@ impl Parser {
             bracket_depth: 0,
             block_depth: 0,
             errors: Vec::new(),
+            iota: None,
             edition,
         }
     }
@@ -112,6 +152,9 @@ impl Parser {
     /// Synchronize parser state by skipping tokens until we reach a declaration boundary
     /// This function includes infinite loop protection by tracking position advancement
     fn synchronize(&mut self) {
+        // A failed item can unwind out of nested brackets and bodies.
+        self.bracket_depth = 0;
+        self.block_depth = 0;
         // Skip tokens until we reach a synchronization point:
         // - A new declaration keyword (cell, record, enum, type, grant, import, etc.)
         // - End of file
@@ -174,60 +217,99 @@ impl Parser {
             }
         }
     }
-    /// Synchronize within a statement block to the next valid statement boundary
-    /// Improved with better keyword detection and infinite loop protection
-    fn synchronize_stmt(&mut self) {
-        let mut last_pos = self.pos;
-        let mut iterations = 0;
-        const MAX_ITERATIONS: usize = 10000;
-
-        while !self.at_end() && iterations < MAX_ITERATIONS {
-            // Safety check: ensure we're making progress
-            if self.pos == last_pos && iterations > 0 {
-                self.advance();
+    /// Panic-mode recovery inside a statement block: after the statement that
+    /// began at token `start` failed to parse, skip the rest of it and stop at
+    /// the start of the next statement in the same block.
+    ///
+    /// Brackets and indentation opened since `start` are tracked, so the skip
+    /// runs to the end of the whole broken statement — including a multi-line
+    /// call or, for a broken block header such as `if a ==`, its indented body
+    /// and closing `end` — rather than resuming mid-construct. A bracket left
+    /// open at a line that starts a new statement is treated as unclosed.
+    fn synchronize_stmt(&mut self, start: usize) {
+        let opens_block = matches!(
+            self.tokens.get(start).map(|t| &t.kind),
+            Some(
+                TokenKind::If
+                    | TokenKind::For
+                    | TokenKind::While
+                    | TokenKind::Loop
+                    | TokenKind::Match
+                    | TokenKind::Switch
+                    | TokenKind::Defer
+                    | TokenKind::At
+            )
+        );
+        let mut brackets = 0usize;
+        let mut indents = 0usize;
+        for tok in &self.tokens[start..self.pos.min(self.tokens.len())] {
+            match tok.kind {
+                TokenKind::LParen | TokenKind::LBracket | TokenKind::LBrace => brackets += 1,
+                TokenKind::RParen | TokenKind::RBracket | TokenKind::RBrace => {
+                    brackets = brackets.saturating_sub(1)
+                }
+                TokenKind::Indent => indents += 1,
+                TokenKind::Dedent => indents = indents.saturating_sub(1),
+                _ => {}
             }
-            last_pos = self.pos;
-            iterations += 1;
+        }
 
+        while !self.at_end() {
             match self.peek_kind() {
-                TokenKind::Newline => {
+                TokenKind::LParen | TokenKind::LBracket | TokenKind::LBrace => brackets += 1,
+                TokenKind::RParen | TokenKind::RBracket | TokenKind::RBrace => {
+                    brackets = brackets.saturating_sub(1)
+                }
+                TokenKind::Indent => indents += 1,
+                TokenKind::Dedent if indents == 0 => break, // end of the enclosing block
+                TokenKind::Dedent => {
                     self.advance();
-                    // After newline, check if next token is a statement keyword
-                    if self.is_stmt_keyword() {
-                        break;
+                    indents -= 1;
+                    if indents == 0 && brackets == 0 && self.stmt_resumes_here(opens_block) {
+                        return;
                     }
+                    continue;
                 }
-                TokenKind::End | TokenKind::Else | TokenKind::Dedent | TokenKind::Eof => break,
-                // Statement keywords - new statement starts here
-                TokenKind::Let
-                | TokenKind::If
-                | TokenKind::For
-                | TokenKind::While
-                | TokenKind::Loop
-                | TokenKind::Match
-                | TokenKind::Return
-                | TokenKind::Halt
-                | TokenKind::Break
-                | TokenKind::Continue
-                | TokenKind::Emit => break,
-                // Top-level declaration keywords
-                TokenKind::Cell
-                | TokenKind::Record
-                | TokenKind::Enum
-                | TokenKind::Type
-                | TokenKind::Grant
-                | TokenKind::Import
-                | TokenKind::Use
-                | TokenKind::Pub
-                | TokenKind::Schema
-                | TokenKind::Fn
-                | TokenKind::Mod
-                | TokenKind::Trait
-                | TokenKind::Impl => break,
-                _ => {
+                TokenKind::End | TokenKind::Else if indents == 0 && brackets == 0 => {
+                    if self.stmt_resumes_here(opens_block) {
+                        return;
+                    }
+                }
+                TokenKind::Newline => {
                     self.advance();
+                    while matches!(self.peek_kind(), TokenKind::Newline) {
+                        self.advance();
+                    }
+                    if brackets > 0
+                        && (self.is_stmt_keyword()
+                            || matches!(self.peek_kind(), TokenKind::End | TokenKind::Else))
+                    {
+                        brackets = 0;
+                    }
+                    if indents == 0 && brackets == 0 && self.stmt_resumes_here(opens_block) {
+                        return;
+                    }
+                    continue;
                 }
+                _ => {}
+            }
+            self.advance();
+        }
+    }
+
+    /// At the start of a line in the broken statement's block, decide whether
+    /// the next statement starts here. A deeper line continues the broken
+    /// statement; so do the `else` and `end` of a broken block header, and
+    /// that `end` is consumed.
+    fn stmt_resumes_here(&mut self, opens_block: bool) -> bool {
+        match self.peek_kind() {
+            TokenKind::Indent => false,
+            TokenKind::Else => !opens_block,
+            TokenKind::End if opens_block => {
+                self.advance();
+                true
             }
+            _ => true,
         }
     }
 
@@ -241,6 +323,7 @@ impl Parser {
                 | TokenKind::While
                 | TokenKind::Loop
                 | TokenKind::Match
+                | TokenKind::Switch
                 | TokenKind::Return
                 | TokenKind::Halt
                 | TokenKind::Break
@@ -390,6 +473,7 @@ impl Parser {
                 | TokenKind::Loop
                 | TokenKind::If
                 | TokenKind::Match
+                | TokenKind::Switch
                 | TokenKind::End
         )
     }
@@ -515,6 +599,18 @@ impl Parser {
                         self.synchronize();
                     }
                 }
+            } else if matches!(self.peek_kind(), TokenKind::Const)
+                && matches!(self.peek_n_kind(1), Some(TokenKind::Newline))
+            {
+                match self.parse_const_block() {
+                    Ok(consts) => items.extend(consts.into_iter().map(Item::ConstDecl)),
+                    Err(err) => {
+                        if self.record_error(err) {
+                            break; // hit max error limit
+                        }
+                        self.synchronize();
+                    }
+                }
             } else {
                 match self.parse_item() {
                     Ok(item) => items.push(item),
@@ -551,11 +647,16 @@ impl Parser {
                 is_pub: false,
                 is_async: false,
                 is_extern: false,
+                is_const: false,
                 must_use: false,
                 where_clauses: vec![],
                 span: span_start.merge(end_span),
                 doc: None,
                 deprecated: None,
+                receiver: None,
+                specializations: vec![],
+                tailcall: false,
+                memoize: None,
             }));
         }
         let span = if items.is_empty() {
@@ -572,12 +673,138 @@ impl Parser {
 
     /// Check if current position is `@must_use` (@ followed by identifier "must_use")
     fn is_must_use_attribute(&self) -> bool {
+        self.is_named_attribute("must_use")
+    }
+
+    /// Check if current position is `@packed` followed by a record definition
+    fn is_packed_attribute(&self) -> bool {
+        if !self.is_named_attribute("packed") {
+            return false;
+        }
+        let mut offset = 2;
+        while matches!(self.peek_n_kind(offset), Some(TokenKind::Newline)) {
+            offset += 1;
+        }
+        if matches!(self.peek_n_kind(offset), Some(TokenKind::Pub)) {
+            offset += 1;
+        }
+        matches!(self.peek_n_kind(offset), Some(TokenKind::Record))
+    }
+
+    /// Parse one or more `@specialize(T1, T2, ...)` lines and the generic
+    /// cell they apply to.
+    fn parse_specialized_cell(&mut self, is_pub: bool) -> Result<Item, ParseError> {
+        let mut specializations = Vec::new();
+        while self.is_named_attribute("specialize") {
+            self.advance(); // consume '@'
+            self.advance(); // consume 'specialize'
+            self.expect(&TokenKind::LParen)?;
+            let mut types = vec![self.parse_type()?];
+            while matches!(self.peek_kind(), TokenKind::Comma) {
+                self.advance();
+                types.push(self.parse_type()?);
+            }
+            self.expect(&TokenKind::RParen)?;
+            self.skip_newlines();
+            specializations.push(types);
+        }
+        let is_pub = is_pub || matches!(self.peek_kind(), TokenKind::Pub);
+        if matches!(self.peek_kind(), TokenKind::Pub) {
+            self.advance();
+            self.skip_newlines();
+        }
+        if !matches!(self.peek_kind(), TokenKind::Cell) {
+            let tok = self.current().clone();
+            return Err(ParseError::Unexpected {
+                found: format!("{}", tok.kind),
+                expected: "cell after @specialize".into(),
+                line: tok.span.line,
+                col: tok.span.col,
+            });
+        }
+        let mut c = self.parse_cell(true)?;
+        c.is_pub = is_pub;
+        c.specializations = specializations;
+        Ok(Item::Cell(c))
+    }
+
+    /// Parse `@tailcall` and the cell it applies to.
+    fn parse_tailcall_cell(&mut self, is_pub: bool) -> Result<Item, ParseError> {
+        self.advance(); // consume '@'
+        self.advance(); // consume 'tailcall'
+        self.skip_newlines();
+        let is_pub = is_pub || matches!(self.peek_kind(), TokenKind::Pub);
+        if matches!(self.peek_kind(), TokenKind::Pub) {
+            self.advance();
+            self.skip_newlines();
+        }
+        if !matches!(self.peek_kind(), TokenKind::Cell) {
+            let tok = self.current().clone();
+            return Err(ParseError::Unexpected {
+                found: format!("{}", tok.kind),
+                expected: "cell after @tailcall".into(),
+                line: tok.span.line,
+                col: tok.span.col,
+            });
+        }
+        let mut c = self.parse_cell(true)?;
+        c.is_pub = is_pub;
+        c.tailcall = true;
+        Ok(Item::Cell(c))
+    }
+
+    /// Parse `@memoize` or `@memoize(n)` and the cell it applies to.
+    fn parse_memoize_cell(&mut self, is_pub: bool) -> Result<Item, ParseError> {
+        self.advance(); // consume '@'
+        self.advance(); // consume 'memoize'
+        let memoize = if matches!(self.peek_kind(), TokenKind::LParen) {
+            self.advance();
+            let tok = self.current().clone();
+            let capacity = match tok.kind {
+                TokenKind::IntLit(n) if n > 0 => n as u64,
+                _ => {
+                    return Err(ParseError::Unexpected {
+                        found: format!("{}", tok.kind),
+                        expected: "a positive cache size in @memoize(...)".into(),
+                        line: tok.span.line,
+                        col: tok.span.col,
+                    });
+                }
+            };
+            self.advance();
+            self.expect(&TokenKind::RParen)?;
+            Memoize::Lru(capacity)
+        } else {
+            Memoize::Unbounded
+        };
+        self.skip_newlines();
+        let is_pub = is_pub || matches!(self.peek_kind(), TokenKind::Pub);
+        if matches!(self.peek_kind(), TokenKind::Pub) {
+            self.advance();
+            self.skip_newlines();
+        }
+        if !matches!(self.peek_kind(), TokenKind::Cell) {
+            let tok = self.current().clone();
+            return Err(ParseError::Unexpected {
+                found: format!("{}", tok.kind),
+                expected: "cell after @memoize".into(),
+                line: tok.span.line,
+                col: tok.span.col,
+            });
+        }
+        let mut c = self.parse_cell(true)?;
+        c.is_pub = is_pub;
+        c.memoize = Some(memoize);
+        Ok(Item::Cell(c))
+    }
+
+    /// Check if current position is `@` followed by the identifier `name`
+    fn is_named_attribute(&self, name: &str) -> bool {
         if !matches!(self.peek_kind(), TokenKind::At) {
             return false;
         }
-        // Look ahead: position after @ should be ident "must_use"
         if let Some(tok) = self.tokens.get(self.pos + 1) {
-            matches!(&tok.kind, TokenKind::Ident(name) if name == "must_use")
+            matches!(&tok.kind, TokenKind::Ident(n) if n == name)
         } else {
             false
         }
@@ -589,6 +816,7 @@ impl Parser {
             | TokenKind::If
             | TokenKind::For
             | TokenKind::Match
+            | TokenKind::Switch
             | TokenKind::Return
             | TokenKind::Halt
             | TokenKind::While
@@ -666,6 +894,28 @@ impl Parser {
                 Ok(Item::Cell(c))
             }
             TokenKind::At => {
+                if self.is_packed_attribute() {
+                    self.advance(); // consume '@'
+                    self.advance(); // consume 'packed'
+                    self.skip_newlines();
+                    let is_pub = is_pub || matches!(self.peek_kind(), TokenKind::Pub);
+                    if matches!(self.peek_kind(), TokenKind::Pub) {
+                        self.advance();
+                    }
+                    let mut r = self.parse_record()?;
+                    r.is_pub = is_pub;
+                    r.packed = true;
+                    return Ok(Item::Record(r));
+                }
+                if self.is_named_attribute("specialize") {
+                    return self.parse_specialized_cell(is_pub);
+                }
+                if self.is_named_attribute("tailcall") {
+                    return self.parse_tailcall_cell(is_pub);
+                }
+                if self.is_named_attribute("memoize") {
+                    return self.parse_memoize_cell(is_pub);
+                }
                 // Check for @must_use before a cell definition
                 if self.is_must_use_attribute() {
                     self.advance(); // consume '@'
@@ -708,6 +958,14 @@ impl Parser {
             TokenKind::Trait => Ok(Item::Trait(self.parse_trait_def(is_pub)?)),
             TokenKind::Impl => Ok(Item::Impl(self.parse_impl_def()?)),
             TokenKind::Import => Ok(Item::Import(self.parse_import(is_pub)?)),
+            TokenKind::Const if matches!(self.peek_n_kind(1), Some(TokenKind::Cell)) => {
+                self.advance(); // consume const
+                let mut c = self.parse_cell(false)?;
+                c.is_pub = is_pub;
+                c.is_async = is_async;
+                c.is_const = true;
+                Ok(Item::Cell(c))
+            }
             TokenKind::Const => Ok(Item::ConstDecl(self.parse_const_decl()?)),
             TokenKind::Macro => Ok(Item::MacroDecl(self.parse_macro_decl()?)),
             TokenKind::Schema => {
@@ -796,6 +1054,8 @@ impl Parser {
         let generic_params = self.parse_optional_generic_params()?;
         self.skip_newlines();
         let mut fields = Vec::new();
+        // Attributes waiting for the field they precede
+        let mut pending = Vec::new();
         // Fields can be indent-based or just listed until 'end'
         let has_indent = matches!(self.peek_kind(), TokenKind::Indent);
         if has_indent {
@@ -812,9 +1072,16 @@ impl Parser {
                 continue;
             }
             if matches!(self.peek_kind(), TokenKind::At) {
-                let _ = self.parse_attribute_decl()?;
+                match self.parse_field_attribute()? {
+                    Some(attr) => pending.push(attr),
+                    None => {
+                        let _ = self.parse_attribute_decl()?;
+                    }
+                }
             } else if self.looks_like_named_field() {
-                fields.push(self.parse_field()?);
+                let mut field = self.parse_field()?;
+                field.attributes = std::mem::take(&mut pending);
+                fields.push(field);
             } else if matches!(self.peek_kind(), TokenKind::Ident(s) if s == "migrate") {
                 self.advance();
                 self.consume_block_until_end();
@@ -841,6 +1108,7 @@ impl Parser {
             span: start.merge(end_span),
             doc: None,
             deprecated: None,
+            packed: false,
         })
     }
 
@@ -874,9 +1142,56 @@ impl Parser {
             default_value,
             constraint,
             span,
+            attributes: vec![],
         })
     }
 
+    /// Parse an `@name` or `@name(arg, ...)` line before a record field, whose
+    /// arguments are string literals or identifiers. Returns `None`, consuming
+    /// nothing, for any other attribute.
+    fn parse_field_attribute(&mut self) -> Result<Option<FieldAttribute>, ParseError> {
+        let save = self.pos;
+        let start = self.expect(&TokenKind::At)?.span;
+        // `json` lexes as a type keyword, which `expect_ident` also accepts
+        let Ok(name) = self.expect_ident() else {
+            self.pos = save;
+            return Ok(None);
+        };
+        let mut args = Vec::new();
+        let mut end = start;
+        if matches!(self.peek_kind(), TokenKind::LParen) {
+            self.advance();
+            while !matches!(self.peek_kind(), TokenKind::RParen) {
+                match self.peek_kind().clone() {
+                    TokenKind::StringLit(s) => args.push(AttributeArg::Str(s)),
+                    TokenKind::Ident(s) => args.push(AttributeArg::Ident(s)),
+                    _ => {
+                        self.pos = save;
+                        return Ok(None);
+                    }
+                }
+                self.advance();
+                if matches!(self.peek_kind(), TokenKind::Comma) {
+                    self.advance();
+                }
+            }
+            end = self.expect(&TokenKind::RParen)?.span;
+        }
+        if !matches!(self.peek_kind(), TokenKind::Newline) {
+            self.pos = save;
+            return Ok(None);
+        }
+        let attr = FieldAttribute {
+            name,
+            args,
+            span: start.merge(end),
+        };
+        if attr.name == "json" {
+            validate_json_attribute(&attr)?;
+        }
+        Ok(Some(attr))
+    }
+
     // ── Enum ──
 
     fn parse_enum(&mut self) -> Result<EnumDef, ParseError> {
@@ -1021,14 +1336,28 @@ impl Parser {
 
     fn parse_cell(&mut self, require_body: bool) -> Result<CellDef, ParseError> {
         let start = self.expect(&TokenKind::Cell)?.span;
+        let (receiver, receiver_param) = if matches!(self.peek_kind(), TokenKind::LParen) {
+            let (mode, param) = self.parse_method_receiver()?;
+            (Some(mode), Some(param))
+        } else {
+            (None, None)
+        };
         let name = self.expect_ident()?;
+        let name = match &receiver_param {
+            Some(Param {
+                ty: TypeExpr::Named(type_name, _),
+                ..
+            }) => format!("{}.{}", type_name, name),
+            _ => name,
+        };
         let generic_params = self.parse_optional_generic_params()?;
         self.expect(&TokenKind::LParen)?;
         self.bracket_depth += 1;
-        let mut params = Vec::new();
+        let mut params: Vec<Param> = receiver_param.into_iter().collect();
+        let explicit_start = params.len();
         self.skip_whitespace_tokens();
         while !matches!(self.peek_kind(), TokenKind::RParen) {
-            if !params.is_empty() {
+            if params.len() > explicit_start {
                 self.expect(&TokenKind::Comma)?;
                 self.skip_whitespace_tokens();
             }
@@ -1085,11 +1414,16 @@ impl Parser {
                 is_pub: false,
                 is_async: false,
                 is_extern: false,
+                is_const: false,
                 must_use: false,
                 where_clauses: vec![],
                 span,
                 doc: None,
                 deprecated: None,
+                receiver,
+                specializations: vec![],
+                tailcall: false,
+                memoize: None,
             });
         }
 
@@ -1123,11 +1457,16 @@ impl Parser {
                     is_pub: false,
                     is_async: false,
                     is_extern: false,
+                    is_const: false,
                     must_use: false,
                     where_clauses: vec![],
                     span: start.merge(end_span),
                     doc: None,
                     deprecated: None,
+                    receiver,
+                    specializations: vec![],
+                    tailcall: false,
+                    memoize: None,
                 });
             }
         }
@@ -1147,14 +1486,53 @@ impl Parser {
             is_pub: false,
             is_async: false,
             is_extern: false,
+            is_const: false,
             must_use: false,
             where_clauses: vec![],
             span: start.merge(end_span),
             doc: None,
             deprecated: None,
+            receiver,
+            specializations: vec![],
+            tailcall: false,
+            memoize: None,
         })
     }
 
+    /// Parse the `(b: Body)` or `(mut b: Body)` receiver of a method declaration.
+    fn parse_method_receiver(&mut self) -> Result<(Receiver, Param), ParseError> {
+        self.expect(&TokenKind::LParen)?;
+        let mode = if matches!(self.peek_kind(), TokenKind::Mut) {
+            self.advance();
+            Receiver::Mut
+        } else {
+            Receiver::Value
+        };
+        let ps = self.current().span;
+        let pname = self.expect_ident()?;
+        self.expect(&TokenKind::Colon)?;
+        let pty = self.parse_type()?;
+        self.expect(&TokenKind::RParen)?;
+        if !matches!(pty, TypeExpr::Named(..)) {
+            return Err(ParseError::MalformedConstruct {
+                construct: "method".into(),
+                reason: "receiver type must be a record name".into(),
+                line: ps.line,
+                col: ps.col,
+            });
+        }
+        Ok((
+            mode,
+            Param {
+                name: pname,
+                ty: pty,
+                default_value: None,
+                variadic: false,
+                span: ps,
+            },
+        ))
+    }
+
     /// Check if the current token should terminate a block.
     /// When `block_depth > 0`, `Cell`/`Record`/`Enum` at column 1 (top-level
     /// indentation) are still terminators — they represent the start of the next
@@ -1227,13 +1605,17 @@ impl Parser {
                 self.advance();
                 continue;
             }
+            let start = self.pos;
+            let bracket_depth = self.bracket_depth;
             match self.parse_stmt() {
                 Ok(stmt) => stmts.push(stmt),
                 Err(err) => {
                     if self.record_error(err) {
                         break; // hit max error limit
                     }
-                    self.synchronize_stmt();
+                    // The error may have left brackets of the broken statement open.
+                    self.bracket_depth = bracket_depth;
+                    self.synchronize_stmt(start);
                 }
             }
             self.skip_newlines();
@@ -1275,13 +1657,17 @@ impl Parser {
             if matches!(self.peek_kind(), TokenKind::Dedent | TokenKind::Eof) {
                 break;
             }
+            let start = self.pos;
+            let bracket_depth = self.bracket_depth;
             match self.parse_stmt() {
                 Ok(stmt) => stmts.push(stmt),
                 Err(err) => {
                     if self.record_error(err) {
                         break; // hit max error limit
                     }
-                    self.synchronize_stmt();
+                    // The error may have left brackets of the broken statement open.
+                    self.bracket_depth = bracket_depth;
+                    self.synchronize_stmt(start);
                 }
             }
             self.skip_newlines();
@@ -1319,9 +1705,11 @@ impl Parser {
         }
         match self.peek_kind() {
             TokenKind::Let => self.parse_let(),
+            TokenKind::Ident(name) if self.at_var_binding(name) => self.parse_let(),
             TokenKind::If => self.parse_if(),
             TokenKind::For => self.parse_for(),
             TokenKind::Match => self.parse_match(),
+            TokenKind::Switch => self.parse_switch(),
             TokenKind::Return => self.parse_return(),
             TokenKind::Halt => self.parse_halt(),
             TokenKind::While => self.parse_while(),
@@ -1488,13 +1876,26 @@ impl Parser {
         Ok(Stmt::Emit(EmitStmt { value, span }))
     }
 
+    /// `var` is a contextual keyword: it starts a binding only when a name
+    /// follows, so `var` remains usable as a field or variable name.
+    fn at_var_binding(&self, name: &str) -> bool {
+        name == "var" && self.peek_n_kind(1).is_some_and(Self::is_identifier_like)
+    }
+
+    /// `let x = ...` binds immutably; `var x = ...` (or the deprecated
+    /// spelling `let mut x = ...`) binds a variable that can be reassigned.
     fn parse_let(&mut self) -> Result<Stmt, ParseError> {
-        let start = self.expect(&TokenKind::Let)?.span;
-        let mutable = if matches!(self.peek_kind(), TokenKind::Mut) {
-            self.advance();
-            true
+        let (start, mutable, is_var) = if matches!(self.peek_kind(), TokenKind::Let) {
+            let start = self.advance().span;
+            let mutable = if matches!(self.peek_kind(), TokenKind::Mut) {
+                self.advance();
+                true
+            } else {
+                false
+            };
+            (start, mutable, false)
         } else {
-            false
+            (self.advance().span, true, true)
         };
         let (name, pattern) = if matches!(self.peek_kind(), TokenKind::LParen | TokenKind::LBracket)
         {
@@ -1580,6 +1981,7 @@ impl Parser {
         Ok(Stmt::Let(LetStmt {
             name,
             mutable,
+            is_var,
             pattern,
             ty,
             value,
@@ -1645,6 +2047,7 @@ impl Parser {
             return Ok(Stmt::Match(MatchStmt {
                 subject,
                 arms,
+                is_switch: false,
                 span: start.merge(end_span),
             }));
         }
@@ -1721,6 +2124,9 @@ impl Parser {
         } else {
             None
         };
+        if self.is_three_clause_for() {
+            return self.parse_three_clause_for(start, label);
+        }
         let (var, pattern) = if matches!(self.peek_kind(), TokenKind::LParen) {
             // Tuple destructuring: for (k, v) in ...
             let pat = self.parse_pattern()?;
@@ -1761,6 +2167,53 @@ impl Parser {
         }))
     }
 
+    /// A `for` header is three-clause when a `;` appears on it outside
+    /// brackets (so `for x in [0; n]` is still a for-in loop).
+    fn is_three_clause_for(&self) -> bool {
+        let mut depth = 0usize;
+        let mut i = self.pos;
+        while let Some(tok) = self.tokens.get(i) {
+            match tok.kind {
+                TokenKind::LParen | TokenKind::LBracket | TokenKind::LBrace => depth += 1,
+                TokenKind::RParen | TokenKind::RBracket | TokenKind::RBrace => {
+                    depth = depth.saturating_sub(1)
+                }
+                TokenKind::Semicolon if depth == 0 => return true,
+                TokenKind::Newline | TokenKind::Indent | TokenKind::Eof if depth == 0 => {
+                    return false
+                }
+                _ => {}
+            }
+            i += 1;
+        }
+        false
+    }
+
+    /// `for init; cond; post ... end` becomes a `while` carrying `init` and
+    /// `post`; lowering runs `post` before every re-test of `cond`.
+    fn parse_three_clause_for(
+        &mut self,
+        start: Span,
+        label: Option<String>,
+    ) -> Result<Stmt, ParseError> {
+        let init = self.parse_stmt()?;
+        self.expect(&TokenKind::Semicolon)?;
+        let condition = self.parse_expr(0)?;
+        self.expect(&TokenKind::Semicolon)?;
+        let post = self.parse_stmt()?;
+        self.skip_newlines();
+        let body = self.parse_block()?;
+        let end_span = self.expect(&TokenKind::End)?.span;
+        Ok(Stmt::While(WhileStmt {
+            label,
+            init: Some(Box::new(init)),
+            condition,
+            body,
+            post: Some(Box::new(post)),
+            span: start.merge(end_span),
+        }))
+    }
+
     fn parse_match(&mut self) -> Result<Stmt, ParseError> {
         let start = self.expect(&TokenKind::Match)?.span;
         let subject = self.parse_expr(0)?;
@@ -1835,10 +2288,137 @@ impl Parser {
         Ok(Stmt::Match(MatchStmt {
             subject,
             arms,
+            is_switch: false,
             span: start.merge(end_span),
         }))
     }
 
+    /// `switch` over Int or String values. Each `case` lists one or more
+    /// literal values; the first matching case runs and control leaves the
+    /// switch (no fall-through). `default` runs when nothing matches.
+    ///
+    /// Desugars to a match over literal patterns with a trailing wildcard
+    /// arm, so lowering can pick a jump table for dense integer cases.
+    fn parse_switch(&mut self) -> Result<Stmt, ParseError> {
+        let start = self.expect(&TokenKind::Switch)?.span;
+        let subject = self.parse_expr(0)?;
+        self.skip_newlines();
+        let mut arms = Vec::new();
+        let mut default_body: Option<Vec<Stmt>> = None;
+        let has_indent = matches!(self.peek_kind(), TokenKind::Indent);
+        if has_indent {
+            self.advance();
+        }
+        self.skip_newlines();
+        while !matches!(self.peek_kind(), TokenKind::End | TokenKind::Eof) {
+            self.skip_newlines();
+            if matches!(self.peek_kind(), TokenKind::Dedent) {
+                self.advance();
+                continue;
+            }
+            if matches!(self.peek_kind(), TokenKind::End | TokenKind::Eof) {
+                break;
+            }
+            let arm_start = self.current().span;
+            let keyword = match self.peek_kind() {
+                TokenKind::Ident(name) if name == "case" || name == "default" => name.clone(),
+                other => {
+                    return Err(ParseError::Unexpected {
+                        found: format!("{}", other),
+                        expected: "'case' or 'default'".into(),
+                        line: arm_start.line,
+                        col: arm_start.col,
+                    })
+                }
+            };
+            self.advance();
+            if keyword == "default" {
+                if default_body.is_some() {
+                    return Err(ParseError::MalformedConstruct {
+                        construct: "switch".into(),
+                        reason: "duplicate 'default' arm".into(),
+                        line: arm_start.line,
+                        col: arm_start.col,
+                    });
+                }
+                default_body = Some(self.parse_switch_arm_body()?);
+                self.skip_newlines();
+                continue;
+            }
+            let mut patterns = vec![self.parse_switch_case_value()?];
+            while matches!(self.peek_kind(), TokenKind::Comma) {
+                self.advance();
+                patterns.push(self.parse_switch_case_value()?);
+            }
+            let pattern = if patterns.len() == 1 {
+                patterns.pop().unwrap()
+            } else {
+                Pattern::Or {
+                    patterns,
+                    span: arm_start,
+                }
+            };
+            let body = self.parse_switch_arm_body()?;
+            let arm_span = arm_start.merge(body.last().map(|s| s.span()).unwrap_or(arm_start));
+            arms.push(MatchArm {
+                pattern,
+                body,
+                span: arm_span,
+            });
+            self.skip_newlines();
+        }
+        if has_indent && matches!(self.peek_kind(), TokenKind::Dedent) {
+            self.advance();
+        }
+        self.skip_newlines();
+        let end_span = self.expect(&TokenKind::End)?.span;
+        // A switch without `default` simply does nothing when no case matches.
+        arms.push(MatchArm {
+            pattern: Pattern::Wildcard(start),
+            body: default_body.unwrap_or_default(),
+            span: start,
+        });
+        Ok(Stmt::Match(MatchStmt {
+            subject,
+            arms,
+            is_switch: true,
+            span: start.merge(end_span),
+        }))
+    }
+
+    fn parse_switch_case_value(&mut self) -> Result<Pattern, ParseError> {
+        let value = self.parse_expr(0)?;
+        let is_literal = match &value {
+            Expr::IntLit(..) | Expr::StringLit(..) => true,
+            Expr::UnaryOp(UnaryOp::Neg, inner, _) => matches!(inner.as_ref(), Expr::IntLit(..)),
+            _ => false,
+        };
+        if !is_literal {
+            let span = value.span();
+            return Err(ParseError::MalformedConstruct {
+                construct: "switch".into(),
+                reason: "case values must be integer or string literals".into(),
+                line: span.line,
+                col: span.col,
+            });
+        }
+        Ok(Pattern::Literal(value))
+    }
+
+    fn parse_switch_arm_body(&mut self) -> Result<Vec<Stmt>, ParseError> {
+        if matches!(self.peek_kind(), TokenKind::Arrow) {
+            // Single-line arm: `case 1 -> stmt`
+            self.advance();
+            return Ok(vec![self.parse_stmt()?]);
+        }
+        self.skip_newlines();
+        if matches!(self.peek_kind(), TokenKind::Indent) {
+            self.parse_block_strict_dedent()
+        } else {
+            Ok(Vec::new())
+        }
+    }
+
     fn parse_pattern(&mut self) -> Result<Pattern, ParseError> {
         if matches!(self.peek_kind(), TokenKind::LParen) {
             let s = self.advance().span;
@@ -1898,9 +2478,12 @@ impl Parser {
                 }
                 if matches!(self.peek_kind(), TokenKind::DotDot | TokenKind::DotDotDot) {
                     self.advance();
-                    if matches!(self.peek_kind(), TokenKind::Ident(_)) {
-                        rest = Some(self.expect_ident()?);
-                    }
+                    // A bare `..` still allows more elements; `_` binds nothing
+                    rest = Some(if matches!(self.peek_kind(), TokenKind::Ident(_)) {
+                        self.expect_ident()?
+                    } else {
+                        "_".to_string()
+                    });
                     while !matches!(self.peek_kind(), TokenKind::RBracket | TokenKind::Eof) {
                         if matches!(self.peek_kind(), TokenKind::Comma) {
                             self.advance();
@@ -1910,7 +2493,16 @@ impl Parser {
                     }
                     continue;
                 }
-                elements.push(self.parse_pattern()?);
+                let elem = self.parse_pattern()?;
+                // `[head, tail..]` spells the rest binding with a trailing `..`
+                if let Pattern::Ident(name, _) = &elem {
+                    if matches!(self.peek_kind(), TokenKind::DotDot | TokenKind::DotDotDot) {
+                        self.advance();
+                        rest = Some(name.clone());
+                        continue;
+                    }
+                }
+                elements.push(elem);
             }
             self.expect(&TokenKind::RBracket)?;
             return Ok(Pattern::ListDestructure {
@@ -2172,6 +2764,7 @@ impl Parser {
                         span: start,
                     },
                 ],
+                is_switch: false,
                 span: start,
             });
 
@@ -2188,8 +2781,10 @@ impl Parser {
         let end_span = self.expect(&TokenKind::End)?.span;
         Ok(Stmt::While(WhileStmt {
             label,
+            init: None,
             condition: cond,
             body,
+            post: None,
             span: start.merge(end_span),
         }))
     }
@@ -2550,6 +3145,84 @@ impl Parser {
         })
     }
 
+    /// Parse a `const` block, one constant per line up to `end`. `iota` is the
+    /// line's index in the block, from 0, and a line holding only a name
+    /// repeats the type and expression of the line before it.
+    fn parse_const_block(&mut self) -> Result<Vec<ConstDeclDef>, ParseError> {
+        let start = self.expect(&TokenKind::Const)?.span;
+        let mut consts = Vec::new();
+        // Token range of the last explicit `: Type = expr`
+        let mut template: Option<(usize, usize)> = None;
+        self.skip_newlines();
+        while !matches!(self.peek_kind(), TokenKind::End | TokenKind::Eof) {
+            if matches!(
+                self.peek_kind(),
+                TokenKind::Indent | TokenKind::Dedent | TokenKind::Newline
+            ) {
+                self.advance();
+                continue;
+            }
+            let name_span = self.current().span;
+            let name = self.expect_ident()?;
+            self.iota = Some(consts.len() as i64);
+            let parsed = if matches!(self.peek_kind(), TokenKind::Colon | TokenKind::Assign) {
+                let from = self.pos;
+                let parsed = self.parse_const_value();
+                template = Some((from, self.pos));
+                parsed.map(|(ty, value)| {
+                    let span = name_span.merge(value.span());
+                    (ty, value, span)
+                })
+            } else if let Some((from, _)) = template {
+                let resume = self.pos;
+                self.pos = from;
+                let parsed = self.parse_const_value();
+                self.pos = resume;
+                parsed.map(|(ty, value)| (ty, value, name_span))
+            } else {
+                Err(ParseError::MalformedConstruct {
+                    construct: "const block".into(),
+                    reason: format!(
+                        "`{}` needs a value: nothing comes before it to repeat",
+                        name
+                    ),
+                    line: name_span.line,
+                    col: name_span.col,
+                })
+            };
+            self.iota = None;
+            let (type_ann, value, span) = parsed?;
+            consts.push(ConstDeclDef {
+                name,
+                type_ann,
+                value,
+                span,
+            });
+        }
+        self.expect(&TokenKind::End)?;
+        if consts.is_empty() {
+            return Err(ParseError::MalformedConstruct {
+                construct: "const block".into(),
+                reason: "expected at least one constant".into(),
+                line: start.line,
+                col: start.col,
+            });
+        }
+        Ok(consts)
+    }
+
+    /// The `: Type = expr` part of a constant.
+    fn parse_const_value(&mut self) -> Result<(Option<TypeExpr>, Expr), ParseError> {
+        let type_ann = if matches!(self.peek_kind(), TokenKind::Colon) {
+            self.advance();
+            Some(self.parse_type()?)
+        } else {
+            None
+        };
+        self.expect(&TokenKind::Assign)?;
+        Ok((type_ann, self.parse_expr(0)?))
+    }
+
     fn parse_macro_decl(&mut self) -> Result<MacroDeclDef, ParseError> {
         let start = self.expect(&TokenKind::Macro)?.span;
         let mut name = if matches!(self.peek_kind(), TokenKind::Ident(_)) {
@@ -3502,11 +4175,16 @@ impl Parser {
                 is_pub: false,
                 is_async: false,
                 is_extern: false,
+                is_const: false,
                 must_use: false,
                 where_clauses: vec![],
                 span,
                 doc: None,
                 deprecated: None,
+                receiver: None,
+                specializations: vec![],
+                tailcall: false,
+                memoize: None,
             });
         }
 
@@ -3536,11 +4214,16 @@ impl Parser {
                     is_pub: false,
                     is_async: false,
                     is_extern: false,
+                    is_const: false,
                     must_use: false,
                     where_clauses: vec![],
                     span: start.merge(end_span),
                     doc: None,
                     deprecated: None,
+                    receiver: None,
+                    specializations: vec![],
+                    tailcall: false,
+                    memoize: None,
                 });
             }
         }
@@ -3558,11 +4241,16 @@ impl Parser {
             is_pub: false,
             is_async: false,
             is_extern: false,
+            is_const: false,
             must_use: false,
             where_clauses: vec![],
             span: start.merge(end_span),
             doc: None,
             deprecated: None,
+            receiver: None,
+            specializations: vec![],
+            tailcall: false,
+            memoize: None,
         })
     }
 
@@ -3764,6 +4452,7 @@ impl Parser {
                 | TokenKind::If
                 | TokenKind::For
                 | TokenKind::Match
+                | TokenKind::Switch
                 | TokenKind::While
                 | TokenKind::Loop => {
                     depth += 1;
@@ -4109,6 +4798,22 @@ impl Parser {
             }
         }
 
+        // Postfix step: `x++` / `count[r]--` desugar to `+= 1` / `-= 1`.
+        if let Some((op, len)) = self.postfix_step() {
+            if let Some(target) = Self::expr_to_assign_target(&expr) {
+                let mut op_span = self.current().span;
+                for _ in 0..len {
+                    op_span = op_span.merge(self.advance().span);
+                }
+                return Ok(Stmt::CompoundAssign(CompoundAssignStmt {
+                    target,
+                    op,
+                    value: Expr::IntLit(1, op_span),
+                    span: start_span.merge(op_span),
+                }));
+            }
+        }
+
         let mut span = start_span;
         if matches!(self.peek_kind(), TokenKind::In) {
             self.advance();
@@ -4143,6 +4848,64 @@ impl Parser {
         }
     }
 
+    /// Convert `if c then a else b` (or the block form with one expression
+    /// per branch, `else if` chains included) into an `Expr::IfExpr`.
+    fn if_stmt_as_expr(stmt: &Stmt) -> Option<Expr> {
+        let Stmt::If(ifs) = stmt else {
+            return None;
+        };
+        let then_val = match ifs.then_body.as_slice() {
+            [Stmt::Expr(es)] => es.expr.clone(),
+            _ => return None,
+        };
+        let else_val = match ifs.else_body.as_deref()? {
+            [Stmt::Expr(es)] => es.expr.clone(),
+            [elif @ Stmt::If(_)] => Self::if_stmt_as_expr(elif)?,
+            _ => return None,
+        };
+        Some(Expr::IfExpr {
+            cond: Box::new(ifs.condition.clone()),
+            then_val: Box::new(then_val),
+            else_val: Box::new(else_val),
+            span: ifs.span,
+        })
+    }
+
+    /// Check for a postfix `++` or `--` that ends the statement, returning
+    /// the compound op it stands for and how many tokens it spans. `--` is
+    /// two adjacent `-` tokens, so `a - -b` and `a--b` still subtract.
+    fn postfix_step(&self) -> Option<(CompoundOp, usize)> {
+        if self.bracket_depth > 0 {
+            return None;
+        }
+        let (op, len) = match self.peek_kind() {
+            TokenKind::PlusPlus => (CompoundOp::AddAssign, 1),
+            TokenKind::Minus => {
+                let first = self.current().span;
+                let second = self.tokens.get(self.pos + 1)?;
+                if !matches!(second.kind, TokenKind::Minus)
+                    || second.span.line != first.line
+                    || second.span.col != first.col + 1
+                {
+                    return None;
+                }
+                (CompoundOp::SubAssign, 2)
+            }
+            _ => return None,
+        };
+        match self.peek_n_kind(len) {
+            None
+            | Some(
+                TokenKind::Newline
+                | TokenKind::Dedent
+                | TokenKind::Eof
+                | TokenKind::Semicolon
+                | TokenKind::End,
+            ) => Some((op, len)),
+            _ => None,
+        }
+    }
+
     /// Check if a token kind is a compound assignment operator and return
     /// the corresponding `CompoundOp`.
     fn peek_compound_op(kind: &TokenKind) -> Option<CompoundOp> {
@@ -4535,6 +5298,10 @@ impl Parser {
                     }
                 }
             }
+            // A trailing `++` / `--` is a postfix step for parse_expr_stmt.
+            if self.postfix_step().is_some() {
+                break;
+            }
             let kind = self.peek_kind();
             let (op, bp) = match kind {
                 TokenKind::Plus => (BinOp::Add, (22, 23)),
@@ -4593,14 +5360,30 @@ impl Parser {
                 TokenKind::And => (BinOp::And, (12, 13)),
                 TokenKind::Or => (BinOp::Or, (10, 11)),
                 TokenKind::PlusPlus => (BinOp::Concat, (18, 19)),
-                // Pipe |> and step: produce Expr::Pipe
+                // Pipe |> and step: `a..b step n` sets the range step,
+                // otherwise produce Expr::Pipe
                 TokenKind::PipeForward | TokenKind::Step => {
                     if min_bp > 16 {
                         break;
                     }
+                    let is_step = matches!(self.peek_kind(), TokenKind::Step);
                     self.advance();
                     let rhs = self.parse_expr(17)?;
                     let span = lhs.span().merge(rhs.span());
+                    if is_step {
+                        if let Expr::RangeExpr {
+                            step,
+                            span: range_span,
+                            ..
+                        } = &mut lhs
+                        {
+                            if step.is_none() {
+                                *step = Some(Box::new(rhs));
+                                *range_span = span;
+                                continue;
+                            }
+                        }
+                    }
                     lhs = Expr::Pipe {
                         left: Box::new(lhs),
                         right: Box::new(rhs),
@@ -4864,6 +5647,10 @@ impl Parser {
                 let s = self.advance().span;
                 Ok(Expr::IntLit(n, s))
             }
+            TokenKind::Ident(ref name) if name == "iota" && self.iota.is_some() => {
+                let s = self.advance().span;
+                Ok(Expr::IntLit(self.iota.unwrap_or(0), s))
+            }
             TokenKind::BigIntLit(ref n) => {
                 let n = n.clone();
                 let s = self.advance().span;
@@ -5123,8 +5910,13 @@ impl Parser {
                 }
             }
             TokenKind::If => {
-                // Reuse the statement-level if parser, wrap result as block expr
+                // Reuse the statement-level if parser. An if/else whose
+                // branches are single expressions is a conditional value;
+                // anything else is wrapped as a block expr.
                 let stmt = self.parse_if()?;
+                if let Some(expr) = Self::if_stmt_as_expr(&stmt) {
+                    return Ok(expr);
+                }
                 let span = stmt.span();
                 Ok(Expr::BlockExpr(vec![stmt], span))
             }
@@ -5702,6 +6494,20 @@ impl Parser {
                 span: start.merge(end),
             });
         }
+        // Repeated fill: [value; count]
+        if matches!(self.peek_kind(), TokenKind::Semicolon) {
+            self.advance();
+            self.skip_whitespace_tokens();
+            let count = self.parse_expr(0)?;
+            self.skip_whitespace_tokens();
+            self.bracket_depth -= 1;
+            let end = self.expect(&TokenKind::RBracket)?.span;
+            return Ok(Expr::ListRepeat(
+                Box::new(first),
+                Box::new(count),
+                start.merge(end),
+            ));
+        }
         // Regular list
         let mut elems = vec![first];
         while matches!(self.peek_kind(), TokenKind::Comma) {
@@ -6364,6 +7170,10 @@ impl Parser {
                 self.advance();
                 Ok("match".into())
             }
+            TokenKind::Switch => {
+                self.advance();
+                Ok("switch".into())
+            }
             TokenKind::Loop => {
                 self.advance();
                 Ok("loop".into())
@@ -6729,6 +7539,86 @@ mod tests {
         }
     }
 
+    #[test]
+    fn test_parse_record_field_attributes() {
+        let src = "record User\n  @json(\"first_name\")\n  name: String\n  @json(\"tags\", omit_empty)\n  tags: list[String]\n  @json(skip)\n  token: String = \"\"\n  age: Int\nend";
+        let prog = parse_src(src).unwrap();
+        let Item::Record(r) = &prog.items[0] else {
+            panic!("expected record");
+        };
+        let args: Vec<_> = r.fields.iter().map(|f| f.attributes.clone()).collect();
+        assert_eq!(args[0][0].name, "json");
+        assert_eq!(
+            args[0][0].args,
+            vec![AttributeArg::Str("first_name".into())]
+        );
+        assert_eq!(
+            args[1][0].args,
+            vec![
+                AttributeArg::Str("tags".into()),
+                AttributeArg::Ident("omit_empty".into())
+            ]
+        );
+        assert_eq!(args[2][0].args, vec![AttributeArg::Ident("skip".into())]);
+        assert!(args[3].is_empty());
+    }
+
+    #[test]
+    fn test_parse_malformed_json_attributes() {
+        for attr in [
+            "@json()",
+            "@json(\"\")",
+            "@json(skip, \"key\")",
+            "@json(omitempty)",
+        ] {
+            let src = format!("record User\n  {}\n  name: String\nend", attr);
+            let tokens = Lexer::new(&src, 1, 0).tokenize().unwrap();
+            let (_, errors) = Parser::new(tokens).parse_program_with_recovery(vec![]);
+            assert!(
+                matches!(
+                    errors.first(),
+                    Some(ParseError::MalformedConstruct { construct, .. }) if construct == "@json attribute"
+                ),
+                "{} should be rejected, got {:?}",
+                attr,
+                errors
+            );
+        }
+    }
+
+    #[test]
+    fn test_parse_const_block_substitutes_iota() {
+        let prog = parse_src("const\n  A = iota\n  B\n  C: Int = 1 << iota\n  D\nend").unwrap();
+        let consts: Vec<_> = prog
+            .items
+            .iter()
+            .map(|item| match item {
+                Item::ConstDecl(c) => c,
+                _ => panic!("expected const"),
+            })
+            .collect();
+        let names: Vec<_> = consts.iter().map(|c| c.name.as_str()).collect();
+        assert_eq!(names, ["A", "B", "C", "D"]);
+        assert!(matches!(consts[1].value, Expr::IntLit(1, _)));
+        assert!(consts[3].type_ann.is_some());
+        match &consts[3].value {
+            Expr::BinOp(_, BinOp::Shl, rhs, _) => assert!(matches!(**rhs, Expr::IntLit(3, _))),
+            other => panic!("expected a shift, got {:?}", other),
+        }
+    }
+
+    #[test]
+    fn test_parse_const_block_first_line_needs_value() {
+        let tokens = Lexer::new("const\n  A\n  B = iota\nend", 1, 0)
+            .tokenize()
+            .unwrap();
+        let (_, errors) = Parser::new(tokens).parse_program_with_recovery(vec![]);
+        assert!(matches!(
+            errors.first(),
+            Some(ParseError::MalformedConstruct { construct, .. }) if construct == "const block"
+        ));
+    }
+
     #[test]
     fn test_parse_cell() {
         let prog = parse_src("cell add(a: Int, b: Int) -> Int\n  return a + b\nend").unwrap();
@@ -7185,6 +8075,34 @@ end"#;
         assert_eq!(fields[1].0, "y");
     }
 
+    #[test]
+    fn test_var_binds_mutably_and_stays_an_identifier() {
+        let src = r#"cell test(var: Int) -> Int
+  var total: Int = var
+  let mut n = 1
+  var = var + n
+  return total
+end"#;
+        let prog = parse_src(src).unwrap();
+        let Item::Cell(c) = &prog.items[0] else {
+            panic!("expected cell");
+        };
+        assert_eq!(c.params[0].name, "var");
+        let Stmt::Let(total) = &c.body[0] else {
+            panic!("expected let");
+        };
+        assert_eq!(total.name, "total");
+        assert!(total.mutable && total.is_var);
+        let Stmt::Let(n) = &c.body[1] else {
+            panic!("expected let");
+        };
+        assert!(n.mutable && !n.is_var);
+        assert!(matches!(
+            &c.body[2],
+            Stmt::Assign(a) if matches!(&a.target, AssignTarget::Variable(v) if v == "var")
+        ));
+    }
+
     #[test]
     fn test_for_loop_simple_var_no_pattern() {
         let src = r#"cell test(items: list[Int]) -> Int
@@ -7758,6 +8676,116 @@ end
         }
     }
 
+    fn recovery_messages(src: &str) -> (Program, Vec<String>) {
+        let mut lexer = Lexer::new(src, 1, 0);
+        let tokens = lexer.tokenize().unwrap();
+        let (program, errors) = parse_with_recovery(tokens, vec![]);
+        (program, errors.iter().map(|e| e.to_string()).collect())
+    }
+
+    #[test]
+    fn test_recovery_two_errors_in_expression_statements() {
+        // The second error is on a line that starts with no keyword, which
+        // recovery used to skip along with the first broken statement.
+        let src = "\
+cell main() -> Int
+  let a = 1 +* 2
+  print(a))
+  return a
+end
+";
+        let (program, errors) = recovery_messages(src);
+        assert_eq!(
+            errors,
+            [
+                "unexpected token * at line 2, col 14; expected expression",
+                "unexpected token ) at line 3, col 11; expected expression",
+            ]
+        );
+        let Item::Cell(main) = &program.items[0] else {
+            panic!("expected cell, got {:?}", program.items[0]);
+        };
+        assert!(matches!(main.body.last(), Some(Stmt::Return(_))));
+    }
+
+    #[test]
+    fn test_recovery_two_errors_in_call_arguments() {
+        let src = "\
+cell main() -> Int
+  print(1 2)
+  print(3 4)
+  return 0
+end
+";
+        let (_, errors) = recovery_messages(src);
+        assert_eq!(
+            errors,
+            [
+                "unexpected token 2 at line 2, col 11; expected ,",
+                "unexpected token 4 at line 3, col 11; expected ,",
+            ]
+        );
+    }
+
+    #[test]
+    fn test_recovery_skips_body_of_broken_block_header() {
+        // The `if` header is broken; its body, `else` branch and `end` belong
+        // to it, so the cell must not end at that `end`.
+        let src = "\
+cell main() -> Int
+  if a ==
+    print(1)
+  else
+    print(2)
+  end
+  x = = 3
+  return 0
+end
+
+cell after() -> Int
+  return 1
+end
+";
+        let (program, errors) = recovery_messages(src);
+        assert_eq!(
+            errors,
+            [
+                "unexpected token NEWLINE at line 2, col 10; expected expression",
+                "unexpected token = at line 7, col 7; expected expression",
+            ]
+        );
+        let names: Vec<_> = program
+            .items
+            .iter()
+            .filter_map(|item| match item {
+                Item::Cell(c) => Some((c.name.as_str(), c.body.len())),
+                _ => None,
+            })
+            .collect();
+        assert_eq!(names, [("main", 1), ("after", 1)]);
+    }
+
+    #[test]
+    fn test_recovery_multiline_call_resumes_after_closing_paren() {
+        let src = "\
+cell main() -> Int
+  let total = add(1,
+    2 3,
+    4)
+  let bad = )
+  return total
+end
+";
+        let (_, errors) = recovery_messages(src);
+        assert_eq!(
+            errors,
+            [
+                "unexpected token 3 at line 3, col 7; expected ,",
+                "unexpected token ) at line 5, col 13; expected expression",
+            ]
+        );
+    }
+
     #[test]
     fn test_chained_comparison() {
         // `0 < x < 100` should desugar to `(0 < x) and (x < 100)`
@@ -414,6 +414,7 @@ mod tests {
                 effects: vec![],
                 generic_params: vec![],
                 must_use: false,
+                receiver: None,
             },
         );
         let locals = HashMap::new();
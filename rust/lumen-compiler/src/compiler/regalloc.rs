@@ -159,6 +159,11 @@ impl RegAlloc {
         self.bindings.get(name).map(|&r| r as u8)
     }
 
+    /// Whether a name in scope is bound to `reg`
+    pub fn is_bound(&self, reg: u8) -> bool {
+        self.bindings.values().any(|&r| r == reg as u16)
+    }
+
     /// Get the maximum register count used.
     /// This returns the high-water mark of register usage, which is
     /// what's needed for the VM to allocate sufficient register space.
@@ -197,6 +202,18 @@ impl RegAlloc {
         self.bindings.remove(name);
     }
 
+    /// Snapshot the named bindings before lowering a nested block.
+    pub fn save_bindings(&self) -> HashMap<String, u16> {
+        self.bindings.clone()
+    }
+
+    /// End a nested block: names it bound go out of scope and names it
+    /// shadowed refer to the outer registers again. The block's registers
+    /// stay reserved, since a closure it created may still read them.
+    pub fn restore_bindings(&mut self, saved: HashMap<String, u16>) {
+        self.bindings = saved;
+    }
+
     /// Get the current next_reg value (for manual tracking)
     pub fn current_reg_count(&self) -> u8 {
         self.next_reg as u8
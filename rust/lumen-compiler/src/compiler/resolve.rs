@@ -1,6 +1,7 @@
 //! Name resolution pass — resolve cells, types, and tool aliases.
 
 use crate::compiler::ast::*;
+use crate::compiler::tokens::Span;
 use std::collections::{BTreeSet, HashMap, HashSet};
 use thiserror::Error;
 
@@ -345,6 +346,8 @@ pub struct CellInfo {
     /// Generic type parameter names (e.g. ["T", "U"])
     pub generic_params: Vec<String>,
     pub must_use: bool,
+    /// Receiver mode for methods declared as `cell (b: Body) name(...)`
+    pub receiver: Option<Receiver>,
 }
 
 #[derive(Debug, Clone)]
@@ -408,6 +411,8 @@ pub struct TraitInfo {
     pub name: String,
     pub parent_traits: Vec<String>,
     pub methods: Vec<String>,
+    /// Declared signature of each method, keyed by method name
+    pub signatures: HashMap<String, CellInfo>,
 }
 
 #[derive(Debug, Clone)]
@@ -436,12 +441,56 @@ impl Default for SymbolTable {
     }
 }
 
+/// Builtin traits that overload operators on user types, as
+/// (trait, method, operator). `!=` is the negation of `Eq.eq`.
+pub const OPERATOR_TRAITS: &[(&str, &str, &str)] = &[
+    ("Add", "add", "+"),
+    ("Sub", "sub", "-"),
+    ("Mul", "mul", "*"),
+    ("Div", "div", "/"),
+    ("Eq", "eq", "=="),
+];
+
+/// Register the operator traits: each has one method
+/// `cell m(self: Self, other: Self) -> Self`, returning Bool for `Eq`.
+fn builtin_operator_traits() -> HashMap<String, TraitInfo> {
+    let self_ty = || TypeExpr::Named("Self".to_string(), Span::dummy());
+    OPERATOR_TRAITS
+        .iter()
+        .map(|&(name, method, _)| {
+            let return_type = if name == "Eq" {
+                TypeExpr::Named("Bool".to_string(), Span::dummy())
+            } else {
+                self_ty()
+            };
+            let sig = CellInfo {
+                params: vec![
+                    ("self".to_string(), self_ty(), false),
+                    ("other".to_string(), self_ty(), false),
+                ],
+                return_type: Some(return_type),
+                effects: vec![],
+                generic_params: vec![],
+                must_use: false,
+                receiver: Some(Receiver::Value),
+            };
+            let info = TraitInfo {
+                name: name.to_string(),
+                parent_traits: vec![],
+                methods: vec![method.to_string()],
+                signatures: HashMap::from([(method.to_string(), sig)]),
+            };
+            (name.to_string(), info)
+        })
+        .collect()
+}
+
 impl SymbolTable {
     pub fn new() -> Self {
         let mut types = HashMap::new();
         // Register builtin types
         for name in &[
-            "String", "Int", "Float", "Bool", "Bytes", "Json", "Null", "Self", "Any",
+            "String", "Int", "Float", "Bool", "Bytes", "Json", "Null", "Self", "Any", "Never",
         ] {
             types.insert(
                 name.to_string(),
@@ -451,6 +500,14 @@ impl SymbolTable {
                 },
             );
         }
+        // `Weak[T]`, made by `weak(v)` and read back with `upgrade()`
+        types.insert(
+            "Weak".to_string(),
+            TypeInfo {
+                kind: TypeInfoKind::Builtin,
+                generic_params: vec!["T".to_string()],
+            },
+        );
         Self {
             types,
             cells: HashMap::new(),
@@ -463,7 +520,7 @@ impl SymbolTable {
             handlers: HashMap::new(),
             addons: Vec::new(),
             type_aliases: HashMap::new(),
-            traits: HashMap::new(),
+            traits: builtin_operator_traits(),
             impls: Vec::new(),
             consts: HashMap::new(),
         }
@@ -607,6 +664,7 @@ fn register_local_defs_in_body(
                                 .map(|gp| gp.name.clone())
                                 .collect(),
                             must_use: c.must_use,
+                            receiver: None,
                         });
                     }
                 }
@@ -721,6 +779,7 @@ fn resolve_with_base_inner(
                         effects: c.effects.clone(),
                         generic_params: c.generic_params.iter().map(|gp| gp.name.clone()).collect(),
                         must_use: c.must_use,
+                        receiver: c.receiver,
                     });
                 }
             },
@@ -774,6 +833,7 @@ fn resolve_with_base_inner(
                                 span: a.span,
                                 doc: None,
                                 deprecated: None,
+                                packed: false,
                             }),
                             generic_params: vec![],
                         });
@@ -789,6 +849,7 @@ fn resolve_with_base_inner(
                             effects: vec![],
                             generic_params: vec![],
                             must_use: false,
+                            receiver: None,
                         },
                     );
                 }
@@ -817,6 +878,7 @@ fn resolve_with_base_inner(
                                     .map(|gp| gp.name.clone())
                                     .collect(),
                                 must_use: cell.must_use,
+                                receiver: None,
                             });
                         }
                     }
@@ -904,6 +966,7 @@ fn resolve_with_base_inner(
                                 span: p.span,
                                 doc: None,
                                 deprecated: None,
+                                packed: false,
                             }),
                             generic_params: vec![],
                         });
@@ -918,6 +981,7 @@ fn resolve_with_base_inner(
                             effects: vec![],
                             generic_params: vec![],
                             must_use: false,
+                            receiver: None,
                         },
                     );
                 }
@@ -937,6 +1001,7 @@ fn resolve_with_base_inner(
                             .map(|gp| gp.name.clone())
                             .collect(),
                         must_use: cell.must_use,
+                        receiver: None,
                     });
                 }
                 for g in &p.grants {
@@ -977,6 +1042,7 @@ fn resolve_with_base_inner(
                             .map(|gp| gp.name.clone())
                             .collect(),
                         must_use: false,
+                        receiver: None,
                     });
                 }
             }
@@ -1021,6 +1087,7 @@ fn resolve_with_base_inner(
                             .map(|gp| gp.name.clone())
                             .collect(),
                         must_use: false,
+                        receiver: None,
                     });
                 }
             }
@@ -1063,6 +1130,29 @@ fn resolve_with_base_inner(
             }
             Item::Trait(t) => {
                 let methods: Vec<String> = t.methods.iter().map(|m| m.name.clone()).collect();
+                let signatures = t
+                    .methods
+                    .iter()
+                    .map(|m| {
+                        let info = CellInfo {
+                            params: m
+                                .params
+                                .iter()
+                                .map(|p| (p.name.clone(), p.ty.clone(), p.variadic))
+                                .collect(),
+                            return_type: m.return_type.clone(),
+                            effects: m.effects.clone(),
+                            generic_params: m
+                                .generic_params
+                                .iter()
+                                .map(|gp| gp.name.clone())
+                                .collect(),
+                            must_use: m.must_use,
+                            receiver: self_receiver(m),
+                        };
+                        (m.name.clone(), info)
+                    })
+                    .collect();
                 match table.traits.entry(t.name.clone()) {
                     Entry::Occupied(_) => {
                         errors.push(ResolveError::Duplicate {
@@ -1075,6 +1165,7 @@ fn resolve_with_base_inner(
                             name: t.name.clone(),
                             parent_traits: t.parent_traits.clone(),
                             methods,
+                            signatures,
                         });
                     }
                 }
@@ -1152,6 +1243,7 @@ fn resolve_with_base_inner(
                             effects: method.effects.clone(),
                             generic_params: method_generic_params,
                             must_use: method.must_use,
+                            receiver: self_receiver(method),
                         });
                     }
                 }
@@ -1547,6 +1639,43 @@ fn resolve_with_base_inner(
     (table, errors)
 }
 
+/// Trait and impl methods whose first parameter is `self` are called with
+/// method syntax and receive the value they are called on.
+fn self_receiver(method: &CellDef) -> Option<Receiver> {
+    match method.params.first() {
+        Some(p) if p.name == "self" => Some(Receiver::Value),
+        _ => None,
+    }
+}
+
+/// Whether `type_name` implements `trait_name`, directly or through an impl
+/// of a trait that extends it.
+pub fn implements_trait(table: &SymbolTable, type_name: &str, trait_name: &str) -> bool {
+    table
+        .impls
+        .iter()
+        .filter(|i| i.target_type.split('[').next() == Some(type_name))
+        .filter_map(|i| i.trait_name.as_deref())
+        .any(|t| trait_extends(table, t, trait_name))
+}
+
+/// Whether `trait_name` is `ancestor` or extends it through parent traits.
+pub fn trait_extends(table: &SymbolTable, trait_name: &str, ancestor: &str) -> bool {
+    let mut pending = vec![trait_name];
+    let mut seen = HashSet::new();
+    while let Some(t) = pending.pop() {
+        if t == ancestor {
+            return true;
+        }
+        if seen.insert(t) {
+            if let Some(info) = table.traits.get(t) {
+                pending.extend(info.parent_traits.iter().map(|p| p.as_str()));
+            }
+        }
+    }
+    false
+}
+
 fn check_generic_param_bounds(
     params: &[GenericParam],
     table: &SymbolTable,
@@ -2486,7 +2615,7 @@ fn collect_stmt_call_requirements(
         Stmt::Expr(s) => collect_expr_call_requirements(&s.expr, table, out),
         Stmt::While(s) => {
             collect_expr_call_requirements(&s.condition, table, out);
-            for st in &s.body {
+            for st in s.all_stmts() {
                 collect_stmt_call_requirements(st, table, out);
             }
         }
@@ -2515,7 +2644,9 @@ fn collect_expr_call_requirements(
     out: &mut Vec<CallRequirement>,
 ) {
     match expr {
-        Expr::BinOp(lhs, _, rhs, _) | Expr::NullCoalesce(lhs, rhs, _) => {
+        Expr::BinOp(lhs, _, rhs, _)
+        | Expr::NullCoalesce(lhs, rhs, _)
+        | Expr::ListRepeat(lhs, rhs, _) => {
             collect_expr_call_requirements(lhs, table, out);
             collect_expr_call_requirements(rhs, table, out);
         }
@@ -2785,7 +2916,7 @@ fn collect_stmt_effect_evidence(
         Stmt::Expr(s) => collect_expr_effect_evidence(&s.expr, table, current, out),
         Stmt::While(s) => {
             collect_expr_effect_evidence(&s.condition, table, current, out);
-            for st in &s.body {
+            for st in s.all_stmts() {
                 collect_stmt_effect_evidence(st, table, current, out);
             }
         }
@@ -2821,7 +2952,9 @@ fn collect_expr_effect_evidence(
     out: &mut Vec<EffectEvidence>,
 ) {
     match expr {
-        Expr::BinOp(lhs, _, rhs, _) | Expr::NullCoalesce(lhs, rhs, _) => {
+        Expr::BinOp(lhs, _, rhs, _)
+        | Expr::NullCoalesce(lhs, rhs, _)
+        | Expr::ListRepeat(lhs, rhs, _) => {
             collect_expr_effect_evidence(lhs, table, current, out);
             collect_expr_effect_evidence(rhs, table, current, out);
         }
@@ -2861,8 +2994,8 @@ fn collect_expr_effect_evidence(
             }
             match callee.as_ref() {
                 Expr::Ident(name, _) => {
-                    if let Some(effects) = current.get(name) {
-                        for effect in effects {
+                    if let Some(effects) = callee_effects(name, table, current) {
+                        for effect in &effects {
                             push_effect_evidence(
                                 out,
                                 effect,
@@ -2881,7 +3014,7 @@ fn collect_expr_effect_evidence(
                             format!("tool call '{}'", name),
                         );
                     }
-                    if name == "emit" || name == "print" {
+                    if name == "emit" || name == "print" || name == "printf" {
                         push_effect_evidence(out, "emit", span.line, format!("call to '{}'", name));
                     }
                     if matches!(
@@ -3195,7 +3328,7 @@ fn infer_stmt_effects(
         Stmt::Expr(s) => infer_expr_effects(&s.expr, table, current, out),
         Stmt::While(s) => {
             infer_expr_effects(&s.condition, table, current, out);
-            for st in &s.body {
+            for st in s.all_stmts() {
                 infer_stmt_effects(st, table, current, out);
             }
         }
@@ -3231,7 +3364,9 @@ fn infer_expr_effects(
     out: &mut BTreeSet<String>,
 ) {
     match expr {
-        Expr::BinOp(lhs, _, rhs, _) | Expr::NullCoalesce(lhs, rhs, _) => {
+        Expr::BinOp(lhs, _, rhs, _)
+        | Expr::NullCoalesce(lhs, rhs, _)
+        | Expr::ListRepeat(lhs, rhs, _) => {
             infer_expr_effects(lhs, table, current, out);
             infer_expr_effects(rhs, table, current, out);
         }
@@ -3271,8 +3406,8 @@ fn infer_expr_effects(
             }
             match callee.as_ref() {
                 Expr::Ident(name, _) => {
-                    if let Some(effects) = current.get(name) {
-                        out.extend(effects.iter().cloned());
+                    if let Some(effects) = callee_effects(name, table, current) {
+                        out.extend(effects);
                     }
                     if table.tools.contains_key(name) {
                         if let Some(effect) = effect_from_tool(name, table) {
@@ -3281,7 +3416,7 @@ fn infer_expr_effects(
                             out.insert("external".into());
                         }
                     }
-                    if name == "emit" || name == "print" {
+                    if name == "emit" || name == "print" || name == "printf" {
                         out.insert("emit".into());
                     }
                     if matches!(
@@ -3479,6 +3614,22 @@ fn infer_expr_effects(
     }
 }
 
+/// Effects of calling the cell `name`: the running inference for cells in
+/// this program, or the effects an imported cell was resolved with.
+fn callee_effects(
+    name: &str,
+    table: &SymbolTable,
+    current: &HashMap<String, BTreeSet<String>>,
+) -> Option<BTreeSet<String>> {
+    match current.get(name) {
+        Some(effects) => Some(effects.clone()),
+        None => table
+            .cells
+            .get(name)
+            .map(|info| normalized_non_pure_effects(&info.effects)),
+    }
+}
+
 fn infer_cell_effects(
     cell: &EffectCell,
     table: &SymbolTable,
@@ -4114,7 +4265,10 @@ fn check_type_refs_with_generics(
             if generics.iter().any(|g| g == name) {
                 return;
             }
-            if !table.types.contains_key(name) && !table.type_aliases.contains_key(name) {
+            if !table.types.contains_key(name)
+                && !table.type_aliases.contains_key(name)
+                && !table.traits.contains_key(name)
+            {
                 let mut candidates: Vec<&str> = table.types.keys().map(|s| s.as_str()).collect();
                 candidates.extend(table.type_aliases.keys().map(|s| s.as_str()));
                 let suggestions = suggest_similar(name, &candidates, 2);
@@ -4278,11 +4432,16 @@ mod tests {
                 is_pub: false,
                 is_async: false,
                 is_extern: false,
+                is_const: false,
                 where_clauses: vec![],
                 must_use: false,
                 span: sp,
                 doc: None,
                 deprecated: None,
+                receiver: None,
+                specializations: vec![],
+                tailcall: false,
+                memoize: None,
             })],
             span: sp,
         };
@@ -4319,11 +4478,16 @@ mod tests {
                 is_pub: false,
                 is_async: false,
                 is_extern: false,
+                is_const: false,
                 must_use: false,
                 where_clauses: vec![],
                 span: sp,
                 doc: None,
                 deprecated: None,
+                receiver: None,
+                specializations: vec![],
+                tailcall: false,
+                memoize: None,
             })],
             span: sp,
         };
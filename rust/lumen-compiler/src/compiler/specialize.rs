@@ -0,0 +1,798 @@
+//! Monomorphized copies of `@specialize` cells.
+//!
+//! A generic cell has one body for every type argument, so a method call on
+//! a value of type `T` looks the method up on the value's runtime type.
+//! `@specialize(Int)` on `cell sort[T: Ord](...)` adds a copy named
+//! `sort[Int]` with `T` replaced by `Int`, in which a method call on a
+//! receiver of known type calls the impl (`Int.compare`) directly.
+//!
+//! A call goes to a copy when the types of its arguments are known at
+//! compile time: literals, record constructors, annotated parameters and
+//! bindings, immutable bindings of such values, and the results of
+//! non-generic cells with a declared return type. Every other call keeps
+//! using the generic cell, which computes the same result.
+
+use std::collections::HashMap;
+
+use crate::compiler::ast::*;
+use crate::compiler::resolve::{SymbolTable, TypeInfoKind};
+use crate::compiler::tokens::Span;
+
+/// One `@specialize` copy: its type arguments, keyed by `type_key`, and the
+/// name it is lowered under.
+struct Copy {
+    keys: Vec<String>,
+    name: String,
+}
+
+/// Generic cell whose calls may be sent to a copy.
+struct Specializable {
+    generic_params: Vec<String>,
+    params: Vec<TypeExpr>,
+    copies: Vec<Copy>,
+}
+
+/// `program` with a copy of each `@specialize` cell added and calls sent to
+/// those copies. None when no cell asks for specialization.
+pub fn expand(program: &Program, symbols: &SymbolTable) -> Option<Program> {
+    let mut targets: HashMap<String, Specializable> = HashMap::new();
+    let mut copies = Vec::new();
+    for item in &program.items {
+        let Item::Cell(cell) = item else { continue };
+        // Variadic arguments are packed by the callee's name, so a copy
+        // could not receive them
+        if cell.specializations.is_empty()
+            || cell.generic_params.is_empty()
+            || cell.params.iter().any(|p| p.variadic)
+        {
+            continue;
+        }
+        let generic_params: Vec<String> =
+            cell.generic_params.iter().map(|g| g.name.clone()).collect();
+        let mut target = Specializable {
+            generic_params: generic_params.clone(),
+            params: cell.params.iter().map(|p| p.ty.clone()).collect(),
+            copies: Vec::new(),
+        };
+        for args in &cell.specializations {
+            // A wrong count or an unnamed type is reported by the type checker
+            if args.len() != generic_params.len() {
+                continue;
+            }
+            let Some(keys) = args.iter().map(type_key).collect::<Option<Vec<_>>>() else {
+                continue;
+            };
+            let name = format!("{}[{}]", cell.name, keys.join(", "));
+            if target.copies.iter().any(|c| c.name == name) {
+                continue;
+            }
+            let subst: HashMap<String, TypeExpr> =
+                generic_params.iter().cloned().zip(args.iter().cloned()).collect();
+            let mut copy = cell.clone();
+            copy.name = name.clone();
+            copy.generic_params.clear();
+            copy.specializations.clear();
+            for p in &mut copy.params {
+                p.ty = substitute(&p.ty, &subst);
+            }
+            copy.return_type = copy.return_type.map(|t| substitute(&t, &subst));
+            copies.push((copy, subst));
+            target.copies.push(Copy { keys, name });
+        }
+        targets.insert(cell.name.clone(), target);
+    }
+    if copies.is_empty() {
+        return None;
+    }
+
+    let mut expanded = program.clone();
+    for item in &mut expanded.items {
+        match item {
+            Item::Cell(cell) => Rewriter::new(symbols, &targets, HashMap::new()).cell(cell),
+            Item::Impl(imp) => {
+                for cell in &mut imp.cells {
+                    Rewriter::new(symbols, &targets, HashMap::new()).cell(cell);
+                }
+            }
+            _ => {}
+        }
+    }
+    for (mut copy, subst) in copies {
+        Rewriter::new(symbols, &targets, subst).cell(&mut copy);
+        expanded.items.push(Item::Cell(copy));
+    }
+    Some(expanded)
+}
+
+/// Canonical spelling of a concrete type, used in copy names. None for
+/// types a copy cannot be keyed on, such as functions and unions.
+fn type_key(ty: &TypeExpr) -> Option<String> {
+    let join = |tys: &[TypeExpr]| -> Option<String> {
+        Some(tys.iter().map(type_key).collect::<Option<Vec<_>>>()?.join(", "))
+    };
+    Some(match ty {
+        TypeExpr::Named(name, _) => name.clone(),
+        TypeExpr::List(inner, _) => format!("list[{}]", type_key(inner)?),
+        TypeExpr::Set(inner, _) => format!("set[{}]", type_key(inner)?),
+        TypeExpr::Map(k, v, _) => format!("map[{}, {}]", type_key(k)?, type_key(v)?),
+        TypeExpr::Tuple(elems, _) => format!("({})", join(elems)?),
+        TypeExpr::Generic(name, args, _) => format!("{}[{}]", name, join(args)?),
+        _ => return None,
+    })
+}
+
+fn substitute(ty: &TypeExpr, subst: &HashMap<String, TypeExpr>) -> TypeExpr {
+    let all = |tys: &[TypeExpr]| tys.iter().map(|t| substitute(t, subst)).collect();
+    match ty {
+        TypeExpr::Named(name, _) => subst.get(name).cloned().unwrap_or_else(|| ty.clone()),
+        TypeExpr::List(inner, s) => TypeExpr::List(Box::new(substitute(inner, subst)), *s),
+        TypeExpr::Set(inner, s) => TypeExpr::Set(Box::new(substitute(inner, subst)), *s),
+        TypeExpr::Map(k, v, s) => TypeExpr::Map(
+            Box::new(substitute(k, subst)),
+            Box::new(substitute(v, subst)),
+            *s,
+        ),
+        TypeExpr::Result(ok, err, s) => TypeExpr::Result(
+            Box::new(substitute(ok, subst)),
+            Box::new(substitute(err, subst)),
+            *s,
+        ),
+        TypeExpr::Union(tys, s) => TypeExpr::Union(all(tys), *s),
+        TypeExpr::Tuple(tys, s) => TypeExpr::Tuple(all(tys), *s),
+        TypeExpr::Fn(params, ret, effects, s) => TypeExpr::Fn(
+            all(params),
+            Box::new(substitute(ret, subst)),
+            effects.clone(),
+            *s,
+        ),
+        TypeExpr::Generic(name, args, s) => TypeExpr::Generic(name.clone(), all(args), *s),
+        TypeExpr::Null(_) => ty.clone(),
+    }
+}
+
+/// Bind the generic parameters in `param` by matching it against the
+/// argument type `arg`. False when a parameter would need two types.
+fn unify(
+    param: &TypeExpr,
+    arg: &TypeExpr,
+    generic_params: &[String],
+    bound: &mut HashMap<String, String>,
+) -> bool {
+    match (param, arg) {
+        (TypeExpr::Named(name, _), _) if generic_params.contains(name) => {
+            let Some(key) = type_key(arg) else {
+                return true;
+            };
+            match bound.get(name) {
+                Some(existing) => *existing == key,
+                None => {
+                    bound.insert(name.clone(), key);
+                    true
+                }
+            }
+        }
+        (TypeExpr::List(p, _), TypeExpr::List(a, _)) | (TypeExpr::Set(p, _), TypeExpr::Set(a, _)) => {
+            unify(p, a, generic_params, bound)
+        }
+        (TypeExpr::Map(pk, pv, _), TypeExpr::Map(ak, av, _)) => {
+            unify(pk, ak, generic_params, bound) && unify(pv, av, generic_params, bound)
+        }
+        (TypeExpr::Tuple(ps, _), TypeExpr::Tuple(args, _)) if ps.len() == args.len() => ps
+            .iter()
+            .zip(args)
+            .all(|(p, a)| unify(p, a, generic_params, bound)),
+        (TypeExpr::Generic(pn, ps, _), TypeExpr::Generic(an, args, _))
+            if pn == an && ps.len() == args.len() =>
+        {
+            ps.iter()
+                .zip(args)
+                .all(|(p, a)| unify(p, a, generic_params, bound))
+        }
+        _ => true,
+    }
+}
+
+fn pattern_bindings(pattern: &Pattern, out: &mut Vec<String>) {
+    match pattern {
+        Pattern::Ident(name, _) | Pattern::TypeCheck { name, .. } => out.push(name.clone()),
+        Pattern::Variant(_, Some(inner), _) => pattern_bindings(inner, out),
+        Pattern::Guard { inner, .. } => pattern_bindings(inner, out),
+        Pattern::Or { patterns, .. } => patterns.iter().for_each(|p| pattern_bindings(p, out)),
+        Pattern::ListDestructure { elements, rest, .. } => {
+            elements.iter().for_each(|p| pattern_bindings(p, out));
+            out.extend(rest.iter().cloned());
+        }
+        Pattern::TupleDestructure { elements, .. } => {
+            elements.iter().for_each(|p| pattern_bindings(p, out))
+        }
+        Pattern::RecordDestructure { fields, .. } => {
+            for (field, sub) in fields {
+                match sub {
+                    Some(p) => pattern_bindings(p, out),
+                    None => out.push(field.clone()),
+                }
+            }
+        }
+        Pattern::Variant(_, None, _)
+        | Pattern::Literal(_)
+        | Pattern::Wildcard(_)
+        | Pattern::Range { .. } => {}
+    }
+}
+
+/// Walks one cell, tracking the static type of each binding in scope.
+struct Rewriter<'a> {
+    symbols: &'a SymbolTable,
+    targets: &'a HashMap<String, Specializable>,
+    /// Type arguments of the copy being rewritten; empty outside copies.
+    /// Method calls are only resolved statically inside copies.
+    subst: HashMap<String, TypeExpr>,
+    /// None for a binding whose type is not known
+    scopes: Vec<HashMap<String, Option<TypeExpr>>>,
+}
+
+impl<'a> Rewriter<'a> {
+    fn new(
+        symbols: &'a SymbolTable,
+        targets: &'a HashMap<String, Specializable>,
+        subst: HashMap<String, TypeExpr>,
+    ) -> Self {
+        Self {
+            symbols,
+            targets,
+            subst,
+            scopes: Vec::new(),
+        }
+    }
+
+    fn cell(&mut self, cell: &mut CellDef) {
+        let generic: Vec<&str> = cell.generic_params.iter().map(|g| g.name.as_str()).collect();
+        let mut params = HashMap::new();
+        for p in &cell.params {
+            // A parameter typed by the cell's own type parameter is not known
+            let known = match &p.ty {
+                TypeExpr::Named(name, _) if generic.contains(&name.as_str()) || name == "Any" => {
+                    None
+                }
+                ty if p.variadic => Some(TypeExpr::List(Box::new(ty.clone()), p.span)),
+                ty => Some(ty.clone()),
+            };
+            params.insert(p.name.clone(), known);
+        }
+        self.scopes.push(params);
+        self.stmts(&mut cell.body);
+        self.scopes.pop();
+    }
+
+    fn bind(&mut self, name: &str, ty: Option<TypeExpr>) {
+        if let Some(scope) = self.scopes.last_mut() {
+            scope.insert(name.to_string(), ty);
+        }
+    }
+
+    fn bind_unknown(&mut self, names: Vec<String>) {
+        for name in names {
+            self.bind(&name, None);
+        }
+    }
+
+    /// Some(type) for a binding in scope; None when `name` is not a binding
+    fn binding(&self, name: &str) -> Option<&Option<TypeExpr>> {
+        self.scopes.iter().rev().find_map(|scope| scope.get(name))
+    }
+
+    fn stmts(&mut self, body: &mut [Stmt]) {
+        self.scopes.push(HashMap::new());
+        for stmt in body.iter_mut() {
+            self.stmt(stmt);
+        }
+        self.scopes.pop();
+    }
+
+    fn stmt(&mut self, stmt: &mut Stmt) {
+        match stmt {
+            Stmt::Let(s) => {
+                if let Some(ty) = &mut s.ty {
+                    *ty = substitute(ty, &self.subst);
+                }
+                self.expr(&mut s.value);
+                match &s.pattern {
+                    Some(pattern) => {
+                        let mut names = Vec::new();
+                        pattern_bindings(pattern, &mut names);
+                        self.bind_unknown(names);
+                    }
+                    None => {
+                        // A mutable binding may be reassigned a value of another type
+                        let ty = match &s.ty {
+                            Some(ty) => Some(ty.clone()),
+                            None if !s.mutable => self.static_type(&s.value),
+                            None => None,
+                        };
+                        self.bind(&s.name, ty);
+                    }
+                }
+            }
+            Stmt::If(s) => {
+                self.expr(&mut s.condition);
+                self.stmts(&mut s.then_body);
+                if let Some(body) = &mut s.else_body {
+                    self.stmts(body);
+                }
+            }
+            Stmt::For(s) => {
+                self.expr(&mut s.iter);
+                let elem = match self.static_type(&s.iter) {
+                    Some(TypeExpr::List(inner, _)) | Some(TypeExpr::Set(inner, _)) => Some(*inner),
+                    _ => None,
+                };
+                self.scopes.push(HashMap::new());
+                match &s.pattern {
+                    Some(pattern) => {
+                        let mut names = Vec::new();
+                        pattern_bindings(pattern, &mut names);
+                        self.bind_unknown(names);
+                    }
+                    None => self.bind(&s.var, elem),
+                }
+                if let Some(filter) = &mut s.filter {
+                    self.expr(filter);
+                }
+                self.stmts(&mut s.body);
+                self.scopes.pop();
+            }
+            Stmt::Match(s) => {
+                self.expr(&mut s.subject);
+                for arm in &mut s.arms {
+                    self.arm(arm);
+                }
+            }
+            Stmt::Return(s) => self.expr(&mut s.value),
+            Stmt::Halt(s) => self.expr(&mut s.message),
+            Stmt::Assign(s) => {
+                self.target(&mut s.target);
+                self.expr(&mut s.value);
+            }
+            Stmt::CompoundAssign(s) => {
+                self.target(&mut s.target);
+                self.expr(&mut s.value);
+            }
+            Stmt::Expr(s) => self.expr(&mut s.expr),
+            Stmt::While(s) => {
+                self.scopes.push(HashMap::new());
+                if let Some(init) = &mut s.init {
+                    self.stmt(init);
+                }
+                self.expr(&mut s.condition);
+                self.stmts(&mut s.body);
+                if let Some(post) = &mut s.post {
+                    self.stmt(post);
+                }
+                self.scopes.pop();
+            }
+            Stmt::Loop(s) => self.stmts(&mut s.body),
+            Stmt::Emit(s) => self.expr(&mut s.value),
+            Stmt::Defer(s) => self.stmts(&mut s.body),
+            Stmt::Yield(s) => self.expr(&mut s.value),
+            Stmt::LocalCell(c) => self.bind(&c.name, None),
+            Stmt::Break(s) => {
+                if let Some(value) = &mut s.value {
+                    self.expr(value);
+                }
+            }
+            Stmt::Continue(_) | Stmt::LocalRecord(_) | Stmt::LocalEnum(_) => {}
+        }
+    }
+
+    fn target(&mut self, target: &mut AssignTarget) {
+        match target {
+            AssignTarget::Variable(_) => {}
+            AssignTarget::Index(base, index) => {
+                self.expr(base);
+                self.expr(index);
+            }
+            AssignTarget::Field(base, _) => self.expr(base),
+        }
+    }
+
+    fn arm(&mut self, arm: &mut MatchArm) {
+        self.scopes.push(HashMap::new());
+        let mut names = Vec::new();
+        pattern_bindings(&arm.pattern, &mut names);
+        self.bind_unknown(names);
+        self.stmts(&mut arm.body);
+        self.scopes.pop();
+    }
+
+    fn expr(&mut self, expr: &mut Expr) {
+        match expr {
+            Expr::IntLit(..)
+            | Expr::BigIntLit(..)
+            | Expr::FloatLit(..)
+            | Expr::StringLit(..)
+            | Expr::BoolLit(..)
+            | Expr::NullLit(..)
+            | Expr::RawStringLit(..)
+            | Expr::BytesLit(..)
+            | Expr::Ident(..) => {}
+            Expr::Perform { args, .. } => args.iter_mut().for_each(|e| self.expr(e)),
+            Expr::StringInterp(segments, _) => {
+                for segment in segments {
+                    match segment {
+                        StringSegment::Interpolation(e)
+                        | StringSegment::FormattedInterpolation(e, _) => self.expr(e),
+                        StringSegment::Literal(_) => {}
+                    }
+                }
+            }
+            Expr::ListLit(elems, _) | Expr::TupleLit(elems, _) | Expr::SetLit(elems, _) => {
+                elems.iter_mut().for_each(|e| self.expr(e))
+            }
+            Expr::MapLit(pairs, _) => {
+                for (k, v) in pairs {
+                    self.expr(k);
+                    self.expr(v);
+                }
+            }
+            Expr::RecordLit(_, fields, _) => fields.iter_mut().for_each(|(_, e)| self.expr(e)),
+            Expr::ListRepeat(a, b, _)
+            | Expr::BinOp(a, _, b, _)
+            | Expr::IndexAccess(a, b, _)
+            | Expr::NullCoalesce(a, b, _)
+            | Expr::NullSafeIndex(a, b, _)
+            | Expr::Pipe {
+                left: a, right: b, ..
+            } => {
+                self.expr(a);
+                self.expr(b);
+            }
+            Expr::UnaryOp(_, e, _)
+            | Expr::DotAccess(e, _, _)
+            | Expr::RoleBlock(_, e, _)
+            | Expr::ExpectSchema(e, _, _)
+            | Expr::TryExpr(e, _)
+            | Expr::NullSafeAccess(e, _, _)
+            | Expr::NullAssert(e, _)
+            | Expr::SpreadExpr(e, _)
+            | Expr::AwaitExpr(e, _)
+            | Expr::IsType { expr: e, .. }
+            | Expr::TypeCast { expr: e, .. }
+            | Expr::ComptimeExpr(e, _)
+            | Expr::ResumeExpr(e, _) => self.expr(e),
+            Expr::ToolCall(callee, args, _) => {
+                self.expr(callee);
+                self.args(args);
+            }
+            Expr::Call(callee, args, _) => {
+                self.expr(callee);
+                self.args(args);
+                self.rewrite_call(expr);
+            }
+            Expr::Lambda { params, body, .. } => {
+                self.scopes.push(HashMap::new());
+                for p in params.iter() {
+                    self.bind(&p.name, None);
+                }
+                match body {
+                    LambdaBody::Expr(e) => self.expr(e),
+                    LambdaBody::Block(stmts) => self.stmts(stmts),
+                }
+                self.scopes.pop();
+            }
+            Expr::RangeExpr {
+                start, end, step, ..
+            } => {
+                for e in [start, end, step].into_iter().flatten() {
+                    self.expr(e);
+                }
+            }
+            Expr::TryElse {
+                expr: inner,
+                error_binding,
+                handler,
+                ..
+            } => {
+                self.expr(inner);
+                self.scopes.push(HashMap::new());
+                self.bind(error_binding, None);
+                self.expr(handler);
+                self.scopes.pop();
+            }
+            Expr::IfExpr {
+                cond,
+                then_val,
+                else_val,
+                ..
+            } => {
+                self.expr(cond);
+                self.expr(then_val);
+                self.expr(else_val);
+            }
+            Expr::Comprehension {
+                body,
+                var,
+                iter,
+                extra_clauses,
+                condition,
+                ..
+            } => {
+                self.expr(iter);
+                self.scopes.push(HashMap::new());
+                self.bind(var, None);
+                for clause in extra_clauses.iter_mut() {
+                    self.expr(&mut clause.iter);
+                    self.bind(&clause.var, None);
+                }
+                if let Some(cond) = condition {
+                    self.expr(cond);
+                }
+                self.expr(body);
+                self.scopes.pop();
+            }
+            Expr::MatchExpr { subject, arms, .. } => {
+                self.expr(subject);
+                for arm in arms {
+                    self.arm(arm);
+                }
+            }
+            Expr::BlockExpr(stmts, _) => self.stmts(stmts),
+            Expr::WhenExpr { arms, else_body, .. } => {
+                for arm in arms {
+                    self.expr(&mut arm.condition);
+                    self.expr(&mut arm.body);
+                }
+                if let Some(e) = else_body {
+                    self.expr(e);
+                }
+            }
+            Expr::HandleExpr { body, handlers, .. } => {
+                self.stmts(body);
+                for handler in handlers {
+                    self.scopes.push(HashMap::new());
+                    for p in &handler.params {
+                        self.bind(&p.name, None);
+                    }
+                    self.stmts(&mut handler.body);
+                    self.scopes.pop();
+                }
+            }
+        }
+    }
+
+    fn args(&mut self, args: &mut [CallArg]) {
+        for arg in args {
+            match arg {
+                CallArg::Positional(e) | CallArg::Named(_, e, _) | CallArg::Role(_, e, _) => {
+                    self.expr(e)
+                }
+            }
+        }
+    }
+
+    fn rewrite_call(&self, expr: &mut Expr) {
+        let Expr::Call(callee, args, _) = expr else {
+            return;
+        };
+        match callee.as_mut() {
+            Expr::Ident(name, _) if self.binding(name).is_none() => {
+                if let Some(copy) = self.copy_for(name, args) {
+                    *name = copy;
+                }
+            }
+            Expr::DotAccess(receiver, method, span) if !self.subst.is_empty() => {
+                let Some(method_cell) = self.static_method(receiver, method) else {
+                    return;
+                };
+                let span = *span;
+                let receiver = std::mem::replace(receiver.as_mut(), Expr::NullLit(span));
+                args.insert(0, CallArg::Positional(receiver));
+                *callee.as_mut() = Expr::Ident(method_cell, span);
+            }
+            _ => {}
+        }
+    }
+
+    /// The copy of generic cell `name` matching the argument types, if any.
+    fn copy_for(&self, name: &str, args: &[CallArg]) -> Option<String> {
+        let target = self.targets.get(name)?;
+        if args.len() != target.params.len() {
+            return None;
+        }
+        let mut bound = HashMap::new();
+        for (param, arg) in target.params.iter().zip(args) {
+            let CallArg::Positional(arg) = arg else {
+                return None;
+            };
+            let ty = self.static_type(arg)?;
+            if !unify(param, &ty, &target.generic_params, &mut bound) {
+                return None;
+            }
+        }
+        let keys = target
+            .generic_params
+            .iter()
+            .map(|g| bound.get(g).cloned())
+            .collect::<Option<Vec<_>>>()?;
+        target
+            .copies
+            .iter()
+            .find(|c| c.keys == keys)
+            .map(|c| c.name.clone())
+    }
+
+    /// `Type.method` when `receiver` has a statically known type with such
+    /// a method. Methods taking a `mut` receiver keep the dynamic call,
+    /// which writes the receiver back.
+    fn static_method(&self, receiver: &Expr, method: &str) -> Option<String> {
+        let ty = match self.static_type(receiver)? {
+            TypeExpr::Named(name, _) | TypeExpr::Generic(name, _, _) => name,
+            _ => return None,
+        };
+        let cell_name = format!("{}.{}", ty, method);
+        let info = self.symbols.cells.get(&cell_name)?;
+        if info.receiver == Some(Receiver::Mut) {
+            return None;
+        }
+        Some(cell_name)
+    }
+
+    /// The type of `expr` when it is known without type inference.
+    fn static_type(&self, expr: &Expr) -> Option<TypeExpr> {
+        let named = |name: &str, span: &Span| Some(TypeExpr::Named(name.to_string(), *span));
+        match expr {
+            Expr::IntLit(_, s) => named("Int", s),
+            Expr::FloatLit(_, s) => named("Float", s),
+            Expr::StringLit(_, s) | Expr::StringInterp(_, s) | Expr::RawStringLit(_, s) => {
+                named("String", s)
+            }
+            Expr::BoolLit(_, s) => named("Bool", s),
+            Expr::BytesLit(_, s) => named("Bytes", s),
+            Expr::Ident(name, _) => self.binding(name)?.clone(),
+            Expr::ListLit(elems, s) => {
+                let first = self.static_type(elems.first()?)?;
+                let key = type_key(&first)?;
+                for e in &elems[1..] {
+                    if type_key(&self.static_type(e)?)? != key {
+                        return None;
+                    }
+                }
+                Some(TypeExpr::List(Box::new(first), *s))
+            }
+            Expr::TupleLit(elems, s) => Some(TypeExpr::Tuple(
+                elems
+                    .iter()
+                    .map(|e| self.static_type(e))
+                    .collect::<Option<Vec<_>>>()?,
+                *s,
+            )),
+            Expr::RecordLit(name, _, s) => {
+                let info = self.symbols.types.get(name)?;
+                if !info.generic_params.is_empty() {
+                    return None;
+                }
+                named(name, s)
+            }
+            Expr::Call(callee, _, _) => {
+                let Expr::Ident(name, _) = callee.as_ref() else {
+                    return None;
+                };
+                if self.binding(name).is_some() {
+                    return None;
+                }
+                let info = self.symbols.cells.get(name)?;
+                if !info.generic_params.is_empty() {
+                    return None;
+                }
+                info.return_type.clone()
+            }
+            Expr::IndexAccess(base, _, _) => match self.static_type(base)? {
+                TypeExpr::List(inner, _) => Some(*inner),
+                TypeExpr::Map(_, value, _) => Some(*value),
+                _ => None,
+            },
+            Expr::DotAccess(base, field, _) => {
+                let TypeExpr::Named(name, _) = self.static_type(base)? else {
+                    return None;
+                };
+                let info = self.symbols.types.get(&name)?;
+                match &info.kind {
+                    TypeInfoKind::Record(def) if info.generic_params.is_empty() => def
+                        .fields
+                        .iter()
+                        .find(|f| f.name == *field)
+                        .map(|f| f.ty.clone()),
+                    _ => None,
+                }
+            }
+            _ => None,
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::compiler::lexer::Lexer;
+    use crate::compiler::parser::Parser;
+    use crate::compiler::resolve::resolve;
+
+    fn expand_src(src: &str) -> Program {
+        let mut lexer = Lexer::new(src, 1, 0);
+        let tokens = lexer.tokenize().unwrap();
+        let mut parser = Parser::new(tokens);
+        let program = parser.parse_program(vec![]).unwrap();
+        let symbols = resolve(&program).unwrap();
+        expand(&program, &symbols).expect("program has @specialize cells")
+    }
+
+    fn cell<'p>(program: &'p Program, name: &str) -> &'p CellDef {
+        program
+            .items
+            .iter()
+            .find_map(|item| match item {
+                Item::Cell(c) if c.name == name => Some(c),
+                _ => None,
+            })
+            .unwrap_or_else(|| panic!("no cell named {}", name))
+    }
+
+    fn return_call_name(cell: &CellDef) -> String {
+        match cell.body.last() {
+            Some(Stmt::Return(ReturnStmt {
+                value: Expr::Call(callee, _, _),
+                ..
+            })) => match callee.as_ref() {
+                Expr::Ident(name, _) => name.clone(),
+                other => panic!("callee is not a name: {:?}", other),
+            },
+            other => panic!("last statement is not `return f(...)`: {:?}", other),
+        }
+    }
+
+    const SRC: &str = "trait Rank\n  cell rank(self: Self) -> Int\nend\n\nrecord Meters\n  value: Int\nend\n\nimpl Rank for Int\n  cell rank(self: Self) -> Int\n    return self\n  end\nend\n\nimpl Rank for Meters\n  cell rank(self: Self) -> Int\n    return self.value\n  end\nend\n\n@specialize(Int)\n@specialize(Meters)\ncell top[T: Rank](x: T) -> Int\n  return x.rank()\nend";
+
+    #[test]
+    fn test_copy_per_specialization() {
+        let program = expand_src(SRC);
+        let copy = cell(&program, "top[Int]");
+        assert!(copy.generic_params.is_empty());
+        assert!(matches!(&copy.params[0].ty, TypeExpr::Named(n, _) if n == "Int"));
+        let copy = cell(&program, "top[Meters]");
+        assert!(matches!(&copy.params[0].ty, TypeExpr::Named(n, _) if n == "Meters"));
+    }
+
+    #[test]
+    fn test_copy_calls_impl_method_directly() {
+        let program = expand_src(SRC);
+        assert_eq!(return_call_name(cell(&program, "top[Int]")), "Int.rank");
+        assert_eq!(return_call_name(cell(&program, "top[Meters]")), "Meters.rank");
+        assert!(matches!(
+            cell(&program, "top").body.last(),
+            Some(Stmt::Return(ReturnStmt { value: Expr::Call(callee, _, _), .. }))
+                if matches!(callee.as_ref(), Expr::DotAccess(..))
+        ));
+    }
+
+    #[test]
+    fn test_calls_with_known_types_use_copy() {
+        let program = expand_src(&format!(
+            "{}\n\ncell lit() -> Int\n  return top(5)\nend\n\ncell param(n: Int) -> Int\n  return top(n)\nend\n\ncell record_arg() -> Int\n  return top(Meters(value: 2))\nend\n\ncell unknown(n) -> Int\n  return top(n)\nend\n\ncell other() -> Int\n  return top(1.5)\nend",
+            SRC
+        ));
+        assert_eq!(return_call_name(cell(&program, "lit")), "top[Int]");
+        assert_eq!(return_call_name(cell(&program, "param")), "top[Int]");
+        assert_eq!(return_call_name(cell(&program, "record_arg")), "top[Meters]");
+        assert_eq!(return_call_name(cell(&program, "unknown")), "top");
+        assert_eq!(return_call_name(cell(&program, "other")), "top");
+    }
+
+    #[test]
+    fn test_no_specialize_leaves_program_alone() {
+        let src = "cell id[T](x: T) -> T\n  return x\nend";
+        let mut lexer = Lexer::new(src, 1, 0);
+        let tokens = lexer.tokenize().unwrap();
+        let program = Parser::new(tokens).parse_program(vec![]).unwrap();
+        let symbols = resolve(&program).unwrap();
+        assert!(expand(&program, &symbols).is_none());
+    }
+}
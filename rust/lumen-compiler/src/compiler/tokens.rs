@@ -140,6 +140,7 @@ pub enum TokenKind {
     Perform,
     Handle,
     Resume,
+    Switch,
     // Existing type keywords used in SPEC
     Bool,
     Int_,
@@ -303,6 +304,7 @@ impl fmt::Display for TokenKind {
             TokenKind::Perform => write!(f, "perform"),
             TokenKind::Handle => write!(f, "handle"),
             TokenKind::Resume => write!(f, "resume"),
+            TokenKind::Switch => write!(f, "switch"),
             TokenKind::Bool => write!(f, "bool"),
             TokenKind::Int_ => write!(f, "int"),
             TokenKind::Float_ => write!(f, "float"),
@@ -1,13 +1,15 @@
 //! Bidirectional type inference and checking for Lumen.
 
 use crate::compiler::ast::*;
-use crate::compiler::resolve::SymbolTable;
+use crate::compiler::const_eval::evaluate_consts;
+use crate::compiler::layout;
+use crate::compiler::resolve::{implements_trait, trait_extends, SymbolTable, OPERATOR_TRAITS};
 
 use std::collections::HashMap;
 use thiserror::Error;
 
 /// Check if a name is a built-in function
-fn is_builtin_function(name: &str) -> bool {
+pub(crate) fn is_builtin_function(name: &str) -> bool {
     matches!(
         name,
         "print"
@@ -52,6 +54,10 @@ fn is_builtin_function(name: &str) -> bool {
             | "spawn"
             | "resume"
             | "format"
+            | "format_fixed"
+            | "int_to_decimal"
+            | "sprintf"
+            | "printf"
             | "partition"
             | "read_dir"
             | "exists"
@@ -62,6 +68,10 @@ fn is_builtin_function(name: &str) -> bool {
             | "assert_ne"
             | "assert_contains"
             | "read_lines"
+            | "fs_read"
+            | "fs_read_chunk"
+            | "fs_write"
+            | "fs_append"
             | "walk_dir"
             | "glob"
             | "path_join"
@@ -69,18 +79,57 @@ fn is_builtin_function(name: &str) -> bool {
             | "path_extension"
             | "path_filename"
             | "path_stem"
+            | "path_join_all"
+            | "path_clean"
+            | "path_base"
+            | "path_dir"
+            | "path_ext"
+            | "path_is_abs"
+            | "path_separator"
+            | "linalg_matmul"
+            | "linalg_transpose"
+            | "linalg_dot"
+            | "bits_popcount"
+            | "bits_leading_zeros"
+            | "bits_trailing_zeros"
+            | "bits_rotate_left"
+            | "bits_rotate_right"
+            | "bits_swap_bytes"
             | "exec"
+            | "os_exec"
             | "read_stdin"
             | "read_line"
+            | "read_stdin_chunk"
+            | "bytes_find"
             | "eprint"
             | "eprintln"
             | "csv_parse"
             | "csv_encode"
+            | "csv_parse_record"
+            | "csv_format_record"
+            | "json_marshal"
+            | "json_unmarshal"
+            | "json_value_parse"
+            | "json_value_get"
+            | "json_value_as"
+            | "json_child_path"
+            | "json_kind"
+            | "reflect_kind"
+            | "reflect_type_name"
+            | "reflect_fields"
+            | "reflect_get"
+            | "reflect_len"
+            | "reflect_elem_type"
             | "toml_parse"
             | "toml_encode"
             | "regex_match"
             | "regex_replace"
             | "regex_find_all"
+            | "regex_compile"
+            | "hash_new"
+            | "hash_write"
+            | "hash_sum"
+            | "regex_find"
             | "string_concat"
             | "http_get"
             | "http_post"
@@ -97,11 +146,21 @@ fn is_builtin_function(name: &str) -> bool {
             | "tcp_close"
             | "map_sorted_keys"
             | "parse_int"
+            | "parse_int_radix"
             | "parse_float"
             | "log2"
             | "log10"
             | "is_nan"
             | "is_infinite"
+            | "float_compare"
+            | "float_vec_add"
+            | "float_vec_mul"
+            | "float_vec_triad"
+            | "float_vec_recip"
+            | "float_vec_rsqrt"
+            | "float_sum"
+            | "float_sum_compensated"
+            | "float_rsqrt"
             | "math_pi"
             | "math_e"
             | "sort_asc"
@@ -109,13 +168,68 @@ fn is_builtin_function(name: &str) -> bool {
             | "sort_by"
             | "binary_search"
             | "hrtime"
+            | "sleep"
+            | "flush"
             | "format_time"
             | "args"
+            | "byte_len"
+            | "rune_count"
+            | "runes"
+            | "byte_at"
+            | "rune_at"
+            | "from_runes"
+            | "rune_is_digit"
+            | "rune_is_letter"
+            | "rune_is_space"
+            | "rune_to_upper"
+            | "rune_to_lower"
+            | "bytes_to_base64"
+            | "base64_to_bytes"
+            | "bytes_to_hex"
+            | "hex_to_bytes"
+            | "utf8_encode"
+            | "utf8_decode"
+            | "intern"
+            | "get_env"
             | "set_env"
+            | "unset_env"
             | "env_vars"
+            | "build_info"
+            | "signal_handle"
+            | "signal_reset"
+            | "signal_raise"
+            | "panic"
+            | "recover"
+            | "todo"
+            | "unimplemented"
+            | "sizeof"
+            | "alignof"
+            | "weak"
+            | "upgrade"
+            | "set_finalizer"
+            | "run_finalizers"
+            | "backtrace"
+            | "list_with_capacity"
+            | "list_capacity"
+            | "list_reserve"
+            | "list_push"
+            | "list_pop"
     )
 }
 
+/// `upgrade` gives back the target of a `Weak[T]`, or null once it is freed.
+fn weak_upgrade_type(weak: Option<&Type>) -> Type {
+    let target = match weak {
+        Some(Type::TypeRef(name, args)) if name == "Weak" && args.len() == 1 => args[0].clone(),
+        _ => Type::Any,
+    };
+    Type::Union(vec![target, Type::Null])
+}
+
+fn is_weak_type(ty: &Type) -> bool {
+    matches!(ty, Type::TypeRef(name, _) if name == "Weak")
+}
+
 /// Check if a name is a built-in math constant.
 pub fn is_builtin_math_constant(name: &str) -> bool {
     matches!(
@@ -143,7 +257,7 @@ fn builtin_return_type(name: &str, arg_types: &[Type]) -> Option<Type> {
         "int" | "to_int" => Some(Type::Int),
         "float" | "to_float" => Some(Type::Float),
         "bool" => Some(Type::Bool),
-        "print" | "println" => Some(Type::Null),
+        "print" | "println" | "printf" => Some(Type::Null),
         "append" => arg_types.first().cloned(),
         "keys" => Some(Type::List(Box::new(Type::String))),
         "values" => Some(Type::List(Box::new(Type::Any))),
@@ -188,7 +302,7 @@ fn builtin_return_type(name: &str, arg_types: &[Type]) -> Option<Type> {
         "timestamp" => Some(Type::Float),
         "random" => Some(Type::Float),
         "get_env" => Some(Type::Union(vec![Type::String, Type::Null])),
-        "format" => Some(Type::String),
+        "format" | "format_fixed" | "int_to_decimal" | "sprintf" => Some(Type::String),
         "partition" => {
             let elem = arg_types
                 .first()
@@ -210,6 +324,16 @@ fn builtin_return_type(name: &str, arg_types: &[Type]) -> Option<Type> {
         "mkdir" => Some(Type::Null),
         "exit" => Some(Type::Null),
         "read_lines" => Some(Type::List(Box::new(Type::String))),
+        // Result-returning filesystem primitives; errors are maps with
+        // "kind", "path" and "message" keys.
+        "fs_read" | "fs_read_chunk" => Some(Type::Result(
+            Box::new(Type::Bytes),
+            Box::new(Type::Map(Box::new(Type::String), Box::new(Type::Any))),
+        )),
+        "fs_write" | "fs_append" => Some(Type::Result(
+            Box::new(Type::Null),
+            Box::new(Type::Map(Box::new(Type::String), Box::new(Type::Any))),
+        )),
         "walk_dir" => Some(Type::List(Box::new(Type::String))),
         "glob" => Some(Type::List(Box::new(Type::String))),
         "path_join" => Some(Type::String),
@@ -217,18 +341,67 @@ fn builtin_return_type(name: &str, arg_types: &[Type]) -> Option<Type> {
         "path_extension" => Some(Type::String),
         "path_filename" => Some(Type::String),
         "path_stem" => Some(Type::String),
+        "path_join_all" | "path_clean" | "path_base" | "path_dir" | "path_ext"
+        | "path_separator" => Some(Type::String),
+        "path_is_abs" => Some(Type::Bool),
+        "linalg_matmul" | "linalg_transpose" => {
+            Some(Type::List(Box::new(Type::List(Box::new(Type::Float)))))
+        }
+        "linalg_dot" => Some(Type::Float),
+        "bits_popcount" | "bits_leading_zeros" | "bits_trailing_zeros" | "bits_rotate_left"
+        | "bits_rotate_right" | "bits_swap_bytes" => Some(Type::Int),
         "exec" => Some(Type::Any),
+        "os_exec" => Some(Type::Tuple(vec![
+            Type::String,
+            Type::String,
+            Type::Int,
+            Type::Union(vec![Type::String, Type::Null]),
+        ])),
         "read_stdin" => Some(Type::String),
         "read_line" => Some(Type::String),
+        "read_stdin_chunk" => Some(Type::Bytes),
+        "bytes_find" => Some(Type::Int),
         "eprint" => Some(Type::Null),
         "eprintln" => Some(Type::Null),
         "csv_parse" => Some(Type::List(Box::new(Type::List(Box::new(Type::String))))),
         "csv_encode" => Some(Type::String),
+        "csv_parse_record" => Some(Type::Result(
+            Box::new(Type::Union(vec![
+                Type::Tuple(vec![
+                    Type::List(Box::new(Type::String)),
+                    Type::Int,
+                    Type::Int,
+                ]),
+                Type::Null,
+            ])),
+            Box::new(Type::String),
+        )),
+        "csv_format_record" => Some(Type::String),
+        "json_marshal" => Some(Type::String),
+        "json_unmarshal" => Some(Type::Result(Box::new(Type::Any), Box::new(Type::String))),
+        "json_value_parse" | "json_value_get" | "json_value_as" => {
+            Some(Type::Result(Box::new(Type::Any), Box::new(Type::String)))
+        }
+        "json_child_path" | "json_kind" => Some(Type::String),
+        "reflect_kind" | "reflect_type_name" | "reflect_elem_type" => Some(Type::String),
+        "reflect_fields" => Some(Type::List(Box::new(Type::Tuple(vec![
+            Type::String,
+            Type::String,
+        ])))),
+        "reflect_get" => Some(Type::Any),
+        "reflect_len" => Some(Type::Int),
         "toml_parse" => Some(Type::Any),
         "toml_encode" => Some(Type::String),
         "regex_match" => Some(Type::List(Box::new(Type::String))),
         "regex_replace" => Some(Type::String),
         "regex_find_all" => Some(Type::List(Box::new(Type::String))),
+        "regex_compile" => Some(Type::Result(Box::new(Type::String), Box::new(Type::String))),
+        "regex_find" => Some(Type::Union(vec![
+            Type::Map(Box::new(Type::String), Box::new(Type::Any)),
+            Type::Null,
+        ])),
+        "hash_new" => Some(Type::Result(Box::new(Type::Bytes), Box::new(Type::String))),
+        "hash_write" | "hash_sum" => Some(Type::Bytes),
         "string_concat" => Some(Type::String),
         // HTTP client builtins — return maps with status, body, ok fields
         "http_get" | "http_post" | "http_put" | "http_delete" | "http_request" => Some(Type::Any),
@@ -240,19 +413,71 @@ fn builtin_return_type(name: &str, arg_types: &[Type]) -> Option<Type> {
         "tcp_close" => Some(Type::Null),
         // Wave 4A: stdlib completeness (T361-T370)
         "map_sorted_keys" => Some(Type::List(Box::new(Type::Any))),
-        "parse_int" => Some(Type::Result(Box::new(Type::Int), Box::new(Type::String))),
+        "parse_int" | "parse_int_radix" => {
+            Some(Type::Result(Box::new(Type::Int), Box::new(Type::String)))
+        }
         "parse_float" => Some(Type::Result(Box::new(Type::Float), Box::new(Type::String))),
         "log2" | "log10" => Some(Type::Float),
         "is_nan" | "is_infinite" => Some(Type::Bool),
+        "float_compare" => Some(Type::Int),
+        "float_rsqrt" | "float_sum" | "float_sum_compensated" => Some(Type::Float),
+        "float_vec_add" | "float_vec_mul" | "float_vec_triad" | "float_vec_recip"
+        | "float_vec_rsqrt" => {
+            Some(Type::List(Box::new(Type::Float)))
+        }
         "math_pi" | "math_e" => Some(Type::Float),
         "sort_asc" | "sort_desc" => arg_types.first().cloned().or(Some(Type::Any)),
         "sort_by" => arg_types.first().cloned().or(Some(Type::Any)),
         "binary_search" => Some(Type::Result(Box::new(Type::Int), Box::new(Type::Int))),
         "hrtime" => Some(Type::Int),
+        "sleep" | "flush" => Some(Type::Null),
         "format_time" => Some(Type::String),
         "args" => Some(Type::List(Box::new(Type::String))),
-        "set_env" => Some(Type::Null),
-        "env_vars" => Some(Type::Map(Box::new(Type::String), Box::new(Type::String))),
+        "byte_len" | "rune_count" => Some(Type::Int),
+        "runes" => Some(Type::List(Box::new(Type::Int))),
+        "byte_at" => Some(Type::Union(vec![Type::Int, Type::Null])),
+        "rune_at" => Some(Type::Union(vec![Type::String, Type::Null])),
+        "from_runes" => Some(Type::String),
+        "rune_is_digit" | "rune_is_letter" | "rune_is_space" => Some(Type::Bool),
+        "rune_to_upper" | "rune_to_lower" => Some(Type::Int),
+        "utf8_encode" => Some(Type::Bytes),
+        "intern" => Some(Type::String),
+        "utf8_decode" => Some(Type::Result(Box::new(Type::String), Box::new(Type::String))),
+        "bytes_to_base64" | "bytes_to_hex" => Some(Type::String),
+        "base64_to_bytes" | "hex_to_bytes" => {
+            Some(Type::Result(Box::new(Type::Bytes), Box::new(Type::String)))
+        }
+        "set_env" | "unset_env" => Some(Type::Null),
+        "env_vars" | "build_info" => {
+            Some(Type::Map(Box::new(Type::String), Box::new(Type::String)))
+        }
+        "signal_handle" | "signal_reset" | "signal_raise" => Some(Type::Null),
+        // These never return, so their result fits any position.
+        "panic" | "todo" | "unimplemented" => Some(Type::Never),
+        "sizeof" | "alignof" => Some(Type::Int),
+        "weak" => Some(Type::TypeRef(
+            "Weak".to_string(),
+            vec![arg_types.first().cloned().unwrap_or(Type::Any)],
+        )),
+        "upgrade" => Some(weak_upgrade_type(arg_types.first())),
+        "set_finalizer" => Some(Type::Null),
+        "run_finalizers" => Some(Type::Int),
+        "backtrace" => Some(Type::List(Box::new(Type::Map(
+            Box::new(Type::String),
+            Box::new(Type::Any),
+        )))),
+        "list_with_capacity" => Some(Type::List(Box::new(Type::Any))),
+        "list_capacity" => Some(Type::Int),
+        "list_reserve" | "list_push" | "list_pop" => {
+            arg_types.first().cloned().or(Some(Type::Any))
+        }
+        "recover" => {
+            let ok = match arg_types.first() {
+                Some(Type::Fn(_, ret)) => ret.clone(),
+                _ => Box::new(Type::Any),
+            };
+            Some(Type::Result(ok, Box::new(Type::String)))
+        }
         _ => None,
     }
 }
@@ -292,6 +517,47 @@ fn desugar_pipe_application(
     }
 }
 
+/// The type of a conditional whose branches have types `a` and `b`. A branch
+/// that diverges never yields a value, so the other branch decides.
+fn join_branch_types(a: Type, b: Type) -> Type {
+    if a == Type::Never {
+        b
+    } else {
+        a
+    }
+}
+
+/// Whether control never continues past `stmt`: it returns, halts, or is a
+/// `loop` that nothing breaks out of.
+fn stmt_diverges(stmt: &Stmt) -> bool {
+    match stmt {
+        Stmt::Return(_) | Stmt::Halt(_) => true,
+        Stmt::Loop(ls) => !body_breaks(&ls.body, true),
+        _ => false,
+    }
+}
+
+/// Whether `body` contains a `break` that leaves the enclosing loop. Unlabeled
+/// breaks in nested loops bind to those loops; labeled breaks are assumed to
+/// leave it.
+fn body_breaks(body: &[Stmt], outermost: bool) -> bool {
+    body.iter().any(|stmt| match stmt {
+        Stmt::Break(bs) => outermost || bs.label.is_some(),
+        Stmt::If(is) => {
+            body_breaks(&is.then_body, outermost)
+                || is
+                    .else_body
+                    .as_ref()
+                    .is_some_and(|eb| body_breaks(eb, outermost))
+        }
+        Stmt::Match(ms) => ms.arms.iter().any(|arm| body_breaks(&arm.body, outermost)),
+        Stmt::For(fs) => body_breaks(&fs.body, false),
+        Stmt::While(ws) => body_breaks(&ws.body, false),
+        Stmt::Loop(ls) => body_breaks(&ls.body, false),
+        _ => false,
+    })
+}
+
 fn type_contains_any(ty: &Type) -> bool {
     match ty {
         Type::Any => true,
@@ -401,6 +667,49 @@ pub enum TypeError {
     },
     #[error("unused result of @must_use cell '{name}' at line {line}")]
     MustUseIgnored { name: String, line: usize },
+    #[error("invalid receiver for method '{method}' at line {line}: {reason}")]
+    BadReceiver {
+        method: String,
+        reason: String,
+        line: usize,
+    },
+    #[error("type '{ty}' does not implement trait '{trait_name}' at line {line}")]
+    TraitNotImplemented {
+        ty: String,
+        trait_name: String,
+        line: usize,
+    },
+    #[error("operator '{op}' is not defined for type '{ty}' at line {line}: {hint}")]
+    OperatorNotImplemented {
+        op: String,
+        ty: String,
+        hint: String,
+        line: usize,
+    },
+    #[error("'{name}' cannot be evaluated at compile time at line {line}: {reason}")]
+    NotConst {
+        name: String,
+        reason: String,
+        line: usize,
+    },
+    #[error("invalid spread in '{ty}' constructor at line {line}: {reason}")]
+    InvalidSpread {
+        ty: String,
+        reason: String,
+        line: usize,
+    },
+    #[error("list pattern at line {line} needs {needed} elements but the list has {len}")]
+    ListPatternLength {
+        needed: String,
+        len: usize,
+        line: usize,
+    },
+    #[error("call to @tailcall cell '{name}' at line {line} is not a tail call: {reason}")]
+    NotTailCall {
+        name: String,
+        reason: String,
+        line: usize,
+    },
 }
 
 /// Resolved type representation
@@ -424,6 +733,11 @@ pub enum Type {
     Fn(Vec<Type>, Box<Type>),
     Generic(String),
     TypeRef(String, Vec<Type>),
+    /// A value of any type that implements the named trait
+    Trait(String),
+    /// The bottom type of expressions that never produce a value: `panic`,
+    /// `todo`, a `loop` with no `break`. Compatible with every expected type.
+    Never,
     Any, // For unresolved / error recovery
 }
 
@@ -438,6 +752,7 @@ impl std::fmt::Display for Type {
             Type::Json => write!(f, "Json"),
             Type::Null => write!(f, "Null"),
             Type::Any => write!(f, "Any"),
+            Type::Never => write!(f, "Never"),
             Type::List(t) => write!(f, "list[{}]", t),
             Type::Map(k, v) => write!(f, "map[{}, {}]", k, v),
             Type::Record(n) => write!(f, "{}", n),
@@ -456,7 +771,7 @@ impl std::fmt::Display for Type {
                 let ps: Vec<_> = params.iter().map(|t| format!("{}", t)).collect();
                 write!(f, "fn({}) -> {}", ps.join(", "), ret)
             }
-            Type::Generic(n) => write!(f, "{}", n),
+            Type::Generic(n) | Type::Trait(n) => write!(f, "{}", n),
             Type::TypeRef(n, args) => {
                 let as_: Vec<_> = args.iter().map(|t| format!("{}", t)).collect();
                 write!(f, "{}[{}]", n, as_.join(", "))
@@ -597,6 +912,7 @@ fn resolve_type_expr_with_subst(ty: &TypeExpr, symbols: &SymbolTable, subst: &Ty
                 "Bytes" => Type::Bytes,
                 "Json" => Type::Json,
                 "Any" => Type::Any,
+                "Never" => Type::Never,
                 "Null" => Type::Null,
                 _ => {
                     if symbols.types.contains_key(name) {
@@ -608,6 +924,8 @@ fn resolve_type_expr_with_subst(ty: &TypeExpr, symbols: &SymbolTable, subst: &Ty
                         }
                     } else if let Some(alias_target) = symbols.type_aliases.get(name) {
                         resolve_type_expr_with_subst(alias_target, symbols, subst)
+                    } else if symbols.traits.contains_key(name) {
+                        Type::Trait(name.clone())
                     } else {
                         Type::Any
                     }
@@ -685,6 +1003,33 @@ struct TypeChecker<'a> {
     locals: HashMap<String, Type>,
     mutables: HashMap<String, bool>,
     errors: Vec<TypeError>,
+    /// Trait bounds on the type parameters of generic cells, by cell name
+    generic_bounds: HashMap<String, HashMap<String, Vec<String>>>,
+    /// Set while checking a `@tailcall` cell
+    tailcall: Option<TailCallCell>,
+    /// The next call checked is the whole value of a `return`
+    in_tail_position: bool,
+    /// Lengths of immutable bindings to list literals, for list patterns
+    list_lens: HashMap<String, usize>,
+    /// Whether each resolved method call takes a `mut` receiver, by the
+    /// byte range of the call
+    method_receivers: HashMap<(usize, usize), bool>,
+}
+
+/// The bindings in scope, saved on entering a block and restored on leaving it
+type Scope = (
+    HashMap<String, Type>,
+    HashMap<String, bool>,
+    HashMap<String, usize>,
+);
+
+/// The `@tailcall` cell being checked.
+struct TailCallCell {
+    name: String,
+    /// A `mut` receiver is written back after every call the cell makes
+    mut_receiver: bool,
+    /// A `defer` has been checked; it runs before any later return
+    defer_pending: bool,
 }
 
 #[derive(Debug)]
@@ -701,14 +1046,43 @@ impl<'a> TypeChecker<'a> {
             locals: HashMap::new(),
             mutables: HashMap::new(),
             errors: Vec::new(),
+            generic_bounds: HashMap::new(),
+            tailcall: None,
+            in_tail_position: false,
+            list_lens: HashMap::new(),
+            method_receivers: HashMap::new(),
         }
     }
 
     fn check_cell(&mut self, cell: &CellDef) {
+        self.check_cell_with_self(cell, None);
+    }
+
+    /// Check a cell; inside an impl block `self_type` is what `Self` means.
+    fn check_cell_with_self(&mut self, cell: &CellDef, self_type: Option<Type>) {
         self.locals.clear();
         self.mutables.clear();
+        self.list_lens.clear();
+        // A type parameter with a single trait bound is typed as that trait,
+        // so the body may call the trait's methods on it. Any other parameter
+        // is `Any`, even when a type of the same name is in scope.
+        let mut subst: TypeSubst = cell
+            .generic_params
+            .iter()
+            .map(|gp| {
+                let ty = if gp.bounds.len() == 1 {
+                    Type::Trait(gp.bounds[0].clone())
+                } else {
+                    Type::Any
+                };
+                (gp.name.clone(), ty)
+            })
+            .collect();
+        if let Some(ty) = self_type {
+            subst.insert("Self".to_string(), ty);
+        }
         for p in &cell.params {
-            let ty = resolve_type_expr(&p.ty, self.symbols);
+            let ty = resolve_type_expr_with_subst(&p.ty, self.symbols, &subst);
             // Variadic params are seen as List[T] inside the function body
             let ty = if p.variadic {
                 Type::List(Box::new(ty))
@@ -718,11 +1092,20 @@ impl<'a> TypeChecker<'a> {
             self.locals.insert(p.name.clone(), ty);
             self.mutables.insert(p.name.clone(), true); // params are mutable by default
         }
-        let return_type = if let Some(ref rt) = cell.return_type {
-            Some(resolve_type_expr(rt, self.symbols))
-        } else {
-            None
-        };
+        if let Some(receiver) = cell.receiver {
+            self.check_method_receiver(cell, receiver);
+        }
+        let return_type = cell
+            .return_type
+            .as_ref()
+            .map(|rt| resolve_type_expr_with_subst(rt, self.symbols, &subst));
+
+        self.check_specializations(cell);
+        self.tailcall = cell.tailcall.then(|| TailCallCell {
+            name: cell.name.clone(),
+            mut_receiver: matches!(cell.receiver, Some(Receiver::Mut)),
+            defer_pending: false,
+        });
 
         let body_len = cell.body.len();
         for (i, stmt) in cell.body.iter().enumerate() {
@@ -731,9 +1114,423 @@ impl<'a> TypeChecker<'a> {
         }
     }
 
+    /// A `@tailcall` cell may only call itself as the whole value of a
+    /// `return`, which is what compiles to a `TailCall` that reuses the frame.
+    fn check_tail_self_call(&mut self, callee: &Expr, in_tail_position: bool, line: usize) {
+        let Some(tc) = &self.tailcall else {
+            return;
+        };
+        if !matches!(callee, Expr::Ident(name, _) if *name == tc.name) {
+            return;
+        }
+        let reason = if !in_tail_position {
+            "its result is used before the cell returns"
+        } else if tc.defer_pending {
+            "a pending `defer` runs after it returns"
+        } else if tc.mut_receiver {
+            "the `mut` receiver is written back after it returns"
+        } else {
+            return;
+        };
+        self.errors.push(TypeError::NotTailCall {
+            name: tc.name.clone(),
+            reason: reason.to_string(),
+            line,
+        });
+    }
+
+    /// Each `@specialize(...)` names one concrete type per type parameter,
+    /// and each type must satisfy that parameter's bounds.
+    fn check_specializations(&mut self, cell: &CellDef) {
+        for args in &cell.specializations {
+            let line = args.first().map_or(cell.span.line, |t| t.span().line);
+            if args.len() != cell.generic_params.len() {
+                self.errors.push(TypeError::ArgCount {
+                    expected: cell.generic_params.len(),
+                    actual: args.len(),
+                    line,
+                });
+                continue;
+            }
+            for (gp, arg) in cell.generic_params.iter().zip(args) {
+                if let TypeExpr::Named(name, span) = arg {
+                    let known = matches!(
+                        name.as_str(),
+                        "Int" | "Float" | "String" | "Bool" | "Bytes" | "Json" | "Null"
+                    ) || self.symbols.types.contains_key(name)
+                        || self.symbols.type_aliases.contains_key(name);
+                    if !known {
+                        self.errors.push(TypeError::UndefinedType {
+                            name: name.clone(),
+                            line: span.line,
+                        });
+                        continue;
+                    }
+                }
+                let ty = resolve_type_expr(arg, self.symbols);
+                for bound in &gp.bounds {
+                    if !self.satisfies_trait(&ty, bound) {
+                        self.errors.push(TypeError::TraitNotImplemented {
+                            ty: format!("{}", ty),
+                            trait_name: bound.clone(),
+                            line: arg.span().line,
+                        });
+                    }
+                }
+            }
+        }
+    }
+
+    /// A value receiver is read-only inside its method.
+    fn check_method_receiver(&mut self, cell: &CellDef, receiver: Receiver) {
+        if receiver == Receiver::Value {
+            self.mutables.insert(cell.params[0].name.clone(), false);
+        }
+    }
+
+    /// Check a nested block. A name the block binds, whether new or
+    /// shadowing an outer one, goes out of scope when the block ends.
+    fn check_block(&mut self, body: &[Stmt], expected_return: Option<&Type>) {
+        let scope = self.enter_scope();
+        for s in body {
+            self.check_stmt(s, expected_return, false);
+        }
+        self.exit_scope(scope);
+    }
+
+    fn enter_scope(&self) -> Scope {
+        (
+            self.locals.clone(),
+            self.mutables.clone(),
+            self.list_lens.clone(),
+        )
+    }
+
+    fn exit_scope(&mut self, (locals, mutables, list_lens): Scope) {
+        self.locals = locals;
+        self.mutables = mutables;
+        self.list_lens = list_lens;
+    }
+
+    /// The length `expr` is known to have: that of a list literal without
+    /// spreads, or of an immutable binding to one.
+    fn known_list_len(&self, expr: &Expr) -> Option<usize> {
+        match expr {
+            Expr::ListLit(items, _) if !items.iter().any(|e| matches!(e, Expr::SpreadExpr(..))) => {
+                Some(items.len())
+            }
+            Expr::Ident(name, _) => self.list_lens.get(name).copied(),
+            _ => None,
+        }
+    }
+
+    /// A list pattern matches only lists of its own length, or at least that
+    /// long with a rest binding; against a list of known length `len` that
+    /// rules it out, it is an error.
+    fn check_list_pattern_len(&mut self, pattern: &Pattern, len: usize, line: usize) {
+        match pattern {
+            Pattern::ListDestructure { elements, rest, .. } => {
+                let n = elements.len();
+                let needed = match rest {
+                    None if n != len => format!("exactly {}", n),
+                    Some(_) if n > len => format!("at least {}", n),
+                    _ => return,
+                };
+                self.errors.push(TypeError::ListPatternLength { needed, len, line });
+            }
+            Pattern::Guard { inner, .. } => self.check_list_pattern_len(inner, len, line),
+            Pattern::Or { patterns, .. } => {
+                for p in patterns {
+                    self.check_list_pattern_len(p, len, line);
+                }
+            }
+            _ => {}
+        }
+    }
+
+    /// `sizeof(T)`/`alignof(T)` on a type name are folded at compile time.
+    /// `sizeof` of any other value stays a runtime call; `alignof` has no
+    /// runtime form, so its argument must name a type.
+    fn check_layout_arg(
+        &mut self,
+        builtin: &str,
+        args: &[CallArg],
+        checked: &[CheckedCallArg],
+        line: usize,
+    ) {
+        match args {
+            [CallArg::Positional(Expr::Ident(ty, _))] if !self.locals.contains_key(ty) => {
+                if layout::type_size_align(ty, self.symbols).is_none() {
+                    self.errors.push(TypeError::UndefinedType {
+                        name: ty.clone(),
+                        line,
+                    });
+                }
+            }
+            [_] if builtin == "sizeof" => {}
+            [_] => {
+                let actual = match checked.first() {
+                    Some(CheckedCallArg::Positional(ty, _) | CheckedCallArg::Named(_, ty, _)) => {
+                        ty.to_string()
+                    }
+                    None => Type::Any.to_string(),
+                };
+                self.errors.push(TypeError::Mismatch {
+                    expected: "type name".to_string(),
+                    actual,
+                    line,
+                });
+            }
+            _ => self.errors.push(TypeError::ArgCount {
+                expected: 1,
+                actual: args.len(),
+                line,
+            }),
+        }
+    }
+
+    /// Type a call `obj.method(args)` on a record with a method declared by
+    /// receiver syntax. Returns None when `obj.method` is not such a method.
+    fn infer_method_call(
+        &mut self,
+        obj: &Expr,
+        method: &str,
+        args: &[CheckedCallArg],
+        span: crate::compiler::tokens::Span,
+    ) -> Option<Type> {
+        let line = span.line;
+        let mut root = obj;
+        while let Expr::DotAccess(inner, _, _) | Expr::IndexAccess(inner, _, _) = root {
+            root = inner;
+        }
+        if let Expr::Ident(name, _) = root {
+            if !self.locals.contains_key(name) {
+                return None;
+            }
+        }
+        let type_name = match self.infer_expr(obj) {
+            weak @ Type::TypeRef(_, _) if method == "upgrade" && is_weak_type(&weak) => {
+                return Some(weak_upgrade_type(Some(&weak)))
+            }
+            Type::Record(name) | Type::TypeRef(name, _) => name,
+            Type::Trait(trait_name) => {
+                return self.infer_trait_method_call(&trait_name, method, args, line)
+            }
+            _ => return None,
+        };
+        if let Some(ti) = self.symbols.types.get(&type_name) {
+            if let crate::compiler::resolve::TypeInfoKind::Record(ref rd) = ti.kind {
+                if rd.fields.iter().any(|f| f.name == method) {
+                    return None;
+                }
+            }
+        }
+        let qualified = format!("{}.{}", type_name, method);
+        let ci = self.symbols.cells.get(&qualified)?.clone();
+        let receiver = ci.receiver?;
+        self.method_receivers
+            .insert((span.start, span.end), receiver == Receiver::Mut);
+        if receiver == Receiver::Mut {
+            match obj {
+                Expr::Ident(name, _) if self.locals.contains_key(name) => {
+                    if self.mutables.get(name.as_str()) == Some(&false) {
+                        self.errors.push(TypeError::ImmutableAssign {
+                            name: name.clone(),
+                            line,
+                        });
+                    }
+                }
+                _ => self.errors.push(TypeError::BadReceiver {
+                    method: qualified.clone(),
+                    reason: "a `mut` receiver must be a variable; bind the value with `let` first"
+                        .to_string(),
+                    line,
+                }),
+            }
+        }
+        let subst = TypeSubst::from([("Self".to_string(), Type::Record(type_name))]);
+        self.check_call_against_signature_with_subst(&ci.params[1..], args, line, &subst);
+        Some(
+            ci.return_type
+                .as_ref()
+                .map(|rt| resolve_type_expr_with_subst(rt, self.symbols, &subst))
+                .unwrap_or(Type::Any),
+        )
+    }
+
+    /// Type a method call on a trait object or bounded type parameter against
+    /// the signature declared in the trait or one of its parent traits.
+    fn infer_trait_method_call(
+        &mut self,
+        trait_name: &str,
+        method: &str,
+        args: &[CheckedCallArg],
+        line: usize,
+    ) -> Option<Type> {
+        let mut pending = vec![trait_name.to_string()];
+        let mut seen = std::collections::HashSet::new();
+        let mut declared = Vec::new();
+        while let Some(t) = pending.pop() {
+            if !seen.insert(t.clone()) {
+                continue;
+            }
+            let Some(info) = self.symbols.traits.get(&t) else {
+                continue;
+            };
+            if let Some(sig) = info.signatures.get(method) {
+                if sig.receiver.is_none() {
+                    return None;
+                }
+                let sig = sig.clone();
+                let subst =
+                    TypeSubst::from([("Self".to_string(), Type::Trait(trait_name.to_string()))]);
+                self.check_call_against_signature_with_subst(&sig.params[1..], args, line, &subst);
+                return Some(
+                    sig.return_type
+                        .as_ref()
+                        .map(|rt| resolve_type_expr_with_subst(rt, self.symbols, &subst))
+                        .unwrap_or(Type::Any),
+                );
+            }
+            declared.extend(info.methods.iter().cloned());
+            pending.extend(info.parent_traits.iter().cloned());
+        }
+        let candidates: Vec<&str> = declared.iter().map(|m| m.as_str()).collect();
+        self.errors.push(TypeError::UnknownField {
+            field: method.to_string(),
+            ty: trait_name.to_string(),
+            line,
+            suggestions: suggest_similar(method, &candidates, 2),
+        });
+        Some(Type::Any)
+    }
+
+    /// Type `lhs op rhs` for an arithmetic or equality operator whose left
+    /// operand is a user type, which must implement the operator's trait.
+    /// Returns None when builtin operator typing applies; records without an
+    /// `Eq` impl compare structurally.
+    fn infer_overloaded_op(
+        &mut self,
+        lhs: &Type,
+        op: BinOp,
+        rhs: &Type,
+        line: usize,
+    ) -> Option<Type> {
+        let type_name = match lhs {
+            Type::Record(name) | Type::TypeRef(name, _) | Type::Trait(name) => name.clone(),
+            _ => return None,
+        };
+        let symbol = match op {
+            BinOp::Add | BinOp::Sub | BinOp::Mul | BinOp::Div | BinOp::FloorDiv | BinOp::Mod => {
+                op.to_string()
+            }
+            BinOp::Eq | BinOp::NotEq => "==".to_string(),
+            _ => return None,
+        };
+        let is_eq = symbol == "==";
+        let Some(&(trait_name, method, _)) = OPERATOR_TRAITS.iter().find(|(_, _, s)| *s == symbol)
+        else {
+            self.errors.push(TypeError::OperatorNotImplemented {
+                op: symbol,
+                ty: format!("{}", lhs),
+                hint: "only + - * / and == can be overloaded".to_string(),
+                line,
+            });
+            return Some(Type::Any);
+        };
+        let result = if is_eq { Type::Bool } else { lhs.clone() };
+        if let Type::Trait(t) = lhs {
+            if trait_extends(self.symbols, t, trait_name) {
+                return Some(result);
+            }
+        } else if implements_trait(self.symbols, &type_name, trait_name) {
+            let qualified = format!("{}.{}", type_name, method);
+            let Some(ci) = self.symbols.cells.get(&qualified).cloned() else {
+                return Some(result);
+            };
+            let subst = TypeSubst::from([("Self".to_string(), lhs.clone())]);
+            let args = [CheckedCallArg::Positional(rhs.clone(), line)];
+            self.check_call_against_signature_with_subst(
+                ci.params.get(1..).unwrap_or(&[]),
+                &args,
+                line,
+                &subst,
+            );
+            return Some(
+                ci.return_type
+                    .as_ref()
+                    .map(|rt| resolve_type_expr_with_subst(rt, self.symbols, &subst))
+                    .unwrap_or(result),
+            );
+        }
+        if is_eq {
+            return None;
+        }
+        let hint = if matches!(lhs, Type::Trait(_)) {
+            format!("trait '{}' does not extend '{}'", type_name, trait_name)
+        } else {
+            format!("add `impl {} for {}`", trait_name, type_name)
+        };
+        self.errors.push(TypeError::OperatorNotImplemented {
+            op: symbol,
+            ty: format!("{}", lhs),
+            hint,
+            line,
+        });
+        Some(Type::Any)
+    }
+
+    /// Whether a value of type `ty` may be used where `trait_name` is required.
+    fn satisfies_trait(&self, ty: &Type, trait_name: &str) -> bool {
+        match ty {
+            Type::Any => true,
+            Type::Trait(t) => trait_extends(self.symbols, t, trait_name),
+            Type::Union(types) => types.iter().all(|t| self.satisfies_trait(t, trait_name)),
+            Type::Record(name) | Type::Enum(name) | Type::TypeRef(name, _) => {
+                implements_trait(self.symbols, name, trait_name)
+            }
+            Type::Int | Type::Float | Type::String | Type::Bool | Type::Bytes => {
+                implements_trait(self.symbols, &ty.to_string(), trait_name)
+            }
+            _ => false,
+        }
+    }
+
+    /// Element type bound by `for x in <iter_type>`, or None if the type is
+    /// not iterable. A record is iterable when its type has a `next` method
+    /// returning `tuple[Elem, Self]?`.
+    fn iter_element_type(&self, iter_type: &Type) -> Option<Type> {
+        match iter_type {
+            Type::List(inner) | Type::Set(inner) => Some(*inner.clone()),
+            Type::Map(k, _) => Some(*k.clone()),
+            Type::String => Some(Type::String),
+            Type::Bytes => Some(Type::Int),
+            Type::Any => Some(Type::Any),
+            Type::Record(name) => {
+                let next = self.symbols.cells.get(&format!("{}.next", name))?;
+                let ret = next
+                    .return_type
+                    .as_ref()
+                    .map(|rt| resolve_type_expr(rt, self.symbols));
+                let step = match ret {
+                    Some(Type::Union(variants)) => variants
+                        .into_iter()
+                        .find(|v| matches!(v, Type::Tuple(elems) if elems.len() == 2)),
+                    other => other,
+                };
+                match step {
+                    Some(Type::Tuple(mut elems)) if elems.len() == 2 => Some(elems.swap_remove(0)),
+                    _ => Some(Type::Any),
+                }
+            }
+            _ => None,
+        }
+    }
+
     fn check_agent_cell(&mut self, cell: &CellDef) {
         self.locals.clear();
         self.mutables.clear();
+        self.list_lens.clear();
         self.locals.insert("self".into(), Type::Any);
         self.mutables.insert("self".into(), true);
         for p in &cell.params {
@@ -882,12 +1679,18 @@ impl<'a> TypeChecker<'a> {
                 }
                 if let Some(ref pattern) = ls.pattern {
                     // Destructuring let — register all bound names from the pattern
-                    self.bind_let_pattern(pattern, &val_type, ls.span.line);
+                    self.bind_let_pattern(pattern, &val_type, ls.span.line, ls.mutable);
                 } else {
                     self.locals.insert(ls.name.clone(), val_type);
-                    // In Lumen, all let bindings are reassignable by default
-                    // `let mut` is just documentation; `const` is immutable
-                    self.mutables.insert(ls.name.clone(), true);
+                    self.mutables.insert(ls.name.clone(), ls.mutable);
+                    match self.known_list_len(&ls.value) {
+                        Some(len) if !ls.mutable => {
+                            self.list_lens.insert(ls.name.clone(), len);
+                        }
+                        _ => {
+                            self.list_lens.remove(&ls.name);
+                        }
+                    }
                 }
             }
             Stmt::If(ifs) => {
@@ -917,9 +1720,7 @@ impl<'a> TypeChecker<'a> {
                     None
                 };
 
-                for s in &ifs.then_body {
-                    self.check_stmt(s, expected_return, false);
-                }
+                self.check_block(&ifs.then_body, expected_return);
 
                 // Restore original type after then-branch
                 if let Some((ref var_name, ref original)) = narrowed {
@@ -931,19 +1732,14 @@ impl<'a> TypeChecker<'a> {
                 }
 
                 if let Some(ref eb) = ifs.else_body {
-                    for s in eb {
-                        self.check_stmt(s, expected_return, false);
-                    }
+                    self.check_block(eb, expected_return);
                 }
             }
             Stmt::For(fs) => {
                 let iter_type = self.infer_expr(&fs.iter);
-                let elem_type = match &iter_type {
-                    Type::List(inner) => *inner.clone(),
-                    Type::Set(inner) => *inner.clone(),
-                    Type::Map(k, _) => *k.clone(),
-                    Type::Any => Type::Any,
-                    _ => {
+                let elem_type = match self.iter_element_type(&iter_type) {
+                    Some(elem) => elem,
+                    None => {
                         self.errors.push(TypeError::Mismatch {
                             expected: "iterable".into(),
                             actual: format!("{}", iter_type),
@@ -952,20 +1748,28 @@ impl<'a> TypeChecker<'a> {
                         Type::Any
                     }
                 };
+                let scope = self.enter_scope();
                 self.locals.insert(fs.var.clone(), elem_type);
+                self.list_lens.remove(&fs.var);
                 if let Some(filter) = &fs.filter {
                     self.infer_expr(filter);
                 }
                 for s in &fs.body {
                     self.check_stmt(s, expected_return, false);
                 }
+                self.exit_scope(scope);
             }
             Stmt::Match(ms) => {
                 let subject_type = self.infer_expr(&ms.subject);
+                let subject_len = self.known_list_len(&ms.subject);
                 let mut covered_variants = Vec::new();
                 let mut has_catchall = false;
 
                 for arm in &ms.arms {
+                    if let Some(len) = subject_len {
+                        self.check_list_pattern_len(&arm.pattern, len, arm.span.line);
+                    }
+                    let scope = self.enter_scope();
                     self.bind_match_pattern(
                         &arm.pattern,
                         &subject_type,
@@ -976,6 +1780,7 @@ impl<'a> TypeChecker<'a> {
                     for s in &arm.body {
                         self.check_stmt(s, expected_return, false);
                     }
+                    self.exit_scope(scope);
                 }
 
                 // Exhaustiveness Check for Enums
@@ -1007,6 +1812,7 @@ impl<'a> TypeChecker<'a> {
                 }
             }
             Stmt::Return(rs) => {
+                self.in_tail_position = matches!(rs.value, Expr::Call(..));
                 let val_type = self.infer_expr(&rs.value);
                 if let Some(expected) = expected_return {
                     self.check_compat(expected, &val_type, rs.span.line);
@@ -1020,10 +1826,13 @@ impl<'a> TypeChecker<'a> {
                 // Check mutability
                 match &asgn.target {
                     AssignTarget::Variable(var_name) => {
-                        if let Some(&is_mut) = self.mutables.get(var_name.as_str()) {
+                        // `p.x = v` parses as the dotted name "p.x"; it is `p`
+                        // that must be mutable.
+                        let root = var_name.split('.').next().unwrap_or(var_name);
+                        if let Some(&is_mut) = self.mutables.get(root) {
                             if !is_mut {
                                 self.errors.push(TypeError::ImmutableAssign {
-                                    name: var_name.to_string(),
+                                    name: root.to_string(),
                                     line: asgn.span.line,
                                 });
                             }
@@ -1031,8 +1840,15 @@ impl<'a> TypeChecker<'a> {
                         self.locals.insert(var_name.to_string(), val_type);
                     }
                     AssignTarget::Index(base, idx) => {
-                        // Check that the base variable is mutable
-                        if let Expr::Ident(base_name, _) = base.as_ref() {
+                        // Check that the base variable is mutable; for
+                        // `m[i][j] = v` or `r.slots[i] = v` that is `m` or `r`.
+                        let mut root = base.as_ref();
+                        while let Expr::IndexAccess(inner, _, _) | Expr::DotAccess(inner, _, _) =
+                            root
+                        {
+                            root = inner;
+                        }
+                        if let Expr::Ident(base_name, _) = root {
                             if let Some(&is_mut) = self.mutables.get(base_name.as_str()) {
                                 if !is_mut {
                                     self.errors.push(TypeError::ImmutableAssign {
@@ -1081,19 +1897,44 @@ impl<'a> TypeChecker<'a> {
                 }
             }
             Stmt::While(ws) => {
+                // A three-clause `for` scopes its `let` to the loop: whatever
+                // the name meant before is restored afterwards.
+                let scoped = match ws.init.as_deref() {
+                    Some(Stmt::Let(ls)) => Some((
+                        ls.name.clone(),
+                        self.locals.get(&ls.name).cloned(),
+                        self.mutables.get(&ls.name).copied(),
+                    )),
+                    _ => None,
+                };
+                if let Some(init) = &ws.init {
+                    self.check_stmt(init, expected_return, false);
+                }
                 let ct = self.infer_expr(&ws.condition);
                 self.check_compat(&Type::Bool, &ct, ws.span.line);
-                for s in &ws.body {
-                    self.check_stmt(s, expected_return, false);
+                self.check_block(&ws.body, expected_return);
+                if let Some(post) = &ws.post {
+                    self.check_stmt(post, expected_return, false);
+                }
+                if let Some((name, prev_type, prev_mut)) = scoped {
+                    match prev_type {
+                        Some(ty) => self.locals.insert(name.clone(), ty),
+                        None => self.locals.remove(&name),
+                    };
+                    match prev_mut {
+                        Some(m) => self.mutables.insert(name, m),
+                        None => self.mutables.remove(&name),
+                    };
                 }
             }
             Stmt::Loop(ls) => {
-                for s in &ls.body {
-                    self.check_stmt(s, expected_return, false);
-                }
+                self.check_block(&ls.body, expected_return);
             }
             Stmt::Break(_) | Stmt::Continue(_) => {}
             Stmt::Defer(ds) => {
+                if let Some(tc) = &mut self.tailcall {
+                    tc.defer_pending = true;
+                }
                 for s in &ds.body {
                     self.check_stmt(s, expected_return, false);
                 }
@@ -1109,10 +1950,13 @@ impl<'a> TypeChecker<'a> {
                 // Check mutability and types
                 match &ca.target {
                     AssignTarget::Variable(var_name) => {
-                        if let Some(&is_mut) = self.mutables.get(var_name.as_str()) {
+                        // `p.x = v` parses as the dotted name "p.x"; it is `p`
+                        // that must be mutable.
+                        let root = var_name.split('.').next().unwrap_or(var_name);
+                        if let Some(&is_mut) = self.mutables.get(root) {
                             if !is_mut {
                                 self.errors.push(TypeError::ImmutableAssign {
-                                    name: var_name.to_string(),
+                                    name: root.to_string(),
                                     line: ca.span.line,
                                 });
                             }
@@ -1138,6 +1982,34 @@ impl<'a> TypeChecker<'a> {
                                         });
                                     }
                                 }
+                                CompoundOp::AddAssign
+                                | CompoundOp::SubAssign
+                                | CompoundOp::MulAssign
+                                | CompoundOp::DivAssign
+                                | CompoundOp::FloorDivAssign
+                                | CompoundOp::ModAssign => {
+                                    let op = match ca.op {
+                                        CompoundOp::AddAssign => BinOp::Add,
+                                        CompoundOp::SubAssign => BinOp::Sub,
+                                        CompoundOp::MulAssign => BinOp::Mul,
+                                        CompoundOp::DivAssign => BinOp::Div,
+                                        CompoundOp::FloorDivAssign => BinOp::FloorDiv,
+                                        _ => BinOp::Mod,
+                                    };
+                                    match self.infer_overloaded_op(
+                                        &existing,
+                                        op,
+                                        &val_type,
+                                        ca.span.line,
+                                    ) {
+                                        Some(result) => {
+                                            self.check_compat(&existing, &result, ca.span.line)
+                                        }
+                                        None => {
+                                            self.check_compat(&existing, &val_type, ca.span.line)
+                                        }
+                                    }
+                                }
                                 _ => {
                                     self.check_compat(&existing, &val_type, ca.span.line);
                                 }
@@ -1145,8 +2017,15 @@ impl<'a> TypeChecker<'a> {
                         }
                     }
                     AssignTarget::Index(base, idx) => {
-                        // Check that the base variable is mutable
-                        if let Expr::Ident(base_name, _) = base.as_ref() {
+                        // Check that the base variable is mutable; for
+                        // `m[i][j] = v` or `r.slots[i] = v` that is `m` or `r`.
+                        let mut root = base.as_ref();
+                        while let Expr::IndexAccess(inner, _, _) | Expr::DotAccess(inner, _, _) =
+                            root
+                        {
+                            root = inner;
+                        }
+                        if let Expr::Ident(base_name, _) = root {
                             if let Some(&is_mut) = self.mutables.get(base_name.as_str()) {
                                 if !is_mut {
                                     self.errors.push(TypeError::ImmutableAssign {
@@ -1185,11 +2064,18 @@ impl<'a> TypeChecker<'a> {
     /// used in `let` position.  Walks the pattern tree and inserts each
     /// bound name into `self.locals` with the appropriate type.
     #[allow(clippy::only_used_in_recursion)]
-    fn bind_let_pattern(&mut self, pattern: &Pattern, subject_type: &Type, line: usize) {
+    fn bind_let_pattern(
+        &mut self,
+        pattern: &Pattern,
+        subject_type: &Type,
+        line: usize,
+        mutable: bool,
+    ) {
         match pattern {
             Pattern::Ident(name, _) => {
                 self.locals.insert(name.clone(), subject_type.clone());
-                self.mutables.insert(name.clone(), true);
+                self.mutables.insert(name.clone(), mutable);
+                self.list_lens.remove(name);
             }
             Pattern::Wildcard(_) => {}
             Pattern::TupleDestructure { elements, .. } => {
@@ -1199,7 +2085,7 @@ impl<'a> TypeChecker<'a> {
                 };
                 for (idx, p) in elements.iter().enumerate() {
                     let ty = elem_types.get(idx).cloned().unwrap_or(Type::Any);
-                    self.bind_let_pattern(p, &ty, line);
+                    self.bind_let_pattern(p, &ty, line, mutable);
                 }
             }
             Pattern::RecordDestructure {
@@ -1220,11 +2106,11 @@ impl<'a> TypeChecker<'a> {
                         Type::Any
                     };
                     if let Some(p) = field_pat {
-                        self.bind_let_pattern(p, &field_ty, line);
+                        self.bind_let_pattern(p, &field_ty, line, mutable);
                     } else {
                         // Shorthand `field_name:` — bind to same name
                         self.locals.insert(field_name.clone(), field_ty);
-                        self.mutables.insert(field_name.clone(), true);
+                        self.mutables.insert(field_name.clone(), mutable);
                     }
                 }
             }
@@ -1234,12 +2120,12 @@ impl<'a> TypeChecker<'a> {
                     _ => Type::Any,
                 };
                 for p in elements {
-                    self.bind_let_pattern(p, &elem_type, line);
+                    self.bind_let_pattern(p, &elem_type, line, mutable);
                 }
                 if let Some(rest_name) = rest {
                     self.locals
                         .insert(rest_name.clone(), Type::List(Box::new(elem_type)));
-                    self.mutables.insert(rest_name.clone(), true);
+                    self.mutables.insert(rest_name.clone(), mutable);
                 }
             }
             Pattern::TypeCheck {
@@ -1248,7 +2134,7 @@ impl<'a> TypeChecker<'a> {
                 let expected = resolve_type_expr(type_expr, self.symbols);
                 self.check_compat(&expected, subject_type, line);
                 self.locals.insert(name.clone(), expected);
-                self.mutables.insert(name.clone(), true);
+                self.mutables.insert(name.clone(), mutable);
             }
             _ => {
                 // Other patterns (Guard, Or, Variant, etc.) not valid in let position
@@ -1346,6 +2232,7 @@ impl<'a> TypeChecker<'a> {
                 if !is_variant {
                     // Regular identifier pattern - binds the value
                     self.locals.insert(name.clone(), subject_type.clone());
+                    self.list_lens.remove(name);
                     *has_catchall = true;
                 }
             }
@@ -1465,7 +2352,14 @@ impl<'a> TypeChecker<'a> {
                 self.check_compat(&expected, subject_type, line);
                 self.locals.insert(name.clone(), expected);
             }
-            Pattern::Literal(_) => {}
+            Pattern::Literal(lit) => {
+                // Int and String subjects (including `switch` cases) must be
+                // compared against literals of the same type.
+                let lit_ty = self.infer_expr(lit);
+                if matches!(subject_type, Type::Int | Type::String) {
+                    self.check_compat(subject_type, &lit_ty, line);
+                }
+            }
             Pattern::Range { start, end, .. } => {
                 let start_ty = self.infer_expr(start);
                 let end_ty = self.infer_expr(end);
@@ -1622,6 +2516,12 @@ impl<'a> TypeChecker<'a> {
                     Type::List(Box::new(first))
                 }
             }
+            Expr::ListRepeat(value, count, _) => {
+                let elem = self.infer_expr(value);
+                let count_type = self.infer_expr(count);
+                self.check_compat(&Type::Int, &count_type, count.span().line);
+                Type::List(Box::new(elem))
+            }
             Expr::MapLit(pairs, _) => {
                 if pairs.is_empty() {
                     Type::Map(Box::new(Type::String), Box::new(Type::Any))
@@ -1709,6 +2609,9 @@ impl<'a> TypeChecker<'a> {
             Expr::BinOp(lhs, op, rhs, _span) => {
                 let lt = self.infer_expr(lhs);
                 let rt = self.infer_expr(rhs);
+                if let Some(ty) = self.infer_overloaded_op(&lt, *op, &rt, _span.line) {
+                    return ty;
+                }
                 match op {
                     BinOp::Add
                     | BinOp::Sub
@@ -1788,6 +2691,8 @@ impl<'a> TypeChecker<'a> {
                 }
             }
             Expr::Call(callee, args, span) => {
+                let in_tail_position = std::mem::take(&mut self.in_tail_position);
+                self.check_tail_self_call(callee, in_tail_position, span.line);
                 let mut checked_args = Vec::new();
                 for arg in args {
                     match arg {
@@ -1806,6 +2711,12 @@ impl<'a> TypeChecker<'a> {
                         CallArg::Role(_, _, _) => {}
                     }
                 }
+                if let Expr::DotAccess(obj, method, _) = callee.as_ref() {
+                    if let Some(ret) = self.infer_method_call(obj, method, &checked_args, *span)
+                    {
+                        return ret;
+                    }
+                }
                 // Try to resolve the return type
                 if let Expr::Ident(name, _) = callee.as_ref() {
                     // Check if it's a cell/function call
@@ -1855,6 +2766,19 @@ impl<'a> TypeChecker<'a> {
                                 .iter()
                                 .map(|p| inferred.get(p).cloned().unwrap_or(Type::Any))
                                 .collect();
+                            if let Some(bounds) = self.generic_bounds.get(name).cloned() {
+                                for (param, ty) in ci.generic_params.iter().zip(&generic_args) {
+                                    for bound in bounds.get(param).into_iter().flatten() {
+                                        if !self.satisfies_trait(ty, bound) {
+                                            self.errors.push(TypeError::TraitNotImplemented {
+                                                ty: format!("{}", ty),
+                                                trait_name: bound.clone(),
+                                                line: span.line,
+                                            });
+                                        }
+                                    }
+                                }
+                            }
                             let subst = build_subst(&ci.generic_params, &generic_args);
 
                             // Check call with substituted param types
@@ -1911,6 +2835,45 @@ impl<'a> TypeChecker<'a> {
                             // Build substitution map for generic parameters
                             let subst = build_subst(&ti.generic_params, &generic_args);
 
+                            // A `..source` argument supplies every field not given
+                            // explicitly, so it must be a value of this record
+                            let spreads: Vec<(&Type, usize)> = args
+                                .iter()
+                                .filter(|a| !matches!(a, CallArg::Role(_, _, _)))
+                                .zip(&checked_args)
+                                .filter_map(|(arg, checked)| match (arg, checked) {
+                                    (
+                                        CallArg::Positional(Expr::SpreadExpr(_, _)),
+                                        CheckedCallArg::Positional(ty, line),
+                                    ) => Some((ty, *line)),
+                                    _ => None,
+                                })
+                                .collect();
+                            if spreads.len() > 1 {
+                                self.errors.push(TypeError::InvalidSpread {
+                                    ty: name.clone(),
+                                    reason: "only one spread source is allowed".into(),
+                                    line: spreads[1].1,
+                                });
+                            }
+                            for (src_ty, line) in &spreads {
+                                let same_record = match src_ty {
+                                    Type::Record(n) | Type::TypeRef(n, _) => n == name,
+                                    Type::Any => true,
+                                    _ => false,
+                                };
+                                if !same_record {
+                                    self.errors.push(TypeError::InvalidSpread {
+                                        ty: name.clone(),
+                                        reason: format!(
+                                            "spread source has type {}, expected {}",
+                                            src_ty, name
+                                        ),
+                                        line: *line,
+                                    });
+                                }
+                            }
+
                             // Check constructor arguments match record fields
                             for checked_arg in &checked_args {
                                 if let CheckedCallArg::Named(fname, arg_ty, line) = checked_arg {
@@ -1948,6 +2911,9 @@ impl<'a> TypeChecker<'a> {
 
                     // Check for builtin function with known return type
                     if is_builtin_function(name) {
+                        if name == "sizeof" || name == "alignof" {
+                            self.check_layout_arg(name, args, &checked_args, span.line);
+                        }
                         let arg_types: Vec<Type> = checked_args
                             .iter()
                             .map(|a| match a {
@@ -2012,6 +2978,7 @@ impl<'a> TypeChecker<'a> {
                 match ot {
                     Type::List(inner) => *inner,
                     Type::Map(_, v) => *v,
+                    Type::String => Type::Int,
                     _ => Type::Any,
                 }
             }
@@ -2045,6 +3012,7 @@ impl<'a> TypeChecker<'a> {
                     }
                     self.locals.insert(p.name.clone(), pt.clone());
                     self.mutables.insert(p.name.clone(), true);
+                    self.list_lens.remove(&p.name);
                     param_types.push(pt);
                 }
                 let ret = if let Some(ref rt) = return_type {
@@ -2089,7 +3057,8 @@ impl<'a> TypeChecker<'a> {
                     self.infer_expr(e);
                 }
                 if let Some(ref st) = step {
-                    self.infer_expr(st);
+                    let step_type = self.infer_expr(st);
+                    self.check_compat(&Type::Int, &step_type, st.span().line);
                 }
                 Type::List(Box::new(Type::Int))
             }
@@ -2282,8 +3251,8 @@ impl<'a> TypeChecker<'a> {
                 let ct = self.infer_expr(cond);
                 self.check_compat(&Type::Bool, &ct, cond.span().line);
                 let tt = self.infer_expr(then_val);
-                self.infer_expr(else_val);
-                tt
+                let et = self.infer_expr(else_val);
+                self.unify_if_branches(tt, et, else_val.span().line)
             }
             Expr::AwaitExpr(inner, _) => self.infer_expr(inner),
             Expr::Comprehension {
@@ -2299,21 +3268,17 @@ impl<'a> TypeChecker<'a> {
                 let saved_locals = self.locals.clone();
 
                 let iter_type = self.infer_expr(iter);
-                let elem_type = match &iter_type {
-                    Type::List(inner) => *inner.clone(),
-                    Type::Set(inner) => *inner.clone(),
-                    _ => Type::Any,
-                };
+                let elem_type = self.iter_element_type(&iter_type).unwrap_or(Type::Any);
                 self.locals.insert(var.clone(), elem_type);
+                self.list_lens.remove(var);
                 // Register bindings for extra for-clauses
                 for clause in extra_clauses {
                     let clause_iter_type = self.infer_expr(&clause.iter);
-                    let clause_elem_type = match &clause_iter_type {
-                        Type::List(inner) => *inner.clone(),
-                        Type::Set(inner) => *inner.clone(),
-                        _ => Type::Any,
-                    };
+                    let clause_elem_type = self
+                        .iter_element_type(&clause_iter_type)
+                        .unwrap_or(Type::Any);
                     self.locals.insert(clause.var.clone(), clause_elem_type);
+                    self.list_lens.remove(&clause.var);
                 }
                 if let Some(ref cond) = condition {
                     let ct = self.infer_expr(cond);
@@ -2336,11 +3301,16 @@ impl<'a> TypeChecker<'a> {
                 span,
             } => {
                 let subject_type = self.infer_expr(subject);
+                let subject_len = self.known_list_len(subject);
                 let mut covered_variants = Vec::new();
                 let mut has_catchall = false;
-                let mut result_type = Type::Any;
+                let mut result_type = Type::Never;
+                let mut saw_arm_type = false;
 
                 for arm in arms {
+                    if let Some(len) = subject_len {
+                        self.check_list_pattern_len(&arm.pattern, len, arm.span.line);
+                    }
                     self.bind_match_pattern(
                         &arm.pattern,
                         &subject_type,
@@ -2351,13 +3321,25 @@ impl<'a> TypeChecker<'a> {
                     for s in &arm.body {
                         self.check_stmt(s, None, false);
                     }
-                    // Infer type from last expression in arm body
-                    if let Some(Stmt::Expr(es)) = arm.body.last() {
-                        result_type = self.infer_expr(&es.expr);
+                    // Infer type from last expression in arm body; arms that
+                    // diverge don't contribute
+                    let arm_type = if arm.body.iter().any(stmt_diverges) {
+                        Some(Type::Never)
+                    } else if let Some(Stmt::Expr(es)) = arm.body.last() {
+                        Some(self.infer_expr(&es.expr))
                     } else if let Some(Stmt::Return(rs)) = arm.body.last() {
-                        result_type = self.infer_expr(&rs.value);
+                        Some(self.infer_expr(&rs.value))
+                    } else {
+                        None
+                    };
+                    if let Some(ty) = arm_type {
+                        result_type = join_branch_types(ty, result_type);
+                        saw_arm_type = true;
                     }
                 }
+                if !saw_arm_type {
+                    result_type = Type::Any;
+                }
 
                 // Exhaustiveness check for enums
                 if let Type::Enum(ref name) = subject_type {
@@ -2393,6 +3375,9 @@ impl<'a> TypeChecker<'a> {
                 for s in stmts {
                     self.check_stmt(s, None, false);
                 }
+                if stmts.iter().any(stmt_diverges) {
+                    return Type::Never;
+                }
                 // Infer type from last expression in block
                 if let Some(Stmt::Expr(es)) = stmts.last() {
                     self.infer_expr(&es.expr)
@@ -2403,13 +3388,18 @@ impl<'a> TypeChecker<'a> {
             Expr::WhenExpr {
                 arms, else_body, ..
             } => {
-                let mut result_type = Type::Any;
+                let mut result_type = Type::Never;
                 for arm in arms {
                     self.infer_expr(&arm.condition);
-                    result_type = self.infer_expr(&arm.body);
+                    let arm_type = self.infer_expr(&arm.body);
+                    result_type = join_branch_types(arm_type, result_type);
                 }
                 if let Some(eb) = else_body {
-                    result_type = self.infer_expr(eb);
+                    let else_type = self.infer_expr(eb);
+                    result_type = join_branch_types(else_type, result_type);
+                }
+                if result_type == Type::Never && arms.is_empty() && else_body.is_none() {
+                    result_type = Type::Any;
                 }
                 result_type
             }
@@ -2438,8 +3428,35 @@ impl<'a> TypeChecker<'a> {
         }
     }
 
+    /// The type of a conditional expression. An `Int` branch widens to a
+    /// `Float` one and a `null` branch makes the result nullable; otherwise
+    /// the `else` value must fit the type of the `then` value.
+    fn unify_if_branches(&mut self, then_type: Type, else_type: Type, line: usize) -> Type {
+        match (&then_type, &else_type) {
+            (Type::Never, _) => else_type,
+            (_, Type::Never) => then_type,
+            (Type::Int, Type::Float) | (Type::Float, Type::Int) => Type::Float,
+            (Type::Null, _) | (_, Type::Null) => {
+                let other = if then_type == Type::Null {
+                    else_type
+                } else {
+                    then_type
+                };
+                match other {
+                    Type::Null => Type::Null,
+                    Type::Union(ref members) if members.contains(&Type::Null) => other,
+                    _ => Type::Union(vec![other, Type::Null]),
+                }
+            }
+            _ => {
+                self.check_compat(&then_type, &else_type, line);
+                then_type
+            }
+        }
+    }
+
     fn check_compat(&mut self, expected: &Type, actual: &Type, line: usize) {
-        if *expected == Type::Any || *actual == Type::Any {
+        if *expected == Type::Any || *actual == Type::Any || *actual == Type::Never {
             return;
         }
         if type_contains_any(expected) || type_contains_any(actual) {
@@ -2449,9 +3466,31 @@ impl<'a> TypeChecker<'a> {
             return;
         }
 
+        // Trait objects accept any type with an impl of the trait
+        if let Type::Trait(trait_name) = expected {
+            if !self.satisfies_trait(actual, trait_name) {
+                self.errors.push(TypeError::TraitNotImplemented {
+                    ty: format!("{}", actual),
+                    trait_name: trait_name.clone(),
+                    line,
+                });
+            }
+            return;
+        }
+        if let (Type::List(e), Type::List(a)) = (expected, actual) {
+            if matches!(**e, Type::Trait(_)) {
+                self.check_compat(e, a, line);
+                return;
+            }
+        }
+
         // Union compatibility: actual is compatible if it matches any member of expected union
         if let Type::Union(ref types) = expected {
-            if types.iter().any(|t| t == actual || *t == Type::Any) {
+            if types.iter().any(|t| {
+                t == actual
+                    || *t == Type::Any
+                    || matches!(t, Type::Trait(n) if self.satisfies_trait(actual, n))
+            }) {
                 return;
             }
         }
@@ -2716,10 +3755,47 @@ fn extract_int_lit(expr: &Expr) -> Option<i64> {
 
 /// Typecheck a program.
 pub fn typecheck(program: &Program, symbols: &SymbolTable) -> Result<(), Vec<TypeError>> {
+    let mut checker = check_program(program, symbols);
+    for err in evaluate_consts(program).1 {
+        checker.errors.push(TypeError::NotConst {
+            name: err.name,
+            reason: err.reason,
+            line: err.line,
+        });
+    }
+    if checker.errors.is_empty() {
+        Ok(())
+    } else {
+        Err(checker.errors)
+    }
+}
+
+/// Whether each method call the checker resolved takes a `mut` receiver, by
+/// the byte range of the call. Calls it could not resolve are left out.
+pub fn method_receivers(
+    program: &Program,
+    symbols: &SymbolTable,
+) -> HashMap<(usize, usize), bool> {
+    check_program(program, symbols).method_receivers
+}
+
+fn check_program<'a>(program: &Program, symbols: &'a SymbolTable) -> TypeChecker<'a> {
     let strict = parse_directive_bool(program, "strict").unwrap_or(true);
     let doc_mode = parse_directive_bool(program, "doc_mode").unwrap_or(false);
     let allow_placeholders = doc_mode || !strict;
     let mut checker = TypeChecker::new(symbols, allow_placeholders);
+    for item in &program.items {
+        if let Item::Cell(c) = item {
+            if c.generic_params.iter().any(|gp| !gp.bounds.is_empty()) {
+                let bounds = c
+                    .generic_params
+                    .iter()
+                    .map(|gp| (gp.name.clone(), gp.bounds.clone()))
+                    .collect();
+                checker.generic_bounds.insert(c.name.clone(), bounds);
+            }
+        }
+    }
     for item in &program.items {
         match item {
             Item::Cell(c) => checker.check_cell(c),
@@ -2745,18 +3821,17 @@ pub fn typecheck(program: &Program, symbols: &SymbolTable) -> Result<(), Vec<Typ
             }
             Item::Impl(i) => {
                 // T208: typecheck each impl method with its own scope.
+                let base = i.target_type.split('[').next().unwrap_or(&i.target_type);
+                let self_type =
+                    resolve_type_expr(&TypeExpr::Named(base.to_string(), i.span), symbols);
                 for method in &i.cells {
-                    checker.check_cell(method);
+                    checker.check_cell_with_self(method, Some(self_type.clone()));
                 }
             }
             _ => {}
         }
     }
-    if checker.errors.is_empty() {
-        Ok(())
-    } else {
-        Err(checker.errors)
-    }
+    checker
 }
 
 #[cfg(test)]
@@ -2877,7 +3952,7 @@ mod tests {
     fn test_compound_assign_bitwise_requires_int() {
         // Bitwise compound assignment on non-Int should error
         let err =
-            typecheck_src("cell bad() -> String\n  let x = \"hello\"\n  x &= 1\n  return x\nend")
+            typecheck_src("cell bad() -> String\n  var x = \"hello\"\n  x &= 1\n  return x\nend")
                 .unwrap_err();
         assert!(err
             .iter()
@@ -2887,7 +3962,31 @@ mod tests {
     #[test]
     fn test_compound_assign_add_is_valid() {
         // Basic compound assignment should work
-        typecheck_src("cell inc() -> Int\n  let x = 1\n  x += 2\n  return x\nend").unwrap();
+        typecheck_src("cell inc() -> Int\n  var x = 1\n  x += 2\n  return x\nend").unwrap();
+    }
+
+    #[test]
+    fn test_let_binding_is_immutable() {
+        for body in ["x = 2", "x += 2", "xs[0] = 2", "p.x = 2", "p.x[0] = 2"] {
+            let src = format!(
+                "cell f() -> Int\n  let x = 1\n  let xs = [1]\n  let p = {{\"x\": 1}}\n  {}\n  return x\nend",
+                body
+            );
+            let err = typecheck_src(&src).unwrap_err();
+            let name = body.split(['[', '.', ' ']).next().unwrap();
+            assert!(
+                err.iter().any(
+                    |e| matches!(e, TypeError::ImmutableAssign { name: n, line: 5 } if n == name)
+                ),
+                "{}: {:?}",
+                body,
+                err
+            );
+        }
+        typecheck_src(
+            "cell f() -> Int\n  let mut x = 1\n  var y = 2\n  x = y\n  y += x\n  return y\nend",
+        )
+        .unwrap();
     }
 
     #[test]
@@ -2906,6 +4005,36 @@ mod tests {
             .any(|e| matches!(e, TypeError::Mismatch { expected, .. } if expected == "Int")));
     }
 
+    #[test]
+    fn test_block_binding_shadows_until_block_ends() {
+        // Inside the block `label` is an Int; afterwards it is the outer String
+        typecheck_src(
+            "cell main() -> String\n  let label = \"outer\"\n  if true\n    let label = 42\n    let n: Int = label + 1\n  end\n  return label\nend",
+        )
+        .unwrap();
+    }
+
+    #[test]
+    fn test_block_binding_not_visible_after_block() {
+        let err = typecheck_src(
+            "cell main() -> Int\n  for i in [1, 2]\n    let doubled = i * 2\n  end\n  return doubled\nend",
+        )
+        .unwrap_err();
+        assert!(err
+            .iter()
+            .any(|e| matches!(e, TypeError::UndefinedVar { name, .. } if name == "doubled")));
+    }
+
+    #[test]
+    fn test_sizeof_value_stays_runtime_but_alignof_needs_type() {
+        typecheck_src("cell main() -> Int\n  let xs = [1, 2]\n  return sizeof(xs)\nend").unwrap();
+        let err = typecheck_src("cell main() -> Int\n  let xs = [1, 2]\n  return alignof(xs)\nend")
+            .unwrap_err();
+        assert!(err
+            .iter()
+            .any(|e| matches!(e, TypeError::Mismatch { expected, .. } if expected == "type name")));
+    }
+
     #[test]
     fn test_validation_error_not_hardcoded() {
         // ValidationError is no longer hardcoded as a builtin type;
@@ -442,7 +442,7 @@ impl TypestateChecker {
             }
             Stmt::While(ws) => {
                 self.check_expr(&ws.condition);
-                for s in &ws.body {
+                for s in ws.all_stmts() {
                     self.check_stmt(s);
                 }
             }
@@ -562,6 +562,10 @@ impl TypestateChecker {
                     self.check_expr(e);
                 }
             }
+            Expr::ListRepeat(value, count, _) => {
+                self.check_expr(value);
+                self.check_expr(count);
+            }
             Expr::MapLit(entries, _) => {
                 for (k, v) in entries {
                     self.check_expr(k);
@@ -1134,11 +1138,16 @@ mod tests {
             is_pub: false,
             is_async: false,
             is_extern: false,
+            is_const: false,
             must_use: false,
             where_clauses: vec![],
             span: span(1),
             doc: None,
             deprecated: None,
+            receiver: None,
+            specializations: vec![],
+            tailcall: false,
+            memoize: None,
         }
     }
 
@@ -1147,6 +1156,7 @@ mod tests {
         Stmt::Let(LetStmt {
             name: var.to_string(),
             mutable: false,
+            is_var: false,
             pattern: None,
             ty: None,
             value: Expr::Call(
@@ -435,7 +435,7 @@ fn check_stmt_calls(
         }
         Stmt::While(while_stmt) => {
             check_expr_calls(&while_stmt.condition, caller_name, contracts, ctx, results);
-            for s in &while_stmt.body {
+            for s in while_stmt.all_stmts() {
                 check_stmt_calls(s, caller_name, contracts, ctx, results);
             }
         }
@@ -673,7 +673,7 @@ fn count_effect_calls_in_stmt(stmt: &Stmt, counts: &mut HashMap<String, u32>) {
         }
         Stmt::While(while_stmt) => {
             count_effect_calls_in_expr(&while_stmt.condition, counts);
-            for s in &while_stmt.body {
+            for s in while_stmt.all_stmts() {
                 count_effect_calls_in_stmt(s, counts);
             }
         }
@@ -762,11 +762,13 @@ mod tests {
                     default_value: None,
                     constraint: Some(constraint),
                     span: span(),
+                    attributes: vec![],
                 }],
                 is_pub: false,
                 span: span(),
                 doc: None,
                 deprecated: None,
+                packed: false,
             })],
             span: span(),
         }
@@ -817,11 +819,16 @@ mod tests {
                 is_pub: false,
                 is_async: false,
                 is_extern: false,
+                is_const: false,
                 must_use: false,
                 where_clauses: vec![wc],
                 span: span(),
                 doc: None,
                 deprecated: None,
+                receiver: None,
+                specializations: vec![],
+                tailcall: false,
+                memoize: None,
             })],
             span: span(),
         };
@@ -888,11 +895,13 @@ mod tests {
                     default_value: None,
                     constraint: None,
                     span: span(),
+                    attributes: vec![],
                 }],
                 is_pub: false,
                 span: span(),
                 doc: None,
                 deprecated: None,
+                packed: false,
             })],
             span: span(),
         };
@@ -936,11 +945,16 @@ mod tests {
             is_pub: false,
             is_async: false,
             is_extern: false,
+            is_const: false,
             must_use: false,
             where_clauses,
             span: span(),
             doc: None,
             deprecated: None,
+            receiver: None,
+            specializations: vec![],
+            tailcall: false,
+            memoize: None,
         }
     }
 
@@ -202,8 +202,14 @@ pub fn type_to_smt_sort(ty: &Type, symbols: Option<&SymbolTable>) -> Result<SmtS
         Type::Generic(name) => Err(MappingError::Unsupported(format!("Generic({})", name))),
         Type::TypeRef(name, _) => Err(MappingError::Unsupported(format!("TypeRef({})", name))),
 
+        // Trait objects are dispatched at runtime.
+        Type::Trait(name) => Err(MappingError::Unsupported(format!("Trait({})", name))),
+
         // Any is inherently unverifiable.
         Type::Any => Err(MappingError::Unsupported("Any".to_string())),
+
+        // Never has no values to map.
+        Type::Never => Err(MappingError::Unsupported("Never".to_string())),
     }
 }
 
@@ -60,6 +60,11 @@ impl Diagnostic {
                 Some("E0207") => "IMMUTABLE ASSIGN",
                 Some("E0208") => "INCOMPLETE MATCH",
                 Some("E0209") => "MUST USE",
+                Some("E0210") => "BAD RECEIVER",
+                Some("E0211") => "TRAIT NOT IMPLEMENTED",
+                Some("E0212") => "UNDEFINED OPERATOR",
+                Some("E0213") => "NOT CONST",
+                Some("E0214") => "INVALID SPREAD",
                 Some("E0300") => "CONSTRAINT ERROR",
                 Some(c) if c.starts_with("E04") => "OWNERSHIP ERROR",
                 Some("E0500") => "LOWERING ERROR",
@@ -240,6 +240,11 @@ fn opcode_info(op: &OpCode) -> (&str, &str, &str) {
         ForIn => ("A, B, C", "For-in iterator step", "Control Flow"),
         Break => ("sAx", "Break from enclosing loop", "Control Flow"),
         Continue => ("sAx", "Continue to next loop iteration", "Control Flow"),
+        JmpTable => (
+            "A, B, C",
+            "Jump through entry R[A] - R[C] of the B Jmps that follow; skip them if out of range",
+            "Control Flow",
+        ),
 
         // Intrinsics
         Intrinsic => (
@@ -440,6 +445,13 @@ fn intrinsic_description(id: &IntrinsicId) -> &str {
         Args => "Return command-line arguments as a list of strings",
         SetEnv => "Set an environment variable",
         EnvVars => "Return all environment variables as a map",
+        IterNext => {
+            "Advance a for-loop iterator; yields the next element or clears the has-more flag"
+        }
+        RangeCount => "Count the values in a stepped range without building it",
+        RangeStep => "Create a list of integers from start toward end by step",
+        ListRepeat => "Create a list holding count copies of a value",
+        SwitchHash => "Hash a string switch subject to its jump-table slot",
     }
 }
 
@@ -466,6 +478,11 @@ const KEYWORDS: &[(&str, &str, &str)] = &[
         "Control Flow",
     ),
     ("match", "Pattern-match on a value", "Control Flow"),
+    (
+        "switch",
+        "Branch on an Int or String value with case arms",
+        "Control Flow",
+    ),
     ("return", "Return a value from a cell", "Control Flow"),
     ("halt", "Halt execution with an error", "Control Flow"),
     (
@@ -482,7 +499,8 @@ const KEYWORDS: &[(&str, &str, &str)] = &[
     ("cell", "Define a function (cell)", "Declarations"),
     ("record", "Define a record type", "Declarations"),
     ("enum", "Define an enum type", "Declarations"),
-    ("let", "Bind a local variable", "Declarations"),
+    ("let", "Bind an immutable local variable", "Declarations"),
+    ("var", "Bind a reassignable local variable", "Declarations"),
     ("type", "Define a type alias", "Declarations"),
     ("fn", "Anonymous function / lambda keyword", "Declarations"),
     ("trait", "Define a trait", "Declarations"),
@@ -543,7 +561,11 @@ const KEYWORDS: &[(&str, &str, &str)] = &[
     ("with", "Attach handler or options", "AI/Tool"),
     ("step", "Pipeline stage declaration", "AI/Tool"),
     // Modifiers
-    ("mut", "Mark a binding as mutable", "Modifiers"),
+    (
+        "mut",
+        "Mutable receiver; `let mut` is a deprecated spelling of `var`",
+        "Modifiers",
+    ),
     ("self", "Reference to the current instance", "Modifiers"),
     ("end", "Block terminator", "Modifiers"),
     ("comptime", "Compile-time evaluation block", "Modifiers"),
@@ -886,6 +908,10 @@ const STATEMENT_FORMS: &[(&str, &str)] = &[
     ("If", "Conditional statement: if cond ... else ... end"),
     ("For", "For loop: for x in collection ... end"),
     ("Match", "Pattern matching: match expr ... end"),
+    (
+        "Switch",
+        "Value dispatch: switch expr case 1, 2 ... default ... end",
+    ),
     ("Return", "Return a value from a cell"),
     ("Halt", "Halt execution with an error message"),
     ("Assign", "Variable assignment: x = expr"),
@@ -927,7 +953,7 @@ const EXPRESSION_FORMS: &[(&str, &str)] = &[
     ("DotAccess", "Field access: record.field"),
     ("IndexAccess", "Index access: list[0]"),
     ("Lambda", "Anonymous function: fn(x) -> x * 2 end"),
-    ("RangeExpr", "Range expression: 1..10, 1..=10"),
+    ("RangeExpr", "Range expression: 1..10, 1..=10, 0..10 step 2"),
     ("TryExpr", "Try expression for error handling"),
     ("NullCoalesce", "Null coalescing: x ?? default"),
     ("NullSafeAccess", "Null-safe access: x?.field"),
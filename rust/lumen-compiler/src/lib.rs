@@ -8,9 +8,12 @@ pub mod lang_ref;
 pub mod markdown;
 
 use compiler::ast::{Directive, ImportDecl, ImportList, Item};
-use compiler::lir::LirModule;
+use compiler::incremental::CellStore;
+use compiler::lir::{LirAddon, LirModule};
+use compiler::opt_report::OptReport;
 use compiler::resolve::SymbolTable;
 use std::collections::HashSet;
+use std::sync::{Arc, Mutex};
 
 use thiserror::Error;
 
@@ -52,6 +55,17 @@ pub struct CompileOptions {
     pub allow_unstable: bool,
     /// Language edition for forward-compatibility. Default: `"2026"`.
     pub edition: String,
+    /// Lowered cells kept between builds, so that only edited cells are
+    /// lowered again. Default: `None`.
+    pub cell_store: Option<Arc<Mutex<dyn CellStore>>>,
+    /// Reassociate float constant chains and turn division by a constant
+    /// into multiplication (see `compiler::fast_math`). Results may differ
+    /// in the last bits from the program as written. Default: `false`.
+    pub fast_math: bool,
+    /// Filled with the optimizations that fired or were blocked in each
+    /// cell of the last module lowered. Asking for a report lowers every
+    /// cell, so `cell_store` is not used. Default: `None`.
+    pub opt_report: Option<Arc<Mutex<OptReport>>>,
 }
 
 impl Default for CompileOptions {
@@ -63,6 +77,9 @@ impl Default for CompileOptions {
             session_actions: std::collections::HashMap::new(),
             allow_unstable: false,
             edition: "2026".to_string(),
+            cell_store: None,
+            fast_math: false,
+            opt_report: None,
         }
     }
 }
@@ -182,17 +199,40 @@ fn run_optional_analyses(
     errors
 }
 
+/// Record `path`, an import path such as `std.collections`, as the module of
+/// every cell in `module`; `backtrace()` reports it as the frame's file.
+fn tag_cell_module(module: &mut LirModule, path: &str) {
+    let addons: Vec<LirAddon> = module
+        .cells
+        .iter()
+        .map(|cell| LirAddon {
+            kind: "cell_module".into(),
+            name: Some(format!("{}={}", cell.name, path)),
+        })
+        .collect();
+    module.addons.extend(addons);
+}
+
 /// Safe wrapper around the lowering pass that converts register allocation
 /// panics into proper `CompileError::Lower` errors instead of crashing.
 fn lower_safe(
     program: &compiler::ast::Program,
     symbols: &SymbolTable,
     source: &str,
+    cell_store: Option<&Arc<Mutex<dyn CellStore>>>,
 ) -> Result<LirModule, CompileError> {
-    std::panic::catch_unwind(std::panic::AssertUnwindSafe(|| {
-        compiler::lower::lower(program, symbols, source)
-    }))
-    .map_err(|panic_val| {
+    catch_lowering_panic(|| match cell_store {
+        Some(store) => {
+            let mut store = store.lock().unwrap_or_else(|e| e.into_inner());
+            compiler::lower::lower_with_store(program, symbols, source, &mut *store)
+        }
+        None => compiler::lower::lower(program, symbols, source),
+    })
+}
+
+/// Run `lower`, turning a panic into [`CompileError::Lower`].
+fn catch_lowering_panic<T>(lower: impl FnOnce() -> T) -> Result<T, CompileError> {
+    std::panic::catch_unwind(std::panic::AssertUnwindSafe(lower)).map_err(|panic_val| {
         let msg = if let Some(s) = panic_val.downcast_ref::<String>() {
             s.clone()
         } else if let Some(s) = panic_val.downcast_ref::<&str>() {
@@ -204,6 +244,31 @@ fn lower_safe(
     })
 }
 
+/// `lower_safe` for a pipeline run with `options`.
+fn lower_with_options(
+    program: &compiler::ast::Program,
+    symbols: &SymbolTable,
+    source: &str,
+    options: &CompileOptions,
+) -> Result<LirModule, CompileError> {
+    let rewritten;
+    let program = if options.fast_math {
+        let mut program = program.clone();
+        compiler::fast_math::rewrite(&mut program);
+        rewritten = program;
+        &rewritten
+    } else {
+        program
+    };
+    if let Some(report) = &options.opt_report {
+        let (module, notes) =
+            catch_lowering_panic(|| compiler::lower::lower_with_report(program, symbols, source))?;
+        *report.lock().unwrap_or_else(|e| e.into_inner()) = notes;
+        return Ok(module);
+    }
+    lower_safe(program, symbols, source, options.cell_store.as_ref())
+}
+
 /// Compile with access to external modules for import resolution.
 ///
 /// The `resolve_import` callback takes a module path (e.g., "mathlib") and returns
@@ -236,7 +301,7 @@ fn compile_with_imports_internal(
     source: &str,
     resolve_import: &dyn Fn(&str) -> Option<String>,
     compilation_stack: &mut HashSet<String>,
-    _current_module: Option<&str>,
+    current_module: Option<&str>,
     options: &CompileOptions,
 ) -> Result<LirModule, CompileError> {
     // 1. Extract Markdown blocks
@@ -478,11 +543,16 @@ fn compile_with_imports_internal(
     }
 
     // 11. Lower to LIR
-    let mut module = lower_safe(&program, &symbols, source)?;
+    let mut module = lower_with_options(&program, &symbols, source, options)?;
+    if let Some(path) = current_module {
+        tag_cell_module(&mut module, path);
+    }
 
     // 11. Merge imported modules
     for imported_module in imported_modules {
-        module.merge(&imported_module);
+        module
+            .merge(&imported_module)
+            .map_err(CompileError::Lower)?;
     }
 
     Ok(module)
@@ -505,7 +575,7 @@ fn compile_raw_with_imports_internal(
     source: &str,
     resolve_import: &dyn Fn(&str) -> Option<String>,
     compilation_stack: &mut HashSet<String>,
-    _current_module: Option<&str>,
+    current_module: Option<&str>,
 ) -> Result<LirModule, CompileError> {
     if source.trim().is_empty() {
         return Ok(LirModule::new("sha256:empty".to_string()));
@@ -708,11 +778,16 @@ fn compile_raw_with_imports_internal(
     }
 
     // 7. Lower to LIR
-    let mut module = lower_safe(&program, &symbols, source)?;
+    let mut module = lower_safe(&program, &symbols, source, None)?;
+    if let Some(path) = current_module {
+        tag_cell_module(&mut module, path);
+    }
 
     // 8. Merge imported modules
     for imported_module in imported_modules {
-        module.merge(&imported_module);
+        module
+            .merge(&imported_module)
+            .map_err(CompileError::Lower)?;
     }
 
     Ok(module)
@@ -770,7 +845,7 @@ pub fn compile_raw_with_options(
     }
 
     // 7. Lower to LIR
-    let module = lower_safe(&program, &symbols, source)?;
+    let module = lower_with_options(&program, &symbols, source, options)?;
 
     Ok(module)
 }
@@ -853,7 +928,7 @@ pub fn compile_with_options(
     }
 
     // 10. Lower to LIR
-    let module = lower_safe(&program, &symbols, source)?;
+    let module = lower_with_options(&program, &symbols, source, options)?;
 
     Ok(module)
 }
@@ -53,6 +53,7 @@ fn body_with_let_call(callee: &str) -> Vec<Stmt> {
     vec![Stmt::Let(LetStmt {
         name: "tmp".to_string(),
         mutable: false,
+        is_var: false,
         pattern: None,
         ty: None,
         value: Expr::Call(
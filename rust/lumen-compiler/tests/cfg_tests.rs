@@ -0,0 +1,69 @@
+//! `cfg::Cfg` splits a lowered cell into basic blocks, and `module_to_dot`
+//! writes them as the Graphviz graph `lumen build --emit-cfg` prints.
+
+use lumen_compiler::compile_raw;
+use lumen_compiler::compiler::cfg::{module_to_dot, Cfg};
+use lumen_compiler::compiler::lir::LirModule;
+
+/// A `while` loop around an `if`.
+const COUNT_ODDS: &str = r#"
+cell count_odds(n: Int) -> Int
+  var i = 0
+  var odds = 0
+  while i < n
+    if i % 2 == 1
+      odds = odds + 1
+    end
+    i = i + 1
+  end
+  return odds
+end
+"#;
+
+fn count_odds() -> (LirModule, usize) {
+    let module = compile_raw(COUNT_ODDS).expect("source should compile");
+    let index = module
+        .cells
+        .iter()
+        .position(|c| c.name == "count_odds")
+        .expect("count_odds should be lowered");
+    (module, index)
+}
+
+#[test]
+fn loop_with_a_conditional_has_the_expected_blocks() {
+    let (module, index) = count_odds();
+    let cfg = Cfg::build(&module.cells[index].instructions);
+    // entry, loop test, loop exit jump, if test, if skip jump, then
+    // branch, loop tail, return
+    assert_eq!(cfg.blocks.len(), 8, "{:#?}", cfg);
+    // loop test -> exit jump or if test, if test -> skip jump or then
+    // branch, and one edge out of each other block but the return
+    assert_eq!(cfg.edge_count(), 9, "{:#?}", cfg);
+
+    // The loop tail jumps back to the loop test
+    let tail = &cfg.blocks[6];
+    assert_eq!(tail.successors.len(), 1);
+    assert_eq!(tail.successors[0].to, 1);
+    assert!(cfg.blocks[7].successors.is_empty());
+}
+
+#[test]
+fn dot_output_has_a_node_per_block_and_a_line_per_edge() {
+    let (module, index) = count_odds();
+    let dot = module_to_dot(&module);
+    assert!(dot.starts_with("digraph cfg {\n"), "{}", dot);
+    assert!(dot.ends_with("}\n"), "{}", dot);
+    assert!(dot.contains("label=\"count_odds\";"), "{}", dot);
+
+    let node = format!("    c{}_b", index);
+    let (nodes, edges): (Vec<&str>, Vec<&str>) = dot
+        .lines()
+        .filter(|l| l.starts_with(&node))
+        .partition(|l| l.contains(" [label="));
+    assert_eq!(nodes.len(), 8, "{}", dot);
+    assert_eq!(edges.len(), 9, "{}", dot);
+    // The loop tail jumps back to the loop test
+    let back_edge = format!("{}6 -> c{}_b1;", node, index);
+    assert!(edges.contains(&back_edge.as_str()), "{}", dot);
+}
@@ -350,11 +350,13 @@ fn fuzz_verification_no_panic() {
                     default_value: None,
                     constraint: Some(final_constraint),
                     span,
+                    attributes: vec![],
                 }],
                 is_pub: false,
                 span,
                 doc: None,
                 deprecated: None,
+                packed: false,
             })],
             span,
         };
@@ -595,11 +597,16 @@ fn fuzz_cell_contracts_no_panic() {
             is_pub: false,
             is_async: false,
             is_extern: false,
+            is_const: false,
             must_use: false,
             where_clauses: vec![where_clause],
             span,
             doc: None,
             deprecated: None,
+            receiver: None,
+            specializations: vec![],
+            tailcall: false,
+            memoize: None,
         };
 
         let caller = CellDef {
@@ -619,11 +626,16 @@ fn fuzz_cell_contracts_no_panic() {
             is_pub: false,
             is_async: false,
             is_extern: false,
+            is_const: false,
             must_use: false,
             where_clauses: vec![],
             span,
             doc: None,
             deprecated: None,
+            receiver: None,
+            specializations: vec![],
+            tailcall: false,
+            memoize: None,
         };
 
         let program = Program {
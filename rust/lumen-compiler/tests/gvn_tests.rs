@@ -0,0 +1,123 @@
+//! Global value numbering (`compiler::gvn`) computes a repeated pure
+//! expression once, but keeps reads that a store or an operator overload
+//! could change.
+
+use lumen_compiler::compile;
+use lumen_compiler::compiler::lir::{LirModule, OpCode};
+use lumen_vm::values::Value;
+use lumen_vm::vm::VM;
+
+fn compile_md(source: &str) -> LirModule {
+    let md = format!("# gvn-test\n\n```lumen\n{}\n```\n", source.trim());
+    compile(&md).expect("source should compile")
+}
+
+/// How many times `op` appears in cell `name`.
+fn count(module: &LirModule, name: &str, op: OpCode) -> usize {
+    module
+        .cells
+        .iter()
+        .find(|c| c.name == name)
+        .unwrap_or_else(|| panic!("{} should be lowered", name))
+        .instructions
+        .iter()
+        .filter(|i| i.op == op)
+        .count()
+}
+
+fn run_main(module: LirModule) -> Value {
+    let mut vm = VM::new();
+    vm.load(module);
+    vm.execute("main", vec![]).expect("main should execute")
+}
+
+#[test]
+fn repeated_pure_subexpression_is_computed_once() {
+    let module = compile_md(
+        r#"
+cell energy(dx: Float, dy: Float) -> Float
+  let first = dx * dx + dy
+  let second = dx * dx - dy
+  return first * second
+end
+
+cell main() -> Float
+  return energy(3.0, 1.0)
+end
+"#,
+    );
+    // `dx * dx` once, and the product of the two halves
+    assert_eq!(count(&module, "energy", OpCode::Mul), 2);
+    assert_eq!(run_main(module), Value::Float(80.0));
+}
+
+#[test]
+fn read_after_a_store_is_not_reused() {
+    let module = compile_md(
+        r#"
+cell bump(xs: list[Int], i: Int) -> Int
+  var ys = xs
+  let before = ys[i]
+  ys[i] = before + 1
+  return before + ys[i]
+end
+
+cell main() -> Int
+  return bump([1, 2, 3], 1)
+end
+"#,
+    );
+    assert_eq!(count(&module, "bump", OpCode::GetIndex), 2);
+    assert_eq!(run_main(module), Value::Int(5));
+}
+
+#[test]
+fn overloaded_operator_is_not_numbered() {
+    let module = compile_md(
+        r#"
+record Vec2
+  x: Int
+  y: Int
+end
+
+impl Add for Vec2
+  cell add(self: Self, other: Self) -> Self
+    return Vec2(x: self.x + other.x, y: self.y + other.y)
+  end
+end
+
+cell twice(a: Vec2, b: Vec2) -> Int
+  let first = a + b
+  let second = a + b
+  return first.x + second.y
+end
+
+cell main() -> Int
+  return twice(Vec2(x: 1, y: 2), Vec2(x: 10, y: 20))
+end
+"#,
+    );
+    // Both `a + b` and the final Int sum
+    assert_eq!(count(&module, "twice", OpCode::Add), 3);
+    assert_eq!(run_main(module), Value::Int(33));
+}
+
+#[test]
+fn value_passed_to_append_is_recomputed_afterwards() {
+    // `append` takes its arguments out of their registers, so the product
+    // it was handed is gone by the time it is needed again
+    let module = compile_md(
+        r#"
+cell collect(a: Int, b: Int) -> Int
+  var xs = []
+  xs = append(xs, a * b)
+  return a * b + length(xs)
+end
+
+cell main() -> Int
+  return collect(3, 4)
+end
+"#,
+    );
+    assert_eq!(run_main(module), Value::Int(13));
+}
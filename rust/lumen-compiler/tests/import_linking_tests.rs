@@ -0,0 +1,130 @@
+//! Cells merged from an imported module keep pointing at the right strings
+//! and closures once they sit in the importing module's tables.
+
+use lumen_compiler::compile_with_imports;
+use lumen_compiler::compiler::lir::LirModule;
+use lumen_vm::values::Value;
+use lumen_vm::vm::VM;
+
+fn link(main_source: &str, lib_source: &str) -> LirModule {
+    compile_with_imports(main_source, &|module| {
+        if module == "lib" {
+            Some(lib_source.to_string())
+        } else {
+            None
+        }
+    })
+    .expect("sources should compile and link")
+}
+
+fn run_main(module: LirModule) -> Value {
+    let mut vm = VM::new();
+    vm.load(module);
+    vm.execute("main", vec![]).expect("main should execute")
+}
+
+#[test]
+fn imported_cells_read_fields_through_the_merged_string_table() {
+    let lib_source = r#"
+```lumen
+record Point
+  x: Int
+  y: Int
+end
+
+cell make(x: Int, y: Int) -> Point
+  return Point(x: x, y: y)
+end
+
+cell shifted(p: Point) -> Point
+  var q = p
+  q.y = q.y + 100
+  return q
+end
+
+cell norm(p: Point) -> Int
+  return p.x * p.x + p.y * p.y
+end
+```
+"#;
+    // `main` interns its own strings first, so the library's field names
+    // land at different string-table indices after the merge
+    let main_source = r#"
+```lumen
+import lib: Point, make, shifted, norm
+
+record Label
+  text: String
+  y: Int
+end
+
+cell main() -> Int
+  let label = Label(text: "origin", y: 1)
+  let p = shifted(make(3, 4 - 100))
+  return norm(p) + label.y
+end
+```
+"#;
+    assert_eq!(run_main(link(main_source, lib_source)), Value::Int(26));
+}
+
+#[test]
+fn imported_lambda_with_a_colliding_name_keeps_its_own_body() {
+    let lib_source = r#"
+```lumen
+cell adder(n: Int) -> fn(Int) -> Int
+  return fn(x: Int) => x + n
+end
+```
+"#;
+    let main_source = r#"
+```lumen
+import lib: adder
+
+cell main() -> Int
+  let triple = fn(x: Int) => x * 3
+  let add_ten = adder(10)
+  return triple(add_ten(1))
+end
+```
+"#;
+    let module = link(main_source, lib_source);
+    let lambdas = module
+        .cells
+        .iter()
+        .filter(|c| c.name.starts_with("<lambda/"))
+        .count();
+    assert_eq!(lambdas, 2, "both lambdas should survive the merge");
+    assert_eq!(run_main(module), Value::Int(33));
+}
+
+#[test]
+fn merge_fails_when_a_field_operand_no_longer_fits_in_a_byte() {
+    let lib = lumen_compiler::compile(
+        r#"
+```lumen
+record Point
+  x: Int
+  y: Int
+end
+
+cell bump(p: Point) -> Point
+  var q = p
+  q.y = q.y + 1
+  return q
+end
+```
+"#,
+    )
+    .expect("library should compile");
+    let mut module = LirModule::new("main".to_string());
+    module.strings = (0..300).map(|i| format!("s{}", i)).collect();
+
+    let err = module
+        .merge(&lib)
+        .expect_err("field name index past 255 should not be truncated");
+    assert!(err.contains("bump"), "error should name the cell: {}", err);
+    // A failed merge leaves the module as it was
+    assert!(module.cells.is_empty());
+    assert_eq!(module.strings.len(), 300);
+}
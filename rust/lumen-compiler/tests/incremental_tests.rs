@@ -0,0 +1,109 @@
+//! Incremental compilation: a rebuild lowers only the cells an edit reaches
+//! and takes the rest from the cell store.
+
+use lumen_compiler::compiler::incremental::MemoryCellStore;
+use lumen_compiler::{compile, compile_with_options, CompileOptions};
+use std::sync::{Arc, Mutex};
+
+fn md(source: &str) -> String {
+    format!("# incremental-test\n\n```lumen\n{}\n```\n", source.trim())
+}
+
+/// Compile `source` against `store`, returning the names of the cells that
+/// were lowered and the names of the cells taken from the store.
+fn build(store: &Arc<Mutex<MemoryCellStore>>, source: &str) -> (Vec<String>, Vec<String>) {
+    let options = CompileOptions {
+        cell_store: Some(store.clone()),
+        ..Default::default()
+    };
+    let module = compile_with_options(&md(source), &options).expect("source should compile");
+    let fresh = compile(&md(source)).expect("source should compile");
+    assert_eq!(
+        serde_json::to_string(&module).unwrap(),
+        serde_json::to_string(&fresh).unwrap(),
+        "a build from the store should match a clean build"
+    );
+
+    let mut store = store.lock().unwrap();
+    let mut compiled = store.compiled.clone();
+    let mut reused = store.reused.clone();
+    compiled.sort();
+    reused.sort();
+    store.clear_log();
+    (compiled, reused)
+}
+
+const PROGRAM: &str = r#"
+cell area(w: Int, h: Int) -> Int
+  return w * h
+end
+
+cell describe(n: Int) -> String
+  return "n = " + to_string(n)
+end
+
+cell main() -> String
+  return describe(area(3, 4))
+end
+"#;
+
+#[test]
+fn editing_a_body_recompiles_only_that_cell() {
+    let store = Arc::new(Mutex::new(MemoryCellStore::new()));
+    let (compiled, reused) = build(&store, PROGRAM);
+    assert_eq!(compiled, vec!["area", "describe", "main"]);
+    assert!(reused.is_empty());
+
+    let (compiled, reused) = build(&store, PROGRAM);
+    assert!(compiled.is_empty());
+    assert_eq!(reused, vec!["area", "describe", "main"]);
+
+    let edited = PROGRAM.replace("return w * h", "return w * h + 0");
+    let (compiled, reused) = build(&store, &edited);
+    assert_eq!(compiled, vec!["area"]);
+    assert_eq!(reused, vec!["describe", "main"]);
+}
+
+#[test]
+fn editing_a_signature_recompiles_every_cell() {
+    let store = Arc::new(Mutex::new(MemoryCellStore::new()));
+    build(&store, PROGRAM);
+
+    let edited = PROGRAM
+        .replace(
+            "cell area(w: Int, h: Int) -> Int",
+            "cell area(w: Int, h: Int, d: Int) -> Int",
+        )
+        .replace("return w * h", "return w * h * d")
+        .replace("area(3, 4)", "area(3, 4, 1)");
+    let (compiled, reused) = build(&store, &edited);
+    assert_eq!(compiled, vec!["area", "describe", "main"]);
+    assert!(reused.is_empty());
+}
+
+#[test]
+fn editing_a_const_cell_recompiles_the_cells_it_was_folded_into() {
+    let program = r#"
+const cell scale(x: Int) -> Int
+  return x * 10
+end
+
+const FACTOR = scale(4)
+
+cell uses() -> Int
+  return FACTOR + 1
+end
+
+cell unrelated() -> Int
+  return 7
+end
+"#;
+    let store = Arc::new(Mutex::new(MemoryCellStore::new()));
+    build(&store, program);
+
+    // `FACTOR` is folded into `uses`, so a new value for it reaches `uses`
+    let edited = program.replace("return x * 10", "return x * 20");
+    let (compiled, reused) = build(&store, &edited);
+    assert_eq!(compiled, vec!["scale", "uses"]);
+    assert_eq!(reused, vec!["unrelated"]);
+}
@@ -0,0 +1,106 @@
+//! `CompileOptions::opt_report` notes, for each cell, the optimizations
+//! that fired and the ones that were blocked, with the reason.
+
+use lumen_compiler::compiler::opt_report::{OptNote, OptReport, Optimization};
+use lumen_compiler::{compile_raw_with_options, CompileOptions};
+use std::sync::{Arc, Mutex};
+
+fn report(source: &str) -> OptReport {
+    let report = Arc::new(Mutex::new(OptReport::default()));
+    let options = CompileOptions {
+        opt_report: Some(Arc::clone(&report)),
+        ..Default::default()
+    };
+    compile_raw_with_options(source, &options).expect("source should compile");
+    let report = report.lock().unwrap().clone();
+    report
+}
+
+/// The tail call notes for `cell`.
+fn tail_calls<'r>(report: &'r OptReport, cell: &str) -> Vec<&'r OptNote> {
+    report
+        .cell(cell)
+        .unwrap_or_else(|| panic!("no report for {}:\n{}", cell, report))
+        .notes
+        .iter()
+        .filter(|n| n.optimization == Optimization::TailCall)
+        .collect()
+}
+
+#[test]
+fn opt_report_explains_why_a_recursive_loop_keeps_its_frames() {
+    let report = report(
+        r#"
+cell countdown(n: Int) -> Int
+  if n == 0
+    return 0
+  end
+  return countdown(n - 1)
+end
+
+cell countdown_logged(n: Int) -> Int
+  defer
+    print("step")
+  end
+  if n == 0
+    return 0
+  end
+  return countdown_logged(n - 1)
+end
+
+cell countdown_implicit(n: Int) -> Int
+  if n == 0
+    return 0
+  end
+  countdown_implicit(n - 1)
+end
+"#,
+    );
+
+    let fired = tail_calls(&report, "countdown");
+    assert_eq!(fired.len(), 1, "{}", report);
+    assert!(!fired[0].blocked);
+    assert_eq!(fired[0].line, Some(6));
+
+    let deferred = tail_calls(&report, "countdown_logged");
+    assert_eq!(deferred.len(), 1, "{}", report);
+    assert!(deferred[0].blocked);
+    assert_eq!(deferred[0].line, Some(16));
+    assert_eq!(
+        deferred[0].detail,
+        "a defer block runs after the call returns"
+    );
+
+    let implicit = tail_calls(&report, "countdown_implicit");
+    assert_eq!(implicit.len(), 1, "{}", report);
+    assert!(implicit[0].blocked);
+    assert!(implicit[0].detail.contains("implicit return"), "{}", report);
+
+    let text = report.to_string();
+    assert!(
+        text.contains("tail call blocked at line 16: a defer block runs after the call returns"),
+        "{}",
+        text
+    );
+}
+
+#[test]
+fn every_lowered_cell_is_in_the_report() {
+    let report = report(
+        r#"
+cell double(x: Int) -> Int
+  return x * 2
+end
+
+cell main() -> Int
+  return double(21)
+end
+"#,
+    );
+    let cells: Vec<&str> = report.cells.iter().map(|c| c.cell.as_str()).collect();
+    assert_eq!(cells, ["double", "main"]);
+    assert!(report.cell("double").unwrap().notes.is_empty());
+    assert!(report
+        .to_string()
+        .contains("cell double\n  no optimizations apply\n"));
+}
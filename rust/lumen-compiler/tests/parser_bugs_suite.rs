@@ -34,7 +34,7 @@ fn compile_to_lir(src: &str) -> lumen_compiler::compiler::lir::LirModule {
 
 #[test]
 fn for_filter_parsed_into_ast() {
-    let src = "cell main() -> Int\n  let total = 0\n  for x in [1, 2, 3, 4, 5] if x > 2\n    total += x\n  end\n  return total\nend";
+    let src = "cell main() -> Int\n  var total = 0\n  for x in [1, 2, 3, 4, 5] if x > 2\n    total += x\n  end\n  return total\nend";
     let program = parse(src);
     let cell = match &program.items[0] {
         lumen_compiler::compiler::ast::Item::Cell(c) => c,
@@ -70,7 +70,7 @@ fn for_filter_parsed_into_ast() {
 
 #[test]
 fn for_filter_compiles_to_lir() {
-    let src = "cell main() -> Int\n  let total = 0\n  for x in [1, 2, 3, 4, 5] if x > 2\n    total += x\n  end\n  return total\nend";
+    let src = "cell main() -> Int\n  var total = 0\n  for x in [1, 2, 3, 4, 5] if x > 2\n    total += x\n  end\n  return total\nend";
     let module = compile_to_lir(src);
     let ops: Vec<_> = module.cells[0].instructions.iter().map(|i| i.op).collect();
 
@@ -99,7 +99,7 @@ fn for_filter_compiles_to_lir() {
 
 #[test]
 fn for_without_filter_still_works() {
-    let src = "cell main() -> Int\n  let total = 0\n  for x in [1, 2, 3]\n    total += x\n  end\n  return total\nend";
+    let src = "cell main() -> Int\n  var total = 0\n  for x in [1, 2, 3]\n    total += x\n  end\n  return total\nend";
     let program = parse(src);
     let cell = match &program.items[0] {
         lumen_compiler::compiler::ast::Item::Cell(c) => c,
@@ -122,7 +122,7 @@ fn for_without_filter_still_works() {
 
 #[test]
 fn for_filter_with_pattern_destructure() {
-    let src = "cell main() -> Int\n  let total = 0\n  let items = [(1, 2), (3, 4), (5, 6)]\n  for (a, b) in items if a > 2\n    total += b\n  end\n  return total\nend";
+    let src = "cell main() -> Int\n  var total = 0\n  let items = [(1, 2), (3, 4), (5, 6)]\n  for (a, b) in items if a > 2\n    total += b\n  end\n  return total\nend";
     let program = parse(src);
     let cell = match &program.items[0] {
         lumen_compiler::compiler::ast::Item::Cell(c) => c,
@@ -234,7 +234,7 @@ fn unlabeled_break_has_none_label() {
 
 #[test]
 fn unlabeled_continue_has_none_label() {
-    let src = "cell main() -> Int\n  let i = 0\n  loop\n    i += 1\n    if i > 5\n      break\n    end\n    continue\n  end\n  return i\nend";
+    let src = "cell main() -> Int\n  var i = 0\n  loop\n    i += 1\n    if i > 5\n      break\n    end\n    continue\n  end\n  return i\nend";
     let program = parse(src);
     let cell = match &program.items[0] {
         lumen_compiler::compiler::ast::Item::Cell(c) => c,
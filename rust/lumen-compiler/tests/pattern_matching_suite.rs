@@ -63,3 +63,67 @@ end
         panic!("deeply_nested_destructure failed:\n{}", err);
     }
 }
+
+#[test]
+fn switch_sparse_int_cases_use_compare_chain() {
+    use lumen_compiler::compiler::lir::OpCode;
+
+    let source = r#"
+cell port_name(port: Int) -> String
+  switch port
+    case 22 -> return "ssh"
+    case 80 -> return "http"
+    case 443 -> return "https"
+    case 8080 -> return "alt"
+  end
+  return "other"
+end
+"#;
+    let md = markdown_from_code(source);
+    let module = compile(&md).unwrap_or_else(|err| panic!("sparse switch failed:\n{}", err));
+    assert!(
+        !module.cells[0]
+            .instructions
+            .iter()
+            .any(|i| i.op == OpCode::JmpTable),
+        "sparse cases should not build a jump table"
+    );
+}
+
+#[test]
+fn switch_case_type_must_match_subject() {
+    let source = r#"
+cell main(code: Int) -> String
+  switch code
+    case 1 -> return "one"
+    case "two" -> return "two"
+  end
+  return "other"
+end
+"#;
+    let md = markdown_from_code(source);
+    assert!(
+        compile(&md).is_err(),
+        "a String case on an Int switch should be a type error"
+    );
+}
+
+#[test]
+fn switch_case_values_must_be_literals() {
+    let source = r#"
+cell main(code: Int, limit: Int) -> String
+  switch code
+    case limit -> return "limit"
+    default -> return "other"
+  end
+end
+"#;
+    let md = markdown_from_code(source);
+    let err = compile(&md).expect_err("a variable case value should be rejected");
+    assert!(
+        err.to_string()
+            .contains("case values must be integer or string literals"),
+        "unexpected error: {}",
+        err
+    );
+}
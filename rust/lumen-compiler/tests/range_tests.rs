@@ -40,7 +40,7 @@ fn range_exclusive_basic() {
         "range_exclusive_basic",
         r#"
 cell main() -> Int
-  let sum = 0
+  var sum = 0
   for i in 0..5
     sum = sum + i
   end
@@ -87,7 +87,7 @@ fn range_inclusive_basic() {
         "range_inclusive_basic",
         r#"
 cell main() -> Int
-  let sum = 0
+  var sum = 0
   for i in 0..=5
     sum = sum + i
   end
@@ -133,7 +133,7 @@ fn range_for_loop_exclusive() {
         "range_for_exclusive",
         r#"
 cell main() -> Int
-  let total = 0
+  var total = 0
   for x in 1..10
     total = total + x
   end
@@ -149,7 +149,7 @@ fn range_for_loop_inclusive() {
         "range_for_inclusive",
         r#"
 cell main() -> Int
-  let total = 0
+  var total = 0
   for x in 1..=10
     total = total + x
   end
@@ -165,7 +165,7 @@ fn range_for_loop_nested() {
         "range_for_nested",
         r#"
 cell main() -> Int
-  let count = 0
+  var count = 0
   for i in 0..3
     for j in 0..3
       count = count + 1
@@ -188,7 +188,7 @@ fn range_with_variable_start() {
         r#"
 cell main() -> Int
   let start = 3
-  let sum = 0
+  var sum = 0
   for i in start..10
     sum = sum + i
   end
@@ -205,7 +205,7 @@ fn range_with_variable_end() {
         r#"
 cell main() -> Int
   let limit = 10
-  let sum = 0
+  var sum = 0
   for i in 0..limit
     sum = sum + i
   end
@@ -223,7 +223,7 @@ fn range_with_variable_both() {
 cell main() -> Int
   let lo = 2
   let hi = 8
-  let sum = 0
+  var sum = 0
   for i in lo..hi
     sum = sum + i
   end
@@ -241,7 +241,7 @@ fn range_inclusive_with_variables() {
 cell main() -> Int
   let lo = 1
   let hi = 5
-  let sum = 0
+  var sum = 0
   for i in lo..=hi
     sum = sum + i
   end
@@ -261,7 +261,7 @@ fn range_expression_bounds() {
         "range_expr_bounds",
         r#"
 cell main() -> Int
-  let sum = 0
+  var sum = 0
   for i in (1 + 1)..(3 + 2)
     sum = sum + i
   end
@@ -282,7 +282,7 @@ fn range_type_is_list_int() {
         "range_type_inference",
         r#"
 cell sum_list(items: list[Int]) -> Int
-  let total = 0
+  var total = 0
   for x in items
     total = total + x
   end
@@ -399,6 +399,68 @@ end
     );
 }
 
+#[test]
+fn range_for_loop_is_counted_without_allocation() {
+    use lumen_compiler::compiler::lir::{IntrinsicId, OpCode};
+
+    let module = assert_compiles_to_lir(
+        "range_for_counted",
+        r#"
+cell main(limit: Int) -> Int
+  let mut total = 0
+  for i in 2..=limit step 2
+    total += i
+  end
+  return total
+end
+"#,
+    );
+
+    let intrinsics: Vec<u8> = module.cells[0]
+        .instructions
+        .iter()
+        .filter(|i| i.op == OpCode::Intrinsic)
+        .map(|i| i.b)
+        .collect();
+    assert!(
+        intrinsics.contains(&(IntrinsicId::RangeCount as u8)),
+        "range loop should count its iterations up front"
+    );
+    assert!(
+        !intrinsics.contains(&(IntrinsicId::Range as u8))
+            && !intrinsics.contains(&(IntrinsicId::RangeStep as u8)),
+        "range loop should not materialize a list, got intrinsics {:?}",
+        intrinsics
+    );
+}
+
+#[test]
+fn range_step_parses_into_range_expr() {
+    use lumen_compiler::compiler::ast::{Expr, Item, Stmt};
+    use lumen_compiler::compiler::lexer::Lexer;
+    use lumen_compiler::compiler::parser::Parser;
+
+    let src = "cell main() -> list[Int]\n  let xs = 10..0 step -2\n  return xs\nend";
+    let mut lexer = Lexer::new(src, 1, 0);
+    let tokens = lexer.tokenize().expect("lex should succeed");
+    let mut parser = Parser::new(tokens);
+    let program = parser.parse_program(vec![]).expect("parse should succeed");
+    let Item::Cell(cell) = &program.items[0] else {
+        panic!("expected cell");
+    };
+    let Stmt::Let(ls) = &cell.body[0] else {
+        panic!("expected let");
+    };
+    match &ls.value {
+        Expr::RangeExpr {
+            inclusive: false,
+            step: Some(_),
+            ..
+        } => {}
+        other => panic!("expected stepped RangeExpr, got {:?}", other),
+    }
+}
+
 // ============================================================================
 // Range with zero-length range
 // ============================================================================
@@ -409,7 +471,7 @@ fn range_zero_length() {
         "range_zero_len",
         r#"
 cell main() -> Int
-  let sum = 0
+  var sum = 0
   for i in 5..5
     sum = sum + i
   end
@@ -442,7 +504,7 @@ fn range_as_function_argument() {
         "range_func_arg",
         r#"
 cell sum_all(items: list[Int]) -> Int
-  let total = 0
+  var total = 0
   for x in items
     total = total + x
   end
@@ -466,7 +528,7 @@ fn range_negative_start() {
         "range_neg_start",
         r#"
 cell main() -> Int
-  let sum = 0
+  var sum = 0
   for i in 0..5
     sum = sum + i
   end
@@ -482,7 +544,7 @@ fn range_with_negative_numbers() {
         "range_neg_nums",
         r#"
 cell main() -> Int
-  let sum = 0
+  var sum = 0
   let start = 0 - 3
   for i in start..3
     sum = sum + i
@@ -500,7 +562,7 @@ fn range_inverted() {
         "range_inverted",
         r#"
 cell main() -> Int
-  let sum = 0
+  var sum = 0
   for i in 10..5
     sum = sum + i
   end
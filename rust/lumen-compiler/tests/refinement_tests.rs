@@ -60,11 +60,16 @@ fn make_cell(name: &str, params: Vec<Param>, where_clauses: Vec<Expr>, body: Vec
         is_pub: false,
         is_async: false,
         is_extern: false,
+        is_const: false,
         must_use: false,
         where_clauses,
         span: span(),
         doc: None,
         deprecated: None,
+        receiver: None,
+        specializations: vec![],
+        tailcall: false,
+        memoize: None,
     }
 }
 
@@ -388,11 +393,13 @@ fn record_field_constraint_always_false_is_violated() {
                 default_value: None,
                 constraint: Some(Expr::BoolLit(false, span())),
                 span: span(),
+                attributes: vec![],
             }],
             is_pub: false,
             span: span(),
             doc: None,
             deprecated: None,
+            packed: false,
         })],
         span: span(),
     };
@@ -420,11 +427,13 @@ fn record_field_constraint_always_true_is_verified() {
                 default_value: None,
                 constraint: Some(Expr::BoolLit(true, span())),
                 span: span(),
+                attributes: vec![],
             }],
             is_pub: false,
             span: span(),
             doc: None,
             deprecated: None,
+            packed: false,
         })],
         span: span(),
     };
@@ -453,11 +462,13 @@ fn record_field_constraint_comparison_is_not_tautology() {
                 default_value: None,
                 constraint: Some(binop(ident("value"), BinOp::Gt, int_lit(0))),
                 span: span(),
+                attributes: vec![],
             }],
             is_pub: false,
             span: span(),
             doc: None,
             deprecated: None,
+            packed: false,
         })],
         span: span(),
     };
@@ -1002,11 +1013,13 @@ fn mixed_record_and_cell_constraints_collected() {
                     default_value: None,
                     constraint: Some(binop(ident("v"), BinOp::Gt, int_lit(0))),
                     span: span(),
+                    attributes: vec![],
                 }],
                 is_pub: false,
                 span: span(),
                 doc: None,
                 deprecated: None,
+                packed: false,
             }),
             Item::Cell(make_cell(
                 "safe_div",
@@ -147,6 +147,65 @@ end
     );
 }
 
+#[test]
+fn typecheck_record_spread_copies_remaining_fields() {
+    assert_compiles(
+        r#"
+record Point
+  x: Int
+  y: Int
+end
+
+cell main() -> Int
+  let a = Point(x: 1, y: 2)
+  let b = Point(..a, y: 5)
+  return b.x + b.y
+end
+"#,
+    );
+}
+
+#[test]
+fn typecheck_record_spread_source_must_be_same_record() {
+    assert_type_error(
+        r#"
+record Point
+  x: Int
+  y: Int
+end
+
+record Size
+  x: Int
+  y: Int
+end
+
+cell main() -> Point
+  let s = Size(x: 1, y: 2)
+  return Point(..s, y: 0)
+end
+"#,
+        "spread source has type Size, expected Point",
+    );
+}
+
+#[test]
+fn typecheck_record_spread_allows_one_source() {
+    assert_type_error(
+        r#"
+record Point
+  x: Int
+  y: Int
+end
+
+cell main() -> Point
+  let a = Point(x: 1, y: 2)
+  return Point(..a, ..a)
+end
+"#,
+        "only one spread source is allowed",
+    );
+}
+
 // ═══════════════════════════════════════════════════════════════════
 // Enum variant payload types
 // ═══════════════════════════════════════════════════════════════════
@@ -325,6 +384,29 @@ end
     );
 }
 
+#[test]
+fn typecheck_list_repeat_infers_element_type() {
+    assert_compiles(
+        r#"
+cell main(n: Int) -> list[Bool]
+  return [false; n * 2]
+end
+"#,
+    );
+}
+
+#[test]
+fn typecheck_list_repeat_count_must_be_int() {
+    assert_type_error(
+        r#"
+cell main() -> list[Int]
+  return [0; "three"]
+end
+"#,
+        "mismatch",
+    );
+}
+
 // NOTE: This test is commented out because the typechecker doesn't yet validate
 // heterogeneous list literal element types.
 // #[test]
@@ -716,6 +798,675 @@ end
     );
 }
 
+// ─── Trait objects and bounds ───
+
+const SHAPE_TRAIT: &str = r#"
+trait Shape
+  cell area(self: Self) -> Int
+end
+
+record Square
+  side: Int
+end
+
+record Circle
+  r: Int
+end
+
+impl Shape for Square
+  cell area(self: Self) -> Int
+    return self.side * self.side
+  end
+end
+"#;
+
+#[test]
+fn typecheck_trait_object_accepts_implementing_types() {
+    assert_compiles(&format!(
+        "{}\n{}",
+        SHAPE_TRAIT,
+        r#"
+cell total(shapes: list[Shape]) -> Int
+  let mut sum = 0
+  for s in shapes
+    sum += s.area()
+  end
+  return sum
+end
+
+cell main() -> Int
+  return total([Square(side: 2)])
+end
+"#
+    ));
+}
+
+#[test]
+fn typecheck_trait_object_rejects_type_without_impl() {
+    assert_type_error(
+        &format!(
+            "{}\n{}",
+            SHAPE_TRAIT,
+            r#"
+cell area_of(s: Shape) -> Int
+  return s.area()
+end
+
+cell main() -> Int
+  return area_of(Circle(r: 1))
+end
+"#
+        ),
+        "traitnotimplemented { ty: \"circle\", trait_name: \"shape\"",
+    );
+}
+
+#[test]
+fn typecheck_trait_bound_checked_at_call_site() {
+    assert_type_error(
+        &format!(
+            "{}\n{}",
+            SHAPE_TRAIT,
+            r#"
+cell area_of[T: Shape](s: T) -> Int
+  return s.area()
+end
+
+cell main() -> Int
+  return area_of(5)
+end
+"#
+        ),
+        "traitnotimplemented { ty: \"int\", trait_name: \"shape\"",
+    );
+}
+
+#[test]
+fn typecheck_trait_object_method_must_be_declared() {
+    assert_type_error(
+        &format!(
+            "{}\n{}",
+            SHAPE_TRAIT,
+            r#"
+cell perimeter_of(s: Shape) -> Int
+  return s.perimeter()
+end
+
+cell main() -> Int
+  return perimeter_of(Square(side: 1))
+end
+"#
+        ),
+        "unknownfield { field: \"perimeter\", ty: \"shape\"",
+    );
+}
+
+#[test]
+fn typecheck_specialize_accepts_implementing_type() {
+    assert_compiles(&format!(
+        "{}\n{}",
+        SHAPE_TRAIT,
+        r#"
+@specialize(Square)
+cell area_of[T: Shape](s: T) -> Int
+  return s.area()
+end
+
+cell main() -> Int
+  return area_of(Square(side: 2))
+end
+"#
+    ));
+}
+
+#[test]
+fn typecheck_specialize_checks_trait_bound() {
+    assert_type_error(
+        &format!(
+            "{}\n{}",
+            SHAPE_TRAIT,
+            r#"
+@specialize(Circle)
+cell area_of[T: Shape](s: T) -> Int
+  return s.area()
+end
+"#
+        ),
+        "traitnotimplemented { ty: \"circle\", trait_name: \"shape\"",
+    );
+}
+
+#[test]
+fn typecheck_specialize_needs_one_type_per_parameter() {
+    assert_type_error(
+        r#"
+@specialize(Int, String)
+cell first[T](xs: list[T]) -> T
+  return xs[0]
+end
+"#,
+        "argcount { expected: 1, actual: 2",
+    );
+}
+
+#[test]
+fn typecheck_specialize_unknown_type() {
+    assert_type_error(
+        r#"
+@specialize(Missing)
+cell first[T](xs: list[T]) -> T
+  return xs[0]
+end
+"#,
+        "undefinedtype { name: \"missing\"",
+    );
+}
+
+// ─── @tailcall ───
+
+#[test]
+fn typecheck_tailcall_accepts_call_in_tail_position() {
+    assert_compiles(
+        r#"
+@tailcall
+cell go(n: Int, acc: Int) -> Int
+  if n <= 1
+    return acc
+  end
+  return go(n - 1, acc * n)
+end
+
+cell main() -> Int
+  return go(10, 1)
+end
+"#,
+    );
+}
+
+#[test]
+fn typecheck_tailcall_rejects_call_whose_result_is_used() {
+    assert_type_error(
+        r#"
+@tailcall
+cell fact(n: Int) -> Int
+  if n <= 1
+    return 1
+  end
+  return n * fact(n - 1)
+end
+"#,
+        "nottailcall { name: \"fact\", reason: \"its result is used",
+    );
+}
+
+#[test]
+fn typecheck_tailcall_rejects_call_before_pending_defer() {
+    assert_type_error(
+        r#"
+@tailcall
+cell drain(n: Int) -> Int
+  defer
+    print("done")
+  end
+  if n == 0
+    return 0
+  end
+  return drain(n - 1)
+end
+"#,
+        "nottailcall { name: \"drain\", reason: \"a pending `defer`",
+    );
+}
+
+#[test]
+fn typecheck_tailcall_rejects_call_bound_to_a_local() {
+    assert_type_error(
+        r#"
+@tailcall
+cell count(n: Int) -> Int
+  if n == 0
+    return 0
+  end
+  let rest = count(n - 1)
+  return rest
+end
+"#,
+        "nottailcall { name: \"count\"",
+    );
+}
+
+// ─── List pattern lengths ───
+
+#[test]
+fn typecheck_list_pattern_head_and_tail_of_fixed_list() {
+    assert_compiles(
+        r#"
+cell main() -> Int
+  let p = [0, 4, 2]
+  match p
+    [a, b, c] -> return a + b + c
+    [0, rest..] -> return len(rest)
+    [first, ...more] -> return first
+    _ -> return 0
+  end
+end
+"#,
+    );
+}
+
+#[test]
+fn typecheck_list_pattern_rejects_wrong_length() {
+    assert_type_error(
+        r#"
+cell main() -> Int
+  let p = [0, 4, 2]
+  match p
+    [a, b] -> return a + b
+    _ -> return 0
+  end
+end
+"#,
+        "listpatternlength { needed: \"exactly 2\", len: 3",
+    );
+}
+
+#[test]
+fn typecheck_list_pattern_rejects_rest_longer_than_list() {
+    assert_type_error(
+        r#"
+cell main() -> Int
+  match [1, 2]
+    [a, b, c, rest..] -> return a
+    _ -> return 0
+  end
+end
+"#,
+        "listpatternlength { needed: \"at least 3\", len: 2",
+    );
+}
+
+#[test]
+fn typecheck_list_pattern_length_unknown_for_mutable_list() {
+    assert_compiles(
+        r#"
+cell main() -> Int
+  let mut p = [0, 4, 2]
+  p = append(p, 7)
+  match p
+    [a, b, c, d] -> return d
+    _ -> return 0
+  end
+end
+"#,
+    );
+}
+
+// ─── Operator overloading ───
+
+const VEC2_ADD: &str = r#"
+record Vec2
+  x: Int
+  y: Int
+end
+
+impl Add for Vec2
+  cell add(self: Self, other: Self) -> Self
+    return Vec2(x: self.x + other.x, y: self.y + other.y)
+  end
+end
+"#;
+
+#[test]
+fn typecheck_overloaded_operator_has_impl_return_type() {
+    assert_type_error(
+        &format!(
+            "{}\n{}",
+            VEC2_ADD,
+            r#"
+cell main() -> Int
+  let total: Int = Vec2(x: 1, y: 2) + Vec2(x: 3, y: 4)
+  return total
+end
+"#
+        ),
+        "mismatch { expected: \"int\", actual: \"vec2\"",
+    );
+}
+
+#[test]
+fn typecheck_unimplemented_operator_is_a_type_error() {
+    let source = format!(
+        "{}\n{}",
+        VEC2_ADD,
+        r#"
+cell main() -> Int
+  let v = Vec2(x: 1, y: 2) * Vec2(x: 3, y: 4)
+  return v.x
+end
+"#
+    );
+    assert_type_error(&source, "operatornotimplemented { op: \"*\", ty: \"vec2\"");
+    assert_type_error(&source, "add `impl mul for vec2`");
+}
+
+// ─── Compile-time constants ───
+
+#[test]
+fn typecheck_const_calling_impure_cell_is_rejected() {
+    assert_type_error(
+        r#"
+cell read_retries() -> Int
+  print("loading")
+  return 3
+end
+
+const RETRIES = read_retries()
+
+cell main() -> Int
+  return RETRIES
+end
+"#,
+        "calls 'read_retries', which is not a `const cell`",
+    );
+}
+
+#[test]
+fn typecheck_const_cell_rejects_io_and_mutable_state() {
+    assert_type_error(
+        r#"
+const cell noisy(x: Int) -> Int
+  print(x)
+  return x
+end
+"#,
+        "calls 'print', which is not pure",
+    );
+    assert_type_error(
+        r#"
+const cell counter(x: Int) -> Int
+  let mut n = x
+  n += 1
+  return n
+end
+"#,
+        "`let mut` declares mutable state",
+    );
+}
+
+// ─── Methods and receivers ───
+
+#[test]
+fn typecheck_method_call_checks_args_and_return() {
+    assert_type_error(
+        r#"
+record Body
+  x: Int
+end
+
+cell (b: Body) shifted(dx: Int) -> Int
+  return b.x + dx
+end
+
+cell main() -> Int
+  let b = Body(x: 1)
+  return b.shifted("far")
+end
+"#,
+        "mismatch",
+    );
+}
+
+#[test]
+fn typecheck_value_receiver_is_read_only() {
+    assert_type_error(
+        r#"
+record Body
+  x: Int
+end
+
+cell (b: Body) reset()
+  b.x = 0
+end
+
+cell main() -> Int
+  return 0
+end
+"#,
+        "immutableassign { name: \"b\"",
+    );
+}
+
+#[test]
+fn typecheck_mut_receiver_needs_a_variable() {
+    assert_type_error(
+        r#"
+record Body
+  x: Int
+end
+
+cell (mut b: Body) nudge()
+  b.x = b.x + 1
+end
+
+cell origin() -> Body
+  return Body(x: 0)
+end
+
+cell main() -> Int
+  origin().nudge()
+  return 0
+end
+"#,
+        "a `mut` receiver must be a variable",
+    );
+}
+
+#[test]
+fn typecheck_same_named_methods_may_differ_in_receiver_kind() {
+    assert_compiles(
+        r#"
+record Body
+  x: Int
+end
+
+record Probe
+  x: Int
+end
+
+cell (mut b: Body) step()
+  b.x = b.x + 1
+end
+
+cell (p: Probe) step() -> Int
+  return p.x
+end
+
+cell main() -> Int
+  return 0
+end
+"#,
+    );
+}
+
+// ═══════════════════════════════════════════════════════════════════
+// Conditional expressions
+// ═══════════════════════════════════════════════════════════════════
+
+#[test]
+fn typecheck_conditional_branches_must_agree() {
+    assert_type_error(
+        r#"
+cell label(flag: Bool) -> String
+  let x = if flag then 1 else "one"
+  return "{x}"
+end
+
+cell main() -> String
+  return label(true)
+end
+"#,
+        "mismatch",
+    );
+}
+
+#[test]
+fn typecheck_conditional_result_type_is_checked() {
+    assert_type_error(
+        r#"
+cell pick(flag: Bool) -> Int
+  let x: String = if flag then 1 else 2
+  return 0
+end
+
+cell main() -> Int
+  return pick(true)
+end
+"#,
+        "mismatch",
+    );
+}
+
+#[test]
+fn typecheck_conditional_widens_int_to_float_and_null_to_optional() {
+    assert_compiles(
+        r#"
+cell scale(flag: Bool) -> Float
+  let x: Float = if flag then 1 else 2.5
+  let y: Int? = if flag then 3 else null
+  return x
+end
+
+cell main() -> Float
+  return scale(true)
+end
+"#,
+    );
+}
+
+// ═══════════════════════════════════════════════════════════════════
+// Three-clause for
+// ═══════════════════════════════════════════════════════════════════
+
+#[test]
+fn typecheck_three_clause_for_variable_not_visible_after_loop() {
+    assert_type_error(
+        r#"
+cell main() -> Int
+  let mut total = 0
+  for let mut i = 0; i < 3; i++
+    total += i
+  end
+  return i
+end
+"#,
+        "undefinedvar { name: \"i\"",
+    );
+}
+
+#[test]
+fn typecheck_three_clause_for_condition_must_be_bool() {
+    assert_type_error(
+        r#"
+cell main() -> Int
+  let mut total = 0
+  for let mut i = 0; i + 3; i++
+    total += i
+  end
+  return total
+end
+"#,
+        "mismatch",
+    );
+}
+
+// ═══════════════════════════════════════════════════════════════════
+// Never and diverging expressions
+// ═══════════════════════════════════════════════════════════════════
+
+#[test]
+fn typecheck_panicking_match_arm_takes_other_arm_type() {
+    assert_compiles(
+        r#"
+cell unwrap(r: result[Int, String]) -> Int
+  let v: Int = match r
+    ok(n) -> n
+    err(e) -> panic(e)
+  end
+  return v
+end
+
+cell main() -> Int
+  return unwrap(ok(1))
+end
+"#,
+    );
+}
+
+#[test]
+fn typecheck_never_arm_does_not_hide_mismatch() {
+    assert_type_error(
+        r#"
+cell unwrap(r: result[Int, String]) -> String
+  let v: String = match r
+    ok(n) -> n
+    err(e) -> panic(e)
+  end
+  return v
+end
+
+cell main() -> String
+  return unwrap(ok(1))
+end
+"#,
+        "mismatch",
+    );
+}
+
+#[test]
+fn typecheck_never_return_type_fits_any_position() {
+    assert_compiles(
+        r#"
+cell fail(msg: String) -> Never
+  panic(msg)
+end
+
+cell pick(r: result[Int, String]) -> Int
+  let v: Int = match r
+    ok(n) -> n
+    err(e) -> fail(e)
+  end
+  return v
+end
+
+cell main() -> Int
+  return pick(err("nope"))
+end
+"#,
+    );
+}
+
+#[test]
+fn typecheck_loop_without_break_is_never() {
+    assert_compiles(
+        r#"
+cell first_square_above(limit: Int) -> Int
+  let mut i = 0
+  let found: Int = loop
+    i += 1
+    if i * i > limit
+      return i
+    end
+  end
+  return found
+end
+
+cell main() -> Int
+  return first_square_above(50)
+end
+"#,
+    );
+}
+
 // NOTE: This test is commented out because the typechecker doesn't yet validate
 // nested record field types at construction sites.
 // #[test]
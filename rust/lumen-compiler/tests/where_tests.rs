@@ -150,6 +150,7 @@ fn cell_where_clause_nonzero_divisor_via_ast() {
             is_pub: false,
             is_async: false,
             is_extern: false,
+            is_const: false,
             must_use: false,
             where_clauses: vec![Expr::BinOp(
                 Box::new(Expr::Ident("b".to_string(), span)),
@@ -160,6 +161,10 @@ fn cell_where_clause_nonzero_divisor_via_ast() {
             span,
             doc: None,
             deprecated: None,
+            receiver: None,
+            specializations: vec![],
+            tailcall: false,
+            memoize: None,
         })],
         span,
     };
@@ -200,6 +205,7 @@ fn cell_where_clause_positive_param_via_ast() {
             is_pub: false,
             is_async: false,
             is_extern: false,
+            is_const: false,
             must_use: false,
             where_clauses: vec![Expr::BinOp(
                 Box::new(Expr::Ident("n".to_string(), span)),
@@ -210,6 +216,10 @@ fn cell_where_clause_positive_param_via_ast() {
             span,
             doc: None,
             deprecated: None,
+            receiver: None,
+            specializations: vec![],
+            tailcall: false,
+            memoize: None,
         })],
         span,
     };
@@ -266,6 +276,7 @@ fn cell_where_clause_multiple_via_ast() {
             is_pub: false,
             is_async: false,
             is_extern: false,
+            is_const: false,
             must_use: false,
             where_clauses: vec![
                 Expr::BinOp(
@@ -284,6 +295,10 @@ fn cell_where_clause_multiple_via_ast() {
             span,
             doc: None,
             deprecated: None,
+            receiver: None,
+            specializations: vec![],
+            tailcall: false,
+            memoize: None,
         })],
         span,
     };
@@ -405,6 +420,7 @@ fn collect_cell_where_clause_via_ast() {
             is_pub: false,
             is_async: false,
             is_extern: false,
+            is_const: false,
             must_use: false,
             where_clauses: vec![Expr::BinOp(
                 Box::new(Expr::Ident("b".to_string(), span)),
@@ -415,6 +431,10 @@ fn collect_cell_where_clause_via_ast() {
             span,
             doc: None,
             deprecated: None,
+            receiver: None,
+            specializations: vec![],
+            tailcall: false,
+            memoize: None,
         })],
         span,
     };
@@ -460,11 +480,13 @@ fn verify_record_field_constraint_always_true() {
                 default_value: None,
                 constraint: Some(Expr::BoolLit(true, span)),
                 span,
+                attributes: vec![],
             }],
             is_pub: false,
             span,
             doc: None,
             deprecated: None,
+            packed: false,
         })],
         span,
     };
@@ -531,11 +553,13 @@ fn collect_constraints_from_multiple_records() {
                         span,
                     )),
                     span,
+                    attributes: vec![],
                 }],
                 is_pub: false,
                 span,
                 doc: None,
                 deprecated: None,
+                packed: false,
             }),
             Item::Record(RecordDef {
                 name: "Bounded".to_string(),
@@ -551,11 +575,13 @@ fn collect_constraints_from_multiple_records() {
                         span,
                     )),
                     span,
+                    attributes: vec![],
                 }],
                 is_pub: false,
                 span,
                 doc: None,
                 deprecated: None,
+                packed: false,
             }),
         ],
         span,
@@ -592,11 +618,13 @@ fn collect_no_constraints_from_unconstrained_record() {
                 default_value: None,
                 constraint: None,
                 span,
+                attributes: vec![],
             }],
             is_pub: false,
             span,
             doc: None,
             deprecated: None,
+            packed: false,
         })],
         span,
     };
@@ -650,6 +678,7 @@ fn cell_where_clause_lowers_to_constraint() {
             is_pub: false,
             is_async: false,
             is_extern: false,
+            is_const: false,
             must_use: false,
             where_clauses: vec![Expr::BinOp(
                 Box::new(Expr::Ident("b".to_string(), span)),
@@ -660,6 +689,10 @@ fn cell_where_clause_lowers_to_constraint() {
             span,
             doc: None,
             deprecated: None,
+            receiver: None,
+            specializations: vec![],
+            tailcall: false,
+            memoize: None,
         })],
         span,
     };
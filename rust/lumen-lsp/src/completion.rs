@@ -38,6 +38,7 @@ fn add_keywords(items: &mut Vec<CompletionItem>) {
         "if",
         "else",
         "match",
+        "switch",
         "for",
         "while",
         "loop",
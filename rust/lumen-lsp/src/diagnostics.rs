@@ -706,6 +706,11 @@ fn type_error_to_diagnostic(error: &TypeError) -> Diagnostic {
                 | TypeError::ArgCount { line, .. }
                 | TypeError::MissingReturn { line, .. }
                 | TypeError::ImmutableAssign { line, .. }
+                | TypeError::BadReceiver { line, .. }
+                | TypeError::TraitNotImplemented { line, .. }
+                | TypeError::OperatorNotImplemented { line, .. }
+                | TypeError::NotConst { line, .. }
+                | TypeError::InvalidSpread { line, .. }
                 | TypeError::UndefinedType { line, .. } => *line,
                 _ => 1,
             };
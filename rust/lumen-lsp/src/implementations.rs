@@ -329,7 +329,7 @@ fn collect_handle_sites_in_expr(
         Expr::TryExpr(inner, _) | Expr::AwaitExpr(inner, _) | Expr::ResumeExpr(inner, _) => {
             collect_handle_sites_in_expr(inner, effect_name, uri, out);
         }
-        Expr::NullCoalesce(left, right, _) => {
+        Expr::NullCoalesce(left, right, _) | Expr::ListRepeat(left, right, _) => {
             collect_handle_sites_in_expr(left, effect_name, uri, out);
             collect_handle_sites_in_expr(right, effect_name, uri, out);
         }
@@ -452,7 +452,7 @@ fn collect_record_constructions_in_stmt(
         }
         Stmt::While(while_stmt) => {
             collect_record_constructions_in_expr(&while_stmt.condition, record_name, uri, out);
-            for s in &while_stmt.body {
+            for s in while_stmt.all_stmts() {
                 collect_record_constructions_in_stmt(s, record_name, uri, out);
             }
         }
@@ -592,7 +592,7 @@ fn collect_record_constructions_in_expr(
         Expr::TryExpr(inner, _) | Expr::AwaitExpr(inner, _) | Expr::ResumeExpr(inner, _) => {
             collect_record_constructions_in_expr(inner, record_name, uri, out);
         }
-        Expr::NullCoalesce(left, right, _) => {
+        Expr::NullCoalesce(left, right, _) | Expr::ListRepeat(left, right, _) => {
             collect_record_constructions_in_expr(left, record_name, uri, out);
             collect_record_constructions_in_expr(right, record_name, uri, out);
         }
@@ -93,7 +93,7 @@ fn extract_hints_from_stmt(
         }
         Stmt::While(while_stmt) => {
             extract_param_hints_from_expr(&while_stmt.condition, hints, symbols, cell_defs);
-            for s in &while_stmt.body {
+            for s in while_stmt.all_stmts() {
                 extract_hints_from_stmt(s, hints, symbols, cell_defs);
             }
         }
@@ -221,6 +221,10 @@ fn extract_param_hints_from_expr(
                 extract_param_hints_from_expr(item, hints, symbols, cell_defs);
             }
         }
+        Expr::ListRepeat(value, count, _) => {
+            extract_param_hints_from_expr(value, hints, symbols, cell_defs);
+            extract_param_hints_from_expr(count, hints, symbols, cell_defs);
+        }
         Expr::DotAccess(inner, _, _) => {
             extract_param_hints_from_expr(inner, hints, symbols, cell_defs);
         }
@@ -315,6 +319,7 @@ fn infer_type_from_expr(expr: &Expr) -> String {
                 format!("list[{}]", elem_type)
             }
         }
+        Expr::ListRepeat(value, _, _) => format!("list[{}]", infer_type_from_expr(value)),
         Expr::MapLit(entries, _) => {
             if entries.is_empty() {
                 "map[_, _]".to_string()
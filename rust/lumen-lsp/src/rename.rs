@@ -254,7 +254,7 @@ fn collect_occurrences_in_stmt(stmt: &Stmt, name: &str, out: &mut Vec<SymbolOccu
         }
         Stmt::While(while_stmt) => {
             collect_occurrences_in_expr(&while_stmt.condition, name, out);
-            for s in &while_stmt.body {
+            for s in while_stmt.all_stmts() {
                 collect_occurrences_in_stmt(s, name, out);
             }
         }
@@ -457,7 +457,7 @@ fn collect_occurrences_in_expr(expr: &Expr, name: &str, out: &mut Vec<SymbolOccu
         Expr::TryExpr(inner, _) => {
             collect_occurrences_in_expr(inner, name, out);
         }
-        Expr::NullCoalesce(left, right, _) => {
+        Expr::NullCoalesce(left, right, _) | Expr::ListRepeat(left, right, _) => {
             collect_occurrences_in_expr(left, name, out);
             collect_occurrences_in_expr(right, name, out);
         }
@@ -710,6 +710,7 @@ fn is_keyword(word: &str) -> bool {
             | "if"
             | "else"
             | "match"
+            | "switch"
             | "for"
             | "while"
             | "loop"
@@ -0,0 +1,383 @@
+//! CSV records for the Lumen runtime (`std.csv`).
+//!
+//! The format is RFC 4180: fields are separated by commas and records by
+//! `\n` or `\r\n`. A field in double quotes may hold commas, quotes written
+//! as `""`, and line breaks, which are kept as they appear in the input.
+//! Blank lines between records are skipped.
+//!
+//! [`parse_record`] reads one record from the front of a buffer that may
+//! hold only part of the input, so a caller can read a large file in
+//! chunks: a record that is not complete yet comes back as `None` until the
+//! rest arrives or `eof` says it never will.
+//!
+//! # Examples
+//!
+//! ```rust
+//! use lumen_runtime::csv::{format_record, parse_record};
+//!
+//! let input = b"name,quote\r\nAda,\"said \"\"hi\"\", twice\"\r\n";
+//! let header = parse_record(input, 1, true).unwrap().unwrap();
+//! assert_eq!(header.fields, ["name", "quote"]);
+//! let row = parse_record(&input[header.consumed..], header.next_line, true)
+//!     .unwrap()
+//!     .unwrap();
+//! assert_eq!(row.fields, ["Ada", "said \"hi\", twice"]);
+//! assert_eq!(format_record(&row.fields, false), "Ada,\"said \"\"hi\"\", twice\"\n");
+//! ```
+
+use std::fmt;
+
+/// One parsed record.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct Record {
+    pub fields: Vec<String>,
+    /// Bytes of the input the record used, with its terminator and any
+    /// blank lines before it
+    pub consumed: usize,
+    /// The line number the next record starts on
+    pub next_line: usize,
+}
+
+/// A malformed record, located by line and 1-based byte column.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct CsvError {
+    pub line: usize,
+    pub column: usize,
+    pub kind: CsvErrorKind,
+}
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum CsvErrorKind {
+    /// A `"` inside a field that did not start with one
+    BareQuote,
+    /// Something other than a separator right after a closing `"`
+    TextAfterQuote,
+    /// The input ended inside a quoted field
+    UnterminatedQuote,
+    InvalidUtf8,
+}
+
+impl fmt::Display for CsvError {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        let what = match self.kind {
+            CsvErrorKind::BareQuote => "bare quote in unquoted field",
+            CsvErrorKind::TextAfterQuote => "unexpected character after closing quote",
+            CsvErrorKind::UnterminatedQuote => "unterminated quoted field",
+            CsvErrorKind::InvalidUtf8 => "invalid UTF-8",
+        };
+        write!(f, "line {}, column {}: {}", self.line, self.column, what)
+    }
+}
+
+impl std::error::Error for CsvError {}
+
+/// Parse the first record of `input`, whose first byte is on line `line`.
+///
+/// Returns `Ok(None)` when `input` holds no complete record: either more
+/// input is needed, or `eof` is set and only blank lines were left.
+pub fn parse_record(input: &[u8], line: usize, eof: bool) -> Result<Option<Record>, CsvError> {
+    let mut p = Parser {
+        input,
+        pos: 0,
+        line,
+        line_start: 0,
+        eof,
+    };
+    // Skip blank lines
+    loop {
+        match p.terminator() {
+            Some(0) => return Ok(None),
+            Some(len) => p.newline(len),
+            None => break,
+        }
+    }
+    if p.pos == input.len() {
+        return Ok(None);
+    }
+
+    let mut fields = Vec::new();
+    loop {
+        let Some(field) = p.field()? else {
+            return Ok(None);
+        };
+        fields.push(field);
+        if p.pos == input.len() {
+            // `field` only stops at the end of the input when `eof` is set
+            break;
+        }
+        if input[p.pos] == b',' {
+            p.pos += 1;
+            continue;
+        }
+        match p.terminator() {
+            Some(0) => return Ok(None),
+            Some(len) => {
+                p.newline(len);
+                break;
+            }
+            None => unreachable!("a field ends at a separator"),
+        }
+    }
+    Ok(Some(Record {
+        fields,
+        consumed: p.pos,
+        next_line: p.line,
+    }))
+}
+
+struct Parser<'a> {
+    input: &'a [u8],
+    pos: usize,
+    line: usize,
+    line_start: usize,
+    eof: bool,
+}
+
+impl Parser<'_> {
+    /// The length of the record terminator at `pos`, `Some(0)` when that
+    /// cannot be told until more input arrives, or `None` for no terminator.
+    fn terminator(&self) -> Option<usize> {
+        match &self.input[self.pos..] {
+            [b'\n', ..] => Some(1),
+            [b'\r', b'\n', ..] => Some(2),
+            [b'\r'] if !self.eof => Some(0),
+            _ => None,
+        }
+    }
+
+    fn newline(&mut self, len: usize) {
+        self.pos += len;
+        self.line += 1;
+        self.line_start = self.pos;
+    }
+
+    fn error(&self, at: usize, kind: CsvErrorKind) -> CsvError {
+        CsvError {
+            line: self.line,
+            column: at - self.line_start + 1,
+            kind,
+        }
+    }
+
+    fn text(&self, bytes: Vec<u8>, start: usize) -> Result<String, CsvError> {
+        String::from_utf8(bytes).map_err(|e| {
+            self.error(
+                start + e.utf8_error().valid_up_to(),
+                CsvErrorKind::InvalidUtf8,
+            )
+        })
+    }
+
+    /// Read the field at `pos`, leaving `pos` on the separator, terminator,
+    /// or end of input after it. `None` means more input is needed.
+    fn field(&mut self) -> Result<Option<String>, CsvError> {
+        let input = self.input;
+        let start = self.pos;
+        if input.get(start) != Some(&b'"') {
+            let mut end = start;
+            loop {
+                match input.get(end) {
+                    None if !self.eof => return Ok(None),
+                    None | Some(b',') | Some(b'\n') => break,
+                    Some(b'\r') => match input.get(end + 1) {
+                        Some(b'\n') => break,
+                        None if !self.eof => return Ok(None),
+                        // A `\r` that does not end the line belongs to the field
+                        _ => end += 1,
+                    },
+                    Some(b'"') => return Err(self.error(end, CsvErrorKind::BareQuote)),
+                    Some(_) => end += 1,
+                }
+            }
+            self.pos = end;
+            return self.text(input[start..end].to_vec(), start).map(Some);
+        }
+
+        let (line, line_start) = (self.line, self.line_start);
+        let mut value = Vec::new();
+        let mut i = start + 1;
+        loop {
+            let Some(&b) = input.get(i) else {
+                if !self.eof {
+                    return Ok(None);
+                }
+                self.line = line;
+                self.line_start = line_start;
+                return Err(self.error(start, CsvErrorKind::UnterminatedQuote));
+            };
+            match b {
+                b'"' => match input.get(i + 1) {
+                    Some(b'"') => {
+                        value.push(b'"');
+                        i += 2;
+                    }
+                    None if !self.eof => return Ok(None),
+                    _ => {
+                        i += 1;
+                        break;
+                    }
+                },
+                b'\n' => {
+                    value.push(b);
+                    i += 1;
+                    self.line += 1;
+                    self.line_start = i;
+                }
+                _ => {
+                    value.push(b);
+                    i += 1;
+                }
+            }
+        }
+        self.pos = i;
+        if i < input.len() && input[i] != b',' {
+            match self.terminator() {
+                Some(0) => return Ok(None),
+                Some(_) => {}
+                None => return Err(self.error(i, CsvErrorKind::TextAfterQuote)),
+            }
+        }
+        self.text(value, start + 1).map(Some)
+    }
+}
+
+/// Format `fields` as one record, ending in `\r\n` when `crlf` is set and
+/// `\n` otherwise. A field is quoted when it holds a comma, a quote, or a
+/// line break, and a record of one empty field is written as `""` so it
+/// does not read back as a blank line.
+pub fn format_record<S: AsRef<str>>(fields: &[S], crlf: bool) -> String {
+    let mut out = String::new();
+    for (i, field) in fields.iter().enumerate() {
+        let field = field.as_ref();
+        if i > 0 {
+            out.push(',');
+        }
+        if field.contains([',', '"', '\r', '\n']) || (fields.len() == 1 && field.is_empty()) {
+            out.push('"');
+            out.push_str(&field.replace('"', "\"\""));
+            out.push('"');
+        } else {
+            out.push_str(field);
+        }
+    }
+    out.push_str(if crlf { "\r\n" } else { "\n" });
+    out
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    /// Every record of `input`, read as a whole.
+    fn parse_all(input: &str) -> Result<Vec<Vec<String>>, CsvError> {
+        let mut records = Vec::new();
+        let (mut pos, mut line) = (0, 1);
+        while let Some(r) = parse_record(&input.as_bytes()[pos..], line, true)? {
+            pos += r.consumed;
+            line = r.next_line;
+            records.push(r.fields);
+        }
+        Ok(records)
+    }
+
+    /// Every record of `input`, fed to the parser `chunk` bytes at a time.
+    fn parse_chunked(input: &str, chunk: usize) -> Result<Vec<Vec<String>>, CsvError> {
+        let bytes = input.as_bytes();
+        let mut records = Vec::new();
+        let (mut buffer, mut fed, mut line) = (Vec::new(), 0, 1);
+        loop {
+            let eof = fed == bytes.len();
+            match parse_record(&buffer, line, eof)? {
+                Some(r) => {
+                    buffer.drain(..r.consumed);
+                    line = r.next_line;
+                    records.push(r.fields);
+                }
+                None if eof => return Ok(records),
+                None => {
+                    let end = (fed + chunk).min(bytes.len());
+                    buffer.extend_from_slice(&bytes[fed..end]);
+                    fed = end;
+                }
+            }
+        }
+    }
+
+    #[test]
+    fn quoted_fields_hold_commas_quotes_and_newlines() {
+        let input = "a,\"b,c\",\"say \"\"hi\"\"\"\n\"two\nlines\",,end\n";
+        let expected = vec![
+            vec!["a", "b,c", "say \"hi\""],
+            vec!["two\nlines", "", "end"],
+        ];
+        assert_eq!(parse_all(input).unwrap(), expected);
+    }
+
+    #[test]
+    fn crlf_blank_lines_and_missing_final_newline() {
+        let input = "h1,h2\r\n\r\n1,2\r\n\n3,\r\n4,\"5\"";
+        let expected = vec![
+            vec!["h1", "h2"],
+            vec!["1", "2"],
+            vec!["3", ""],
+            vec!["4", "5"],
+        ];
+        assert_eq!(parse_all(input).unwrap(), expected);
+        // A lone `\r` is field data
+        assert_eq!(parse_all("a\rb,c\n").unwrap(), vec![vec!["a\rb", "c"]]);
+    }
+
+    #[test]
+    fn chunked_input_parses_like_whole_input() {
+        let input =
+            "id,text\r\n1,\"quoted, with \"\"quotes\"\"\r\nand a break\"\r\n2,é\r\n\r\n3,\"\"";
+        let whole = parse_all(input).unwrap();
+        assert_eq!(whole.len(), 4);
+        for chunk in 1..input.len() {
+            assert_eq!(
+                parse_chunked(input, chunk).unwrap(),
+                whole,
+                "chunk {}",
+                chunk
+            );
+        }
+    }
+
+    #[test]
+    fn malformed_records_are_located() {
+        let err = parse_all("a,b\nab\"c,d\n").unwrap_err();
+        assert_eq!(
+            (err.line, err.column, err.kind),
+            (2, 3, CsvErrorKind::BareQuote)
+        );
+        let err = parse_all("\"ab\"c,d\n").unwrap_err();
+        assert_eq!(
+            (err.line, err.column, err.kind),
+            (1, 5, CsvErrorKind::TextAfterQuote)
+        );
+        let err = parse_all("x\n\"open\nstill open").unwrap_err();
+        assert_eq!(
+            (err.line, err.column, err.kind),
+            (2, 1, CsvErrorKind::UnterminatedQuote)
+        );
+        assert_eq!(
+            err.to_string(),
+            "line 2, column 1: unterminated quoted field"
+        );
+    }
+
+    #[test]
+    fn formatted_records_round_trip() {
+        let records: Vec<Vec<&str>> = vec![
+            vec!["plain", "with,comma", "with \"quote\""],
+            vec!["multi\nline", "", "cr\r\nlf"],
+            vec![""],
+        ];
+        for crlf in [false, true] {
+            let text: String = records.iter().map(|r| format_record(r, crlf)).collect();
+            assert_eq!(parse_all(&text).unwrap(), records);
+        }
+        assert_eq!(format_record(&["a", "b,c"], true), "a,\"b,c\"\r\n");
+        assert_eq!(format_record(&[""], false), "\"\"\n");
+    }
+}
@@ -0,0 +1,253 @@
+//! Base64 and hex codecs for the Lumen runtime (`std.encoding`).
+//!
+//! Base64 uses the standard alphabet of RFC 4648 with `=` padding, so every
+//! encoding is a multiple of four characters long. Hex encodes each byte as
+//! two lower-case digits and decodes either case.
+//!
+//! Decoding is strict: white space, line breaks, and missing padding are
+//! rejected, and the first problem found is reported with its byte offset
+//! in the input.
+//!
+//! # Examples
+//!
+//! ```rust
+//! use lumen_runtime::encoding::{base64_decode, base64_encode, DecodeError};
+//!
+//! assert_eq!(base64_encode(b"lumen"), "bHVtZW4=");
+//! assert_eq!(base64_decode("bHVtZW4=").unwrap(), b"lumen");
+//! assert_eq!(base64_decode("bHVtZW4"), Err(DecodeError::Length { len: 7 }));
+//! ```
+
+use std::fmt;
+
+const BASE64_ALPHABET: &[u8; 64] =
+    b"ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/";
+const HEX_DIGITS: &[u8; 16] = b"0123456789abcdef";
+
+/// Why a string could not be decoded.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub enum DecodeError {
+    /// The input is not a whole number of base64 quads or hex pairs.
+    Length { len: usize },
+    /// A character outside the alphabet, at a byte offset into the input.
+    Character { ch: char, offset: usize },
+    /// A base64 `=` somewhere other than the last one or two characters.
+    Padding { offset: usize },
+}
+
+impl fmt::Display for DecodeError {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        match self {
+            DecodeError::Length { len } => write!(f, "invalid input length {}", len),
+            DecodeError::Character { ch, offset } => {
+                write!(f, "invalid character {:?} at offset {}", ch, offset)
+            }
+            DecodeError::Padding { offset } => write!(f, "misplaced padding at offset {}", offset),
+        }
+    }
+}
+
+impl std::error::Error for DecodeError {}
+
+/// Encode `data` as padded standard base64.
+pub fn base64_encode(data: &[u8]) -> String {
+    let mut out = String::with_capacity(data.len().div_ceil(3) * 4);
+    for chunk in data.chunks(3) {
+        let b = [
+            chunk[0],
+            chunk.get(1).copied().unwrap_or(0),
+            chunk.get(2).copied().unwrap_or(0),
+        ];
+        let triple = ((b[0] as u32) << 16) | ((b[1] as u32) << 8) | (b[2] as u32);
+        for i in 0..4 {
+            if i <= chunk.len() {
+                out.push(BASE64_ALPHABET[((triple >> (18 - 6 * i)) & 0x3F) as usize] as char);
+            } else {
+                out.push('=');
+            }
+        }
+    }
+    out
+}
+
+/// Decode padded standard base64.
+///
+/// The input must be a multiple of four characters long. `=` may only
+/// appear as the last one or two characters.
+pub fn base64_decode(s: &str) -> Result<Vec<u8>, DecodeError> {
+    let bytes = s.as_bytes();
+    if bytes.len() % 4 != 0 {
+        return Err(DecodeError::Length { len: bytes.len() });
+    }
+    let padding = bytes
+        .iter()
+        .rev()
+        .take(2)
+        .take_while(|&&b| b == b'=')
+        .count();
+    let data_len = bytes.len() - padding;
+    let mut out = Vec::with_capacity(bytes.len() / 4 * 3);
+    let mut acc = 0u32;
+    for (offset, &b) in bytes[..data_len].iter().enumerate() {
+        let value = match b {
+            b'=' => return Err(DecodeError::Padding { offset }),
+            _ => base64_value(b).ok_or_else(|| DecodeError::Character {
+                ch: char_at(s, offset),
+                offset,
+            })?,
+        };
+        acc = (acc << 6) | value as u32;
+        if offset % 4 == 3 {
+            out.extend_from_slice(&[(acc >> 16) as u8, (acc >> 8) as u8, acc as u8]);
+            acc = 0;
+        }
+    }
+    match padding {
+        1 => {
+            acc <<= 6;
+            out.extend_from_slice(&[(acc >> 16) as u8, (acc >> 8) as u8]);
+        }
+        2 => {
+            acc <<= 12;
+            out.push((acc >> 16) as u8);
+        }
+        _ => {}
+    }
+    Ok(out)
+}
+
+/// Encode `data` as lower-case hex, two digits per byte.
+pub fn hex_encode(data: &[u8]) -> String {
+    let mut out = String::with_capacity(data.len() * 2);
+    for &b in data {
+        out.push(HEX_DIGITS[(b >> 4) as usize] as char);
+        out.push(HEX_DIGITS[(b & 0x0F) as usize] as char);
+    }
+    out
+}
+
+/// Decode hex in either case. The input must have an even length.
+pub fn hex_decode(s: &str) -> Result<Vec<u8>, DecodeError> {
+    let bytes = s.as_bytes();
+    let digit = |offset: usize| {
+        (bytes[offset] as char)
+            .to_digit(16)
+            .ok_or_else(|| DecodeError::Character {
+                ch: char_at(s, offset),
+                offset,
+            })
+    };
+    // A character reported before the length is more useful for text
+    // that was never hex at all
+    let mut out = Vec::with_capacity(bytes.len() / 2);
+    for pair in (0..bytes.len()).step_by(2) {
+        let high = digit(pair)?;
+        if pair + 1 == bytes.len() {
+            return Err(DecodeError::Length { len: bytes.len() });
+        }
+        let low = digit(pair + 1)?;
+        out.push(((high << 4) | low) as u8);
+    }
+    Ok(out)
+}
+
+fn base64_value(b: u8) -> Option<u8> {
+    match b {
+        b'A'..=b'Z' => Some(b - b'A'),
+        b'a'..=b'z' => Some(b - b'a' + 26),
+        b'0'..=b'9' => Some(b - b'0' + 52),
+        b'+' => Some(62),
+        b'/' => Some(63),
+        _ => None,
+    }
+}
+
+/// The character of `s` whose encoding includes byte `offset`.
+fn char_at(s: &str, offset: usize) -> char {
+    s.char_indices()
+        .take_while(|&(start, _)| start <= offset)
+        .last()
+        .map_or(char::REPLACEMENT_CHARACTER, |(_, ch)| ch)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn base64_rfc4648_vectors() {
+        let vectors = [
+            ("", ""),
+            ("f", "Zg=="),
+            ("fo", "Zm8="),
+            ("foo", "Zm9v"),
+            ("foob", "Zm9vYg=="),
+            ("fooba", "Zm9vYmE="),
+            ("foobar", "Zm9vYmFy"),
+        ];
+        for (plain, encoded) in vectors {
+            assert_eq!(base64_encode(plain.as_bytes()), encoded);
+            assert_eq!(base64_decode(encoded).unwrap(), plain.as_bytes());
+        }
+    }
+
+    #[test]
+    fn base64_rejects_malformed_input() {
+        assert_eq!(base64_decode("Zm9"), Err(DecodeError::Length { len: 3 }));
+        assert_eq!(
+            base64_decode("Zm9v\nYg=="),
+            Err(DecodeError::Length { len: 9 })
+        );
+        assert_eq!(
+            base64_decode("Zm*v"),
+            Err(DecodeError::Character { ch: '*', offset: 2 })
+        );
+        assert_eq!(
+            base64_decode("Zg==Zm8="),
+            Err(DecodeError::Padding { offset: 2 })
+        );
+        assert_eq!(
+            base64_decode("Z==="),
+            Err(DecodeError::Padding { offset: 1 })
+        );
+        assert_eq!(base64_decode("Zm9é"), Err(DecodeError::Length { len: 5 }));
+        assert_eq!(
+            base64_decode("Zmé"),
+            Err(DecodeError::Character {
+                ch: 'é', offset: 2
+            })
+        );
+    }
+
+    #[test]
+    fn hex_round_trip_and_errors() {
+        let data: Vec<u8> = (0..=255).collect();
+        let encoded = hex_encode(&data);
+        assert_eq!(&encoded[..8], "00010203");
+        assert_eq!(hex_decode(&encoded).unwrap(), data);
+        assert_eq!(hex_decode("DEADbeef").unwrap(), [0xde, 0xad, 0xbe, 0xef]);
+        assert_eq!(hex_decode("abc"), Err(DecodeError::Length { len: 3 }));
+        assert_eq!(
+            hex_decode("0g"),
+            Err(DecodeError::Character { ch: 'g', offset: 1 })
+        );
+        assert_eq!(
+            hex_decode("ü0"),
+            Err(DecodeError::Character {
+                ch: 'ü', offset: 0
+            })
+        );
+    }
+
+    #[test]
+    fn error_messages_name_the_problem() {
+        assert_eq!(
+            DecodeError::Character { ch: '*', offset: 2 }.to_string(),
+            "invalid character '*' at offset 2"
+        );
+        assert_eq!(
+            DecodeError::Length { len: 3 }.to_string(),
+            "invalid input length 3"
+        );
+    }
+}
@@ -0,0 +1,447 @@
+//! Streaming checksums and digests for the Lumen runtime (`std.hash`).
+//!
+//! Provides FNV-1a (64-bit), CRC-32 (IEEE 802.3), and SHA-256 behind a common
+//! [`StreamHasher`] interface: feed data with `write` any number of times and
+//! read the digest with `sum`. Writing in pieces always yields the same digest
+//! as writing the concatenation once.
+//!
+//! Lumen values are immutable, so the VM cannot hold a live hasher between
+//! calls. Each hasher can therefore be saved to and restored from a compact
+//! byte string with [`StreamHasher::state`] and [`StreamHasher::from_state`].
+//!
+//! All three algorithms are implemented in pure Rust so their state can be
+//! serialized without reaching into another crate's internals.
+//!
+//! # Examples
+//!
+//! ```rust
+//! use lumen_runtime::hash::{Algorithm, StreamHasher};
+//!
+//! let mut h = StreamHasher::new(Algorithm::Crc32);
+//! h.write(b"1234");
+//! h.write(b"56789");
+//! assert_eq!(h.sum(), vec![0xcb, 0xf4, 0x39, 0x26]);
+//! ```
+
+use std::fmt;
+
+/// The hash algorithms supported by [`StreamHasher`].
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum Algorithm {
+    Fnv1a64,
+    Crc32,
+    Sha256,
+}
+
+impl Algorithm {
+    /// Parse the name used by Lumen code: `"fnv1a"`, `"crc32"`, or `"sha256"`.
+    pub fn from_name(name: &str) -> Option<Self> {
+        match name {
+            "fnv1a" | "fnv1a64" => Some(Algorithm::Fnv1a64),
+            "crc32" => Some(Algorithm::Crc32),
+            "sha256" => Some(Algorithm::Sha256),
+            _ => None,
+        }
+    }
+
+    pub fn name(self) -> &'static str {
+        match self {
+            Algorithm::Fnv1a64 => "fnv1a",
+            Algorithm::Crc32 => "crc32",
+            Algorithm::Sha256 => "sha256",
+        }
+    }
+
+    /// Length of the digest returned by `sum`, in bytes.
+    pub fn digest_len(self) -> usize {
+        match self {
+            Algorithm::Fnv1a64 => 8,
+            Algorithm::Crc32 => 4,
+            Algorithm::Sha256 => 32,
+        }
+    }
+}
+
+impl fmt::Display for Algorithm {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        f.write_str(self.name())
+    }
+}
+
+/// An incremental hasher for one [`Algorithm`].
+#[derive(Debug, Clone)]
+pub enum StreamHasher {
+    Fnv1a64(u64),
+    Crc32(u32),
+    Sha256(Sha256State),
+}
+
+const FNV_OFFSET_BASIS: u64 = 0xcbf2_9ce4_8422_2325;
+const FNV_PRIME: u64 = 0x0000_0100_0000_01b3;
+
+impl StreamHasher {
+    pub fn new(algorithm: Algorithm) -> Self {
+        match algorithm {
+            Algorithm::Fnv1a64 => StreamHasher::Fnv1a64(FNV_OFFSET_BASIS),
+            Algorithm::Crc32 => StreamHasher::Crc32(0xffff_ffff),
+            Algorithm::Sha256 => StreamHasher::Sha256(Sha256State::new()),
+        }
+    }
+
+    pub fn algorithm(&self) -> Algorithm {
+        match self {
+            StreamHasher::Fnv1a64(_) => Algorithm::Fnv1a64,
+            StreamHasher::Crc32(_) => Algorithm::Crc32,
+            StreamHasher::Sha256(_) => Algorithm::Sha256,
+        }
+    }
+
+    /// Feed more data into the hasher.
+    pub fn write(&mut self, data: &[u8]) {
+        match self {
+            StreamHasher::Fnv1a64(h) => {
+                for &b in data {
+                    *h ^= b as u64;
+                    *h = h.wrapping_mul(FNV_PRIME);
+                }
+            }
+            StreamHasher::Crc32(c) => {
+                let table = crc32_table();
+                for &b in data {
+                    *c = table[((*c ^ b as u32) & 0xff) as usize] ^ (*c >> 8);
+                }
+            }
+            StreamHasher::Sha256(s) => s.update(data),
+        }
+    }
+
+    /// The digest of everything written so far, big-endian. Does not reset
+    /// the hasher.
+    pub fn sum(&self) -> Vec<u8> {
+        match self {
+            StreamHasher::Fnv1a64(h) => h.to_be_bytes().to_vec(),
+            StreamHasher::Crc32(c) => (!c).to_be_bytes().to_vec(),
+            StreamHasher::Sha256(s) => s.clone().finish().to_vec(),
+        }
+    }
+
+    /// Serialize the in-progress state.
+    pub fn state(&self) -> Vec<u8> {
+        match self {
+            StreamHasher::Fnv1a64(h) => h.to_be_bytes().to_vec(),
+            StreamHasher::Crc32(c) => c.to_be_bytes().to_vec(),
+            StreamHasher::Sha256(s) => {
+                let mut out = Vec::with_capacity(40 + s.pending.len());
+                for word in s.h {
+                    out.extend_from_slice(&word.to_be_bytes());
+                }
+                out.extend_from_slice(&s.total_len.to_be_bytes());
+                out.extend_from_slice(&s.pending);
+                out
+            }
+        }
+    }
+
+    /// Restore a hasher saved with [`StreamHasher::state`]. Returns `None` if
+    /// `state` is not a valid state for `algorithm`.
+    pub fn from_state(algorithm: Algorithm, state: &[u8]) -> Option<Self> {
+        match algorithm {
+            Algorithm::Fnv1a64 => {
+                let bytes: [u8; 8] = state.try_into().ok()?;
+                Some(StreamHasher::Fnv1a64(u64::from_be_bytes(bytes)))
+            }
+            Algorithm::Crc32 => {
+                let bytes: [u8; 4] = state.try_into().ok()?;
+                Some(StreamHasher::Crc32(u32::from_be_bytes(bytes)))
+            }
+            Algorithm::Sha256 => {
+                if state.len() < 40 || state.len() >= 40 + 64 {
+                    return None;
+                }
+                let mut h = [0u32; 8];
+                for (i, word) in h.iter_mut().enumerate() {
+                    *word = u32::from_be_bytes(state[i * 4..i * 4 + 4].try_into().ok()?);
+                }
+                let total_len = u64::from_be_bytes(state[32..40].try_into().ok()?);
+                let pending = state[40..].to_vec();
+                if total_len % 64 != pending.len() as u64 {
+                    return None;
+                }
+                Some(StreamHasher::Sha256(Sha256State {
+                    h,
+                    total_len,
+                    pending,
+                }))
+            }
+        }
+    }
+}
+
+/// One-shot digest of `data`.
+pub fn digest(algorithm: Algorithm, data: &[u8]) -> Vec<u8> {
+    let mut h = StreamHasher::new(algorithm);
+    h.write(data);
+    h.sum()
+}
+
+// ---------------------------------------------------------------------------
+// CRC-32
+// ---------------------------------------------------------------------------
+
+/// Lookup table for the reflected IEEE polynomial 0xEDB88320.
+fn crc32_table() -> &'static [u32; 256] {
+    static TABLE: std::sync::OnceLock<[u32; 256]> = std::sync::OnceLock::new();
+    TABLE.get_or_init(|| {
+        let mut table = [0u32; 256];
+        for (i, entry) in table.iter_mut().enumerate() {
+            let mut c = i as u32;
+            for _ in 0..8 {
+                c = if c & 1 != 0 {
+                    0xedb8_8320 ^ (c >> 1)
+                } else {
+                    c >> 1
+                };
+            }
+            *entry = c;
+        }
+        table
+    })
+}
+
+// ---------------------------------------------------------------------------
+// SHA-256 (FIPS 180-4)
+// ---------------------------------------------------------------------------
+
+const SHA256_K: [u32; 64] = [
+    0x428a2f98, 0x71374491, 0xb5c0fbcf, 0xe9b5dba5, 0x3956c25b, 0x59f111f1, 0x923f82a4, 0xab1c5ed5,
+    0xd807aa98, 0x12835b01, 0x243185be, 0x550c7dc3, 0x72be5d74, 0x80deb1fe, 0x9bdc06a7, 0xc19bf174,
+    0xe49b69c1, 0xefbe4786, 0x0fc19dc6, 0x240ca1cc, 0x2de92c6f, 0x4a7484aa, 0x5cb0a9dc, 0x76f988da,
+    0x983e5152, 0xa831c66d, 0xb00327c8, 0xbf597fc7, 0xc6e00bf3, 0xd5a79147, 0x06ca6351, 0x14292967,
+    0x27b70a85, 0x2e1b2138, 0x4d2c6dfc, 0x53380d13, 0x650a7354, 0x766a0abb, 0x81c2c92e, 0x92722c85,
+    0xa2bfe8a1, 0xa81a664b, 0xc24b8b70, 0xc76c51a3, 0xd192e819, 0xd6990624, 0xf40e3585, 0x106aa070,
+    0x19a4c116, 0x1e376c08, 0x2748774c, 0x34b0bcb5, 0x391c0cb3, 0x4ed8aa4a, 0x5b9cca4f, 0x682e6ff3,
+    0x748f82ee, 0x78a5636f, 0x84c87814, 0x8cc70208, 0x90befffa, 0xa4506ceb, 0xbef9a3f7, 0xc67178f2,
+];
+
+const SHA256_INIT: [u32; 8] = [
+    0x6a09e667, 0xbb67ae85, 0x3c6ef372, 0xa54ff53a, 0x510e527f, 0x9b05688c, 0x1f83d9ab, 0x5be0cd19,
+];
+
+/// In-progress SHA-256 computation.
+#[derive(Debug, Clone)]
+pub struct Sha256State {
+    h: [u32; 8],
+    /// Total number of bytes written.
+    total_len: u64,
+    /// Bytes not yet compressed; always shorter than one 64-byte block.
+    pending: Vec<u8>,
+}
+
+impl Sha256State {
+    fn new() -> Self {
+        Self {
+            h: SHA256_INIT,
+            total_len: 0,
+            pending: Vec::with_capacity(64),
+        }
+    }
+
+    fn update(&mut self, mut data: &[u8]) {
+        self.total_len += data.len() as u64;
+        if !self.pending.is_empty() {
+            let take = (64 - self.pending.len()).min(data.len());
+            self.pending.extend_from_slice(&data[..take]);
+            data = &data[take..];
+            if self.pending.len() < 64 {
+                return;
+            }
+            let block: [u8; 64] = self.pending[..].try_into().expect("full block");
+            self.compress(&block);
+            self.pending.clear();
+        }
+        let mut blocks = data.chunks_exact(64);
+        for block in &mut blocks {
+            self.compress(block.try_into().expect("64-byte chunk"));
+        }
+        self.pending.extend_from_slice(blocks.remainder());
+    }
+
+    fn finish(mut self) -> [u8; 32] {
+        let bit_len = self.total_len.wrapping_mul(8);
+        let mut tail = std::mem::take(&mut self.pending);
+        tail.push(0x80);
+        while tail.len() % 64 != 56 {
+            tail.push(0);
+        }
+        tail.extend_from_slice(&bit_len.to_be_bytes());
+        for block in tail.chunks_exact(64) {
+            self.compress(block.try_into().expect("64-byte chunk"));
+        }
+        let mut out = [0u8; 32];
+        for (i, word) in self.h.iter().enumerate() {
+            out[i * 4..i * 4 + 4].copy_from_slice(&word.to_be_bytes());
+        }
+        out
+    }
+
+    fn compress(&mut self, block: &[u8; 64]) {
+        let mut w = [0u32; 64];
+        for (i, chunk) in block.chunks_exact(4).enumerate() {
+            w[i] = u32::from_be_bytes(chunk.try_into().expect("4-byte word"));
+        }
+        for i in 16..64 {
+            let s0 = w[i - 15].rotate_right(7) ^ w[i - 15].rotate_right(18) ^ (w[i - 15] >> 3);
+            let s1 = w[i - 2].rotate_right(17) ^ w[i - 2].rotate_right(19) ^ (w[i - 2] >> 10);
+            w[i] = w[i - 16]
+                .wrapping_add(s0)
+                .wrapping_add(w[i - 7])
+                .wrapping_add(s1);
+        }
+
+        let [mut a, mut b, mut c, mut d, mut e, mut f, mut g, mut h] = self.h;
+        for i in 0..64 {
+            let s1 = e.rotate_right(6) ^ e.rotate_right(11) ^ e.rotate_right(25);
+            let ch = (e & f) ^ (!e & g);
+            let t1 = h
+                .wrapping_add(s1)
+                .wrapping_add(ch)
+                .wrapping_add(SHA256_K[i])
+                .wrapping_add(w[i]);
+            let s0 = a.rotate_right(2) ^ a.rotate_right(13) ^ a.rotate_right(22);
+            let maj = (a & b) ^ (a & c) ^ (b & c);
+            let t2 = s0.wrapping_add(maj);
+            h = g;
+            g = f;
+            f = e;
+            e = d.wrapping_add(t1);
+            d = c;
+            c = b;
+            b = a;
+            a = t1.wrapping_add(t2);
+        }
+
+        for (slot, v) in self.h.iter_mut().zip([a, b, c, d, e, f, g, h]) {
+            *slot = slot.wrapping_add(v);
+        }
+    }
+}
+
+// ===========================================================================
+// Tests
+// ===========================================================================
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn hex(bytes: &[u8]) -> String {
+        bytes.iter().map(|b| format!("{:02x}", b)).collect()
+    }
+
+    #[test]
+    fn fnv1a_reference_vectors() {
+        assert_eq!(hex(&digest(Algorithm::Fnv1a64, b"")), "cbf29ce484222325");
+        assert_eq!(hex(&digest(Algorithm::Fnv1a64, b"a")), "af63dc4c8601ec8c");
+        assert_eq!(
+            hex(&digest(Algorithm::Fnv1a64, b"foobar")),
+            "85944171f73967e8"
+        );
+    }
+
+    #[test]
+    fn crc32_reference_vectors() {
+        assert_eq!(hex(&digest(Algorithm::Crc32, b"")), "00000000");
+        assert_eq!(hex(&digest(Algorithm::Crc32, b"123456789")), "cbf43926");
+        assert_eq!(
+            hex(&digest(
+                Algorithm::Crc32,
+                b"The quick brown fox jumps over the lazy dog"
+            )),
+            "414fa339"
+        );
+    }
+
+    #[test]
+    fn sha256_reference_vectors() {
+        assert_eq!(
+            hex(&digest(Algorithm::Sha256, b"")),
+            "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+        );
+        assert_eq!(
+            hex(&digest(Algorithm::Sha256, b"abc")),
+            "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+        );
+        assert_eq!(
+            hex(&digest(
+                Algorithm::Sha256,
+                b"abcdbcdecdefdefgefghfghighijhijkijkljklmklmnlmnomnopnopq"
+            )),
+            "248d6a61d20638b8e5c026930c3e6039a33ce45964ff2167f6ecedd419db06c1"
+        );
+    }
+
+    #[test]
+    fn sha256_matches_sha2_crate_across_block_boundaries() {
+        use sha2::{Digest, Sha256};
+        for len in [0usize, 1, 55, 56, 63, 64, 65, 119, 120, 128, 1000] {
+            let data: Vec<u8> = (0..len).map(|i| (i * 31 % 251) as u8).collect();
+            assert_eq!(
+                digest(Algorithm::Sha256, &data),
+                Sha256::digest(&data).to_vec(),
+                "length {}",
+                len
+            );
+        }
+    }
+
+    #[test]
+    fn incremental_writes_equal_single_write() {
+        let data: Vec<u8> = (0..300u32).map(|i| (i % 256) as u8).collect();
+        for algorithm in [Algorithm::Fnv1a64, Algorithm::Crc32, Algorithm::Sha256] {
+            let whole = digest(algorithm, &data);
+            for split in [0usize, 1, 7, 63, 64, 65, 200, 300] {
+                let mut h = StreamHasher::new(algorithm);
+                h.write(&data[..split]);
+                h.write(&data[split..]);
+                assert_eq!(h.sum(), whole, "{} split at {}", algorithm, split);
+            }
+            let mut piecewise = StreamHasher::new(algorithm);
+            for chunk in data.chunks(13) {
+                piecewise.write(chunk);
+            }
+            assert_eq!(piecewise.sum(), whole, "{} in 13-byte chunks", algorithm);
+        }
+    }
+
+    #[test]
+    fn state_round_trips_mid_stream() {
+        let data = b"state survives a round trip through bytes, mid-block and all";
+        for algorithm in [Algorithm::Fnv1a64, Algorithm::Crc32, Algorithm::Sha256] {
+            let mut h = StreamHasher::new(algorithm);
+            h.write(&data[..20]);
+            let mut restored = StreamHasher::from_state(algorithm, &h.state()).unwrap();
+            restored.write(&data[20..]);
+            assert_eq!(restored.sum(), digest(algorithm, data));
+            assert_eq!(restored.sum().len(), algorithm.digest_len());
+        }
+    }
+
+    #[test]
+    fn from_state_rejects_malformed_input() {
+        assert!(StreamHasher::from_state(Algorithm::Fnv1a64, &[1, 2, 3]).is_none());
+        assert!(StreamHasher::from_state(Algorithm::Crc32, &[]).is_none());
+        assert!(StreamHasher::from_state(Algorithm::Sha256, &[0; 39]).is_none());
+        // Pending length must agree with the recorded total length.
+        let mut bad = StreamHasher::new(Algorithm::Sha256).state();
+        bad.push(0xaa);
+        assert!(StreamHasher::from_state(Algorithm::Sha256, &bad).is_none());
+    }
+
+    #[test]
+    fn algorithm_names_round_trip() {
+        for algorithm in [Algorithm::Fnv1a64, Algorithm::Crc32, Algorithm::Sha256] {
+            assert_eq!(Algorithm::from_name(algorithm.name()), Some(algorithm));
+        }
+        assert_eq!(Algorithm::from_name("md5"), None);
+    }
+}
@@ -8,17 +8,21 @@ pub mod cache;
 pub mod channel;
 pub mod checkpoint;
 pub mod crypto;
+pub mod csv;
 pub mod debugger;
 pub mod durability;
 pub mod effect_budget;
+pub mod encoding;
 pub mod error_context;
 pub mod execution_graph;
 pub mod fs_async;
 pub mod graph;
+pub mod hash;
 pub mod http;
 pub mod idempotency;
 pub mod injection;
 pub mod json_ops;
+pub mod linalg;
 pub mod linear_collections;
 pub mod mailbox;
 pub mod mock_effects;
@@ -26,6 +30,7 @@ pub mod net;
 pub mod nursery;
 pub mod panic_boundary;
 pub mod parity_durability;
+pub mod path;
 pub mod process;
 pub mod reduction;
 pub mod replay;
@@ -33,9 +38,13 @@ pub mod retry;
 pub mod scheduler;
 pub mod schema_drift;
 pub mod select;
+pub mod signal;
 pub mod snapshot;
+pub mod subprocess;
 pub mod supervisor;
 pub mod sync_scheduler;
+pub mod task_local;
 pub mod tools;
 pub mod trace;
+pub mod unicode;
 pub mod versioning;
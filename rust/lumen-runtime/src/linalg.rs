@@ -0,0 +1,252 @@
+//! Dense linear algebra for the Lumen runtime (`std.linalg`).
+//!
+//! A [`Matrix`] keeps its elements row-major in one buffer, so a program's
+//! list of row lists is copied once on the way in and once on the way out,
+//! and the inner loops run over contiguous memory.
+//!
+//! [`matmul`] walks each row of the left operand against whole rows of
+//! the right one (the i-k-j loop order). Every element of the product is
+//! still summed over `k` in increasing order, so results are bit for bit
+//! those of the textbook triple loop, only without its strided reads.
+//!
+//! # Examples
+//!
+//! ```rust
+//! use lumen_runtime::linalg::{dot, matmul, Matrix};
+//!
+//! let a = Matrix::from_rows(&[vec![1.0, 2.0], vec![3.0, 4.0]]).unwrap();
+//! let b = Matrix::from_rows(&[vec![5.0, 6.0], vec![7.0, 8.0]]).unwrap();
+//! assert_eq!(matmul(&a, &b).unwrap().to_rows(), vec![vec![19.0, 22.0], vec![43.0, 50.0]]);
+//! assert_eq!(dot(&[1.0, 2.0, 3.0], &[4.0, 5.0, 6.0]), Ok(32.0));
+//! ```
+
+use std::fmt;
+
+/// Why operands could not be combined.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub enum ShapeError {
+    /// A row whose length differs from the first row's.
+    Ragged {
+        row: usize,
+        len: usize,
+        expected: usize,
+    },
+    /// Matrices whose inner dimensions differ, as (rows, cols) pairs.
+    Multiply {
+        left: (usize, usize),
+        right: (usize, usize),
+    },
+    /// Vectors of different lengths.
+    Dot { left: usize, right: usize },
+}
+
+impl fmt::Display for ShapeError {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        match self {
+            ShapeError::Ragged { row, len, expected } => write!(
+                f,
+                "row {} has {} elements, but row 0 has {}",
+                row, len, expected
+            ),
+            ShapeError::Multiply { left, right } => write!(
+                f,
+                "cannot multiply a {}x{} matrix by a {}x{} matrix",
+                left.0, left.1, right.0, right.1
+            ),
+            ShapeError::Dot { left, right } => {
+                write!(f, "vectors have different lengths ({} and {})", left, right)
+            }
+        }
+    }
+}
+
+impl std::error::Error for ShapeError {}
+
+/// A dense `rows` x `cols` matrix of `f64`.
+#[derive(Debug, Clone, PartialEq)]
+pub struct Matrix {
+    rows: usize,
+    cols: usize,
+    data: Vec<f64>,
+}
+
+impl Matrix {
+    /// A matrix of zeros.
+    pub fn zeros(rows: usize, cols: usize) -> Self {
+        Matrix {
+            rows,
+            cols,
+            data: vec![0.0; rows * cols],
+        }
+    }
+
+    /// A matrix from its rows, which must all be the same length. No rows
+    /// is the 0x0 matrix.
+    pub fn from_rows(rows: &[Vec<f64>]) -> Result<Self, ShapeError> {
+        let cols = rows.first().map_or(0, Vec::len);
+        let mut data = Vec::with_capacity(rows.len() * cols);
+        for (row, values) in rows.iter().enumerate() {
+            if values.len() != cols {
+                return Err(ShapeError::Ragged {
+                    row,
+                    len: values.len(),
+                    expected: cols,
+                });
+            }
+            data.extend_from_slice(values);
+        }
+        Ok(Matrix {
+            rows: rows.len(),
+            cols,
+            data,
+        })
+    }
+
+    /// The matrix as a list of rows.
+    pub fn to_rows(&self) -> Vec<Vec<f64>> {
+        (0..self.rows).map(|i| self.row(i).to_vec()).collect()
+    }
+
+    pub fn rows(&self) -> usize {
+        self.rows
+    }
+
+    pub fn cols(&self) -> usize {
+        self.cols
+    }
+
+    /// Row `i` as a slice.
+    pub fn row(&self, i: usize) -> &[f64] {
+        &self.data[i * self.cols..(i + 1) * self.cols]
+    }
+
+    fn row_mut(&mut self, i: usize) -> &mut [f64] {
+        &mut self.data[i * self.cols..(i + 1) * self.cols]
+    }
+}
+
+/// The product `a * b`, defined when `a` has as many columns as `b` has
+/// rows.
+pub fn matmul(a: &Matrix, b: &Matrix) -> Result<Matrix, ShapeError> {
+    if a.cols != b.rows {
+        return Err(ShapeError::Multiply {
+            left: (a.rows, a.cols),
+            right: (b.rows, b.cols),
+        });
+    }
+    let mut c = Matrix::zeros(a.rows, b.cols);
+    for i in 0..a.rows {
+        let out = c.row_mut(i);
+        for (k, &scale) in a.row(i).iter().enumerate() {
+            for (sum, &x) in out.iter_mut().zip(b.row(k)) {
+                *sum += scale * x;
+            }
+        }
+    }
+    Ok(c)
+}
+
+/// `m` with rows and columns swapped.
+pub fn transpose(m: &Matrix) -> Matrix {
+    let mut t = Matrix::zeros(m.cols, m.rows);
+    for i in 0..m.rows {
+        for (j, &x) in m.row(i).iter().enumerate() {
+            t.data[j * m.rows + i] = x;
+        }
+    }
+    t
+}
+
+/// The sum of the products of corresponding elements, in order.
+pub fn dot(a: &[f64], b: &[f64]) -> Result<f64, ShapeError> {
+    if a.len() != b.len() {
+        return Err(ShapeError::Dot {
+            left: a.len(),
+            right: b.len(),
+        });
+    }
+    Ok(a.iter().zip(b).fold(0.0, |sum, (x, y)| sum + x * y))
+}
+
+// ---------------------------------------------------------------------------
+// Tests
+// ---------------------------------------------------------------------------
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn m(rows: &[&[f64]]) -> Matrix {
+        let rows: Vec<Vec<f64>> = rows.iter().map(|r| r.to_vec()).collect();
+        Matrix::from_rows(&rows).unwrap()
+    }
+
+    #[test]
+    fn matmul_of_non_square_matrices() {
+        let a = m(&[&[1.0, 2.0, 3.0], &[4.0, 5.0, 6.0]]);
+        let b = m(&[&[7.0, 8.0], &[9.0, 10.0], &[11.0, 12.0]]);
+        assert_eq!(
+            matmul(&a, &b).unwrap(),
+            m(&[&[58.0, 64.0], &[139.0, 154.0]])
+        );
+        assert_eq!(
+            matmul(&b, &a).unwrap(),
+            m(&[
+                &[39.0, 54.0, 69.0],
+                &[49.0, 68.0, 87.0],
+                &[59.0, 82.0, 105.0]
+            ])
+        );
+    }
+
+    #[test]
+    fn matmul_sums_in_the_textbook_order() {
+        // Summing k in another order would round differently here
+        let a = m(&[&[1e16, 1.0, -1e16]]);
+        let b = m(&[&[1.0], &[1.0], &[1.0]]);
+        let naive = (1e16 * 1.0 + 1.0 * 1.0) + -1e16 * 1.0;
+        assert_eq!(matmul(&a, &b).unwrap().row(0), &[naive]);
+    }
+
+    #[test]
+    fn matmul_rejects_mismatched_inner_dimensions() {
+        let a = Matrix::zeros(2, 3);
+        let err = matmul(&a, &a).unwrap_err();
+        assert_eq!(
+            err,
+            ShapeError::Multiply {
+                left: (2, 3),
+                right: (2, 3)
+            }
+        );
+        assert_eq!(
+            err.to_string(),
+            "cannot multiply a 2x3 matrix by a 2x3 matrix"
+        );
+    }
+
+    #[test]
+    fn transpose_swaps_rows_and_columns() {
+        let a = m(&[&[1.0, 2.0, 3.0], &[4.0, 5.0, 6.0]]);
+        assert_eq!(transpose(&a), m(&[&[1.0, 4.0], &[2.0, 5.0], &[3.0, 6.0]]));
+        assert_eq!(transpose(&transpose(&a)), a);
+        assert_eq!(transpose(&Matrix::zeros(0, 0)), Matrix::zeros(0, 0));
+    }
+
+    #[test]
+    fn ragged_rows_and_unequal_vectors_are_errors() {
+        assert_eq!(
+            Matrix::from_rows(&[vec![1.0, 2.0], vec![3.0]]),
+            Err(ShapeError::Ragged {
+                row: 1,
+                len: 1,
+                expected: 2
+            })
+        );
+        assert_eq!(
+            dot(&[1.0], &[1.0, 2.0]),
+            Err(ShapeError::Dot { left: 1, right: 2 })
+        );
+        assert_eq!(dot(&[], &[]), Ok(0.0));
+    }
+}
@@ -0,0 +1,339 @@
+//! Lexical path manipulation for the Lumen runtime (`std.path`).
+//!
+//! Paths are plain strings and nothing here touches the filesystem, so the
+//! results are the same whether or not a path exists. The functions follow
+//! Go's `path/filepath`: [`clean`] removes `.` segments, `..` segments that
+//! follow a name, and repeated separators, and [`join`] cleans what it
+//! joins.
+//!
+//! Each function takes the [`Style`] whose rules apply. Unix paths use
+//! `/`. Windows paths accept both `\` and `/`, produce `\`, and may start
+//! with a volume: a drive such as `C:` or a UNC share such as
+//! `\\server\share`.
+//!
+//! # Examples
+//!
+//! ```rust
+//! use lumen_runtime::path::{clean, ext, join, Style};
+//!
+//! assert_eq!(join(&["src", "compiler", "..", "main.lm"], Style::Unix), "src/main.lm");
+//! assert_eq!(clean("C:/a//b/./c", Style::Windows), r"C:\a\b\c");
+//! assert_eq!(ext("archive.tar.gz", Style::Unix), ".gz");
+//! ```
+
+/// Which platform's path rules apply.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum Style {
+    Unix,
+    Windows,
+}
+
+impl Style {
+    /// The style of the platform this runtime was built for.
+    pub fn native() -> Style {
+        if cfg!(windows) {
+            Style::Windows
+        } else {
+            Style::Unix
+        }
+    }
+
+    /// The separator this style writes.
+    pub fn separator(self) -> char {
+        match self {
+            Style::Unix => '/',
+            Style::Windows => '\\',
+        }
+    }
+
+    /// Whether this style reads `c` as a separator.
+    pub fn is_separator(self, c: char) -> bool {
+        match self {
+            Style::Unix => c == '/',
+            Style::Windows => c == '\\' || c == '/',
+        }
+    }
+}
+
+/// Length of the volume at the start of `path`: `C:` or `\\server\share`
+/// on Windows, and always 0 on Unix.
+fn volume_len(path: &str, style: Style) -> usize {
+    if style == Style::Unix {
+        return 0;
+    }
+    let bytes = path.as_bytes();
+    if bytes.len() >= 2 && bytes[0].is_ascii_alphabetic() && bytes[1] == b':' {
+        return 2;
+    }
+    let sep = |i: usize| bytes.get(i).is_some_and(|&b| style.is_separator(b as char));
+    // UNC: two separators, then a server and a share name
+    if sep(0) && sep(1) && bytes.len() > 2 && !sep(2) {
+        let mut i = 3;
+        while i < bytes.len() && !sep(i) {
+            i += 1;
+        }
+        if i + 1 < bytes.len() && !sep(i + 1) {
+            i += 1;
+            while i < bytes.len() && !sep(i) {
+                i += 1;
+            }
+            return i;
+        }
+    }
+    0
+}
+
+/// The volume at the start of `path`, with its separators in `style`.
+fn volume(path: &str, style: Style) -> String {
+    path[..volume_len(path, style)]
+        .chars()
+        .map(|c| {
+            if style.is_separator(c) {
+                style.separator()
+            } else {
+                c
+            }
+        })
+        .collect()
+}
+
+/// The shortest path naming the same file as `path`, by lexical rules
+/// alone.
+///
+/// Repeated separators become one, `.` segments are removed, and each `..`
+/// removes the segment before it. A `..` at the start of a relative path
+/// is kept, and one right after the root is dropped. The result ends in a
+/// separator only when it is the root, and is `.` when nothing is left.
+pub fn clean(path: &str, style: Style) -> String {
+    let vol = volume(path, style);
+    let rest = &path[volume_len(path, style)..];
+    if rest.is_empty() && vol.len() > 2 {
+        // A UNC share on its own
+        return vol;
+    }
+    let rooted = rest.chars().next().is_some_and(|c| style.is_separator(c));
+
+    let mut segments: Vec<&str> = Vec::new();
+    for segment in rest.split(|c| style.is_separator(c)) {
+        match segment {
+            "" | "." => {}
+            ".." => match segments.last() {
+                Some(&last) if last != ".." => {
+                    segments.pop();
+                }
+                _ if rooted => {}
+                _ => segments.push(".."),
+            },
+            name => segments.push(name),
+        }
+    }
+
+    let sep = style.separator().to_string();
+    let mut out = vol;
+    if rooted {
+        out.push_str(&sep);
+    }
+    out.push_str(&segments.join(&sep));
+    if !rooted && segments.is_empty() {
+        out.push('.');
+    }
+    out
+}
+
+/// `parts` joined by the separator and cleaned. Empty parts are skipped,
+/// and the result is empty when every part is.
+pub fn join(parts: &[&str], style: Style) -> String {
+    let parts: Vec<&str> = parts.iter().copied().filter(|p| !p.is_empty()).collect();
+    if parts.is_empty() {
+        return String::new();
+    }
+    clean(&parts.join(&style.separator().to_string()), style)
+}
+
+/// The last segment of `path`, ignoring trailing separators: `.` for an
+/// empty path and the separator for the root.
+pub fn base(path: &str, style: Style) -> String {
+    if path.is_empty() {
+        return ".".to_string();
+    }
+    let rest = &path[volume_len(path, style)..];
+    let trimmed = rest.trim_end_matches(|c| style.is_separator(c));
+    if trimmed.is_empty() {
+        return if rest.is_empty() {
+            ".".to_string()
+        } else {
+            style.separator().to_string()
+        };
+    }
+    match trimmed.rfind(|c| style.is_separator(c)) {
+        Some(i) => trimmed[i + 1..].to_string(),
+        None => trimmed.to_string(),
+    }
+}
+
+/// Everything in `path` before its last segment, cleaned: `.` when there is
+/// no directory part.
+pub fn dir(path: &str, style: Style) -> String {
+    let vol_len = volume_len(path, style);
+    let rest = &path[vol_len..];
+    let head = match rest.rfind(|c| style.is_separator(c)) {
+        Some(i) => &rest[..=i],
+        None => "",
+    };
+    let dir = clean(head, style);
+    if dir == "." && vol_len > 2 {
+        // A UNC share is its own directory
+        return volume(path, style);
+    }
+    format!("{}{}", volume(path, style), dir)
+}
+
+/// The extension of the last segment of `path`, from its last `.`; empty
+/// when that segment has no `.`.
+pub fn ext(path: &str, style: Style) -> String {
+    for (i, c) in path.char_indices().rev() {
+        if style.is_separator(c) {
+            break;
+        }
+        if c == '.' {
+            return path[i..].to_string();
+        }
+    }
+    String::new()
+}
+
+/// Whether `path` starts at a root rather than the current directory. A
+/// Windows path is absolute only with a volume, as in `C:\` or a UNC
+/// share, so `\a` is relative to the current drive.
+pub fn is_abs(path: &str, style: Style) -> bool {
+    match style {
+        Style::Unix => path.starts_with('/'),
+        Style::Windows => {
+            let vol_len = volume_len(path, style);
+            vol_len > 2
+                || (vol_len == 2
+                    && path[2..]
+                        .chars()
+                        .next()
+                        .is_some_and(|c| style.is_separator(c)))
+        }
+    }
+}
+
+// ---------------------------------------------------------------------------
+// Tests
+// ---------------------------------------------------------------------------
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn join_skips_empty_parts_and_cleans() {
+        let unix = Style::Unix;
+        assert_eq!(join(&["a", "b", "c"], unix), "a/b/c");
+        assert_eq!(join(&["a", "", "b"], unix), "a/b");
+        assert_eq!(join(&["/", "usr", "lib/"], unix), "/usr/lib");
+        assert_eq!(join(&["a/", "/b"], unix), "a/b");
+        assert_eq!(join(&["a", "../../b"], unix), "../b");
+        assert_eq!(join(&["", ""], unix), "");
+        assert_eq!(join(&[], unix), "");
+        assert_eq!(
+            join(&["C:", "lumen", "std"], Style::Windows),
+            r"C:\lumen\std"
+        );
+    }
+
+    #[test]
+    fn clean_resolves_dot_dot_segments() {
+        let cases = [
+            ("", "."),
+            (".", "."),
+            ("a/./b", "a/b"),
+            ("a//b///c/", "a/b/c"),
+            ("a/b/../c", "a/c"),
+            ("a/b/../../..", ".."),
+            ("../../a", "../../a"),
+            ("a/../../b/./c/..", "../b"),
+            ("/../a", "/a"),
+            ("/a/b/../../..", "/"),
+            ("/", "/"),
+            ("//", "/"),
+        ];
+        for (path, want) in cases {
+            assert_eq!(clean(path, Style::Unix), want, "clean({:?})", path);
+        }
+    }
+
+    #[test]
+    fn ext_is_taken_from_the_last_segment() {
+        let unix = Style::Unix;
+        assert_eq!(ext("main.lm", unix), ".lm");
+        assert_eq!(ext("docs/guide.lm.md", unix), ".md");
+        assert_eq!(ext("/tmp/archive.tar.gz", unix), ".gz");
+        assert_eq!(ext("Makefile", unix), "");
+        assert_eq!(ext("v1.2/README", unix), "");
+        assert_eq!(ext("trailing.", unix), ".");
+        assert_eq!(ext(r"v1.2\README", Style::Windows), "");
+        assert_eq!(ext(r"v1.2\README", unix), r".2\README");
+    }
+
+    #[test]
+    fn base_and_dir_split_the_last_segment() {
+        let unix = Style::Unix;
+        assert_eq!(base("/a/b/c.lm", unix), "c.lm");
+        assert_eq!(base("a/b/", unix), "b");
+        assert_eq!(base("/", unix), "/");
+        assert_eq!(base("", unix), ".");
+        assert_eq!(dir("/a/b/c.lm", unix), "/a/b");
+        assert_eq!(dir("a/b/../c/d", unix), "a/c");
+        assert_eq!(dir("c.lm", unix), ".");
+        assert_eq!(dir("/c.lm", unix), "/");
+    }
+
+    #[test]
+    fn windows_accepts_both_separators_and_writes_backslashes() {
+        let win = Style::Windows;
+        assert_eq!(clean("a/b\\c/../d", win), r"a\b\d");
+        assert_eq!(clean(r"C:\a\..\..", win), r"C:\");
+        assert_eq!(clean("C:", win), "C:.");
+        assert_eq!(clean("c:a/b", win), r"c:a\b");
+        assert_eq!(clean(r"\\server\share\a\..\b", win), r"\\server\share\b");
+        assert_eq!(clean("//server/share", win), r"\\server\share");
+        assert_eq!(base(r"C:\lumen\main.lm", win), "main.lm");
+        assert_eq!(base(r"C:\", win), r"\");
+        assert_eq!(dir(r"C:\lumen\main.lm", win), r"C:\lumen");
+        assert_eq!(dir(r"\\server\share\main.lm", win), r"\\server\share\");
+        assert_eq!(dir(r"\\server\share", win), r"\\server\share");
+    }
+
+    #[test]
+    fn unix_treats_backslash_as_an_ordinary_character() {
+        let unix = Style::Unix;
+        assert_eq!(clean(r"a\b/../c", unix), "c");
+        assert_eq!(base(r"dir\file", unix), r"dir\file");
+        assert_eq!(volume_len("C:/x", unix), 0);
+        assert_eq!(clean("C:/x/..", unix), "C:");
+    }
+
+    #[test]
+    fn absolute_paths_need_a_root() {
+        assert!(is_abs("/usr", Style::Unix));
+        assert!(!is_abs("usr/lib", Style::Unix));
+        assert!(!is_abs(r"C:\x", Style::Unix));
+        assert!(is_abs(r"C:\x", Style::Windows));
+        assert!(is_abs("C:/x", Style::Windows));
+        assert!(is_abs(r"\\server\share\x", Style::Windows));
+        assert!(!is_abs("C:x", Style::Windows));
+        assert!(!is_abs(r"\x", Style::Windows));
+    }
+
+    #[test]
+    fn native_style_matches_the_platform() {
+        assert_eq!(
+            Style::native().separator(),
+            std::path::MAIN_SEPARATOR,
+            "native separator"
+        );
+    }
+}
@@ -13,11 +13,19 @@
 //! 3. Steal from a random peer worker's [`Stealer`].
 //! 4. Park briefly (1 ms) to avoid busy-spinning, then retry.
 //!
+//! A task spawned from inside another task — through a [`Spawner`], or the
+//! [`Scheduler`] itself — goes to the local deque of the worker running it,
+//! where it is cheapest to pop and where idle peers steal it from. A local
+//! deque holds at most [`LOCAL_QUEUE_CAPACITY`] tasks; past that, spawns
+//! overflow to the global queue. Tasks spawned from any other thread go to
+//! the global queue.
+//!
 //! Task completion is tracked via a shared [`AtomicUsize`] counter so callers
 //! can wait for a known number of tasks to finish.
 
 use crate::process::{ProcessControlBlock, ProcessId, ProcessStatus};
 use crossbeam_deque::{Injector, Steal, Stealer, Worker};
+use std::cell::RefCell;
 use std::collections::HashMap;
 use std::fmt;
 use std::sync::atomic::{AtomicBool, AtomicUsize, Ordering};
@@ -34,6 +42,38 @@ fn lock_inner<T>(mutex: &Mutex<T>) -> Result<MutexGuard<'_, T>, String> {
     mutex.lock().map_err(|e| format!("Mutex poisoned: {}", e))
 }
 
+// ---------------------------------------------------------------------------
+// Local queues
+// ---------------------------------------------------------------------------
+
+/// The most tasks a worker's local deque holds before spawns from its tasks
+/// overflow to the global queue.
+pub const LOCAL_QUEUE_CAPACITY: usize = 256;
+
+/// Source of [`Scheduler`] ids, which tell apart the local deques of
+/// different schedulers' workers.
+static NEXT_SCHEDULER_ID: AtomicUsize = AtomicUsize::new(1);
+
+thread_local! {
+    /// On a worker thread, the id of its scheduler and its local deque.
+    static LOCAL_QUEUE: RefCell<Option<(usize, Worker<Task>)>> = const { RefCell::new(None) };
+}
+
+/// Push `task` to the calling worker's local deque when that worker belongs
+/// to scheduler `id` and has room, and to `global` otherwise.
+fn push_task(id: usize, global: &Injector<Task>, task: Task) {
+    let overflow = LOCAL_QUEUE.with(|slot| match slot.borrow().as_ref() {
+        Some((owner, local)) if *owner == id && local.len() < LOCAL_QUEUE_CAPACITY => {
+            local.push(task);
+            None
+        }
+        _ => Some(task),
+    });
+    if let Some(task) = overflow {
+        global.push(task);
+    }
+}
+
 // ---------------------------------------------------------------------------
 // Task
 // ---------------------------------------------------------------------------
@@ -63,13 +103,14 @@ impl Task {
         }
     }
 
-    /// Execute the task's work closure, consuming it.
+    /// Execute the task's work closure, consuming it. The closure gets its
+    /// own [task-local](crate::task_local) values, dropped when it returns.
     ///
     /// Returns `true` if the closure was present and executed, `false` if the
     /// task had already been consumed.
     pub fn run(&mut self) -> bool {
         if let Some(f) = self.work.take() {
-            f();
+            crate::task_local::scope(f);
             true
         } else {
             false
@@ -126,6 +167,36 @@ pub struct Scheduler {
     completed_count: Arc<AtomicUsize>,
     /// Registry of spawned process control blocks, keyed by [`ProcessId`].
     process_registry: Arc<Mutex<HashMap<ProcessId, Arc<ProcessControlBlock>>>>,
+    /// Identifies this scheduler's workers' local deques.
+    id: usize,
+}
+
+/// A cloneable handle for spawning onto a [`Scheduler`], which tasks can
+/// capture to spawn more tasks.
+#[derive(Clone)]
+pub struct Spawner {
+    id: usize,
+    global_queue: Arc<Injector<Task>>,
+}
+
+impl Spawner {
+    /// Spawn a task: onto the local deque when called from one of the
+    /// scheduler's workers, and onto the global queue otherwise.
+    pub fn spawn(&self, task: Task) {
+        push_task(self.id, &self.global_queue, task);
+    }
+
+    /// Convenience: wrap a closure in a [`Task`] with a fresh [`ProcessId`]
+    /// and spawn it.
+    pub fn spawn_fn<F: FnOnce() + Send + 'static>(&self, f: F) {
+        self.spawn(Task::new(ProcessId::next(), f));
+    }
+}
+
+impl fmt::Debug for Spawner {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        f.debug_struct("Spawner").field("id", &self.id).finish()
+    }
 }
 
 impl Scheduler {
@@ -139,6 +210,7 @@ impl Scheduler {
             num_workers
         };
 
+        let id = NEXT_SCHEDULER_ID.fetch_add(1, Ordering::Relaxed);
         let global_queue = Arc::new(Injector::<Task>::new());
         let shutdown = Arc::new(AtomicBool::new(false));
         let completed_count = Arc::new(AtomicUsize::new(0));
@@ -168,7 +240,10 @@ impl Scheduler {
             let jh = thread::Builder::new()
                 .name(format!("lumen-worker-{}", idx))
                 .spawn(move || {
-                    Self::worker_loop(idx, local, global, peer_stealers, shutdown_flag, completed);
+                    LOCAL_QUEUE.with(|slot| *slot.borrow_mut() = Some((id, local)));
+                    Self::worker_loop(idx, global, peer_stealers, shutdown_flag, completed);
+                    // Tasks still queued locally are abandoned, as on shutdown.
+                    LOCAL_QUEUE.with(|slot| slot.borrow_mut().take());
                 })
                 .expect("failed to spawn worker thread");
 
@@ -185,6 +260,15 @@ impl Scheduler {
             worker_count: num_workers,
             completed_count,
             process_registry: Arc::new(Mutex::new(HashMap::new())),
+            id,
+        }
+    }
+
+    /// A handle that spawns onto this scheduler, for tasks to capture.
+    pub fn spawner(&self) -> Spawner {
+        Spawner {
+            id: self.id,
+            global_queue: Arc::clone(&self.global_queue),
         }
     }
 
@@ -198,27 +282,29 @@ impl Scheduler {
         self.completed_count.load(Ordering::Acquire)
     }
 
-    /// Spawn a new task onto the global injection queue.
+    /// Spawn a new task onto the global injection queue, or onto the local
+    /// deque when called from one of this scheduler's tasks.
     pub fn spawn(&self, task: Task) {
-        self.global_queue.push(task);
+        push_task(self.id, &self.global_queue, task);
     }
 
     /// Convenience: wrap a closure in a [`Task`] with a fresh [`ProcessId`]
-    /// and push it to the global queue.
+    /// and spawn it as [`spawn`](Self::spawn) does.
     ///
     /// This is useful when you don't need to correlate the task back to an
     /// existing process control block.
     pub fn spawn_fn<F: FnOnce() + Send + 'static>(&self, f: F) {
         let pid = ProcessId::next();
-        self.global_queue.push(Task::new(pid, f));
+        self.spawn(Task::new(pid, f));
     }
 
     /// Spawn a new process with a [`ProcessControlBlock`].
     ///
     /// Creates a PCB with the given priority and optional name, wraps the
     /// closure so that the process status is updated on completion, registers
-    /// the PCB in the process registry, and pushes the task to the global
-    /// injection queue. Returns the [`ProcessId`] of the new process.
+    /// the PCB in the process registry, and spawns the task as
+    /// [`spawn`](Self::spawn) does. Returns the [`ProcessId`] of the new
+    /// process.
     pub fn spawn_process<F>(&self, priority: u8, name: Option<String>, work: F) -> ProcessId
     where
         F: FnOnce() + Send + 'static,
@@ -240,7 +326,7 @@ impl Scheduler {
             let _ = pcb_inner.set_status(ProcessStatus::Completed);
         });
 
-        self.global_queue.push(task);
+        self.spawn(task);
         pid
     }
 
@@ -310,7 +396,8 @@ impl Scheduler {
         x
     }
 
-    /// The main loop executed by each worker thread.
+    /// The main loop executed by each worker thread, whose local deque is
+    /// in [`LOCAL_QUEUE`].
     ///
     /// Priority order:
     /// 1. Pop from the local deque (cheapest).
@@ -319,7 +406,6 @@ impl Scheduler {
     /// 4. Park briefly (1 ms) to avoid busy-spinning.
     fn worker_loop(
         idx: usize,
-        local: Worker<Task>,
         global: Arc<Injector<Task>>,
         stealers: Arc<Vec<Stealer<Task>>>,
         shutdown: Arc<AtomicBool>,
@@ -333,63 +419,65 @@ impl Scheduler {
                 return;
             }
 
-            // 1. Try local deque.
-            if let Some(mut task) = local.pop() {
-                task.run();
-                completed.fetch_add(1, Ordering::Release);
-                continue;
-            }
-
-            // 2. Try global queue (steal a batch into local).
-            match global.steal_batch_and_pop(&local) {
-                Steal::Success(mut task) => {
+            // The deque is borrowed only while looking: a running task may
+            // spawn onto it.
+            let found = LOCAL_QUEUE.with(|slot| {
+                let slot = slot.borrow();
+                let (_, local) = slot.as_ref()?;
+                Self::find_task(idx, local, &global, &stealers, &mut rng_state)
+            });
+            match found {
+                Some(Steal::Success(mut task)) => {
                     task.run();
                     completed.fetch_add(1, Ordering::Release);
-                    continue;
-                }
-                Steal::Retry => {
-                    // Contention — try again next iteration.
-                    thread::yield_now();
-                    continue;
                 }
-                Steal::Empty => {}
+                // Contention — try again next iteration.
+                Some(Steal::Retry) => thread::yield_now(),
+                // 4. Nothing to do — brief sleep to avoid busy-spinning.
+                //    A production scheduler would use a condition variable /
+                //    eventfd here, but this is adequate for the current phase.
+                Some(Steal::Empty) | None => thread::park_timeout(Duration::from_millis(1)),
             }
+        }
+    }
 
-            // 3. Try stealing from a random peer.
-            let num_peers = stealers.len();
-            if num_peers > 0 {
-                let start = Self::xorshift32(&mut rng_state) as usize % num_peers;
-                let mut stolen = false;
-                for offset in 0..num_peers {
-                    let peer_idx = (start + offset) % num_peers;
-                    // Skip our own stealer — stealing from ourselves is a no-op
-                    // on FIFO deques (and the Worker handle is on this thread).
-                    if peer_idx == idx {
-                        continue;
-                    }
-                    match stealers[peer_idx].steal_batch_and_pop(&local) {
-                        Steal::Success(mut task) => {
-                            task.run();
-                            completed.fetch_add(1, Ordering::Release);
-                            stolen = true;
-                            break;
-                        }
-                        Steal::Retry => {
-                            // Will try other peers or next loop iteration.
-                        }
-                        Steal::Empty => {}
-                    }
-                }
-                if stolen {
+    /// The next task for worker `idx`, from steps 1–3 of the loop.
+    fn find_task(
+        idx: usize,
+        local: &Worker<Task>,
+        global: &Injector<Task>,
+        stealers: &[Stealer<Task>],
+        rng_state: &mut u32,
+    ) -> Option<Steal<Task>> {
+        // 1. Try local deque.
+        if let Some(task) = local.pop() {
+            return Some(Steal::Success(task));
+        }
+
+        // 2. Try global queue (steal a batch into local).
+        match global.steal_batch_and_pop(local) {
+            Steal::Empty => {}
+            other => return Some(other),
+        }
+
+        // 3. Try stealing from a random peer.
+        let num_peers = stealers.len();
+        if num_peers > 0 {
+            let start = Self::xorshift32(rng_state) as usize % num_peers;
+            for offset in 0..num_peers {
+                let peer_idx = (start + offset) % num_peers;
+                // Skip our own stealer — stealing from ourselves is a no-op
+                // on FIFO deques (and the Worker handle is on this thread).
+                if peer_idx == idx {
                     continue;
                 }
+                // On Retry, will try other peers or next loop iteration.
+                if let Steal::Success(task) = stealers[peer_idx].steal_batch_and_pop(local) {
+                    return Some(Steal::Success(task));
+                }
             }
-
-            // 4. Nothing to do — brief sleep to avoid busy-spinning.
-            //    A production scheduler would use a condition variable /
-            //    eventfd here, but this is adequate for the current phase.
-            thread::park_timeout(Duration::from_millis(1));
         }
+        Some(Steal::Empty)
     }
 }
 
@@ -699,6 +787,82 @@ mod tests {
         assert_eq!(counter.load(Ordering::SeqCst), n);
     }
 
+    #[test]
+    fn spawner_runs_tasks_spawned_from_tasks() {
+        let mut sched = Scheduler::new(4);
+        let counter = Arc::new(AtomicUsize::new(0));
+        let spawner = sched.spawner();
+        let parents = 16;
+        let children = 64;
+
+        for _ in 0..parents {
+            let spawner = spawner.clone();
+            let ctr = Arc::clone(&counter);
+            sched.spawn_fn(move || {
+                for _ in 0..children {
+                    let ctr = Arc::clone(&ctr);
+                    spawner.spawn_fn(move || {
+                        ctr.fetch_add(1, Ordering::Relaxed);
+                    });
+                }
+            });
+        }
+
+        let expected = parents + parents * children;
+        let completed = sched.wait_for_completion(expected, Duration::from_secs(10));
+        sched.shutdown();
+
+        assert_eq!(completed, expected);
+        assert_eq!(counter.load(Ordering::SeqCst), parents * children);
+    }
+
+    #[test]
+    fn local_queue_overflows_to_global() {
+        // One worker: the children past the local capacity can only run by
+        // way of the global queue.
+        let mut sched = Scheduler::new(1);
+        let counter = Arc::new(AtomicUsize::new(0));
+        let spawner = sched.spawner();
+        let children = LOCAL_QUEUE_CAPACITY * 4;
+
+        let ctr = Arc::clone(&counter);
+        sched.spawn_fn(move || {
+            for _ in 0..children {
+                let ctr = Arc::clone(&ctr);
+                spawner.spawn_fn(move || {
+                    ctr.fetch_add(1, Ordering::Relaxed);
+                });
+            }
+        });
+
+        let completed = sched.wait_for_completion(children + 1, Duration::from_secs(10));
+        sched.shutdown();
+
+        assert_eq!(completed, children + 1);
+        assert_eq!(counter.load(Ordering::SeqCst), children);
+    }
+
+    #[test]
+    fn spawn_from_another_schedulers_task_uses_its_global_queue() {
+        let mut outer = Scheduler::new(1);
+        let mut inner = Scheduler::new(1);
+        let counter = Arc::new(AtomicUsize::new(0));
+        let inner_spawner = inner.spawner();
+
+        let ctr = Arc::clone(&counter);
+        outer.spawn_fn(move || {
+            inner_spawner.spawn_fn(move || {
+                ctr.fetch_add(1, Ordering::Relaxed);
+            });
+        });
+
+        assert_eq!(outer.wait_for_completion(1, Duration::from_secs(5)), 1);
+        assert_eq!(inner.wait_for_completion(1, Duration::from_secs(5)), 1);
+        outer.shutdown();
+        inner.shutdown();
+        assert_eq!(counter.load(Ordering::SeqCst), 1);
+    }
+
     // -- T170: graceful error handling tests --------------------------------
 
     #[test]
@@ -10,6 +10,14 @@
 //! **randomly** (not always the first-registered channel). This is guaranteed
 //! by the underlying [`crossbeam_channel::Select`] primitive.
 //!
+//! # Timeouts
+//!
+//! A [`timeout`](Selector::timeout) or [`timeout_case`](Selector::timeout_case)
+//! gives up once the duration has passed since `select` was called, however
+//! many arms were lost to races in the meantime. A message that arrives
+//! first preempts it. With a timeout and no channels, `select` sleeps for
+//! the duration and takes the timeout branch.
+//!
 //! # Example
 //!
 //! ```ignore
@@ -33,11 +41,14 @@
 
 use crate::channel::Receiver;
 use crossbeam_channel::{self as cb};
-use std::time::Duration;
+use std::time::{Duration, Instant};
 
 /// Type alias for the boxed handler closures stored inside [`Selector`].
 type HandlerFn<'a> = Box<dyn FnOnce() -> Option<SelectResult> + 'a>;
 
+/// Handler for the default and timeout cases.
+type CaseFn<'a> = Box<dyn FnOnce() -> SelectResult + 'a>;
+
 // ---------------------------------------------------------------------------
 // Result type
 // ---------------------------------------------------------------------------
@@ -75,8 +86,12 @@ pub struct Selector<'a> {
     /// Optional deadline.
     timeout: Option<Duration>,
 
+    /// Optional handler run when the timeout elapses, in place of
+    /// returning [`SelectResult::Timeout`].
+    timeout_handler: Option<CaseFn<'a>>,
+
     /// Optional non-blocking default handler.
-    default_handler: Option<Box<dyn FnOnce() -> SelectResult + 'a>>,
+    default_handler: Option<CaseFn<'a>>,
 }
 
 /// Internal helper trait to erase `T` from `Receiver<T>` so we can store
@@ -104,6 +119,7 @@ impl<'a> Selector<'a> {
             receivers: Vec::new(),
             handlers: Vec::new(),
             timeout: None,
+            timeout_handler: None,
             default_handler: None,
         }
     }
@@ -142,6 +158,18 @@ impl<'a> Selector<'a> {
     /// is returned.
     pub fn timeout(mut self, duration: Duration) -> Self {
         self.timeout = Some(duration);
+        self.timeout_handler = None;
+        self
+    }
+
+    /// Set a timeout case: if no channel becomes ready within `duration`,
+    /// `handler` runs and its return value is the result.
+    pub fn timeout_case<F>(mut self, duration: Duration, handler: F) -> Self
+    where
+        F: FnOnce() -> SelectResult + 'a,
+    {
+        self.timeout = Some(duration);
+        self.timeout_handler = Some(Box::new(handler));
         self
     }
 
@@ -166,21 +194,33 @@ impl<'a> Selector<'a> {
     ///
     /// # Panics
     ///
-    /// Panics if no channels have been registered and no default handler is
-    /// set.
+    /// Panics if no channels have been registered and neither a default
+    /// handler nor a timeout is set.
     pub fn select(self) -> SelectResult {
         let Selector {
             receivers,
             handlers,
             timeout,
+            timeout_handler,
             default_handler,
         } = self;
+        // Fixed once, so retries after a lost race do not extend the wait.
+        let deadline = timeout.map(|d| Instant::now() + d);
+        let timed_out = move || match timeout_handler {
+            Some(th) => th(),
+            None => SelectResult::Timeout,
+        };
 
         if receivers.is_empty() {
-            // No channels registered — run default if present, otherwise panic.
+            // No channels registered — run default if present, then wait
+            // out the timeout, otherwise panic.
             if let Some(dh) = default_handler {
                 return dh();
             }
+            if let Some(d) = timeout {
+                std::thread::sleep(d);
+                return timed_out();
+            }
             panic!("Selector::select called with no channels and no default handler");
         }
 
@@ -198,7 +238,7 @@ impl<'a> Selector<'a> {
         let mut handlers: Vec<Option<HandlerFn<'a>>> = handlers.into_iter().map(Some).collect();
 
         // ---- Attempt loop --------------------------------------------------
-        // `ready()` / `try_ready()` / `ready_timeout()` tell us which index
+        // `ready()` / `try_ready()` / `ready_deadline()` tell us which index
         // is ready but do NOT consume the message. We then call `try_recv()`
         // via the handler closure. If `try_recv` fails (race), we remove
         // that arm and retry.
@@ -246,14 +286,14 @@ impl<'a> Selector<'a> {
                 return SelectResult::Closed;
             }
 
-            let ready_result = if let Some(dur) = timeout {
-                sel.ready_timeout(dur)
+            let ready_result = if let Some(at) = deadline {
+                sel.ready_deadline(at)
             } else {
                 Ok(sel.ready())
             };
 
             match ready_result {
-                Err(_) => return SelectResult::Timeout,
+                Err(_) => return timed_out(),
                 Ok(ready_idx) => {
                     // Map crossbeam index back to our handler position.
                     if let Some(&(pos, _)) = live.iter().find(|(_, ci)| *ci == ready_idx) {
@@ -385,6 +425,52 @@ mod tests {
         assert_eq!(result, SelectResult::Matched("7".into()));
     }
 
+    #[test]
+    fn select_timeout_case_runs_its_handler() {
+        let (_tx, rx) = channel::unbounded::<i32>();
+
+        let start = Instant::now();
+        let result = Selector::new()
+            .recv(&rx, |v| SelectResult::Matched(format!("{v}")))
+            .timeout_case(Duration::from_millis(30), || {
+                SelectResult::Matched("gave up".into())
+            })
+            .select();
+
+        assert_eq!(result, SelectResult::Matched("gave up".into()));
+        assert!(start.elapsed() >= Duration::from_millis(25));
+    }
+
+    #[test]
+    fn select_arriving_value_preempts_timeout_case() {
+        let (tx, rx) = channel::unbounded::<i32>();
+        let sender = thread::spawn(move || {
+            thread::sleep(Duration::from_millis(20));
+            tx.send(5).unwrap();
+        });
+
+        let start = Instant::now();
+        let result = Selector::new()
+            .recv(&rx, |v| SelectResult::Matched(format!("{v}")))
+            .timeout_case(Duration::from_secs(10), || {
+                SelectResult::Matched("gave up".into())
+            })
+            .select();
+
+        sender.join().unwrap();
+        assert_eq!(result, SelectResult::Matched("5".into()));
+        assert!(start.elapsed() < Duration::from_secs(5));
+    }
+
+    #[test]
+    fn select_timeout_with_no_channels_waits_then_times_out() {
+        let start = Instant::now();
+        let result = Selector::new().timeout(Duration::from_millis(20)).select();
+
+        assert_eq!(result, SelectResult::Timeout);
+        assert!(start.elapsed() >= Duration::from_millis(15));
+    }
+
     // -- default case (non-blocking) --------------------------------------
 
     #[test]
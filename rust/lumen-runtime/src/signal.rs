@@ -0,0 +1,136 @@
+//! Process signals for the Lumen runtime (`std.os.signal`).
+//!
+//! A signal is not acted on where it arrives. Whatever receives it, such as
+//! `lumen run`'s Ctrl-C handler or a test, records it in a
+//! [`PendingSignals`] set, and the VM takes it from there at its next
+//! safepoint. Only the two signals a program is asked to stop with are
+//! modelled; each has the exit code a shell reports for a process the
+//! signal killed, 128 plus its number.
+//!
+//! # Example
+//!
+//! ```rust
+//! use lumen_runtime::signal::{PendingSignals, Signal};
+//!
+//! let pending = PendingSignals::default();
+//! pending.raise(Signal::Terminate);
+//! pending.raise(Signal::Interrupt);
+//! assert_eq!(pending.take(), Some(Signal::Interrupt));
+//! assert_eq!(pending.take(), Some(Signal::Terminate));
+//! assert_eq!(pending.take(), None);
+//! assert_eq!(Signal::from_name("SIGTERM").map(Signal::exit_code), Some(143));
+//! ```
+
+use std::sync::atomic::{AtomicU32, Ordering};
+
+/// A signal a Lumen program can handle.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash, PartialOrd, Ord)]
+pub enum Signal {
+    /// `SIGINT`, sent by Ctrl-C.
+    Interrupt,
+    /// `SIGTERM`, the polite request to stop that `kill` sends by default.
+    Terminate,
+}
+
+impl Signal {
+    /// Every signal, in the order pending ones are delivered.
+    pub const ALL: [Signal; 2] = [Signal::Interrupt, Signal::Terminate];
+
+    /// The conventional name, such as `"SIGINT"`.
+    pub fn name(self) -> &'static str {
+        match self {
+            Signal::Interrupt => "SIGINT",
+            Signal::Terminate => "SIGTERM",
+        }
+    }
+
+    /// The POSIX signal number.
+    pub fn number(self) -> i32 {
+        match self {
+            Signal::Interrupt => 2,
+            Signal::Terminate => 15,
+        }
+    }
+
+    /// The exit code of a process this signal stopped.
+    pub fn exit_code(self) -> i32 {
+        128 + self.number()
+    }
+
+    /// The signal called `name`, with or without its `SIG` prefix.
+    pub fn from_name(name: &str) -> Option<Signal> {
+        let name = name.strip_prefix("SIG").unwrap_or(name);
+        match name {
+            "INT" => Some(Signal::Interrupt),
+            "TERM" => Some(Signal::Terminate),
+            _ => None,
+        }
+    }
+
+    fn bit(self) -> u32 {
+        1 << (self as u32)
+    }
+}
+
+/// Signals raised but not yet taken, shared between whatever raises them
+/// and the VM that delivers them. Raising one that is already pending has
+/// no further effect.
+#[derive(Debug, Default)]
+pub struct PendingSignals(AtomicU32);
+
+impl PendingSignals {
+    /// Mark `signal` as pending.
+    pub fn raise(&self, signal: Signal) {
+        self.0.fetch_or(signal.bit(), Ordering::Relaxed);
+    }
+
+    /// Whether `signal` is pending.
+    pub fn is_pending(&self, signal: Signal) -> bool {
+        self.0.load(Ordering::Relaxed) & signal.bit() != 0
+    }
+
+    /// Remove and return the first pending signal in [`Signal::ALL`] order.
+    pub fn take(&self) -> Option<Signal> {
+        let pending = self.0.load(Ordering::Relaxed);
+        if pending == 0 {
+            return None;
+        }
+        Signal::ALL.into_iter().find(|s| {
+            pending & s.bit() != 0 && self.0.fetch_and(!s.bit(), Ordering::Relaxed) & s.bit() != 0
+        })
+    }
+}
+
+// ---------------------------------------------------------------------------
+// Tests
+// ---------------------------------------------------------------------------
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn names_numbers_and_exit_codes() {
+        assert_eq!(Signal::Interrupt.name(), "SIGINT");
+        assert_eq!(Signal::Interrupt.exit_code(), 130);
+        assert_eq!(Signal::Terminate.number(), 15);
+        assert_eq!(Signal::Terminate.exit_code(), 143);
+        for signal in Signal::ALL {
+            assert_eq!(Signal::from_name(signal.name()), Some(signal));
+        }
+        assert_eq!(Signal::from_name("INT"), Some(Signal::Interrupt));
+        assert_eq!(Signal::from_name("SIGKILL"), None);
+        assert_eq!(Signal::from_name("sigint"), None);
+    }
+
+    #[test]
+    fn raising_twice_delivers_once() {
+        let pending = PendingSignals::default();
+        pending.raise(Signal::Interrupt);
+        pending.raise(Signal::Interrupt);
+        assert!(pending.is_pending(Signal::Interrupt));
+        assert!(!pending.is_pending(Signal::Terminate));
+        assert_eq!(pending.take(), Some(Signal::Interrupt));
+        assert_eq!(pending.take(), None);
+    }
+}
@@ -0,0 +1,220 @@
+//! Child processes for the Lumen runtime (`std.os.exec`).
+//!
+//! [`run`] starts a program directly, without a shell, so its arguments
+//! reach it exactly as given. Standard output and standard error are read
+//! on their own threads while the child runs, so a child that fills one
+//! pipe cannot block on it. With a timeout, a child still running at the
+//! deadline is killed and its output so far is returned; processes it
+//! started itself are not killed, but are no longer waited for.
+//!
+//! # Example
+//!
+//! ```rust,no_run
+//! use lumen_runtime::subprocess::run;
+//! use std::time::Duration;
+//!
+//! let out = run("echo", &["hello".to_string()], Some(Duration::from_secs(5))).unwrap();
+//! assert_eq!(out.stdout, "hello\n");
+//! assert_eq!(out.code, Some(0));
+//! ```
+
+use std::io::{self, Read};
+use std::process::{Child, Command, Stdio};
+use std::sync::{mpsc, Arc, Mutex};
+use std::thread;
+use std::time::{Duration, Instant};
+
+/// How often a child with a timeout is checked for having exited.
+const POLL_INTERVAL: Duration = Duration::from_millis(5);
+
+/// How long output is still read after a child with a timeout ends.
+const PIPE_GRACE: Duration = Duration::from_millis(100);
+
+/// What a finished or killed child wrote, and how it ended.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct Output {
+    pub stdout: String,
+    pub stderr: String,
+    /// The exit code; `None` when the child was ended by a signal,
+    /// including the kill at a timeout.
+    pub code: Option<i32>,
+    /// Whether the child was killed for running past the timeout.
+    pub timed_out: bool,
+}
+
+impl Output {
+    /// A one-line description of how the child failed, or `None` when it
+    /// exited with code 0.
+    pub fn error(&self, timeout: Option<Duration>) -> Option<String> {
+        match (self.timed_out, self.code) {
+            (true, _) => Some(format!(
+                "timed out after {} ms",
+                timeout.map_or(0, |t| t.as_millis())
+            )),
+            (false, Some(0)) => None,
+            (false, Some(code)) => Some(format!("exit status {}", code)),
+            (false, None) => Some("terminated by a signal".to_string()),
+        }
+    }
+}
+
+/// Run `program` with `args` and wait for it, killing it once `timeout`
+/// has passed. Fails only when the program cannot be started.
+pub fn run(program: &str, args: &[String], timeout: Option<Duration>) -> io::Result<Output> {
+    let mut child = Command::new(program)
+        .args(args)
+        .stdin(Stdio::null())
+        .stdout(Stdio::piped())
+        .stderr(Stdio::piped())
+        .spawn()?;
+    let stdout = Pipe::drain(child.stdout.take());
+    let stderr = Pipe::drain(child.stderr.take());
+
+    let (status, timed_out, pipes_deadline) = match timeout {
+        None => (child.wait()?, false, None),
+        Some(timeout) => {
+            let (status, timed_out) = wait_until(&mut child, Instant::now() + timeout)?;
+            (status, timed_out, Some(Instant::now() + PIPE_GRACE))
+        }
+    };
+    Ok(Output {
+        stdout: stdout.collect(pipes_deadline),
+        stderr: stderr.collect(pipes_deadline),
+        code: status.code(),
+        timed_out,
+    })
+}
+
+/// Everything read so far from one of the child's output pipes.
+struct Pipe {
+    buf: Arc<Mutex<Vec<u8>>>,
+    closed: mpsc::Receiver<()>,
+}
+
+impl Pipe {
+    /// Read all of `pipe` on a new thread.
+    fn drain<R: Read + Send + 'static>(pipe: Option<R>) -> Pipe {
+        let buf = Arc::new(Mutex::new(Vec::new()));
+        let (tx, closed) = mpsc::channel();
+        let sink = Arc::clone(&buf);
+        thread::spawn(move || {
+            if let Some(mut pipe) = pipe {
+                let mut chunk = [0u8; 8192];
+                while let Ok(n @ 1..) = pipe.read(&mut chunk) {
+                    sink.lock()
+                        .unwrap_or_else(|e| e.into_inner())
+                        .extend_from_slice(&chunk[..n]);
+                }
+            }
+            let _ = tx.send(());
+        });
+        Pipe { buf, closed }
+    }
+
+    /// The pipe's contents once it closes, or at `deadline`. A process the
+    /// child started may keep the pipe open after the child is gone.
+    fn collect(self, deadline: Option<Instant>) -> String {
+        let _ = match deadline {
+            None => self.closed.recv().ok(),
+            Some(deadline) => self
+                .closed
+                .recv_timeout(deadline.saturating_duration_since(Instant::now()))
+                .ok(),
+        };
+        let buf = self.buf.lock().unwrap_or_else(|e| e.into_inner());
+        String::from_utf8_lossy(&buf).into_owned()
+    }
+}
+
+/// Wait for `child` to exit, killing it at `deadline`.
+fn wait_until(
+    child: &mut Child,
+    deadline: Instant,
+) -> io::Result<(std::process::ExitStatus, bool)> {
+    loop {
+        if let Some(status) = child.try_wait()? {
+            return Ok((status, false));
+        }
+        let now = Instant::now();
+        if now >= deadline {
+            // The child may exit between the check and the kill
+            let _ = child.kill();
+            return Ok((child.wait()?, true));
+        }
+        thread::sleep(POLL_INTERVAL.min(deadline - now));
+    }
+}
+
+// ---------------------------------------------------------------------------
+// Tests
+// ---------------------------------------------------------------------------
+
+#[cfg(all(test, unix))]
+mod tests {
+    use super::*;
+
+    fn args(args: &[&str]) -> Vec<String> {
+        args.iter().map(|a| a.to_string()).collect()
+    }
+
+    #[test]
+    fn captures_stdout_and_exit_code() {
+        let out = run("echo", &args(&["hello", "world"]), None).unwrap();
+        assert_eq!(out.stdout, "hello world\n");
+        assert_eq!(out.stderr, "");
+        assert_eq!(out.code, Some(0));
+        assert_eq!(out.error(None), None);
+    }
+
+    #[test]
+    fn captures_stderr_and_a_failing_exit_code() {
+        let out = run("sh", &args(&["-c", "echo oops >&2; exit 3"]), None).unwrap();
+        assert_eq!(out.stdout, "");
+        assert_eq!(out.stderr, "oops\n");
+        assert_eq!(out.code, Some(3));
+        assert_eq!(out.error(None).as_deref(), Some("exit status 3"));
+    }
+
+    #[test]
+    fn arguments_are_not_split_by_a_shell() {
+        let out = run("printf", &args(&["%s|", "a b", "$HOME"]), None).unwrap();
+        assert_eq!(out.stdout, "a b|$HOME|");
+    }
+
+    #[test]
+    fn kills_a_hanging_command_at_the_timeout() {
+        let timeout = Duration::from_millis(100);
+        let start = Instant::now();
+        let out = run(
+            "sh",
+            &args(&["-c", "echo started; sleep 30"]),
+            Some(timeout),
+        )
+        .unwrap();
+        assert!(
+            start.elapsed() < Duration::from_secs(10),
+            "{:?}",
+            start.elapsed()
+        );
+        assert!(out.timed_out);
+        assert_eq!(out.code, None);
+        assert_eq!(out.stdout, "started\n");
+        assert_eq!(
+            out.error(Some(timeout)).as_deref(),
+            Some("timed out after 100 ms")
+        );
+    }
+
+    #[test]
+    fn a_fast_command_finishes_before_its_timeout() {
+        let out = run("true", &[], Some(Duration::from_secs(10))).unwrap();
+        assert!(!out.timed_out);
+        assert_eq!(out.code, Some(0));
+    }
+
+    #[test]
+    fn a_missing_program_fails_to_start() {
+        let err = run("lumen-no-such-program", &[], None).unwrap_err();
+        assert_eq!(err.kind(), io::ErrorKind::NotFound);
+    }
+}
@@ -0,0 +1,246 @@
+//! Task-local storage for the Lumen runtime.
+//!
+//! A [`ThreadLocal`] holds one value for each task that uses it, so
+//! concurrent tasks can keep scratch buffers or accumulators without any
+//! synchronization. A task's value is created by the initializer the first
+//! time the task reads it and is dropped when the task finishes.
+//!
+//! A [`Task`](crate::scheduler::Task) runs start to finish on a single
+//! worker thread, so its values live in that thread's storage for the length
+//! of [`Task::run`](crate::scheduler::Task::run) and are never shared. Two
+//! tasks run one after the other by the same worker see independent values.
+//! Code running outside any task, such as a [`Nursery`](crate::nursery::Nursery)
+//! child on its own OS thread, gets one value per thread instead.
+//!
+//! # Example
+//!
+//! ```rust
+//! use lumen_runtime::task_local::ThreadLocal;
+//!
+//! let scratch: ThreadLocal<Vec<u8>> = ThreadLocal::new(Vec::new);
+//! scratch.with(|buf| buf.extend_from_slice(b"abc"));
+//! assert_eq!(scratch.get(), b"abc".to_vec());
+//! scratch.set(Vec::new());
+//! assert!(scratch.get().is_empty());
+//! ```
+
+use std::any::Any;
+use std::cell::RefCell;
+use std::collections::HashMap;
+use std::fmt;
+use std::sync::atomic::{AtomicUsize, Ordering};
+
+/// Source of [`ThreadLocal`] keys.
+static NEXT_KEY: AtomicUsize = AtomicUsize::new(0);
+
+thread_local! {
+    /// Values of the task running on this thread, by [`ThreadLocal`] key; the
+    /// thread's own values when no task is running.
+    static SLOTS: RefCell<HashMap<usize, Box<dyn Any>>> = RefCell::new(HashMap::new());
+}
+
+/// Run `f` with a fresh set of task-local values, restoring the previous
+/// set afterwards, even if `f` panics.
+pub(crate) fn scope<R>(f: impl FnOnce() -> R) -> R {
+    struct Restore(Option<HashMap<usize, Box<dyn Any>>>);
+    impl Drop for Restore {
+        fn drop(&mut self) {
+            if let Some(outer) = self.0.take() {
+                // Dropping the task's values here, outside the borrow, lets
+                // their destructors use task-local storage too.
+                let _inner = SLOTS.with(|slots| slots.replace(outer));
+            }
+        }
+    }
+    let _restore = Restore(Some(SLOTS.with(|slots| slots.take())));
+    f()
+}
+
+/// One value of `T` per task.
+///
+/// Share it between tasks in an `Arc` or a `static`. Values never move
+/// between threads, so `T` need not be `Send`.
+pub struct ThreadLocal<T: 'static> {
+    key: usize,
+    init: Box<dyn Fn() -> T + Send + Sync>,
+}
+
+impl<T: 'static> ThreadLocal<T> {
+    /// Create a slot whose value starts as `init()` in each task.
+    pub fn new<F>(init: F) -> Self
+    where
+        F: Fn() -> T + Send + Sync + 'static,
+    {
+        Self {
+            key: NEXT_KEY.fetch_add(1, Ordering::Relaxed),
+            init: Box::new(init),
+        }
+    }
+
+    /// Run `f` on the current task's value, creating it first if needed.
+    ///
+    /// The value is taken out of storage while `f` runs, so `f` may use
+    /// other slots; a nested `with` or `get` of this same slot inside `f`
+    /// sees a fresh value, and its changes are overwritten when `f` returns.
+    pub fn with<R>(&self, f: impl FnOnce(&mut T) -> R) -> R {
+        let stored = SLOTS.with(|slots| slots.borrow_mut().remove(&self.key));
+        let mut value = match stored {
+            Some(boxed) => match boxed.downcast::<T>() {
+                Ok(value) => *value,
+                Err(_) => unreachable!("task-local key {} holds another type", self.key),
+            },
+            None => (self.init)(),
+        };
+        let out = f(&mut value);
+        self.set(value);
+        out
+    }
+
+    /// A copy of the current task's value, creating it first if needed.
+    pub fn get(&self) -> T
+    where
+        T: Clone,
+    {
+        self.with(|value| value.clone())
+    }
+
+    /// Replace the current task's value.
+    pub fn set(&self, value: T) {
+        let _old = SLOTS.with(|slots| slots.borrow_mut().insert(self.key, Box::new(value)));
+    }
+}
+
+impl<T: 'static> fmt::Debug for ThreadLocal<T> {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        f.debug_struct("ThreadLocal")
+            .field("key", &self.key)
+            .finish()
+    }
+}
+
+// ---------------------------------------------------------------------------
+// Tests
+// ---------------------------------------------------------------------------
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::process::ProcessId;
+    use crate::scheduler::{Scheduler, Task};
+    use std::sync::{mpsc, Arc};
+    use std::time::Duration;
+
+    #[test]
+    fn set_then_get_outside_a_task() {
+        let slot = ThreadLocal::new(|| 0);
+        assert_eq!(slot.get(), 0);
+        slot.set(7);
+        assert_eq!(slot.get(), 7);
+        slot.with(|v| *v += 1);
+        assert_eq!(slot.get(), 8);
+    }
+
+    #[test]
+    fn two_tasks_see_independent_values() {
+        let slot = Arc::new(ThreadLocal::new(|| 0i64));
+        let mut first = {
+            let slot = Arc::clone(&slot);
+            Task::new(ProcessId::next(), move || {
+                slot.set(1);
+                assert_eq!(slot.get(), 1);
+            })
+        };
+        let (tx, rx) = mpsc::channel();
+        let mut second = {
+            let slot = Arc::clone(&slot);
+            Task::new(ProcessId::next(), move || {
+                tx.send(slot.get()).unwrap();
+                slot.set(2);
+            })
+        };
+
+        // Same thread, one after the other: nothing carries over.
+        first.run();
+        second.run();
+        assert_eq!(rx.recv().unwrap(), 0);
+        // Nor does either task's value leak into the thread's own.
+        assert_eq!(slot.get(), 0);
+    }
+
+    #[test]
+    fn concurrent_tasks_keep_per_task_accumulators() {
+        let slot = Arc::new(ThreadLocal::new(Vec::<usize>::new));
+        let mut sched = Scheduler::new(4);
+        let (tx, rx) = mpsc::channel();
+        let tasks = 32;
+
+        for t in 0..tasks {
+            let (slot, tx) = (Arc::clone(&slot), tx.clone());
+            sched.spawn_fn(move || {
+                for i in 0..100 {
+                    slot.with(|acc| acc.push(t * 1000 + i));
+                }
+                tx.send((t, slot.get())).unwrap();
+            });
+        }
+
+        for _ in 0..tasks {
+            let (t, acc) = rx.recv_timeout(Duration::from_secs(10)).unwrap();
+            assert_eq!(acc, (0..100).map(|i| t * 1000 + i).collect::<Vec<_>>());
+        }
+        sched.shutdown();
+    }
+
+    #[test]
+    fn initializer_runs_once_per_task() {
+        let inits = Arc::new(AtomicUsize::new(0));
+        let slot = {
+            let inits = Arc::clone(&inits);
+            Arc::new(ThreadLocal::new(move || {
+                inits.fetch_add(1, Ordering::SeqCst);
+                String::from("fresh")
+            }))
+        };
+        let mut sched = Scheduler::new(2);
+        let tasks = 10;
+
+        for _ in 0..tasks {
+            let slot = Arc::clone(&slot);
+            sched.spawn_fn(move || {
+                for _ in 0..5 {
+                    assert_eq!(slot.get(), "fresh");
+                }
+                slot.with(|s| s.push('!'));
+                assert_eq!(slot.get(), "fresh!");
+            });
+        }
+
+        assert_eq!(
+            sched.wait_for_completion(tasks, Duration::from_secs(10)),
+            tasks
+        );
+        sched.shutdown();
+        assert_eq!(inits.load(Ordering::SeqCst), tasks);
+    }
+
+    #[test]
+    fn values_are_dropped_when_the_task_finishes() {
+        struct Flag(Arc<AtomicUsize>);
+        impl Drop for Flag {
+            fn drop(&mut self) {
+                self.0.fetch_add(1, Ordering::SeqCst);
+            }
+        }
+        let drops = Arc::new(AtomicUsize::new(0));
+        let slot = {
+            let drops = Arc::clone(&drops);
+            Arc::new(ThreadLocal::new(move || Flag(Arc::clone(&drops))))
+        };
+        let mut task = {
+            let slot = Arc::clone(&slot);
+            Task::new(ProcessId::next(), move || slot.with(|_| ()))
+        };
+        task.run();
+        assert_eq!(drops.load(Ordering::SeqCst), 1);
+    }
+}
@@ -0,0 +1,112 @@
+//! Unicode character classification for the Lumen runtime (`std.unicode`).
+//!
+//! Letters, white space, and case mappings use the Unicode tables behind
+//! Rust's `char`: a letter is a character with the `Alphabetic` property and
+//! a space one with the `White_Space` property. Case conversion maps one
+//! character to one character; when the full mapping is longer (`ß` upper
+//! cases to `SS`), the character is returned unchanged.
+//!
+//! Decimal digits, general category `Nd`, have no `char` method. Every such
+//! character belongs to a run of ten holding the values 0 through 9 in
+//! order, so [`DIGIT_ZEROS`] lists the first code point of each run, from
+//! the Unicode 15.0 character database.
+
+/// The code point of the zero of each run of decimal digits, ascending.
+const DIGIT_ZEROS: [u32; 68] = [
+    0x0030, 0x0660, 0x06F0, 0x07C0, 0x0966, 0x09E6, 0x0A66, 0x0AE6, 0x0B66, 0x0BE6, 0x0C66, 0x0CE6,
+    0x0D66, 0x0DE6, 0x0E50, 0x0ED0, 0x0F20, 0x1040, 0x1090, 0x17E0, 0x1810, 0x1946, 0x19D0, 0x1A80,
+    0x1A90, 0x1B50, 0x1BB0, 0x1C40, 0x1C50, 0xA620, 0xA8D0, 0xA900, 0xA9D0, 0xA9F0, 0xAA50, 0xABF0,
+    0xFF10, 0x104A0, 0x10D30, 0x11066, 0x110F0, 0x11136, 0x111D0, 0x112F0, 0x11450, 0x114D0,
+    0x11650, 0x116C0, 0x11730, 0x118E0, 0x11950, 0x11C50, 0x11D50, 0x11DA0, 0x11F50, 0x16A60,
+    0x16AC0, 0x16B50, 0x1D7CE, 0x1D7D8, 0x1D7E2, 0x1D7EC, 0x1D7F6, 0x1E140, 0x1E2F0, 0x1E4F0,
+    0x1E950, 0x1FBF0,
+];
+
+/// The value 0–9 of a decimal digit in any script, or `None`.
+pub fn digit_value(c: char) -> Option<u32> {
+    let cp = c as u32;
+    let run = match DIGIT_ZEROS.binary_search(&cp) {
+        Ok(i) => i,
+        Err(0) => return None,
+        Err(i) => i - 1,
+    };
+    let value = cp - DIGIT_ZEROS[run];
+    (value < 10).then_some(value)
+}
+
+/// True for a decimal digit (general category `Nd`) in any script.
+pub fn is_digit(c: char) -> bool {
+    digit_value(c).is_some()
+}
+
+/// True for a character with the `Alphabetic` property.
+pub fn is_letter(c: char) -> bool {
+    c.is_alphabetic()
+}
+
+/// True for a character with the `White_Space` property.
+pub fn is_space(c: char) -> bool {
+    c.is_whitespace()
+}
+
+/// The upper-case form of `c`, or `c` when it has no single-character one.
+pub fn to_upper(c: char) -> char {
+    single(c.to_uppercase()).unwrap_or(c)
+}
+
+/// The lower-case form of `c`, or `c` when it has no single-character one.
+pub fn to_lower(c: char) -> char {
+    single(c.to_lowercase()).unwrap_or(c)
+}
+
+fn single(mut chars: impl Iterator<Item = char>) -> Option<char> {
+    let first = chars.next()?;
+    chars.next().is_none().then_some(first)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn digit_zeros_are_sorted_runs_of_ten() {
+        for pair in DIGIT_ZEROS.windows(2) {
+            assert!(
+                pair[1] - pair[0] >= 10,
+                "{:#x} overlaps {:#x}",
+                pair[0],
+                pair[1]
+            );
+        }
+        for zero in DIGIT_ZEROS {
+            let c = char::from_u32(zero).unwrap();
+            assert!(c.is_numeric(), "{:#x} is not numeric", zero);
+        }
+    }
+
+    #[test]
+    fn digits_in_several_scripts() {
+        assert_eq!(digit_value('7'), Some(7));
+        assert_eq!(digit_value('٣'), Some(3)); // Arabic-Indic
+        assert_eq!(digit_value('९'), Some(9)); // Devanagari
+        assert_eq!(digit_value('０'), Some(0)); // fullwidth
+        assert_eq!(digit_value('𝟗'), Some(9)); // mathematical bold
+        assert!(!is_digit('a'));
+        assert!(!is_digit('²')); // superscript: category No
+        assert!(!is_digit('Ⅳ')); // Roman numeral: category Nl
+        assert!(!is_digit('/')); // just below '0'
+        assert!(!is_digit(':')); // just above '9'
+    }
+
+    #[test]
+    fn letters_spaces_and_case() {
+        assert!(is_letter('a') && is_letter('Ж') && is_letter('語'));
+        assert!(!is_letter('1') && !is_letter(' '));
+        assert!(is_space(' ') && is_space('\t') && is_space('\u{3000}'));
+        assert!(!is_space('x') && !is_space('\u{200B}'));
+        assert_eq!(to_upper('é'), 'É');
+        assert_eq!(to_lower('Σ'), 'σ');
+        assert_eq!(to_upper('ß'), 'ß');
+        assert_eq!(to_upper('1'), '1');
+    }
+}
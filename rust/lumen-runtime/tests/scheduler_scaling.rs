@@ -0,0 +1,172 @@
+//! Integration tests for the work-stealing `Scheduler` under load: the
+//! cross-language matrix benchmark computed one task per row, and the
+//! speedup of many small tasks as workers are added.
+
+use lumen_runtime::scheduler::{Scheduler, Spawner};
+use std::hint::black_box;
+use std::sync::atomic::{AtomicU64, Ordering};
+use std::sync::mpsc;
+use std::sync::Arc;
+use std::time::{Duration, Instant};
+
+// ===========================================================================
+// Matrix multiply — bench/cross-language/matrix_mult
+// ===========================================================================
+
+const N: usize = 200;
+
+fn matrices() -> (Vec<Vec<f64>>, Vec<Vec<f64>>) {
+    let a = (0..N)
+        .map(|i| {
+            (0..N)
+                .map(|j| ((i * N + j) % 1000) as f64 / 1000.0)
+                .collect()
+        })
+        .collect();
+    let b = (0..N)
+        .map(|i| {
+            (0..N)
+                .map(|j| ((j * N + i) % 1000) as f64 / 1000.0)
+                .collect()
+        })
+        .collect();
+    (a, b)
+}
+
+fn product_row(a: &[Vec<f64>], b: &[Vec<f64>], i: usize) -> Vec<f64> {
+    (0..N)
+        .map(|j| {
+            let mut sum = 0.0;
+            for k in 0..N {
+                sum += a[i][k] * b[k][j];
+            }
+            sum
+        })
+        .collect()
+}
+
+/// Sum of every element, row by row, in the reference implementations'
+/// order.
+fn checksum(c: &[Vec<f64>]) -> f64 {
+    let mut checksum = 0.0;
+    for row in c {
+        for x in row {
+            checksum += x;
+        }
+    }
+    checksum
+}
+
+/// `A * B` with one task per row, spawned from a single root task so the
+/// rows start on one worker's local deque and the others steal them.
+fn parallel_product(workers: usize) -> Vec<Vec<f64>> {
+    let (a, b) = matrices();
+    let (a, b) = (Arc::new(a), Arc::new(b));
+    let mut sched = Scheduler::new(workers);
+    let spawner = sched.spawner();
+    let (tx, rx) = mpsc::channel();
+    sched.spawn_fn(move || {
+        for i in 0..N {
+            let (a, b, tx) = (Arc::clone(&a), Arc::clone(&b), tx.clone());
+            spawner.spawn_fn(move || {
+                let _ = tx.send((i, product_row(&a, &b, i)));
+            });
+        }
+    });
+    let mut rows: Vec<(usize, Vec<f64>)> = rx.iter().take(N).collect();
+    sched.shutdown();
+    rows.sort_by_key(|(i, _)| *i);
+    rows.into_iter().map(|(_, row)| row).collect()
+}
+
+#[test]
+fn parallel_matrix_checksum_matches_the_reference() {
+    let (a, b) = matrices();
+    let sequential: Vec<Vec<f64>> = (0..N).map(|i| product_row(&a, &b, i)).collect();
+    for workers in [1, 2, 4] {
+        let parallel = parallel_product(workers);
+        assert_eq!(parallel, sequential, "{} workers", workers);
+        let sum = checksum(&parallel);
+        assert_eq!(sum.to_bits(), checksum(&sequential).to_bits());
+        assert_eq!(format!("{:.6}", sum), "2022668.000001");
+    }
+}
+
+// ===========================================================================
+// Scaling — many small tasks
+// ===========================================================================
+
+const BATCHES: usize = 64;
+const TASKS_PER_BATCH: usize = 512;
+const SPINS_PER_TASK: u64 = 20_000;
+
+/// A small unit of CPU-bound work.
+fn spin(seed: u64) -> u64 {
+    let mut x = seed | 1;
+    for _ in 0..SPINS_PER_TASK {
+        x ^= x << 13;
+        x ^= x >> 7;
+        x ^= x << 17;
+    }
+    black_box(x)
+}
+
+/// Spawn `TASKS_PER_BATCH` tasks from each of `BATCHES` tasks, and return
+/// the wall time until all have run and the XOR of their results.
+fn run_small_tasks(workers: usize) -> (Duration, u64) {
+    let mut sched = Scheduler::new(workers);
+    let spawner: Spawner = sched.spawner();
+    let acc = Arc::new(AtomicU64::new(0));
+    let start = Instant::now();
+    for batch in 0..BATCHES {
+        let spawner = spawner.clone();
+        let acc = Arc::clone(&acc);
+        sched.spawn_fn(move || {
+            for t in 0..TASKS_PER_BATCH {
+                let acc = Arc::clone(&acc);
+                let seed = (batch * TASKS_PER_BATCH + t) as u64;
+                spawner.spawn_fn(move || {
+                    acc.fetch_xor(spin(seed), Ordering::Relaxed);
+                });
+            }
+        });
+    }
+    let expected = BATCHES + BATCHES * TASKS_PER_BATCH;
+    let completed = sched.wait_for_completion(expected, Duration::from_secs(300));
+    let elapsed = start.elapsed();
+    sched.shutdown();
+    assert_eq!(completed, expected, "{} workers timed out", workers);
+    (elapsed, acc.load(Ordering::SeqCst))
+}
+
+#[test]
+#[ignore] // Timing-sensitive. Run with: cargo test --release -p lumen-runtime --test scheduler_scaling -- --ignored
+fn small_tasks_speed_up_nearly_linearly_up_to_cpu_count() {
+    let cpus = std::thread::available_parallelism()
+        .map(|n| n.get())
+        .unwrap_or(1);
+    let mut counts: Vec<usize> = std::iter::successors(Some(1), |w| Some(w * 2))
+        .take_while(|w| *w < cpus)
+        .collect();
+    counts.push(cpus);
+
+    let (baseline, expected) = run_small_tasks(1);
+    for workers in counts.into_iter().skip(1) {
+        let (elapsed, result) = run_small_tasks(workers);
+        assert_eq!(
+            result, expected,
+            "{} workers computed a different result",
+            workers
+        );
+        let speedup = baseline.as_secs_f64() / elapsed.as_secs_f64();
+        println!("{:>3} workers: {:?} ({:.2}x)", workers, elapsed, speedup);
+        // Near-linear: at least 60% of ideal, leaving room for SMT siblings
+        // and the 1 ms idle parking.
+        assert!(
+            speedup >= 0.6 * workers as f64,
+            "{} workers: {:.2}x speedup over 1 worker",
+            workers,
+            speedup
+        );
+    }
+}
@@ -0,0 +1,72 @@
+//! Embeds the commit and target a build came from, for `lumen_vm::build_info`.
+//!
+//! `LUMEN_BUILD_COMMIT` overrides the commit, for builds made outside a git
+//! checkout such as from a source tarball; without it the commit is read
+//! from git, and is `unknown` when git cannot say.
+//!
+//! `LUMEN_HASH_SEED`, when set, fixes the seed of the runtime's hash tables
+//! for `lumen_vm::hash_seed`.
+
+use std::path::Path;
+use std::process::Command;
+
+fn main() {
+    println!("cargo:rerun-if-changed=build.rs");
+    println!("cargo:rerun-if-env-changed=LUMEN_BUILD_COMMIT");
+
+    let commit = std::env::var("LUMEN_BUILD_COMMIT")
+        .ok()
+        .filter(|c| !c.is_empty())
+        .or_else(git_commit)
+        .unwrap_or_else(|| "unknown".to_string());
+    let target = std::env::var("TARGET").unwrap_or_else(|_| "unknown".to_string());
+    println!("cargo:rustc-env=LUMEN_BUILD_COMMIT={}", commit);
+    println!("cargo:rustc-env=LUMEN_BUILD_TARGET={}", target);
+
+    println!("cargo:rerun-if-env-changed=LUMEN_HASH_SEED");
+    if let Some(seed) = std::env::var("LUMEN_HASH_SEED")
+        .ok()
+        .filter(|s| !s.is_empty())
+    {
+        let seed: u64 = seed
+            .trim()
+            .parse()
+            .unwrap_or_else(|_| panic!("LUMEN_HASH_SEED must be a u64, got {:?}", seed));
+        println!("cargo:rustc-env=LUMEN_BUILD_HASH_SEED={}", seed);
+    }
+}
+
+/// The abbreviated hash of HEAD, and a request to rebuild when it moves.
+fn git_commit() -> Option<String> {
+    let output = Command::new("git")
+        .args(["rev-parse", "--short=12", "HEAD"])
+        .output()
+        .ok()?;
+    if !output.status.success() {
+        return None;
+    }
+    let commit = String::from_utf8(output.stdout).ok()?.trim().to_string();
+    if commit.is_empty() {
+        return None;
+    }
+    let git_dir = Command::new("git")
+        .args(["rev-parse", "--git-dir"])
+        .output()
+        .ok()
+        .and_then(|o| String::from_utf8(o.stdout).ok())
+        .map(|dir| dir.trim().to_string());
+    if let Some(git_dir) = git_dir {
+        let head = Path::new(&git_dir).join("HEAD");
+        println!("cargo:rerun-if-changed={}", head.display());
+        // HEAD names a branch; the branch's ref is what changes on commit
+        if let Ok(contents) = std::fs::read_to_string(&head) {
+            if let Some(branch) = contents.trim().strip_prefix("ref: ") {
+                println!(
+                    "cargo:rerun-if-changed={}",
+                    Path::new(&git_dir).join(branch).display()
+                );
+            }
+        }
+    }
+    Some(commit)
+}
@@ -2,7 +2,10 @@
 //!
 //! All allocations within an arena share the arena's lifetime.
 //! The arena frees all memory at once when dropped, or can be
-//! `reset()` to reuse the underlying chunk storage.
+//! `reset()` to reuse the underlying chunk storage. A `region()` frees
+//! just the allocations made inside it, so a phase that builds many
+//! temporaries (a parse tree, say) gives its memory back on exit without
+//! any tracing.
 
 use std::alloc::Layout;
 use std::fmt;
@@ -11,6 +14,14 @@ use std::marker::PhantomData;
 /// Default chunk size: 64 KiB.
 const DEFAULT_CHUNK_SIZE: usize = 64 * 1024;
 
+/// Position of an arena's cursor, taken on entry to a region.
+#[derive(Debug, Clone, Copy)]
+struct ArenaMark {
+    chunks: usize,
+    current: *mut u8,
+    total_allocated: usize,
+}
+
 /// A simple bump-pointer arena for process-local allocations.
 ///
 /// All allocations are freed at once when the arena is dropped.
@@ -122,6 +133,20 @@ impl Arena {
         self.chunks.truncate(1);
     }
 
+    /// Run `f` in a region of this arena: whatever it allocates is freed
+    /// when it returns. References into the region cannot escape, since the
+    /// result type may not borrow from the arena.
+    ///
+    /// # Panics
+    /// Panics if `f` leaves the arena with fewer allocations than it found,
+    /// by resetting it or swapping in another arena.
+    pub fn region<R>(&mut self, f: impl FnOnce(&mut Arena) -> R) -> R {
+        let mark = self.mark();
+        let result = f(self);
+        self.release_to(mark);
+        result
+    }
+
     /// Total bytes handed out to callers (excluding alignment padding).
     pub fn bytes_allocated(&self) -> usize {
         self.total_allocated
@@ -139,6 +164,48 @@ impl Arena {
 
     // --- internal helpers ---
 
+    /// Record the current cursor so later allocations can be released
+    /// with `release_to`.
+    fn mark(&self) -> ArenaMark {
+        ArenaMark {
+            chunks: self.chunks.len(),
+            current: self.current,
+            total_allocated: self.total_allocated,
+        }
+    }
+
+    /// Free everything allocated since `mark`: chunks added after it are
+    /// dropped and the cursor is rewound to where it was. Only `region`
+    /// calls this, after its borrow of every later allocation has ended.
+    ///
+    /// The mark is checked against the arena rather than trusted, so a
+    /// cursor that does not lie in this arena's chunks panics instead of
+    /// dangling.
+    fn release_to(&mut self, mark: ArenaMark) {
+        assert!(
+            mark.chunks <= self.chunks.len() && mark.total_allocated <= self.total_allocated,
+            "arena mark is newer than the arena's cursor"
+        );
+        self.chunks.truncate(mark.chunks);
+        let Some(chunk) = self.chunks.last_mut() else {
+            assert!(mark.current.is_null(), "arena mark is from another arena");
+            self.current = std::ptr::null_mut();
+            self.remaining = 0;
+            self.total_allocated = mark.total_allocated;
+            return;
+        };
+        let start = chunk.as_mut_ptr() as usize;
+        let end = start + chunk.len();
+        let at = mark.current as usize;
+        assert!(
+            (start..=end).contains(&at),
+            "arena mark is from another arena"
+        );
+        self.current = mark.current;
+        self.remaining = end - at;
+        self.total_allocated = mark.total_allocated;
+    }
+
     /// Try to bump-allocate within the current chunk.
     fn try_alloc_in_current(&mut self, size: usize, align: usize) -> Option<*mut u8> {
         if self.current.is_null() {
@@ -314,6 +381,93 @@ mod tests {
         assert_eq!(arena.chunk_count(), 0);
     }
 
+    #[test]
+    fn test_region_reclaims_tree() {
+        struct Node<'a> {
+            value: u64,
+            left: Option<&'a Node<'a>>,
+            right: Option<&'a Node<'a>>,
+        }
+
+        fn build<'a>(arena: &'a mut Arena, depth: u32, next: &mut u64) -> &'a Node<'a> {
+            let arena: *mut Arena = arena;
+            // SAFETY: nodes never move and all live until the region ends;
+            // the raw pointer only lets children borrow the same arena.
+            let (left, right) = if depth == 0 {
+                (None, None)
+            } else {
+                unsafe {
+                    (
+                        Some(build(&mut *arena, depth - 1, next)),
+                        Some(build(&mut *arena, depth - 1, next)),
+                    )
+                }
+            };
+            *next += 1;
+            unsafe {
+                (*arena).alloc_value(Node {
+                    value: *next,
+                    left,
+                    right,
+                })
+            }
+        }
+
+        fn sum(node: &Node) -> u64 {
+            node.value + node.left.map_or(0, sum) + node.right.map_or(0, sum)
+        }
+
+        let mut arena = Arena::with_chunk_size(4096);
+        let _ = arena.alloc_value(7u64);
+        let allocated_before = arena.bytes_allocated();
+        let reserved_before = arena.bytes_reserved();
+
+        // 2^15 - 1 nodes, far more than one chunk holds
+        let total = arena.region(|arena| {
+            let mut next = 0;
+            let total = sum(build(arena, 14, &mut next));
+            assert!(arena.chunk_count() > 1);
+            total
+        });
+        let nodes = (1u64 << 15) - 1;
+        assert_eq!(total, nodes * (nodes + 1) / 2);
+
+        assert_eq!(arena.bytes_allocated(), allocated_before);
+        assert_eq!(arena.bytes_reserved(), reserved_before);
+        assert_eq!(arena.chunk_count(), 1);
+    }
+
+    #[test]
+    fn test_region_keeps_earlier_allocations() {
+        let mut arena = Arena::with_chunk_size(256);
+        let kept = arena.alloc_value(11u64) as *mut u64;
+        arena.region(|arena| {
+            for i in 0..64u64 {
+                let _ = arena.alloc_value(i);
+            }
+        });
+        // The cursor is back after `kept`, so the next value lands behind it
+        let next = arena.alloc_value(12u64) as *mut u64;
+        assert_eq!(next as usize, kept as usize + 8);
+        assert_eq!(unsafe { *kept }, 11);
+        assert_eq!(arena.bytes_allocated(), 16);
+    }
+
+    #[test]
+    #[should_panic(expected = "arena mark is from another arena")]
+    fn test_region_rejects_a_swapped_arena() {
+        let mut arena = Arena::with_chunk_size(256);
+        let _ = arena.alloc_value(1u64);
+        arena.region(|arena| {
+            // The new arena is further along than the mark, so only the
+            // cursor check can tell the mark does not belong to it
+            let mut other = Arena::with_chunk_size(256);
+            let _ = other.alloc_value(2u64);
+            let _ = other.alloc_value(3u64);
+            std::mem::swap(arena, &mut other);
+        });
+    }
+
     #[test]
     #[should_panic(expected = "chunk_size must be > 0")]
     fn test_zero_chunk_size_panics() {
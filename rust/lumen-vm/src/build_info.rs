@@ -0,0 +1,38 @@
+//! Which build of Lumen is running.
+//!
+//! The version comes from the workspace manifest; the commit and target are
+//! embedded by `build.rs` when the VM is compiled. `lumen --version` prints
+//! [`LONG_VERSION`], and Lumen programs read the same values with the
+//! `build_info()` builtin, so a benchmark report can record exactly which
+//! build produced it.
+
+/// The release version, such as `0.5.0`.
+pub const VERSION: &str = env!("CARGO_PKG_VERSION");
+
+/// The git commit the build came from, abbreviated, or `unknown`.
+pub const COMMIT: &str = env!("LUMEN_BUILD_COMMIT");
+
+/// The target triple the build runs on, such as `x86_64-unknown-linux-gnu`.
+pub const TARGET: &str = env!("LUMEN_BUILD_TARGET");
+
+/// `VERSION (COMMIT TARGET)`.
+pub const LONG_VERSION: &str = concat!(
+    env!("CARGO_PKG_VERSION"),
+    " (",
+    env!("LUMEN_BUILD_COMMIT"),
+    " ",
+    env!("LUMEN_BUILD_TARGET"),
+    ")"
+);
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn long_version_names_all_three() {
+        assert_eq!(LONG_VERSION, format!("{} ({} {})", VERSION, COMMIT, TARGET));
+        assert!(!COMMIT.is_empty());
+        assert!(!TARGET.is_empty());
+    }
+}
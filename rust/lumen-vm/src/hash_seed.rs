@@ -0,0 +1,112 @@
+//! The seed for the runtime's hash tables.
+//!
+//! Lumen maps and sets are ordered trees and never hash, but the runtime
+//! keeps its own hash tables keyed by program data, such as the lookup side
+//! of the string intern table. A table's buckets, and so which keys collide
+//! and when it resizes, depend on the seed its hasher starts from.
+//!
+//! By default every process picks a random seed, so input chosen to collide
+//! in one run does not collide in the next. Building with
+//! `LUMEN_HASH_SEED=<u64>` in the environment fixes the seed instead, so a
+//! collision pattern seen while debugging comes back on every run of that
+//! build:
+//!
+//! ```text
+//! LUMEN_HASH_SEED=42 cargo build -p lumen-cli
+//! ```
+
+use std::collections::hash_map::{DefaultHasher, RandomState};
+use std::collections::HashMap;
+use std::hash::{BuildHasher, Hasher};
+use std::sync::OnceLock;
+
+/// The seed fixed at build time by `LUMEN_HASH_SEED`, if any.
+pub const FIXED_SEED: Option<&str> = option_env!("LUMEN_BUILD_HASH_SEED");
+
+/// The seed this process hashes with: the build's fixed seed, or one drawn
+/// at random on first use.
+pub fn hash_seed() -> u64 {
+    static SEED: OnceLock<u64> = OnceLock::new();
+    *SEED.get_or_init(|| match FIXED_SEED {
+        // build.rs has already checked that it parses
+        Some(seed) => seed.parse().expect("LUMEN_HASH_SEED should be a u64"),
+        None => RandomState::new().build_hasher().finish(),
+    })
+}
+
+/// A hash map whose hasher starts from a [`SeededState`].
+pub type SeededHashMap<K, V> = HashMap<K, V, SeededState>;
+
+/// Builds hashers that start from a seed: [`hash_seed`] by default, or one
+/// given to [`SeededState::with_seed`]. Two tables built from the same seed
+/// with the same operations have the same buckets.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub struct SeededState {
+    seed: u64,
+}
+
+impl SeededState {
+    pub fn with_seed(seed: u64) -> Self {
+        SeededState { seed }
+    }
+
+    pub fn seed(&self) -> u64 {
+        self.seed
+    }
+}
+
+impl Default for SeededState {
+    fn default() -> Self {
+        SeededState::with_seed(hash_seed())
+    }
+}
+
+impl BuildHasher for SeededState {
+    type Hasher = DefaultHasher;
+
+    fn build_hasher(&self) -> DefaultHasher {
+        let mut hasher = DefaultHasher::new();
+        hasher.write_u64(self.seed);
+        hasher
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    /// The keys of `map` in bucket order, which is the order a std
+    /// `HashMap` iterates in.
+    fn layout(map: &SeededHashMap<String, u32>) -> Vec<String> {
+        map.keys().cloned().collect()
+    }
+
+    fn build(seed: u64) -> SeededHashMap<String, u32> {
+        let mut map = HashMap::with_hasher(SeededState::with_seed(seed));
+        for i in 0..500 {
+            map.insert(format!("key{}", i), i);
+        }
+        map
+    }
+
+    #[test]
+    fn same_seed_builds_the_same_bucket_layout() {
+        let (a, b) = (build(42), build(42));
+        assert_eq!(a.capacity(), b.capacity());
+        assert_eq!(layout(&a), layout(&b));
+    }
+
+    #[test]
+    fn different_seeds_build_different_bucket_layouts() {
+        assert_ne!(layout(&build(1)), layout(&build(2)));
+    }
+
+    #[test]
+    fn seed_is_the_same_for_the_whole_process() {
+        assert_eq!(hash_seed(), hash_seed());
+        assert_eq!(SeededState::default().seed(), hash_seed());
+        if let Some(seed) = FIXED_SEED {
+            assert_eq!(hash_seed().to_string(), seed);
+        }
+    }
+}
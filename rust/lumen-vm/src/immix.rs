@@ -5,6 +5,17 @@
 //! 128-byte lines. The allocator bump-allocates within lines and
 //! recycles partially-free blocks by finding holes (contiguous
 //! unmarked lines).
+//!
+//! Alignment padding depends on where a block sits in memory, so the same
+//! allocation sequence can pack differently from run to run. For the GC
+//! tests below, `ImmixAllocator::deterministic` takes its blocks in order
+//! from one block-aligned region reserved up front: every allocation lands
+//! at the same offset into the region on every run and platform, and so do
+//! the [`GcStats`] of a sequence of collections. Nothing outside the tests
+//! allocates through this module yet, so the mode is built only for them.
+
+use std::alloc::Layout;
+use std::ptr::NonNull;
 
 /// Block size in bytes (32 KiB).
 pub const BLOCK_SIZE: usize = 32 * 1024;
@@ -13,26 +24,58 @@ pub const LINE_SIZE: usize = 128;
 /// Number of lines per block.
 pub const LINES_PER_BLOCK: usize = BLOCK_SIZE / LINE_SIZE;
 
+/// Backing memory of a [`Block`].
+enum BlockMemory {
+    /// A block-sized allocation of its own.
+    Owned(Box<[u8; BLOCK_SIZE]>),
+    /// A slot of the allocator's [`BumpRegion`], which outlives the block.
+    Region(NonNull<u8>),
+}
+
 /// A single Immix block: a 32 KiB region divided into 128-byte lines.
 pub struct Block {
     /// Raw storage.
-    data: Box<[u8; BLOCK_SIZE]>,
+    data: BlockMemory,
     /// Per-line mark bitmap. `true` = line contains at least one live object.
     line_marks: [bool; LINES_PER_BLOCK],
+    /// Lines allocated into since the last sweep, or live at it.
+    used_lines: [bool; LINES_PER_BLOCK],
     /// Cached count of holes (contiguous runs of unmarked lines).
     hole_count: u32,
 }
 
+// SAFETY: a region slot is only reached through the one block that owns it,
+// and the region it points into is owned by the same allocator.
+unsafe impl Send for Block {}
+
 impl Block {
     /// Create a new zeroed block with all lines unmarked.
     pub fn new() -> Self {
+        Self::with_memory(BlockMemory::Owned(Box::new([0u8; BLOCK_SIZE])))
+    }
+
+    fn with_memory(data: BlockMemory) -> Self {
         Self {
-            data: Box::new([0u8; BLOCK_SIZE]),
+            data,
             line_marks: [false; LINES_PER_BLOCK],
+            used_lines: [false; LINES_PER_BLOCK],
             hole_count: 0,
         }
     }
 
+    fn base(&self) -> *const u8 {
+        match &self.data {
+            BlockMemory::Owned(data) => data.as_ptr(),
+            BlockMemory::Region(slot) => slot.as_ptr() as *const u8,
+        }
+    }
+
+    /// The line holding `ptr`, if `ptr` points into this block.
+    fn line_of(&self, ptr: *const u8) -> Option<usize> {
+        let offset = (ptr as usize).checked_sub(self.base() as usize)?;
+        (offset < BLOCK_SIZE).then_some(offset / LINE_SIZE)
+    }
+
     /// Mark a line as containing live data.
     ///
     /// # Panics
@@ -147,7 +190,11 @@ impl Block {
             "line index {line_idx} out of range (max {})",
             LINES_PER_BLOCK - 1
         );
-        unsafe { self.data.as_mut_ptr().add(line_idx * LINE_SIZE) }
+        let base = match &mut self.data {
+            BlockMemory::Owned(data) => data.as_mut_ptr(),
+            BlockMemory::Region(slot) => slot.as_ptr(),
+        };
+        unsafe { base.add(line_idx * LINE_SIZE) }
     }
 
     /// Clear all line marks (used when recycling a block).
@@ -163,6 +210,72 @@ impl Default for Block {
     }
 }
 
+/// A fixed run of block-aligned slots handed out in order.
+struct BumpRegion {
+    base: NonNull<u8>,
+    layout: Layout,
+    slots: usize,
+    next: usize,
+}
+
+impl BumpRegion {
+    #[cfg(test)]
+    fn new(slots: usize) -> Self {
+        assert!(slots > 0, "deterministic region needs at least one block");
+        let layout = slots
+            .checked_mul(BLOCK_SIZE)
+            .and_then(|size| Layout::from_size_align(size, BLOCK_SIZE).ok())
+            .expect("deterministic region size overflows");
+        // SAFETY: `layout` has a non-zero size.
+        let ptr = unsafe { std::alloc::alloc_zeroed(layout) };
+        let base = NonNull::new(ptr).unwrap_or_else(|| std::alloc::handle_alloc_error(layout));
+        Self {
+            base,
+            layout,
+            slots,
+            next: 0,
+        }
+    }
+
+    fn take(&mut self) -> Option<Block> {
+        if self.next == self.slots {
+            return None;
+        }
+        // SAFETY: `next < slots`, so the slot lies inside the region.
+        let slot =
+            unsafe { NonNull::new_unchecked(self.base.as_ptr().add(self.next * BLOCK_SIZE)) };
+        self.next += 1;
+        Some(Block::with_memory(BlockMemory::Region(slot)))
+    }
+
+    #[cfg(test)]
+    fn offset_of(&self, ptr: *const u8) -> Option<usize> {
+        let offset = (ptr as usize).checked_sub(self.base.as_ptr() as usize)?;
+        (offset < self.layout.size()).then_some(offset)
+    }
+}
+
+impl Drop for BumpRegion {
+    fn drop(&mut self) {
+        // SAFETY: `base` was allocated with `layout` in `new`.
+        unsafe { std::alloc::dealloc(self.base.as_ptr(), self.layout) }
+    }
+}
+
+// SAFETY: the region is plain memory owned by a single allocator.
+unsafe impl Send for BumpRegion {}
+
+/// Counters kept across the collections of an [`ImmixAllocator`].
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq)]
+pub struct GcStats {
+    /// Number of sweeps run.
+    pub collections: u64,
+    /// Bytes handed out, including alignment padding.
+    pub bytes_allocated: u64,
+    /// Bytes of lines that were in use and found dead by a sweep.
+    pub bytes_freed: u64,
+}
+
 /// Immix-style allocator managing a set of blocks.
 ///
 /// Allocation bump-allocates within lines of the current block.
@@ -182,6 +295,9 @@ pub struct ImmixAllocator {
     free_blocks: Vec<Block>,
     /// Partially-occupied blocks (have holes) available for recycling.
     recyclable_blocks: Vec<Block>,
+    /// Where fresh blocks come from in deterministic mode.
+    region: Option<BumpRegion>,
+    stats: GcStats,
 }
 
 impl ImmixAllocator {
@@ -194,9 +310,40 @@ impl ImmixAllocator {
             cursor: 0,
             free_blocks: Vec::new(),
             recyclable_blocks: Vec::new(),
+            region: None,
+            stats: GcStats::default(),
         }
     }
 
+    /// Create an allocator whose blocks come, in order, from one region of
+    /// `max_blocks` blocks reserved now, so that a given sequence of
+    /// allocations and collections behaves identically on every run.
+    ///
+    /// # Panics
+    /// Panics if `max_blocks` is 0.
+    #[cfg(test)]
+    pub(crate) fn deterministic(max_blocks: usize) -> Self {
+        let mut region = BumpRegion::new(max_blocks);
+        let first = region.take().expect("region has at least one block");
+        Self {
+            blocks: vec![first],
+            region: Some(region),
+            ..Self::new()
+        }
+    }
+
+    /// Offset of `ptr` from the start of the deterministic region; `None`
+    /// for an allocator without one or a pointer outside it.
+    #[cfg(test)]
+    pub(crate) fn region_offset(&self, ptr: *const u8) -> Option<usize> {
+        self.region.as_ref()?.offset_of(ptr)
+    }
+
+    /// Counters for the allocations and sweeps so far.
+    pub fn stats(&self) -> GcStats {
+        self.stats
+    }
+
     /// Attempt to allocate `size` bytes with the given `align`ment.
     ///
     /// Returns `Some(ptr)` on success, `None` if no space is available
@@ -225,20 +372,58 @@ impl ImmixAllocator {
     }
 
     /// Allocate and add a fresh block, making it the current block.
+    ///
+    /// # Panics
+    /// Panics if a deterministic allocator has used its whole region.
     pub fn alloc_new_block(&mut self) {
-        self.blocks.push(Block::new());
+        let block = match &mut self.region {
+            Some(region) => region.take().expect("deterministic region exhausted"),
+            None => Block::new(),
+        };
+        self.blocks.push(block);
         self.current_block = self.blocks.len() - 1;
         self.current_line = 0;
         self.cursor = 0;
     }
 
+    /// Start a collection: clear the line marks of every block that may
+    /// hold live objects, ready for [`mark_object`](Self::mark_object).
+    pub fn start_collection(&mut self) {
+        for block in self.blocks.iter_mut().chain(&mut self.recyclable_blocks) {
+            block.line_marks = [false; LINES_PER_BLOCK];
+        }
+    }
+
+    /// Mark the lines of the `size`-byte object at `ptr` as live. Returns
+    /// `false` if `ptr` is not in one of this allocator's blocks.
+    pub fn mark_object(&mut self, ptr: *const u8, size: usize) -> bool {
+        let last = (ptr as usize).saturating_add(size.max(1) - 1) as *const u8;
+        for block in self.blocks.iter_mut().chain(&mut self.recyclable_blocks) {
+            if let Some(first) = block.line_of(ptr) {
+                let end = block.line_of(last).unwrap_or(LINES_PER_BLOCK - 1);
+                for line in first..=end {
+                    block.line_marks[line] = true;
+                }
+                return true;
+            }
+        }
+        false
+    }
+
     /// Run the sweep phase: categorize blocks into free, recyclable,
     /// and fully occupied. Blocks with no live lines are moved to
     /// the free list; partially live blocks go to the recyclable list.
     pub fn sweep(&mut self) {
         let mut kept = Vec::new();
-
-        for mut block in self.blocks.drain(..) {
+        let mut recyclable = std::mem::take(&mut self.recyclable_blocks);
+        self.stats.collections += 1;
+
+        for mut block in self.blocks.drain(..).chain(recyclable.drain(..)) {
+            let freed = (0..LINES_PER_BLOCK)
+                .filter(|&i| block.used_lines[i] && !block.line_marks[i])
+                .count();
+            self.stats.bytes_freed += (freed * LINE_SIZE) as u64;
+            block.used_lines = block.line_marks;
             block.update_hole_count();
             if block.is_empty() {
                 block.clear_marks();
@@ -251,8 +436,9 @@ impl ImmixAllocator {
         }
 
         self.blocks = kept;
+        // Kept blocks are full: the next allocation takes a new block
         self.current_block = 0;
-        self.current_line = 0;
+        self.current_line = LINES_PER_BLOCK;
         self.cursor = 0;
     }
 
@@ -275,7 +461,7 @@ impl ImmixAllocator {
 
     /// Try to bump-allocate within the current line of the current block.
     fn try_alloc_in_current_line(&mut self, size: usize, align: usize) -> Option<*mut u8> {
-        if self.blocks.is_empty() {
+        if self.blocks.is_empty() || self.current_line >= LINES_PER_BLOCK {
             return None;
         }
         let block = &mut self.blocks[self.current_block];
@@ -291,6 +477,8 @@ impl ImmixAllocator {
         }
 
         self.cursor += needed;
+        block.used_lines[self.current_line] = true;
+        self.stats.bytes_allocated += needed as u64;
         Some(aligned as *mut u8)
     }
 
@@ -524,6 +712,130 @@ mod tests {
         assert_eq!(alloc.recyclable_block_count(), 0);
     }
 
+    #[test]
+    fn test_sweep_does_not_reuse_a_full_live_block() {
+        let mut alloc = ImmixAllocator::new();
+        let live: Vec<_> = (0..LINES_PER_BLOCK)
+            .map(|_| alloc.alloc(LINE_SIZE, 8).unwrap())
+            .collect();
+        alloc.start_collection();
+        for &ptr in &live {
+            assert!(alloc.mark_object(ptr, LINE_SIZE));
+        }
+        alloc.sweep();
+        assert_eq!(alloc.active_block_count(), 1);
+        // Every line is live, so there is nowhere to put another object
+        assert_eq!(alloc.alloc(16, 8), None);
+        alloc.alloc_new_block();
+        let fresh = alloc.alloc(16, 8).unwrap();
+        assert!(!live.contains(&fresh));
+    }
+
+    #[test]
+    fn test_sweep_frees_recyclable_blocks_that_died() {
+        let mut alloc = ImmixAllocator::new();
+        let live = alloc.alloc(64, 8).unwrap();
+        alloc.start_collection();
+        assert!(alloc.mark_object(live, 64));
+        alloc.sweep();
+        assert_eq!(alloc.recyclable_block_count(), 1);
+
+        // The object dies before the block is reused; the next sweep must
+        // still look at the block and free it
+        alloc.start_collection();
+        alloc.sweep();
+        assert_eq!(alloc.recyclable_block_count(), 0);
+        assert_eq!(alloc.free_block_count(), 1);
+        assert_eq!(alloc.stats().bytes_freed, LINE_SIZE as u64);
+    }
+
+    // --- Deterministic mode ---
+
+    /// Allocate a node, taking a new block when the current ones are full.
+    fn alloc_node(alloc: &mut ImmixAllocator) -> *mut u8 {
+        alloc.alloc(48, 8).unwrap_or_else(|| {
+            alloc.alloc_new_block();
+            alloc.alloc(48, 8).unwrap()
+        })
+    }
+
+    /// Nodes of a complete binary tree of `depth` levels, allocated
+    /// depth-first.
+    fn build_tree(alloc: &mut ImmixAllocator, depth: u32, out: &mut Vec<*mut u8>) {
+        out.push(alloc_node(alloc));
+        if depth > 1 {
+            build_tree(alloc, depth - 1, out);
+            build_tree(alloc, depth - 1, out);
+        }
+    }
+
+    /// A binary-trees GC stress run: a long-lived tree survives while
+    /// short-lived trees are built and collected. Returns the stats and the
+    /// region offsets of the long-lived nodes.
+    fn tree_stress() -> (GcStats, Vec<usize>) {
+        let mut alloc = ImmixAllocator::deterministic(64);
+        let mut long_lived = Vec::new();
+        build_tree(&mut alloc, 8, &mut long_lived);
+        for depth in [4, 6, 8, 10, 6, 4] {
+            let mut short_lived = Vec::new();
+            build_tree(&mut alloc, depth, &mut short_lived);
+            alloc.start_collection();
+            for &node in &long_lived {
+                assert!(alloc.mark_object(node, 48));
+            }
+            alloc.sweep();
+        }
+        let offsets = long_lived
+            .iter()
+            .map(|&node| alloc.region_offset(node).unwrap())
+            .collect();
+        (alloc.stats(), offsets)
+    }
+
+    #[test]
+    fn test_deterministic_tree_stress_is_reproducible() {
+        let (first, first_offsets) = tree_stress();
+        let (second, second_offsets) = tree_stress();
+        assert_eq!(first, second);
+        assert_eq!(first_offsets, second_offsets);
+        assert_eq!(first.collections, 6);
+        assert!(first.bytes_freed > 0);
+        // Nodes are 48 bytes with 8-byte alignment from a block-aligned base
+        assert_eq!(first_offsets[..3], [0, 48, 128]);
+    }
+
+    #[test]
+    fn test_sweep_frees_dead_lines_and_keeps_live_ones() {
+        let mut alloc = ImmixAllocator::deterministic(4);
+        let live = alloc.alloc(64, 8).unwrap();
+        let _dead = alloc.alloc(64, 8).unwrap();
+        let _dead_next_line = alloc.alloc(64, 8).unwrap();
+        alloc.start_collection();
+        assert!(alloc.mark_object(live, 64));
+        alloc.sweep();
+        assert_eq!(alloc.stats().bytes_freed, LINE_SIZE as u64);
+        assert_eq!(alloc.recyclable_block_count(), 1);
+
+        // The live line is skipped when the block is reused
+        let reused = alloc.alloc(64, 8).unwrap();
+        assert_eq!(alloc.region_offset(reused), Some(LINE_SIZE));
+    }
+
+    #[test]
+    fn test_region_offset_is_none_outside_deterministic_mode() {
+        let mut alloc = ImmixAllocator::new();
+        let ptr = alloc.alloc(16, 8).unwrap();
+        assert_eq!(alloc.region_offset(ptr), None);
+        assert!(!alloc.mark_object(std::ptr::null(), 16));
+    }
+
+    #[test]
+    #[should_panic(expected = "deterministic region exhausted")]
+    fn test_deterministic_region_is_bounded() {
+        let mut alloc = ImmixAllocator::deterministic(1);
+        alloc.alloc_new_block();
+    }
+
     #[test]
     fn test_constants() {
         assert_eq!(BLOCK_SIZE, 32 * 1024);
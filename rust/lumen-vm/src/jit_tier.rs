@@ -39,6 +39,10 @@ pub struct JitTierConfig {
     pub opt_level: JitOptLevel,
     /// Whether JIT is enabled at all.
     pub enabled: bool,
+    /// Fuse float multiply-adds on targets with hardware FMA. Fused results
+    /// can differ from the interpreter's in the last bit, so this is off by
+    /// default and compiled cells reproduce the interpreter bit for bit.
+    pub fuse_multiply_add: bool,
 }
 
 /// Mirror of codegen OptLevel so the VM crate doesn't leak codegen types
@@ -56,6 +60,7 @@ impl Default for JitTierConfig {
             hot_threshold: 10,
             opt_level: JitOptLevel::Speed,
             enabled: true,
+            fuse_multiply_add: false,
         }
     }
 }
@@ -208,6 +213,7 @@ impl JitTier {
             let settings = CodegenSettings {
                 opt_level: opt,
                 target: None,
+                fuse_multiply_add: self.config.fuse_multiply_add,
             };
 
             // Create a new engine each time (Cranelift JITModule doesn't support
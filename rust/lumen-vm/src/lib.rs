@@ -2,7 +2,9 @@
 #![warn(clippy::all)]
 
 pub mod arena;
+pub mod build_info;
 pub mod gc;
+pub mod hash_seed;
 pub mod immix;
 pub mod jit_tier;
 pub mod parity_concurrency;
@@ -11,4 +13,5 @@ pub mod tagged;
 pub mod tlab;
 pub mod types;
 pub mod values;
+pub mod vecops;
 pub mod vm;
@@ -1,12 +1,22 @@
 //! String interning table for fast comparisons.
 
-use std::collections::HashMap;
+use std::sync::Arc;
+
+use crate::hash_seed::SeededHashMap;
 
 /// Intern table mapping strings to unique IDs.
+///
+/// Each distinct string is stored once, shared by the ID list and the
+/// lookup map, so two interned copies of the same text are the same
+/// allocation and compare by ID. IDs are handed out in order of first
+/// interning, so a program that interns the same strings in the same order
+/// sees the same IDs on every run. The lookup map hashes with the
+/// process's [`hash_seed`](crate::hash_seed::hash_seed), which only decides
+/// its buckets, never an ID.
 #[derive(Debug, Default)]
 pub struct StringTable {
-    strings: Vec<String>,
-    lookup: HashMap<String, u32>,
+    strings: Vec<Arc<str>>,
+    lookup: SeededHashMap<Arc<str>, u32>,
 }
 
 impl StringTable {
@@ -19,13 +29,19 @@ impl StringTable {
             return id;
         }
         let id = self.strings.len() as u32;
-        self.strings.push(s.to_string());
-        self.lookup.insert(s.to_string(), id);
+        let shared: Arc<str> = Arc::from(s);
+        self.strings.push(Arc::clone(&shared));
+        self.lookup.insert(shared, id);
         id
     }
 
+    /// The ID of `s` if it has been interned, without interning it.
+    pub fn get(&self, s: &str) -> Option<u32> {
+        self.lookup.get(s).copied()
+    }
+
     pub fn resolve(&self, id: u32) -> Option<&str> {
-        self.strings.get(id as usize).map(|s| s.as_str())
+        self.strings.get(id as usize).map(|s| &**s)
     }
 
     pub fn len(&self) -> usize {
@@ -50,4 +66,40 @@ mod tests {
         assert_ne!(id1, id2);
         assert_eq!(table.resolve(id1), Some("hello"));
     }
+
+    #[test]
+    fn test_intern_shares_one_copy() {
+        let mut table = StringTable::new();
+        let built = ["ident", "ifier"].concat();
+        let a = table.intern(&built);
+        let b = table.intern("identifier");
+        assert_eq!(a, b);
+        assert!(std::ptr::eq(
+            table.resolve(a).unwrap(),
+            table.resolve(b).unwrap()
+        ));
+        assert_eq!(table.len(), 1);
+        assert_eq!(table.get("identifier"), Some(a));
+        assert_eq!(table.get("other"), None);
+        assert_eq!(table.len(), 1);
+    }
+
+    #[test]
+    fn test_intern_distinct_strings_never_collide() {
+        let mut table = StringTable::new();
+        let words: Vec<String> = (0..1000).map(|i| format!("name_{}", i)).collect();
+        let ids: Vec<u32> = words.iter().map(|w| table.intern(w)).collect();
+        // Ids follow first-intern order, and each resolves to its own text
+        assert_eq!(ids, (0..1000).collect::<Vec<u32>>());
+        for (word, id) in words.iter().zip(&ids) {
+            assert_eq!(table.resolve(*id), Some(word.as_str()));
+        }
+        // Prefixes, case, and the empty string are all distinct
+        let more = ["", "name", "name_", "Name_1", "name_1 "];
+        for s in more {
+            let id = table.intern(s);
+            assert!(id >= 1000, "{:?} collided with id {}", s, id);
+        }
+        assert_eq!(table.len(), 1000 + more.len());
+    }
 }
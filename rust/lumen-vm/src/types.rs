@@ -1,5 +1,7 @@
 //! Runtime type registry for schema validation.
 
+use crate::values::Value;
+use lumen_compiler::compiler::lir::JsonField;
 use std::collections::HashMap;
 
 #[derive(Debug, Clone)]
@@ -18,6 +20,9 @@ pub enum RuntimeTypeKind {
 pub struct RuntimeField {
     pub name: String,
     pub ty: String,
+    /// The declared default, when it is a literal
+    pub default: Option<Value>,
+    pub json: Option<JsonField>,
 }
 
 #[derive(Debug, Clone)]
@@ -43,4 +48,16 @@ impl TypeTable {
     pub fn get(&self, name: &str) -> Option<&RuntimeType> {
         self.types.get(name)
     }
+
+    /// The enum declaring `variant`, when exactly one registered enum does.
+    pub fn enum_of_variant(&self, variant: &str) -> Option<&str> {
+        let mut owners = self.types.values().filter(|t| match &t.kind {
+            RuntimeTypeKind::Enum(variants) => variants.iter().any(|v| v.name == variant),
+            RuntimeTypeKind::Record(_) => false,
+        });
+        match (owners.next(), owners.next()) {
+            (Some(ty), None) => Some(&ty.name),
+            _ => None,
+        }
+    }
 }
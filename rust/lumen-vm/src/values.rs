@@ -7,7 +7,7 @@ use serde::{Deserialize, Serialize};
 use std::cmp::Ordering;
 use std::collections::{BTreeMap, BTreeSet};
 use std::fmt;
-use std::sync::Arc;
+use std::sync::{Arc, Weak};
 
 /// Runtime values in the Lumen VM.
 ///
@@ -44,6 +44,7 @@ pub enum Value {
     Closure(ClosureValue),
     TraceRef(TraceRefValue),
     Future(FutureValue),
+    Weak(WeakValue),
 }
 
 /// A string reference (interned ID or owned)
@@ -56,7 +57,164 @@ pub enum StringRef {
 #[derive(Debug, Clone, Serialize, Deserialize)]
 pub struct RecordValue {
     pub type_name: String,
-    pub fields: BTreeMap<String, Value>,
+    pub fields: FieldMap,
+}
+
+/// The fields of a record, kept sorted by name.
+///
+/// Lookups by name binary-search the names; `get_index` reads the field at a
+/// position directly, which is how the VM's field-read caches skip the
+/// search. Iteration, equality and the serialized form are those of a
+/// `BTreeMap<String, Value>` with the same entries.
+#[derive(Clone, Default, PartialEq)]
+pub struct FieldMap {
+    entries: Vec<(String, Value)>,
+}
+
+impl FieldMap {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// The position of field `name` in name order.
+    #[inline]
+    pub fn position(&self, name: &str) -> Option<usize> {
+        self.entries
+            .binary_search_by(|(k, _)| k.as_str().cmp(name))
+            .ok()
+    }
+
+    /// The field at `position` in name order.
+    #[inline]
+    pub fn get_index(&self, position: usize) -> Option<(&String, &Value)> {
+        self.entries.get(position).map(|(k, v)| (k, v))
+    }
+
+    #[inline]
+    pub fn get(&self, name: &str) -> Option<&Value> {
+        self.position(name).map(|i| &self.entries[i].1)
+    }
+
+    pub fn get_mut(&mut self, name: &str) -> Option<&mut Value> {
+        self.position(name).map(|i| &mut self.entries[i].1)
+    }
+
+    pub fn contains_key(&self, name: &str) -> bool {
+        self.position(name).is_some()
+    }
+
+    /// Set field `name`, returning the value it replaced.
+    pub fn insert(&mut self, name: String, value: Value) -> Option<Value> {
+        match self
+            .entries
+            .binary_search_by(|(k, _)| k.as_str().cmp(&name))
+        {
+            Ok(i) => Some(std::mem::replace(&mut self.entries[i].1, value)),
+            Err(i) => {
+                self.entries.insert(i, (name, value));
+                None
+            }
+        }
+    }
+
+    pub fn remove(&mut self, name: &str) -> Option<Value> {
+        self.position(name).map(|i| self.entries.remove(i).1)
+    }
+
+    pub fn len(&self) -> usize {
+        self.entries.len()
+    }
+
+    pub fn is_empty(&self) -> bool {
+        self.entries.is_empty()
+    }
+
+    pub fn iter(&self) -> FieldIter<'_> {
+        FieldIter(self.entries.iter())
+    }
+
+    pub fn keys(&self) -> impl DoubleEndedIterator<Item = &String> + ExactSizeIterator {
+        self.entries.iter().map(|(k, _)| k)
+    }
+
+    pub fn values(&self) -> impl DoubleEndedIterator<Item = &Value> + ExactSizeIterator {
+        self.entries.iter().map(|(_, v)| v)
+    }
+}
+
+/// Borrowing iterator over a [`FieldMap`] in name order.
+pub struct FieldIter<'a>(std::slice::Iter<'a, (String, Value)>);
+
+impl<'a> Iterator for FieldIter<'a> {
+    type Item = (&'a String, &'a Value);
+
+    #[inline]
+    fn next(&mut self) -> Option<Self::Item> {
+        self.0.next().map(|(k, v)| (k, v))
+    }
+
+    fn size_hint(&self) -> (usize, Option<usize>) {
+        self.0.size_hint()
+    }
+}
+
+impl DoubleEndedIterator for FieldIter<'_> {
+    fn next_back(&mut self) -> Option<Self::Item> {
+        self.0.next_back().map(|(k, v)| (k, v))
+    }
+}
+
+impl ExactSizeIterator for FieldIter<'_> {}
+
+impl<'a> IntoIterator for &'a FieldMap {
+    type Item = (&'a String, &'a Value);
+    type IntoIter = FieldIter<'a>;
+
+    fn into_iter(self) -> Self::IntoIter {
+        self.iter()
+    }
+}
+
+impl IntoIterator for FieldMap {
+    type Item = (String, Value);
+    type IntoIter = std::vec::IntoIter<(String, Value)>;
+
+    fn into_iter(self) -> Self::IntoIter {
+        self.entries.into_iter()
+    }
+}
+
+/// A later entry for a name replaces an earlier one, as in a `BTreeMap`.
+impl FromIterator<(String, Value)> for FieldMap {
+    fn from_iter<I: IntoIterator<Item = (String, Value)>>(iter: I) -> Self {
+        iter.into_iter().collect::<BTreeMap<_, _>>().into()
+    }
+}
+
+impl From<BTreeMap<String, Value>> for FieldMap {
+    fn from(map: BTreeMap<String, Value>) -> Self {
+        Self {
+            entries: map.into_iter().collect(),
+        }
+    }
+}
+
+impl fmt::Debug for FieldMap {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        f.debug_map().entries(self.iter()).finish()
+    }
+}
+
+impl Serialize for FieldMap {
+    fn serialize<S: serde::Serializer>(&self, serializer: S) -> Result<S::Ok, S::Error> {
+        serializer.collect_map(self.iter())
+    }
+}
+
+impl<'de> Deserialize<'de> for FieldMap {
+    fn deserialize<D: serde::Deserializer<'de>>(deserializer: D) -> Result<Self, D::Error> {
+        BTreeMap::<String, Value>::deserialize(deserializer).map(FieldMap::from)
+    }
 }
 
 #[derive(Debug, Clone)]
@@ -111,6 +269,59 @@ pub struct FutureValue {
     pub state: FutureStatus,
 }
 
+/// A reference that does not keep its target alive, made by `weak(v)`.
+///
+/// The target is freed once its last strong reference goes; after that
+/// `upgrade` returns None. Updating a value in place copies it if only weak
+/// references share it, so a weak reference observes the version it was
+/// taken from.
+#[derive(Debug, Clone)]
+pub enum WeakValue {
+    List(Weak<Vec<Value>>),
+    Tuple(Weak<Vec<Value>>),
+    Set(Weak<BTreeSet<Value>>),
+    Map(Weak<BTreeMap<String, Value>>),
+    Record(Weak<RecordValue>),
+}
+
+impl WeakValue {
+    /// The target, if it is still alive.
+    pub fn upgrade(&self) -> Option<Value> {
+        match self {
+            WeakValue::List(w) => w.upgrade().map(Value::List),
+            WeakValue::Tuple(w) => w.upgrade().map(Value::Tuple),
+            WeakValue::Set(w) => w.upgrade().map(Value::Set),
+            WeakValue::Map(w) => w.upgrade().map(Value::Map),
+            WeakValue::Record(w) => w.upgrade().map(Value::Record),
+        }
+    }
+
+    /// Address of the target, which identifies it while it is alive.
+    pub(crate) fn addr(&self) -> usize {
+        match self {
+            WeakValue::List(w) | WeakValue::Tuple(w) => w.as_ptr() as usize,
+            WeakValue::Set(w) => w.as_ptr() as usize,
+            WeakValue::Map(w) => w.as_ptr() as usize,
+            WeakValue::Record(w) => w.as_ptr() as usize,
+        }
+    }
+}
+
+// A weak reference cannot outlive the process that made it, so it is
+// serialized as a unit and deserialized as one that never upgrades.
+impl Serialize for WeakValue {
+    fn serialize<S: serde::Serializer>(&self, serializer: S) -> Result<S::Ok, S::Error> {
+        serializer.serialize_unit_struct("WeakValue")
+    }
+}
+
+impl<'de> Deserialize<'de> for WeakValue {
+    fn deserialize<D: serde::Deserializer<'de>>(deserializer: D) -> Result<Self, D::Error> {
+        serde::de::IgnoredAny::deserialize(deserializer)?;
+        Ok(WeakValue::Record(Weak::new()))
+    }
+}
+
 #[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
 pub enum FutureStatus {
     Pending,
@@ -145,6 +356,18 @@ impl Value {
         Value::Record(Arc::new(r))
     }
 
+    /// A weak reference to this value; None for values not held on the heap.
+    pub fn downgrade(&self) -> Option<WeakValue> {
+        match self {
+            Value::List(l) => Some(WeakValue::List(Arc::downgrade(l))),
+            Value::Tuple(t) => Some(WeakValue::Tuple(Arc::downgrade(t))),
+            Value::Set(s) => Some(WeakValue::Set(Arc::downgrade(s))),
+            Value::Map(m) => Some(WeakValue::Map(Arc::downgrade(m))),
+            Value::Record(r) => Some(WeakValue::Record(Arc::downgrade(r))),
+            _ => None,
+        }
+    }
+
     pub fn is_truthy(&self) -> bool {
         match self {
             Value::Null => false,
@@ -224,6 +447,10 @@ impl Value {
             ),
             Value::TraceRef(t) => format!("<trace:{}:{}>", t.trace_id, t.seq),
             Value::Future(f) => format!("<future:{}:{}>", f.id, future_status_name(f.state)),
+            Value::Weak(w) => match w.upgrade() {
+                Some(_) => "<weak>".to_string(),
+                None => "<weak:dropped>".to_string(),
+            },
         }
     }
 
@@ -308,7 +535,7 @@ impl Value {
     }
 
     /// Return a numeric discriminant for type ordering.
-    /// Order: Null < Bool < Int < Float < String < Bytes < List < Tuple < Set < Map < Record < Union < Closure < TraceRef
+    /// Order: Null < Bool < Int < Float < String < Bytes < List < Tuple < Set < Map < Record < Union < Closure < TraceRef < Future < Weak
     fn type_order(&self) -> u8 {
         match self {
             Value::Null => 0,
@@ -325,6 +552,7 @@ impl Value {
             Value::Closure(_) => 12,
             Value::TraceRef(_) => 13,
             Value::Future(_) => 14,
+            Value::Weak(_) => 15,
         }
     }
 
@@ -348,6 +576,7 @@ impl Value {
             Value::Closure(_) => 13,
             Value::TraceRef(_) => 14,
             Value::Future(_) => 15,
+            Value::Weak(_) => 16,
         }
     }
 
@@ -372,6 +601,7 @@ impl Value {
             Value::Closure(_) => "Closure",
             Value::TraceRef(_) => "TraceRef",
             Value::Future(_) => "Future",
+            Value::Weak(_) => "Weak",
         }
     }
 
@@ -447,12 +677,26 @@ impl Value {
     }
 }
 
-/// Format a float nicely (avoid unnecessary trailing zeros but keep at least one decimal)
+/// Format a float with the fewest digits that parse back to exactly the same
+/// value. The digits come from core's shortest round-trip formatter (Grisu
+/// with an exact Dragon fallback); this only decides the layout.
+///
+/// Magnitudes in `[1e-4, 1e16)` print positionally, integral values keeping
+/// a trailing `.0`; smaller and larger ones use exponent form (`1e20`,
+/// `2.5e-7`), so no value prints as a long run of zeros.
 fn format_float(f: f64) -> String {
-    if f == f.floor() && f.abs() < 1e15 {
-        format!("{:.1}", f)
+    if !f.is_finite() {
+        return format!("{}", f);
+    }
+    let abs = f.abs();
+    if abs != 0.0 && !(1e-4..1e16).contains(&abs) {
+        return format!("{:e}", f);
+    }
+    let s = format!("{}", f);
+    if s.contains('.') {
+        s
     } else {
-        format!("{}", f)
+        s + ".0"
     }
 }
 
@@ -505,6 +749,7 @@ impl PartialEq for Value {
             }
             (Value::TraceRef(a), Value::TraceRef(b)) => a.trace_id == b.trace_id && a.seq == b.seq,
             (Value::Future(a), Value::Future(b)) => a.id == b.id && a.state == b.state,
+            (Value::Weak(a), Value::Weak(b)) => a.addr() == b.addr(),
             _ => false,
         }
     }
@@ -515,6 +760,9 @@ impl Eq for Value {}
 /// Compare two values for equality, resolving interned strings via the provided
 /// `StringTable`. This enables correct cross-representation string equality
 /// (interned vs owned) at all nesting depths (lists, maps, records, etc.).
+///
+/// Floats compare as IEEE 754 numbers, as the language's `==` requires: NaN
+/// is unequal to everything including itself, and `-0.0 == 0.0`.
 pub fn values_equal(a: &Value, b: &Value, strings: &StringTable) -> bool {
     match (a, b) {
         (Value::Null, Value::Null) => true,
@@ -523,15 +771,11 @@ pub fn values_equal(a: &Value, b: &Value, strings: &StringTable) -> bool {
         (Value::BigInt(x), Value::BigInt(y)) => x == y,
         (Value::Int(x), Value::BigInt(y)) => BigInt::from(*x) == *y,
         (Value::BigInt(x), Value::Int(y)) => *x == BigInt::from(*y),
-        (Value::Float(x), Value::Float(y)) => x.to_bits() == y.to_bits(),
-        (Value::BigInt(x), Value::Float(y)) => x
-            .to_f64()
-            .map(|f| f.to_bits() == y.to_bits())
-            .unwrap_or(false),
-        (Value::Float(x), Value::BigInt(y)) => y
-            .to_f64()
-            .map(|f| f.to_bits() == x.to_bits())
-            .unwrap_or(false),
+        (Value::Float(x), Value::Float(y)) => x == y,
+        (Value::BigInt(x), Value::Float(y)) => x.to_f64().map(|f| f == *y).unwrap_or(false),
+        (Value::Float(x), Value::BigInt(y)) => y.to_f64().map(|f| f == *x).unwrap_or(false),
+        // The table holds each string once, so equal ids mean equal text
+        (Value::String(StringRef::Interned(x)), Value::String(StringRef::Interned(y))) => x == y,
         (Value::String(sa), Value::String(sb)) => {
             let left = match sa {
                 StringRef::Owned(s) => s.as_str(),
@@ -588,6 +832,7 @@ pub fn values_equal(a: &Value, b: &Value, strings: &StringTable) -> bool {
         }
         (Value::TraceRef(x), Value::TraceRef(y)) => x.trace_id == y.trace_id && x.seq == y.seq,
         (Value::Future(x), Value::Future(y)) => x.id == y.id && x.state == y.state,
+        (Value::Weak(x), Value::Weak(y)) => x.addr() == y.addr(),
         _ => false,
     }
 }
@@ -611,7 +856,7 @@ impl Ord for Value {
             (Value::Bool(a), Value::Bool(b)) => a.cmp(b),
             (Value::Int(a), Value::Int(b)) => a.cmp(b),
             (Value::BigInt(a), Value::BigInt(b)) => a.cmp(b),
-            (Value::Float(a), Value::Float(b)) => a.total_cmp(b),
+            (Value::Float(a), Value::Float(b)) => float_total_order(*a, *b),
             (Value::String(a), Value::String(b)) => match (a, b) {
                 (StringRef::Owned(sa), StringRef::Owned(sb)) => sa.cmp(sb),
                 (StringRef::Interned(ida), StringRef::Interned(idb)) => ida.cmp(idb),
@@ -667,6 +912,7 @@ impl Ord for Value {
                 a.id.cmp(&b.id)
                     .then_with(|| future_status_ord(a.state).cmp(&future_status_ord(b.state)))
             }
+            (Value::Weak(a), Value::Weak(b)) => a.addr().cmp(&b.addr()),
             _ => {
                 // Same type_order but different variants - order by variant index
                 // This ensures deterministic ordering for types like Int vs Float
@@ -685,6 +931,19 @@ fn future_status_name(state: FutureStatus) -> &'static str {
     }
 }
 
+/// The order `sort` puts floats in: ascending, `-0.0` before `0.0`, and
+/// every NaN last whatever its sign bit, so a NaN computed at run time
+/// (often negative) lands beside the `NAN` constant. NaNs order among
+/// themselves by bit pattern, which keeps the order consistent with `Eq`.
+pub fn float_total_order(a: f64, b: f64) -> Ordering {
+    match (a.is_nan(), b.is_nan()) {
+        (false, false) => a.total_cmp(&b),
+        (false, true) => Ordering::Less,
+        (true, false) => Ordering::Greater,
+        (true, true) => a.to_bits().cmp(&b.to_bits()),
+    }
+}
+
 fn future_status_ord(state: FutureStatus) -> u8 {
     match state {
         FutureStatus::Pending => 0,
@@ -726,7 +985,7 @@ mod tests {
 
     #[test]
     fn test_display_pretty_record() {
-        let mut fields = BTreeMap::new();
+        let mut fields = FieldMap::new();
         fields.insert(
             "name".to_string(),
             Value::String(StringRef::Owned("Alice".into())),
@@ -739,6 +998,49 @@ mod tests {
         assert_eq!(v.display_pretty(), "Person(age: 30, name: \"Alice\")");
     }
 
+    #[test]
+    fn test_format_float_shortest_layout() {
+        let cases = [
+            (0.1, "0.1"),
+            (0.1 + 0.2, "0.30000000000000004"),
+            (3.0, "3.0"),
+            (-0.0, "-0.0"),
+            (1e15, "1000000000000000.0"),
+            (1e16, "1e16"),
+            (1e20, "1e20"),
+            (123456.789, "123456.789"),
+            (0.0001, "0.0001"),
+            (0.00001, "1e-5"),
+            (-2.5e-7, "-2.5e-7"),
+            (5e-324, "5e-324"),
+            (f64::MAX, "1.7976931348623157e308"),
+            (f64::INFINITY, "inf"),
+        ];
+        for (f, expected) in cases {
+            assert_eq!(format_float(f), expected, "formatting {:?}", f);
+        }
+    }
+
+    #[test]
+    fn test_format_float_round_trips() {
+        // xorshift64: deterministic sample over all bit patterns
+        let mut state: u64 = 0x9E37_79B9_7F4A_7C15;
+        let mut checked = 0;
+        while checked < 100_000 {
+            state ^= state << 13;
+            state ^= state >> 7;
+            state ^= state << 17;
+            let f = f64::from_bits(state);
+            if !f.is_finite() {
+                continue;
+            }
+            let s = format_float(f);
+            let back: f64 = s.parse().unwrap_or_else(|_| panic!("cannot parse {}", s));
+            assert_eq!(back.to_bits(), f.to_bits(), "{} does not round-trip", s);
+            checked += 1;
+        }
+    }
+
     #[test]
     fn test_truthiness() {
         assert!(!Value::Null.is_truthy());
@@ -803,6 +1105,58 @@ mod tests {
         assert_eq!(nan_b.cmp(&nan_a), Ordering::Greater);
     }
 
+    #[test]
+    fn test_float_order_puts_nans_last_regardless_of_sign() {
+        let negative_nan = f64::from_bits(0xfff8_0000_0000_0000);
+        let mut values = vec![
+            Value::Float(2.0),
+            Value::Float(f64::NAN),
+            Value::Float(f64::NEG_INFINITY),
+            Value::Float(negative_nan),
+            Value::Float(0.0),
+            Value::Float(-0.0),
+            Value::Float(f64::INFINITY),
+        ];
+        values.sort();
+        let bits: Vec<u64> = values
+            .iter()
+            .map(|v| match v {
+                Value::Float(f) => f.to_bits(),
+                other => panic!("expected float, got {:?}", other),
+            })
+            .collect();
+        let expected: Vec<u64> = [
+            f64::NEG_INFINITY,
+            -0.0,
+            0.0,
+            2.0,
+            f64::INFINITY,
+            f64::NAN,
+            negative_nan,
+        ]
+        .iter()
+        .map(|f| f.to_bits())
+        .collect();
+        assert_eq!(bits, expected);
+    }
+
+    #[test]
+    fn test_values_equal_follows_ieee_for_floats() {
+        let strings = StringTable::new();
+        let nan = Value::Float(f64::NAN);
+        assert!(!values_equal(&nan, &nan, &strings));
+        assert!(values_equal(
+            &Value::Float(-0.0),
+            &Value::Float(0.0),
+            &strings
+        ));
+        assert!(!values_equal(
+            &Value::new_list(vec![nan.clone()]),
+            &Value::new_list(vec![nan]),
+            &strings
+        ));
+    }
+
     #[test]
     fn test_float_equality_normal() {
         assert_eq!(Value::Float(1.5), Value::Float(1.5));
@@ -949,6 +1303,40 @@ mod tests {
         ));
     }
 
+    // ── Weak references ──────────────────────────────────────────────
+
+    #[test]
+    fn test_weak_upgrades_while_target_is_alive() {
+        let node = Value::new_record(RecordValue {
+            type_name: "Node".into(),
+            fields: FieldMap::from_iter([("value".to_string(), Value::Int(1))]),
+        });
+        let weak = node.downgrade().expect("records can be weakly referenced");
+        assert_eq!(weak.upgrade(), Some(node.clone()));
+        // A clone elsewhere keeps the target alive
+        let held = node.clone();
+        drop(node);
+        assert_eq!(weak.upgrade(), Some(held));
+    }
+
+    #[test]
+    fn test_weak_does_not_keep_target_alive() {
+        let list = Value::new_list((0..1000).map(Value::Int).collect());
+        let weak = list.downgrade().unwrap();
+        assert_eq!(Value::Weak(weak.clone()).display_pretty(), "<weak>");
+        drop(list);
+        assert_eq!(weak.upgrade(), None);
+        assert_eq!(Value::Weak(weak).display_pretty(), "<weak:dropped>");
+    }
+
+    #[test]
+    fn test_weak_of_scalar_is_none() {
+        assert!(Value::Int(3).downgrade().is_none());
+        assert!(Value::String(StringRef::Owned("s".into()))
+            .downgrade()
+            .is_none());
+    }
+
     // ── T014: scalar copy optimization verification ──────────────────
 
     #[test]
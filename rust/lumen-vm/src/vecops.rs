@@ -0,0 +1,382 @@
+//! Bulk float64 kernels behind the `float_vec_*` builtins.
+//!
+//! Each kernel has a scalar version in [`scalar`] and a dispatching version
+//! that uses 256-bit AVX lanes when the running x86_64 CPU supports them,
+//! falling back to the scalar loop everywhere else. The vector paths do the
+//! same IEEE operations in the same order as the scalar ones — `triad` is a
+//! multiply then an add, never a fused multiply-add — so every non-NaN result
+//! is bit-identical. A NaN result is NaN on both paths, but its sign and
+//! payload are not: Rust leaves those unspecified, and the optimizer may fold
+//! `0.0 * inf` in the scalar loop to a different NaN than the CPU produces.
+//!
+//! `recip` and `rsqrt` divide and take square roots, which IEEE 754 rounds
+//! correctly on both paths: `recip` is within half an ulp of `1 / a[i]`, and
+//! `rsqrt`, which rounds once for the root and once for the quotient, within
+//! 1.5 ulp of `1 / √a[i]`.
+//!
+//! All kernels require their operands to have the same length.
+//!
+//! The reductions, [`sum`] and [`compensated_sum`], never use vector lanes:
+//! splitting a sum across lanes changes where it rounds. Both add strictly
+//! from left to right, so a checksum is the same on every CPU and matches a
+//! plain loop in any other language.
+
+/// Name of the vector path the dispatching kernels use on this CPU.
+pub fn kernel_name() -> &'static str {
+    if avx_available() {
+        "avx"
+    } else {
+        "scalar"
+    }
+}
+
+/// `dst[i] = a[i] + b[i]`
+pub fn add(dst: &mut [f64], a: &[f64], b: &[f64]) {
+    check_lengths(dst, a, b);
+    #[cfg(target_arch = "x86_64")]
+    if avx_available() {
+        // SAFETY: AVX support was just checked at runtime.
+        unsafe { avx::add(dst, a, b) };
+        return;
+    }
+    scalar::add(dst, a, b);
+}
+
+/// `dst[i] = a[i] * b[i]`
+pub fn mul(dst: &mut [f64], a: &[f64], b: &[f64]) {
+    check_lengths(dst, a, b);
+    #[cfg(target_arch = "x86_64")]
+    if avx_available() {
+        // SAFETY: AVX support was just checked at runtime.
+        unsafe { avx::mul(dst, a, b) };
+        return;
+    }
+    scalar::mul(dst, a, b);
+}
+
+/// `dst[i] = a[i] + s * b[i]`, the STREAM triad.
+pub fn triad(dst: &mut [f64], a: &[f64], b: &[f64], s: f64) {
+    check_lengths(dst, a, b);
+    #[cfg(target_arch = "x86_64")]
+    if avx_available() {
+        // SAFETY: AVX support was just checked at runtime.
+        unsafe { avx::triad(dst, a, b, s) };
+        return;
+    }
+    scalar::triad(dst, a, b, s);
+}
+
+/// `dst[i] = 1 / a[i]`
+pub fn recip(dst: &mut [f64], a: &[f64]) {
+    check_lengths(dst, a, a);
+    #[cfg(target_arch = "x86_64")]
+    if avx_available() {
+        // SAFETY: AVX support was just checked at runtime.
+        unsafe { avx::recip(dst, a) };
+        return;
+    }
+    scalar::recip(dst, a);
+}
+
+/// `dst[i] = 1 / sqrt(a[i])`, the inverse distance in n-body force loops.
+pub fn rsqrt(dst: &mut [f64], a: &[f64]) {
+    check_lengths(dst, a, a);
+    #[cfg(target_arch = "x86_64")]
+    if avx_available() {
+        // SAFETY: AVX support was just checked at runtime.
+        unsafe { avx::rsqrt(dst, a) };
+        return;
+    }
+    scalar::rsqrt(dst, a);
+}
+
+/// `((0.0 + a[0]) + a[1]) + ...`, rounding after every addition.
+pub fn sum(a: &[f64]) -> f64 {
+    a.iter().fold(0.0, |total, x| total + x)
+}
+
+/// The left-to-right sum of `a`, with the rounding error of each addition
+/// carried in a second accumulator and added back at the end (Neumaier's
+/// form of Kahan summation). The error stays within a few ulp of the exact
+/// sum however many terms cancel, where [`sum`] can lose every digit. A
+/// NaN or infinite term gives the same result as in [`sum`].
+pub fn compensated_sum(a: &[f64]) -> f64 {
+    let mut total = 0.0;
+    let mut compensation = 0.0;
+    for &x in a {
+        let t = total + x;
+        // Recover the low-order bits lost from whichever operand was smaller
+        compensation += if f64::abs(total) >= f64::abs(x) {
+            (total - t) + x
+        } else {
+            (x - t) + total
+        };
+        total = t;
+    }
+    if compensation.is_finite() {
+        total + compensation
+    } else {
+        total
+    }
+}
+
+fn check_lengths(dst: &[f64], a: &[f64], b: &[f64]) {
+    assert!(
+        dst.len() == a.len() && a.len() == b.len(),
+        "vector lengths differ: dst {}, a {}, b {}",
+        dst.len(),
+        a.len(),
+        b.len()
+    );
+}
+
+#[cfg(target_arch = "x86_64")]
+fn avx_available() -> bool {
+    std::arch::is_x86_feature_detected!("avx")
+}
+
+#[cfg(not(target_arch = "x86_64"))]
+fn avx_available() -> bool {
+    false
+}
+
+/// One element at a time; the fallback and the reference for the vector paths.
+pub mod scalar {
+    pub fn add(dst: &mut [f64], a: &[f64], b: &[f64]) {
+        for ((d, x), y) in dst.iter_mut().zip(a).zip(b) {
+            *d = x + y;
+        }
+    }
+
+    pub fn mul(dst: &mut [f64], a: &[f64], b: &[f64]) {
+        for ((d, x), y) in dst.iter_mut().zip(a).zip(b) {
+            *d = x * y;
+        }
+    }
+
+    pub fn triad(dst: &mut [f64], a: &[f64], b: &[f64], s: f64) {
+        for ((d, x), y) in dst.iter_mut().zip(a).zip(b) {
+            *d = x + s * y;
+        }
+    }
+
+    pub fn recip(dst: &mut [f64], a: &[f64]) {
+        for (d, x) in dst.iter_mut().zip(a) {
+            *d = 1.0 / x;
+        }
+    }
+
+    pub fn rsqrt(dst: &mut [f64], a: &[f64]) {
+        for (d, x) in dst.iter_mut().zip(a) {
+            *d = 1.0 / x.sqrt();
+        }
+    }
+}
+
+#[cfg(target_arch = "x86_64")]
+mod avx {
+    use std::arch::x86_64::*;
+
+    const LANES: usize = 4;
+
+    #[target_feature(enable = "avx")]
+    pub(super) unsafe fn add(dst: &mut [f64], a: &[f64], b: &[f64]) {
+        let whole = dst.len() - dst.len() % LANES;
+        for i in (0..whole).step_by(LANES) {
+            let x = _mm256_loadu_pd(a.as_ptr().add(i));
+            let y = _mm256_loadu_pd(b.as_ptr().add(i));
+            _mm256_storeu_pd(dst.as_mut_ptr().add(i), _mm256_add_pd(x, y));
+        }
+        super::scalar::add(&mut dst[whole..], &a[whole..], &b[whole..]);
+    }
+
+    #[target_feature(enable = "avx")]
+    pub(super) unsafe fn mul(dst: &mut [f64], a: &[f64], b: &[f64]) {
+        let whole = dst.len() - dst.len() % LANES;
+        for i in (0..whole).step_by(LANES) {
+            let x = _mm256_loadu_pd(a.as_ptr().add(i));
+            let y = _mm256_loadu_pd(b.as_ptr().add(i));
+            _mm256_storeu_pd(dst.as_mut_ptr().add(i), _mm256_mul_pd(x, y));
+        }
+        super::scalar::mul(&mut dst[whole..], &a[whole..], &b[whole..]);
+    }
+
+    #[target_feature(enable = "avx")]
+    pub(super) unsafe fn triad(dst: &mut [f64], a: &[f64], b: &[f64], s: f64) {
+        let whole = dst.len() - dst.len() % LANES;
+        let scale = _mm256_set1_pd(s);
+        for i in (0..whole).step_by(LANES) {
+            let x = _mm256_loadu_pd(a.as_ptr().add(i));
+            let y = _mm256_loadu_pd(b.as_ptr().add(i));
+            let sum = _mm256_add_pd(x, _mm256_mul_pd(scale, y));
+            _mm256_storeu_pd(dst.as_mut_ptr().add(i), sum);
+        }
+        super::scalar::triad(&mut dst[whole..], &a[whole..], &b[whole..], s);
+    }
+
+    #[target_feature(enable = "avx")]
+    pub(super) unsafe fn recip(dst: &mut [f64], a: &[f64]) {
+        let whole = dst.len() - dst.len() % LANES;
+        let one = _mm256_set1_pd(1.0);
+        for i in (0..whole).step_by(LANES) {
+            let x = _mm256_loadu_pd(a.as_ptr().add(i));
+            _mm256_storeu_pd(dst.as_mut_ptr().add(i), _mm256_div_pd(one, x));
+        }
+        super::scalar::recip(&mut dst[whole..], &a[whole..]);
+    }
+
+    #[target_feature(enable = "avx")]
+    pub(super) unsafe fn rsqrt(dst: &mut [f64], a: &[f64]) {
+        let whole = dst.len() - dst.len() % LANES;
+        let one = _mm256_set1_pd(1.0);
+        for i in (0..whole).step_by(LANES) {
+            let x = _mm256_loadu_pd(a.as_ptr().add(i));
+            let root = _mm256_sqrt_pd(x);
+            _mm256_storeu_pd(dst.as_mut_ptr().add(i), _mm256_div_pd(one, root));
+        }
+        super::scalar::rsqrt(&mut dst[whole..], &a[whole..]);
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    /// Deterministic values with a spread of magnitudes and signs plus the
+    /// awkward cases: signed zeros, infinities, NaN, and subnormals.
+    fn inputs(n: usize, seed: u64) -> Vec<f64> {
+        let specials = [
+            0.0,
+            -0.0,
+            f64::INFINITY,
+            f64::NEG_INFINITY,
+            f64::NAN,
+            f64::MIN_POSITIVE / 4.0,
+            f64::MAX,
+        ];
+        let mut state = seed;
+        (0..n)
+            .map(|i| {
+                state = state
+                    .wrapping_mul(6364136223846793005)
+                    .wrapping_add(1442695040888963407);
+                if i % 11 == 3 {
+                    specials[(state >> 60) as usize % specials.len()]
+                } else {
+                    let mantissa = (state >> 11) as f64 / (1u64 << 53) as f64;
+                    let exponent = ((state >> 3) % 40) as i32 - 20;
+                    (mantissa - 0.5) * 2f64.powi(exponent)
+                }
+            })
+            .collect()
+    }
+
+    /// Exact bits, except that every NaN compares equal to every other.
+    fn bits(xs: &[f64]) -> Vec<u64> {
+        xs.iter()
+            .map(|x| if x.is_nan() { u64::MAX } else { x.to_bits() })
+            .collect()
+    }
+
+    #[test]
+    fn vector_kernels_match_scalar_bit_for_bit() {
+        // Lengths around the lane width exercise the scalar tail.
+        for n in [0, 1, 3, 4, 5, 8, 13, 64, 1001] {
+            let a = inputs(n, 1);
+            let b = inputs(n, 2);
+
+            let mut expected = vec![0.0; n];
+            let mut actual = vec![0.0; n];
+
+            scalar::add(&mut expected, &a, &b);
+            add(&mut actual, &a, &b);
+            assert_eq!(bits(&actual), bits(&expected), "add, n = {}", n);
+
+            scalar::mul(&mut expected, &a, &b);
+            mul(&mut actual, &a, &b);
+            assert_eq!(bits(&actual), bits(&expected), "mul, n = {}", n);
+
+            for s in [3.0, -0.5, 0.0, f64::NAN] {
+                scalar::triad(&mut expected, &a, &b, s);
+                triad(&mut actual, &a, &b, s);
+                assert_eq!(bits(&actual), bits(&expected), "triad {}, n = {}", s, n);
+            }
+
+            scalar::recip(&mut expected, &a);
+            recip(&mut actual, &a);
+            assert_eq!(bits(&actual), bits(&expected), "recip, n = {}", n);
+
+            scalar::rsqrt(&mut expected, &a);
+            rsqrt(&mut actual, &a);
+            assert_eq!(bits(&actual), bits(&expected), "rsqrt, n = {}", n);
+        }
+    }
+
+    #[test]
+    fn triad_is_not_fused() {
+        // With a fused multiply-add this would round once and give 2^-104.
+        let e = 2f64.powi(-52);
+        let (a, b, s) = ([-1.0 - 2.0 * e; 4], [1.0 + e; 4], 1.0 + e);
+        let mut dst = [f64::NAN; 4];
+        triad(&mut dst, &a, &b, s);
+        assert_eq!(dst, [0.0; 4]);
+    }
+
+    #[test]
+    fn recip_and_rsqrt_round_like_division() {
+        // Powers of four have exact roots, so both results are exact there.
+        let a: Vec<f64> = (-40..40).map(|e| 4f64.powi(e)).collect();
+        let mut dst = vec![0.0; a.len()];
+        recip(&mut dst, &a);
+        for (x, r) in a.iter().zip(&dst) {
+            assert_eq!(*r, 1.0 / x);
+        }
+        rsqrt(&mut dst, &a);
+        for (x, r) in a.iter().zip(&dst) {
+            assert_eq!(*r * *r, 1.0 / x);
+        }
+
+        // Elsewhere rsqrt stays within 1.5 ulp of the true value: squaring
+        // it and multiplying back lands within a few ulp of 1.
+        let a = inputs(1001, 3);
+        let mut dst = vec![0.0; a.len()];
+        rsqrt(&mut dst, &a);
+        for (x, r) in a.iter().zip(&dst) {
+            if x.is_normal() && *x > 0.0 && *x < 1e300 {
+                assert!((r * r * x - 1.0).abs() < 8.0 * f64::EPSILON, "rsqrt({})", x);
+            }
+        }
+        let specials = [0.0, -0.0, f64::INFINITY, -1.0];
+        let mut out = [0.0; 4];
+        rsqrt(&mut out, &specials);
+        assert_eq!(out[..3], [f64::INFINITY, f64::NEG_INFINITY, 0.0]);
+        assert!(out[3].is_nan());
+    }
+
+    #[test]
+    fn sums_add_left_to_right() {
+        // 1e16 + 1 rounds back to 1e16, so the 1 is lost unless it is
+        // added last or carried separately.
+        let a = [1.0, 1e16, -1e16];
+        assert_eq!(sum(&a), 0.0);
+        assert_eq!(sum(&[1e16, -1e16, 1.0]), 1.0);
+        assert_eq!(compensated_sum(&a), 1.0);
+
+        assert_eq!(sum(&[]), 0.0);
+        assert_eq!(compensated_sum(&[]), 0.0);
+        assert_eq!(compensated_sum(&[1.0, f64::INFINITY]), f64::INFINITY);
+        assert!(compensated_sum(&[f64::INFINITY, f64::NEG_INFINITY]).is_nan());
+        assert_eq!(compensated_sum(&[f64::MAX, f64::MAX]), f64::INFINITY);
+    }
+
+    #[test]
+    #[should_panic(expected = "vector lengths differ: dst 2, a 3, b 3")]
+    fn mismatched_lengths_panic() {
+        add(&mut [0.0; 2], &[1.0; 3], &[1.0; 3]);
+    }
+
+    #[test]
+    fn kernel_name_is_known() {
+        assert!(["avx", "scalar"].contains(&kernel_name()));
+    }
+}
@@ -0,0 +1,129 @@
+//! Allocation-site profiling.
+//!
+//! When enabled with [`VM::enable_alloc_profile`](super::VM::enable_alloc_profile),
+//! every list, map, record, tuple, and set the interpreter builds is
+//! counted against the source line that built it, as are the lists that
+//! `list_with_capacity` reserves. A site is the cell, file, and line of the
+//! statement holding the constructor or call, located through the
+//! `alloc_lines` and `call_lines` addons (see `backtrace`).
+//!
+//! Bytes are an estimate of the heap payload at the time of allocation: one
+//! `Value` per element, two per map entry or record field, and one per
+//! reserved slot. Growth after construction, such as appending to a list, is
+//! not counted. A disabled profiler costs one branch per allocation.
+
+use super::backtrace::SourceMap;
+use lumen_compiler::compiler::lir::LirModule;
+use std::collections::BTreeMap;
+
+/// Allocations made at one source line.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct AllocSite {
+    /// The cell the allocation happened in
+    pub function: String,
+    pub file: String,
+    /// 0 when the line is unknown
+    pub line: usize,
+    pub count: u64,
+    pub bytes: u64,
+}
+
+/// Sites of a profiled run, most bytes first.
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub struct AllocProfile {
+    pub sites: Vec<AllocSite>,
+}
+
+impl AllocProfile {
+    /// Total allocations and bytes over every site.
+    pub fn totals(&self) -> (u64, u64) {
+        self.sites.iter().fold((0, 0), |(count, bytes), s| {
+            (count + s.count, bytes + s.bytes)
+        })
+    }
+
+    /// The site at `file:line`, if anything was allocated there.
+    pub fn site(&self, file: &str, line: usize) -> Option<&AllocSite> {
+        self.sites.iter().find(|s| s.file == file && s.line == line)
+    }
+
+    /// A table of the sites, one per line, for printing after a run.
+    pub fn report(&self) -> String {
+        let mut out = format!("{:>12} {:>8}  site\n", "bytes", "allocs");
+        for s in &self.sites {
+            out.push_str(&format!(
+                "{:>12} {:>8}  {}:{} ({})\n",
+                s.bytes, s.count, s.file, s.line, s.function
+            ));
+        }
+        let (count, bytes) = self.totals();
+        out.push_str(&format!("{:>12} {:>8}  total\n", bytes, count));
+        out
+    }
+}
+
+#[derive(Debug, Default)]
+pub(crate) struct AllocProfiler {
+    /// Line of each instruction, by cell then instruction index
+    lines: Vec<Vec<usize>>,
+    /// Counts and bytes by cell index and line
+    stats: BTreeMap<(usize, usize), (u64, u64)>,
+}
+
+impl AllocProfiler {
+    pub(crate) fn for_module(module: &LirModule, source_map: &SourceMap) -> Self {
+        Self {
+            lines: module
+                .cells
+                .iter()
+                .map(|cell| source_map.site_lines(cell))
+                .collect(),
+            stats: BTreeMap::new(),
+        }
+    }
+
+    /// Count an allocation of `bytes` by instruction `ip` of cell `cell_idx`.
+    pub(crate) fn record(&mut self, cell_idx: usize, ip: usize, bytes: usize) {
+        let line = self
+            .lines
+            .get(cell_idx)
+            .and_then(|lines| lines.get(ip))
+            .copied()
+            .unwrap_or(0);
+        let entry = self.stats.entry((cell_idx, line)).or_default();
+        entry.0 += 1;
+        entry.1 += bytes as u64;
+    }
+
+    pub(crate) fn profile(
+        &self,
+        module: &LirModule,
+        source_map: &SourceMap,
+        main: &str,
+    ) -> AllocProfile {
+        let mut sites: Vec<AllocSite> = self
+            .stats
+            .iter()
+            .map(|(&(cell_idx, line), &(count, bytes))| {
+                let function = module
+                    .cells
+                    .get(cell_idx)
+                    .map_or_else(|| format!("<cell {}>", cell_idx), |c| c.name.clone());
+                AllocSite {
+                    file: source_map.file(&function, main).to_string(),
+                    function,
+                    line,
+                    count,
+                    bytes,
+                }
+            })
+            .collect();
+        sites.sort_by(|a, b| {
+            b.bytes
+                .cmp(&a.bytes)
+                .then_with(|| a.file.cmp(&b.file))
+                .then(a.line.cmp(&b.line))
+        });
+        AllocProfile { sites }
+    }
+}
@@ -0,0 +1,138 @@
+//! Source positions for `backtrace()`.
+//!
+//! A waiting frame's `ip` is just past the call it made. The compiler lists
+//! the source line of every `Call`, `TailCall` and `Intrinsic` in a cell, in
+//! order, as a `call_lines` addon, so the line of a frame is the entry for
+//! the number of calls that precede that one. Each cell of an imported
+//! module also gets a `cell_module` addon naming its import path, which a
+//! frame reports as its file; cells of the main document report the VM's
+//! source name.
+//!
+//! The allocation profiler locates its sites the same way, from the
+//! `alloc_lines` addon listing the line of every instruction that allocates.
+
+use super::VM;
+use crate::values::{StringRef, Value};
+use lumen_compiler::compiler::lir::{LirAddon, LirCell, OpCode};
+use std::collections::{BTreeMap, HashMap};
+
+/// Name reported as the file of main-document frames until the host sets one.
+pub(crate) const DEFAULT_SOURCE_NAME: &str = "<main>";
+
+#[derive(Debug, Default)]
+pub(crate) struct SourceMap {
+    call_lines: HashMap<String, Vec<usize>>,
+    alloc_lines: HashMap<String, Vec<usize>>,
+    modules: HashMap<String, String>,
+}
+
+impl SourceMap {
+    pub(crate) fn from_addons(addons: &[LirAddon]) -> Self {
+        let mut map = Self::default();
+        for addon in addons {
+            let Some((cell, value)) = addon.name.as_deref().and_then(|n| n.split_once('=')) else {
+                continue;
+            };
+            // Merging drops an imported cell whose name the importer already
+            // uses, and the importer's addons come first, so the first wins
+            match addon.kind.as_str() {
+                "call_lines" | "alloc_lines" => {
+                    let lines = value.split(',').filter_map(|l| l.parse().ok()).collect();
+                    let by_cell = if addon.kind == "call_lines" {
+                        &mut map.call_lines
+                    } else {
+                        &mut map.alloc_lines
+                    };
+                    by_cell.entry(cell.to_string()).or_insert(lines);
+                }
+                "cell_module" => {
+                    map.modules
+                        .entry(cell.to_string())
+                        .or_insert_with(|| value.to_string());
+                }
+                _ => {}
+            }
+        }
+        map
+    }
+
+    /// Line of the call that ends just before `ip` in `cell`; 0 if unknown.
+    fn line(&self, cell: &LirCell, ip: usize) -> usize {
+        let Some(lines) = self.call_lines.get(&cell.name) else {
+            return 0;
+        };
+        let call = ip.saturating_sub(1).min(cell.instructions.len());
+        let earlier_calls = cell.instructions[..call]
+            .iter()
+            .filter(|i| matches!(i.op, OpCode::Call | OpCode::TailCall | OpCode::Intrinsic))
+            .count();
+        lines.get(earlier_calls).copied().unwrap_or(0)
+    }
+
+    /// The file `cell` belongs to, or `main` for the main document.
+    pub(crate) fn file<'a>(&'a self, cell: &str, main: &'a str) -> &'a str {
+        self.modules.get(cell).map_or(main, String::as_str)
+    }
+
+    /// The line of each instruction of `cell` that allocates or calls, by
+    /// instruction index; 0 for every other instruction and when unknown.
+    pub(crate) fn site_lines(&self, cell: &LirCell) -> Vec<usize> {
+        let none = Vec::new();
+        let calls = self.call_lines.get(&cell.name).unwrap_or(&none);
+        let allocs = self.alloc_lines.get(&cell.name).unwrap_or(&none);
+        let (mut next_call, mut next_alloc) = (0, 0);
+        cell.instructions
+            .iter()
+            .map(|instr| {
+                let (lines, next) = match instr.op {
+                    OpCode::Call | OpCode::TailCall | OpCode::Intrinsic => (calls, &mut next_call),
+                    OpCode::NewList
+                    | OpCode::NewMap
+                    | OpCode::NewRecord
+                    | OpCode::NewTuple
+                    | OpCode::NewSet => (allocs, &mut next_alloc),
+                    _ => return 0,
+                };
+                *next += 1;
+                lines.get(*next - 1).copied().unwrap_or(0)
+            })
+            .collect()
+    }
+}
+
+impl VM {
+    /// The call stack as `backtrace()` returns it: one `{function, file,
+    /// line}` map per frame, innermost first. A tail call replaces its
+    /// caller's frame, so the caller does not appear.
+    pub(crate) fn backtrace_value(&self) -> Value {
+        let Some(module) = &self.module else {
+            return Value::new_list(Vec::new());
+        };
+        let frames = self
+            .frames
+            .iter()
+            .rev()
+            .filter_map(|frame| {
+                let cell = module.cells.get(frame.cell_idx)?;
+                let file = self
+                    .source_map
+                    .modules
+                    .get(&cell.name)
+                    .unwrap_or(&self.source_name);
+                let line = self.source_map.line(cell, frame.ip);
+                let mut map = BTreeMap::new();
+                map.insert(
+                    "function".to_string(),
+                    Value::String(StringRef::Owned(cell.name.clone())),
+                );
+                map.insert(
+                    "file".to_string(),
+                    Value::String(StringRef::Owned(file.clone())),
+                );
+                map.insert("line".to_string(), Value::Int(line as i64));
+                Some(Value::new_map(map))
+            })
+            .collect();
+        Value::new_list(frames)
+    }
+}
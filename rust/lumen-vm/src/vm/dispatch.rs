@@ -0,0 +1,501 @@
+//! Table dispatch for the interpreter loop.
+//!
+//! `run_until` normally finds the code for an instruction with one `match`
+//! on its opcode. Under [`DispatchMode::Table`] it first indexes [`HANDLERS`],
+//! an array of handler functions by opcode byte, and calls the entry instead.
+//! Only opcodes that stay within the current frame have a handler; calls,
+//! returns, and anything else that switches frames fall through to the
+//! match. The match arms for the opcodes below call the same handlers
+//! directly, so both modes run the same code and differ only in how they
+//! reach it.
+
+use super::ops::compare_values;
+use super::*;
+use std::cmp::Ordering;
+
+/// How `run_until` finds the code for each instruction.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default)]
+pub enum DispatchMode {
+    /// One `match` on the opcode.
+    #[default]
+    Match,
+    /// An indirect call through [`HANDLERS`], with the match for opcodes that
+    /// have no handler.
+    Table,
+}
+
+/// Runs one instruction of `cell`, whose registers start at `base`. `ip`
+/// already points past the instruction.
+pub(crate) type Handler = fn(
+    vm: &mut VM,
+    module: &LirModule,
+    cell: &LirCell,
+    base: usize,
+    instr: Instruction,
+    ip: &mut usize,
+) -> Result<(), VmError>;
+
+/// The handler for each opcode byte, or None for the opcodes that stay in
+/// the match.
+pub(crate) static HANDLERS: [Option<Handler>; 256] = handlers();
+
+const fn handlers() -> [Option<Handler>; 256] {
+    let mut table: [Option<Handler>; 256] = [None; 256];
+    table[OpCode::LoadK as usize] = Some(op_load_k);
+    table[OpCode::LoadNil as usize] = Some(op_load_nil);
+    table[OpCode::LoadBool as usize] = Some(op_load_bool);
+    table[OpCode::LoadInt as usize] = Some(op_load_int);
+    table[OpCode::Move as usize] = Some(op_move);
+    table[OpCode::MoveOwn as usize] = Some(op_move_own);
+    table[OpCode::GetTuple as usize] = Some(op_get_tuple);
+    table[OpCode::Add as usize] = Some(op_add);
+    table[OpCode::Sub as usize] = Some(op_sub);
+    table[OpCode::Mul as usize] = Some(op_mul);
+    table[OpCode::Div as usize] = Some(op_div);
+    table[OpCode::FloorDiv as usize] = Some(op_floor_div);
+    table[OpCode::Mod as usize] = Some(op_mod);
+    table[OpCode::Pow as usize] = Some(op_pow);
+    table[OpCode::Neg as usize] = Some(op_neg);
+    table[OpCode::Eq as usize] = Some(op_eq);
+    table[OpCode::Lt as usize] = Some(op_lt);
+    table[OpCode::Le as usize] = Some(op_le);
+    table[OpCode::Not as usize] = Some(op_not);
+    table[OpCode::NullCo as usize] = Some(op_null_co);
+    table[OpCode::Test as usize] = Some(op_test);
+    table[OpCode::Jmp as usize] = Some(op_jmp);
+    table[OpCode::IsVariant as usize] = Some(op_is_variant);
+    table[OpCode::Unbox as usize] = Some(op_unbox);
+    table
+}
+
+#[inline]
+pub(crate) fn op_load_k(
+    vm: &mut VM,
+    _module: &LirModule,
+    cell: &LirCell,
+    base: usize,
+    instr: Instruction,
+    _ip: &mut usize,
+) -> Result<(), VmError> {
+    let val = match &cell.constants[instr.bx() as usize] {
+        Constant::Null => Value::Null,
+        Constant::Bool(v) => Value::Bool(*v),
+        Constant::Int(v) => Value::Int(*v),
+        Constant::BigInt(v) => Value::BigInt(v.clone()),
+        Constant::Float(v) => Value::Float(*v),
+        Constant::String(v) => Value::String(StringRef::Owned(v.clone())),
+    };
+    vm.registers[base + instr.a as usize] = val;
+    Ok(())
+}
+
+#[inline]
+pub(crate) fn op_load_nil(
+    vm: &mut VM,
+    _module: &LirModule,
+    _cell: &LirCell,
+    base: usize,
+    instr: Instruction,
+    _ip: &mut usize,
+) -> Result<(), VmError> {
+    let a = instr.a as usize;
+    for i in 0..=instr.b as usize {
+        vm.registers[base + a + i] = Value::Null;
+    }
+    Ok(())
+}
+
+#[inline]
+pub(crate) fn op_load_bool(
+    vm: &mut VM,
+    _module: &LirModule,
+    _cell: &LirCell,
+    base: usize,
+    instr: Instruction,
+    ip: &mut usize,
+) -> Result<(), VmError> {
+    vm.registers[base + instr.a as usize] = Value::Bool(instr.b != 0);
+    if instr.c != 0 {
+        *ip += 1;
+    }
+    Ok(())
+}
+
+#[inline]
+pub(crate) fn op_load_int(
+    vm: &mut VM,
+    _module: &LirModule,
+    _cell: &LirCell,
+    base: usize,
+    instr: Instruction,
+    _ip: &mut usize,
+) -> Result<(), VmError> {
+    vm.registers[base + instr.a as usize] = Value::Int(instr.sbx() as i64);
+    Ok(())
+}
+
+#[inline]
+pub(crate) fn op_move(
+    vm: &mut VM,
+    _module: &LirModule,
+    _cell: &LirCell,
+    base: usize,
+    instr: Instruction,
+    _ip: &mut usize,
+) -> Result<(), VmError> {
+    // Clone the source register. With Rc-wrapped collections this is just a
+    // refcount increment (negligible cost). For scalars (Int, Float, Bool) it
+    // is a tiny copy. The previous `std::mem::replace` approach destructively
+    // nulled the source register, causing use-after-move bugs whenever the
+    // compiler emitted code that referenced the source register again.
+    vm.registers[base + instr.a as usize] = vm.registers[base + instr.b as usize].clone();
+    Ok(())
+}
+
+#[inline]
+pub(crate) fn op_move_own(
+    vm: &mut VM,
+    _module: &LirModule,
+    _cell: &LirCell,
+    base: usize,
+    instr: Instruction,
+    _ip: &mut usize,
+) -> Result<(), VmError> {
+    // Destructive move: source register becomes Null. The compiler guarantees
+    // the source is dead after this instruction, so it is safe to take
+    // ownership without cloning. For Rc/Arc-wrapped collections this avoids a
+    // refcount bump, which in turn lets subsequent in-place mutations
+    // (Arc::make_mut, push_str, etc.) skip the copy-on-write path.
+    vm.registers[base + instr.a as usize] =
+        std::mem::take(&mut vm.registers[base + instr.b as usize]);
+    Ok(())
+}
+
+#[inline]
+pub(crate) fn op_get_tuple(
+    vm: &mut VM,
+    _module: &LirModule,
+    _cell: &LirCell,
+    base: usize,
+    instr: Instruction,
+    _ip: &mut usize,
+) -> Result<(), VmError> {
+    let c = instr.c as usize;
+    let val = match &vm.registers[base + instr.b as usize] {
+        Value::Tuple(t) => {
+            if c >= t.len() {
+                return Err(index_out_of_range(c as i64, t.len()));
+            }
+            t[c].clone()
+        }
+        Value::List(l) => {
+            if c >= l.len() {
+                return Err(index_out_of_range(c as i64, l.len()));
+            }
+            l[c].clone()
+        }
+        _ => Value::Null,
+    };
+    vm.registers[base + instr.a as usize] = val;
+    Ok(())
+}
+
+#[inline]
+pub(crate) fn op_add(
+    vm: &mut VM,
+    _module: &LirModule,
+    _cell: &LirCell,
+    base: usize,
+    instr: Instruction,
+    _ip: &mut usize,
+) -> Result<(), VmError> {
+    let (a, b, c) = (instr.a as usize, instr.b as usize, instr.c as usize);
+    vm.add_op(base, a, b, c)
+}
+
+#[inline]
+pub(crate) fn op_sub(
+    vm: &mut VM,
+    _module: &LirModule,
+    _cell: &LirCell,
+    base: usize,
+    instr: Instruction,
+    _ip: &mut usize,
+) -> Result<(), VmError> {
+    let (a, b, c) = (instr.a as usize, instr.b as usize, instr.c as usize);
+    vm.arith_op(base, a, b, c, BinaryOp::Sub)
+}
+
+#[inline]
+pub(crate) fn op_mul(
+    vm: &mut VM,
+    _module: &LirModule,
+    _cell: &LirCell,
+    base: usize,
+    instr: Instruction,
+    _ip: &mut usize,
+) -> Result<(), VmError> {
+    let (a, b, c) = (instr.a as usize, instr.b as usize, instr.c as usize);
+    vm.arith_op(base, a, b, c, BinaryOp::Mul)
+}
+
+#[inline]
+pub(crate) fn op_div(
+    vm: &mut VM,
+    _module: &LirModule,
+    _cell: &LirCell,
+    base: usize,
+    instr: Instruction,
+    _ip: &mut usize,
+) -> Result<(), VmError> {
+    let (a, b, c) = (instr.a as usize, instr.b as usize, instr.c as usize);
+    // Pre-check for integer division by zero
+    if matches!(
+        (&vm.registers[base + b], &vm.registers[base + c]),
+        (Value::Int(_), Value::Int(0))
+    ) {
+        return Err(VmError::DivisionByZero);
+    }
+    vm.arith_op(base, a, b, c, BinaryOp::Div)
+}
+
+#[inline]
+pub(crate) fn op_floor_div(
+    vm: &mut VM,
+    _module: &LirModule,
+    _cell: &LirCell,
+    base: usize,
+    instr: Instruction,
+    _ip: &mut usize,
+) -> Result<(), VmError> {
+    let (a, b, c) = (instr.a as usize, instr.b as usize, instr.c as usize);
+    vm.arith_op(base, a, b, c, BinaryOp::FloorDiv)
+}
+
+#[inline]
+pub(crate) fn op_mod(
+    vm: &mut VM,
+    _module: &LirModule,
+    _cell: &LirCell,
+    base: usize,
+    instr: Instruction,
+    _ip: &mut usize,
+) -> Result<(), VmError> {
+    let (a, b, c) = (instr.a as usize, instr.b as usize, instr.c as usize);
+    // Pre-check for integer modulo by zero
+    if matches!(
+        (&vm.registers[base + b], &vm.registers[base + c]),
+        (Value::Int(_), Value::Int(0))
+    ) {
+        return Err(VmError::DivisionByZero);
+    }
+    vm.arith_op(base, a, b, c, BinaryOp::Mod)
+}
+
+#[inline]
+pub(crate) fn op_pow(
+    vm: &mut VM,
+    _module: &LirModule,
+    _cell: &LirCell,
+    base: usize,
+    instr: Instruction,
+    _ip: &mut usize,
+) -> Result<(), VmError> {
+    let (a, b, c) = (instr.a as usize, instr.b as usize, instr.c as usize);
+    vm.arith_op(base, a, b, c, BinaryOp::Pow)
+}
+
+#[inline]
+pub(crate) fn op_neg(
+    vm: &mut VM,
+    _module: &LirModule,
+    _cell: &LirCell,
+    base: usize,
+    instr: Instruction,
+    _ip: &mut usize,
+) -> Result<(), VmError> {
+    let val = &vm.registers[base + instr.b as usize];
+    vm.registers[base + instr.a as usize] = match val {
+        Value::Int(n) => n
+            .checked_neg()
+            .map(Value::Int)
+            .ok_or(VmError::ArithmeticOverflow("negation".to_string()))?,
+        Value::Float(f) => Value::Float(-f),
+        _ => return Err(VmError::TypeError(format!("cannot negate {}", val))),
+    };
+    Ok(())
+}
+
+#[inline]
+pub(crate) fn op_eq(
+    vm: &mut VM,
+    _module: &LirModule,
+    _cell: &LirCell,
+    base: usize,
+    instr: Instruction,
+    _ip: &mut usize,
+) -> Result<(), VmError> {
+    let lhs = &vm.registers[base + instr.b as usize];
+    let rhs = &vm.registers[base + instr.c as usize];
+    let eq = if matches!(lhs, Value::Record(_)) {
+        // A record type with an `eq` method decides equality itself
+        let (lhs, rhs) = (lhs.clone(), rhs.clone());
+        match vm.call_operator_method("eq", &lhs, &rhs)? {
+            Some(result) => result.is_truthy(),
+            None => values_equal(&lhs, &rhs, &vm.strings),
+        }
+    } else {
+        values_equal(lhs, rhs, &vm.strings)
+    };
+    vm.registers[base + instr.a as usize] = Value::Bool(eq);
+    Ok(())
+}
+
+#[inline]
+pub(crate) fn op_lt(
+    vm: &mut VM,
+    _module: &LirModule,
+    _cell: &LirCell,
+    base: usize,
+    instr: Instruction,
+    _ip: &mut usize,
+) -> Result<(), VmError> {
+    let order = compare_values(
+        &vm.registers[base + instr.b as usize],
+        &vm.registers[base + instr.c as usize],
+        &vm.strings,
+    );
+    vm.registers[base + instr.a as usize] = Value::Bool(order == Some(Ordering::Less));
+    Ok(())
+}
+
+#[inline]
+pub(crate) fn op_le(
+    vm: &mut VM,
+    _module: &LirModule,
+    _cell: &LirCell,
+    base: usize,
+    instr: Instruction,
+    _ip: &mut usize,
+) -> Result<(), VmError> {
+    let order = compare_values(
+        &vm.registers[base + instr.b as usize],
+        &vm.registers[base + instr.c as usize],
+        &vm.strings,
+    );
+    vm.registers[base + instr.a as usize] =
+        Value::Bool(matches!(order, Some(Ordering::Less | Ordering::Equal)));
+    Ok(())
+}
+
+#[inline]
+pub(crate) fn op_not(
+    vm: &mut VM,
+    _module: &LirModule,
+    _cell: &LirCell,
+    base: usize,
+    instr: Instruction,
+    _ip: &mut usize,
+) -> Result<(), VmError> {
+    let truthy = vm.value_is_truthy(&vm.registers[base + instr.b as usize]);
+    vm.registers[base + instr.a as usize] = Value::Bool(!truthy);
+    Ok(())
+}
+
+#[inline]
+pub(crate) fn op_null_co(
+    vm: &mut VM,
+    _module: &LirModule,
+    _cell: &LirCell,
+    base: usize,
+    instr: Instruction,
+    _ip: &mut usize,
+) -> Result<(), VmError> {
+    let val = &vm.registers[base + instr.b as usize];
+    vm.registers[base + instr.a as usize] = if matches!(val, Value::Null) {
+        vm.registers[base + instr.c as usize].clone()
+    } else {
+        val.clone()
+    };
+    Ok(())
+}
+
+#[inline]
+pub(crate) fn op_test(
+    vm: &mut VM,
+    _module: &LirModule,
+    _cell: &LirCell,
+    base: usize,
+    instr: Instruction,
+    ip: &mut usize,
+) -> Result<(), VmError> {
+    let truthy = vm.value_is_truthy(&vm.registers[base + instr.a as usize]);
+    if truthy != (instr.c != 0) {
+        *ip += 1;
+    }
+    Ok(())
+}
+
+#[inline]
+pub(crate) fn op_jmp(
+    vm: &mut VM,
+    _module: &LirModule,
+    _cell: &LirCell,
+    _base: usize,
+    instr: Instruction,
+    ip: &mut usize,
+) -> Result<(), VmError> {
+    let offset = instr.sax_val();
+    if offset < 0 {
+        vm.safepoint(*ip)?;
+    }
+    *ip = (*ip as i32 + offset) as usize;
+    Ok(())
+}
+
+#[inline]
+pub(crate) fn op_is_variant(
+    vm: &mut VM,
+    module: &LirModule,
+    _cell: &LirCell,
+    base: usize,
+    instr: Instruction,
+    ip: &mut usize,
+) -> Result<(), VmError> {
+    // The bx field is an index into module.strings. We need to resolve it to
+    // an interned string ID to compare against the Union's tag (which is also
+    // an interned ID).
+    let tag_idx = instr.bx() as usize;
+    let tag_id = if tag_idx < module.strings.len() {
+        vm.strings.intern(&module.strings[tag_idx])
+    } else {
+        u32::MAX // will never match
+    };
+    let matched = match &vm.registers[base + instr.a as usize] {
+        Value::Union(u) => u.tag == tag_id,
+        _ => false,
+    };
+    if matched {
+        *ip += 1;
+    }
+    Ok(())
+}
+
+#[inline]
+pub(crate) fn op_unbox(
+    vm: &mut VM,
+    _module: &LirModule,
+    _cell: &LirCell,
+    base: usize,
+    instr: Instruction,
+    _ip: &mut usize,
+) -> Result<(), VmError> {
+    // Clone the union payload instead of destructively taking from the source
+    // register, which could null out a variable that is still needed later
+    // (e.g. in multi-branch match).
+    vm.registers[base + instr.a as usize] = match &vm.registers[base + instr.b as usize] {
+        Value::Union(u) => (*u.payload).clone(),
+        _ => Value::Null,
+    };
+    Ok(())
+}
@@ -0,0 +1,175 @@
+//! Monomorphic inline caches for record field reads.
+//!
+//! `p.mass` lowers to a `GetIndex` with a constant string key, and compound
+//! field assignment reads through `GetField`. Each such instruction gets a
+//! slot that remembers where the field sat among the name-ordered fields of
+//! the last record it read. A read checks the name at that position, one
+//! indexed load and a string compare, instead of searching the fields by
+//! name. A record that keeps the field elsewhere (a different record behind
+//! a trait object, or one that has gained a field) misses, finds the field
+//! by binary search, and re-primes the slot with the new position.
+
+use crate::values::{FieldMap, Value};
+use lumen_compiler::compiler::lir::{LirModule, OpCode};
+
+/// Marks an instruction that cannot read a record field.
+const NO_SITE: u32 = u32::MAX;
+
+/// Marks a slot that has not read a field yet.
+const EMPTY: u32 = u32::MAX;
+
+/// Hit and miss counts across every field-read site of the loaded module.
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq)]
+pub struct FieldCacheStats {
+    pub hits: u64,
+    pub misses: u64,
+}
+
+#[derive(Debug, Default)]
+pub(crate) struct FieldCache {
+    /// Slot index for each instruction, by cell then instruction index.
+    sites: Vec<Vec<u32>>,
+    /// Field position each slot last read.
+    slots: Vec<u32>,
+    stats: FieldCacheStats,
+}
+
+impl FieldCache {
+    /// One empty slot per `GetField` and `GetIndex` instruction in `module`.
+    pub(crate) fn for_module(module: &LirModule) -> Self {
+        let mut next = 0u32;
+        let sites = module
+            .cells
+            .iter()
+            .map(|cell| {
+                cell.instructions
+                    .iter()
+                    .map(|instr| {
+                        if matches!(instr.op, OpCode::GetField | OpCode::GetIndex) {
+                            next += 1;
+                            next - 1
+                        } else {
+                            NO_SITE
+                        }
+                    })
+                    .collect()
+            })
+            .collect();
+        Self {
+            sites,
+            slots: vec![EMPTY; next as usize],
+            stats: FieldCacheStats::default(),
+        }
+    }
+
+    pub(crate) fn stats(&self) -> FieldCacheStats {
+        self.stats
+    }
+
+    /// Read `field` of a record at instruction `ip` of cell `cell_idx`.
+    /// Returns `None` when the record has no such field.
+    #[inline]
+    pub(crate) fn get<'a>(
+        &mut self,
+        cell_idx: usize,
+        ip: usize,
+        fields: &'a FieldMap,
+        field: &str,
+    ) -> Option<&'a Value> {
+        let site = self
+            .sites
+            .get(cell_idx)
+            .and_then(|cell| cell.get(ip))
+            .copied()
+            .unwrap_or(NO_SITE);
+        if site == NO_SITE {
+            return fields.get(field);
+        }
+        let slot = &mut self.slots[site as usize];
+        // The name check keeps a stale position from ever reading the wrong
+        // field, whatever produced the record.
+        if let Some((k, v)) = fields.get_index(*slot as usize) {
+            if k == field {
+                self.stats.hits += 1;
+                return Some(v);
+            }
+        }
+        self.stats.misses += 1;
+        let position = fields.position(field)?;
+        *slot = position as u32;
+        fields.get_index(position).map(|(_, v)| v)
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use lumen_compiler::compiler::lir::{Instruction, LirCell};
+
+    fn module_with_get_fields(n: usize) -> LirModule {
+        let mut module = LirModule::new("sha256:test".to_string());
+        module.cells.push(LirCell {
+            name: "main".to_string(),
+            params: vec![],
+            returns: None,
+            registers: 4,
+            constants: vec![],
+            instructions: (0..n)
+                .map(|_| Instruction::abc(OpCode::GetField, 0, 1, 0))
+                .collect(),
+            effect_handler_metas: vec![],
+        });
+        module
+    }
+
+    fn fields(pairs: &[(&str, i64)]) -> FieldMap {
+        pairs
+            .iter()
+            .map(|(k, v)| (k.to_string(), Value::Int(*v)))
+            .collect()
+    }
+
+    #[test]
+    fn same_layout_hits_after_first_read() {
+        let mut cache = FieldCache::for_module(&module_with_get_fields(1));
+        let body = fields(&[("mass", 5), ("vx", 1), ("x", 2)]);
+        for _ in 0..3 {
+            assert_eq!(cache.get(0, 0, &body, "vx"), Some(&Value::Int(1)));
+        }
+        assert_eq!(cache.stats(), FieldCacheStats { hits: 2, misses: 1 });
+    }
+
+    #[test]
+    fn other_layout_misses_and_reprimes() {
+        let mut cache = FieldCache::for_module(&module_with_get_fields(1));
+        let square = fields(&[("name", 1), ("side", 2)]);
+        let rect = fields(&[("h", 3), ("name", 4), ("w", 5)]);
+        assert_eq!(cache.get(0, 0, &square, "name"), Some(&Value::Int(1)));
+        assert_eq!(cache.get(0, 0, &rect, "name"), Some(&Value::Int(4)));
+        assert_eq!(cache.get(0, 0, &rect, "name"), Some(&Value::Int(4)));
+        assert_eq!(cache.get(0, 0, &square, "name"), Some(&Value::Int(1)));
+        assert_eq!(cache.stats(), FieldCacheStats { hits: 1, misses: 3 });
+    }
+
+    #[test]
+    fn field_at_another_position_reads_the_right_field() {
+        let mut cache = FieldCache::for_module(&module_with_get_fields(1));
+        let a = fields(&[("a", 1), ("name", 2)]);
+        let b = fields(&[("name", 3), ("z", 4)]);
+        assert_eq!(cache.get(0, 0, &a, "name"), Some(&Value::Int(2)));
+        assert_eq!(cache.get(0, 0, &b, "name"), Some(&Value::Int(3)));
+        assert_eq!(cache.get(0, 0, &b, "missing"), None);
+        assert_eq!(cache.stats(), FieldCacheStats { hits: 0, misses: 3 });
+    }
+
+    #[test]
+    fn sites_are_independent() {
+        let mut cache = FieldCache::for_module(&module_with_get_fields(2));
+        let body = fields(&[("mass", 5), ("x", 2)]);
+        cache.get(0, 0, &body, "mass");
+        cache.get(0, 1, &body, "x");
+        cache.get(0, 0, &body, "mass");
+        cache.get(0, 1, &body, "x");
+        assert_eq!(cache.stats(), FieldCacheStats { hits: 2, misses: 2 });
+    }
+}
@@ -153,7 +153,11 @@ pub(crate) fn parse_machine_expr_json(value: &serde_json::Value) -> Option<Machi
     }
 }
 
+/// The schedule a module asks for: `@schedule eager`, `deferred`, or
+/// `cooperative` names one, and otherwise `@deterministic true` defers.
 pub(crate) fn future_schedule_from_addons(addons: &[LirAddon]) -> FutureSchedule {
+    let mut schedule = FutureSchedule::Eager;
+    let mut deterministic_seen = false;
     for addon in addons {
         if addon.kind != "directive" {
             continue;
@@ -166,20 +170,30 @@ pub(crate) fn future_schedule_from_addons(addons: &[LirAddon]) -> FutureSchedule
             None => (raw.trim(), None),
         };
         let key = name.trim_start_matches('@').to_ascii_lowercase();
-        if key != "deterministic" {
+        if key == "schedule" {
+            let named = raw_value
+                .map(strip_quote_wrappers)
+                .map(|v| v.to_ascii_lowercase());
+            match named.as_deref() {
+                Some("eager") => return FutureSchedule::Eager,
+                Some("deferred") => return FutureSchedule::DeferredFifo,
+                Some("cooperative") => return FutureSchedule::Cooperative,
+                _ => continue,
+            }
+        }
+        if key != "deterministic" || deterministic_seen {
             continue;
         }
+        deterministic_seen = true;
         let parsed = raw_value
             .map(strip_quote_wrappers)
             .and_then(parse_bool_like)
             .unwrap_or(true);
-        return if parsed {
-            FutureSchedule::DeferredFifo
-        } else {
-            FutureSchedule::Eager
-        };
+        if parsed {
+            schedule = FutureSchedule::DeferredFifo;
+        }
     }
-    FutureSchedule::Eager
+    schedule
 }
 
 pub(crate) fn strip_quote_wrappers(s: &str) -> &str {
@@ -229,7 +243,9 @@ pub(crate) fn value_to_json(
         Value::Int(n) => serde_json::json!(*n),
         Value::Float(f) => serde_json::json!(*f),
         Value::String(StringRef::Owned(s)) => serde_json::Value::String(s.clone()),
-        Value::String(StringRef::Interned(_)) => serde_json::Value::String(val.as_string()),
+        Value::String(StringRef::Interned(_)) => {
+            serde_json::Value::String(val.as_string_resolved(strings))
+        }
         Value::List(l) => {
             serde_json::Value::Array(l.iter().map(|v| value_to_json(v, strings)).collect())
         }
@@ -268,6 +284,220 @@ pub(crate) fn value_to_json(
     }
 }
 
+/// JSON for `json_marshal`. A record becomes an object holding its declared
+/// fields under their `@json` keys, without the `__type` key `value_to_json`
+/// adds; skipped fields and empty `omit_empty` fields are left out.
+pub(crate) fn value_to_field_json(
+    val: &Value,
+    types: &TypeTable,
+    strings: &crate::strings::StringTable,
+) -> serde_json::Value {
+    let nested = |v: &Value| value_to_field_json(v, types, strings);
+    match val {
+        Value::Record(r) => {
+            let mut obj = serde_json::Map::new();
+            match types.get(&r.type_name).map(|t| &t.kind) {
+                Some(RuntimeTypeKind::Record(fields)) => {
+                    let plain = JsonField::default();
+                    for f in fields {
+                        let json = f.json.as_ref().unwrap_or(&plain);
+                        let v = r.fields.get(&f.name).unwrap_or(&Value::Null);
+                        if json.skip || (json.omit_empty && is_empty_value(v, strings)) {
+                            continue;
+                        }
+                        let key = json.key.clone().unwrap_or_else(|| f.name.clone());
+                        obj.insert(key, nested(v));
+                    }
+                }
+                _ => {
+                    for (k, v) in &r.fields {
+                        obj.insert(k.clone(), nested(v));
+                    }
+                }
+            }
+            serde_json::Value::Object(obj)
+        }
+        Value::List(l) => serde_json::Value::Array(l.iter().map(nested).collect()),
+        Value::Tuple(t) => serde_json::Value::Array(t.iter().map(nested).collect()),
+        Value::Set(s) => serde_json::Value::Array(s.iter().map(nested).collect()),
+        Value::Map(m) => {
+            serde_json::Value::Object(m.iter().map(|(k, v)| (k.clone(), nested(v))).collect())
+        }
+        other => value_to_json(other, strings),
+    }
+}
+
+/// Null, or an empty string or collection: what `omit_empty` leaves out.
+fn is_empty_value(val: &Value, strings: &crate::strings::StringTable) -> bool {
+    match val {
+        Value::Null => true,
+        Value::String(StringRef::Owned(s)) => s.is_empty(),
+        Value::String(StringRef::Interned(id)) => strings.resolve(*id).unwrap_or("").is_empty(),
+        Value::List(l) => l.is_empty(),
+        Value::Tuple(t) => t.is_empty(),
+        Value::Set(s) => s.is_empty(),
+        Value::Map(m) => m.is_empty(),
+        _ => false,
+    }
+}
+
+/// Read `json` as a value of type `ty`, written as in a LIR field type, for
+/// `json_unmarshal`. Records are built from objects by their fields' `@json`
+/// keys. `path` locates the value in error messages.
+pub(crate) fn json_to_typed_value(
+    json: &serde_json::Value,
+    ty: &str,
+    types: &TypeTable,
+    path: &str,
+) -> Result<Value, String> {
+    let mismatch = || {
+        let found = match json {
+            serde_json::Value::Null => "null",
+            serde_json::Value::Bool(_) => "a boolean",
+            serde_json::Value::Number(_) => "a number",
+            serde_json::Value::String(_) => "a string",
+            serde_json::Value::Array(_) => "an array",
+            serde_json::Value::Object(_) => "an object",
+        };
+        let at = if path.is_empty() {
+            String::new()
+        } else {
+            format!(" at {}", path)
+        };
+        format!("expected {}{}, found {}", ty, at, found)
+    };
+    let parts = split_type_list(ty, '|');
+    if parts.len() > 1 {
+        if json.is_null() && parts.contains(&"Null") {
+            return Ok(Value::Null);
+        }
+        let rest: Vec<&str> = parts.into_iter().filter(|p| *p != "Null").collect();
+        return match rest.as_slice() {
+            [only] => json_to_typed_value(json, only, types, path),
+            _ => Ok(json_to_value(json)),
+        };
+    }
+    match ty {
+        "Null" if json.is_null() => Ok(Value::Null),
+        "String" => json
+            .as_str()
+            .map(|s| Value::String(StringRef::Owned(s.to_string())))
+            .ok_or_else(mismatch),
+        "Int" => json.as_i64().map(Value::Int).ok_or_else(mismatch),
+        "Float" => json.as_f64().map(Value::Float).ok_or_else(mismatch),
+        "Bool" => json.as_bool().map(Value::Bool).ok_or_else(mismatch),
+        "Null" => Err(mismatch()),
+        _ if ty.starts_with("list[") && ty.ends_with(']') => {
+            let inner = &ty[5..ty.len() - 1];
+            let items = json.as_array().ok_or_else(mismatch)?;
+            let values = items
+                .iter()
+                .enumerate()
+                .map(|(i, item)| {
+                    json_to_typed_value(item, inner, types, &format!("{}[{}]", path, i))
+                })
+                .collect::<Result<Vec<_>, _>>()?;
+            Ok(Value::new_list(values))
+        }
+        _ if ty.starts_with("map[") && ty.ends_with(']') => {
+            let inner = split_type_list(&ty[4..ty.len() - 1], ',');
+            let value_ty = inner.get(1).copied().unwrap_or("Any");
+            let obj = json.as_object().ok_or_else(mismatch)?;
+            let mut map = BTreeMap::new();
+            for (k, v) in obj {
+                let at = field_path(path, k);
+                map.insert(k.clone(), json_to_typed_value(v, value_ty, types, &at)?);
+            }
+            Ok(Value::new_map(map))
+        }
+        _ => match types.get(ty).map(|t| &t.kind) {
+            Some(RuntimeTypeKind::Record(fields)) => {
+                let obj = json.as_object().ok_or_else(mismatch)?;
+                json_to_record(obj, ty, fields, types, path)
+            }
+            _ => Ok(json_to_value(json)),
+        },
+    }
+}
+
+/// Build a record from a JSON object. A field that is skipped or absent takes
+/// its literal default, or null when its type allows it; an absent
+/// `omit_empty` field is first read as the empty value it was omitted for.
+fn json_to_record(
+    obj: &serde_json::Map<String, serde_json::Value>,
+    type_name: &str,
+    fields: &[RuntimeField],
+    types: &TypeTable,
+    path: &str,
+) -> Result<Value, String> {
+    let plain = JsonField::default();
+    let mut values = FieldMap::new();
+    for f in fields {
+        let json = f.json.as_ref().unwrap_or(&plain);
+        let key = json.key.as_deref().unwrap_or(&f.name);
+        let at = field_path(path, key);
+        let found = if json.skip { None } else { obj.get(key) };
+        let nullable = split_type_list(&f.ty, '|').contains(&"Null");
+        let value = match found {
+            Some(v) => json_to_typed_value(v, &f.ty, types, &at)?,
+            None if json.omit_empty && !json.skip && (nullable || f.default.is_none()) => {
+                empty_value(&f.ty, nullable).ok_or_else(|| format!("missing field {}", at))?
+            }
+            None => match (&f.default, nullable) {
+                (Some(default), _) => default.clone(),
+                (None, true) => Value::Null,
+                (None, false) => return Err(format!("missing field {}", at)),
+            },
+        };
+        values.insert(f.name.clone(), value);
+    }
+    Ok(Value::new_record(RecordValue {
+        type_name: type_name.to_string(),
+        fields: values,
+    }))
+}
+
+/// The value `omit_empty` leaves out for a field of type `ty`.
+fn empty_value(ty: &str, nullable: bool) -> Option<Value> {
+    if nullable {
+        return Some(Value::Null);
+    }
+    match ty {
+        "String" => Some(Value::String(StringRef::Owned(String::new()))),
+        _ if ty.starts_with("list[") => Some(Value::new_list(vec![])),
+        _ if ty.starts_with("map[") => Some(Value::new_map(BTreeMap::new())),
+        _ if ty.starts_with("set[") => Some(Value::new_set_from_vec(vec![])),
+        _ => None,
+    }
+}
+
+fn field_path(path: &str, key: &str) -> String {
+    if path.is_empty() {
+        key.to_string()
+    } else {
+        format!("{}.{}", path, key)
+    }
+}
+
+/// Split a type list such as `A | list[B | C]` at `sep` outside brackets.
+fn split_type_list(ty: &str, sep: char) -> Vec<&str> {
+    let mut parts = Vec::new();
+    let (mut depth, mut start) = (0usize, 0);
+    for (i, c) in ty.char_indices() {
+        match c {
+            '[' | '(' => depth += 1,
+            ']' | ')' => depth = depth.saturating_sub(1),
+            _ if c == sep && depth == 0 => {
+                parts.push(ty[start..i].trim());
+                start = i + 1;
+            }
+            _ => {}
+        }
+    }
+    parts.push(ty[start..].trim());
+    parts
+}
+
 /// Convert a serde_json Value to a Lumen Value.
 pub(crate) fn json_to_value(val: &serde_json::Value) -> Value {
     match val {
@@ -294,6 +524,120 @@ pub(crate) fn json_to_value(val: &serde_json::Value) -> Value {
     }
 }
 
+/// The JSON kind of a value from `json_to_value`, with its article, as
+/// `std.json` errors name it.
+pub(crate) fn json_kind_noun(val: &Value) -> &'static str {
+    match val {
+        Value::Map(_) => "an object",
+        Value::List(_) => "an array",
+        Value::String(_) => "a string",
+        Value::Int(_) | Value::Float(_) => "a number",
+        Value::Bool(_) => "a boolean",
+        Value::Null => "null",
+        _ => "a non-JSON value",
+    }
+}
+
+/// Where a `std.json` path points, for error messages.
+fn json_location(path: &str) -> String {
+    if path.is_empty() {
+        "the root".to_string()
+    } else {
+        path.to_string()
+    }
+}
+
+/// `segment` joined onto `base` with a `.`, as `std.json` paths are written.
+pub(crate) fn json_child_path(base: &str, segment: &str) -> String {
+    if segment.is_empty() {
+        base.to_string()
+    } else if base.is_empty() {
+        segment.to_string()
+    } else {
+        format!("{}.{}", base, segment)
+    }
+}
+
+/// The value at the dot-separated `path` below `val`, which itself sits at
+/// `base`. A segment names an object key, or an array index when the value
+/// there is an array; an empty `path` is `val` itself.
+pub(crate) fn json_path_get(val: &Value, base: &str, path: &str) -> Result<Value, String> {
+    let mut current = val.clone();
+    let mut at = base.to_string();
+    if path.is_empty() {
+        return Ok(current);
+    }
+    for segment in path.split('.') {
+        if segment.is_empty() {
+            return Err(format!("json: empty segment in path \"{}\"", path));
+        }
+        let next = match &current {
+            Value::Map(entries) => entries
+                .get(segment)
+                .cloned()
+                .ok_or_else(|| format!("json: no key \"{}\" at {}", segment, json_location(&at)))?,
+            Value::List(items) => {
+                let index = segment.parse::<usize>().map_err(|_| {
+                    format!(
+                        "json: \"{}\" is not an index into the array at {}",
+                        segment,
+                        json_location(&at)
+                    )
+                })?;
+                items.get(index).cloned().ok_or_else(|| {
+                    format!(
+                        "json: index {} is out of range at {}, which has {} elements",
+                        index,
+                        json_location(&at),
+                        items.len()
+                    )
+                })?
+            }
+            other => {
+                return Err(format!(
+                    "json: cannot look up \"{}\" in {} at {}",
+                    segment,
+                    json_kind_noun(other),
+                    json_location(&at)
+                ))
+            }
+        };
+        at = json_child_path(&at, segment);
+        current = next;
+    }
+    Ok(current)
+}
+
+/// `val` when it is of the JSON `kind` ("string", "number", "boolean",
+/// "array", or "object"), or the mismatch error for a value at `path`.
+/// Numbers come back as `Float`.
+pub(crate) fn json_expect_kind(val: &Value, path: &str, kind: &str) -> Result<Value, String> {
+    let matches = match (kind, val) {
+        ("number", Value::Int(n)) => return Ok(Value::Float(*n as f64)),
+        ("number", Value::Float(_)) => true,
+        ("string", Value::String(_)) => true,
+        ("boolean", Value::Bool(_)) => true,
+        ("array", Value::List(_)) => true,
+        ("object", Value::Map(_)) => true,
+        _ => false,
+    };
+    if matches {
+        return Ok(val.clone());
+    }
+    let article = if kind.starts_with(['a', 'o']) {
+        "an"
+    } else {
+        "a"
+    };
+    Err(format!(
+        "json: expected {} {} at {}, found {}",
+        article,
+        kind,
+        json_location(path),
+        json_kind_noun(val)
+    ))
+}
+
 /// Simple base64 encode (no external dependency).
 pub(crate) fn simple_base64_encode(data: &[u8]) -> String {
     const CHARS: &[u8] = b"ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/";
@@ -384,3 +728,50 @@ pub(crate) fn value_to_str_cow<'a>(
         _ => std::borrow::Cow::Owned(val.as_string()),
     }
 }
+
+/// The runtime error for reading or writing `collection[index]` past its
+/// `len` elements, in Go's form: `index 5 out of range [0:3]`. Negative
+/// indices count from the end, so for one of those the range starts at
+/// `-len`.
+pub(crate) fn index_out_of_range(index: i64, len: usize) -> VmError {
+    let first = if index < 0 { -(len as i64) } else { 0 };
+    VmError::Runtime(format!("index {} out of range [{}:{}]", index, first, len))
+}
+
+/// The runtime error for `target[key] = v` or `target.key = v` when
+/// `target` is null, typically a map that was never initialized.
+pub(crate) fn null_entry_write(key: &Value, strings: &crate::strings::StringTable) -> VmError {
+    match key {
+        Value::String(_) => VmError::Runtime(format!(
+            "assignment to key \"{}\" in null map",
+            value_to_str_cow(key, strings)
+        )),
+        _ => VmError::Runtime(format!(
+            "assignment to index {} of null",
+            key.display_pretty()
+        )),
+    }
+}
+
+/// The elements of a `float_vec_*` operand as `f64`s. `Int` elements widen.
+pub(crate) fn float_vec_operand(builtin: &str, value: &Value) -> Result<Vec<f64>, VmError> {
+    let Value::List(items) = value else {
+        return Err(VmError::Runtime(format!(
+            "{}: expected a list of floats, got {}",
+            builtin,
+            value.type_name()
+        )));
+    };
+    items
+        .iter()
+        .map(|item| match item {
+            Value::Float(f) => Ok(*f),
+            Value::Int(n) => Ok(*n as f64),
+            other => Err(VmError::Runtime(format!(
+                "{}: expected a list of floats, found {} element",
+                builtin,
+                other.type_name()
+            ))),
+        })
+        .collect()
+}
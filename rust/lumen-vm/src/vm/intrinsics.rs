@@ -1,7 +1,15 @@
 //! Builtin function dispatch, intrinsic opcodes, and closure calls for the VM.
 
+use super::memo::{memo_key, MemoCache};
 use super::*;
 use lumen_compiler::compile_raw;
+use lumen_runtime::csv;
+use lumen_runtime::encoding;
+use lumen_runtime::hash::{Algorithm, StreamHasher};
+use lumen_runtime::linalg::{self, Matrix};
+use lumen_runtime::path::{self as lexical_path, Style};
+use lumen_runtime::subprocess;
+use lumen_runtime::unicode;
 use num_bigint::BigInt;
 use num_traits::{Signed, ToPrimitive};
 use std::collections::{BTreeMap, BTreeSet};
@@ -28,14 +36,24 @@ impl VM {
                     parts.push(val.display_pretty());
                 }
                 let output = parts.join(" ");
-                println!("{}", output);
-                self.output.push(output);
+                self.stdout.write_line(&output);
+                self.capture_line(output);
+                Ok(Value::Null)
+            }
+            "flush" => {
+                self.stdout
+                    .flush()
+                    .map_err(|e| VmError::Runtime(format!("flush failed: {}", e)))?;
                 Ok(Value::Null)
             }
             "len" | "length" => {
                 let arg = &self.registers[base + a + 1];
                 Ok(match arg {
                     Value::String(StringRef::Owned(s)) => Value::Int(s.len() as i64),
+                    Value::String(StringRef::Interned(id)) => {
+                        let s = self.strings.resolve(*id).unwrap_or("");
+                        Value::Int(s.len() as i64)
+                    }
                     Value::List(l) => Value::Int(l.len() as i64),
                     Value::Map(m) => Value::Int(m.len() as i64),
                     Value::Tuple(t) => Value::Int(t.len() as i64),
@@ -93,6 +111,99 @@ impl VM {
                 let arg = &self.registers[base + a + 1];
                 Ok(Value::String(StringRef::Owned(arg.type_name().to_string())))
             }
+            // Reflection (std.reflect)
+            "reflect_kind" => {
+                let arg = &self.registers[base + a + 1];
+                Ok(Value::String(StringRef::Owned(
+                    reflect_kind(arg).to_string(),
+                )))
+            }
+            "reflect_type_name" => {
+                let arg = &self.registers[base + a + 1];
+                let name = match arg {
+                    Value::Union(u) => {
+                        let tag = self.strings.resolve(u.tag).unwrap_or("Union");
+                        self.types.enum_of_variant(tag).unwrap_or(tag).to_string()
+                    }
+                    other => other.type_name().to_string(),
+                };
+                Ok(Value::String(StringRef::Owned(name)))
+            }
+            "reflect_fields" => {
+                let arg = &self.registers[base + a + 1];
+                let Value::Record(r) = arg else {
+                    return Ok(Value::new_list(vec![]));
+                };
+                let field = |name: &str, ty: &str| {
+                    Value::new_tuple(vec![
+                        Value::String(StringRef::Owned(name.to_string())),
+                        Value::String(StringRef::Owned(ty.to_string())),
+                    ])
+                };
+                let fields = match self.types.get(&r.type_name).map(|t| &t.kind) {
+                    Some(RuntimeTypeKind::Record(declared)) => {
+                        declared.iter().map(|f| field(&f.name, &f.ty)).collect()
+                    }
+                    // Not declared in a loaded module: report what the value holds
+                    _ => r
+                        .fields
+                        .iter()
+                        .map(|(k, v)| field(k, v.type_name_resolved(&self.strings)))
+                        .collect(),
+                };
+                Ok(Value::new_list(fields))
+            }
+            "reflect_get" => {
+                let arg = &self.registers[base + a + 1];
+                let key = &self.registers[base + a + 2];
+                let index = |len: usize| -> Option<usize> {
+                    let i = key.as_int()?;
+                    let i = if i < 0 { i + len as i64 } else { i };
+                    (0..len as i64).contains(&i).then_some(i as usize)
+                };
+                Ok(match arg {
+                    Value::Record(r) => {
+                        let name = value_to_str_cow(key, &self.strings);
+                        r.fields.get(name.as_ref()).cloned().unwrap_or(Value::Null)
+                    }
+                    Value::Map(m) => {
+                        let name = value_to_str_cow(key, &self.strings);
+                        m.get(name.as_ref()).cloned().unwrap_or(Value::Null)
+                    }
+                    Value::List(l) => index(l.len()).map_or(Value::Null, |i| l[i].clone()),
+                    Value::Tuple(t) => index(t.len()).map_or(Value::Null, |i| t[i].clone()),
+                    Value::Bytes(b) => {
+                        index(b.len()).map_or(Value::Null, |i| Value::Int(b[i] as i64))
+                    }
+                    _ => Value::Null,
+                })
+            }
+            "reflect_len" => {
+                let arg = &self.registers[base + a + 1];
+                let len = match arg {
+                    Value::Record(r) => r.fields.len(),
+                    Value::List(l) => l.len(),
+                    Value::Tuple(t) => t.len(),
+                    Value::Set(s) => s.len(),
+                    Value::Map(m) => m.len(),
+                    Value::Bytes(b) => b.len(),
+                    _ => 0,
+                };
+                Ok(Value::Int(len as i64))
+            }
+            "reflect_elem_type" => {
+                let arg = &self.registers[base + a + 1];
+                let strings = &self.strings;
+                let ty = match arg {
+                    Value::List(l) => common_type_name(l.iter(), strings),
+                    Value::Tuple(t) => common_type_name(t.iter(), strings),
+                    Value::Set(s) => common_type_name(s.iter(), strings),
+                    Value::Map(m) => common_type_name(m.values(), strings),
+                    Value::Bytes(_) => "Int",
+                    _ => "Null",
+                };
+                Ok(Value::String(StringRef::Owned(ty.to_string())))
+            }
             "keys" => {
                 let arg = &self.registers[base + a + 1];
                 Ok(match arg {
@@ -400,6 +511,29 @@ impl VM {
                 let h = format!("sha256:{:x}", Sha256::digest(s.as_bytes()));
                 Ok(Value::String(StringRef::Owned(h)))
             }
+            // ── Streaming hashers (std.hash) ──
+            // The hasher state travels through Lumen code as Bytes.
+            "hash_new" => {
+                let name = value_to_str_cow(&self.registers[base + a + 1], &self.strings);
+                match Algorithm::from_name(&name) {
+                    Some(alg) => Ok(self.ok_value(Value::Bytes(StreamHasher::new(alg).state()))),
+                    None => Ok(self.err_value(Value::String(StringRef::Owned(format!(
+                        "unknown hash algorithm: {}",
+                        name
+                    ))))),
+                }
+            }
+            "hash_write" | "hash_sum" => {
+                let mut hasher = self.hasher_arg(name, base + a + 1)?;
+                if name == "hash_sum" {
+                    return Ok(Value::Bytes(hasher.sum()));
+                }
+                match &self.registers[base + a + 3] {
+                    Value::Bytes(b) => hasher.write(b),
+                    other => hasher.write(value_to_str_cow(other, &self.strings).as_bytes()),
+                }
+                Ok(Value::Bytes(hasher.state()))
+            }
             // Collection ops
             "sort" => {
                 let arg = std::mem::take(&mut self.registers[base + a + 1]);
@@ -502,6 +636,113 @@ impl VM {
                         .collect(),
                 ))
             }
+            // ── UTF-8: byte- and rune-level access ──
+            // `len`, `s[i]`, and `index_of` count UTF-8 bytes; the rune API
+            // (`rune_count`, `runes`, `rune_at`) counts Unicode scalar values.
+            "byte_len" => {
+                let s = value_to_str_cow(&self.registers[base + a + 1], &self.strings);
+                Ok(Value::Int(s.len() as i64))
+            }
+            "rune_count" => {
+                let s = value_to_str_cow(&self.registers[base + a + 1], &self.strings);
+                Ok(Value::Int(s.chars().count() as i64))
+            }
+            "runes" => {
+                let s = value_to_str_cow(&self.registers[base + a + 1], &self.strings);
+                Ok(Value::new_list(
+                    s.chars().map(|c| Value::Int(c as u32 as i64)).collect(),
+                ))
+            }
+            "byte_at" => {
+                let s = value_to_str_cow(&self.registers[base + a + 1], &self.strings);
+                let idx = self.registers[base + a + 2].as_int().unwrap_or(-1);
+                Ok(if idx >= 0 && (idx as usize) < s.len() {
+                    Value::Int(s.as_bytes()[idx as usize] as i64)
+                } else {
+                    Value::Null
+                })
+            }
+            "rune_at" => {
+                let s = value_to_str_cow(&self.registers[base + a + 1], &self.strings);
+                let idx = self.registers[base + a + 2].as_int().unwrap_or(-1);
+                Ok(if idx >= 0 {
+                    match s.chars().nth(idx as usize) {
+                        Some(c) => Value::String(StringRef::Owned(c.to_string())),
+                        None => Value::Null,
+                    }
+                } else {
+                    Value::Null
+                })
+            }
+            "from_runes" => {
+                let out: String = match &self.registers[base + a + 1] {
+                    Value::List(l) => l
+                        .iter()
+                        .map(|v| {
+                            v.as_int()
+                                .and_then(|n| u32::try_from(n).ok())
+                                .and_then(char::from_u32)
+                                .unwrap_or(char::REPLACEMENT_CHARACTER)
+                        })
+                        .collect(),
+                    _ => String::new(),
+                };
+                Ok(Value::String(StringRef::Owned(out)))
+            }
+            // Runes are Unicode scalar values; any other Int is neither a
+            // digit, letter, nor space, and has no case to change.
+            "rune_is_digit" | "rune_is_letter" | "rune_is_space" => {
+                let c = self.registers[base + a + 1]
+                    .as_int()
+                    .and_then(|n| u32::try_from(n).ok())
+                    .and_then(char::from_u32);
+                let class = match name {
+                    "rune_is_digit" => unicode::is_digit,
+                    "rune_is_letter" => unicode::is_letter,
+                    _ => unicode::is_space,
+                };
+                Ok(Value::Bool(c.is_some_and(class)))
+            }
+            "rune_to_upper" | "rune_to_lower" => {
+                let arg = &self.registers[base + a + 1];
+                let c = arg
+                    .as_int()
+                    .and_then(|n| u32::try_from(n).ok())
+                    .and_then(char::from_u32);
+                Ok(match c {
+                    Some(c) if name == "rune_to_upper" => {
+                        Value::Int(unicode::to_upper(c) as u32 as i64)
+                    }
+                    Some(c) => Value::Int(unicode::to_lower(c) as u32 as i64),
+                    None => arg.clone(),
+                })
+            }
+            "utf8_encode" => {
+                let s = value_to_str_cow(&self.registers[base + a + 1], &self.strings);
+                Ok(Value::Bytes(s.as_bytes().to_vec()))
+            }
+            "utf8_decode" => match &self.registers[base + a + 1] {
+                Value::Bytes(b) => match std::str::from_utf8(b) {
+                    Ok(s) => Ok(self.ok_value(Value::String(StringRef::Owned(s.to_string())))),
+                    Err(e) => Ok(self.err_value(Value::String(StringRef::Owned(format!(
+                        "invalid UTF-8 at byte {}",
+                        e.valid_up_to()
+                    ))))),
+                },
+                other => Err(VmError::TypeError(format!(
+                    "utf8_decode expects Bytes, got {}",
+                    other.type_name()
+                ))),
+            },
+            "intern" => match &self.registers[base + a + 1] {
+                Value::String(StringRef::Interned(id)) => {
+                    Ok(Value::String(StringRef::Interned(*id)))
+                }
+                other => {
+                    let s = value_to_str_cow(other, &self.strings).into_owned();
+                    Ok(Value::String(StringRef::Interned(self.strings.intern(&s))))
+                }
+            },
             "starts_with" => {
                 let s = value_to_str_cow(&self.registers[base + a + 1], &self.strings);
                 let prefix = value_to_str_cow(&self.registers[base + a + 2], &self.strings);
@@ -754,8 +995,13 @@ impl VM {
                 }
             }
             "hex_encode" => {
-                let s = value_to_str_cow(&self.registers[base + a + 1], &self.strings);
-                let hex: String = s.bytes().map(|b| format!("{:02x}", b)).collect();
+                let hex: String = match &self.registers[base + a + 1] {
+                    Value::Bytes(b) => b.iter().map(|b| format!("{:02x}", b)).collect(),
+                    other => value_to_str_cow(other, &self.strings)
+                        .bytes()
+                        .map(|b| format!("{:02x}", b))
+                        .collect(),
+                };
                 Ok(Value::String(StringRef::Owned(hex)))
             }
             "hex_decode" => {
@@ -778,6 +1024,33 @@ impl VM {
                     String::from_utf8_lossy(&bytes).to_string(),
                 )))
             }
+            // `std.encoding`: strict codecs over Bytes, failing with a result
+            "bytes_to_base64" | "bytes_to_hex" => {
+                let data = match &self.registers[base + a + 1] {
+                    Value::Bytes(b) => b.clone(),
+                    other => value_to_str_cow(other, &self.strings).as_bytes().to_vec(),
+                };
+                let encoded = if name == "bytes_to_base64" {
+                    encoding::base64_encode(&data)
+                } else {
+                    encoding::hex_encode(&data)
+                };
+                Ok(Value::String(StringRef::Owned(encoded)))
+            }
+            "base64_to_bytes" | "hex_to_bytes" => {
+                let s = value_to_str_cow(&self.registers[base + a + 1], &self.strings);
+                let (codec, decoded) = if name == "base64_to_bytes" {
+                    ("base64", encoding::base64_decode(&s))
+                } else {
+                    ("hex", encoding::hex_decode(&s))
+                };
+                Ok(match decoded {
+                    Ok(bytes) => self.ok_value(Value::Bytes(bytes)),
+                    Err(e) => {
+                        self.err_value(Value::String(StringRef::Owned(format!("{}: {}", codec, e))))
+                    }
+                })
+            }
             "url_encode" => {
                 let s = value_to_str_cow(&self.registers[base + a + 1], &self.strings);
                 let mut encoded = String::new();
@@ -835,6 +1108,70 @@ impl VM {
                     .map_err(|e| VmError::Runtime(format!("json_pretty failed: {}", e)))?;
                 Ok(Value::String(StringRef::Owned(pretty)))
             }
+            // `std.json` Value: parse errors are reported, not turned into null
+            "json_value_parse" => {
+                let text = value_to_str_cow(&self.registers[base + a + 1], &self.strings);
+                Ok(match serde_json::from_str::<serde_json::Value>(&text) {
+                    Ok(v) => self.ok_value(json_to_value(&v)),
+                    Err(e) => {
+                        self.err_value(Value::String(StringRef::Owned(format!("json: {}", e))))
+                    }
+                })
+            }
+            "json_value_get" | "json_value_as" => {
+                let at = value_to_str_cow(&self.registers[base + a + 2], &self.strings);
+                let arg = value_to_str_cow(&self.registers[base + a + 3], &self.strings);
+                let val = &self.registers[base + a + 1];
+                let found = if name == "json_value_get" {
+                    json_path_get(val, &at, &arg)
+                } else {
+                    json_expect_kind(val, &at, &arg)
+                };
+                Ok(match found {
+                    Ok(v) => self.ok_value(v),
+                    Err(e) => self.err_value(Value::String(StringRef::Owned(e))),
+                })
+            }
+            "json_child_path" => {
+                let at = value_to_str_cow(&self.registers[base + a + 1], &self.strings);
+                let segment = value_to_str_cow(&self.registers[base + a + 2], &self.strings);
+                Ok(Value::String(StringRef::Owned(json_child_path(
+                    &at, &segment,
+                ))))
+            }
+            "json_kind" => {
+                let noun = json_kind_noun(&self.registers[base + a + 1]);
+                let kind = noun.rsplit(' ').next().unwrap_or(noun);
+                Ok(Value::String(StringRef::Owned(kind.to_string())))
+            }
+            "json_marshal" => {
+                let val = &self.registers[base + a + 1];
+                let j = value_to_field_json(val, &self.types, &self.strings);
+                Ok(Value::String(StringRef::Owned(j.to_string())))
+            }
+            "json_unmarshal" => {
+                let text = value_to_str_cow(&self.registers[base + a + 1], &self.strings);
+                let type_name =
+                    value_to_str_cow(&self.registers[base + a + 2], &self.strings).into_owned();
+                if !matches!(
+                    self.types.get(&type_name).map(|t| &t.kind),
+                    Some(RuntimeTypeKind::Record(_))
+                ) {
+                    return Ok(self.err_value(Value::String(StringRef::Owned(format!(
+                        "unmarshal: unknown record type {}",
+                        type_name
+                    )))));
+                }
+                let result = serde_json::from_str::<serde_json::Value>(&text)
+                    .map_err(|e| e.to_string())
+                    .and_then(|j| json_to_typed_value(&j, &type_name, &self.types, ""));
+                Ok(match result {
+                    Ok(v) => self.ok_value(v),
+                    Err(e) => {
+                        self.err_value(Value::String(StringRef::Owned(format!("unmarshal: {}", e))))
+                    }
+                })
+            }
             // String case transforms (std.string)
             "capitalize" => {
                 let s = value_to_str_cow(&self.registers[base + a + 1], &self.strings);
@@ -951,8 +1288,8 @@ impl VM {
             // Emit/debug
             "emit" => {
                 let val = self.registers[base + a + 1].display_pretty();
-                println!("{}", val);
-                self.output.push(val);
+                self.stdout.write_line(&val);
+                self.capture_line(val);
                 Ok(Value::Null)
             }
             "debug" => {
@@ -1183,6 +1520,58 @@ impl VM {
                     Err(e) => Err(VmError::Runtime(format!("write_file failed: {}", e))),
                 }
             }
+            // Result-returning filesystem primitives backing std.fs.
+            // Failures are reported as err(map) with "kind", "path" and "message"
+            // keys instead of aborting execution.
+            "fs_read" => {
+                let path =
+                    value_to_str_cow(&self.registers[base + a + 1], &self.strings).into_owned();
+                Ok(match std::fs::read(&path) {
+                    Ok(data) => self.ok_value(Value::Bytes(data)),
+                    Err(e) => self.err_value(fs_error_payload(&path, &e)),
+                })
+            }
+            // Opens the file on every call: a std.fs reader holds a path and
+            // an offset, not a handle
+            "fs_read_chunk" => {
+                use std::io::{Read, Seek, SeekFrom};
+                let path =
+                    value_to_str_cow(&self.registers[base + a + 1], &self.strings).into_owned();
+                let offset = self.registers[base + a + 2].as_int().unwrap_or(0).max(0) as u64;
+                let max = self.registers[base + a + 3].as_int().unwrap_or(0).max(0) as usize;
+                let result = std::fs::File::open(&path).and_then(|mut file| {
+                    file.seek(SeekFrom::Start(offset))?;
+                    let mut buf = Vec::with_capacity(max);
+                    file.take(max as u64).read_to_end(&mut buf)?;
+                    Ok(buf)
+                });
+                Ok(match result {
+                    Ok(data) => self.ok_value(Value::Bytes(data)),
+                    Err(e) => self.err_value(fs_error_payload(&path, &e)),
+                })
+            }
+            "fs_write" | "fs_append" => {
+                use std::io::Write;
+                let path =
+                    value_to_str_cow(&self.registers[base + a + 1], &self.strings).into_owned();
+                let data = match &self.registers[base + a + 2] {
+                    Value::Bytes(b) => b.clone(),
+                    other => value_to_str_cow(other, &self.strings)
+                        .into_owned()
+                        .into_bytes(),
+                };
+                let result = std::fs::OpenOptions::new()
+                    .write(true)
+                    .create(true)
+                    .append(name == "fs_append")
+                    .truncate(name == "fs_write")
+                    .open(&path)
+                    .and_then(|mut file| file.write_all(&data));
+                Ok(match result {
+                    Ok(()) => self.ok_value(Value::Null),
+                    Err(e) => self.err_value(fs_error_payload(&path, &e)),
+                })
+            }
             // Random
             "random" => {
                 use std::cell::Cell;
@@ -1237,6 +1626,67 @@ impl VM {
                 }
                 Ok(Value::String(StringRef::Owned(result)))
             }
+            // ── Go-style % formatting ──
+            "sprintf" | "printf" => {
+                let template =
+                    value_to_str_cow(&self.registers[base + a + 1], &self.strings).into_owned();
+                let args: Vec<Value> = (1..nargs)
+                    .map(|i| match &self.registers[base + a + 1 + i] {
+                        v @ Value::String(StringRef::Interned(_)) => Value::String(
+                            StringRef::Owned(value_to_str_cow(v, &self.strings).into_owned()),
+                        ),
+                        v => v.clone(),
+                    })
+                    .collect();
+                let text = super::printf::sprintf(&template, &args);
+                if name == "printf" {
+                    self.stdout.write_str(&text);
+                    self.capture_text(&text);
+                    return Ok(Value::Null);
+                }
+                Ok(Value::String(StringRef::Owned(text)))
+            }
+            // ── Fixed-precision floats (std.fmt) ──
+            "format_fixed" => {
+                let x = match &self.registers[base + a + 1] {
+                    Value::Float(f) => *f,
+                    Value::Int(n) => *n as f64,
+                    other => {
+                        return Err(VmError::Runtime(format!(
+                            "format_fixed: expected a number, got {}",
+                            other.type_name()
+                        )))
+                    }
+                };
+                let precision = match &self.registers[base + a + 2] {
+                    Value::Int(p) if *p >= 0 => *p as usize,
+                    other => {
+                        return Err(VmError::Runtime(format!(
+                            "format_fixed: precision must be a non-negative Int, got {}",
+                            other.display_pretty()
+                        )))
+                    }
+                };
+                Ok(Value::String(StringRef::Owned(format_fixed(x, precision))))
+            }
+            // Decimal integers as Go's `%d` prints them (std.fmt)
+            "int_to_decimal" => {
+                let unsigned = self.registers[base + a + 2].is_truthy();
+                let text = match &self.registers[base + a + 1] {
+                    // Unsigned reads the Int's 64 bits as a u64, as Go's
+                    // `uint64(x)` conversion does
+                    Value::Int(n) if unsigned => (*n as u64).to_string(),
+                    Value::Int(n) => n.to_string(),
+                    Value::BigInt(n) => n.to_string(),
+                    other => {
+                        return Err(VmError::Runtime(format!(
+                            "int_to_decimal: expected an Int, got {}",
+                            other.type_name()
+                        )))
+                    }
+                };
+                Ok(Value::String(StringRef::Owned(text)))
+            }
             "partition" => {
                 let list = self.registers[base + a].clone();
                 let predicate = self.registers[base + a + 1].clone();
@@ -1309,8 +1759,103 @@ impl VM {
                     Err(e) => Err(VmError::Runtime(format!("mkdir failed: {}", e))),
                 }
             }
+            // ── Panics: unrecoverable unless caught by a `recover` boundary ──
+            "panic" => {
+                let msg = self.registers[base + a + 1].display_pretty();
+                Err(VmError::Panic(msg))
+            }
+            "todo" | "unimplemented" => {
+                // The compiler passes the call's line ahead of the user's message
+                let line = self.registers[base + a + 1].as_int().unwrap_or(0);
+                let what = if name == "todo" {
+                    "not yet implemented"
+                } else {
+                    "not implemented"
+                };
+                let msg = if nargs > 1 {
+                    format!(
+                        "{}: {} at line {}",
+                        what,
+                        self.registers[base + a + 2].display_pretty(),
+                        line
+                    )
+                } else {
+                    format!("{} at line {}", what, line)
+                };
+                Err(VmError::Panic(msg))
+            }
+            "recover" => {
+                let callee = self.registers[base + a + 1].clone();
+                let closure = self.callable_arg("recover", callee)?;
+                let frame_depth = self.frames.len();
+                let handler_depth = self.effect_handlers.len();
+                let register_len = self.registers.len();
+                let register_top = self.register_top;
+                match self.call_closure_sync(&closure, &[]) {
+                    Ok(v) => Ok(self.ok_value(v)),
+                    Err(e) => match e.panic_message() {
+                        Some(msg) => {
+                            // Unwind everything the panicking call left behind.
+                            self.frames.truncate(frame_depth);
+                            self.effect_handlers.truncate(handler_depth);
+                            self.registers.truncate(register_len);
+                            self.register_top = register_top;
+                            Ok(self.err_value(Value::String(StringRef::Owned(msg))))
+                        }
+                        None => Err(e),
+                    },
+                }
+            }
+            // ── Weak references: do not keep their target alive ──
+            "weak" => {
+                let target = &self.registers[base + a + 1];
+                match target.downgrade() {
+                    Some(w) => Ok(Value::Weak(w)),
+                    None => Err(VmError::Runtime(format!(
+                        "weak expects a list, tuple, set, map or record, got {}",
+                        target.type_name()
+                    ))),
+                }
+            }
+            "upgrade" => match &self.registers[base + a + 1] {
+                Value::Weak(w) => Ok(w.upgrade().unwrap_or(Value::Null)),
+                other => Err(VmError::Runtime(format!(
+                    "upgrade expects a Weak, got {}",
+                    other.type_name()
+                ))),
+            },
+            // ── Finalizers: run by `run_finalizers` after their object is freed ──
+            "set_finalizer" => {
+                let target = &self.registers[base + a + 1];
+                let Some(weak) = target.downgrade() else {
+                    return Err(VmError::Runtime(format!(
+                        "set_finalizer expects a list, tuple, set, map or record, got {}",
+                        target.type_name()
+                    )));
+                };
+                let callee = self.registers[base + a + 2].clone();
+                let callback = self.callable_arg("set_finalizer", callee)?;
+                // A callback holding its own object would keep it alive forever
+                if callback
+                    .captures
+                    .iter()
+                    .any(|c| c.downgrade().is_some_and(|w| w.addr() == weak.addr()))
+                {
+                    return Err(VmError::Runtime(
+                        "set_finalizer callback must not capture the object it finalizes".into(),
+                    ));
+                }
+                self.finalizers.push(Finalizer {
+                    target: weak,
+                    callback,
+                });
+                Ok(Value::Null)
+            }
+            "run_finalizers" => Ok(Value::Int(self.run_finalizers()? as i64)),
+            "backtrace" => Ok(self.backtrace_value()),
             "exit" => {
                 let code = self.registers[base + a].as_int().unwrap_or(0);
+                let _ = self.stdout.flush();
                 std::process::exit(code as i32);
             }
             // String trimming variants
@@ -1453,6 +1998,34 @@ impl VM {
                 Ok(Value::String(StringRef::Owned(result)))
             }
 
+            // ── @memoize caches, called by the cell the compiler puts in
+            // front of a memoized body ──
+            "__memo_lookup" => {
+                let key = memo_key(&self.registers[base + a + 2], &self.strings);
+                let cell = value_to_str_cow(&self.registers[base + a + 1], &self.strings);
+                let hit = self
+                    .memo_caches
+                    .get_mut(cell.as_ref())
+                    .and_then(|cache| cache.get(&key));
+                Ok(Value::new_list(hit.into_iter().collect()))
+            }
+            "__memo_store" => {
+                let cell =
+                    value_to_str_cow(&self.registers[base + a + 1], &self.strings).into_owned();
+                // Zero is `@memoize` without a bound
+                let capacity = match self.registers[base + a + 2] {
+                    Value::Int(n) if n > 0 => Some(n as usize),
+                    _ => None,
+                };
+                let key = memo_key(&self.registers[base + a + 3], &self.strings);
+                let value = self.registers[base + a + 4].clone();
+                self.memo_caches
+                    .entry(cell)
+                    .or_insert_with(|| MemoCache::new(capacity))
+                    .insert(key, value.clone());
+                Ok(value)
+            }
+
             // ── T123: Wrapping arithmetic builtins ──
             "wrapping_add" => {
                 if nargs != 2 {
@@ -1503,8 +2076,62 @@ impl VM {
                     )),
                 }
             }
+            // ── Capacity-controlled lists (std.collections Vec) ──
+            // Each returns the updated list; copies of a shared list keep
+            // its capacity, so what was reserved stays reserved.
+            "list_with_capacity" => {
+                let n = self.registers[base + a + 1].as_int().unwrap_or(0);
+                if n < 0 {
+                    return Err(VmError::Runtime(format!(
+                        "list_with_capacity: capacity must be non-negative, got {}",
+                        n
+                    )));
+                }
+                if let Some(f) = self.frames.last() {
+                    let (cell_idx, ip) = (f.cell_idx, f.ip.saturating_sub(1));
+                    self.record_alloc(cell_idx, ip, n as usize);
+                }
+                Ok(Value::new_list(Vec::with_capacity(n as usize)))
+            }
+            "list_capacity" => match &self.registers[base + a + 1] {
+                Value::List(l) => Ok(Value::Int(l.capacity() as i64)),
+                other => Err(VmError::Runtime(format!(
+                    "list_capacity requires a list, got {}",
+                    other.type_name()
+                ))),
+            },
+            "list_reserve" | "list_push" | "list_pop" => {
+                let mut l = match std::mem::take(&mut self.registers[base + a + 1]) {
+                    Value::List(l) => l,
+                    other => {
+                        return Err(VmError::Runtime(format!(
+                            "{} requires a list as first argument, got {}",
+                            name,
+                            other.type_name()
+                        )))
+                    }
+                };
+                let items = list_mut_keep_capacity(&mut l);
+                match name {
+                    "list_reserve" => {
+                        let n = self.registers[base + a + 2].as_int().unwrap_or(0);
+                        if n < 0 {
+                            return Err(VmError::Runtime(format!(
+                                "list_reserve: additional must be non-negative, got {}",
+                                n
+                            )));
+                        }
+                        items.reserve_exact(n as usize);
+                    }
+                    "list_push" => items.push(std::mem::take(&mut self.registers[base + a + 2])),
+                    _ => {
+                        items.pop();
+                    }
+                }
+                Ok(Value::List(l))
+            }
 
-            // ── String-to-number parsing: parse_int / parse_float ──
+            // ── String-to-number parsing: parse_int / parse_int_radix / parse_float ──
             "parse_int" => {
                 let s = value_to_str_cow(&self.registers[base + a + 1], &self.strings);
                 let tag_ok = self.tag_ok;
@@ -1529,22 +2156,27 @@ impl VM {
                     },
                 }
             }
+            "parse_int_radix" => {
+                let s = value_to_str_cow(&self.registers[base + a + 1], &self.strings);
+                let radix = match &self.registers[base + a + 2] {
+                    Value::Int(r) => *r,
+                    other => {
+                        return Err(VmError::Runtime(format!(
+                            "parse_int_radix: base must be an Int, got {}",
+                            other.type_name()
+                        )))
+                    }
+                };
+                match parse_int_radix_str(&s, radix) {
+                    Ok(n) => Ok(self.ok_value(Value::Int(n))),
+                    Err(e) => Ok(self.err_value(Value::String(StringRef::Owned(e)))),
+                }
+            }
             "parse_float" => {
                 let s = value_to_str_cow(&self.registers[base + a + 1], &self.strings);
-                let tag_ok = self.tag_ok;
-                let tag_err = self.tag_err;
-                match s.trim().parse::<f64>() {
-                    Ok(f) => Ok(Value::Union(UnionValue {
-                        tag: tag_ok,
-                        payload: Arc::new(Value::Float(f)),
-                    })),
-                    Err(_) => Ok(Value::Union(UnionValue {
-                        tag: tag_err,
-                        payload: Arc::new(Value::String(StringRef::Owned(format!(
-                            "invalid float: {}",
-                            s
-                        )))),
-                    })),
+                match parse_float_str(&s) {
+                    Ok(f) => Ok(self.ok_value(Value::Float(f))),
+                    Err(e) => Ok(self.err_value(Value::String(StringRef::Owned(e)))),
                 }
             }
 
@@ -1611,6 +2243,20 @@ impl VM {
                 })
             }
 
+            // Index of the first `byte` at or after `from`, or -1
+            "bytes_find" => {
+                let needle = self.registers[base + a + 2].as_int();
+                let from = self.registers[base + a + 3].as_int().unwrap_or(0).max(0) as usize;
+                Ok(match (&self.registers[base + a + 1], needle) {
+                    (Value::Bytes(b), Some(needle)) => Value::Int(
+                        b.get(from..)
+                            .and_then(|rest| rest.iter().position(|&x| x as i64 == needle))
+                            .map_or(-1, |i| (from + i) as i64),
+                    ),
+                    _ => Value::Null,
+                })
+            }
+
             // ── Schema validation builtin ──
             "validate" => {
                 if nargs < 2 {
@@ -1722,6 +2368,84 @@ impl VM {
                     .unwrap_or_default();
                 Ok(Value::String(StringRef::Owned(stem)))
             }
+            // `std.path`: lexical rules of the host platform, so results do
+            // not depend on what exists on disk
+            "path_join_all" => match &self.registers[base + a + 1] {
+                Value::List(items) => {
+                    let parts: Vec<String> = items
+                        .iter()
+                        .map(|p| value_to_str_cow(p, &self.strings).into_owned())
+                        .collect();
+                    let parts: Vec<&str> = parts.iter().map(String::as_str).collect();
+                    Ok(Value::String(StringRef::Owned(lexical_path::join(
+                        &parts,
+                        Style::native(),
+                    ))))
+                }
+                other => Err(VmError::TypeError(format!(
+                    "path_join_all expects a list of path segments, got {}",
+                    other.type_name()
+                ))),
+            },
+            "path_clean" | "path_base" | "path_dir" | "path_ext" => {
+                let p = value_to_str_cow(&self.registers[base + a + 1], &self.strings);
+                let style = Style::native();
+                let out = match name {
+                    "path_clean" => lexical_path::clean(&p, style),
+                    "path_base" => lexical_path::base(&p, style),
+                    "path_dir" => lexical_path::dir(&p, style),
+                    _ => lexical_path::ext(&p, style),
+                };
+                Ok(Value::String(StringRef::Owned(out)))
+            }
+            "path_is_abs" => {
+                let p = value_to_str_cow(&self.registers[base + a + 1], &self.strings);
+                Ok(Value::Bool(lexical_path::is_abs(&p, Style::native())))
+            }
+            "path_separator" => Ok(Value::String(StringRef::Owned(
+                Style::native().separator().to_string(),
+            ))),
+            // `std.bits`: an Int is read as its 64-bit two's complement
+            // pattern. Each operation is a single instruction (popcnt,
+            // lzcnt, tzcnt, rol/ror, bswap) where the target has one.
+            "bits_popcount" | "bits_leading_zeros" | "bits_trailing_zeros" | "bits_swap_bytes" => {
+                let x = bits_int_arg(name, &self.registers[base + a + 1])?;
+                Ok(Value::Int(match name {
+                    "bits_popcount" => x.count_ones() as i64,
+                    "bits_leading_zeros" => x.leading_zeros() as i64,
+                    "bits_trailing_zeros" => x.trailing_zeros() as i64,
+                    _ => x.swap_bytes(),
+                }))
+            }
+            // `std.linalg`: matrices are lists of equal-length rows of numbers
+            "linalg_matmul" => {
+                let lhs = linalg_matrix(name, &self.registers[base + a + 1])?;
+                let rhs = linalg_matrix(name, &self.registers[base + a + 2])?;
+                linalg::matmul(&lhs, &rhs)
+                    .map(|m| matrix_value(&m))
+                    .map_err(|e| VmError::Runtime(format!("{}: {}", name, e)))
+            }
+            "linalg_transpose" => {
+                let m = linalg_matrix(name, &self.registers[base + a + 1])?;
+                Ok(matrix_value(&linalg::transpose(&m)))
+            }
+            "linalg_dot" => {
+                let lhs = float_vec_operand(name, &self.registers[base + a + 1])?;
+                let rhs = float_vec_operand(name, &self.registers[base + a + 2])?;
+                linalg::dot(&lhs, &rhs)
+                    .map(Value::Float)
+                    .map_err(|e| VmError::Runtime(format!("{}: {}", name, e)))
+            }
+            "bits_rotate_left" | "bits_rotate_right" => {
+                let x = bits_int_arg(name, &self.registers[base + a + 1])?;
+                // A negative amount rotates the other way
+                let by = bits_int_arg(name, &self.registers[base + a + 2])?.rem_euclid(64) as u32;
+                Ok(Value::Int(if name == "bits_rotate_left" {
+                    x.rotate_left(by)
+                } else {
+                    x.rotate_right(by)
+                }))
+            }
 
             // ── Process execution ──
             "exec" => {
@@ -1751,10 +2475,56 @@ impl VM {
                     Err(e) => Err(VmError::Runtime(format!("exec failed: {}", e))),
                 }
             }
+            // `std.os.exec`: the program runs without a shell, and failing to
+            // start it is reported in the result rather than raised
+            "os_exec" => {
+                let program =
+                    value_to_str_cow(&self.registers[base + a + 1], &self.strings).into_owned();
+                let args: Vec<String> = match &self.registers[base + a + 2] {
+                    Value::List(items) => items
+                        .iter()
+                        .map(|v| value_to_str_cow(v, &self.strings).into_owned())
+                        .collect(),
+                    Value::Null => Vec::new(),
+                    other => {
+                        return Err(VmError::TypeError(format!(
+                            "os_exec expects a list of arguments, got {}",
+                            other.type_name()
+                        )))
+                    }
+                };
+                let timeout = self.registers[base + a + 3]
+                    .as_int()
+                    .filter(|ms| *ms > 0)
+                    .map(|ms| std::time::Duration::from_millis(ms as u64));
+                let _ = self.stdout.flush();
+                let str_value = |s: String| Value::String(StringRef::Owned(s));
+                let (stdout, stderr, code, error) = match subprocess::run(&program, &args, timeout)
+                {
+                    Ok(out) => {
+                        let error = out.error(timeout).map_or(Value::Null, str_value);
+                        let code = out.code.map_or(-1, i64::from);
+                        (out.stdout, out.stderr, code, error)
+                    }
+                    Err(e) => (
+                        String::new(),
+                        String::new(),
+                        -1,
+                        str_value(format!("exec {}: {}", program, e)),
+                    ),
+                };
+                Ok(Value::new_tuple(vec![
+                    str_value(stdout),
+                    str_value(stderr),
+                    Value::Int(code),
+                    error,
+                ]))
+            }
 
             // ── Stdin reading ──
             "read_stdin" => {
                 use std::io::Read;
+                let _ = self.stdout.flush();
                 let mut buf = String::new();
                 std::io::stdin()
                     .lock()
@@ -1764,6 +2534,7 @@ impl VM {
             }
             "read_line" => {
                 use std::io::BufRead;
+                let _ = self.stdout.flush();
                 let mut line = String::new();
                 std::io::stdin()
                     .lock()
@@ -1778,6 +2549,20 @@ impl VM {
                 Ok(Value::String(StringRef::Owned(line)))
             }
 
+            // Up to `max` bytes from stdin for std.bufio; empty at end of input
+            "read_stdin_chunk" => {
+                use std::io::Read;
+                let _ = self.stdout.flush();
+                let max = self.registers[base + a + 1].as_int().unwrap_or(0).max(0) as usize;
+                let mut buf = vec![0; max];
+                let n = std::io::stdin()
+                    .lock()
+                    .read(&mut buf)
+                    .map_err(|e| VmError::Runtime(format!("read_stdin_chunk failed: {}", e)))?;
+                buf.truncate(n);
+                Ok(Value::Bytes(buf))
+            }
+
             // ── Stderr output ──
             "eprint" => {
                 let msg = self.registers[base + a + 1].display_pretty();
@@ -1799,6 +2584,54 @@ impl VM {
                 let val = &self.registers[base + a + 1];
                 Ok(Value::String(StringRef::Owned(csv_encode_value(val))))
             }
+            // Strict record-at-a-time CSV backing std.csv. Parses the record
+            // at byte `pos` of a buffer that may hold only part of the input:
+            // ok((fields, next_pos, next_line)), or ok(null) when the buffer
+            // holds no complete record.
+            "csv_parse_record" => {
+                let pos = self.registers[base + a + 2].as_int().unwrap_or(0).max(0) as usize;
+                let line = self.registers[base + a + 3].as_int().unwrap_or(1).max(1) as usize;
+                let eof = self.registers[base + a + 4].is_truthy();
+                let parsed = match &self.registers[base + a + 1] {
+                    Value::Bytes(b) => csv::parse_record(b.get(pos..).unwrap_or(&[]), line, eof),
+                    other => {
+                        let s = value_to_str_cow(other, &self.strings);
+                        csv::parse_record(s.as_bytes().get(pos..).unwrap_or(&[]), line, eof)
+                    }
+                };
+                Ok(match parsed {
+                    Ok(Some(record)) => {
+                        let fields = record
+                            .fields
+                            .into_iter()
+                            .map(|f| Value::String(StringRef::Owned(f)))
+                            .collect();
+                        self.ok_value(Value::new_tuple(vec![
+                            Value::new_list(fields),
+                            Value::Int((pos + record.consumed) as i64),
+                            Value::Int(record.next_line as i64),
+                        ]))
+                    }
+                    Ok(None) => self.ok_value(Value::Null),
+                    Err(e) => self.err_value(Value::String(StringRef::Owned(e.to_string()))),
+                })
+            }
+            "csv_format_record" => {
+                let crlf = self.registers[base + a + 2].is_truthy();
+                match &self.registers[base + a + 1] {
+                    Value::List(items) => {
+                        let fields: Vec<String> =
+                            items.iter().map(|f| f.display_pretty()).collect();
+                        Ok(Value::String(StringRef::Owned(csv::format_record(
+                            &fields, crlf,
+                        ))))
+                    }
+                    other => Err(VmError::TypeError(format!(
+                        "csv_format_record expects a list of fields, got {}",
+                        other.type_name()
+                    ))),
+                }
+            }
 
             // ── TOML ──
             "toml_parse" => {
@@ -1814,7 +2647,7 @@ impl VM {
             "regex_match" => {
                 let pattern = value_to_str_cow(&self.registers[base + a + 1], &self.strings);
                 let text = value_to_str_cow(&self.registers[base + a + 2], &self.strings);
-                match regex::Regex::new(&pattern) {
+                match cached_regex(&mut self.regex_cache, &pattern) {
                     Ok(re) => {
                         if let Some(caps) = re.captures(&text) {
                             let groups: Vec<Value> = caps
@@ -1841,7 +2674,7 @@ impl VM {
                 let pattern = value_to_str_cow(&self.registers[base + a + 1], &self.strings);
                 let text = value_to_str_cow(&self.registers[base + a + 2], &self.strings);
                 let replacement = value_to_str_cow(&self.registers[base + a + 3], &self.strings);
-                match regex::Regex::new(&pattern) {
+                match cached_regex(&mut self.regex_cache, &pattern) {
                     Ok(re) => {
                         let result = re.replace_all(&text, &*replacement);
                         Ok(Value::String(StringRef::Owned(result.to_string())))
@@ -1855,7 +2688,7 @@ impl VM {
             "regex_find_all" => {
                 let pattern = value_to_str_cow(&self.registers[base + a + 1], &self.strings);
                 let text = value_to_str_cow(&self.registers[base + a + 2], &self.strings);
-                match regex::Regex::new(&pattern) {
+                match cached_regex(&mut self.regex_cache, &pattern) {
                     Ok(re) => {
                         let matches: Vec<Value> = re
                             .find_iter(&text)
@@ -1870,6 +2703,26 @@ impl VM {
                 }
             }
 
+            "regex_compile" => {
+                let pattern = value_to_str_cow(&self.registers[base + a + 1], &self.strings);
+                match cached_regex(&mut self.regex_cache, &pattern) {
+                    Ok(_) => {
+                        Ok(self.ok_value(Value::String(StringRef::Owned(pattern.into_owned()))))
+                    }
+                    Err(e) => Ok(self.err_value(Value::String(StringRef::Owned(e.to_string())))),
+                }
+            }
+            "regex_find" => {
+                let pattern = value_to_str_cow(&self.registers[base + a + 1], &self.strings);
+                let text = value_to_str_cow(&self.registers[base + a + 2], &self.strings);
+                let re = cached_regex(&mut self.regex_cache, &pattern)
+                    .map_err(|e| VmError::Runtime(format!("regex_find: invalid pattern: {}", e)))?;
+                Ok(match re.captures(&text) {
+                    Some(caps) => regex_match_value(&caps),
+                    None => Value::Null,
+                })
+            }
+
             // ── String concat ──
             "string_concat" => {
                 let arg = &self.registers[base + a + 1];
@@ -2019,6 +2872,116 @@ impl VM {
                     _ => Value::Bool(false),
                 })
             }
+            // Total order used by `sort`: -1, 0 or 1, with every NaN last
+            "float_compare" => {
+                let mut operands = [0.0f64; 2];
+                for (i, slot) in operands.iter_mut().enumerate() {
+                    *slot = match &self.registers[base + a + 1 + i] {
+                        Value::Float(f) => *f,
+                        Value::Int(n) => *n as f64,
+                        other => {
+                            return Err(VmError::Runtime(format!(
+                                "float_compare: expected a number, got {}",
+                                other.type_name()
+                            )))
+                        }
+                    };
+                }
+                Ok(Value::Int(
+                    match crate::values::float_total_order(operands[0], operands[1]) {
+                        std::cmp::Ordering::Less => -1,
+                        std::cmp::Ordering::Equal => 0,
+                        std::cmp::Ordering::Greater => 1,
+                    },
+                ))
+            }
+            "float_vec_add" | "float_vec_mul" | "float_vec_triad" => {
+                let xs = float_vec_operand(name, &self.registers[base + a + 2])?;
+                let ys = float_vec_operand(name, &self.registers[base + a + 3])?;
+                let scale = if name == "float_vec_triad" {
+                    match &self.registers[base + a + 4] {
+                        Value::Float(f) => *f,
+                        Value::Int(n) => *n as f64,
+                        other => {
+                            return Err(VmError::Runtime(format!(
+                                "float_vec_triad: expected a number for the scale, got {}",
+                                other.type_name()
+                            )))
+                        }
+                    }
+                } else {
+                    0.0
+                };
+                let Value::List(mut dst) = std::mem::take(&mut self.registers[base + a + 1]) else {
+                    return Err(VmError::Runtime(format!(
+                        "{}: expected a destination list",
+                        name
+                    )));
+                };
+                if dst.len() != xs.len() || xs.len() != ys.len() {
+                    return Err(VmError::Runtime(format!(
+                        "{}: vector lengths differ: dst {}, a {}, b {}",
+                        name,
+                        dst.len(),
+                        xs.len(),
+                        ys.len()
+                    )));
+                }
+                let mut out = vec![0.0; xs.len()];
+                match name {
+                    "float_vec_add" => crate::vecops::add(&mut out, &xs, &ys),
+                    "float_vec_mul" => crate::vecops::mul(&mut out, &xs, &ys),
+                    _ => crate::vecops::triad(&mut out, &xs, &ys, scale),
+                }
+                for (slot, x) in Arc::make_mut(&mut dst).iter_mut().zip(out) {
+                    *slot = Value::Float(x);
+                }
+                Ok(Value::List(dst))
+            }
+            "float_sum" | "float_sum_compensated" => {
+                let xs = float_vec_operand(name, &self.registers[base + a + 1])?;
+                Ok(Value::Float(if name == "float_sum" {
+                    crate::vecops::sum(&xs)
+                } else {
+                    crate::vecops::compensated_sum(&xs)
+                }))
+            }
+            "float_vec_recip" | "float_vec_rsqrt" => {
+                let xs = float_vec_operand(name, &self.registers[base + a + 2])?;
+                let Value::List(mut dst) = std::mem::take(&mut self.registers[base + a + 1]) else {
+                    return Err(VmError::Runtime(format!(
+                        "{}: expected a destination list",
+                        name
+                    )));
+                };
+                if dst.len() != xs.len() {
+                    return Err(VmError::Runtime(format!(
+                        "{}: vector lengths differ: dst {}, a {}",
+                        name,
+                        dst.len(),
+                        xs.len()
+                    )));
+                }
+                let mut out = vec![0.0; xs.len()];
+                if name == "float_vec_recip" {
+                    crate::vecops::recip(&mut out, &xs);
+                } else {
+                    crate::vecops::rsqrt(&mut out, &xs);
+                }
+                for (slot, x) in Arc::make_mut(&mut dst).iter_mut().zip(out) {
+                    *slot = Value::Float(x);
+                }
+                Ok(Value::List(dst))
+            }
+            "float_rsqrt" => {
+                let arg = &self.registers[base + a + 1];
+                Ok(match arg {
+                    Value::Float(f) => Value::Float(1.0 / f.sqrt()),
+                    Value::Int(n) => Value::Float(1.0 / (*n as f64).sqrt()),
+                    Value::BigInt(n) => Value::Float(1.0 / n.to_f64().unwrap_or(f64::NAN).sqrt()),
+                    _ => Value::Null,
+                })
+            }
             "math_pi" => Ok(Value::Float(std::f64::consts::PI)),
             "math_e" => Ok(Value::Float(std::f64::consts::E)),
             "sort_asc" => {
@@ -2100,6 +3063,18 @@ impl VM {
                 let elapsed = epoch.elapsed();
                 Ok(Value::Int(elapsed.as_nanos() as i64))
             }
+            "sleep" => {
+                // Block the current thread for the given number of milliseconds.
+                let millis = match &self.registers[base + a + 1] {
+                    Value::Int(n) => *n as f64,
+                    Value::Float(f) => *f,
+                    _ => 0.0,
+                };
+                if millis > 0.0 && millis.is_finite() {
+                    std::thread::sleep(std::time::Duration::from_secs_f64(millis / 1000.0));
+                }
+                Ok(Value::Null)
+            }
             "format_time" => {
                 let timestamp_secs = match &self.registers[base + a + 1] {
                     Value::Float(f) => *f,
@@ -2141,12 +3116,7 @@ impl VM {
                 };
                 Ok(Value::String(StringRef::Owned(result)))
             }
-            "args" => {
-                let args: Vec<Value> = std::env::args()
-                    .map(|a| Value::String(StringRef::Owned(a)))
-                    .collect();
-                Ok(Value::new_list(args))
-            }
+            "args" => Ok(self.program_args_value()),
             "set_env" => {
                 let key =
                     value_to_str_cow(&self.registers[base + a + 1], &self.strings).into_owned();
@@ -2158,6 +3128,29 @@ impl VM {
                 }
                 Ok(Value::Null)
             }
+            "unset_env" => {
+                let key =
+                    value_to_str_cow(&self.registers[base + a + 1], &self.strings).into_owned();
+                #[allow(unused_unsafe)]
+                unsafe {
+                    std::env::remove_var(&key);
+                }
+                Ok(Value::Null)
+            }
+            "build_info" => {
+                let mut info = BTreeMap::new();
+                for (key, value) in [
+                    ("version", crate::build_info::VERSION),
+                    ("commit", crate::build_info::COMMIT),
+                    ("target", crate::build_info::TARGET),
+                ] {
+                    info.insert(
+                        key.to_string(),
+                        Value::String(StringRef::Owned(value.into())),
+                    );
+                }
+                Ok(Value::new_map(info))
+            }
             "env_vars" => {
                 let mut map = BTreeMap::new();
                 for (key, value) in std::env::vars() {
@@ -2165,11 +3158,131 @@ impl VM {
                 }
                 Ok(Value::new_map(map))
             }
+            // ── Signals: delivered at safepoints (see `InterruptHandle`) ──
+            "signal_handle" => {
+                let signal = self.signal_arg("signal_handle", &self.registers[base + a + 1])?;
+                let callee = self.registers[base + a + 2].clone();
+                let handler = self.callable_arg("signal_handle", callee)?;
+                self.signal_handlers.insert(signal, handler);
+                Ok(Value::Null)
+            }
+            "signal_reset" => {
+                let signal = self.signal_arg("signal_reset", &self.registers[base + a + 1])?;
+                self.signal_handlers.remove(&signal);
+                Ok(Value::Null)
+            }
+            "signal_raise" => {
+                let signal = self.signal_arg("signal_raise", &self.registers[base + a + 1])?;
+                self.interrupt_handle().raise(signal);
+                Ok(Value::Null)
+            }
 
             _ => Err(VmError::UndefinedCell(name.to_string())),
         }
     }
 
+    /// Rebuild a hasher from an `(algorithm, state)` register pair.
+    fn hasher_arg(&self, builtin: &str, reg: usize) -> Result<StreamHasher, VmError> {
+        let name = value_to_str_cow(&self.registers[reg], &self.strings);
+        let alg = Algorithm::from_name(&name).ok_or_else(|| {
+            VmError::Runtime(format!("{}: unknown hash algorithm: {}", builtin, name))
+        })?;
+        match &self.registers[reg + 1] {
+            Value::Bytes(state) => StreamHasher::from_state(alg, state).ok_or_else(|| {
+                VmError::Runtime(format!("{}: corrupt {} hasher state", builtin, alg))
+            }),
+            other => Err(VmError::TypeError(format!(
+                "{} expects hasher state Bytes, got {}",
+                builtin,
+                other.type_name()
+            ))),
+        }
+    }
+
+    /// Program arguments as a list of strings: the ones set through
+    /// `set_program_args`, or the host process arguments otherwise.
+    fn program_args_value(&self) -> Value {
+        let args: Vec<Value> = match &self.program_args {
+            Some(args) => args
+                .iter()
+                .map(|a| Value::String(StringRef::Owned(a.clone())))
+                .collect(),
+            None => std::env::args()
+                .map(|a| Value::String(StringRef::Owned(a)))
+                .collect(),
+        };
+        Value::new_list(args)
+    }
+
+    /// Wrap a value in the built-in `ok` result variant.
+    pub(crate) fn ok_value(&self, payload: Value) -> Value {
+        Value::Union(UnionValue {
+            tag: self.tag_ok,
+            payload: Arc::new(payload),
+        })
+    }
+
+    /// Wrap a value in the built-in `err` result variant.
+    pub(crate) fn err_value(&self, payload: Value) -> Value {
+        Value::Union(UnionValue {
+            tag: self.tag_err,
+            payload: Arc::new(payload),
+        })
+    }
+
+    /// Resolve the callable argument of a builtin that calls back into Lumen:
+    /// a closure, or the name of a cell.
+    fn callable_arg(&self, builtin: &str, callee: Value) -> Result<ClosureValue, VmError> {
+        match callee {
+            Value::Closure(cv) => Ok(cv),
+            Value::String(ref s) => {
+                let name_str = match s {
+                    StringRef::Owned(s) => s.as_str(),
+                    StringRef::Interned(id) => self.strings.resolve(*id).unwrap_or(""),
+                };
+                let module = self.module.as_ref().ok_or(VmError::NoModule)?;
+                match module.cells.iter().position(|c| c.name == name_str) {
+                    Some(idx) => Ok(ClosureValue {
+                        cell_idx: idx,
+                        captures: vec![],
+                    }),
+                    None => Err(VmError::UndefinedCell(name_str.to_string())),
+                }
+            }
+            other => Err(VmError::Runtime(format!(
+                "{} expects a cell or closure, got {}",
+                builtin,
+                other.type_name()
+            ))),
+        }
+    }
+
+    /// The signal a builtin's name argument, such as `"SIGINT"`, stands for.
+    fn signal_arg(&self, builtin: &str, name: &Value) -> Result<Signal, VmError> {
+        let name = value_to_str_cow(name, &self.strings);
+        Signal::from_name(&name).ok_or_else(|| {
+            VmError::Runtime(format!(
+                "{}: unknown signal \"{}\" (expected SIGINT or SIGTERM)",
+                builtin, name
+            ))
+        })
+    }
+
+    /// Call the finalizer of every object freed since the last run, in the
+    /// order they were registered, and return how many ran. Finalizers are
+    /// given nothing — their object is already gone — so none can bring it
+    /// back. One registered while this runs waits for the next call.
+    pub fn run_finalizers(&mut self) -> Result<usize, VmError> {
+        let (freed, live): (Vec<Finalizer>, Vec<Finalizer>) = std::mem::take(&mut self.finalizers)
+            .into_iter()
+            .partition(|f| f.target.upgrade().is_none());
+        self.finalizers = live;
+        for f in &freed {
+            self.call_closure_sync(&f.callback, &[])?;
+        }
+        Ok(freed.len())
+    }
+
     /// Synchronously call a closure with the given arguments, returning its result.
     /// Used by HOF intrinsics (map, filter, reduce, etc.).
     pub(crate) fn call_closure_sync(
@@ -2177,8 +3290,8 @@ impl VM {
         closure: &ClosureValue,
         args: &[Value],
     ) -> Result<Value, VmError> {
-        if self.frames.len() >= MAX_CALL_DEPTH {
-            return Err(VmError::StackOverflow(MAX_CALL_DEPTH));
+        if self.frames.len() >= self.max_call_depth {
+            return Err(VmError::StackOverflow(self.max_call_depth));
         }
         let cv = closure.clone();
         let module = self.module.as_ref().ok_or(VmError::NoModule)?;
@@ -2194,9 +3307,7 @@ impl VM {
         let num_regs = callee_cell.registers as usize;
         let params: Vec<LirParam> = callee_cell.params.clone();
         let cell_regs = callee_cell.registers;
-        let new_base = self.registers.len();
-        self.registers
-            .resize(new_base + num_regs.max(16), Value::Null);
+        let new_base = self.grow_registers(num_regs.max(16));
         // Copy captures into frame registers
         for (i, cap) in cv.captures.iter().enumerate() {
             self.check_register(i, cell_regs)?;
@@ -2236,9 +3347,81 @@ impl VM {
             return_register: new_base, // result will be written here
             future_id: None,
         });
-        // Run the VM until this frame returns
-        self.run_until(self.frames.len().saturating_sub(1))?;
-        Ok(self.registers[new_base].clone())
+        // Run the VM until this frame returns; its Return hands back the value
+        self.run_until(self.frames.len().saturating_sub(1))
+    }
+
+    /// Read a stepped range `[start, end, step, inclusive]` from the register
+    /// block at `arg_reg` and return `(start, step, count)`, where `count` is
+    /// the number of values the range yields.
+    fn stepped_range(&self, base: usize, arg_reg: usize) -> Result<(i64, i64, i64), VmError> {
+        let reg = base + arg_reg;
+        let start = self.registers[reg].as_int().unwrap_or(0);
+        let end = self.registers[reg + 1].as_int().unwrap_or(0);
+        let step = self.registers[reg + 2].as_int().unwrap_or(1);
+        let inclusive = self.registers[reg + 3].is_truthy();
+        if step == 0 {
+            return Err(VmError::Runtime("range step cannot be zero".into()));
+        }
+        // Distance to cover in the direction of the step
+        let span = if step > 0 {
+            end as i128 - start as i128
+        } else {
+            start as i128 - end as i128
+        };
+        let stride = (step as i128).abs();
+        let count = if inclusive {
+            if span < 0 {
+                0
+            } else {
+                span / stride + 1
+            }
+        } else if span <= 0 {
+            0
+        } else {
+            (span + stride - 1) / stride
+        };
+        Ok((start, step, count.min(i64::MAX as i128) as i64))
+    }
+
+    /// Advance a user-defined iterator stored at absolute register `iter_reg`.
+    /// The record's type must define `next`, returning a `(value, rest)` tuple
+    /// or null once exhausted; `rest` replaces the iterator for the next pass.
+    fn advance_user_iterator(
+        &mut self,
+        iter_reg: usize,
+        type_name: &str,
+    ) -> Result<Option<Value>, VmError> {
+        let method = format!("{}.next", type_name);
+        let module = self.module.as_ref().ok_or(VmError::NoModule)?;
+        let cell_idx = if let Some(&cached) = self.cell_index_cache.get(&method) {
+            cached
+        } else if let Some(idx) = module.cells.iter().position(|c| c.name == method) {
+            self.cell_index_cache.insert(method.clone(), idx);
+            idx
+        } else {
+            return Err(VmError::Runtime(format!(
+                "type {} is not iterable: it has no next method",
+                type_name
+            )));
+        };
+        let closure = ClosureValue {
+            cell_idx,
+            captures: vec![],
+        };
+        let current = self.registers[iter_reg].clone();
+        match self.call_closure_sync(&closure, &[current])? {
+            Value::Null => Ok(None),
+            Value::Tuple(t) if t.len() == 2 => {
+                self.registers[iter_reg] = t[1].clone();
+                Ok(Some(t[0].clone()))
+            }
+            other => Err(VmError::Runtime(format!(
+                "{} must return a (value, iterator) tuple or null, got {}",
+                method,
+                other.type_name()
+            ))),
+        }
     }
 
     /// Execute an intrinsic function by ID.
@@ -2252,12 +3435,12 @@ impl VM {
         let arg = &self.registers[base + arg_reg];
         match func_id {
             0 => {
-                // LENGTH (Unicode-aware for strings)
+                // LENGTH (UTF-8 bytes for strings)
                 Ok(match arg {
-                    Value::String(StringRef::Owned(s)) => Value::Int(s.chars().count() as i64),
+                    Value::String(StringRef::Owned(s)) => Value::Int(s.len() as i64),
                     Value::String(StringRef::Interned(id)) => {
                         let s = self.strings.resolve(*id).unwrap_or("");
-                        Value::Int(s.chars().count() as i64)
+                        Value::Int(s.len() as i64)
                     }
                     Value::List(l) => Value::Int(l.len() as i64),
                     Value::Map(m) => Value::Int(m.len() as i64),
@@ -2333,8 +3516,8 @@ impl VM {
             9 => {
                 // PRINT
                 let output = arg.display_pretty();
-                println!("{}", output);
-                self.output.push(output);
+                self.stdout.write_line(&output);
+                self.capture_line(output);
                 Ok(Value::Null)
             }
             10 => Ok(Value::String(StringRef::Owned(arg.display_pretty()))), // TOSTRING
@@ -2480,18 +3663,24 @@ impl VM {
                             Value::new_list(vec![])
                         }
                     }
-                    Value::String(StringRef::Owned(s)) => {
-                        let char_count = s.chars().count();
-                        let start = start.max(0) as usize;
+                    Value::String(_) => {
+                        // Byte offsets, like `len` and `s[i]`; an offset inside
+                        // a multi-byte character moves back to its first byte
+                        let s = value_to_str_cow(arg, &self.strings);
+                        let floor = |mut i: usize| {
+                            while !s.is_char_boundary(i) {
+                                i -= 1;
+                            }
+                            i
+                        };
+                        let start = floor((start.max(0) as usize).min(s.len()));
                         let end = if end <= 0 {
-                            char_count
+                            s.len()
                         } else {
-                            (end as usize).min(char_count)
+                            floor((end as usize).min(s.len()))
                         };
                         if start < end {
-                            Value::String(StringRef::Owned(
-                                s.chars().skip(start).take(end - start).collect::<String>(),
-                            ))
+                            Value::String(StringRef::Owned(s[start..end].to_string()))
                         } else {
                             Value::String(StringRef::Owned("".into()))
                         }
@@ -3039,10 +4228,10 @@ impl VM {
             72 => {
                 // SIZE - alias for length/count
                 Ok(match arg {
-                    Value::String(StringRef::Owned(s)) => Value::Int(s.chars().count() as i64),
+                    Value::String(StringRef::Owned(s)) => Value::Int(s.len() as i64),
                     Value::String(StringRef::Interned(id)) => {
                         let s = self.strings.resolve(*id).unwrap_or("");
-                        Value::Int(s.chars().count() as i64)
+                        Value::Int(s.len() as i64)
                     }
                     Value::List(l) => Value::Int(l.len() as i64),
                     Value::Map(m) => Value::Int(m.len() as i64),
@@ -3229,7 +4418,9 @@ impl VM {
                 match compile_raw(&wrapped_src) {
                     Ok(new_module) => {
                         if let Some(current_mod) = self.module.as_mut() {
-                            current_mod.merge(&new_module);
+                            current_mod.merge(&new_module).map_err(|e| {
+                                VmError::Runtime(format!("eval linking failed: {}", e))
+                            })?;
                             self.call_cell_sync(&cell_name, vec![])
                         } else {
                             Err(VmError::Runtime("VM has no module loaded for eval".into()))
@@ -3255,6 +4446,7 @@ impl VM {
             85 => {
                 // EXIT: exit process with code
                 let code = arg.as_int().unwrap_or(0);
+                let _ = self.stdout.flush();
                 std::process::exit(code as i32);
             }
             86 => {
@@ -3383,6 +4575,7 @@ impl VM {
             95 => {
                 // READ_STDIN: read all of stdin to string
                 use std::io::Read;
+                let _ = self.stdout.flush();
                 let mut buf = String::new();
                 std::io::stdin()
                     .lock()
@@ -3425,7 +4618,7 @@ impl VM {
                 // REGEX_MATCH: return capture groups for first match
                 let pattern = value_to_str_cow(arg, &self.strings);
                 let text = value_to_str_cow(&self.registers[base + arg_reg + 1], &self.strings);
-                match regex::Regex::new(&pattern) {
+                match cached_regex(&mut self.regex_cache, &pattern) {
                     Ok(re) => {
                         if let Some(caps) = re.captures(&text) {
                             let groups: Vec<Value> = caps
@@ -3454,7 +4647,7 @@ impl VM {
                 let text = value_to_str_cow(&self.registers[base + arg_reg + 1], &self.strings);
                 let replacement =
                     value_to_str_cow(&self.registers[base + arg_reg + 2], &self.strings);
-                match regex::Regex::new(&pattern) {
+                match cached_regex(&mut self.regex_cache, &pattern) {
                     Ok(re) => {
                         let result = re.replace_all(&text, &*replacement);
                         Ok(Value::String(StringRef::Owned(result.to_string())))
@@ -3469,7 +4662,7 @@ impl VM {
                 // REGEX_FIND_ALL: return all matches
                 let pattern = value_to_str_cow(arg, &self.strings);
                 let text = value_to_str_cow(&self.registers[base + arg_reg + 1], &self.strings);
-                match regex::Regex::new(&pattern) {
+                match cached_regex(&mut self.regex_cache, &pattern) {
                     Ok(re) => {
                         let matches: Vec<Value> = re
                             .find_iter(&text)
@@ -3486,6 +4679,7 @@ impl VM {
             105 => {
                 // READ_LINE: read a single line from stdin
                 use std::io::BufRead;
+                let _ = self.stdout.flush();
                 let mut line = String::new();
                 std::io::stdin()
                     .lock()
@@ -3631,20 +4825,9 @@ impl VM {
             122 => {
                 // PARSE_FLOAT: parse string to float, return result type
                 let s = value_to_str_cow(arg, &self.strings);
-                let tag_ok = self.tag_ok;
-                let tag_err = self.tag_err;
-                match s.trim().parse::<f64>() {
-                    Ok(f) => Ok(Value::Union(UnionValue {
-                        tag: tag_ok,
-                        payload: Arc::new(Value::Float(f)),
-                    })),
-                    Err(_) => Ok(Value::Union(UnionValue {
-                        tag: tag_err,
-                        payload: Arc::new(Value::String(StringRef::Owned(format!(
-                            "invalid float: {}",
-                            s
-                        )))),
-                    })),
+                match parse_float_str(&s) {
+                    Ok(f) => Ok(self.ok_value(Value::Float(f))),
+                    Err(e) => Ok(self.err_value(Value::String(StringRef::Owned(e)))),
                 }
             }
             123 => {
@@ -3820,10 +5003,7 @@ impl VM {
             }
             135 => {
                 // ARGS: return command-line arguments
-                let args: Vec<Value> = std::env::args()
-                    .map(|a| Value::String(StringRef::Owned(a)))
-                    .collect();
-                Ok(Value::new_list(args))
+                Ok(self.program_args_value())
             }
             136 => {
                 // SET_ENV: set environment variable
@@ -3845,6 +5025,93 @@ impl VM {
                 }
                 Ok(Value::new_map(map))
             }
+            138 => {
+                // ITER_NEXT: advance the for-loop block [iter, cursor, more].
+                // Collections are walked by index; maps yield their keys,
+                // strings their characters, and bytes their values as Ints.
+                let iter_reg = base + arg_reg;
+                let cursor = match self.registers[iter_reg + 1] {
+                    Value::Int(i) => i.max(0) as usize,
+                    _ => 0,
+                };
+                // Sets, maps and strings cannot be indexed by position in
+                // O(1), so the first step swaps the block's private copy for
+                // a list of the elements the loop will yield
+                if cursor == 0 {
+                    let snapshot: Option<Vec<Value>> = match &self.registers[iter_reg] {
+                        Value::Set(s) => Some(s.iter().cloned().collect()),
+                        Value::Map(m) => Some(
+                            m.keys()
+                                .map(|k| Value::String(StringRef::Owned(k.clone())))
+                                .collect(),
+                        ),
+                        Value::String(sr) => {
+                            let s = match sr {
+                                StringRef::Owned(s) => s.as_str(),
+                                StringRef::Interned(id) => self.strings.resolve(*id).unwrap_or(""),
+                            };
+                            Some(
+                                s.chars()
+                                    .map(|ch| Value::String(StringRef::Owned(ch.to_string())))
+                                    .collect(),
+                            )
+                        }
+                        _ => None,
+                    };
+                    if let Some(items) = snapshot {
+                        self.registers[iter_reg] = Value::new_list(items);
+                    }
+                }
+                let next = match &self.registers[iter_reg] {
+                    Value::List(l) | Value::Tuple(l) => l.get(cursor).cloned(),
+                    Value::Bytes(b) => b.get(cursor).map(|&byte| Value::Int(byte as i64)),
+                    Value::Record(r) => {
+                        let type_name = r.type_name.clone();
+                        self.advance_user_iterator(iter_reg, &type_name)?
+                    }
+                    _ => None,
+                };
+                self.registers[iter_reg + 1] = Value::Int(cursor as i64 + 1);
+                self.registers[iter_reg + 2] = Value::Bool(next.is_some());
+                Ok(next.unwrap_or(Value::Null))
+            }
+            139 => {
+                // RANGE_COUNT: number of values in [start, end, step, inclusive]
+                let (_, _, count) = self.stepped_range(base, arg_reg)?;
+                Ok(Value::Int(count))
+            }
+            140 => {
+                // RANGE_STEP: materialize [start, end, step, inclusive] as a list
+                let (start, step, count) = self.stepped_range(base, arg_reg)?;
+                let list: Vec<Value> = (0..count).map(|i| Value::Int(start + i * step)).collect();
+                Ok(Value::new_list(list))
+            }
+            141 => {
+                // LIST_REPEAT: [value; count]
+                let value = self.registers[base + arg_reg].clone();
+                let count = match &self.registers[base + arg_reg + 1] {
+                    Value::Int(n) if *n >= 0 => *n as usize,
+                    other => {
+                        return Err(VmError::Runtime(format!(
+                            "list repeat count must be a non-negative Int, got {}",
+                            other.display_pretty()
+                        )))
+                    }
+                };
+                Ok(Value::new_list(vec![value; count]))
+            }
+            142 => {
+                // SWITCH_HASH: [subject, slots] -> the subject's slot in a
+                // string switch table, or -1 (skip the table) for a non-string
+                let slots = self.registers[base + arg_reg + 1].as_int().unwrap_or(1);
+                Ok(Value::Int(match arg {
+                    Value::String(_) => {
+                        let s = value_to_str_cow(arg, &self.strings);
+                        switch_bucket(&s, slots.max(1) as u64) as i64
+                    }
+                    _ => -1,
+                }))
+            }
             _ => Err(VmError::Runtime(format!(
                 "Unknown intrinsic ID {} - this is a compiler/VM mismatch bug",
                 func_id
@@ -3853,6 +5120,168 @@ impl VM {
     }
 }
 
+/// Parse a decimal float (optionally in scientific notation), rounding to the
+/// nearest double exactly as Go's `strconv.ParseFloat` does. Surrounding
+/// whitespace is ignored. A finite literal beyond the double range is an
+/// error rather than becoming infinity; tiny values round to a subnormal or 0.
+/// Mutable access to a list, copying it first if it is shared. Unlike
+/// `Arc::make_mut`, the copy keeps the original capacity.
+/// The lower-case kind `reflect_kind` reports for a value.
+fn reflect_kind(val: &Value) -> &'static str {
+    match val {
+        Value::Null => "null",
+        Value::Bool(_) => "bool",
+        Value::Int(_) | Value::BigInt(_) => "int",
+        Value::Float(_) => "float",
+        Value::String(_) => "string",
+        Value::Bytes(_) => "bytes",
+        Value::List(_) => "list",
+        Value::Tuple(_) => "tuple",
+        Value::Set(_) => "set",
+        Value::Map(_) => "map",
+        Value::Record(_) => "record",
+        Value::Union(_) => "enum",
+        Value::Closure(_) => "closure",
+        Value::TraceRef(_) => "trace",
+        Value::Future(_) => "future",
+        Value::Weak(_) => "weak",
+    }
+}
+
+/// The type name every item shares, or `Any` when they differ or there are
+/// none.
+fn common_type_name<'a>(
+    mut items: impl Iterator<Item = &'a Value>,
+    strings: &'a crate::strings::StringTable,
+) -> &'a str {
+    let Some(first) = items.next().map(|v| v.type_name_resolved(strings)) else {
+        return "Any";
+    };
+    if items.all(|v| v.type_name_resolved(strings) == first) {
+        first
+    } else {
+        "Any"
+    }
+}
+
+/// The Int argument of a `bits_*` builtin.
+fn bits_int_arg(builtin: &str, val: &Value) -> Result<i64, VmError> {
+    match val {
+        Value::Int(n) => Ok(*n),
+        other => Err(VmError::TypeError(format!(
+            "{} expects an Int, got {}",
+            builtin,
+            other.type_name()
+        ))),
+    }
+}
+
+/// A list of rows as the matrix argument of a `linalg_*` builtin.
+fn linalg_matrix(builtin: &str, val: &Value) -> Result<Matrix, VmError> {
+    let Value::List(rows) = val else {
+        return Err(VmError::Runtime(format!(
+            "{}: expected a list of rows, got {}",
+            builtin,
+            val.type_name()
+        )));
+    };
+    let rows = rows
+        .iter()
+        .map(|row| float_vec_operand(builtin, row))
+        .collect::<Result<Vec<_>, _>>()?;
+    Matrix::from_rows(&rows).map_err(|e| VmError::Runtime(format!("{}: {}", builtin, e)))
+}
+
+fn matrix_value(m: &Matrix) -> Value {
+    Value::new_list(
+        (0..m.rows())
+            .map(|i| Value::new_list(m.row(i).iter().map(|&x| Value::Float(x)).collect()))
+            .collect(),
+    )
+}
+
+fn list_mut_keep_capacity(l: &mut Arc<Vec<Value>>) -> &mut Vec<Value> {
+    if Arc::get_mut(l).is_none() {
+        let mut copy = Vec::with_capacity(l.capacity());
+        copy.extend(l.iter().cloned());
+        *l = Arc::new(copy);
+    }
+    Arc::get_mut(l).expect("list was just unshared")
+}
+
+fn parse_float_str(s: &str) -> Result<f64, String> {
+    let text = s.trim();
+    match text.parse::<f64>() {
+        Ok(f) if f.is_infinite() => {
+            let unsigned = text.trim_start_matches(['+', '-']);
+            if unsigned.eq_ignore_ascii_case("inf") || unsigned.eq_ignore_ascii_case("infinity") {
+                Ok(f)
+            } else {
+                Err(format!("float out of range: {}", text))
+            }
+        }
+        Ok(f) => Ok(f),
+        Err(_) => Err(format!("invalid float: {}", s)),
+    }
+}
+
+/// Parse a signed integer in `radix` (2–36) into an `i64`. Surrounding
+/// whitespace is ignored and a sign may precede the digits. A `0x`, `0o`, or
+/// `0b` prefix is accepted when it matches the radix; radix 0 picks the radix
+/// from the prefix and defaults to decimal. Values outside the `i64` range are
+/// an error rather than widening to a BigInt.
+fn parse_int_radix_str(s: &str, radix: i64) -> Result<i64, String> {
+    if radix != 0 && !(2..=36).contains(&radix) {
+        return Err(format!("invalid base {}: must be 0 or 2 through 36", radix));
+    }
+    let text = s.trim();
+    let (negative, unsigned) = match text.as_bytes().first() {
+        Some(b'-') => (true, &text[1..]),
+        Some(b'+') => (false, &text[1..]),
+        _ => (false, text),
+    };
+    let prefixed = |p: char| {
+        let mut chars = unsigned.chars();
+        chars.next() == Some('0') && chars.next().map(|c| c.to_ascii_lowercase()) == Some(p)
+    };
+    let (radix, digits) = match radix {
+        0 | 16 if prefixed('x') => (16, &unsigned[2..]),
+        0 | 8 if prefixed('o') => (8, &unsigned[2..]),
+        0 | 2 if prefixed('b') => (2, &unsigned[2..]),
+        0 => (10, unsigned),
+        r => (r as u32, unsigned),
+    };
+    if digits.is_empty() || digits.starts_with(['+', '-']) {
+        return Err(format!("invalid integer: {}", s));
+    }
+    let signed = if negative {
+        format!("-{}", digits)
+    } else {
+        digits.to_string()
+    };
+    i64::from_str_radix(&signed, radix).map_err(|e| match e.kind() {
+        std::num::IntErrorKind::PosOverflow | std::num::IntErrorKind::NegOverflow => {
+            format!("integer out of range: {}", text)
+        }
+        _ => format!("invalid integer: {}", s),
+    })
+}
+
+/// Format `f` with exactly `precision` digits after the point, like Go's
+/// `%.Nf`: the exact binary value is rounded half-to-even, and non-finite
+/// values print as `+Inf`, `-Inf`, and `NaN`.
+fn format_fixed(f: f64, precision: usize) -> String {
+    if f.is_nan() {
+        "NaN".to_string()
+    } else if f.is_infinite() {
+        if f > 0.0 { "+Inf" } else { "-Inf" }.to_string()
+    } else {
+        // Rust's fixed-precision formatting is exact with ties-to-even and
+        // keeps the sign of negative zero, which is what Go does.
+        format!("{:.*}", precision, f)
+    }
+}
+
 /// Format a Value according to a format specifier string.
 ///
 /// Supported specifiers (Python-style):
@@ -4105,6 +5534,80 @@ fn validate_value_against_schema(
     }
 }
 
+// ── Regex helper ──
+
+/// Patterns the VM keeps compiled before it starts over.
+const REGEX_CACHE_LIMIT: usize = 256;
+
+/// Compile `pattern`, or reuse the copy compiled by an earlier call.
+fn cached_regex<'c>(
+    cache: &'c mut HashMap<String, regex::Regex>,
+    pattern: &str,
+) -> Result<&'c regex::Regex, regex::Error> {
+    if !cache.contains_key(pattern) {
+        let re = regex::Regex::new(pattern)?;
+        if cache.len() >= REGEX_CACHE_LIMIT {
+            cache.clear();
+        }
+        cache.insert(pattern.to_string(), re);
+    }
+    Ok(&cache[pattern])
+}
+
+/// Describe a regex match as a map: the matched `text`, its `start` and
+/// `stop` byte offsets (matching `slice`), and the capture `groups`
+/// (group 0 is the whole match; groups that did not participate are null).
+fn regex_match_value(caps: &regex::Captures<'_>) -> Value {
+    let whole = caps.get(0).expect("group 0 always participates");
+    let (start, stop) = (whole.start(), whole.end());
+    let groups: Vec<Value> = caps
+        .iter()
+        .map(|m| match m {
+            Some(m) => Value::String(StringRef::Owned(m.as_str().to_string())),
+            None => Value::Null,
+        })
+        .collect();
+    let mut map = BTreeMap::new();
+    map.insert(
+        "text".to_string(),
+        Value::String(StringRef::Owned(whole.as_str().to_string())),
+    );
+    map.insert("start".to_string(), Value::Int(start as i64));
+    map.insert("stop".to_string(), Value::Int(stop as i64));
+    map.insert("groups".to_string(), Value::new_list(groups));
+    Value::new_map(map)
+}
+
+// ── Filesystem error helper ──
+
+/// Build the `err` payload for a failed filesystem operation.
+///
+/// The `kind` key is a stable, lowercase classification that Lumen code can
+/// match on: `not_found`, `permission_denied`, `already_exists`, or `io` for
+/// anything else.
+fn fs_error_payload(path: &str, err: &std::io::Error) -> Value {
+    let kind = match err.kind() {
+        std::io::ErrorKind::NotFound => "not_found",
+        std::io::ErrorKind::PermissionDenied => "permission_denied",
+        std::io::ErrorKind::AlreadyExists => "already_exists",
+        _ => "io",
+    };
+    let mut map = BTreeMap::new();
+    map.insert(
+        "kind".to_string(),
+        Value::String(StringRef::Owned(kind.to_string())),
+    );
+    map.insert(
+        "path".to_string(),
+        Value::String(StringRef::Owned(path.to_string())),
+    );
+    map.insert(
+        "message".to_string(),
+        Value::String(StringRef::Owned(err.to_string())),
+    );
+    Value::new_map(map)
+}
+
 // ── Glob matching helper ──
 
 /// Convert Unix epoch seconds to (year, month, day, hour, minute, second).
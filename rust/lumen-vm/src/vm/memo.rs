@@ -0,0 +1,153 @@
+//! Result caches behind `@memoize` cells.
+//!
+//! The compiler turns a memoized cell into a caller of `__memo_lookup` and
+//! `__memo_store` (see `lumen_compiler::compiler::memoize`), which read and
+//! fill one [`MemoCache`] per cell. A cache is keyed by the list of
+//! arguments. Two calls share an entry when their arguments are the same
+//! values of the same types: strings compare by text whether or not they
+//! are interned, and floats by bit pattern, so a NaN argument finds its own
+//! entry while `0.0` and `-0.0` do not share one. An `Int` and a `Float`
+//! are different keys even when numerically equal.
+
+use crate::strings::StringTable;
+use crate::values::{RecordValue, StringRef, UnionValue, Value};
+use std::collections::BTreeMap;
+use std::sync::Arc;
+
+/// The results of one memoized cell, optionally bounded by count.
+#[derive(Debug, Default)]
+pub(crate) struct MemoCache {
+    /// Most entries kept; None keeps every result.
+    capacity: Option<usize>,
+    /// Result and last use of each argument list.
+    entries: BTreeMap<Value, (Value, u64)>,
+    /// Argument lists by last use, oldest first.
+    recency: BTreeMap<u64, Value>,
+    clock: u64,
+}
+
+impl MemoCache {
+    pub(crate) fn new(capacity: Option<usize>) -> Self {
+        MemoCache {
+            capacity,
+            ..MemoCache::default()
+        }
+    }
+
+    /// The cached result for `key`, which becomes the most recently used.
+    pub(crate) fn get(&mut self, key: &Value) -> Option<Value> {
+        self.clock += 1;
+        let (value, last_use) = self.entries.get_mut(key)?;
+        self.recency.remove(last_use);
+        *last_use = self.clock;
+        self.recency.insert(self.clock, key.clone());
+        Some(value.clone())
+    }
+
+    /// Cache `value` for `key`, dropping the least recently used entry if
+    /// the cache is full.
+    pub(crate) fn insert(&mut self, key: Value, value: Value) {
+        self.clock += 1;
+        if let Some((_, last_use)) = self.entries.remove(&key) {
+            self.recency.remove(&last_use);
+        }
+        if let Some(capacity) = self.capacity {
+            while self.entries.len() >= capacity {
+                let Some((_, oldest)) = self.recency.pop_first() else {
+                    break;
+                };
+                self.entries.remove(&oldest);
+            }
+        }
+        self.recency.insert(self.clock, key.clone());
+        self.entries.insert(key, (value, self.clock));
+    }
+}
+
+/// `value` with every interned string replaced by its text, so that equal
+/// arguments are equal keys however their strings are stored.
+pub(crate) fn memo_key(value: &Value, strings: &StringTable) -> Value {
+    match value {
+        Value::String(StringRef::Interned(id)) => Value::String(StringRef::Owned(
+            strings.resolve(*id).unwrap_or("").to_string(),
+        )),
+        Value::List(items) => Value::new_list(items.iter().map(|v| memo_key(v, strings)).collect()),
+        Value::Tuple(items) => {
+            Value::new_tuple(items.iter().map(|v| memo_key(v, strings)).collect())
+        }
+        Value::Set(items) => {
+            Value::new_set_from_vec(items.iter().map(|v| memo_key(v, strings)).collect())
+        }
+        Value::Map(entries) => Value::new_map(
+            entries
+                .iter()
+                .map(|(k, v)| (k.clone(), memo_key(v, strings)))
+                .collect(),
+        ),
+        Value::Record(r) => Value::new_record(RecordValue {
+            type_name: r.type_name.clone(),
+            fields: r
+                .fields
+                .iter()
+                .map(|(k, v)| (k.clone(), memo_key(v, strings)))
+                .collect(),
+        }),
+        Value::Union(u) => Value::Union(UnionValue {
+            tag: u.tag,
+            payload: Arc::new(memo_key(&u.payload, strings)),
+        }),
+        other => other.clone(),
+    }
+}
+
+// ---------------------------------------------------------------------------
+// Tests
+// ---------------------------------------------------------------------------
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn key(n: i64) -> Value {
+        Value::new_list(vec![Value::Int(n)])
+    }
+
+    #[test]
+    fn bounded_cache_evicts_the_least_recently_used() {
+        let mut cache = MemoCache::new(Some(2));
+        cache.insert(key(1), Value::Int(10));
+        cache.insert(key(2), Value::Int(20));
+        // Reading 1 leaves 2 as the oldest
+        assert_eq!(cache.get(&key(1)), Some(Value::Int(10)));
+        cache.insert(key(3), Value::Int(30));
+        assert_eq!(cache.entries.len(), 2);
+        assert_eq!(cache.get(&key(2)), None);
+        assert_eq!(cache.get(&key(1)), Some(Value::Int(10)));
+        assert_eq!(cache.get(&key(3)), Some(Value::Int(30)));
+    }
+
+    #[test]
+    fn unbounded_cache_keeps_everything() {
+        let mut cache = MemoCache::new(None);
+        for n in 0..1000 {
+            cache.insert(key(n), Value::Int(n * n));
+        }
+        assert_eq!(cache.entries.len(), 1000);
+        assert_eq!(cache.get(&key(0)), Some(Value::Int(0)));
+    }
+
+    #[test]
+    fn keys_compare_interned_strings_by_text() {
+        let mut strings = StringTable::new();
+        let id = strings.intern("abc");
+        let interned = Value::new_list(vec![Value::String(StringRef::Interned(id))]);
+        let owned = Value::new_list(vec![Value::String(StringRef::Owned("abc".into()))]);
+        assert_eq!(memo_key(&interned, &strings), memo_key(&owned, &strings));
+
+        let nan = Value::new_list(vec![Value::Float(f64::NAN)]);
+        let mut cache = MemoCache::new(None);
+        cache.insert(memo_key(&nan, &strings), Value::Int(1));
+        assert_eq!(cache.get(&memo_key(&nan, &strings)), Some(Value::Int(1)));
+        assert_eq!(cache.get(&Value::new_list(vec![Value::Float(1.0)])), None);
+    }
+}
@@ -1,29 +1,45 @@
 //! Register VM dispatch loop for executing LIR bytecode.
 
+mod alloc_profile;
+mod backtrace;
 pub mod continuations;
+mod dispatch;
+mod field_cache;
 mod helpers;
 mod intrinsics;
+mod memo;
 mod ops;
+mod printf;
 pub(crate) mod processes;
-
+mod stdout;
+mod verify;
+
+use alloc_profile::AllocProfiler;
+pub use alloc_profile::{AllocProfile, AllocSite};
+use backtrace::SourceMap;
+pub use dispatch::DispatchMode;
+use field_cache::FieldCache;
+pub use field_cache::FieldCacheStats;
 use helpers::*;
+use memo::MemoCache;
 pub(crate) use processes::{
     MachineExpr, MachineGraphDef, MachineParamDef, MachineRuntime, MachineStateDef, MemoryRuntime,
 };
+use stdout::StdoutBuffer;
+pub use verify::{verify_module, VerifyError};
 
 use crate::jit_tier::{JitTier, JitTierConfig};
 use crate::strings::StringTable;
 use crate::types::{RuntimeField, RuntimeType, RuntimeTypeKind, RuntimeVariant, TypeTable};
 use crate::values::{
-    values_equal, ClosureValue, FutureStatus, FutureValue, RecordValue, StringRef, TraceRefValue,
-    UnionValue, Value,
+    values_equal, ClosureValue, FieldMap, FutureStatus, FutureValue, RecordValue, StringRef,
+    TraceRefValue, UnionValue, Value, WeakValue,
 };
 use crate::vm::ops::BinaryOp;
 use lumen_compiler::compiler::lir::*;
 
+use lumen_runtime::signal::{PendingSignals, Signal};
 use lumen_runtime::tools::{ProviderRegistry, ToolDispatcher, ToolRequest};
-use num_bigint::BigInt;
-use num_traits::ToPrimitive;
 use std::collections::{BTreeMap, HashMap, VecDeque};
 use std::sync::Arc;
 use thiserror::Error;
@@ -74,6 +90,8 @@ pub enum VmError {
     Runtime(String),
     #[error("halt: {0}")]
     Halt(String),
+    #[error("panic: {0}")]
+    Panic(String),
     #[error("stack overflow: call depth exceeded {0}")]
     StackOverflow(usize),
     #[error("undefined cell: {0}")]
@@ -94,6 +112,10 @@ pub enum VmError {
     InstructionLimitExceeded(u64),
     #[error("register out of bounds: {0}")]
     RegisterOutOfBounds(usize),
+    #[error("interrupted")]
+    Interrupted,
+    #[error("terminated")]
+    Terminated,
     #[error("{message}\nStack trace (most recent call last):{stack_trace}")]
     WithStackTrace {
         message: String,
@@ -114,16 +136,9 @@ impl VmError {
             return self;
         }
         let message = format!("{}", self);
-        let mut trace = String::new();
-        for (i, frame) in frames.iter().rev().enumerate() {
-            trace.push_str(&format!(
-                "\n  #{}: {} (instruction {})",
-                i, frame.cell_name, frame.ip
-            ));
-        }
         VmError::WithStackTrace {
             message,
-            stack_trace: trace,
+            stack_trace: format_frames(&frames),
             frames,
         }
     }
@@ -131,12 +146,7 @@ impl VmError {
     /// Format stack trace as a string (for external use without wrapping).
     pub fn format_stack_trace(frames: &[StackFrame]) -> String {
         let mut msg = String::from("\nStack trace (most recent call last):");
-        for (i, frame) in frames.iter().rev().enumerate() {
-            msg.push_str(&format!(
-                "\n  #{}: {} (instruction {})",
-                i, frame.cell_name, frame.ip
-            ));
-        }
+        msg.push_str(&format_frames(frames));
         msg
     }
 
@@ -206,6 +216,74 @@ impl VmError {
         }
     }
 
+    /// Check if the underlying error is a Panic (works through WithStackTrace wrapper).
+    pub fn is_panic(&self) -> bool {
+        match self {
+            VmError::Panic(_) => true,
+            VmError::WithStackTrace { message, .. } => message.starts_with("panic: "),
+            _ => false,
+        }
+    }
+
+    /// Check if the underlying error is a StackOverflow (works through
+    /// WithStackTrace wrapper).
+    pub fn is_stack_overflow(&self) -> bool {
+        match self {
+            VmError::StackOverflow(_) => true,
+            VmError::WithStackTrace { message, .. } => message.starts_with("stack overflow: "),
+            _ => false,
+        }
+    }
+
+    /// Check if the run was stopped through an [`InterruptHandle`] (works
+    /// through WithStackTrace wrapper).
+    pub fn is_interrupted(&self) -> bool {
+        match self {
+            VmError::Interrupted => true,
+            VmError::WithStackTrace { message, .. } => message == "interrupted",
+            _ => false,
+        }
+    }
+
+    /// Check if the run was stopped by a `SIGTERM` it had no handler for
+    /// (works through WithStackTrace wrapper).
+    pub fn is_terminated(&self) -> bool {
+        match self {
+            VmError::Terminated => true,
+            VmError::WithStackTrace { message, .. } => message == "terminated",
+            _ => false,
+        }
+    }
+
+    /// The signal that stopped the run, if one did.
+    pub fn signal(&self) -> Option<Signal> {
+        if self.is_interrupted() {
+            Some(Signal::Interrupt)
+        } else if self.is_terminated() {
+            Some(Signal::Terminate)
+        } else {
+            None
+        }
+    }
+
+    /// The message a `recover` boundary reports for this error, or `None` if
+    /// the error must keep unwinding. Explicit panics report their own message;
+    /// programmer errors (out-of-bounds indexing, failed halts, runtime type
+    /// errors, arithmetic faults) report their display form. Resource limits
+    /// and VM faults are never recoverable, so a supervisor cannot swallow them.
+    pub fn panic_message(&self) -> Option<String> {
+        match self {
+            VmError::Panic(msg) => Some(msg.clone()),
+            VmError::Runtime(_)
+            | VmError::Halt(_)
+            | VmError::TypeError(_)
+            | VmError::ArithmeticOverflow(_)
+            | VmError::DivisionByZero
+            | VmError::UndefinedCell(_) => Some(self.to_string()),
+            _ => None,
+        }
+    }
+
     /// Get the stack frames from a WithStackTrace error, or empty vec for other variants.
     pub fn stack_frames(&self) -> &[StackFrame] {
         match self {
@@ -234,7 +312,50 @@ impl VmError {
     }
 }
 
-const MAX_CALL_DEPTH: usize = 4096;
+/// Call frames a VM allows unless [`VM::set_max_call_depth`] changes it.
+pub const DEFAULT_MAX_CALL_DEPTH: usize = 4096;
+
+/// Native stack a thread running a VM should have per allowed call frame.
+/// Interpreted calls keep their frames on the heap, but JIT-compiled cells
+/// and closures called from builtins nest on the native stack.
+const NATIVE_STACK_PER_FRAME: usize = 4 * 1024;
+
+/// Native stack for a thread that runs a VM with `max_call_depth`, so the
+/// VM's depth check fails before the thread's own guard page is reached.
+pub fn native_stack_size(max_call_depth: usize) -> usize {
+    (8usize << 20).saturating_add(max_call_depth.saturating_mul(NATIVE_STACK_PER_FRAME))
+}
+
+/// Stops a running VM from another thread, such as a Ctrl-C handler.
+///
+/// Loop back-edges and calls are safepoints: the interpreter checks the
+/// handle at each one, so a run stops within one iteration of its innermost
+/// loop with [`VmError::Interrupted`]. Code that does not return to the
+/// interpreter, such as a loop inside a JIT-compiled cell or a blocking
+/// builtin, is only stopped once it does.
+///
+/// [`raise`](Self::raise) delivers any [`Signal`] the same way. A signal
+/// the program installed a handler for with `std.os.signal` runs the
+/// handler at the safepoint instead, and the run then carries on.
+#[derive(Debug, Clone, Default)]
+pub struct InterruptHandle(Arc<PendingSignals>);
+
+impl InterruptHandle {
+    /// Ask the VM to stop at its next safepoint.
+    pub fn interrupt(&self) {
+        self.raise(Signal::Interrupt);
+    }
+
+    /// Deliver `signal` at the VM's next safepoint.
+    pub fn raise(&self, signal: Signal) {
+        self.0.raise(signal);
+    }
+
+    /// The next pending signal; taking it lets the VM run again.
+    fn take(&self) -> Option<Signal> {
+        self.0.take()
+    }
+}
 
 /// Call frame on the VM stack.
 #[derive(Debug, Clone)]
@@ -266,10 +387,18 @@ pub(crate) enum FutureTarget {
     Closure(ClosureValue),
 }
 
+/// When spawned futures run.
 #[derive(Debug, Clone, Copy, PartialEq, Eq)]
 pub enum FutureSchedule {
+    /// Run each future to completion as soon as it is spawned.
     Eager,
+    /// Queue futures and run each one when it is first awaited.
     DeferredFifo,
+    /// Queue futures and run them one at a time in spawn order: an `await`
+    /// of a future still pending runs the oldest queued task and then checks
+    /// again, and tasks never awaited run, in order, after the entry cell
+    /// returns. Every run of a program interleaves its tasks the same way.
+    Cooperative,
 }
 
 /// Scope for an installed effect handler.
@@ -299,6 +428,14 @@ pub(crate) struct SuspendedContinuation {
     pub result_reg: usize,
 }
 
+/// A callback registered with `set_finalizer`; `run_finalizers` calls it
+/// once its target has been freed.
+#[derive(Debug, Clone)]
+pub(crate) struct Finalizer {
+    pub target: WeakValue,
+    pub callback: ClosureValue,
+}
+
 /// The Lumen register VM.
 pub struct VM {
     pub strings: StringTable,
@@ -306,8 +443,14 @@ pub struct VM {
     pub(crate) registers: Vec<Value>,
     pub(crate) frames: Vec<CallFrame>,
     pub(crate) module: Option<LirModule>,
-    /// Captured stdout output (for testing and tracing)
+    /// Captured stdout output (for testing and tracing), one entry per line
     pub output: Vec<String>,
+    /// Text `printf` wrote after its last newline, waiting for the rest of
+    /// its line before it goes into `output`
+    pub(crate) partial_line: String,
+    /// Buffered writer behind `print`/`emit`; flushed on `flush()`, before
+    /// stdin reads and `exit`, and when the VM is dropped.
+    pub(crate) stdout: StdoutBuffer,
     /// Optional tool dispatcher
     pub tool_dispatcher: Option<Box<dyn ToolDispatcher>>,
     /// Optional debug callback for step-through debugging
@@ -329,9 +472,15 @@ pub struct VM {
     pub(crate) suspended_continuation: Option<SuspendedContinuation>,
     pub(crate) max_instructions: u64,
     pub(crate) instruction_count: u64,
+    /// Most call frames allowed at once; one more is a stack overflow.
+    pub(crate) max_call_depth: usize,
+    /// Checked at loop back-edges and calls.
+    interrupt: InterruptHandle,
     /// Optional fuel counter. Each instruction decrements fuel by 1.
     /// When fuel hits 0, execution stops with a "fuel exhausted" error.
     pub(crate) fuel: Option<u64>,
+    /// How `run_until` finds the code for each instruction.
+    dispatch_mode: DispatchMode,
     pub(crate) trace_id: Option<String>,
     pub(crate) trace_seq: u64,
     /// Effect budget tracking: maps effect name → (remaining_calls, original_limit).
@@ -339,6 +488,29 @@ pub struct VM {
     pub(crate) effect_budgets: HashMap<String, (u32, u32)>,
     /// Cache mapping cell names to their index in module.cells for O(1) dispatch.
     cell_index_cache: HashMap<String, usize>,
+    /// Inline caches for record field reads, rebuilt when a module is loaded.
+    field_cache: FieldCache,
+    /// Whether `field_cache` gets a slot per read site; off, every read
+    /// looks its field up by name.
+    field_cache_enabled: bool,
+    /// Compiled regexes by pattern, so the `regex_*` builtins compile each
+    /// pattern once rather than on every call.
+    pub(crate) regex_cache: HashMap<String, regex::Regex>,
+    /// Results of `@memoize` cells, by cell name; emptied when a module is
+    /// loaded.
+    pub(crate) memo_caches: HashMap<String, MemoCache>,
+    /// Pending finalizers, in registration order.
+    pub(crate) finalizers: Vec<Finalizer>,
+    /// Handlers installed with `signal_handle`.
+    pub(crate) signal_handlers: HashMap<Signal, ClosureValue>,
+    /// Set while a signal handler runs; signals raised meanwhile wait.
+    in_signal_handler: bool,
+    /// Call lines and modules of the loaded cells, for `backtrace()`.
+    source_map: SourceMap,
+    /// File reported by `backtrace()` for cells of the main document.
+    source_name: String,
+    /// Allocation counts by source line, when profiling is enabled.
+    alloc_profiler: Option<AllocProfiler>,
     /// Logical top of the register file. Registers beyond this index are unused.
     /// We pre-allocate a large Vec and use this watermark to avoid resize/truncate costs.
     pub(crate) register_top: usize,
@@ -348,6 +520,49 @@ pub struct VM {
     /// Pre-interned tag IDs for common union tags ("ok", "err").
     pub tag_ok: u32,
     pub tag_err: u32,
+    /// Arguments passed to the Lumen program itself, returned by `args()`.
+    /// When unset, `args()` falls back to the host process arguments.
+    pub(crate) program_args: Option<Vec<String>>,
+}
+
+/// Identical frames in a row shown before the rest are summed up in one
+/// line, so the trace of a runaway recursion stays readable.
+const REPEATED_FRAMES_SHOWN: usize = 3;
+
+/// One line per frame, innermost first, with runs of identical frames
+/// (the same call site in the same cell) cut short.
+fn format_frames(frames: &[StackFrame]) -> String {
+    let mut trace = String::new();
+    let mut frames = frames.iter().rev().enumerate().peekable();
+    while let Some((i, frame)) = frames.next() {
+        trace.push_str(&format!(
+            "\n  #{}: {} (instruction {})",
+            i, frame.cell_name, frame.ip
+        ));
+        let mut run = 1;
+        while let Some((_, next)) = frames.peek() {
+            if next.cell_name != frame.cell_name || next.ip != frame.ip {
+                break;
+            }
+            run += 1;
+            let (i, next) = frames.next().unwrap_or((i, frame));
+            if run <= REPEATED_FRAMES_SHOWN {
+                trace.push_str(&format!(
+                    "\n  #{}: {} (instruction {})",
+                    i, next.cell_name, next.ip
+                ));
+            }
+        }
+        if run > REPEATED_FRAMES_SHOWN {
+            trace.push_str(&format!(
+                "\n  ... {} more frames of {} (instruction {})",
+                run - REPEATED_FRAMES_SHOWN,
+                frame.cell_name,
+                frame.ip
+            ));
+        }
+    }
+    trace
 }
 
 const MAX_AWAIT_RETRIES: u32 = 10_000;
@@ -365,6 +580,8 @@ impl VM {
             frames: Vec::new(),
             module: None,
             output: Vec::new(),
+            partial_line: String::new(),
+            stdout: StdoutBuffer::new(),
             tool_dispatcher: None,
             debug_callback: None,
             next_future_id: 1,
@@ -384,15 +601,29 @@ impl VM {
             suspended_continuation: None,
             max_instructions: DEFAULT_MAX_INSTRUCTIONS,
             instruction_count: 0,
+            max_call_depth: DEFAULT_MAX_CALL_DEPTH,
+            interrupt: InterruptHandle::default(),
             fuel: None,
+            dispatch_mode: DispatchMode::default(),
             trace_id: None,
             trace_seq: 0,
             effect_budgets: HashMap::new(),
             cell_index_cache: HashMap::new(),
+            field_cache: FieldCache::default(),
+            field_cache_enabled: true,
+            regex_cache: HashMap::new(),
+            memo_caches: HashMap::new(),
+            finalizers: Vec::new(),
+            signal_handlers: HashMap::new(),
+            in_signal_handler: false,
+            source_map: SourceMap::default(),
+            source_name: backtrace::DEFAULT_SOURCE_NAME.to_string(),
+            alloc_profiler: None,
             register_top: 0,
             jit_tier: JitTier::disabled(),
             tag_ok,
             tag_err,
+            program_args: None,
         }
     }
 
@@ -429,6 +660,45 @@ impl VM {
         self.jit_tier.tier_stats()
     }
 
+    /// Get record field-read inline cache statistics for the loaded module.
+    pub fn field_cache_stats(&self) -> FieldCacheStats {
+        self.field_cache.stats()
+    }
+
+    /// Count allocations by the source line that made them, for
+    /// [`alloc_profile`](Self::alloc_profile). Native code does not report
+    /// its allocations, so this also turns the JIT tier off.
+    pub fn enable_alloc_profile(&mut self) {
+        self.jit_tier = JitTier::disabled();
+        if let Some(module) = &self.module {
+            self.jit_tier.init_for_module(module.cells.len());
+        }
+        self.alloc_profiler = Some(match &self.module {
+            Some(module) => AllocProfiler::for_module(module, &self.source_map),
+            None => AllocProfiler::default(),
+        });
+    }
+
+    /// Allocations counted since profiling was enabled or the module loaded;
+    /// empty when profiling is off.
+    pub fn alloc_profile(&self) -> AllocProfile {
+        match (&self.alloc_profiler, &self.module) {
+            (Some(profiler), Some(module)) => {
+                profiler.profile(module, &self.source_map, &self.source_name)
+            }
+            _ => AllocProfile::default(),
+        }
+    }
+
+    /// Count an allocation of `slots` values by instruction `ip` of cell
+    /// `cell_idx`, when profiling.
+    #[inline]
+    pub(crate) fn record_alloc(&mut self, cell_idx: usize, ip: usize, slots: usize) {
+        if let Some(profiler) = &mut self.alloc_profiler {
+            profiler.record(cell_idx, ip, slots * std::mem::size_of::<Value>());
+        }
+    }
+
     /// Grow register file for a new call frame. Returns the new base index.
     /// Uses the `register_top` watermark to avoid unnecessary resize/truncate.
     #[inline(always)]
@@ -447,6 +717,18 @@ impl VM {
         new_base
     }
 
+    /// Widen the frame at `base` to hold `num_regs` registers, for a tail call
+    /// that reuses the frame for a cell with more registers than its caller.
+    fn grow_frame_registers(&mut self, base: usize, num_regs: usize) {
+        let needed = base + num_regs;
+        if needed > self.registers.len() {
+            self.registers.resize(needed, Value::Null);
+        }
+        if needed > self.register_top {
+            self.register_top = needed;
+        }
+    }
+
     /// Shrink register file back after a return. Clears the callee region
     /// and resets the watermark without reallocating.
     #[inline(always)]
@@ -545,6 +827,15 @@ impl VM {
         }
     }
 
+    /// Verify `module` with [`verify_module`], then load it. A module that
+    /// fails is not loaded, so modules from outside the compiler can be
+    /// rejected before any of their bytecode runs.
+    pub fn load_verified(&mut self, module: LirModule) -> Result<(), VerifyError> {
+        verify_module(&module)?;
+        self.load(module);
+        Ok(())
+    }
+
     /// Load a LIR module into the VM.
     pub fn load(&mut self, module: LirModule) {
         // Intern all strings
@@ -569,6 +860,8 @@ impl VM {
         self.suspended_continuation = None;
         self.instruction_count = 0;
         self.cell_index_cache.clear();
+        self.memo_caches.clear();
+        self.signal_handlers.clear();
         let mut machine_initials: BTreeMap<String, String> = BTreeMap::new();
         for addon in &module.addons {
             if let Some(name) = &addon.name {
@@ -697,6 +990,17 @@ impl VM {
                             .map(|f| RuntimeField {
                                 name: f.name.clone(),
                                 ty: f.ty.clone(),
+                                default: f.default.as_ref().map(|c| match c {
+                                    Constant::Null => Value::Null,
+                                    Constant::Bool(v) => Value::Bool(*v),
+                                    Constant::Int(v) => Value::Int(*v),
+                                    Constant::BigInt(v) => Value::BigInt(v.clone()),
+                                    Constant::Float(v) => Value::Float(*v),
+                                    Constant::String(v) => {
+                                        Value::String(StringRef::Owned(v.clone()))
+                                    }
+                                }),
+                                json: f.json.clone(),
                             })
                             .collect(),
                     ),
@@ -719,6 +1023,11 @@ impl VM {
         }
         // Initialise the JIT tier to track the correct number of cells.
         let num_cells = module.cells.len();
+        self.field_cache = self.new_field_cache(&module);
+        self.source_map = SourceMap::from_addons(&module.addons);
+        if self.alloc_profiler.is_some() {
+            self.alloc_profiler = Some(AllocProfiler::for_module(&module, &self.source_map));
+        }
         self.module = Some(module);
         self.jit_tier.init_for_module(num_cells);
     }
@@ -728,6 +1037,69 @@ impl VM {
         self.future_schedule_explicit = true;
     }
 
+    /// Redirect program output to `sink`, buffering up to `capacity` bytes.
+    /// Anything already buffered for the previous sink is flushed first.
+    pub fn set_stdout_sink(&mut self, sink: Box<dyn std::io::Write + Send>, capacity: usize) {
+        let _ = self.stdout.flush();
+        self.stdout = StdoutBuffer::with_sink(sink, capacity);
+    }
+
+    /// Write any buffered program output to the underlying sink.
+    pub fn flush_stdout(&mut self) -> std::io::Result<()> {
+        self.stdout.flush()
+    }
+
+    /// Capture a line `print` or `emit` wrote, finishing any line `printf`
+    /// started.
+    pub(crate) fn capture_line(&mut self, line: String) {
+        if self.partial_line.is_empty() {
+            self.output.push(line);
+        } else {
+            let mut full = std::mem::take(&mut self.partial_line);
+            full.push_str(&line);
+            self.output.push(full);
+        }
+    }
+
+    /// Capture text `printf` wrote: each line it completes goes into
+    /// `output` without its newline, and the rest waits in `partial_line`.
+    pub(crate) fn capture_text(&mut self, text: &str) {
+        let mut rest = text;
+        while let Some(end) = rest.find('\n') {
+            self.partial_line.push_str(&rest[..end]);
+            let line = std::mem::take(&mut self.partial_line);
+            self.output.push(line);
+            rest = &rest[end + 1..];
+        }
+        self.partial_line.push_str(rest);
+    }
+
+    /// Capture the unfinished line `printf` left, if any, as a line of its own.
+    pub(crate) fn finish_partial_line(&mut self) {
+        if !self.partial_line.is_empty() {
+            let line = std::mem::take(&mut self.partial_line);
+            self.output.push(line);
+        }
+    }
+
+    /// Set the arguments visible to the program through `args()`.
+    ///
+    /// The list should not include the interpreter or source file name; it
+    /// mirrors what a compiled program would see after its own name.
+    pub fn set_program_args<I, S>(&mut self, args: I)
+    where
+        I: IntoIterator<Item = S>,
+        S: Into<String>,
+    {
+        self.program_args = Some(args.into_iter().map(Into::into).collect());
+    }
+
+    /// Set the file `backtrace()` reports for cells of the main document,
+    /// normally the path it was compiled from.
+    pub fn set_source_name<S: Into<String>>(&mut self, name: S) {
+        self.source_name = name.into();
+    }
+
     pub fn set_trace_id<S: Into<String>>(&mut self, trace_id: S) {
         self.trace_id = Some(trace_id.into());
         self.trace_seq = 0;
@@ -741,12 +1113,94 @@ impl VM {
         self.max_instructions = max_instructions;
     }
 
+    /// Set the most call frames allowed at once. A call past the limit
+    /// fails with [`VmError::StackOverflow`] and the stack trace; a tail
+    /// call reuses its frame and does not count.
+    pub fn set_max_call_depth(&mut self, frames: usize) {
+        self.max_call_depth = frames.max(1);
+    }
+
+    pub fn max_call_depth(&self) -> usize {
+        self.max_call_depth
+    }
+
+    /// A handle that stops this VM's current or next run from any thread.
+    pub fn interrupt_handle(&self) -> InterruptHandle {
+        self.interrupt.clone()
+    }
+
+    /// Deliver a pending signal, if there is one and no handler is already
+    /// running. Called at loop back-edges and calls.
+    #[inline]
+    fn safepoint(&mut self, ip: usize) -> Result<(), VmError> {
+        if self.in_signal_handler {
+            return Ok(());
+        }
+        let Some(signal) = self.interrupt.take() else {
+            return Ok(());
+        };
+        if let Some(f) = self.frames.last_mut() {
+            f.ip = ip;
+        }
+        self.deliver_signals(signal)
+    }
+
+    /// Run the handler installed for `signal`, as if the interrupted code
+    /// had called it, then those of any signals raised while it ran. Fails
+    /// with [`VmError::Interrupted`] or [`VmError::Terminated`] at the first
+    /// signal that has no handler.
+    #[cold]
+    fn deliver_signals(&mut self, signal: Signal) -> Result<(), VmError> {
+        let mut next = Some(signal);
+        while let Some(signal) = next {
+            let Some(handler) = self.signal_handlers.get(&signal).cloned() else {
+                return Err(match signal {
+                    Signal::Interrupt => VmError::Interrupted,
+                    Signal::Terminate => VmError::Terminated,
+                });
+            };
+            let name = Value::String(StringRef::Owned(signal.name().to_string()));
+            self.in_signal_handler = true;
+            let outcome = self.call_closure_sync(&handler, &[name]);
+            self.in_signal_handler = false;
+            outcome?;
+            next = self.interrupt.take();
+        }
+        Ok(())
+    }
+
     /// Set the fuel counter. Each executed instruction consumes one unit of fuel.
     /// When fuel reaches 0, execution stops with a "fuel exhausted" error.
     pub fn set_fuel(&mut self, fuel: u64) {
         self.fuel = Some(fuel);
     }
 
+    /// Choose how the interpreter loop dispatches instructions. Both modes
+    /// give the same results; [`DispatchMode::Table`] exists to compare
+    /// dispatch costs.
+    pub fn set_dispatch_mode(&mut self, mode: DispatchMode) {
+        self.dispatch_mode = mode;
+    }
+
+    /// Turn the record field-read inline caches on or off; they are on by
+    /// default. Reads give the same results either way, so this exists to
+    /// measure what the caches save.
+    pub fn set_field_cache(&mut self, enabled: bool) {
+        self.field_cache_enabled = enabled;
+        self.field_cache = match &self.module {
+            Some(module) => self.new_field_cache(module),
+            None => FieldCache::default(),
+        };
+    }
+
+    fn new_field_cache(&self, module: &LirModule) -> FieldCache {
+        if self.field_cache_enabled {
+            FieldCache::for_module(module)
+        } else {
+            FieldCache::default()
+        }
+    }
+
     /// Set an effect budget — the maximum number of times `effect` may be
     /// invoked (via `perform` or tool-call) before the VM rejects further
     /// calls with a `BudgetExhausted` error.
@@ -894,117 +1348,9 @@ impl VM {
         instr: Instruction,
         cell_registers: u16,
     ) -> Result<(), VmError> {
-        let a = instr.a as usize;
-        let b = instr.b as usize;
-        let c = instr.c as usize;
-
-        match instr.op {
-            OpCode::Nop | OpCode::Jmp | OpCode::Break | OpCode::Continue => Ok(()),
-
-            OpCode::LoadK
-            | OpCode::LoadBool
-            | OpCode::LoadInt
-            | OpCode::NewRecord
-            | OpCode::Test
-            | OpCode::Return
-            | OpCode::Halt
-            | OpCode::Loop
-            | OpCode::Closure
-            | OpCode::Schema
-            | OpCode::Emit
-            | OpCode::TraceRef
-            | OpCode::Spawn
-            | OpCode::IsVariant => self.check_register(a, cell_registers),
-
-            OpCode::LoadNil => self.check_register_span(a, b + 1, cell_registers),
-
-            OpCode::Move
-            | OpCode::MoveOwn
-            | OpCode::Neg
-            | OpCode::BitNot
-            | OpCode::Not
-            | OpCode::Append
-            | OpCode::Unbox => {
-                self.check_register(a, cell_registers)?;
-                self.check_register(b, cell_registers)
-            }
-
-            OpCode::NewList | OpCode::NewTuple | OpCode::NewSet => {
-                self.check_register(a, cell_registers)?;
-                self.check_register_span(a + 1, b, cell_registers)
-            }
-
-            OpCode::NewMap => {
-                self.check_register(a, cell_registers)?;
-                self.check_register_span(a + 1, b.saturating_mul(2), cell_registers)
-            }
-
-            OpCode::GetField
-            | OpCode::GetIndex
-            | OpCode::GetTuple
-            | OpCode::Add
-            | OpCode::Sub
-            | OpCode::Mul
-            | OpCode::Div
-            | OpCode::FloorDiv
-            | OpCode::Mod
-            | OpCode::Pow
-            | OpCode::Concat
-            | OpCode::BitOr
-            | OpCode::BitAnd
-            | OpCode::BitXor
-            | OpCode::Shl
-            | OpCode::Shr
-            | OpCode::Eq
-            | OpCode::Lt
-            | OpCode::Le
-            | OpCode::And
-            | OpCode::Or
-            | OpCode::In
-            | OpCode::Is
-            | OpCode::NullCo
-            | OpCode::SetIndex
-            | OpCode::NewUnion
-            | OpCode::Await => {
-                self.check_register(a, cell_registers)?;
-                self.check_register(b, cell_registers)?;
-                self.check_register(c, cell_registers)
-            }
-
-            OpCode::SetField | OpCode::SetUpval => {
-                self.check_register(a, cell_registers)?;
-                self.check_register(c, cell_registers)
-            }
-
-            OpCode::ForPrep => self.check_register_span(a, 3, cell_registers),
-
-            OpCode::ForLoop => self.check_register_span(a, 4, cell_registers),
-
-            OpCode::ForIn => {
-                self.check_register(a, cell_registers)?;
-                self.check_register(a + 1, cell_registers)?;
-                self.check_register(b, cell_registers)?;
-                self.check_register(c, cell_registers)
-            }
-
-            OpCode::Call | OpCode::TailCall => {
-                self.check_register(a, cell_registers)?;
-                self.check_register_span(a + 1, b, cell_registers)
-            }
-
-            OpCode::Intrinsic => {
-                self.check_register(a, cell_registers)?;
-                self.check_register(c, cell_registers)
-            }
-
-            OpCode::GetUpval => self.check_register(a, cell_registers),
-
-            OpCode::ToolCall => self.check_register(a, cell_registers),
-
-            OpCode::Perform => self.check_register(a, cell_registers),
-            OpCode::HandlePush | OpCode::HandlePop => Ok(()),
-            OpCode::Resume => self.check_register(a, cell_registers),
-        }
+        verify::for_each_register_span(instr, |start, len| {
+            self.check_register_span(start, len, cell_registers)
+        })
     }
 
     fn ensure_process_instance(&mut self, value: &mut Value) {
@@ -1065,8 +1411,8 @@ impl VM {
     }
 
     fn start_future_task(&mut self, task: FutureTask) -> Result<(), VmError> {
-        if self.frames.len() >= MAX_CALL_DEPTH {
-            return Err(VmError::StackOverflow(MAX_CALL_DEPTH));
+        if self.frames.len() >= self.max_call_depth {
+            return Err(VmError::StackOverflow(self.max_call_depth));
         }
         let module = self.module.as_ref().ok_or(VmError::NoModule)?;
         match task.target {
@@ -1144,7 +1490,7 @@ impl VM {
     fn schedule_future_task(&mut self, task: FutureTask) -> Result<(), VmError> {
         match self.future_schedule {
             FutureSchedule::Eager => self.start_future_task(task),
-            FutureSchedule::DeferredFifo => {
+            FutureSchedule::DeferredFifo | FutureSchedule::Cooperative => {
                 self.scheduled_futures.push_back(task);
                 Ok(())
             }
@@ -1203,6 +1549,16 @@ impl VM {
         Ok(true)
     }
 
+    /// Run the tasks still queued, oldest first, including any they spawn.
+    fn run_queued_futures(&mut self) -> Result<(), VmError> {
+        while let Some(task) = self.scheduled_futures.pop_front() {
+            let depth = self.frames.len();
+            self.start_future_task(task)?;
+            self.run_until(depth)?;
+        }
+        Ok(())
+    }
+
     fn await_future_value(&mut self, future: &FutureValue) -> Result<Option<Value>, VmError> {
         match self.future_states.get(&future.id).cloned() {
             Some(FutureState::Completed(val)) => Ok(Some(val)),
@@ -1210,6 +1566,19 @@ impl VM {
                 "await failed for future {}: {}",
                 future.id, msg
             ))),
+            Some(FutureState::Pending) if self.future_schedule == FutureSchedule::Cooperative => {
+                // Yield to the oldest queued task, whichever future it is for
+                match self.scheduled_futures.pop_front() {
+                    Some(task) => {
+                        self.start_future_task(task)?;
+                        Ok(None)
+                    }
+                    None => Err(VmError::Runtime(format!(
+                        "future {} is pending with no runnable task",
+                        future.id
+                    ))),
+                }
+            }
             Some(FutureState::Pending) => {
                 let has_task = self
                     .scheduled_futures
@@ -1275,7 +1644,7 @@ impl VM {
                 Ok(Some(Value::new_map(out)))
             }
             Value::Record(mut record) => {
-                let mut out = BTreeMap::new();
+                let mut out = FieldMap::new();
                 for (k, v) in std::mem::take(&mut Arc::make_mut(&mut record).fields) {
                     match self.await_value_recursive(v)? {
                         Some(resolved) => {
@@ -1347,7 +1716,14 @@ impl VM {
         });
 
         // Execute
-        self.run_until(0).map_err(|err| {
+        let result = self.run_until(0).and_then(|value| {
+            if self.future_schedule == FutureSchedule::Cooperative {
+                self.run_queued_futures()?;
+            }
+            Ok(value)
+        });
+        self.finish_partial_line();
+        result.map_err(|err| {
             let frames = self.capture_stack_trace();
             err.with_stack_trace(frames)
         })
@@ -1367,6 +1743,23 @@ impl VM {
             .ok_or_else(|| VmError::Runtime(format!("constant index {} out of bounds", idx)))
     }
 
+    /// The value of `obj.name` when `obj` has no such field: `b.kinetic`
+    /// names the method cell `Body.kinetic`, and trait impls on builtins
+    /// resolve the same way (`Int.show`). Null when there is no such method.
+    fn method_ref(&self, module: &LirModule, type_name: &str, name: &str) -> Value {
+        let method = format!("{}.{}", type_name, name);
+        if self.cell_index_cache.contains_key(&method)
+            || module.cells.iter().any(|c| c.name == method)
+        {
+            Value::String(StringRef::Owned(method))
+        } else if type_name == "Weak" && name == "upgrade" {
+            // `w.upgrade()` calls the builtin with the reference as its argument
+            Value::String(StringRef::Owned(name.to_string()))
+        } else {
+            Value::Null
+        }
+    }
+
     /// Helper to get a string from the module string table.
     #[allow(dead_code)]
     fn get_module_string(&self, idx: usize) -> Result<String, VmError> {
@@ -1401,6 +1794,7 @@ impl VM {
         // Pre-check: do we have debug or fuel active? Branch once, not per-instruction.
         let has_debug = self.debug_callback.is_some();
         let has_fuel = self.fuel.is_some();
+        let table_dispatch = self.dispatch_mode == DispatchMode::Table;
 
         // Local instruction counter — only sync to self every batch to avoid cache-line writes
         let mut local_count: u64 = 0;
@@ -1471,481 +1865,34 @@ impl VM {
                 self.validate_instruction_registers(instr, cell_registers)?;
             }
 
-            // Handle opcodes that need mutable self first (before borrowing module).
-            if matches!(
-                instr.op,
-                OpCode::Call | OpCode::TailCall | OpCode::Intrinsic
-            ) {
-                // Sync IP back to frame before dispatch (call/return needs it)
-                if let Some(f) = self.frames.last_mut() {
-                    f.ip = ip;
-                }
-                match instr.op {
-                    OpCode::Call => {
-                        // === FAST PATH: inline cell call ===
-                        // Resolve cell index from callee register WITHOUT cloning.
-                        // This avoids heap-allocating a String clone on every call.
-                        let callee_reg = base + a;
-                        let nargs = b;
-                        let fast_cell_idx = match &self.registers[callee_reg] {
-                            Value::String(sr) => {
-                                let name_str = match sr {
-                                    StringRef::Owned(s) => s.as_str(),
-                                    StringRef::Interned(id) => {
-                                        self.strings.resolve(*id).unwrap_or("")
-                                    }
-                                };
-                                // Fast check: is this a self-recursive call?
-                                // Compare against current cell name to skip HashMap lookup.
-                                if name_str == cell.name {
-                                    Some(cell_idx)
-                                } else if let Some(&cached) = self.cell_index_cache.get(name_str) {
-                                    Some(cached)
-                                } else if let Some(idx) =
-                                    module.cells.iter().position(|c| c.name == name_str)
-                                {
-                                    self.cell_index_cache.insert(name_str.to_string(), idx);
-                                    Some(idx)
-                                } else {
-                                    None // builtin — fall through to dispatch_call
-                                }
-                            }
-                            _ => None, // closure or other — fall through to dispatch_call
-                        };
-
-                        if let Some(target_idx) = fast_cell_idx {
-                            // ─── JIT TIER: check if cell is compiled ─────────
-                            // If the cell is already JIT-compiled, execute it as
-                            // a native function pointer and skip the interpreter.
-                            if self.jit_tier.is_enabled() {
-                                // Check if already compiled
-                                let run_jit = if self.jit_tier.is_compiled(target_idx) {
-                                    true
-                                } else if self.jit_tier.record_call(target_idx) {
-                                    // Just crossed hot threshold — try to compile
-                                    if self.jit_tier.check_eligibility(target_idx, module) {
-                                        self.jit_tier.try_compile(target_idx, module)
-                                    } else {
-                                        false
-                                    }
-                                } else {
-                                    false
-                                };
-
-                                if run_jit {
-                                    // Extract i64 args from registers.
-                                    // Int → raw i64, Float → f64 bits as i64,
-                                    // String → heap-clone as *mut String cast to i64
-                                    // (the JIT owns this pointer and will free it).
-                                    let callee_cell = &module.cells[target_idx];
-                                    let mut i64_args: Vec<i64> = Vec::with_capacity(nargs);
-                                    let mut string_arg_ptrs: Vec<i64> = Vec::new();
-                                    let mut args_ok = true;
-                                    for i in 0..nargs {
-                                        match &self.registers[base + a + 1 + i] {
-                                            Value::Int(v) => i64_args.push(*v),
-                                            Value::Float(v) => i64_args.push(v.to_bits() as i64),
-                                            Value::String(s) => {
-                                                // Allocate a heap String for the JIT.
-                                                // The JIT takes ownership via *mut String.
-                                                let owned = match s {
-                                                    StringRef::Owned(o) => o.clone(),
-                                                    StringRef::Interned(id) => module
-                                                        .strings
-                                                        .get(*id as usize)
-                                                        .cloned()
-                                                        .unwrap_or_default(),
-                                                };
-                                                let boxed = Box::new(owned);
-                                                let ptr = Box::into_raw(boxed) as i64;
-                                                string_arg_ptrs.push(ptr);
-                                                i64_args.push(ptr);
-                                            }
-                                            Value::Bool(b) => i64_args.push(if *b { 1 } else { 0 }),
-                                            _ => {
-                                                args_ok = false;
-                                                break;
-                                            }
-                                        }
-                                    }
-                                    if !args_ok {
-                                        // Clean up any string args we already allocated.
-                                        for ptr in string_arg_ptrs {
-                                            unsafe {
-                                                let _ = Box::from_raw(ptr as *mut String);
-                                            }
-                                        }
-                                    }
-                                    if args_ok {
-                                        if let Some(result) =
-                                            self.jit_tier.execute(&callee_cell.name, &i64_args)
-                                        {
-                                            // Check if the JIT function returns a string pointer.
-                                            if self.jit_tier.returns_string(&callee_cell.name) {
-                                                // Convert the raw *mut String pointer back to a
-                                                // Value::String. This consumes the heap allocation.
-                                                let s = unsafe {
-                                                    crate::jit_tier::take_jit_string(result)
-                                                };
-                                                self.registers[callee_reg] =
-                                                    Value::String(StringRef::Owned(s));
-                                            } else {
-                                                self.registers[callee_reg] = Value::Int(result);
-                                            }
-                                            continue;
-                                        }
-                                    }
-                                    // JIT execution failed — fall through to interpreter
-                                }
-                            }
-                            // ─── END JIT TIER ────────────────────────────────
-
-                            // Fast path: direct cell call — no cloning, no dispatch_call overhead
-                            if self.frames.len() >= MAX_CALL_DEPTH {
-                                return Err(VmError::StackOverflow(MAX_CALL_DEPTH));
-                            }
-                            let callee_cell = &module.cells[target_idx];
-                            let num_regs = callee_cell.registers as usize;
-                            let new_base = self.grow_registers(num_regs.max(16));
-
-                            // Inline arg copy — avoid cloning params Vec.
-                            // Use raw pointer to access params from module (no borrow conflict).
-                            let callee_cell_ptr = &module.cells[target_idx] as *const LirCell;
-                            let params_ptr = unsafe { &(*callee_cell_ptr).params };
-                            let cell_regs = callee_cell.registers;
-                            for i in 0..nargs {
-                                if i < params_ptr.len() {
-                                    let dst = params_ptr[i].register as usize;
-                                    debug_assert!(
-                                        (dst as u16) < cell_regs,
-                                        "register OOB in fast call"
-                                    );
-                                    // Move arg value instead of cloning — avoids Rc refcount
-                                    // bump on every call. The caller's arg slot (base+a+1+i) is
-                                    // set to Null. This is safe because:
-                                    // 1. Arg registers are evaluation temporaries that held the
-                                    //    computed arguments; they are not read after the call.
-                                    // 2. The return value is written to a separate register
-                                    //    (callee_reg = base+a, not base+a+1+i).
-                                    // 3. If a register IS reused after return (rare edge case),
-                                    //    it reads Null — a clear runtime error, not silent
-                                    //    corruption.
-                                    self.registers[new_base + dst] =
-                                        std::mem::take(&mut self.registers[base + a + 1 + i]);
-                                }
-                            }
-
-                            self.frames.push(CallFrame {
-                                cell_idx: target_idx,
-                                base_register: new_base,
-                                ip: 0,
-                                return_register: callee_reg,
-                                future_id: None,
-                            });
-
-                            if has_debug {
-                                self.emit_debug_event(DebugEvent::CallEnter {
-                                    cell_name: module.cells[target_idx].name.clone(),
-                                });
-                            }
-
-                            // Reload frame state
-                            cell_idx = target_idx;
-                            base = new_base;
-                            ip = 0;
-                            cell = &module.cells[cell_idx];
-                            continue;
-                        }
-
-                        // Slow path: closures, builtins, other callable values
-                        if let Err(err) = self.dispatch_call(base, a, b) {
-                            if self.fail_current_future(err.to_string()) {
-                                // Reload frame state after future failure
-                                if self.frames.len() <= limit {
-                                    return Ok(Value::Null);
-                                }
-                                let frame = self.frames.last().unwrap();
-                                cell_idx = frame.cell_idx;
-                                base = frame.base_register;
-                                ip = frame.ip;
-                                cell = &module.cells[cell_idx];
-                                continue;
-                            }
-                            return Err(err);
-                        }
-                        // Reload frame state after call (new frame pushed)
-                        let frame = self.frames.last().unwrap();
-                        cell_idx = frame.cell_idx;
-                        base = frame.base_register;
-                        ip = frame.ip;
-                        cell = &module.cells[cell_idx];
-                        continue;
-                    }
-                    OpCode::TailCall => {
-                        // ─── JIT TIER: TailCall fast path ────────────────
-                        // Same JIT dispatch as Call, but on success we
-                        // simulate Return (pop frame, write to caller)
-                        // instead of continuing in the current frame.
-                        let callee_reg = base + a;
-                        let nargs = b;
-                        let mut jit_handled = false;
-
-                        if self.jit_tier.is_enabled() {
-                            // Resolve cell index from callee register
-                            let fast_cell_idx = match &self.registers[callee_reg] {
-                                Value::String(sr) => {
-                                    let name_str = match sr {
-                                        StringRef::Owned(s) => s.as_str(),
-                                        StringRef::Interned(id) => {
-                                            self.strings.resolve(*id).unwrap_or("")
-                                        }
-                                    };
-                                    if name_str == cell.name {
-                                        Some(cell_idx)
-                                    } else if let Some(&cached) =
-                                        self.cell_index_cache.get(name_str)
-                                    {
-                                        Some(cached)
-                                    } else if let Some(idx) =
-                                        module.cells.iter().position(|c| c.name == name_str)
-                                    {
-                                        self.cell_index_cache.insert(name_str.to_string(), idx);
-                                        Some(idx)
-                                    } else {
-                                        None
-                                    }
-                                }
-                                _ => None,
-                            };
-
-                            if let Some(target_idx) = fast_cell_idx {
-                                let run_jit = if self.jit_tier.is_compiled(target_idx) {
-                                    true
-                                } else if self.jit_tier.record_call(target_idx) {
-                                    if self.jit_tier.check_eligibility(target_idx, module) {
-                                        self.jit_tier.try_compile(target_idx, module)
-                                    } else {
-                                        false
-                                    }
-                                } else {
-                                    false
-                                };
-
-                                if run_jit {
-                                    let callee_cell = &module.cells[target_idx];
-                                    let mut i64_args: Vec<i64> = Vec::with_capacity(nargs);
-                                    let mut string_arg_ptrs: Vec<i64> = Vec::new();
-                                    let mut args_ok = true;
-                                    for i in 0..nargs {
-                                        match &self.registers[base + a + 1 + i] {
-                                            Value::Int(v) => i64_args.push(*v),
-                                            Value::Float(v) => i64_args.push(v.to_bits() as i64),
-                                            Value::String(s) => {
-                                                let owned = match s {
-                                                    StringRef::Owned(o) => o.clone(),
-                                                    StringRef::Interned(id) => module
-                                                        .strings
-                                                        .get(*id as usize)
-                                                        .cloned()
-                                                        .unwrap_or_default(),
-                                                };
-                                                let boxed = Box::new(owned);
-                                                let ptr = Box::into_raw(boxed) as i64;
-                                                string_arg_ptrs.push(ptr);
-                                                i64_args.push(ptr);
-                                            }
-                                            Value::Bool(b) => i64_args.push(if *b { 1 } else { 0 }),
-                                            _ => {
-                                                args_ok = false;
-                                                break;
-                                            }
-                                        }
-                                    }
-                                    if !args_ok {
-                                        for ptr in string_arg_ptrs {
-                                            unsafe {
-                                                let _ = Box::from_raw(ptr as *mut String);
-                                            }
-                                        }
-                                    }
-                                    if args_ok {
-                                        if let Some(result) =
-                                            self.jit_tier.execute(&callee_cell.name, &i64_args)
-                                        {
-                                            // Convert i64 result back to Value
-                                            let result_value = if self
-                                                .jit_tier
-                                                .returns_string(&callee_cell.name)
-                                            {
-                                                let s = unsafe {
-                                                    crate::jit_tier::take_jit_string(result)
-                                                };
-                                                Value::String(StringRef::Owned(s))
-                                            } else {
-                                                Value::Int(result)
-                                            };
-
-                                            // TailCall JIT success: simulate Return.
-                                            // Pop current frame and write result to caller.
-                                            let frame = self.frames.pop().ok_or_else(|| {
-                                                VmError::Runtime(
-                                                    "call stack underflow on tailcall JIT".into(),
-                                                )
-                                            })?;
-
-                                            if has_debug {
-                                                let cname =
-                                                    module.cells[frame.cell_idx].name.clone();
-                                                self.emit_debug_event(DebugEvent::CallExit {
-                                                    cell_name: cname,
-                                                    result: result_value.clone(),
-                                                });
-                                            }
-
-                                            self.shrink_registers(frame.base_register);
-
-                                            if let Some(fid) = frame.future_id {
-                                                self.future_states.insert(
-                                                    fid,
-                                                    FutureState::Completed(result_value),
-                                                );
-                                                if self.frames.len() <= limit {
-                                                    return Ok(Value::Null);
-                                                }
-                                                let f = self.frames.last().unwrap();
-                                                cell_idx = f.cell_idx;
-                                                base = f.base_register;
-                                                ip = f.ip;
-                                                cell = &module.cells[cell_idx];
-                                                continue;
-                                            }
-                                            if self.frames.len() <= limit {
-                                                return Ok(result_value);
-                                            }
-                                            self.registers[frame.return_register] = result_value;
-                                            let f = self.frames.last().unwrap();
-                                            cell_idx = f.cell_idx;
-                                            base = f.base_register;
-                                            ip = f.ip;
-                                            cell = &module.cells[cell_idx];
-                                            jit_handled = true;
-                                        }
-                                    }
-                                    // JIT execution failed — fall through to interpreter
-                                }
-                            }
-                        }
-                        // ─── END JIT TIER (TailCall) ─────────────────────
-
-                        if jit_handled {
-                            continue;
-                        }
-
-                        if let Err(err) = self.dispatch_tailcall(base, a, b) {
-                            if self.fail_current_future(err.to_string()) {
-                                if self.frames.len() <= limit {
-                                    return Ok(Value::Null);
-                                }
-                                let frame = self.frames.last().unwrap();
-                                cell_idx = frame.cell_idx;
-                                base = frame.base_register;
-                                ip = frame.ip;
-                                cell = &module.cells[cell_idx];
-                                continue;
-                            }
-                            return Err(err);
-                        }
-                        // Reload frame state after tailcall (frame reused)
-                        let frame = self.frames.last().unwrap();
-                        cell_idx = frame.cell_idx;
-                        base = frame.base_register;
-                        ip = frame.ip;
-                        cell = &module.cells[cell_idx];
-                        continue;
-                    }
-                    OpCode::Intrinsic => {
-                        let result = match self.exec_intrinsic(base, a, b, c) {
-                            Ok(v) => v,
-                            Err(err) => {
-                                if self.fail_current_future(err.to_string()) {
-                                    if self.frames.len() <= limit {
-                                        return Ok(Value::Null);
-                                    }
-                                    let frame = self.frames.last().unwrap();
-                                    cell_idx = frame.cell_idx;
-                                    base = frame.base_register;
-                                    ip = frame.ip;
-                                    cell = &module.cells[cell_idx];
-                                    continue;
-                                }
-                                return Err(err);
-                            }
-                        };
-                        self.registers[base + a] = result;
-                        continue;
-                    }
-                    _ => unreachable!("guarded by matches! above"),
+            if table_dispatch {
+                if let Some(handler) = dispatch::HANDLERS[instr.op as usize] {
+                    handler(self, module, cell, base, instr, &mut ip)?;
+                    continue;
                 }
             }
 
-            // For all other opcodes, use the cached module/cell references directly
-            // No need to re-borrow self.module
-
+            // One jump-table dispatch per instruction; every opcode, calls
+            // included, is an arm of this match. Under table dispatch only
+            // the opcodes without a handler get here.
             match instr.op {
                 OpCode::Nop => { /* no operation */ }
 
-                OpCode::LoadK => {
-                    let bx = instr.bx() as usize;
-                    let val = match &cell.constants[bx] {
-                        Constant::Null => Value::Null,
-                        Constant::Bool(v) => Value::Bool(*v),
-                        Constant::Int(v) => Value::Int(*v),
-                        Constant::BigInt(v) => Value::BigInt(v.clone()),
-                        Constant::Float(v) => Value::Float(*v),
-                        Constant::String(v) => Value::String(StringRef::Owned(v.clone())),
-                    };
-                    self.registers[base + a] = val;
-                }
-                OpCode::LoadNil => {
-                    for i in 0..=b {
-                        self.registers[base + a + i] = Value::Null;
-                    }
-                }
+                OpCode::LoadK => dispatch::op_load_k(self, module, cell, base, instr, &mut ip)?,
+                OpCode::LoadNil => dispatch::op_load_nil(self, module, cell, base, instr, &mut ip)?,
                 OpCode::LoadBool => {
-                    self.registers[base + a] = Value::Bool(b != 0);
-                    if c != 0 {
-                        ip += 1;
-                    }
-                }
-                OpCode::LoadInt => {
-                    let sb = instr.sbx();
-                    self.registers[base + a] = Value::Int(sb as i64);
-                }
-                OpCode::Move => {
-                    // Clone the source register. With Rc-wrapped collections this
-                    // is just a refcount increment (negligible cost). For scalars
-                    // (Int, Float, Bool) it is a tiny copy. The previous
-                    // `std::mem::replace` approach destructively nulled the source
-                    // register, causing use-after-move bugs whenever the compiler
-                    // emitted code that referenced the source register again.
-                    self.registers[base + a] = self.registers[base + b].clone();
-                }
-                OpCode::MoveOwn => {
-                    // Destructive move: source register becomes Null.
-                    // The compiler guarantees the source is dead after this
-                    // instruction, so it is safe to take ownership without
-                    // cloning.  For Rc/Arc-wrapped collections this avoids a
-                    // refcount bump, which in turn lets subsequent in-place
-                    // mutations (Arc::make_mut, push_str, etc.) skip the
-                    // copy-on-write path.
-                    self.registers[base + a] = std::mem::take(&mut self.registers[base + b]);
+                    dispatch::op_load_bool(self, module, cell, base, instr, &mut ip)?
                 }
+                OpCode::LoadInt => dispatch::op_load_int(self, module, cell, base, instr, &mut ip)?,
+                OpCode::Move => dispatch::op_move(self, module, cell, base, instr, &mut ip)?,
+                OpCode::MoveOwn => dispatch::op_move_own(self, module, cell, base, instr, &mut ip)?,
                 OpCode::NewList => {
                     let mut list = Vec::with_capacity(b);
                     for i in 1..=b {
                         list.push(self.registers[base + a + i].clone());
                     }
                     self.registers[base + a] = Value::new_list(list);
+                    self.record_alloc(cell_idx, ip - 1, b);
                 }
                 OpCode::NewMap => {
                     let mut map = BTreeMap::new();
@@ -1957,6 +1904,7 @@ impl VM {
                         map.insert(k, v);
                     }
                     self.registers[base + a] = Value::new_map(map);
+                    self.record_alloc(cell_idx, ip - 1, 2 * b);
                 }
                 OpCode::NewRecord => {
                     let bx = instr.bx() as usize;
@@ -1965,7 +1913,16 @@ impl VM {
                     } else {
                         "Unknown".to_string()
                     };
-                    let fields = BTreeMap::new();
+                    if self.alloc_profiler.is_some() {
+                        // Fields are set one at a time after this, so the
+                        // declared ones are counted now
+                        let declared = match self.types.get(&type_name).map(|t| &t.kind) {
+                            Some(RuntimeTypeKind::Record(fields)) => fields.len(),
+                            _ => 0,
+                        };
+                        self.record_alloc(cell_idx, ip - 1, 2 * declared);
+                    }
+                    let fields = FieldMap::new();
                     self.registers[base + a] = Value::new_record(RecordValue { type_name, fields });
                 }
                 OpCode::NewUnion => {
@@ -1981,6 +1938,7 @@ impl VM {
                         elems.push(self.registers[base + a + i].clone());
                     }
                     self.registers[base + a] = Value::new_tuple(elems);
+                    self.record_alloc(cell_idx, ip - 1, b);
                 }
                 OpCode::NewSet => {
                     let mut elems = Vec::with_capacity(b);
@@ -1991,6 +1949,7 @@ impl VM {
                         }
                     }
                     self.registers[base + a] = Value::new_set_from_vec(elems);
+                    self.record_alloc(cell_idx, ip - 1, b);
                 }
 
                 // Access
@@ -2001,12 +1960,18 @@ impl VM {
                     } else {
                         ""
                     };
-                    let val = match obj {
+                    let cached = match obj {
                         Value::Record(r) => {
-                            r.fields.get(field_name).cloned().unwrap_or(Value::Null)
+                            self.field_cache
+                                .get(cell_idx, ip - 1, &r.fields, field_name)
                         }
-                        Value::Map(m) => m.get(field_name).cloned().unwrap_or(Value::Null),
-                        _ => Value::Null,
+                        _ => None,
+                    };
+                    let val = match (cached, obj) {
+                        (Some(v), _) => v.clone(),
+                        (None, Value::Map(m)) => m.get(field_name).cloned().unwrap_or(Value::Null),
+                        (None, Value::Null) => Value::Null,
+                        (None, _) => self.method_ref(module, obj.type_name(), field_name),
                     };
                     self.registers[base + a] = val;
                 }
@@ -2017,8 +1982,18 @@ impl VM {
                     } else {
                         String::new()
                     };
-                    if let Value::Record(ref mut r) = self.registers[base + a] {
-                        Arc::make_mut(r).fields.insert(field_name, val);
+                    match &mut self.registers[base + a] {
+                        Value::Record(r) => {
+                            Arc::make_mut(r).fields.insert(field_name, val);
+                        }
+                        Value::Map(m) => {
+                            Arc::make_mut(m).insert(field_name, val);
+                        }
+                        Value::Null => {
+                            let key = Value::String(StringRef::Owned(field_name));
+                            return Err(null_entry_write(&key, &self.strings));
+                        }
+                        _ => {}
                     }
                 }
                 OpCode::GetIndex => {
@@ -2030,10 +2005,7 @@ impl VM {
                             let len = l.len() as i64;
                             let effective = if ii < 0 { ii + len } else { ii };
                             if effective < 0 || effective >= len {
-                                return Err(VmError::Runtime(format!(
-                                    "index {} out of bounds for list of length {}",
-                                    ii, len
-                                )));
+                                return Err(index_out_of_range(ii, l.len()));
                             }
                             l[effective as usize].clone()
                         }
@@ -2042,37 +2014,50 @@ impl VM {
                             let len = t.len() as i64;
                             let effective = if ii < 0 { ii + len } else { ii };
                             if effective < 0 || effective >= len {
-                                return Err(VmError::Runtime(format!(
-                                    "index {} out of bounds for tuple of length {}",
-                                    ii, len
-                                )));
+                                return Err(index_out_of_range(ii, t.len()));
                             }
                             t[effective as usize].clone()
                         }
+                        // Strings index by UTF-8 byte; `rune_at` reads by character
+                        (Value::String(_), Value::Int(i)) => {
+                            let s = value_to_str_cow(obj, &self.strings);
+                            let ii = *i;
+                            let len = s.len() as i64;
+                            let effective = if ii < 0 { ii + len } else { ii };
+                            if effective < 0 || effective >= len {
+                                return Err(index_out_of_range(ii, s.len()));
+                            }
+                            Value::Int(s.as_bytes()[effective as usize] as i64)
+                        }
                         (Value::Map(m), _) => m
                             .get(&idx.as_string_resolved(&self.strings))
                             .cloned()
                             .unwrap_or(Value::Null),
-                        (Value::Record(r), _) => r
-                            .fields
-                            .get(&idx.as_string_resolved(&self.strings))
-                            .cloned()
-                            .unwrap_or(Value::Null),
+                        (Value::Record(r), _) => {
+                            let name = value_to_str_cow(idx, &self.strings);
+                            match self.field_cache.get(cell_idx, ip - 1, &r.fields, &name) {
+                                Some(v) => v.clone(),
+                                None => self.method_ref(module, &r.type_name, &name),
+                            }
+                        }
                         (Value::Set(s), Value::Int(i)) => {
                             let ii = *i;
                             let len = s.len() as i64;
                             let effective = if ii < 0 { ii + len } else { ii };
                             if effective < 0 || effective >= len {
-                                return Err(VmError::Runtime(format!(
-                                    "index {} out of bounds for set of size {}",
-                                    ii, len
-                                )));
+                                return Err(index_out_of_range(ii, s.len()));
                             }
                             s.iter()
                                 .nth(effective as usize)
                                 .cloned()
                                 .unwrap_or(Value::Null)
                         }
+                        (Value::Null, _) => Value::Null,
+                        (_, Value::String(_)) => self.method_ref(
+                            module,
+                            obj.type_name(),
+                            &value_to_str_cow(idx, &self.strings),
+                        ),
                         _ => Value::Null,
                     };
                     self.registers[base + a] = val;
@@ -2086,10 +2071,7 @@ impl VM {
                                 let len = l.len() as i64;
                                 let effective = if i < 0 { i + len } else { i };
                                 if effective < 0 || effective >= len {
-                                    return Err(VmError::Runtime(format!(
-                                        "index {} out of bounds for list of length {}",
-                                        i, len
-                                    )));
+                                    return Err(index_out_of_range(i, l.len()));
                                 }
                                 Arc::make_mut(l)[effective as usize] = val;
                             } else {
@@ -2105,150 +2087,34 @@ impl VM {
                         Value::Record(r) => {
                             Arc::make_mut(r)
                                 .fields
-                                .insert(key.as_string_resolved(&self.strings), val);
-                        }
-                        target => {
-                            return Err(VmError::TypeError(format!(
-                                "cannot assign by index on {} (expected list, map, or record)",
-                                target.type_name()
-                            )));
-                        }
-                    }
-                }
-                OpCode::GetTuple => {
-                    let obj = &self.registers[base + b];
-                    let val = match obj {
-                        Value::Tuple(t) => {
-                            if c >= t.len() {
-                                return Err(VmError::Runtime(format!(
-                                    "index {} out of bounds for tuple of length {}",
-                                    c,
-                                    t.len()
-                                )));
-                            }
-                            t[c].clone()
-                        }
-                        Value::List(l) => {
-                            if c >= l.len() {
-                                return Err(VmError::Runtime(format!(
-                                    "index {} out of bounds for list of length {}",
-                                    c,
-                                    l.len()
-                                )));
-                            }
-                            l[c].clone()
-                        }
-                        _ => Value::Null,
-                    };
-                    self.registers[base + a] = val;
-                }
-
-                // Arithmetic
-                OpCode::Add => {
-                    let lhs = &self.registers[base + b];
-                    let rhs = &self.registers[base + c];
-                    // Check for strings first for concatenation
-                    if matches!(lhs, Value::String(_)) || matches!(rhs, Value::String(_)) {
-                        // In-place string concat optimization: when the destination
-                        // IS the source (a == b, i.e. `s = s + "x"`), we can safely
-                        // take the left value out and push_str in-place to avoid
-                        // allocating a new String. Otherwise we must clone to avoid
-                        // nulling a register that may be read again later.
-                        if a == b {
-                            // Read RHS as borrowed &str before mutating LHS
-                            let rhs_cow =
-                                value_to_str_cow(&self.registers[base + c], &self.strings);
-                            let rhs_str: String = rhs_cow.into_owned();
-                            // Safe in-place: destination overwrites source
-                            let left_val =
-                                std::mem::replace(&mut self.registers[base + b], Value::Null);
-                            let result = match left_val {
-                                Value::String(StringRef::Owned(mut s)) => {
-                                    s.push_str(&rhs_str);
-                                    Value::String(StringRef::Owned(s))
-                                }
-                                other => {
-                                    let left_str = match &other {
-                                        Value::String(StringRef::Interned(id)) => {
-                                            self.strings.resolve(*id).unwrap_or("").to_string()
-                                        }
-                                        _ => other.as_string(),
-                                    };
-                                    let mut s =
-                                        String::with_capacity(left_str.len() + rhs_str.len());
-                                    s.push_str(&left_str);
-                                    s.push_str(&rhs_str);
-                                    Value::String(StringRef::Owned(s))
-                                }
-                            };
-                            self.registers[base + a] = result;
-                        } else {
-                            // Not safe to take: borrow both operands
-                            let lhs_cow =
-                                value_to_str_cow(&self.registers[base + b], &self.strings);
-                            let rhs_cow =
-                                value_to_str_cow(&self.registers[base + c], &self.strings);
-                            let mut s = String::with_capacity(lhs_cow.len() + rhs_cow.len());
-                            s.push_str(&lhs_cow);
-                            s.push_str(&rhs_cow);
-                            self.registers[base + a] = Value::String(StringRef::Owned(s));
+                                .insert(key.as_string_resolved(&self.strings), val);
                         }
-                    } else if matches!(lhs, Value::List(_)) && matches!(rhs, Value::List(_)) {
-                        // List concatenation with pre-allocated capacity
-                        if let (Value::List(l), Value::List(r)) = (lhs, rhs) {
-                            let mut combined = Vec::with_capacity(l.len() + r.len());
-                            combined.extend(l.iter().cloned());
-                            combined.extend(r.iter().cloned());
-                            self.registers[base + a] = Value::new_list(combined);
+                        // Reading a null map yields null, but writing one is
+                        // always a bug: the map was never initialized.
+                        Value::Null => return Err(null_entry_write(&key, &self.strings)),
+                        target => {
+                            return Err(VmError::TypeError(format!(
+                                "cannot assign by index on {} (expected list, map, or record)",
+                                target.type_name()
+                            )));
                         }
-                    } else {
-                        // Numeric addition with promotion
-                        self.arith_op(base, a, b, c, BinaryOp::Add)?;
                     }
                 }
-                OpCode::Sub => {
-                    self.arith_op(base, a, b, c, BinaryOp::Sub)?;
-                }
-                OpCode::Mul => {
-                    self.arith_op(base, a, b, c, BinaryOp::Mul)?;
-                }
-                OpCode::Div => {
-                    // Pre-check for integer division by zero
-                    if matches!(
-                        (&self.registers[base + b], &self.registers[base + c]),
-                        (Value::Int(_), Value::Int(0))
-                    ) {
-                        return Err(VmError::DivisionByZero);
-                    }
-                    self.arith_op(base, a, b, c, BinaryOp::Div)?;
+                OpCode::GetTuple => {
+                    dispatch::op_get_tuple(self, module, cell, base, instr, &mut ip)?
                 }
+
+                // Arithmetic
+                OpCode::Add => dispatch::op_add(self, module, cell, base, instr, &mut ip)?,
+                OpCode::Sub => dispatch::op_sub(self, module, cell, base, instr, &mut ip)?,
+                OpCode::Mul => dispatch::op_mul(self, module, cell, base, instr, &mut ip)?,
+                OpCode::Div => dispatch::op_div(self, module, cell, base, instr, &mut ip)?,
                 OpCode::FloorDiv => {
-                    self.arith_op(base, a, b, c, BinaryOp::FloorDiv)?;
-                }
-                OpCode::Mod => {
-                    // Pre-check for integer modulo by zero
-                    if matches!(
-                        (&self.registers[base + b], &self.registers[base + c]),
-                        (Value::Int(_), Value::Int(0))
-                    ) {
-                        return Err(VmError::DivisionByZero);
-                    }
-                    self.arith_op(base, a, b, c, BinaryOp::Mod)?;
-                }
-                OpCode::Pow => {
-                    self.arith_op(base, a, b, c, BinaryOp::Pow)?;
-                }
-                OpCode::Neg => {
-                    let val = &self.registers[base + b];
-                    self.registers[base + a] = match val {
-                        Value::Int(n) => n
-                            .checked_neg()
-                            .map(Value::Int)
-                            .ok_or(VmError::ArithmeticOverflow("negation".to_string()))?,
-                        Value::Float(f) => Value::Float(-f),
-                        _ => return Err(VmError::TypeError(format!("cannot negate {}", val))),
-                    };
+                    dispatch::op_floor_div(self, module, cell, base, instr, &mut ip)?
                 }
+                OpCode::Mod => dispatch::op_mod(self, module, cell, base, instr, &mut ip)?,
+                OpCode::Pow => dispatch::op_pow(self, module, cell, base, instr, &mut ip)?,
+                OpCode::Neg => dispatch::op_neg(self, module, cell, base, instr, &mut ip)?,
                 OpCode::Concat => {
                     let lhs = &self.registers[base + b];
                     let rhs = &self.registers[base + c];
@@ -2372,108 +2238,10 @@ impl VM {
                 }
 
                 // Comparison / logic
-                OpCode::Eq => {
-                    let lhs = &self.registers[base + b];
-                    let rhs = &self.registers[base + c];
-                    let eq = values_equal(lhs, rhs, &self.strings);
-                    self.registers[base + a] = Value::Bool(eq);
-                }
-                OpCode::Lt => {
-                    let b_val = &self.registers[base + b];
-                    let c_val = &self.registers[base + c];
-                    let result = match (b_val, c_val) {
-                        (Value::Int(x), Value::Int(y)) => x < y,
-                        (Value::Float(x), Value::Float(y)) => x < y,
-                        (Value::Int(x), Value::Float(y)) => (*x as f64) < *y,
-                        (Value::Float(x), Value::Int(y)) => *x < (*y as f64),
-                        (Value::String(x), Value::String(y)) => {
-                            let s1 = match x {
-                                StringRef::Owned(s) => s.as_str(),
-                                StringRef::Interned(id) => self.strings.resolve(*id).unwrap_or(""),
-                            };
-                            let s2 = match y {
-                                StringRef::Owned(s) => s.as_str(),
-                                StringRef::Interned(id) => self.strings.resolve(*id).unwrap_or(""),
-                            };
-                            s1 < s2
-                        }
-                        (Value::Int(x), Value::BigInt(y)) => BigInt::from(*x) < *y,
-                        (Value::BigInt(x), Value::Int(y)) => *x < BigInt::from(*y),
-                        (Value::BigInt(x), Value::BigInt(y)) => x < y,
-                        (Value::BigInt(x), Value::Float(y)) => {
-                            let af = x.to_f64().unwrap_or_else(|| {
-                                if x.sign() == num_bigint::Sign::Minus {
-                                    f64::NEG_INFINITY
-                                } else {
-                                    f64::INFINITY
-                                }
-                            });
-                            af < *y
-                        }
-                        (Value::Float(x), Value::BigInt(y)) => {
-                            let bf = y.to_f64().unwrap_or_else(|| {
-                                if y.sign() == num_bigint::Sign::Minus {
-                                    f64::NEG_INFINITY
-                                } else {
-                                    f64::INFINITY
-                                }
-                            });
-                            *x < bf
-                        }
-                        _ => false,
-                    };
-                    self.registers[base + a] = Value::Bool(result);
-                }
-                OpCode::Le => {
-                    let b_val = &self.registers[base + b];
-                    let c_val = &self.registers[base + c];
-                    let result = match (b_val, c_val) {
-                        (Value::Int(x), Value::Int(y)) => x <= y,
-                        (Value::Float(x), Value::Float(y)) => x <= y,
-                        (Value::Int(x), Value::Float(y)) => (*x as f64) <= *y,
-                        (Value::Float(x), Value::Int(y)) => *x <= (*y as f64),
-                        (Value::String(x), Value::String(y)) => {
-                            let s1 = match x {
-                                StringRef::Owned(s) => s.as_str(),
-                                StringRef::Interned(id) => self.strings.resolve(*id).unwrap_or(""),
-                            };
-                            let s2 = match y {
-                                StringRef::Owned(s) => s.as_str(),
-                                StringRef::Interned(id) => self.strings.resolve(*id).unwrap_or(""),
-                            };
-                            s1 <= s2
-                        }
-                        (Value::Int(x), Value::BigInt(y)) => BigInt::from(*x) <= *y,
-                        (Value::BigInt(x), Value::Int(y)) => *x <= BigInt::from(*y),
-                        (Value::BigInt(x), Value::BigInt(y)) => x <= y,
-                        (Value::BigInt(x), Value::Float(y)) => {
-                            let af = x.to_f64().unwrap_or_else(|| {
-                                if x.sign() == num_bigint::Sign::Minus {
-                                    f64::NEG_INFINITY
-                                } else {
-                                    f64::INFINITY
-                                }
-                            });
-                            af <= *y
-                        }
-                        (Value::Float(x), Value::BigInt(y)) => {
-                            let bf = y.to_f64().unwrap_or_else(|| {
-                                if y.sign() == num_bigint::Sign::Minus {
-                                    f64::NEG_INFINITY
-                                } else {
-                                    f64::INFINITY
-                                }
-                            });
-                            *x <= bf
-                        }
-                        _ => false,
-                    };
-                    self.registers[base + a] = Value::Bool(result);
-                }
-                OpCode::Not => {
-                    let truthy = self.value_is_truthy(&self.registers[base + b]);
-                    self.registers[base + a] = Value::Bool(!truthy);
-                }
+                OpCode::Eq => dispatch::op_eq(self, module, cell, base, instr, &mut ip)?,
+                OpCode::Lt => dispatch::op_lt(self, module, cell, base, instr, &mut ip)?,
+                OpCode::Le => dispatch::op_le(self, module, cell, base, instr, &mut ip)?,
+                OpCode::Not => dispatch::op_not(self, module, cell, base, instr, &mut ip)?,
                 OpCode::And => {
                     let lt = self.value_is_truthy(&self.registers[base + b]);
                     let rt = self.value_is_truthy(&self.registers[base + c]);
@@ -2508,29 +2276,438 @@ impl VM {
                     let matches = val.type_name_resolved(&self.strings) == type_str.as_ref();
                     self.registers[base + a] = Value::Bool(matches);
                 }
-                OpCode::NullCo => {
-                    let val = &self.registers[base + b];
-                    if matches!(val, Value::Null) {
-                        self.registers[base + a] = self.registers[base + c].clone();
-                    } else {
-                        self.registers[base + a] = val.clone();
+                OpCode::NullCo => dispatch::op_null_co(self, module, cell, base, instr, &mut ip)?,
+                OpCode::Test => dispatch::op_test(self, module, cell, base, instr, &mut ip)?,
+
+                // Control flow
+                OpCode::Jmp => dispatch::op_jmp(self, module, cell, base, instr, &mut ip)?,
+                // Calls sync the IP back to the frame, since call and return need it.
+                OpCode::Call | OpCode::TailCall | OpCode::Intrinsic => {
+                    if !matches!(instr.op, OpCode::Intrinsic) {
+                        self.safepoint(ip)?;
                     }
-                }
-                OpCode::Test => {
-                    let truthy = self.value_is_truthy(&self.registers[base + a]);
-                    if truthy != (c != 0) {
-                        ip += 1;
+                    // Sync IP back to frame before dispatch (call/return needs it)
+                    if let Some(f) = self.frames.last_mut() {
+                        f.ip = ip;
                     }
-                }
+                    match instr.op {
+                        OpCode::Call => {
+                            // === FAST PATH: inline cell call ===
+                            // Resolve cell index from callee register WITHOUT cloning.
+                            // This avoids heap-allocating a String clone on every call.
+                            let callee_reg = base + a;
+                            let nargs = b;
+                            let fast_cell_idx = match &self.registers[callee_reg] {
+                                Value::String(sr) => {
+                                    let name_str = match sr {
+                                        StringRef::Owned(s) => s.as_str(),
+                                        StringRef::Interned(id) => {
+                                            self.strings.resolve(*id).unwrap_or("")
+                                        }
+                                    };
+                                    // Fast check: is this a self-recursive call?
+                                    // Compare against current cell name to skip HashMap lookup.
+                                    if name_str == cell.name {
+                                        Some(cell_idx)
+                                    } else if let Some(&cached) =
+                                        self.cell_index_cache.get(name_str)
+                                    {
+                                        Some(cached)
+                                    } else if let Some(idx) =
+                                        module.cells.iter().position(|c| c.name == name_str)
+                                    {
+                                        self.cell_index_cache.insert(name_str.to_string(), idx);
+                                        Some(idx)
+                                    } else {
+                                        None // builtin — fall through to dispatch_call
+                                    }
+                                }
+                                _ => None, // closure or other — fall through to dispatch_call
+                            };
 
-                // Control flow
-                OpCode::Jmp => {
-                    let offset = instr.sax_val();
-                    ip = (ip as i32 + offset) as usize;
-                }
-                // Call and TailCall are handled in the pre-match above
-                OpCode::Call | OpCode::TailCall => {
-                    unreachable!()
+                            if let Some(target_idx) = fast_cell_idx {
+                                // ─── JIT TIER: check if cell is compiled ─────────
+                                // If the cell is already JIT-compiled, execute it as
+                                // a native function pointer and skip the interpreter.
+                                if self.jit_tier.is_enabled() {
+                                    // Check if already compiled
+                                    let run_jit = if self.jit_tier.is_compiled(target_idx) {
+                                        true
+                                    } else if self.jit_tier.record_call(target_idx) {
+                                        // Just crossed hot threshold — try to compile
+                                        if self.jit_tier.check_eligibility(target_idx, module) {
+                                            self.jit_tier.try_compile(target_idx, module)
+                                        } else {
+                                            false
+                                        }
+                                    } else {
+                                        false
+                                    };
+
+                                    if run_jit {
+                                        // Extract i64 args from registers.
+                                        // Int → raw i64, Float → f64 bits as i64,
+                                        // String → heap-clone as *mut String cast to i64
+                                        // (the JIT owns this pointer and will free it).
+                                        let callee_cell = &module.cells[target_idx];
+                                        let mut i64_args: Vec<i64> = Vec::with_capacity(nargs);
+                                        let mut string_arg_ptrs: Vec<i64> = Vec::new();
+                                        let mut args_ok = true;
+                                        for i in 0..nargs {
+                                            match &self.registers[base + a + 1 + i] {
+                                                Value::Int(v) => i64_args.push(*v),
+                                                Value::Float(v) => {
+                                                    i64_args.push(v.to_bits() as i64)
+                                                }
+                                                Value::String(s) => {
+                                                    // Allocate a heap String for the JIT.
+                                                    // The JIT takes ownership via *mut String.
+                                                    let owned = match s {
+                                                        StringRef::Owned(o) => o.clone(),
+                                                        StringRef::Interned(id) => module
+                                                            .strings
+                                                            .get(*id as usize)
+                                                            .cloned()
+                                                            .unwrap_or_default(),
+                                                    };
+                                                    let boxed = Box::new(owned);
+                                                    let ptr = Box::into_raw(boxed) as i64;
+                                                    string_arg_ptrs.push(ptr);
+                                                    i64_args.push(ptr);
+                                                }
+                                                Value::Bool(b) => {
+                                                    i64_args.push(if *b { 1 } else { 0 })
+                                                }
+                                                _ => {
+                                                    args_ok = false;
+                                                    break;
+                                                }
+                                            }
+                                        }
+                                        if !args_ok {
+                                            // Clean up any string args we already allocated.
+                                            for ptr in string_arg_ptrs {
+                                                unsafe {
+                                                    let _ = Box::from_raw(ptr as *mut String);
+                                                }
+                                            }
+                                        }
+                                        if args_ok {
+                                            if let Some(result) =
+                                                self.jit_tier.execute(&callee_cell.name, &i64_args)
+                                            {
+                                                // Check if the JIT function returns a string pointer.
+                                                if self.jit_tier.returns_string(&callee_cell.name) {
+                                                    // Convert the raw *mut String pointer back to a
+                                                    // Value::String. This consumes the heap allocation.
+                                                    let s = unsafe {
+                                                        crate::jit_tier::take_jit_string(result)
+                                                    };
+                                                    self.registers[callee_reg] =
+                                                        Value::String(StringRef::Owned(s));
+                                                } else {
+                                                    self.registers[callee_reg] = Value::Int(result);
+                                                }
+                                                continue;
+                                            }
+                                        }
+                                        // JIT execution failed — fall through to interpreter
+                                    }
+                                }
+                                // ─── END JIT TIER ────────────────────────────────
+
+                                // Fast path: direct cell call — no cloning, no dispatch_call overhead
+                                if self.frames.len() >= self.max_call_depth {
+                                    return Err(VmError::StackOverflow(self.max_call_depth));
+                                }
+                                let callee_cell = &module.cells[target_idx];
+                                let num_regs = callee_cell.registers as usize;
+                                let new_base = self.grow_registers(num_regs.max(16));
+
+                                // Inline arg copy — avoid cloning params Vec.
+                                // Use raw pointer to access params from module (no borrow conflict).
+                                let callee_cell_ptr = &module.cells[target_idx] as *const LirCell;
+                                let params_ptr = unsafe { &(*callee_cell_ptr).params };
+                                let cell_regs = callee_cell.registers;
+                                for i in 0..nargs {
+                                    if i < params_ptr.len() {
+                                        let dst = params_ptr[i].register as usize;
+                                        debug_assert!(
+                                            (dst as u16) < cell_regs,
+                                            "register OOB in fast call"
+                                        );
+                                        // Move arg value instead of cloning — avoids Rc refcount
+                                        // bump on every call. The caller's arg slot (base+a+1+i) is
+                                        // set to Null. This is safe because:
+                                        // 1. Arg registers are evaluation temporaries that held the
+                                        //    computed arguments; they are not read after the call.
+                                        // 2. The return value is written to a separate register
+                                        //    (callee_reg = base+a, not base+a+1+i).
+                                        // 3. If a register IS reused after return (rare edge case),
+                                        //    it reads Null — a clear runtime error, not silent
+                                        //    corruption.
+                                        self.registers[new_base + dst] =
+                                            std::mem::take(&mut self.registers[base + a + 1 + i]);
+                                    }
+                                }
+
+                                self.frames.push(CallFrame {
+                                    cell_idx: target_idx,
+                                    base_register: new_base,
+                                    ip: 0,
+                                    return_register: callee_reg,
+                                    future_id: None,
+                                });
+
+                                if has_debug {
+                                    self.emit_debug_event(DebugEvent::CallEnter {
+                                        cell_name: module.cells[target_idx].name.clone(),
+                                    });
+                                }
+
+                                // Reload frame state
+                                cell_idx = target_idx;
+                                base = new_base;
+                                ip = 0;
+                                cell = &module.cells[cell_idx];
+                                continue;
+                            }
+
+                            // Slow path: closures, builtins, other callable values
+                            if let Err(err) = self.dispatch_call(base, a, b) {
+                                if self.fail_current_future(err.to_string()) {
+                                    // Reload frame state after future failure
+                                    if self.frames.len() <= limit {
+                                        return Ok(Value::Null);
+                                    }
+                                    let frame = self.frames.last().unwrap();
+                                    cell_idx = frame.cell_idx;
+                                    base = frame.base_register;
+                                    ip = frame.ip;
+                                    cell = &module.cells[cell_idx];
+                                    continue;
+                                }
+                                return Err(err);
+                            }
+                            // Reload frame state after call (new frame pushed)
+                            let frame = self.frames.last().unwrap();
+                            cell_idx = frame.cell_idx;
+                            base = frame.base_register;
+                            ip = frame.ip;
+                            cell = &module.cells[cell_idx];
+                            continue;
+                        }
+                        OpCode::TailCall => {
+                            // ─── JIT TIER: TailCall fast path ────────────────
+                            // Same JIT dispatch as Call, but on success we
+                            // simulate Return (pop frame, write to caller)
+                            // instead of continuing in the current frame.
+                            let callee_reg = base + a;
+                            let nargs = b;
+                            let mut jit_handled = false;
+
+                            if self.jit_tier.is_enabled() {
+                                // Resolve cell index from callee register
+                                let fast_cell_idx = match &self.registers[callee_reg] {
+                                    Value::String(sr) => {
+                                        let name_str = match sr {
+                                            StringRef::Owned(s) => s.as_str(),
+                                            StringRef::Interned(id) => {
+                                                self.strings.resolve(*id).unwrap_or("")
+                                            }
+                                        };
+                                        if name_str == cell.name {
+                                            Some(cell_idx)
+                                        } else if let Some(&cached) =
+                                            self.cell_index_cache.get(name_str)
+                                        {
+                                            Some(cached)
+                                        } else if let Some(idx) =
+                                            module.cells.iter().position(|c| c.name == name_str)
+                                        {
+                                            self.cell_index_cache.insert(name_str.to_string(), idx);
+                                            Some(idx)
+                                        } else {
+                                            None
+                                        }
+                                    }
+                                    _ => None,
+                                };
+
+                                if let Some(target_idx) = fast_cell_idx {
+                                    let run_jit = if self.jit_tier.is_compiled(target_idx) {
+                                        true
+                                    } else if self.jit_tier.record_call(target_idx) {
+                                        if self.jit_tier.check_eligibility(target_idx, module) {
+                                            self.jit_tier.try_compile(target_idx, module)
+                                        } else {
+                                            false
+                                        }
+                                    } else {
+                                        false
+                                    };
+
+                                    if run_jit {
+                                        let callee_cell = &module.cells[target_idx];
+                                        let mut i64_args: Vec<i64> = Vec::with_capacity(nargs);
+                                        let mut string_arg_ptrs: Vec<i64> = Vec::new();
+                                        let mut args_ok = true;
+                                        for i in 0..nargs {
+                                            match &self.registers[base + a + 1 + i] {
+                                                Value::Int(v) => i64_args.push(*v),
+                                                Value::Float(v) => {
+                                                    i64_args.push(v.to_bits() as i64)
+                                                }
+                                                Value::String(s) => {
+                                                    let owned = match s {
+                                                        StringRef::Owned(o) => o.clone(),
+                                                        StringRef::Interned(id) => module
+                                                            .strings
+                                                            .get(*id as usize)
+                                                            .cloned()
+                                                            .unwrap_or_default(),
+                                                    };
+                                                    let boxed = Box::new(owned);
+                                                    let ptr = Box::into_raw(boxed) as i64;
+                                                    string_arg_ptrs.push(ptr);
+                                                    i64_args.push(ptr);
+                                                }
+                                                Value::Bool(b) => {
+                                                    i64_args.push(if *b { 1 } else { 0 })
+                                                }
+                                                _ => {
+                                                    args_ok = false;
+                                                    break;
+                                                }
+                                            }
+                                        }
+                                        if !args_ok {
+                                            for ptr in string_arg_ptrs {
+                                                unsafe {
+                                                    let _ = Box::from_raw(ptr as *mut String);
+                                                }
+                                            }
+                                        }
+                                        if args_ok {
+                                            if let Some(result) =
+                                                self.jit_tier.execute(&callee_cell.name, &i64_args)
+                                            {
+                                                // Convert i64 result back to Value
+                                                let result_value = if self
+                                                    .jit_tier
+                                                    .returns_string(&callee_cell.name)
+                                                {
+                                                    let s = unsafe {
+                                                        crate::jit_tier::take_jit_string(result)
+                                                    };
+                                                    Value::String(StringRef::Owned(s))
+                                                } else {
+                                                    Value::Int(result)
+                                                };
+
+                                                // TailCall JIT success: simulate Return.
+                                                // Pop current frame and write result to caller.
+                                                let frame = self.frames.pop().ok_or_else(|| {
+                                                    VmError::Runtime(
+                                                        "call stack underflow on tailcall JIT"
+                                                            .into(),
+                                                    )
+                                                })?;
+
+                                                if has_debug {
+                                                    let cname =
+                                                        module.cells[frame.cell_idx].name.clone();
+                                                    self.emit_debug_event(DebugEvent::CallExit {
+                                                        cell_name: cname,
+                                                        result: result_value.clone(),
+                                                    });
+                                                }
+
+                                                self.shrink_registers(frame.base_register);
+
+                                                if let Some(fid) = frame.future_id {
+                                                    self.future_states.insert(
+                                                        fid,
+                                                        FutureState::Completed(result_value),
+                                                    );
+                                                    if self.frames.len() <= limit {
+                                                        return Ok(Value::Null);
+                                                    }
+                                                    let f = self.frames.last().unwrap();
+                                                    cell_idx = f.cell_idx;
+                                                    base = f.base_register;
+                                                    ip = f.ip;
+                                                    cell = &module.cells[cell_idx];
+                                                    continue;
+                                                }
+                                                if self.frames.len() <= limit {
+                                                    return Ok(result_value);
+                                                }
+                                                self.registers[frame.return_register] =
+                                                    result_value;
+                                                let f = self.frames.last().unwrap();
+                                                cell_idx = f.cell_idx;
+                                                base = f.base_register;
+                                                ip = f.ip;
+                                                cell = &module.cells[cell_idx];
+                                                jit_handled = true;
+                                            }
+                                        }
+                                        // JIT execution failed — fall through to interpreter
+                                    }
+                                }
+                            }
+                            // ─── END JIT TIER (TailCall) ─────────────────────
+
+                            if jit_handled {
+                                continue;
+                            }
+
+                            if let Err(err) = self.dispatch_tailcall(base, a, b) {
+                                if self.fail_current_future(err.to_string()) {
+                                    if self.frames.len() <= limit {
+                                        return Ok(Value::Null);
+                                    }
+                                    let frame = self.frames.last().unwrap();
+                                    cell_idx = frame.cell_idx;
+                                    base = frame.base_register;
+                                    ip = frame.ip;
+                                    cell = &module.cells[cell_idx];
+                                    continue;
+                                }
+                                return Err(err);
+                            }
+                            // Reload frame state after tailcall (frame reused)
+                            let frame = self.frames.last().unwrap();
+                            cell_idx = frame.cell_idx;
+                            base = frame.base_register;
+                            ip = frame.ip;
+                            cell = &module.cells[cell_idx];
+                            continue;
+                        }
+                        OpCode::Intrinsic => {
+                            let result = match self.exec_intrinsic(base, a, b, c) {
+                                Ok(v) => v,
+                                Err(err) => {
+                                    if self.fail_current_future(err.to_string()) {
+                                        if self.frames.len() <= limit {
+                                            return Ok(Value::Null);
+                                        }
+                                        let frame = self.frames.last().unwrap();
+                                        cell_idx = frame.cell_idx;
+                                        base = frame.base_register;
+                                        ip = frame.ip;
+                                        cell = &module.cells[cell_idx];
+                                        continue;
+                                    }
+                                    return Err(err);
+                                }
+                            };
+                            self.registers[base + a] = result;
+                            continue;
+                        }
+                        _ => unreachable!("guarded by the enclosing match arm"),
+                    }
                 }
                 OpCode::Return => {
                     // Take the return value instead of cloning when possible.
@@ -2605,6 +2782,7 @@ impl VM {
                     if let Value::Int(ref mut n) = self.registers[base + a] {
                         *n -= 1;
                         if *n > 0 {
+                            self.safepoint(ip)?;
                             ip = (ip as i32 + sb) as usize;
                         }
                     }
@@ -2640,6 +2818,7 @@ impl VM {
                         };
                         self.registers[base + a + 3] = elem;
                         self.registers[base + a + 1] = Value::Int(idx + 1);
+                        self.safepoint(ip)?;
                         ip = (ip as i32 - bx as i32) as usize;
                     }
                 }
@@ -2694,12 +2873,23 @@ impl VM {
                 OpCode::Continue => {
                     // Jump to loop start (offset in Ax)
                     let offset = instr.sax_val();
+                    if offset < 0 {
+                        self.safepoint(ip)?;
+                    }
                     ip = (ip as i32 + offset) as usize;
                 }
-
-                // Intrinsic is handled in the pre-match above
-                OpCode::Intrinsic => {
-                    unreachable!()
+                OpCode::JmpTable => {
+                    // The B instructions after this one are the table's Jmps,
+                    // indexed from the low bound in R[C]. Values outside the
+                    // table (or non-Int values) skip it to the fallthrough path.
+                    let entry = match (&self.registers[base + a], &self.registers[base + c]) {
+                        (Value::Int(v), Value::Int(low)) => v.checked_sub(*low),
+                        _ => None,
+                    };
+                    match entry {
+                        Some(i) if i >= 0 && (i as usize) < b => ip += i as usize,
+                        _ => ip += b,
+                    }
                 }
 
                 // Closures
@@ -2891,8 +3081,8 @@ impl VM {
                 }
                 OpCode::Emit => {
                     let val = self.registers[base + a].display_pretty();
-                    println!("{}", val);
-                    self.output.push(val);
+                    self.stdout.write_line(&val);
+                    self.capture_line(val);
                 }
                 OpCode::TraceRef => {
                     self.registers[base + a] = Value::TraceRef(self.next_trace_ref());
@@ -2961,35 +3151,9 @@ impl VM {
 
                 // Type checks
                 OpCode::IsVariant => {
-                    let val = &self.registers[base + a];
-                    let tag_idx = instr.bx() as usize;
-                    // The bx field is an index into module.strings. We need to
-                    // resolve it to an interned string ID to compare against the
-                    // Union's tag (which is also an interned ID).
-                    let tag_id = if tag_idx < module.strings.len() {
-                        self.strings.intern(&module.strings[tag_idx])
-                    } else {
-                        u32::MAX // will never match
-                    };
-                    let matched = match val {
-                        Value::Union(u) => u.tag == tag_id,
-                        _ => false,
-                    };
-                    if matched {
-                        ip += 1;
-                    }
-                }
-                OpCode::Unbox => {
-                    // Clone the union payload instead of destructively taking
-                    // from the source register, which could null out a variable
-                    // that is still needed later (e.g. in multi-branch match).
-                    let val = &self.registers[base + b];
-                    if let Value::Union(u) = val {
-                        self.registers[base + a] = (*u.payload).clone();
-                    } else {
-                        self.registers[base + a] = Value::Null;
-                    }
+                    dispatch::op_is_variant(self, module, cell, base, instr, &mut ip)?
                 }
+                OpCode::Unbox => dispatch::op_unbox(self, module, cell, base, instr, &mut ip)?,
 
                 // Algebraic effects
                 OpCode::HandlePush => {
@@ -3139,8 +3303,8 @@ impl VM {
                     None
                 };
                 if let Some(idx) = idx_opt {
-                    if self.frames.len() >= MAX_CALL_DEPTH {
-                        return Err(VmError::StackOverflow(MAX_CALL_DEPTH));
+                    if self.frames.len() >= self.max_call_depth {
+                        return Err(VmError::StackOverflow(self.max_call_depth));
                     }
                     let callee_cell = &module.cells[idx];
                     let num_regs = callee_cell.registers as usize;
@@ -3168,8 +3332,8 @@ impl VM {
                 }
             }
             Value::Closure(ref cv) => {
-                if self.frames.len() >= MAX_CALL_DEPTH {
-                    return Err(VmError::StackOverflow(MAX_CALL_DEPTH));
+                if self.frames.len() >= self.max_call_depth {
+                    return Err(VmError::StackOverflow(self.max_call_depth));
                 }
                 let cv = cv.clone();
                 let module = self.module.as_ref().ok_or(VmError::NoModule)?;
@@ -3265,6 +3429,7 @@ impl VM {
                     let params: Vec<LirParam> = callee_cell.params.clone();
                     let cell_regs = callee_cell.registers;
                     let _ = module;
+                    self.grow_frame_registers(base, (cell_regs as usize).max(16));
                     self.copy_args_to_params(&params, base, base + a + 1, nargs, 0, cell_regs)?;
                     if let Some(f) = self.frames.last_mut() {
                         f.cell_idx = idx;
@@ -3283,6 +3448,7 @@ impl VM {
                 let params: Vec<LirParam> = callee_cell.params.clone();
                 let cell_regs = callee_cell.registers;
                 let _ = module;
+                self.grow_frame_registers(base, (cell_regs as usize).max(16));
                 for (i, cap) in cv.captures.iter().enumerate() {
                     self.check_register(i, cell_regs)?;
                     self.registers[base + i] = cap.clone();
@@ -4763,6 +4929,40 @@ end
         assert_eq!(vm.future_schedule(), FutureSchedule::Eager);
     }
 
+    #[test]
+    fn test_schedule_directive_overrides_deterministic_default() {
+        let mut module = make_return_42();
+        module.addons.push(LirAddon {
+            kind: "directive".to_string(),
+            name: Some("deterministic=true".to_string()),
+        });
+        module.addons.push(LirAddon {
+            kind: "directive".to_string(),
+            name: Some("schedule=cooperative".to_string()),
+        });
+        let mut vm = VM::new();
+        vm.load(module);
+        assert_eq!(vm.future_schedule(), FutureSchedule::Cooperative);
+    }
+
+    #[test]
+    fn test_spawn_await_cooperative_schedule() {
+        let module = make_spawn_await_module(
+            vec![
+                Instruction::abx(OpCode::LoadK, 0, 0),
+                Instruction::abc(OpCode::Return, 0, 1, 0),
+            ],
+            vec![Constant::Int(9)],
+        );
+        let mut vm = VM::new();
+        vm.set_future_schedule(FutureSchedule::Cooperative);
+        vm.load(module);
+        let out = vm
+            .execute("main", vec![])
+            .expect("cooperative spawn/await should resolve");
+        assert_eq!(out, Value::Int(9));
+    }
+
     #[test]
     fn test_parallel_builtin_collects_values() {
         let result = run_main(
@@ -5245,10 +5445,10 @@ end
 
     #[test]
     fn test_stack_overflow_detection() {
-        // Verify MAX_CALL_DEPTH is enforced
+        // Verify DEFAULT_MAX_CALL_DEPTH is enforced
         let mut vm = VM::new();
         // Push frames up to the limit
-        for _ in 0..MAX_CALL_DEPTH {
+        for _ in 0..DEFAULT_MAX_CALL_DEPTH {
             vm.frames.push(CallFrame {
                 cell_idx: 0,
                 base_register: 0,
@@ -5257,7 +5457,7 @@ end
                 future_id: None,
             });
         }
-        assert_eq!(vm.frames.len(), MAX_CALL_DEPTH);
+        assert_eq!(vm.frames.len(), DEFAULT_MAX_CALL_DEPTH);
     }
 
     #[test]
@@ -7613,4 +7813,27 @@ end
             .expect("2 calls within budget of 2 should succeed");
         assert_eq!(result, Value::String(StringRef::Owned("ok".into())));
     }
+
+    #[test]
+    fn test_regex_patterns_compile_once_per_vm() {
+        let source = r#"
+cell main() -> Int
+  var found = 0
+  for i in range(0, 50)
+    if regex_find("[0-9]+", "a{i}b") != null
+      found = found + 1
+    end
+  end
+  return found + len(regex_find_all("[a-z]", "x1y2"))
+end
+"#;
+        let md = format!("# test\n\n```lumen\n{}\n```\n", source.trim());
+        let module = compile_lumen(&md).expect("source should compile");
+        let mut vm = VM::new();
+        vm.load(module);
+        let result = vm.execute("main", vec![]).expect("main should execute");
+        assert_eq!(result, Value::Int(52));
+        // One entry per distinct pattern, however often each one ran
+        assert_eq!(vm.regex_cache.len(), 2);
+    }
 }
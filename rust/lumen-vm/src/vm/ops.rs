@@ -1,4 +1,4 @@
-//! Arithmetic, diff, patch, and redact operations for the VM.
+//! Arithmetic, comparison, diff, patch, and redact operations for the VM.
 
 use super::*;
 use std::collections::BTreeMap;
@@ -21,6 +21,58 @@ pub enum BinaryOp {
     Rem,
 }
 
+/// How two values order for `<` and `<=`. None when they do not compare,
+/// which makes both false.
+pub(crate) fn compare_values(
+    lhs: &Value,
+    rhs: &Value,
+    strings: &StringTable,
+) -> Option<std::cmp::Ordering> {
+    // A BigInt too large for f64 compares as the infinity of its sign
+    let big_to_f64 = |x: &BigInt| {
+        x.to_f64()
+            .unwrap_or(if x.sign() == num_bigint::Sign::Minus {
+                f64::NEG_INFINITY
+            } else {
+                f64::INFINITY
+            })
+    };
+    match (lhs, rhs) {
+        (Value::Int(x), Value::Int(y)) => Some(x.cmp(y)),
+        (Value::Float(x), Value::Float(y)) => x.partial_cmp(y),
+        (Value::Int(x), Value::Float(y)) => (*x as f64).partial_cmp(y),
+        (Value::Float(x), Value::Int(y)) => x.partial_cmp(&(*y as f64)),
+        (Value::String(x), Value::String(y)) => {
+            let s1 = match x {
+                StringRef::Owned(s) => s.as_str(),
+                StringRef::Interned(id) => strings.resolve(*id).unwrap_or(""),
+            };
+            let s2 = match y {
+                StringRef::Owned(s) => s.as_str(),
+                StringRef::Interned(id) => strings.resolve(*id).unwrap_or(""),
+            };
+            Some(s1.cmp(s2))
+        }
+        (Value::Int(x), Value::BigInt(y)) => Some(BigInt::from(*x).cmp(y)),
+        (Value::BigInt(x), Value::Int(y)) => Some(x.cmp(&BigInt::from(*y))),
+        (Value::BigInt(x), Value::BigInt(y)) => Some(x.cmp(y)),
+        (Value::BigInt(x), Value::Float(y)) => big_to_f64(x).partial_cmp(y),
+        (Value::Float(x), Value::BigInt(y)) => x.partial_cmp(&big_to_f64(y)),
+        _ => None,
+    }
+}
+
+/// Method a record type defines to overload an arithmetic operator.
+fn operator_method(op: BinaryOp) -> Option<&'static str> {
+    match op {
+        BinaryOp::Add => Some("add"),
+        BinaryOp::Sub => Some("sub"),
+        BinaryOp::Mul => Some("mul"),
+        BinaryOp::Div => Some("div"),
+        _ => None,
+    }
+}
+
 /// Checked integer arithmetic — returns None on overflow or division by zero.
 #[inline(always)]
 fn int_op(op: BinaryOp, x: i64, y: i64) -> Option<i64> {
@@ -360,9 +412,117 @@ impl VM {
             return Ok(());
         }
 
+        // Records dispatch to their operator method, `Vec2.add` for `+`
+        if let (Value::Record(_), Some(method)) = (lhs_ref, operator_method(op)) {
+            let (lhs, rhs) = (lhs_ref.clone(), rhs_ref.clone());
+            if let Some(result) = self.call_operator_method(method, &lhs, &rhs)? {
+                self.registers[base + a] = result;
+                return Ok(());
+            }
+            self.registers[base + a] = arith_op_slow(op, &lhs, &rhs)?;
+            return Ok(());
+        }
+
         // COLD PATH: BigInt and error cases — delegated to a separate non-inlined function
         // so the compiler doesn't bloat the hot path's instruction cache footprint.
         self.registers[base + a] = arith_op_slow(op, lhs_ref, rhs_ref)?;
         Ok(())
     }
+
+    /// `+`: string concatenation when either side is a string, list
+    /// concatenation for two lists, and arithmetic otherwise.
+    #[inline(always)]
+    pub(crate) fn add_op(
+        &mut self,
+        base: usize,
+        a: usize,
+        b: usize,
+        c: usize,
+    ) -> Result<(), VmError> {
+        let lhs = &self.registers[base + b];
+        let rhs = &self.registers[base + c];
+        // Check for strings first for concatenation
+        if matches!(lhs, Value::String(_)) || matches!(rhs, Value::String(_)) {
+            // In-place string concat optimization: when the destination
+            // IS the source (a == b, i.e. `s = s + "x"`), we can safely
+            // take the left value out and push_str in-place to avoid
+            // allocating a new String. Otherwise we must clone to avoid
+            // nulling a register that may be read again later.
+            if a == b {
+                // Read RHS as borrowed &str before mutating LHS
+                let rhs_cow = value_to_str_cow(&self.registers[base + c], &self.strings);
+                let rhs_str: String = rhs_cow.into_owned();
+                // Safe in-place: destination overwrites source
+                let left_val = std::mem::replace(&mut self.registers[base + b], Value::Null);
+                let result = match left_val {
+                    Value::String(StringRef::Owned(mut s)) => {
+                        s.push_str(&rhs_str);
+                        Value::String(StringRef::Owned(s))
+                    }
+                    other => {
+                        let left_str = match &other {
+                            Value::String(StringRef::Interned(id)) => {
+                                self.strings.resolve(*id).unwrap_or("").to_string()
+                            }
+                            _ => other.as_string(),
+                        };
+                        let mut s = String::with_capacity(left_str.len() + rhs_str.len());
+                        s.push_str(&left_str);
+                        s.push_str(&rhs_str);
+                        Value::String(StringRef::Owned(s))
+                    }
+                };
+                self.registers[base + a] = result;
+            } else {
+                // Not safe to take: borrow both operands
+                let lhs_cow = value_to_str_cow(&self.registers[base + b], &self.strings);
+                let rhs_cow = value_to_str_cow(&self.registers[base + c], &self.strings);
+                let mut s = String::with_capacity(lhs_cow.len() + rhs_cow.len());
+                s.push_str(&lhs_cow);
+                s.push_str(&rhs_cow);
+                self.registers[base + a] = Value::String(StringRef::Owned(s));
+            }
+        } else if matches!(lhs, Value::List(_)) && matches!(rhs, Value::List(_)) {
+            // List concatenation with pre-allocated capacity
+            if let (Value::List(l), Value::List(r)) = (lhs, rhs) {
+                let mut combined = Vec::with_capacity(l.len() + r.len());
+                combined.extend(l.iter().cloned());
+                combined.extend(r.iter().cloned());
+                self.registers[base + a] = Value::new_list(combined);
+            }
+        } else {
+            // Numeric addition with promotion
+            self.arith_op(base, a, b, c, BinaryOp::Add)?;
+        }
+        Ok(())
+    }
+
+    /// Call `lhs.method(rhs)` when `lhs` is a record whose type defines the
+    /// operator method. Returns None when it does not.
+    pub(crate) fn call_operator_method(
+        &mut self,
+        method: &str,
+        lhs: &Value,
+        rhs: &Value,
+    ) -> Result<Option<Value>, VmError> {
+        let Value::Record(r) = lhs else {
+            return Ok(None);
+        };
+        let name = format!("{}.{}", r.type_name, method);
+        let module = self.module.as_ref().ok_or(VmError::NoModule)?;
+        let cell_idx = if let Some(&cached) = self.cell_index_cache.get(&name) {
+            cached
+        } else if let Some(idx) = module.cells.iter().position(|c| c.name == name) {
+            self.cell_index_cache.insert(name, idx);
+            idx
+        } else {
+            return Ok(None);
+        };
+        let closure = ClosureValue {
+            cell_idx,
+            captures: vec![],
+        };
+        self.call_closure_sync(&closure, &[lhs.clone(), rhs.clone()])
+            .map(Some)
+    }
 }
@@ -0,0 +1,389 @@
+//! Go-style `%` formatting for the `sprintf` and `printf` builtins.
+//!
+//! A directive is `%[flags][width][.precision]verb`. Flags, padding, and
+//! sign placement follow Go's `fmt` package; mistakes are reported inline
+//! the way Go reports them (`%!d(String=hi)`, `%!d(MISSING)`,
+//! `%!(EXTRA Int=1)`) rather than failing the call.
+
+use crate::values::{StringRef, Value};
+use num_bigint::Sign;
+
+/// Widths and precisions above this are rejected, as in Go.
+const MAX_WIDTH: usize = 1_000_000;
+
+#[derive(Default)]
+struct Spec {
+    minus: bool,
+    plus: bool,
+    sharp: bool,
+    space: bool,
+    zero: bool,
+    width: Option<usize>,
+    precision: Option<usize>,
+}
+
+/// Render `template` with `args`. String arguments must already be owned
+/// (resolved out of the string table).
+pub(crate) fn sprintf(template: &str, args: &[Value]) -> String {
+    let mut out = String::new();
+    let mut chars = template.chars().peekable();
+    let mut next_arg = 0;
+    while let Some(ch) = chars.next() {
+        if ch != '%' {
+            out.push(ch);
+            continue;
+        }
+        let mut spec = Spec::default();
+        while let Some(&c) = chars.peek() {
+            match c {
+                '-' => {
+                    spec.minus = true;
+                    spec.zero = false; // never pad with zeros on the right
+                }
+                '+' => spec.plus = true,
+                '#' => spec.sharp = true,
+                ' ' => spec.space = true,
+                '0' => spec.zero = !spec.minus,
+                _ => break,
+            }
+            chars.next();
+        }
+        spec.width = parse_num(&mut chars);
+        if spec.width.is_some_and(|w| w > MAX_WIDTH) {
+            out.push_str("%!(BADWIDTH)");
+            spec.width = None;
+        }
+        if chars.peek() == Some(&'.') {
+            chars.next();
+            spec.precision = Some(parse_num(&mut chars).unwrap_or(0));
+            if spec.precision.is_some_and(|p| p > MAX_WIDTH) {
+                out.push_str("%!(BADPREC)");
+                spec.precision = None;
+            }
+        }
+        let Some(verb) = chars.next() else {
+            out.push_str("%!(NOVERB)");
+            break;
+        };
+        if verb == '%' {
+            out.push('%');
+            continue;
+        }
+        match args.get(next_arg) {
+            Some(arg) => out.push_str(&format_arg(arg, verb, &spec)),
+            None => out.push_str(&format!("%!{}(MISSING)", verb)),
+        }
+        next_arg += 1;
+    }
+    if next_arg < args.len() {
+        let extra: Vec<String> = args[next_arg..].iter().map(describe).collect();
+        out.push_str(&format!("%!(EXTRA {})", extra.join(", ")));
+    }
+    out
+}
+
+fn parse_num(chars: &mut std::iter::Peekable<std::str::Chars<'_>>) -> Option<usize> {
+    let mut n: Option<usize> = None;
+    while let Some(d) = chars.peek().and_then(|c| c.to_digit(10)) {
+        n = Some(n.unwrap_or(0).saturating_mul(10).saturating_add(d as usize));
+        chars.next();
+    }
+    n
+}
+
+/// `Type=value`, as shown in bad-verb and extra-argument reports.
+fn describe(arg: &Value) -> String {
+    format!("{}={}", arg.type_name(), arg.display_pretty())
+}
+
+fn format_arg(arg: &Value, verb: char, spec: &Spec) -> String {
+    match (verb, arg) {
+        ('d' | 'v', Value::Int(n)) => fmt_integer(n.unsigned_abs().to_string(), *n < 0, verb, spec),
+        ('x' | 'X' | 'o' | 'b', Value::Int(n)) => {
+            let m = n.unsigned_abs();
+            let digits = match verb {
+                'x' => format!("{:x}", m),
+                'X' => format!("{:X}", m),
+                'o' => format!("{:o}", m),
+                _ => format!("{:b}", m),
+            };
+            fmt_integer(digits, *n < 0, verb, spec)
+        }
+        ('d' | 'v' | 'x' | 'X' | 'o' | 'b', Value::BigInt(n)) => {
+            let mut digits = n.magnitude().to_str_radix(radix(verb));
+            if verb == 'X' {
+                digits.make_ascii_uppercase();
+            }
+            fmt_integer(digits, n.sign() == Sign::Minus, verb, spec)
+        }
+        ('c', Value::Int(n)) => {
+            let c = u32::try_from(*n)
+                .ok()
+                .and_then(char::from_u32)
+                .unwrap_or(char::REPLACEMENT_CHARACTER);
+            pad(c.to_string(), spec)
+        }
+        ('f' | 'F' | 'e' | 'E', Value::Float(f)) => fmt_float(*f, verb, spec),
+        ('f' | 'F' | 'e' | 'E', Value::Int(n)) => fmt_float(*n as f64, verb, spec),
+        ('v', Value::Float(f)) => {
+            let text = arg.display_pretty();
+            if spec.plus && f.is_sign_positive() && !f.is_nan() {
+                pad(format!("+{}", text), spec)
+            } else {
+                pad(text, spec)
+            }
+        }
+        ('s' | 'v', Value::String(StringRef::Owned(s))) => match spec.precision {
+            Some(p) => pad(s.chars().take(p).collect(), spec),
+            None => pad(s.clone(), spec),
+        },
+        ('x' | 'X', Value::String(StringRef::Owned(s))) => fmt_hex_bytes(s.as_bytes(), verb, spec),
+        ('x' | 'X', Value::Bytes(b)) => fmt_hex_bytes(b, verb, spec),
+        ('t' | 'v', Value::Bool(b)) => pad(b.to_string(), spec),
+        ('v', _) => pad(arg.display_pretty(), spec),
+        _ => format!("%!{}({})", verb, describe(arg)),
+    }
+}
+
+fn radix(verb: char) -> u32 {
+    match verb {
+        'x' | 'X' => 16,
+        'o' => 8,
+        'b' => 2,
+        _ => 10,
+    }
+}
+
+/// Pad `text` to the width with spaces, on the right for `-`.
+fn pad(text: String, spec: &Spec) -> String {
+    let len = text.chars().count();
+    match spec.width {
+        Some(w) if w > len && spec.minus => format!("{}{}", text, " ".repeat(w - len)),
+        Some(w) if w > len => format!("{}{}", " ".repeat(w - len), text),
+        _ => text,
+    }
+}
+
+/// Lay out integer `digits` (the magnitude, already in the verb's base) with
+/// the precision as a minimum digit count, zero padding after the sign, the
+/// `#` base prefix, and the sign. Mirrors Go's `fmtInteger`.
+fn fmt_integer(digits: String, negative: bool, verb: char, spec: &Spec) -> String {
+    let min_digits = match (spec.precision, spec.width) {
+        // `%.0d` of zero prints nothing but padding.
+        (Some(0), _) if digits == "0" => return pad(String::new(), spec),
+        (Some(p), _) => p,
+        (None, Some(w)) if spec.zero => {
+            w.saturating_sub((negative || spec.plus || spec.space) as usize)
+        }
+        _ => 0,
+    };
+    let mut body = String::new();
+    if spec.sharp {
+        match verb {
+            'b' => body.push_str("0b"),
+            // Octal gets a leading 0 unless the digits already start with one.
+            'o' if digits.len() >= min_digits && !digits.starts_with('0') => body.push('0'),
+            'x' => body.push_str("0x"),
+            'X' => body.push_str("0X"),
+            _ => {}
+        }
+    }
+    if digits.len() < min_digits {
+        body.push_str(&"0".repeat(min_digits - digits.len()));
+    }
+    body.push_str(&digits);
+    let sign = if negative {
+        "-"
+    } else if spec.plus {
+        "+"
+    } else if spec.space {
+        " "
+    } else {
+        ""
+    };
+    pad(format!("{}{}", sign, body), spec)
+}
+
+/// `%f` and `%e` with Go's default precision of 6. Infinities and NaN are
+/// never zero padded; `+Inf` always carries its sign.
+fn fmt_float(v: f64, verb: char, spec: &Spec) -> String {
+    let sign = if v.is_sign_negative() && !v.is_nan() {
+        "-"
+    } else if spec.plus {
+        "+"
+    } else if spec.space {
+        " "
+    } else {
+        ""
+    };
+    if v.is_nan() {
+        return pad(format!("{}NaN", sign), spec);
+    }
+    if v.is_infinite() {
+        let sign = if sign.is_empty() { "+" } else { sign };
+        return pad(format!("{}Inf", sign), spec);
+    }
+    let prec = spec.precision.unwrap_or(6);
+    let body = match verb {
+        'e' | 'E' => go_exponent(&format!("{:.*e}", prec, v.abs()), verb),
+        _ => format!("{:.*}", prec, v.abs()),
+    };
+    let len = sign.len() + body.len();
+    match spec.width {
+        Some(w) if spec.zero && w > len => format!("{}{}{}", sign, "0".repeat(w - len), body),
+        _ => pad(format!("{}{}", sign, body), spec),
+    }
+}
+
+/// Rewrite Rust's `1.5e3` exponent form as Go's `1.5e+03`.
+fn go_exponent(rust: &str, verb: char) -> String {
+    let (mantissa, exp) = rust.split_once('e').unwrap_or((rust, "0"));
+    let exp: i32 = exp.parse().unwrap_or(0);
+    format!(
+        "{}{}{}{:02}",
+        mantissa,
+        verb,
+        if exp < 0 { '-' } else { '+' },
+        exp.unsigned_abs()
+    )
+}
+
+fn fmt_hex_bytes(bytes: &[u8], verb: char, spec: &Spec) -> String {
+    let bytes = match spec.precision {
+        Some(p) if p < bytes.len() => &bytes[..p],
+        _ => bytes,
+    };
+    if bytes.is_empty() {
+        return pad(String::new(), spec);
+    }
+    let mut text = String::with_capacity(bytes.len() * 2 + 2);
+    if spec.sharp {
+        text.push_str(if verb == 'X' { "0X" } else { "0x" });
+    }
+    for b in bytes {
+        if verb == 'X' {
+            text.push_str(&format!("{:02X}", b));
+        } else {
+            text.push_str(&format!("{:02x}", b));
+        }
+    }
+    pad(text, spec)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn s(text: &str) -> Value {
+        Value::String(StringRef::Owned(text.to_string()))
+    }
+
+    // Expected strings are Go's `fmt.Sprintf` output for the same calls.
+    #[test]
+    fn zero_padding_goes_after_the_sign() {
+        assert_eq!(sprintf("%08d", &[Value::Int(42)]), "00000042");
+        assert_eq!(sprintf("%08d", &[Value::Int(-42)]), "-0000042");
+        assert_eq!(sprintf("%+08d", &[Value::Int(42)]), "+0000042");
+        assert_eq!(sprintf("%08.3f", &[Value::Float(-3.14159)]), "-003.142");
+        assert_eq!(sprintf("%.5d", &[Value::Int(-42)]), "-00042");
+        assert_eq!(sprintf("%8.5d", &[Value::Int(42)]), "   00042");
+        assert_eq!(sprintf("[%.0d]", &[Value::Int(0)]), "[]");
+        assert_eq!(sprintf("%08f", &[Value::Float(f64::INFINITY)]), "    +Inf");
+    }
+
+    #[test]
+    fn minus_left_aligns_and_disables_zero_padding() {
+        assert_eq!(sprintf("[%-10s]", &[s("name")]), "[name      ]");
+        assert_eq!(sprintf("[%10s]", &[s("name")]), "[      name]");
+        assert_eq!(sprintf("[%-08d]", &[Value::Int(42)]), "[42      ]");
+        assert_eq!(sprintf("[%0-8d]", &[Value::Int(42)]), "[42      ]");
+        assert_eq!(sprintf("[%-6t]", &[Value::Bool(true)]), "[true  ]");
+        assert_eq!(sprintf("[%.3s]", &[s("héllo")]), "[hél]");
+        assert_eq!(sprintf("[%4s]", &[s("日本")]), "[  日本]");
+    }
+
+    #[test]
+    fn explicit_sign_and_space() {
+        assert_eq!(
+            sprintf("%+d %+d", &[Value::Int(5), Value::Int(-5)]),
+            "+5 -5"
+        );
+        assert_eq!(
+            sprintf("% d|% d", &[Value::Int(5), Value::Int(-5)]),
+            " 5|-5"
+        );
+        assert_eq!(sprintf("%+.2f", &[Value::Float(1.5)]), "+1.50");
+        assert_eq!(sprintf("%+.1f", &[Value::Float(-0.0)]), "-0.0");
+        assert_eq!(
+            sprintf("%+f %f", &[Value::Float(f64::NAN), Value::Float(f64::NAN)]),
+            "+NaN NaN"
+        );
+        assert_eq!(sprintf("%+x", &[Value::Int(255)]), "+ff");
+    }
+
+    #[test]
+    fn base_conversions() {
+        assert_eq!(
+            sprintf(
+                "%x %X %o %b",
+                &[
+                    Value::Int(255),
+                    Value::Int(255),
+                    Value::Int(8),
+                    Value::Int(5)
+                ]
+            ),
+            "ff FF 10 101"
+        );
+        assert_eq!(
+            sprintf(
+                "%#x %#X %#o %#b",
+                &[
+                    Value::Int(255),
+                    Value::Int(255),
+                    Value::Int(8),
+                    Value::Int(5)
+                ]
+            ),
+            "0xff 0XFF 010 0b101"
+        );
+        assert_eq!(sprintf("%x", &[Value::Int(-255)]), "-ff");
+        assert_eq!(sprintf("%08x", &[Value::Int(48879)]), "0000beef");
+        assert_eq!(sprintf("%#08x", &[Value::Int(255)]), "0x000000ff");
+        assert_eq!(
+            sprintf("%#.3o %#o", &[Value::Int(8), Value::Int(0)]),
+            "010 0"
+        );
+        assert_eq!(sprintf("%#X", &[Value::Int(16)]), "0X10");
+        assert_eq!(sprintf("%x", &[Value::Int(i64::MIN)]), "-8000000000000000");
+        assert_eq!(sprintf("%x", &[s("Hi!")]), "486921");
+        assert_eq!(sprintf("%#X", &[Value::Bytes(vec![0xde, 0xad])]), "0XDEAD");
+        assert_eq!(sprintf("%c%c", &[Value::Int(76), Value::Int(0x263A)]), "L☺");
+    }
+
+    #[test]
+    fn exponent_form() {
+        assert_eq!(sprintf("%e", &[Value::Float(1234.5678)]), "1.234568e+03");
+        assert_eq!(sprintf("%.2E", &[Value::Float(0.000123)]), "1.23E-04");
+        assert_eq!(sprintf("%10.1e", &[Value::Float(-5.0)]), "  -5.0e+00");
+    }
+
+    #[test]
+    fn float_verbs_accept_ints() {
+        // Go rejects an int for %f; Lumen converts it, like `format_fixed`.
+        assert_eq!(sprintf("%06.2f", &[Value::Int(5)]), "005.00");
+    }
+
+    #[test]
+    fn malformed_directives_are_reported_inline() {
+        assert_eq!(sprintf("%d", &[s("hi")]), "%!d(String=hi)");
+        assert_eq!(sprintf("%d %d", &[Value::Int(1)]), "1 %!d(MISSING)");
+        assert_eq!(
+            sprintf("%d", &[Value::Int(1), s("x")]),
+            "1%!(EXTRA String=x)"
+        );
+        assert_eq!(sprintf("100%", &[]), "100%!(NOVERB)");
+        assert_eq!(sprintf("100%%", &[]), "100%");
+        assert_eq!(sprintf("%z", &[Value::Int(1)]), "%!z(Int=1)");
+    }
+}
@@ -111,7 +111,12 @@ impl VM {
                     match compile_raw(&wrapped_src) {
                         Ok(new_module) => {
                             if let Some(current_mod) = self.module.as_mut() {
-                                current_mod.merge(&new_module);
+                                if let Err(e) = current_mod.merge(&new_module) {
+                                    return Some(Err(VmError::Runtime(format!(
+                                        "eval linking failed: {}",
+                                        e
+                                    ))));
+                                }
                                 let input_val = self.registers[base + a + 2].clone();
                                 Some(self.call_cell_sync(&cell_name, vec![input_val]))
                             } else {
@@ -352,7 +357,7 @@ impl VM {
     }
 
     fn machine_state_value(owner: &str, state: &MachineRuntime) -> Value {
-        let mut fields = BTreeMap::new();
+        let mut fields = FieldMap::new();
         fields.insert(
             "name".to_string(),
             Value::String(StringRef::Owned(state.current_state.clone())),
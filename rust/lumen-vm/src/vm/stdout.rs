@@ -0,0 +1,159 @@
+//! Buffered program output.
+//!
+//! `print` and `emit` append to an in-memory buffer instead of issuing a
+//! write syscall per line. The buffer is flushed when it fills, when the
+//! program calls `flush()`, before blocking on stdin or exiting, and when the
+//! VM is dropped. When stdout is an interactive terminal every line is flushed
+//! immediately so prompts and progress output still appear in real time.
+
+use std::io::{self, IsTerminal, Write};
+
+/// Default buffer size before an automatic flush.
+pub const DEFAULT_STDOUT_CAPACITY: usize = 8 * 1024;
+
+/// Buffered writer for program stdout.
+pub struct StdoutBuffer {
+    buf: Vec<u8>,
+    capacity: usize,
+    line_buffered: bool,
+    sink: Box<dyn Write + Send>,
+}
+
+impl StdoutBuffer {
+    /// Buffer writing to the process stdout. Line-buffered on a terminal.
+    pub fn new() -> Self {
+        let line_buffered = io::stdout().is_terminal();
+        Self {
+            buf: Vec::with_capacity(DEFAULT_STDOUT_CAPACITY),
+            capacity: DEFAULT_STDOUT_CAPACITY,
+            line_buffered,
+            sink: Box::new(io::stdout()),
+        }
+    }
+
+    /// Buffer writing to an arbitrary sink, flushing every `capacity` bytes.
+    pub fn with_sink(sink: Box<dyn Write + Send>, capacity: usize) -> Self {
+        Self {
+            buf: Vec::with_capacity(capacity),
+            capacity,
+            line_buffered: false,
+            sink,
+        }
+    }
+
+    /// Append `line` followed by a newline.
+    pub fn write_line(&mut self, line: &str) {
+        self.buf.extend_from_slice(line.as_bytes());
+        self.buf.push(b'\n');
+        self.flush_if_due();
+    }
+
+    /// Append `text` as-is, without a trailing newline.
+    pub fn write_str(&mut self, text: &str) {
+        self.buf.extend_from_slice(text.as_bytes());
+        self.flush_if_due();
+    }
+
+    fn flush_if_due(&mut self) {
+        if self.line_buffered || self.buf.len() >= self.capacity {
+            let _ = self.flush();
+        }
+    }
+
+    /// Number of bytes waiting to be written.
+    #[cfg(test)]
+    pub fn pending(&self) -> usize {
+        self.buf.len()
+    }
+
+    /// Write all buffered bytes to the sink.
+    pub fn flush(&mut self) -> io::Result<()> {
+        if !self.buf.is_empty() {
+            let result = self.sink.write_all(&self.buf);
+            self.buf.clear();
+            result?;
+        }
+        self.sink.flush()
+    }
+}
+
+impl Default for StdoutBuffer {
+    fn default() -> Self {
+        Self::new()
+    }
+}
+
+impl Drop for StdoutBuffer {
+    fn drop(&mut self) {
+        let _ = self.flush();
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::sync::{Arc, Mutex};
+
+    #[derive(Clone, Default)]
+    struct SharedSink(Arc<Mutex<Vec<u8>>>);
+
+    impl Write for SharedSink {
+        fn write(&mut self, data: &[u8]) -> io::Result<usize> {
+            self.0.lock().unwrap().extend_from_slice(data);
+            Ok(data.len())
+        }
+        fn flush(&mut self) -> io::Result<()> {
+            Ok(())
+        }
+    }
+
+    impl SharedSink {
+        fn contents(&self) -> String {
+            String::from_utf8(self.0.lock().unwrap().clone()).unwrap()
+        }
+    }
+
+    #[test]
+    fn holds_output_until_flush() {
+        let sink = SharedSink::default();
+        let mut out = StdoutBuffer::with_sink(Box::new(sink.clone()), 1024);
+        out.write_line("a");
+        out.write_line("b");
+        assert_eq!(sink.contents(), "");
+        assert_eq!(out.pending(), 4);
+        out.flush().unwrap();
+        assert_eq!(sink.contents(), "a\nb\n");
+        assert_eq!(out.pending(), 0);
+    }
+
+    #[test]
+    fn flushes_when_capacity_is_reached() {
+        let sink = SharedSink::default();
+        let mut out = StdoutBuffer::with_sink(Box::new(sink.clone()), 8);
+        out.write_line("abc");
+        assert_eq!(sink.contents(), "");
+        out.write_line("defg");
+        assert_eq!(sink.contents(), "abc\ndefg\n");
+    }
+
+    #[test]
+    fn write_str_adds_no_newline() {
+        let sink = SharedSink::default();
+        let mut out = StdoutBuffer::with_sink(Box::new(sink.clone()), 1024);
+        out.write_str("a=");
+        out.write_str("1\n");
+        out.write_line("b");
+        out.flush().unwrap();
+        assert_eq!(sink.contents(), "a=1\nb\n");
+    }
+
+    #[test]
+    fn drop_flushes_remaining_output() {
+        let sink = SharedSink::default();
+        {
+            let mut out = StdoutBuffer::with_sink(Box::new(sink.clone()), 1024);
+            out.write_line("tail");
+        }
+        assert_eq!(sink.contents(), "tail\n");
+    }
+}
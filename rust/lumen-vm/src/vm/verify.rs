@@ -0,0 +1,525 @@
+//! Load-time bytecode verification.
+//!
+//! The interpreter trusts the modules it runs: in release builds it indexes
+//! registers, constants, and cells straight from instruction operands. A
+//! module read back from a cache or handed over by another process may be
+//! truncated or tampered with, so [`verify_module`] checks it once, before
+//! anything runs, and [`VM::load_verified`](super::VM::load_verified) refuses
+//! modules that fail. For every instruction of every cell it checks that
+//!
+//! - each register operand lies inside the cell's register frame, including
+//!   the argument window of a call and the element window of a constructor.
+//!   This is the register machine's stack-depth check: a call whose
+//!   arguments run past the frame would read into the next frame;
+//! - each jump, loop, and handler target is an instruction of the same cell,
+//!   or the end of the cell (an implicit return);
+//! - each constant and cell operand refers to one that exists, and every
+//!   entry of a jump table is a `Jmp`.
+//!
+//! Parameter registers are checked against the frame as well. String
+//! operands are not: the interpreter reads a missing string as empty.
+
+use lumen_compiler::compiler::lir::{Instruction, LirCell, LirModule, OpCode};
+use thiserror::Error;
+
+/// Why a module was rejected. Each error names the cell and, where there is
+/// one, the index of the offending instruction.
+#[derive(Debug, Clone, PartialEq, Eq, Error)]
+pub enum VerifyError {
+    #[error("cell '{cell}': {op:?} at instruction {ip} uses r{reg}, but the cell has {registers} registers")]
+    RegisterOutOfRange {
+        cell: String,
+        ip: usize,
+        op: OpCode,
+        reg: usize,
+        registers: u16,
+    },
+    #[error(
+        "cell '{cell}': parameter '{param}' is in r{reg}, but the cell has {registers} registers"
+    )]
+    ParamOutOfRange {
+        cell: String,
+        param: String,
+        reg: u8,
+        registers: u16,
+    },
+    #[error("cell '{cell}': {op:?} at instruction {ip} jumps to instruction {target}, but the cell has {len} instructions")]
+    JumpOutOfRange {
+        cell: String,
+        ip: usize,
+        op: OpCode,
+        target: i64,
+        len: usize,
+    },
+    #[error("cell '{cell}': {op:?} at instruction {ip} refers to {what} {index}, but there are only {count}")]
+    IndexOutOfRange {
+        cell: String,
+        ip: usize,
+        op: OpCode,
+        what: &'static str,
+        index: usize,
+        count: usize,
+    },
+    #[error(
+        "cell '{cell}': entry {entry} of the jump table at instruction {ip} is {op:?}, not Jmp"
+    )]
+    BadJumpTableEntry {
+        cell: String,
+        ip: usize,
+        entry: usize,
+        op: OpCode,
+    },
+}
+
+/// Check every cell of `module`, stopping at the first problem.
+pub fn verify_module(module: &LirModule) -> Result<(), VerifyError> {
+    for cell in &module.cells {
+        verify_cell(cell, module.cells.len())?;
+    }
+    Ok(())
+}
+
+fn verify_cell(cell: &LirCell, cell_count: usize) -> Result<(), VerifyError> {
+    for param in &cell.params {
+        if param.register as usize >= cell.registers as usize {
+            return Err(VerifyError::ParamOutOfRange {
+                cell: cell.name.clone(),
+                param: param.name.clone(),
+                reg: param.register,
+                registers: cell.registers,
+            });
+        }
+    }
+
+    let len = cell.instructions.len();
+    for (ip, &instr) in cell.instructions.iter().enumerate() {
+        for_each_register_span(instr, |start, count| {
+            if count == 0 {
+                return Ok(());
+            }
+            let last = start.saturating_add(count - 1);
+            if last < cell.registers as usize {
+                Ok(())
+            } else {
+                Err(VerifyError::RegisterOutOfRange {
+                    cell: cell.name.clone(),
+                    ip,
+                    op: instr.op,
+                    reg: last,
+                    registers: cell.registers,
+                })
+            }
+        })?;
+
+        // Targets are relative to the next instruction, except a handler's
+        // which is relative to the HandlePush itself
+        let next = ip as i64 + 1;
+        let target = match instr.op {
+            OpCode::Jmp | OpCode::Break | OpCode::Continue => Some(next + instr.sax_val() as i64),
+            OpCode::Loop => Some(next + instr.sbx() as i64),
+            OpCode::ForPrep => Some(next + instr.bx() as i64),
+            OpCode::ForLoop => Some(next - instr.bx() as i64),
+            OpCode::HandlePush => Some(ip as i64 + instr.bx() as i64),
+            // The fallthrough past the table's entries
+            OpCode::JmpTable => Some(next + instr.b as i64),
+            _ => None,
+        };
+        if let Some(target) = target {
+            if target < 0 || target > len as i64 {
+                return Err(VerifyError::JumpOutOfRange {
+                    cell: cell.name.clone(),
+                    ip,
+                    op: instr.op,
+                    target,
+                    len,
+                });
+            }
+        }
+
+        let index = match instr.op {
+            OpCode::LoadK => Some(("constant", cell.constants.len())),
+            OpCode::Closure | OpCode::Spawn => Some(("cell", cell_count)),
+            _ => None,
+        };
+        if let Some((what, count)) = index {
+            let index = instr.bx() as usize;
+            if index >= count {
+                return Err(VerifyError::IndexOutOfRange {
+                    cell: cell.name.clone(),
+                    ip,
+                    op: instr.op,
+                    what,
+                    index,
+                    count,
+                });
+            }
+        }
+
+        if instr.op == OpCode::JmpTable {
+            for entry in 0..instr.b as usize {
+                let op = cell.instructions[ip + 1 + entry].op;
+                if op != OpCode::Jmp {
+                    return Err(VerifyError::BadJumpTableEntry {
+                        cell: cell.name.clone(),
+                        ip,
+                        entry,
+                        op,
+                    });
+                }
+            }
+        }
+    }
+    Ok(())
+}
+
+/// Call `check(first, count)` for each run of registers `instr` reads or
+/// writes, stopping at the first error.
+pub(crate) fn for_each_register_span<E>(
+    instr: Instruction,
+    mut check: impl FnMut(usize, usize) -> Result<(), E>,
+) -> Result<(), E> {
+    let a = instr.a as usize;
+    let b = instr.b as usize;
+    let c = instr.c as usize;
+
+    match instr.op {
+        OpCode::Nop | OpCode::Jmp | OpCode::Break | OpCode::Continue => Ok(()),
+
+        OpCode::LoadK
+        | OpCode::LoadBool
+        | OpCode::LoadInt
+        | OpCode::NewRecord
+        | OpCode::Test
+        | OpCode::Return
+        | OpCode::Halt
+        | OpCode::Loop
+        | OpCode::Closure
+        | OpCode::Schema
+        | OpCode::Emit
+        | OpCode::TraceRef
+        | OpCode::Spawn
+        | OpCode::IsVariant => check(a, 1),
+
+        OpCode::LoadNil => check(a, b + 1),
+
+        OpCode::Move
+        | OpCode::MoveOwn
+        | OpCode::Neg
+        | OpCode::BitNot
+        | OpCode::Not
+        | OpCode::Append
+        | OpCode::Unbox => {
+            check(a, 1)?;
+            check(b, 1)
+        }
+
+        OpCode::NewList | OpCode::NewTuple | OpCode::NewSet => {
+            check(a, 1)?;
+            check(a + 1, b)
+        }
+
+        OpCode::NewMap => {
+            check(a, 1)?;
+            check(a + 1, b.saturating_mul(2))
+        }
+
+        OpCode::GetField
+        | OpCode::GetIndex
+        | OpCode::GetTuple
+        | OpCode::Add
+        | OpCode::Sub
+        | OpCode::Mul
+        | OpCode::Div
+        | OpCode::FloorDiv
+        | OpCode::Mod
+        | OpCode::Pow
+        | OpCode::Concat
+        | OpCode::BitOr
+        | OpCode::BitAnd
+        | OpCode::BitXor
+        | OpCode::Shl
+        | OpCode::Shr
+        | OpCode::Eq
+        | OpCode::Lt
+        | OpCode::Le
+        | OpCode::And
+        | OpCode::Or
+        | OpCode::In
+        | OpCode::Is
+        | OpCode::NullCo
+        | OpCode::SetIndex
+        | OpCode::NewUnion
+        | OpCode::Await => {
+            check(a, 1)?;
+            check(b, 1)?;
+            check(c, 1)
+        }
+
+        OpCode::SetField | OpCode::SetUpval => {
+            check(a, 1)?;
+            check(c, 1)
+        }
+
+        OpCode::ForPrep => check(a, 3),
+
+        OpCode::ForLoop => check(a, 4),
+
+        OpCode::ForIn => {
+            check(a, 2)?;
+            check(b, 1)?;
+            check(c, 1)
+        }
+
+        OpCode::Call | OpCode::TailCall => {
+            check(a, 1)?;
+            check(a + 1, b)
+        }
+
+        OpCode::Intrinsic => {
+            check(a, 1)?;
+            check(c, 1)
+        }
+
+        OpCode::JmpTable => {
+            check(a, 1)?;
+            check(c, 1)
+        }
+
+        OpCode::GetUpval => check(a, 1),
+
+        OpCode::ToolCall => check(a, 1),
+
+        OpCode::Perform => check(a, 1),
+        OpCode::HandlePush | OpCode::HandlePop => Ok(()),
+        OpCode::Resume => check(a, 1),
+    }
+}
+
+// ---------------------------------------------------------------------------
+// Tests
+// ---------------------------------------------------------------------------
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::vm::VM;
+    use lumen_compiler::compiler::lir::{Constant, LirParam};
+
+    fn cell(name: &str, registers: u16, instructions: Vec<Instruction>) -> LirCell {
+        LirCell {
+            name: name.into(),
+            params: vec![],
+            returns: Some("Int".into()),
+            registers,
+            constants: vec![Constant::Int(1)],
+            instructions,
+            effect_handler_metas: vec![],
+        }
+    }
+
+    fn module(cells: Vec<LirCell>) -> LirModule {
+        let mut module = LirModule::new("test".into());
+        module.cells = cells;
+        module
+    }
+
+    #[test]
+    fn compiled_modules_verify() {
+        let md = r#"# verify-test
+
+```lumen
+cell add(a: Int, b: Int) -> Int
+  return a + b
+end
+
+cell classify(x: Int) -> Int
+  match x
+    0 -> return 10
+    1 -> return 20
+    2 -> return 30
+    _ -> return 0
+  end
+end
+
+cell main() -> Int
+  var total = 0
+  for i in 0..4
+    total = add(total, i)
+  end
+  let f = spawn("add", 1, 2)
+  return classify(total) + await f
+end
+```
+"#;
+        let module = lumen_compiler::compile(md).expect("source should compile");
+        assert_eq!(verify_module(&module), Ok(()));
+    }
+
+    #[test]
+    fn rejects_a_jump_past_the_end() {
+        let m = module(vec![cell(
+            "main",
+            2,
+            vec![
+                Instruction::abx(OpCode::LoadK, 0, 0),
+                Instruction::sax(OpCode::Jmp, 40),
+                Instruction::abc(OpCode::Return, 0, 1, 0),
+            ],
+        )]);
+        let err = verify_module(&m).unwrap_err();
+        assert_eq!(
+            err,
+            VerifyError::JumpOutOfRange {
+                cell: "main".into(),
+                ip: 1,
+                op: OpCode::Jmp,
+                target: 42,
+                len: 3,
+            }
+        );
+        assert_eq!(
+            err.to_string(),
+            "cell 'main': Jmp at instruction 1 jumps to instruction 42, but the cell has 3 instructions"
+        );
+    }
+
+    #[test]
+    fn rejects_a_backward_jump_before_the_start() {
+        let m = module(vec![cell(
+            "main",
+            2,
+            vec![
+                Instruction::abx(OpCode::LoadK, 0, 0),
+                Instruction::sax(OpCode::Jmp, -5),
+            ],
+        )]);
+        assert!(matches!(
+            verify_module(&m),
+            Err(VerifyError::JumpOutOfRange { target: -3, .. })
+        ));
+    }
+
+    #[test]
+    fn jump_to_the_end_is_an_implicit_return() {
+        let m = module(vec![cell(
+            "main",
+            2,
+            vec![
+                Instruction::sax(OpCode::Jmp, 1),
+                Instruction::abc(OpCode::Return, 0, 1, 0),
+            ],
+        )]);
+        assert_eq!(verify_module(&m), Ok(()));
+    }
+
+    #[test]
+    fn rejects_a_call_whose_arguments_run_past_the_frame() {
+        // Callee in r1, three arguments in r2..r4 of a 4-register frame
+        let m = module(vec![cell(
+            "main",
+            4,
+            vec![
+                Instruction::abc(OpCode::Call, 1, 3, 1),
+                Instruction::abc(OpCode::Return, 1, 1, 0),
+            ],
+        )]);
+        let err = verify_module(&m).unwrap_err();
+        assert_eq!(
+            err.to_string(),
+            "cell 'main': Call at instruction 0 uses r4, but the cell has 4 registers"
+        );
+    }
+
+    #[test]
+    fn rejects_a_missing_constant_or_cell() {
+        let m = module(vec![cell(
+            "main",
+            2,
+            vec![Instruction::abx(OpCode::LoadK, 0, 3)],
+        )]);
+        assert_eq!(
+            verify_module(&m).unwrap_err().to_string(),
+            "cell 'main': LoadK at instruction 0 refers to constant 3, but there are only 1"
+        );
+
+        let m = module(vec![cell(
+            "main",
+            2,
+            vec![Instruction::abx(OpCode::Spawn, 0, 9)],
+        )]);
+        assert!(matches!(
+            verify_module(&m),
+            Err(VerifyError::IndexOutOfRange {
+                what: "cell",
+                index: 9,
+                count: 1,
+                ..
+            })
+        ));
+    }
+
+    #[test]
+    fn rejects_a_jump_table_entry_that_is_not_a_jump() {
+        let m = module(vec![cell(
+            "main",
+            2,
+            vec![
+                Instruction::abc(OpCode::JmpTable, 0, 2, 1),
+                Instruction::sax(OpCode::Jmp, 1),
+                Instruction::abc(OpCode::Return, 0, 1, 0),
+                Instruction::abc(OpCode::Return, 1, 1, 0),
+            ],
+        )]);
+        assert_eq!(
+            verify_module(&m),
+            Err(VerifyError::BadJumpTableEntry {
+                cell: "main".into(),
+                ip: 0,
+                entry: 1,
+                op: OpCode::Return,
+            })
+        );
+    }
+
+    #[test]
+    fn rejects_a_parameter_outside_the_frame() {
+        let mut c = cell("add", 2, vec![Instruction::abc(OpCode::Return, 0, 1, 0)]);
+        c.params.push(LirParam {
+            name: "x".into(),
+            ty: "Int".into(),
+            register: 5,
+            variadic: false,
+        });
+        assert_eq!(
+            verify_module(&module(vec![c])).unwrap_err().to_string(),
+            "cell 'add': parameter 'x' is in r5, but the cell has 2 registers"
+        );
+    }
+
+    #[test]
+    fn load_verified_refuses_a_corrupt_module() {
+        let m = module(vec![cell(
+            "main",
+            1,
+            vec![
+                Instruction::abx(OpCode::LoadK, 0, 0),
+                Instruction::sax(OpCode::Jmp, 7),
+            ],
+        )]);
+        let mut vm = VM::new();
+        assert!(vm.load_verified(m).is_err());
+        assert!(vm.execute("main", vec![]).is_err());
+
+        let m = module(vec![cell(
+            "main",
+            1,
+            vec![
+                Instruction::abx(OpCode::LoadK, 0, 0),
+                Instruction::abc(OpCode::Return, 0, 1, 0),
+            ],
+        )]);
+        vm.load_verified(m)
+            .expect("a well-formed module should load");
+        assert_eq!(vm.execute("main", vec![]).unwrap().as_int(), Some(1));
+    }
+}
@@ -0,0 +1,110 @@
+//! With `enable_alloc_profile`, every list, map, record, tuple, and set the
+//! VM builds is counted against the source line that built it.
+
+use lumen_compiler::compile;
+use lumen_vm::vm::{AllocProfile, VM};
+
+fn markdown(source: &str) -> String {
+    format!("# alloc-profile-test\n\n```lumen\n{}\n```\n", source.trim())
+}
+
+/// 1-based line of the first line of `md` containing `needle`.
+fn line_of(md: &str, needle: &str) -> usize {
+    md.lines()
+        .position(|l| l.contains(needle))
+        .unwrap_or_else(|| panic!("no line contains {:?}", needle))
+        + 1
+}
+
+fn profile(md: &str) -> AllocProfile {
+    let mut vm = VM::new();
+    vm.enable_alloc_profile();
+    vm.load(compile(md).expect("source should compile"));
+    vm.execute("main", vec![]).expect("main should execute");
+    vm.alloc_profile()
+}
+
+#[test]
+fn alloc_profile_attributes_allocations_to_source_lines() {
+    let md = markdown(
+        r#"
+record Point
+  x: Int
+  y: Int
+end
+
+cell make_point(i: Int) -> Point
+  let p = Point(x: i, y: i * 2)
+  return p
+end
+
+cell main() -> Int
+  var total = 0
+  for i in 0..5
+    let pt = make_point(i)
+    let pair = [pt.x, pt.y, i]
+    total = total + len(pair)
+  end
+  let tags = {"a": 1, "b": 2}
+  return total + len(tags)
+end
+"#,
+    );
+    let profile = profile(&md);
+
+    let record = profile
+        .site("<main>", line_of(&md, "let p = Point("))
+        .expect("the record literal should be a site");
+    assert_eq!(record.function, "make_point");
+    assert_eq!(record.count, 5);
+
+    let list = profile
+        .site("<main>", line_of(&md, "let pair = ["))
+        .expect("the list literal should be a site");
+    assert_eq!(list.function, "main");
+    assert_eq!(list.count, 5);
+
+    let map = profile
+        .site("<main>", line_of(&md, "let tags = {"))
+        .expect("the map literal should be a site");
+    assert_eq!(map.count, 1);
+
+    // Nothing is allocated on the line in between
+    assert!(profile
+        .site("<main>", line_of(&md, "total = total +"))
+        .is_none());
+}
+
+#[test]
+fn alloc_profile_counts_reserved_list_capacity() {
+    let md = markdown(
+        r#"
+cell main() -> Int
+  let buf = list_with_capacity(64)
+  return len(buf)
+end
+"#,
+    );
+    let profile = profile(&md);
+    let site = profile
+        .site("<main>", line_of(&md, "list_with_capacity(64)"))
+        .expect("list_with_capacity should be a site");
+    assert_eq!(site.count, 1);
+    assert!(site.bytes >= 64, "reserved bytes: {}", site.bytes);
+}
+
+#[test]
+fn alloc_profile_is_empty_when_disabled() {
+    let md = markdown(
+        r#"
+cell main() -> Int
+  let xs = [1, 2, 3]
+  return len(xs)
+end
+"#,
+    );
+    let mut vm = VM::new();
+    vm.load(compile(&md).expect("source should compile"));
+    vm.execute("main", vec![]).expect("main should execute");
+    assert!(vm.alloc_profile().sites.is_empty());
+}
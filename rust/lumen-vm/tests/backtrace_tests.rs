@@ -0,0 +1,149 @@
+//! `backtrace()` returns the call stack at the point it is called, innermost
+//! frame first, as `{function, file, line}` maps, without unwinding.
+
+use lumen_compiler::{compile, compile_with_imports};
+use lumen_vm::values::Value;
+use lumen_vm::vm::VM;
+
+fn markdown(source: &str) -> String {
+    format!("# backtrace-test\n\n```lumen\n{}\n```\n", source.trim())
+}
+
+/// 1-based line of the first line of `md` containing `needle`.
+fn line_of(md: &str, needle: &str) -> i64 {
+    md.lines()
+        .position(|l| l.contains(needle))
+        .unwrap_or_else(|| panic!("no line contains {:?}", needle)) as i64
+        + 1
+}
+
+/// `(function, file, line)` of each frame in a `backtrace()` result.
+fn frames(value: &Value) -> Vec<(String, String, i64)> {
+    value
+        .as_list()
+        .expect("backtrace should be a list")
+        .iter()
+        .map(|frame| {
+            let frame = frame.as_map().expect("frame should be a map");
+            (
+                frame["function"].as_string(),
+                frame["file"].as_string(),
+                frame["line"].as_int().expect("line should be an Int"),
+            )
+        })
+        .collect()
+}
+
+#[test]
+fn backtrace_lists_the_call_chain() {
+    let md = markdown(
+        r#"
+cell inner() -> list[map[String, Any]]
+  let here = backtrace()
+  return here
+end
+
+cell outer() -> list[map[String, Any]]
+  let from_inner = inner()
+  return from_inner
+end
+
+cell main() -> list[map[String, Any]]
+  let from_outer = outer()
+  return from_outer
+end
+"#,
+    );
+    let mut vm = VM::new();
+    vm.load(compile(&md).expect("source should compile"));
+    let result = vm.execute("main", vec![]).expect("main should execute");
+    let main = "<main>".to_string();
+    assert_eq!(
+        frames(&result),
+        vec![
+            ("inner".to_string(), main.clone(), line_of(&md, "let here")),
+            ("outer".to_string(), main.clone(), line_of(&md, "let from_inner")),
+            ("main".to_string(), main, line_of(&md, "let from_outer")),
+        ]
+    );
+}
+
+#[test]
+fn backtrace_in_recursion_reports_each_frame() {
+    let md = markdown(
+        r#"
+cell countdown(n: Int) -> list[map[String, Any]]
+  if n == 0
+    let here = backtrace()
+    return here
+  end
+  let deeper = countdown(n - 1)
+  return deeper
+end
+
+cell main() -> list[map[String, Any]]
+  let captured = countdown(2)
+  print("still running")
+  return captured
+end
+"#,
+    );
+    let mut vm = VM::new();
+    vm.set_source_name("app.lm.md");
+    vm.load(compile(&md).expect("source should compile"));
+    let result = vm.execute("main", vec![]).expect("main should execute");
+    assert_eq!(vm.output, vec!["still running"]);
+
+    let file = "app.lm.md".to_string();
+    let recurse = line_of(&md, "let deeper");
+    assert_eq!(
+        frames(&result),
+        vec![
+            ("countdown".to_string(), file.clone(), line_of(&md, "let here")),
+            ("countdown".to_string(), file.clone(), recurse),
+            ("countdown".to_string(), file.clone(), recurse),
+            ("main".to_string(), file, line_of(&md, "let captured")),
+        ]
+    );
+}
+
+#[test]
+fn backtrace_names_the_module_of_imported_cells() {
+    let lib = markdown(
+        r#"
+cell capture() -> list[map[String, Any]]
+  let here = backtrace()
+  return here
+end
+"#,
+    );
+    let md = markdown(
+        r#"
+import tools.trace: capture
+
+cell main() -> list[map[String, Any]]
+  let from_lib = capture()
+  return from_lib
+end
+"#,
+    );
+    let resolve = |path: &str| (path == "tools.trace").then(|| lib.clone());
+    let mut vm = VM::new();
+    vm.load(compile_with_imports(&md, &resolve).expect("source should compile"));
+    let result = vm.execute("main", vec![]).expect("main should execute");
+    assert_eq!(
+        frames(&result),
+        vec![
+            (
+                "capture".to_string(),
+                "tools.trace".to_string(),
+                line_of(&lib, "let here")
+            ),
+            (
+                "main".to_string(),
+                "<main>".to_string(),
+                line_of(&md, "let from_lib")
+            ),
+        ]
+    );
+}
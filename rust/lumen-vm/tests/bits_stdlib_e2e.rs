@@ -0,0 +1,121 @@
+mod common;
+
+use common::StdModule;
+use lumen_vm::values::Value;
+
+const STD_BITS: StdModule = StdModule {
+    import: "std.bits",
+    path: "stdlib/std/bits.lm.md",
+};
+
+fn ints(value: Value) -> Vec<i64> {
+    let Value::List(items) = value else {
+        panic!("expected a list, got {:?}", value);
+    };
+    items
+        .iter()
+        .map(|v| match v {
+            Value::Int(n) => *n,
+            other => panic!("expected an Int, got {:?}", other),
+        })
+        .collect()
+}
+
+/// Bit `i` of `x`, counting from the least significant.
+fn bit(x: i64, i: u32) -> bool {
+    (x as u64 >> i) & 1 == 1
+}
+
+/// Popcount, leading zeros, and trailing zeros one bit at a time.
+fn reference_counts(x: i64) -> Vec<i64> {
+    let ones = (0..64).filter(|&i| bit(x, i)).count() as i64;
+    let leading = (0..64).rev().take_while(|&i| !bit(x, i)).count() as i64;
+    let trailing = (0..64).take_while(|&i| !bit(x, i)).count() as i64;
+    vec![ones, leading, trailing]
+}
+
+const EDGE_VALUES: [i64; 11] = [
+    0,
+    -1,
+    1,
+    -2,
+    i64::MIN,
+    i64::MAX,
+    0x5555_5555_5555_5555,
+    0x8000_0000,
+    0x0100_0000_0000_0000,
+    12_345_678_910,
+    -12_345_678_910,
+];
+
+#[test]
+fn e2e_bits_counts_match_a_bit_by_bit_reference() {
+    let mut vm = STD_BITS.load(
+        r#"
+import std.bits: popcount, leading_zeros, trailing_zeros
+
+cell main(x: Int) -> list[Int]
+  return [popcount(x), leading_zeros(x), trailing_zeros(x)]
+end
+"#,
+    );
+    for x in EDGE_VALUES {
+        let got = ints(
+            vm.execute("main", vec![Value::Int(x)])
+                .expect("main should execute"),
+        );
+        assert_eq!(got, reference_counts(x), "counts of {:#x}", x);
+    }
+}
+
+#[test]
+fn e2e_bits_rotate_and_swap_bytes() {
+    let mut vm = STD_BITS.load(
+        r#"
+import std.bits: rotate_left, rotate_right, swap_bytes, bit_length
+
+cell main() -> list[Int]
+  let x = 0x0102030405060708
+  let rotated = [rotate_left(1, 65), rotate_right(1, 1), rotate_left(x, -8), rotate_right(rotate_left(x, 13), 13)]
+  let swapped = [swap_bytes(x), swap_bytes(swap_bytes(x)), swap_bytes(-1)]
+  let lengths = [bit_length(0), bit_length(255), bit_length(-1)]
+  return rotated + swapped + lengths
+end
+"#,
+    );
+    let x: i64 = 0x0102_0304_0506_0708;
+    assert_eq!(
+        ints(vm.execute("main", vec![]).expect("main should execute")),
+        vec![
+            2,
+            i64::MIN,
+            0x0801_0203_0405_0607,
+            x,
+            0x0807_0605_0403_0201,
+            x,
+            -1,
+            0,
+            8,
+            64,
+        ]
+    );
+}
+
+#[test]
+fn e2e_bits_rejects_non_int_arguments() {
+    let mut vm = STD_BITS.load(
+        r#"
+cell main() -> Int
+  return bits_popcount(1.5)
+end
+"#,
+    );
+    let err = vm
+        .execute("main", vec![])
+        .expect_err("a Float has no bit pattern to count");
+    assert!(
+        err.message_contains("bits_popcount expects an Int"),
+        "{}",
+        err
+    );
+}
@@ -0,0 +1,102 @@
+mod common;
+
+use common::{owned, result_payload, scratch_dir, StdModule};
+use std::fs;
+
+const STD_BUFIO: StdModule = StdModule {
+    import: "std.bufio",
+    path: "stdlib/std/bufio.lm.md",
+};
+
+/// Cells joining every line of a reader as `[line]`, so empty lines and
+/// stray terminators show in the output.
+const COLLECT: &str = r#"
+import std.bufio: LineReader, from_string, from_file, read_line
+
+cell collect(start: LineReader) -> result[String, String]
+  var r = start
+  var out = ""
+  loop
+    let (next, line) = read_line(r)?
+    if line == null
+      return ok(out)
+    end
+    out = out + "[" + line + "]"
+    r = next
+  end
+end
+"#;
+
+#[test]
+fn e2e_bufio_reads_lines_including_an_unterminated_last_line() {
+    let source = format!(
+        r#"{}
+cell main() -> result[String, String]
+  return collect(from_string("alpha\nbeta\r\n\ngamma"))
+end
+"#,
+        COLLECT
+    );
+    assert_eq!(
+        result_payload(STD_BUFIO.run_main(&source)),
+        owned("[alpha][beta][][gamma]")
+    );
+}
+
+#[test]
+fn e2e_bufio_trailing_newline_adds_no_empty_line() {
+    let source = format!(
+        r#"{}
+cell main() -> result[String, String]
+  return ok(collect(from_string("one\ntwo\n"))? + "|" + collect(from_string(""))?)
+end
+"#,
+        COLLECT
+    );
+    assert_eq!(
+        result_payload(STD_BUFIO.run_main(&source)),
+        owned("[one][two]|")
+    );
+}
+
+#[test]
+fn e2e_bufio_file_lines_longer_than_the_chunk_grow_the_buffer() {
+    let dir = scratch_dir("bufio", "long");
+    let path = dir.join("lines.txt");
+    let long = "x".repeat(50);
+    fs::write(&path, format!("short\n{}\r\n\nmid é line\nlast", long)).unwrap();
+    let source = format!(
+        r#"{}
+cell main() -> result[String, String]
+  return collect(from_file("{}", 4)?)
+end
+"#,
+        COLLECT,
+        path.display()
+    );
+    assert_eq!(
+        result_payload(STD_BUFIO.run_main(&source)),
+        owned(&format!("[short][{}][][mid é line][last]", long))
+    );
+    let _ = fs::remove_dir_all(&dir);
+}
+
+#[test]
+fn e2e_bufio_reports_a_missing_file() {
+    let dir = scratch_dir("bufio", "missing");
+    let source = format!(
+        r#"
+import std.bufio: from_file
+
+cell main() -> String
+  match from_file("{}")
+    ok(_) -> return "opened"
+    err(e) -> return "error"
+  end
+end
+"#,
+        dir.join("absent.txt").display()
+    );
+    assert_eq!(STD_BUFIO.run_main(&source), owned("error"));
+    let _ = fs::remove_dir_all(&dir);
+}
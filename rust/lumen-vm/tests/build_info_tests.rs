@@ -0,0 +1,61 @@
+//! `build_info()` reports the version, commit, and target embedded when the
+//! VM was built, the same ones `lumen --version` prints.
+
+use lumen_compiler::compile;
+use lumen_vm::build_info;
+use lumen_vm::values::{StringRef, Value};
+use lumen_vm::vm::VM;
+
+fn run(source: &str) -> Value {
+    let md = format!("# build-info-test\n\n```lumen\n{}\n```\n", source.trim());
+    let module = compile(&md).expect("source should compile");
+    let mut vm = VM::new();
+    vm.load(module);
+    vm.execute("main", vec![]).expect("main should execute")
+}
+
+fn owned(s: &str) -> Value {
+    Value::String(StringRef::Owned(s.to_string()))
+}
+
+#[test]
+fn build_info_returns_the_embedded_version() {
+    let info = run(r#"
+cell main() -> list[String]
+  let build = build_info()
+  return [build["version"], build["commit"], build["target"]]
+end
+"#);
+    assert_eq!(
+        info,
+        Value::new_list(vec![
+            owned(env!("CARGO_PKG_VERSION")),
+            owned(build_info::COMMIT),
+            owned(build_info::TARGET),
+        ])
+    );
+}
+
+#[test]
+fn build_info_has_exactly_the_documented_keys() {
+    let keys = run(r#"
+cell main() -> list[String]
+  return keys(build_info())
+end
+"#);
+    assert_eq!(
+        keys,
+        Value::new_list(vec![owned("commit"), owned("target"), owned("version")])
+    );
+}
+
+#[test]
+fn long_version_is_what_lumen_version_prints() {
+    assert!(
+        build_info::LONG_VERSION.starts_with(env!("CARGO_PKG_VERSION")),
+        "{}",
+        build_info::LONG_VERSION
+    );
+    assert!(!build_info::COMMIT.is_empty());
+    assert!(build_info::TARGET.contains('-'), "{}", build_info::TARGET);
+}
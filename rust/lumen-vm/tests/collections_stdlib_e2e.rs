@@ -0,0 +1,354 @@
+mod common;
+
+use common::{as_string, StdModule};
+
+const STD_COLLECTIONS: StdModule = StdModule {
+    import: "std.collections",
+    path: "stdlib/std/collections.lm.md",
+};
+
+#[test]
+fn e2e_deque_push_and_pop_at_both_ends() {
+    let source = r#"
+import std.collections: new_deque, push_front, push_back, pop_front, pop_back, peek_front, peek_back, deque_len, deque_to_list
+
+cell show(xs: list[Int]) -> String
+  return join(map(xs, fn(x: Int) => string(x)), ",")
+end
+
+cell main() -> String
+  var d = new_deque()
+  d = push_back(d, 2)
+  d = push_back(d, 3)
+  d = push_front(d, 1)
+  d = push_front(d, 0)
+  let built = show(deque_to_list(d))
+  let ends = "{peek_front(d)}/{peek_back(d)}"
+
+  # Popping from the front drains the pushed-front side, then crosses over
+  d = pop_front(d)
+  d = pop_front(d)
+  d = pop_front(d)
+  let after_front = show(deque_to_list(d))
+
+  d = push_front(d, 9)
+  d = pop_back(d)
+  d = pop_back(d)
+  let drained = "{deque_len(d)}:{peek_front(d)}:{peek_back(d)}"
+
+  # Popping an empty deque leaves it empty
+  d = pop_front(d)
+  d = pop_back(d)
+  return "{built} {ends} {after_front} {drained} {deque_len(d)}"
+end
+"#;
+
+    assert_eq!(
+        as_string(&STD_COLLECTIONS.run_main(source)),
+        "0,1,2,3 0/3 3 0:null:null 0"
+    );
+}
+
+#[test]
+fn e2e_deque_alternating_pops_keep_order() {
+    let source = r#"
+import std.collections: new_deque, push_back, pop_front, pop_back, peek_front, peek_back
+
+cell main() -> String
+  var d = new_deque()
+  for i in range(1, 9)
+    d = push_back(d, i)
+  end
+  var seen = []
+  var from_front = true
+  while len(seen) < 8
+    if from_front
+      seen = append(seen, string(peek_front(d)))
+      d = pop_front(d)
+    else
+      seen = append(seen, string(peek_back(d)))
+      d = pop_back(d)
+    end
+    from_front = not from_front
+  end
+  return join(seen, ",")
+end
+"#;
+
+    assert_eq!(
+        as_string(&STD_COLLECTIONS.run_main(source)),
+        "1,8,2,7,3,6,4,5"
+    );
+}
+
+#[test]
+fn e2e_priority_queue_orders_by_comparator() {
+    let source = r#"
+import std.collections: new_priority_queue, pq_push, pq_pop, pq_peek, pq_len
+
+cell drain(less: fn(Int, Int) -> Bool, xs: list[Int]) -> String
+  var q = new_priority_queue(less)
+  for x in xs
+    q = pq_push(q, x)
+  end
+  var out = []
+  while pq_len(q) > 0
+    out = append(out, string(pq_peek(q)))
+    q = pq_pop(q)
+  end
+  return join(out, ",")
+end
+
+cell main() -> String
+  let xs = [5, 1, 8, 3, 9, 2, 7, 3, 6]
+  let ascending = drain(fn(a: Int, b: Int) => a < b, xs)
+  let descending = drain(fn(a: Int, b: Int) => a > b, xs)
+  let empty = new_priority_queue(fn(a: Int, b: Int) => a < b)
+  return "{ascending} {descending} {pq_peek(empty)} {pq_len(pq_pop(empty))}"
+end
+"#;
+
+    assert_eq!(
+        as_string(&STD_COLLECTIONS.run_main(source)),
+        "1,2,3,3,5,6,7,8,9 9,8,7,6,5,3,3,2,1 null 0"
+    );
+}
+
+#[test]
+fn e2e_priority_queue_ties_follow_sequence_numbers() {
+    // The heap is not stable; a sequence number in the comparator makes
+    // equal priorities come out in push order.
+    let source = r#"
+import std.collections: new_priority_queue, pq_push, pq_pop, pq_peek, pq_len
+
+record Job
+  priority: Int
+  seq: Int
+  name: String
+end
+
+cell main() -> String
+  var q = new_priority_queue(fn(a: Job, b: Job) => a.priority < b.priority or (a.priority == b.priority and a.seq < b.seq))
+  let jobs = [("b", 2), ("a1", 1), ("c", 3), ("a2", 1), ("b2", 2), ("a3", 1)]
+  var seq = 0
+  for (name, priority) in jobs
+    q = pq_push(q, Job(priority: priority, seq: seq, name: name))
+    seq += 1
+  end
+  var out = []
+  while pq_len(q) > 0
+    out = append(out, pq_peek(q).name)
+    q = pq_pop(q)
+  end
+  return join(out, ",")
+end
+"#;
+
+    assert_eq!(
+        as_string(&STD_COLLECTIONS.run_main(source)),
+        "a1,a2,a3,b,b2,c"
+    );
+}
+
+#[test]
+fn e2e_priority_queue_dijkstra_shortest_paths() {
+    let source = r#"
+import std.collections: new_priority_queue, pq_push, pq_pop, pq_peek, pq_len
+
+record Visit
+  node: Int
+  dist: Int
+end
+
+cell main() -> String
+  # edges[n] lists (to, weight) pairs out of node n
+  let edges = [
+    [(1, 4), (2, 1)],
+    [(3, 1)],
+    [(1, 2), (3, 5)],
+    [(4, 3)],
+    []
+  ]
+  let unreached = 1000000
+  var dist = [0, unreached, unreached, unreached, unreached]
+  var q = pq_push(new_priority_queue(fn(a: Visit, b: Visit) => a.dist < b.dist), Visit(node: 0, dist: 0))
+  while pq_len(q) > 0
+    let v = pq_peek(q)
+    q = pq_pop(q)
+    if v.dist > dist[v.node]
+      continue
+    end
+    for (to, weight) in edges[v.node]
+      let candidate = v.dist + weight
+      if candidate < dist[to]
+        dist[to] = candidate
+        q = pq_push(q, Visit(node: to, dist: candidate))
+      end
+    end
+  end
+  return join(map(dist, fn(d: Int) => string(d)), ",")
+end
+"#;
+
+    assert_eq!(as_string(&STD_COLLECTIONS.run_main(source)), "0,3,1,4,7");
+}
+
+#[test]
+fn e2e_set_operations_over_ints() {
+    let source = r#"
+import std.collections: new_set, set_of, set_add, set_remove, set_contains, set_len, set_to_list, set_union, set_intersection, set_difference
+
+cell show(xs: list[Int]) -> String
+  return join(map(xs, fn(x: Int) => string(x)), ",")
+end
+
+cell main() -> String
+  var s = new_set()
+  s = set_add(s, 10)
+  s = set_add(s, 2)
+  s = set_add(s, 10)
+  s = set_add(s, 33)
+  s = set_remove(s, 33)
+  s = set_remove(s, 99)
+  let basics = "{set_len(s)} {set_contains(s, 2)} {set_contains(s, 33)} {show(set_to_list(s))}"
+
+  let evens = set_of([0, 2, 4, 6, 8, 10])
+  let threes = set_of([0, 3, 6, 9])
+  let parts = [
+    basics,
+    show(set_to_list(set_union(evens, threes))),
+    show(set_to_list(set_intersection(evens, threes))),
+    show(set_to_list(set_difference(evens, threes))),
+    show(set_to_list(set_difference(threes, evens)))
+  ]
+  return join(parts, " | ")
+end
+"#;
+
+    assert_eq!(
+        as_string(&STD_COLLECTIONS.run_main(source)),
+        "2 true false 2,10 | 0,2,3,4,6,8,9,10 | 0,6 | 2,4,8,10 | 3,9"
+    );
+}
+
+#[test]
+fn e2e_set_operations_over_strings() {
+    let source = r#"
+import std.collections: set_of, set_contains, set_len, set_to_list, set_union, set_intersection, set_difference
+
+cell main() -> String
+  let a = set_of(["pear", "apple", "fig", "apple"])
+  let b = set_of(["fig", "kiwi", "pear"])
+  # Iteration is in ascending order, independent of insertion order
+  var seen = []
+  for fruit in set_to_list(set_union(a, b))
+    seen = append(seen, fruit)
+  end
+  let both = join(set_to_list(set_intersection(a, b)), ",")
+  let only_a = join(set_to_list(set_difference(a, b)), ",")
+  # A string and an int with the same text are different elements
+  let mixed = set_of(["1", 1])
+  let all = join(seen, ",")
+  let has_apple = set_contains(b, "apple")
+  return "{set_len(a)} {all} {both} {only_a} {has_apple} {set_len(mixed)}"
+end
+"#;
+
+    assert_eq!(
+        as_string(&STD_COLLECTIONS.run_main(source)),
+        "3 apple,fig,kiwi,pear fig,pear apple false 2"
+    );
+}
+
+#[test]
+fn e2e_vec_capacity_survives_pop_and_reserve() {
+    let source = r#"
+import std.collections: vec_with_capacity, vec_len, vec_capacity, vec_reserve, vec_push, vec_pop, vec_get
+
+cell main() -> String
+  var v = vec_with_capacity(4)
+  let initial = vec_capacity(v)
+  for i in range(0, 4)
+    v = vec_push(v, i * 10)
+  end
+  let filled = vec_capacity(v)
+
+  # Popping never gives capacity back
+  v = vec_pop(v)
+  v = vec_pop(v)
+  let popped = "{vec_len(v)}/{vec_capacity(v)}"
+
+  # After reserving, pushing the reserved count does not reallocate
+  v = vec_reserve(v, 100)
+  let reserved = vec_capacity(v)
+  for i in range(0, 100)
+    v = vec_push(v, i)
+  end
+  let unchanged = vec_capacity(v) == reserved
+  let enough = reserved >= 102
+  return "{initial} {filled} {popped} {enough} {unchanged} {vec_len(v)} {vec_get(v, 1)} {vec_get(v, 101)}"
+end
+"#;
+
+    assert_eq!(
+        as_string(&STD_COLLECTIONS.run_main(source)),
+        "4 4 2/4 true true 102 10 99"
+    );
+}
+
+#[test]
+fn e2e_linked_list_insert_and_remove_anywhere() {
+    let source = r#"
+import std.collections: new_linked_list, linked_push_front, linked_push_back, linked_insert, linked_remove, linked_get, linked_len, linked_to_list
+
+cell main() -> String
+  var l = new_linked_list()
+  l = linked_push_back(l, "b")
+  l = linked_push_back(l, "d")
+  l = linked_push_front(l, "a")
+  l = linked_insert(l, 2, "c")
+  l = linked_insert(l, 4, "e")
+  let sep = ","
+  let built = join(linked_to_list(l), sep)
+  let ends = linked_get(l, 0) + linked_get(l, 4)
+
+  l = linked_remove(l, 2)
+  l = linked_remove(l, 0)
+  l = linked_remove(l, 2)
+  let removed = join(linked_to_list(l), sep)
+
+  # Freed slots are reused for new nodes
+  l = linked_insert(l, 1, "x")
+  l = linked_insert(l, 0, "y")
+  let reused = join(linked_to_list(l), sep)
+  return "{built} {ends} {removed} {reused} {linked_len(l)} {linked_get(l, 2)}"
+end
+"#;
+
+    assert_eq!(
+        as_string(&STD_COLLECTIONS.run_main(source)),
+        "a,b,c,d,e ae b,d y,b,x,d 4 x"
+    );
+}
+
+#[test]
+fn e2e_linked_list_index_out_of_range_panics() {
+    let source = r#"
+import std.collections: new_linked_list, linked_push_back, linked_remove
+
+cell main() -> Int
+  var l = linked_push_back(new_linked_list(), 1)
+  l = linked_remove(l, 1)
+  return 0
+end
+"#;
+    let mut vm = STD_COLLECTIONS.load(source);
+    let err = vm
+        .execute("main", vec![])
+        .expect_err("removing past the end should panic");
+    assert!(
+        err.message_contains("linked_remove: index 1 out of range for length 1"),
+        "unexpected error: {}",
+        err
+    );
+}
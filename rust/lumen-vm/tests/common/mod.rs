@@ -0,0 +1,104 @@
+//! Helpers shared by the `*_stdlib_e2e.rs` tests, which run programs that
+//! import one standard-library module read from `stdlib/`.
+
+// Each test file is its own crate and uses only some of these.
+#![allow(dead_code)]
+
+use std::fs;
+use std::path::PathBuf;
+
+use lumen_compiler::compiler::lir::LirModule;
+use lumen_compiler::{compile_raw_with_imports, compile_with_imports};
+use lumen_vm::values::{StringRef, Value};
+use lumen_vm::vm::VM;
+
+/// A standard-library module: the name programs import and the file it is
+/// read from.
+pub struct StdModule {
+    /// The import name, such as `std.container.ringbuffer`.
+    pub import: &'static str,
+    /// The source file, relative to the repository root.
+    pub path: &'static str,
+}
+
+impl StdModule {
+    pub fn source(&self) -> String {
+        repo_file(self.path)
+    }
+
+    /// Compile raw Lumen `source`, which may import this module.
+    pub fn compile(&self, source: &str) -> LirModule {
+        let module_source = self.source();
+        compile_raw_with_imports(source, &|module| {
+            (module == self.import).then(|| module_source.clone())
+        })
+        .unwrap_or_else(|e| panic!("raw source should compile with {}: {:?}", self.import, e))
+    }
+
+    /// Compile markdown `source`, which may import this module.
+    pub fn compile_markdown(&self, source: &str) -> LirModule {
+        let module_source = self.source();
+        compile_with_imports(source, &|module| {
+            (module == self.import).then(|| module_source.clone())
+        })
+        .unwrap_or_else(|e| {
+            panic!(
+                "markdown source should compile with {}: {:?}",
+                self.import, e
+            )
+        })
+    }
+
+    /// A VM with raw `source` compiled and loaded.
+    pub fn load(&self, source: &str) -> VM {
+        let mut vm = VM::new();
+        vm.load(self.compile(source));
+        vm
+    }
+
+    /// Run `main` of raw `source`.
+    pub fn run_main(&self, source: &str) -> Value {
+        self.load(source)
+            .execute("main", vec![])
+            .expect("main should execute")
+    }
+}
+
+/// The contents of a file, relative to the repository root.
+pub fn repo_file(relative: &str) -> String {
+    let manifest_dir = PathBuf::from(env!("CARGO_MANIFEST_DIR"));
+    let path = manifest_dir.join("../..").join(relative);
+    fs::read_to_string(&path).unwrap_or_else(|e| panic!("cannot read {}: {}", path.display(), e))
+}
+
+/// A fresh scratch directory unique to this test process, `module` and
+/// `case`.
+pub fn scratch_dir(module: &str, case: &str) -> PathBuf {
+    let dir = std::env::temp_dir().join(format!(
+        "lumen-{}-stdlib-{}-{}",
+        module,
+        std::process::id(),
+        case
+    ));
+    let _ = fs::remove_dir_all(&dir);
+    fs::create_dir_all(&dir).expect("create scratch dir");
+    dir
+}
+
+pub fn as_string(value: &Value) -> String {
+    match value {
+        Value::String(StringRef::Owned(s)) => s.clone(),
+        other => panic!("expected owned string, got {:?}", other),
+    }
+}
+
+pub fn owned(s: &str) -> Value {
+    Value::String(StringRef::Owned(s.to_string()))
+}
+
+pub fn result_payload(v: Value) -> Value {
+    match v {
+        Value::Union(u) => (*u.payload).clone(),
+        other => panic!("expected result union, got {:?}", other),
+    }
+}
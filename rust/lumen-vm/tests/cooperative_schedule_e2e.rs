@@ -0,0 +1,150 @@
+//! Under the cooperative schedule, spawned tasks run one at a time in spawn
+//! order, so a program's output is the same on every run.
+
+use std::io::{self, Write};
+use std::sync::{Arc, Mutex};
+
+use lumen_vm::vm::{FutureSchedule, VM};
+
+#[derive(Clone, Default)]
+struct SharedSink(Arc<Mutex<Vec<u8>>>);
+
+impl Write for SharedSink {
+    fn write(&mut self, data: &[u8]) -> io::Result<usize> {
+        self.0.lock().unwrap().extend_from_slice(data);
+        Ok(data.len())
+    }
+    fn flush(&mut self) -> io::Result<()> {
+        Ok(())
+    }
+}
+
+const PRODUCER_CONSUMER: &str = r#"
+cell produce(name: String, n: Int) -> list[Int]
+  var items = []
+  for i in 0..n
+    print(name + " produced " + to_string(i))
+    items = append(items, i)
+  end
+  return items
+end
+
+cell consume(name: String, source: Any) -> Int
+  print(name + " waiting")
+  let items = await source
+  var total = 0
+  for item in items
+    print(name + " consumed " + to_string(item))
+    total = total + item
+  end
+  return total
+end
+
+cell log_done(name: String) -> Null
+  print(name + " done")
+  return null
+end
+
+cell main() -> Int
+  let a = spawn("produce", "a", 2)
+  let sum_a = spawn("consume", "x", a)
+  let b = spawn("produce", "b", 3)
+  let sum_b = spawn("consume", "y", b)
+  spawn("log_done", "background")
+  print("main waiting")
+  let total = await sum_b
+  print("main got " + to_string(total))
+  let rest = await sum_a
+  return total + rest
+end
+"#;
+
+/// Output and result of one run of `md` under `schedule`, or under the
+/// module's own directive when `schedule` is `None`.
+fn run(md: &str, schedule: Option<FutureSchedule>) -> (String, i64) {
+    let module = lumen_compiler::compile(md).expect("source should compile");
+    let sink = SharedSink::default();
+    let mut vm = VM::new();
+    if let Some(schedule) = schedule {
+        vm.set_future_schedule(schedule);
+    }
+    vm.set_stdout_sink(Box::new(sink.clone()), 4096);
+    vm.load(module);
+    let result = vm.execute("main", vec![]).expect("main should execute");
+    vm.flush_stdout().unwrap();
+    let output = String::from_utf8(sink.0.lock().unwrap().clone()).unwrap();
+    (output, result.as_int().expect("main should return an Int"))
+}
+
+#[test]
+fn e2e_cooperative_producer_consumer_output_is_reproducible() {
+    let md = format!(
+        "# test\n\n@schedule cooperative\n\n```lumen\n{}\n```\n",
+        PRODUCER_CONSUMER.trim()
+    );
+    let (first, total) = run(&md, None);
+    assert_eq!(total, 4);
+    assert_eq!(
+        first,
+        [
+            "main waiting",
+            // Awaiting `sum_b` runs the queue in spawn order until it is done
+            "a produced 0",
+            "a produced 1",
+            "x waiting",
+            "x consumed 0",
+            "x consumed 1",
+            "b produced 0",
+            "b produced 1",
+            "b produced 2",
+            "y waiting",
+            "y consumed 0",
+            "y consumed 1",
+            "y consumed 2",
+            "main got 3",
+            // Never awaited, so it runs once `main` returns
+            "background done",
+            "",
+        ]
+        .join("\n")
+    );
+    for _ in 0..10 {
+        assert_eq!(run(&md, None), (first.clone(), total));
+    }
+}
+
+#[test]
+fn e2e_cooperative_await_runs_earlier_spawns_first() {
+    let md = format!(
+        "# test\n\n```lumen\n{}\n```\n",
+        r#"
+cell produce(n: Int) -> Int
+  print("produced " + to_string(n))
+  return n
+end
+
+cell consume(source: Any) -> Int
+  print("consumer waiting")
+  let n = await source
+  print("consumed " + to_string(n))
+  return n
+end
+
+cell main() -> Int
+  let later = spawn("produce", 1)
+  let first = spawn("produce", 2)
+  let c = spawn("consume", first)
+  let consumed = await c
+  let produced = await later
+  return consumed + produced
+end
+"#
+        .trim()
+    );
+    let (output, total) = run(&md, Some(FutureSchedule::Cooperative));
+    assert_eq!(total, 3);
+    assert_eq!(
+        output,
+        "produced 1\nproduced 2\nconsumer waiting\nconsumed 2\n"
+    );
+}
@@ -0,0 +1,116 @@
+mod common;
+
+use std::fs;
+
+use common::{owned, result_payload, scratch_dir, StdModule};
+use lumen_vm::values::Value;
+
+const STD_CSV: StdModule = StdModule {
+    import: "std.csv",
+    path: "stdlib/std/csv.lm.md",
+};
+
+fn records(rows: &[&[&str]]) -> Value {
+    Value::new_list(
+        rows.iter()
+            .map(|row| Value::new_list(row.iter().map(|f| owned(f)).collect()))
+            .collect(),
+    )
+}
+
+#[test]
+fn e2e_csv_round_trips_quotes_commas_and_newlines() {
+    let source = r#"
+import std.csv: format_records, parse
+
+cell main() -> result[list[list[String]], String]
+  let rows = [["name", "quote"], ["Ada", "said \"hi\", twice"], ["multi\nline", ""], ["\"\"", "a,b,c"]]
+  return parse(format_records(rows))
+end
+"#;
+    assert_eq!(
+        result_payload(STD_CSV.run_main(source)),
+        records(&[
+            &["name", "quote"],
+            &["Ada", "said \"hi\", twice"],
+            &["multi\nline", ""],
+            &["\"\"", "a,b,c"],
+        ])
+    );
+}
+
+#[test]
+fn e2e_csv_reads_a_crlf_file_in_small_chunks() {
+    let dir = scratch_dir("csv", "crlf");
+    let path = dir.join("data.csv");
+    fs::write(
+        &path,
+        "id,city,note\r\n1,Oslo,\"cold, dark\"\r\n\r\n2,\"São Paulo\",\"two\r\nlines\"\r\n3,Lima,",
+    )
+    .unwrap();
+    let source = format!(
+        r#"
+import std.csv: from_file, read_all
+
+cell main() -> result[list[list[String]], String]
+  return read_all(from_file("{}", 5)?)
+end
+"#,
+        path.display()
+    );
+    assert_eq!(
+        result_payload(STD_CSV.run_main(&source)),
+        records(&[
+            &["id", "city", "note"],
+            &["1", "Oslo", "cold, dark"],
+            &["2", "São Paulo", "two\r\nlines"],
+            &["3", "Lima", ""],
+        ])
+    );
+    let _ = fs::remove_dir_all(&dir);
+}
+
+#[test]
+fn e2e_csv_writer_quotes_only_where_needed() {
+    let dir = scratch_dir("csv", "writer");
+    let path = dir.join("out.csv");
+    let source = format!(
+        r#"
+import std.csv: create_writer, write_record, flush_writer
+
+cell main() -> result[Null, String]
+  var w = create_writer("{}", true, 16)?
+  w = write_record(w, ["plain", "has,comma", "has \"quote\""])?
+  w = write_record(w, ["1", "2"])?
+  w = write_record(w, [""])?
+  w = flush_writer(w)?
+  return ok(null)
+end
+"#,
+        path.display()
+    );
+    STD_CSV.run_main(&source);
+    assert_eq!(
+        fs::read_to_string(&path).unwrap(),
+        "plain,\"has,comma\",\"has \"\"quote\"\"\"\r\n1,2\r\n\"\"\r\n"
+    );
+    let _ = fs::remove_dir_all(&dir);
+}
+
+#[test]
+fn e2e_csv_reports_malformed_records_by_position() {
+    let source = r#"
+import std.csv: parse
+
+cell main() -> String
+  match parse("a,b\n1,x\"y\n")
+    ok(_) -> return "parsed"
+    err(e) -> return e
+  end
+end
+"#;
+    assert_eq!(
+        STD_CSV.run_main(source),
+        owned("line 2, column 4: bare quote in unquoted field")
+    );
+}
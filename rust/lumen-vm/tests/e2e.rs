@@ -1,8 +1,9 @@
 //! End-to-end tests: compile Lumen source and execute it in the VM.
 
-use lumen_compiler::compile;
+use lumen_compiler::compiler::lir::Constant;
+use lumen_compiler::{compile, compile_raw};
 use lumen_vm::values::{StringRef, Value};
-use lumen_vm::vm::VM;
+use lumen_vm::vm::{DispatchMode, FieldCacheStats, VM};
 
 /// Helper: wrap raw Lumen code in markdown, compile it, run `main`, return the result.
 fn run_main(source: &str) -> Value {
@@ -123,6 +124,66 @@ end
     assert_eq!(result, Value::Bool(true));
 }
 
+#[test]
+fn e2e_and_or_skip_right_operand_when_decided() {
+    let (result, output) = run_main_with_output(
+        r#"
+cell touch(tag: String, v: Bool) -> Bool
+  print(tag)
+  return v
+end
+
+cell main() -> list[Bool]
+  let a = touch("a", false) and touch("b", true)
+  let b = touch("c", true) or touch("d", true)
+  let c = touch("e", true) && touch("f", false)
+  let d = touch("g", false) || touch("h", true)
+  return [a, b, c, d]
+end
+"#,
+    );
+    assert_eq!(
+        result,
+        Value::new_list(vec![
+            Value::Bool(false),
+            Value::Bool(true),
+            Value::Bool(false),
+            Value::Bool(true),
+        ])
+    );
+    assert_eq!(output, vec!["a", "c", "e", "f", "g", "h"]);
+}
+
+#[test]
+fn e2e_short_circuit_guards_out_of_bounds_index() {
+    let result = run_main(
+        r#"
+cell is_sorted(data: list[Int]) -> Bool
+  let n = len(data)
+  for let mut i = 0; i < n; i++
+    if i < n - 1 && data[i] > data[i + 1]
+      return false
+    end
+  end
+  return true
+end
+
+cell main() -> list[Bool]
+  return [is_sorted([1, 2, 3]), is_sorted([3, 1]), is_sorted([]), is_sorted([5])]
+end
+"#,
+    );
+    assert_eq!(
+        result,
+        Value::new_list(vec![
+            Value::Bool(true),
+            Value::Bool(false),
+            Value::Bool(true),
+            Value::Bool(true),
+        ])
+    );
+}
+
 // ─── String operations ───
 
 #[test]
@@ -190,6 +251,42 @@ end
     assert_eq!(result, Value::Int(2));
 }
 
+#[test]
+fn e2e_nested_conditional_expressions() {
+    let result = run_main(
+        r#"
+cell sign(n: Int) -> String
+  return if n > 0 then "positive" else if n < 0 then "negative" else "zero"
+end
+
+cell grade(score: Int) -> String
+  let g = if score >= 90
+    "A"
+  else if score >= 80
+    "B"
+  else
+    "C"
+  end
+  return g
+end
+
+cell main() -> String
+  let mut checksum = 0
+  for let mut perm_count = 0; perm_count < 6; perm_count++
+    checksum += if perm_count % 2 == 0 then perm_count else -perm_count
+  end
+  return join([sign(4), sign(-2), sign(0), grade(95), grade(85), grade(10), "{checksum}"], " ")
+end
+"#,
+    );
+    assert_eq!(
+        result,
+        Value::String(StringRef::Owned(
+            "positive negative zero A B C -3".to_string()
+        ))
+    );
+}
+
 // ─── While loop accumulation ───
 
 #[test]
@@ -284,6 +381,124 @@ end
     assert_eq!(result, Value::Int(15));
 }
 
+#[test]
+fn e2e_three_clause_for_sieve_with_continue() {
+    let result = run_main(
+        r#"
+cell main() -> Int
+  let limit = 100
+  let mut is_prime = [true; limit + 1]
+  let mut count = 0
+  for let mut i = 2; i <= limit; i++
+    if not is_prime[i]
+      continue
+    end
+    count++
+    for let mut j = i * i; j <= limit; j += i
+      is_prime[j] = false
+    end
+  end
+  return count
+end
+"#,
+    );
+    assert_eq!(result, Value::Int(25));
+}
+
+#[test]
+fn e2e_fannkuch_control_flow_with_loop_while_and_for() {
+    let result = run_main(
+        r#"
+cell fannkuch(n: Int) -> list[Int]
+  let mut perm = [0; n]
+  let mut perm1 = [0; n]
+  for let mut i = 0; i < n; i++
+    perm1[i] = i
+  end
+  let mut count = [0; n]
+  let mut max_flips = 0
+  let mut checksum = 0
+  let mut r = n
+  let mut perm_count = 0
+  loop
+    while r > 1
+      count[r - 1] = r
+      r--
+    end
+    for let mut i = 0; i < n; i++
+      perm[i] = perm1[i]
+    end
+    let mut flips = 0
+    let mut k = perm[0]
+    while k != 0
+      let mut hi = k
+      for let mut lo = 0; lo < hi; lo++
+        let t = perm[lo]
+        perm[lo] = perm[hi]
+        perm[hi] = t
+        hi--
+      end
+      flips++
+      k = perm[0]
+    end
+    if flips > max_flips
+      max_flips = flips
+    end
+    if perm_count % 2 == 0
+      checksum += flips
+    else
+      checksum -= flips
+    end
+    perm_count++
+    loop
+      if r == n
+        return [checksum, max_flips]
+      end
+      let p0 = perm1[0]
+      for let mut j = 0; j < r; j++
+        perm1[j] = perm1[j + 1]
+      end
+      perm1[r] = p0
+      count[r]--
+      if count[r] > 0
+        break
+      end
+      r++
+    end
+  end
+end
+
+cell main() -> list[Int]
+  return fannkuch(7)
+end
+"#,
+    );
+    assert_eq!(
+        result,
+        Value::new_list(vec![Value::Int(228), Value::Int(16)])
+    );
+}
+
+#[test]
+fn e2e_three_clause_for_init_is_scoped_to_loop() {
+    let result = run_main(
+        r#"
+cell main() -> Int
+  let i = 100
+  let mut total = 0
+  for let mut i = 0; i < 4; i++
+    total += i
+  end
+  for let mut i = 10; i > 8; i--
+    total += i
+  end
+  return total + i
+end
+"#,
+    );
+    assert_eq!(result, Value::Int(6 + 19 + 100));
+}
+
 // ─── List operations ───
 
 #[test]
@@ -319,6 +534,71 @@ end
     }
 }
 
+#[test]
+fn e2e_list_repeat_literal() {
+    let result = run_main(
+        r#"
+cell main() -> list[Int]
+  let primes = [2, 3, 5]
+  let zeros = [0; 4]
+  return primes ++ zeros
+end
+"#,
+    );
+    let expected: Vec<Value> = [2, 3, 5, 0, 0, 0, 0].into_iter().map(Value::Int).collect();
+    assert_eq!(result, Value::new_list(expected));
+}
+
+#[test]
+fn e2e_list_repeat_computed_length() {
+    // Sieve-style table: one flag per integer in 0..=limit
+    let result = run_main(
+        r#"
+cell main() -> String
+  let limit = 10
+  let mut composite = [false; limit + 1]
+  let mut p = 2
+  while p * p <= limit
+    if not composite[p]
+      let mut m = p * p
+      while m <= limit
+        composite[m] = true
+        m += p
+      end
+    end
+    p += 1
+  end
+  let empty = [""; limit - 10]
+  return "{len(composite)} {len(empty)} {composite}"
+end
+"#,
+    );
+    assert_eq!(
+        result,
+        Value::String(StringRef::Owned(
+            "11 0 [false, false, false, false, true, false, true, false, true, true, true]"
+                .to_string()
+        ))
+    );
+}
+
+#[test]
+fn e2e_list_repeat_negative_count_is_runtime_error() {
+    let md = "# e2e-test\n\n```lumen\ncell main() -> list[Int]\n  let n = 2\n  return [0; n - 3]\nend\n```\n";
+    let module = compile(md).expect("source should compile");
+    let mut vm = VM::new();
+    vm.load(module);
+    let err = vm
+        .execute("main", vec![])
+        .expect_err("negative count should fail");
+    assert!(
+        err.to_string()
+            .contains("list repeat count must be a non-negative Int, got -1"),
+        "got {}",
+        err
+    );
+}
+
 // ─── Match ───
 
 #[test]
@@ -344,6 +624,57 @@ end
     }
 }
 
+#[test]
+fn e2e_explicit_discard_runs_call() {
+    let (result, output) = run_main_with_output(
+        r#"
+cell save(n: Int) -> result[Int, String]
+  print("saved {n}")
+  return ok(n)
+end
+
+cell main() -> Int
+  _ = save(1)
+  _ = save(2)
+  return 3
+end
+"#,
+    );
+    assert_eq!(result, Value::Int(3));
+    assert_eq!(output, vec!["saved 1", "saved 2"]);
+}
+
+#[test]
+fn e2e_diverging_match_arm_and_loop_in_typed_positions() {
+    let result = run_main(
+        r#"
+cell unwrap(r: result[Int, String]) -> Int
+  let v: Int = match r
+    ok(n) -> n
+    err(e) -> panic(e)
+  end
+  return v
+end
+
+cell first_square_above(limit: Int) -> Int
+  let mut i = 0
+  let found: Int = loop
+    i += 1
+    if i * i > limit
+      return i
+    end
+  end
+  return found
+end
+
+cell main() -> Int
+  return unwrap(ok(40)) + first_square_above(50)
+end
+"#,
+    );
+    assert_eq!(result, Value::Int(48));
+}
+
 // ─── Print / output capture ───
 
 #[test]
@@ -427,6 +758,64 @@ end
     assert_eq!(result, Value::Int(24));
 }
 
+#[test]
+fn e2e_increment_and_decrement() {
+    let result = run_main(
+        r#"
+cell main() -> list[Int]
+  let mut i = 5
+  i++
+  i++
+  i--
+  let mut count = [3, 3, 3]
+  let r = 1
+  count[r]--
+  count[r]--
+  count[0]++
+  let a = 10
+  let b = 4
+  let d = a--b
+  return [i, count[0], count[1], count[2], d]
+end
+"#,
+    );
+    assert_eq!(
+        result,
+        Value::new_list(vec![
+            Value::Int(6),
+            Value::Int(4),
+            Value::Int(1),
+            Value::Int(3),
+            Value::Int(14),
+        ])
+    );
+}
+
+#[test]
+fn e2e_compound_assignment_evaluates_index_once() {
+    let (result, output) = run_main_with_output(
+        r#"
+cell pick(i: Int) -> Int
+  print("pick {i}")
+  return i
+end
+
+cell main() -> list[Int]
+  let mut counts = [0, 5, 0]
+  counts[pick(0)] += 10
+  counts[pick(1)]--
+  counts[pick(2)] *= 3
+  return counts
+end
+"#,
+    );
+    assert_eq!(
+        result,
+        Value::new_list(vec![Value::Int(10), Value::Int(4), Value::Int(0)])
+    );
+    assert_eq!(output, vec!["pick 0", "pick 1", "pick 2"]);
+}
+
 // ─── Null value ───
 
 #[test]
@@ -1782,6 +2171,40 @@ end
     assert_eq!(result, Value::Int(10001));
 }
 
+#[test]
+fn e2e_record_spread_overrides_one_field() {
+    let result = run_main(
+        r#"
+record Body
+  x: Float
+  vx: Float
+  mass: Float
+  name: String
+end
+
+cell halt_motion(b: Body) -> Body
+  return Body(..b, vx: 0.0)
+end
+
+cell main() -> list[Any]
+  let b = Body(x: 1.5, vx: -2.25, mass: 9.5, name: "jupiter")
+  let stopped = halt_motion(b)
+  return [stopped.x, stopped.vx, stopped.mass, stopped.name, b.vx]
+end
+"#,
+    );
+    assert_eq!(
+        result,
+        Value::new_list(vec![
+            Value::Float(1.5),
+            Value::Float(0.0),
+            Value::Float(9.5),
+            Value::String(StringRef::Owned("jupiter".to_string())),
+            Value::Float(-2.25),
+        ])
+    );
+}
+
 // ═══════════════════════════════════════════════════════════════════
 // Enum construction and pattern matching
 // ═══════════════════════════════════════════════════════════════════
@@ -2057,64 +2480,209 @@ end
     assert_eq!(result, Value::Int(20)); // 0+2+4+6+8
 }
 
-// ═══════════════════════════════════════════════════════════════════
-// While loop with complex conditions
-// ═══════════════════════════════════════════════════════════════════
+fn int_list(values: &[i64]) -> Value {
+    Value::new_list(values.iter().map(|&v| Value::Int(v)).collect())
+}
 
 #[test]
-fn e2e_while_loop_complex_condition() {
+fn e2e_for_loop_range_step_forward() {
     let result = run_main(
         r#"
-cell main() -> Int
-  let mut x = 0
-  let mut y = 100
-  while x < 10 and y > 90
-    x += 1
-    y -= 1
+cell main() -> list[list[Int]]
+  let mut exclusive: list[Int] = []
+  for i in 0..10 step 3
+    exclusive = append(exclusive, i)
   end
-  return x + y
+  let mut inclusive: list[Int] = []
+  for i in 1..=10 step 3
+    inclusive = append(inclusive, i)
+  end
+  return [exclusive, inclusive]
 end
 "#,
     );
-    assert_eq!(result, Value::Int(100)); // x=10, y=90
+    assert_eq!(
+        result,
+        Value::new_list(vec![int_list(&[0, 3, 6, 9]), int_list(&[1, 4, 7, 10])])
+    );
 }
 
 #[test]
-fn e2e_while_loop_with_multiple_breaks() {
+fn e2e_for_loop_range_step_reverse() {
     let result = run_main(
         r#"
-cell main() -> Int
-  let mut i = 0
-  let mut sum = 0
-  while i < 100
-    i += 1
-    if i == 5
-      continue
-    end
-    if i == 10
-      break
-    end
-    sum += i
+cell main() -> list[list[Int]]
+  let mut down: list[Int] = []
+  for i in 10..0 step -3
+    down = append(down, i)
   end
-  return sum
+  let mut down_to_zero: list[Int] = []
+  for i in 10..=0 step -5
+    down_to_zero = append(down_to_zero, i)
+  end
+  return [down, down_to_zero]
 end
 "#,
     );
-    assert_eq!(result, Value::Int(40)); // 1+2+3+4+6+7+8+9 (skip 5, break at 10)
+    assert_eq!(
+        result,
+        Value::new_list(vec![int_list(&[10, 7, 4, 1]), int_list(&[10, 5, 0])])
+    );
 }
 
-// ─── Unicode string operations ───
-
 #[test]
-fn e2e_unicode_length() {
+fn e2e_for_loop_range_step_uneven_span() {
+    // The last value stays inside the bound when the step doesn't divide the span.
     let result = run_main(
         r#"
-cell main() -> Int
+cell main() -> list[Int]
+  let limit = 11
+  let mut seen: list[Int] = []
+  for i in 2..=limit step 4
+    seen = append(seen, i)
+  end
+  return seen
+end
+"#,
+    );
+    assert_eq!(result, int_list(&[2, 6, 10]));
+}
+
+#[test]
+fn e2e_for_loop_range_empty() {
+    let result = run_main(
+        r#"
+cell main() -> Int
+  let mut count = 0
+  for i in 5..5
+    count += 1
+  end
+  for i in 5..0
+    count += 1
+  end
+  for i in 0..5 step -1
+    count += 1
+  end
+  for i in 3..=2
+    count += 1
+  end
+  return count
+end
+"#,
+    );
+    assert_eq!(result, Value::Int(0));
+}
+
+#[test]
+fn e2e_for_loop_range_continue_advances_counter() {
+    let result = run_main(
+        r#"
+cell main() -> Int
+  let mut sum = 0
+  for i in 0..=20 step 2
+    if i % 4 == 0
+      continue
+    end
+    sum += i
+  end
+  return sum
+end
+"#,
+    );
+    assert_eq!(result, Value::Int(2 + 6 + 10 + 14 + 18));
+}
+
+#[test]
+fn e2e_range_step_expression_builds_list() {
+    let result = run_main(
+        r#"
+cell main() -> list[list[Int]]
+  let evens = 0..=10 step 5
+  let squares = [i * i for i in 9..0 step -4]
+  return [evens, squares]
+end
+"#,
+    );
+    assert_eq!(
+        result,
+        Value::new_list(vec![int_list(&[0, 5, 10]), int_list(&[81, 25, 1])])
+    );
+}
+
+#[test]
+fn e2e_range_zero_step_is_runtime_error() {
+    let md = "# e2e-test\n\n```lumen\ncell main() -> Int\n  let mut n = 0\n  for i in 0..3 step 0\n    n += 1\n  end\n  return n\nend\n```\n";
+    let module = compile(md).expect("source should compile");
+    let mut vm = VM::new();
+    vm.load(module);
+    let err = vm
+        .execute("main", vec![])
+        .expect_err("zero step should fail");
+    assert!(
+        err.to_string().contains("range step cannot be zero"),
+        "got {}",
+        err
+    );
+}
+
+// ═══════════════════════════════════════════════════════════════════
+// While loop with complex conditions
+// ═══════════════════════════════════════════════════════════════════
+
+#[test]
+fn e2e_while_loop_complex_condition() {
+    let result = run_main(
+        r#"
+cell main() -> Int
+  let mut x = 0
+  let mut y = 100
+  while x < 10 and y > 90
+    x += 1
+    y -= 1
+  end
+  return x + y
+end
+"#,
+    );
+    assert_eq!(result, Value::Int(100)); // x=10, y=90
+}
+
+#[test]
+fn e2e_while_loop_with_multiple_breaks() {
+    let result = run_main(
+        r#"
+cell main() -> Int
+  let mut i = 0
+  let mut sum = 0
+  while i < 100
+    i += 1
+    if i == 5
+      continue
+    end
+    if i == 10
+      break
+    end
+    sum += i
+  end
+  return sum
+end
+"#,
+    );
+    assert_eq!(result, Value::Int(40)); // 1+2+3+4+6+7+8+9 (skip 5, break at 10)
+}
+
+// ─── Unicode string operations ───
+
+#[test]
+fn e2e_unicode_length() {
+    let result = run_main(
+        r#"
+cell main() -> Int
   return length("café")
 end
 "#,
     );
-    assert_eq!(result, Value::Int(4)); // 4 characters, not 5 bytes
+    assert_eq!(result, Value::Int(5)); // 5 UTF-8 bytes; `rune_count` gives 4
 }
 
 #[test]
@@ -2122,11 +2690,15 @@ fn e2e_unicode_slice() {
     let result = run_main(
         r#"
 cell main() -> String
-  return slice("café", 0, 3)
+  return slice("café", 0, 3) + "|" + slice("café", 3, 5) + "|" + slice("café", 0, 4)
 end
 "#,
     );
-    assert_eq!(result, Value::String(StringRef::Owned("caf".to_string())));
+    // Byte offsets; 4 falls inside 'é' and moves back to its first byte
+    assert_eq!(
+        result,
+        Value::String(StringRef::Owned("caf|é|caf".to_string()))
+    );
 }
 
 #[test]
@@ -2138,7 +2710,7 @@ cell main() -> Int
 end
 "#,
     );
-    assert_eq!(result, Value::Int(3)); // character index, not byte index
+    assert_eq!(result, Value::Int(3)); // byte index; "caf" is one byte per character
 }
 
 #[test]
@@ -2184,6 +2756,123 @@ end
     assert_eq!(result, Value::Int(4));
 }
 
+#[test]
+fn e2e_unicode_byte_len_vs_rune_count() {
+    let result = run_main(
+        r#"
+cell main() -> String
+  let s = "héllo, 世界 🎉"
+  return "{byte_len(s)}:{rune_count(s)}:{length(s)}"
+end
+"#,
+    );
+    // 'é' is 2 bytes, each CJK character 3, the emoji 4.
+    assert_eq!(
+        result,
+        Value::String(StringRef::Owned("19:11:19".to_string()))
+    );
+}
+
+#[test]
+fn e2e_unicode_rune_iteration() {
+    let result = run_main(
+        r#"
+cell main() -> String
+  let mut out = []
+  for r in chars("aé世🎉")
+    out = append(out, "{r}/{byte_len(r)}")
+  end
+  return join(out, " ")
+end
+"#,
+    );
+    assert_eq!(
+        result,
+        Value::String(StringRef::Owned("a/1 é/2 世/3 🎉/4".to_string()))
+    );
+}
+
+#[test]
+fn e2e_unicode_byte_and_rune_indexing() {
+    let result = run_main(
+        r#"
+cell main() -> String
+  let s = "é!"
+  let b0 = byte_at(s, 0)
+  let b1 = byte_at(s, 1)
+  let b2 = byte_at(s, 2)
+  let past = byte_at(s, 3)
+  let r1 = rune_at(s, 1)
+  return "{b0},{b1},{b2},{past == null},{r1},{rune_at(s, 2) == null},{s[0]},{s[-1]}"
+end
+"#,
+    );
+    assert_eq!(
+        result,
+        Value::String(StringRef::Owned(
+            "195,169,33,true,!,true,195,33".to_string()
+        ))
+    );
+}
+
+#[test]
+fn e2e_unicode_string_index_past_last_byte_is_an_error() {
+    let md =
+        "# e2e-test\n\n```lumen\ncell main() -> Int\n  let s = \"é\"\n  return s[2]\nend\n```\n";
+    let module = compile(md).expect("source should compile");
+    let mut vm = VM::new();
+    vm.load(module);
+    let err = vm
+        .execute("main", vec![])
+        .expect_err("s[2] is past the 2 bytes of 'é'");
+    assert!(
+        err.to_string().contains("index 2 out of range [0:2]"),
+        "unexpected error: {}",
+        err
+    );
+}
+
+#[test]
+fn e2e_unicode_decodes_known_utf8_sequence() {
+    let result = run_main(
+        r#"
+cell main() -> String
+  let encoded = utf8_encode("€")
+  let cps = runes("€")
+  let round_trip = from_runes([8364, 26085])
+  match utf8_decode(encoded)
+    ok(text) -> return "{bytes_len(encoded)}:{cps[0]}:{text}:{round_trip}"
+    err(e) -> return e
+  end
+end
+"#,
+    );
+    // U+20AC EURO SIGN encodes as E2 82 AC.
+    assert_eq!(
+        result,
+        Value::String(StringRef::Owned("3:8364:€:€日".to_string()))
+    );
+}
+
+#[test]
+fn e2e_unicode_invalid_utf8_is_an_error() {
+    let result = run_main(
+        r#"
+cell main() -> String
+  let bad = bytes_concat(utf8_encode("ok"), bytes_slice(utf8_encode("€"), 0, 2))
+  match utf8_decode(bad)
+    ok(text) -> return "decoded " + text
+    err(e) -> return e
+  end
+end
+"#,
+    );
+    assert_eq!(
+        result,
+        Value::String(StringRef::Owned("invalid UTF-8 at byte 2".to_string()))
+    );
+}
+
 // ─── Map/Set operations ───
 // Note: Map literal syntax is broken in a separate bug, so these tests are commented out
 
@@ -2242,6 +2931,32 @@ end
     assert_eq!(result, Value::Int(2));
 }
 
+#[test]
+fn e2e_map_layout_is_independent_of_insertion_order() {
+    // Maps are ordered by key rather than hashed, so there is no seed and two
+    // maps with the same entries have the same layout in every run.
+    let source = r#"
+cell main() -> list[String]
+  let mut forward = {}
+  let mut backward = {}
+  for i in 0..200
+    forward["k{i}"] = i
+    backward["k{199 - i}"] = 199 - i
+  end
+  return [join(keys(forward), ","), join(keys(backward), ","), string(forward == backward)]
+end
+"#;
+    let first = run_main(source);
+    let second = run_main(source);
+    assert_eq!(first, second);
+    let parts = first.as_list().expect("main should return a list");
+    assert_eq!(parts[0], parts[1]);
+    assert_eq!(
+        parts[2],
+        Value::String(StringRef::Owned("true".to_string()))
+    );
+}
+
 #[test]
 fn e2e_regalloc_stress() {
     let result = run_main(
@@ -2812,3 +3527,1317 @@ end
     );
     assert_eq!(result, Value::Int(60));
 }
+
+// ─── Iteration protocol ───
+
+const COUNTDOWN_ITERATOR: &str = r#"
+record Countdown
+  n: Int
+end
+
+trait Iterator
+  cell next(self: Countdown) -> tuple[Int, Countdown]?
+end
+
+impl Iterator for Countdown
+  cell next(self: Countdown) -> tuple[Int, Countdown]?
+    if self.n <= 0
+      return null
+    end
+    return (self.n, Countdown(n: self.n - 1))
+  end
+end
+"#;
+
+#[test]
+fn e2e_for_custom_iterator_runs_to_exhaustion() {
+    let source = format!(
+        "{}\n{}",
+        COUNTDOWN_ITERATOR,
+        r#"
+cell main() -> list[Int]
+  let mut seen: list[Int] = []
+  for n in Countdown(n: 4)
+    seen = append(seen, n)
+  end
+  return seen
+end
+"#
+    );
+    assert_eq!(
+        run_main(&source),
+        Value::new_list(vec![
+            Value::Int(4),
+            Value::Int(3),
+            Value::Int(2),
+            Value::Int(1)
+        ])
+    );
+}
+
+#[test]
+fn e2e_for_custom_iterator_stops_on_first_null() {
+    // next() returns null at the first negative item even though more follow.
+    let result = run_main(
+        r#"
+record UntilNegative
+  items: list[Int]
+  pos: Int
+end
+
+trait Iterator
+  cell next(self: UntilNegative) -> tuple[Int, UntilNegative]?
+end
+
+impl Iterator for UntilNegative
+  cell next(self: UntilNegative) -> tuple[Int, UntilNegative]?
+    if self.pos >= len(self.items)
+      return null
+    end
+    let item = self.items[self.pos]
+    if item < 0
+      return null
+    end
+    return (item, UntilNegative(items: self.items, pos: self.pos + 1))
+  end
+end
+
+cell main() -> Int
+  let mut total = 0
+  let mut steps = 0
+  for x in UntilNegative(items: [3, 1, 4, -1, 5, 9], pos: 0)
+    total += x
+    steps += 1
+  end
+  return total * 10 + steps
+end
+"#,
+    );
+    assert_eq!(result, Value::Int(83)); // 3 + 1 + 4 = 8, three steps
+}
+
+#[test]
+fn e2e_for_custom_iterator_break_continue_and_comprehension() {
+    let source = format!(
+        "{}\n{}",
+        COUNTDOWN_ITERATOR,
+        r#"
+cell main() -> String
+  let it = Countdown(n: 6)
+  let mut odd: list[Int] = []
+  for n in it
+    if n % 2 == 0
+      continue
+    end
+    if n == 1
+      break
+    end
+    odd = append(odd, n)
+  end
+  let squares = [n * n for n in it if n > 3]
+  return "{odd} {squares} {it.n}"
+end
+"#
+    );
+    assert_eq!(
+        run_main(&source),
+        Value::String(StringRef::Owned("[5, 3] [36, 25, 16] 6".into()))
+    );
+}
+
+#[test]
+fn e2e_for_iterates_map_keys_and_string_chars() {
+    let result = run_main(
+        r#"
+cell main() -> String
+  let m = {"b": 2, "a": 1, "c": 3}
+  let mut keys = ""
+  for k in m
+    keys = keys + k
+  end
+  let mut chars: list[String] = []
+  for ch in "héllo"
+    chars = append(chars, ch)
+  end
+  return keys + " " + join(chars, "-")
+end
+"#,
+    );
+    assert_eq!(
+        result,
+        Value::String(StringRef::Owned("abc h-é-l-l-o".into()))
+    );
+}
+
+#[test]
+fn e2e_for_over_large_set_map_and_string_visits_each_element_once() {
+    // Each loop walks the snapshot its first step takes, so it yields every
+    // element exactly once and never sees what the body adds or replaces
+    let result = run_main(
+        r#"
+cell main() -> String
+  var seen = to_set(range(0, 100000))
+  var count = 0
+  var total = 0
+  for n in seen
+    count += 1
+    total += n
+    seen = to_set([0])
+  end
+  var scores = {"k0": 0}
+  for i in range(1, 50000)
+    scores["k{i}"] = i
+  end
+  var keys = 0
+  var score_total = 0
+  for k in scores
+    keys += 1
+    score_total += scores[k]
+    # Sorts after every "k" key, so a live walk would reach it
+    scores["z{k}"] = 1
+  end
+  var text = join(["é"; 100000], "")
+  var runes = 0
+  for ch in text
+    if ch == "é"
+      runes += 1
+    end
+    text = "x"
+  end
+  return "{count} {total} {len(seen)} {keys} {score_total} {len(scores)} {runes}"
+end
+"#,
+    );
+    assert_eq!(
+        result,
+        Value::String(StringRef::Owned(
+            "100000 4999950000 1 50000 1249975000 100000 100000".into()
+        ))
+    );
+}
+
+// ─── Switch ───
+
+#[test]
+fn e2e_switch_dense_int_cases_use_jump_table() {
+    let source = r#"
+cell opname(op: Int) -> String
+  let mut name = "?"
+  switch op
+    case 0 -> name = "nop"
+    case 1
+      name = "load"
+    case 2, 3
+      name = "arith"
+    case 5 -> name = "jmp"
+    case 6 -> name = "call"
+    default
+      name = "bad"
+  end
+  return name
+end
+
+cell main() -> String
+  let mut out = []
+  for op in -1..8
+    out = append(out, opname(op))
+  end
+  return join(out, " ")
+end
+"#;
+    let md = format!("# e2e-test\n\n```lumen\n{}\n```\n", source.trim());
+    let module = compile(&md).expect("source should compile");
+    let opname = module
+        .cells
+        .iter()
+        .find(|c| c.name == "opname")
+        .expect("opname cell");
+    assert!(
+        opname
+            .instructions
+            .iter()
+            .any(|i| i.op == lumen_compiler::compiler::lir::OpCode::JmpTable),
+        "dense int switch should dispatch through a jump table"
+    );
+
+    let mut vm = VM::new();
+    vm.load(module);
+    let result = vm.execute("main", vec![]).expect("main should execute");
+    assert_eq!(
+        result,
+        Value::String(StringRef::Owned(
+            "bad nop load arith arith bad jmp call bad".into()
+        ))
+    );
+}
+
+#[test]
+fn e2e_switch_on_strings_selects_arm_and_default() {
+    let result = run_main(
+        r#"
+cell verb(cmd: String) -> Int
+  switch cmd
+    case "get", "head" -> return 1
+    case "put" -> return 2
+    case "delete" -> return 3
+    default -> return 0
+  end
+  return -1
+end
+
+cell main() -> String
+  let mut out = []
+  for cmd in ["head", "put", "delete", "patch", "get"]
+    out = append(out, to_string(verb(cmd)))
+  end
+  return join(out, ",")
+end
+"#,
+    );
+    assert_eq!(result, Value::String(StringRef::Owned("1,2,3,0,1".into())));
+}
+
+#[test]
+fn e2e_switch_on_strings_hashes_to_the_matching_arm() {
+    use lumen_compiler::compiler::lir::{IntrinsicId, OpCode};
+
+    let source = r#"
+cell method(cmd: String) -> Int
+  switch cmd
+    case "GET", "HEAD" -> return 1
+    case "POST" -> return 2
+    case "PUT" -> return 3
+    case "PATCH" -> return 4
+    case "DELETE" -> return 5
+    case "OPTIONS", "TRACE" -> return 6
+    case "CONNECT" -> return 7
+    default -> return 0
+  end
+  return -1
+end
+
+cell main() -> String
+  var out = []
+  for cmd in ["GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS", "TRACE", "CONNECT", "get", "", "BREW"]
+    out = append(out, to_string(method(cmd)))
+  end
+  return join(out, ",")
+end
+"#;
+    let md = format!("# e2e-test\n\n```lumen\n{}\n```\n", source.trim());
+    let module = compile(&md).expect("source should compile");
+    let method = module
+        .cells
+        .iter()
+        .find(|c| c.name == "method")
+        .expect("method cell");
+    assert!(
+        method
+            .instructions
+            .iter()
+            .any(|i| i.op == OpCode::Intrinsic && i.b == IntrinsicId::SwitchHash as u8),
+        "string switch should hash its subject"
+    );
+    assert!(
+        method.instructions.iter().any(|i| i.op == OpCode::JmpTable),
+        "string switch should dispatch through a jump table"
+    );
+
+    let mut vm = VM::new();
+    vm.load(module);
+    let result = vm.execute("main", vec![]).expect("main should execute");
+    // Unmatched subjects take the default whatever slot they hash to
+    assert_eq!(
+        result,
+        Value::String(StringRef::Owned("1,1,2,3,4,5,6,6,7,0,0,0".into()))
+    );
+}
+
+#[test]
+fn e2e_switch_string_table_survives_hash_collisions() {
+    // Forty cases in at most eighty slots are bound to share some; a subject
+    // in a shared slot compares down from the first arm there
+    let mut cases = String::new();
+    let mut subjects = Vec::new();
+    for i in 0..40 {
+        cases.push_str(&format!("    case \"k{}\" -> return {}\n", i, i));
+        subjects.push(format!("\"k{}\"", i));
+    }
+    subjects.push("\"k40\"".to_string());
+    let source = format!(
+        "cell pick(key: String) -> Int\n  switch key\n{}    default -> return -1\n  end\n  return -2\nend\n\ncell main() -> Int\n  var total = 0\n  for key in [{}]\n    total += pick(key)\n  end\n  return total\nend\n",
+        cases,
+        subjects.join(", ")
+    );
+    // 0 + 1 + ... + 39, and -1 for the unmatched "k40"
+    assert_eq!(run_main(&source), Value::Int(779));
+}
+
+#[test]
+fn e2e_switch_does_not_fall_through_and_ignores_unmatched_without_default() {
+    let result = run_main(
+        r#"
+cell main() -> Int
+  let mut hits = 0
+  for x in [1, 2, 9, 2]
+    switch x
+      case 1
+        hits += 1
+      case 2
+        hits += 10
+    end
+  end
+  return hits
+end
+"#,
+    );
+    assert_eq!(result, Value::Int(21));
+}
+
+// ─── Methods ───
+
+#[test]
+fn e2e_value_receiver_method() {
+    let result = run_main(
+        r#"
+record Body
+  mass: Float
+  v: Float
+end
+
+cell (b: Body) kinetic() -> Float
+  return 0.5 * b.mass * b.v * b.v
+end
+
+cell (b: Body) scaled(k: Float) -> Body
+  return Body(mass: b.mass * k, v: b.v)
+end
+
+cell main() -> Float
+  let b = Body(mass: 2.0, v: 3.0)
+  let heavy = b.scaled(2.0)
+  return b.kinetic() + heavy.kinetic()
+end
+"#,
+    );
+    assert_eq!(result, Value::Float(27.0));
+}
+
+#[test]
+fn e2e_mut_receiver_mutation_is_visible_to_caller() {
+    let result = run_main(
+        r#"
+record Body
+  x: Int
+  vx: Int
+end
+
+cell (b: Body) ahead(steps: Int) -> Int
+  return b.x + b.vx * steps
+end
+
+cell (mut b: Body) advance(steps: Int)
+  b.x = b.x + b.vx * steps
+end
+
+cell (mut b: Body) bounce() -> Int
+  b.vx = 0 - b.vx
+  return b.vx
+end
+
+cell (mut b: Body) run_until(limit: Int) -> Int
+  let mut n = 0
+  while true
+    if b.x >= limit
+      return n
+    end
+    b.advance(1)
+    n += 1
+  end
+  return -1
+end
+
+cell main() -> String
+  let mut b = Body(x: 1, vx: 2)
+  let before = b
+  let guess = b.ahead(10)
+  b.advance(1)
+  b.advance(1)
+  let v = b.bounce()
+  let back = b.bounce()
+  let steps = b.run_until(12)
+  return "{guess} {v} {back} {steps} {b.x} {b.vx} {before.x}"
+end
+"#,
+    );
+    assert_eq!(
+        result,
+        Value::String(StringRef::Owned("21 -2 2 4 13 2 1".into()))
+    );
+}
+
+#[test]
+fn e2e_mut_receiver_write_back_follows_the_receiver_type() {
+    // `step` is `mut` on Counter but not on Probe; each call site writes
+    // back only when its receiver is a Counter
+    let result = run_main(
+        r#"
+record Counter
+  n: Int
+end
+
+record Probe
+  n: Int
+end
+
+cell (mut c: Counter) step() -> Int
+  c.n = c.n + 1
+  return c.n
+end
+
+cell (p: Probe) step() -> Int
+  return p.n + 100
+end
+
+cell main() -> String
+  var c = Counter(n: 0)
+  let p = Probe(n: 5)
+  let a = c.step()
+  let b = p.step()
+  let d = c.step()
+  return "{a} {b} {d} {c.n} {p.n}"
+end
+"#,
+    );
+    assert_eq!(
+        result,
+        Value::String(StringRef::Owned("1 105 2 2 5".into()))
+    );
+}
+
+// ─── Traits ───
+
+const SHAPES: &str = r#"
+trait Shape
+  cell area(self: Self) -> Int
+  cell label(self: Self) -> String
+end
+
+record Square
+  side: Int
+end
+
+record Rect
+  w: Int
+  h: Int
+end
+
+impl Shape for Square
+  cell area(self: Self) -> Int
+    return self.side * self.side
+  end
+  cell label(self: Self) -> String
+    return "square"
+  end
+end
+
+impl Shape for Rect
+  cell area(self: Self) -> Int
+    return self.w * self.h
+  end
+  cell label(self: Self) -> String
+    return "rect"
+  end
+end
+
+impl Shape for Int
+  cell area(self: Self) -> Int
+    return self
+  end
+  cell label(self: Self) -> String
+    return "int"
+  end
+end
+"#;
+
+#[test]
+fn e2e_trait_bound_generic_dispatch() {
+    let source = format!(
+        "{}\n{}",
+        SHAPES,
+        r#"
+cell describe[T: Shape](s: T) -> String
+  return s.label() + "=" + to_string(s.area())
+end
+
+cell main() -> String
+  return describe(Square(side: 3)) + " " + describe(Rect(w: 2, h: 5)) + " " + describe(7)
+end
+"#
+    );
+    assert_eq!(
+        run_main(&source),
+        Value::String(StringRef::Owned("square=9 rect=10 int=7".into()))
+    );
+}
+
+#[test]
+fn e2e_trait_object_list_dispatches_per_element() {
+    let source = format!(
+        "{}\n{}",
+        SHAPES,
+        r#"
+cell total_area(shapes: list[Shape]) -> Int
+  let mut total = 0
+  for s in shapes
+    total += s.area()
+  end
+  return total
+end
+
+cell main() -> String
+  let shapes: list[Shape] = [Square(side: 3), Rect(w: 2, h: 5), 4, Square(side: 1)]
+  let mut labels: list[String] = []
+  for s in shapes
+    labels = append(labels, s.label())
+  end
+  return join(labels, ",") + " " + to_string(total_area(shapes))
+end
+"#
+    );
+    assert_eq!(
+        run_main(&source),
+        Value::String(StringRef::Owned("square,rect,int,square 24".into()))
+    );
+}
+
+#[test]
+fn e2e_operator_overloading_dispatches_to_impls() {
+    let result = run_main(
+        r#"
+record Vec2
+  x: Int
+  y: Int
+end
+
+impl Add for Vec2
+  cell add(self: Self, other: Self) -> Self
+    return Vec2(x: self.x + other.x, y: self.y + other.y)
+  end
+end
+
+impl Sub for Vec2
+  cell sub(self: Self, other: Self) -> Self
+    return Vec2(x: self.x - other.x, y: self.y - other.y)
+  end
+end
+
+impl Mul for Vec2
+  cell mul(self: Self, other: Self) -> Self
+    return Vec2(x: self.x * other.x, y: self.y * other.y)
+  end
+end
+
+record Angle
+  deg: Int
+end
+
+impl Eq for Angle
+  cell eq(self: Self, other: Self) -> Bool
+    return self.deg % 360 == other.deg % 360
+  end
+end
+
+cell main() -> String
+  let v1 = Vec2(x: 1, y: 2)
+  let v2 = Vec2(x: 10, y: 20)
+  let mut v = v1 + v2 * v1 - Vec2(x: 1, y: 1)
+  v += v1
+  let same = Angle(deg: 30) == Angle(deg: 390)
+  let differ = Angle(deg: 30) != Angle(deg: 390)
+  let plain = v1 == Vec2(x: 1, y: 2)
+  return "{v.x},{v.y} {same} {differ} {plain}"
+end
+"#,
+    );
+    assert_eq!(
+        result,
+        Value::String(StringRef::Owned("11,43 true false true".into()))
+    );
+}
+
+// ─── Compile-time constants ───
+
+const CONST_CELLS: &str = r#"
+const cell square(x: Float) -> Float
+  return x * x
+end
+
+const cell sum_to(n: Int) -> Int
+  if n <= 0
+    return 0
+  end
+  let rest = sum_to(n - 1)
+  return n + rest
+end
+
+const SOLAR_MASS = 4.0 * square(PI)
+const TRIANGLE = sum_to(100)
+"#;
+
+#[test]
+fn e2e_const_initialized_from_const_cells() {
+    let result = run_main(&format!(
+        "{}\n{}",
+        CONST_CELLS,
+        r#"
+cell main() -> String
+  let exact = SOLAR_MASS == 4.0 * PI * PI
+  return "{exact} {TRIANGLE}"
+end
+"#
+    ));
+    assert_eq!(result, Value::String(StringRef::Owned("true 5050".into())));
+}
+
+#[test]
+fn e2e_const_cell_value_is_computed_at_compile_time() {
+    let md = format!(
+        "# e2e-test\n\n```lumen\n{}\ncell main() -> Int\n  return TRIANGLE\nend\n```\n",
+        CONST_CELLS
+    );
+    let module = compile(&md).expect("source should compile");
+    let main = module.cells.iter().find(|c| c.name == "main").unwrap();
+    assert!(main
+        .constants
+        .iter()
+        .any(|k| matches!(k, Constant::Int(5050))));
+}
+
+#[test]
+fn e2e_const_block_iota_counts_lines() {
+    let result = run_main(
+        r#"
+const
+  OP_ADD = iota
+  OP_SUB
+  OP_MUL
+  OP_DIV
+end
+
+const
+  FIRST = iota + 100
+  SECOND
+end
+
+cell main() -> String
+  return "{OP_ADD} {OP_SUB} {OP_MUL} {OP_DIV} {FIRST} {SECOND}"
+end
+"#,
+    );
+    assert_eq!(
+        result,
+        Value::String(StringRef::Owned("0 1 2 3 100 101".into()))
+    );
+}
+
+#[test]
+fn e2e_const_block_shifted_iota_yields_powers_of_two() {
+    let result = run_main(
+        r#"
+const
+  READ: Int = 1 << iota
+  WRITE
+  EXEC
+  SKIPPED = -1
+  AFTER
+  FLAG = 1 << iota
+  LAST
+end
+
+cell main() -> String
+  return "{READ} {WRITE} {EXEC} {SKIPPED} {AFTER} {FLAG} {LAST} {READ + EXEC}"
+end
+"#,
+    );
+    assert_eq!(
+        result,
+        Value::String(StringRef::Owned("1 2 4 -1 -1 32 64 5".into()))
+    );
+}
+
+#[test]
+fn e2e_sprintf_and_printf_format_a_table() {
+    let (result, output) = run_main_with_output(
+        r#"
+cell main() -> String
+  let names = ["fib", "nbody"]
+  let runs = [35, -3]
+  let secs = [0.7512, 12.0]
+  for i in range(0, 2)
+    printf("%-8s|%+06d|%8.2f\n", names[i], runs[i], secs[i])
+  end
+  return sprintf("%#x %08b %o %X %5.1e %c%%", 48879, 5, 64, "lm", 1234.5, 955)
+end
+"#,
+    );
+    assert_eq!(
+        output,
+        vec!["fib     |+00035|    0.75", "nbody   |-00003|   12.00"]
+    );
+    assert_eq!(
+        result,
+        Value::String(StringRef::Owned(
+            "0xbeef 00000101 100 6C6D 1.2e+03 λ%".into()
+        ))
+    );
+}
+
+// Captured output holds one entry per line: a line printf leaves open is
+// finished by whatever prints next, and one still open at the end of the
+// run is captured on its own.
+#[test]
+fn e2e_printf_captures_whole_lines() {
+    let (_, output) = run_main_with_output(
+        r#"
+cell main() -> Null
+  printf("a=%d", 1)
+  printf(", b=%d\nc=", 2)
+  print(3)
+  printf("%s\n\n%s", "x", "tail")
+  return null
+end
+"#,
+    );
+    assert_eq!(output, vec!["a=1, b=2", "c=3", "x", "", "tail"]);
+}
+
+#[test]
+fn e2e_nested_index_assignment_updates_jagged_rows() {
+    let result = run_main(
+        r#"
+cell main() -> String
+  let mut rows = [[1], [2, 3], []]
+  rows[1][1] = 30
+  rows[1][0] += 18
+  rows[2] = append(rows[2], 7)
+  let snapshot = rows
+  rows[0][0] = 100
+  let mut cube = [[[0; 2]; 2]; 2]
+  cube[1][0][1] = 5
+  cube[1][0][1] *= 3
+  let mut tally = {"a": [0, 0]}
+  tally["a"][1] += 4
+  return "{rows} {snapshot} {len(rows[1])} {cube} {tally}"
+end
+"#,
+    );
+    assert_eq!(
+        result,
+        Value::String(StringRef::Owned(
+            "[[100], [20, 30], [7]] [[1], [20, 30], [7]] 2 \
+             [[[0, 0], [0, 0]], [[0, 15], [0, 0]]] {a: [0, 4]}"
+                .into()
+        ))
+    );
+}
+
+#[test]
+fn e2e_index_assignment_through_a_field_updates_the_record() {
+    let result = run_main(
+        r#"
+record Grid
+  cells: list[Int]
+  rows: list[list[Int]]
+end
+
+cell main() -> String
+  let mut g = Grid(cells: [1, 2, 3], rows: [[0, 0], [0]])
+  let before = g
+  g.cells[1] = 20
+  g.cells[2] += 10
+  g.rows[0][1] = 5
+  return "{g.cells} {g.rows} {before.cells} {before.rows}"
+end
+"#,
+    );
+    assert_eq!(
+        result,
+        Value::String(StringRef::Owned(
+            "[1, 20, 13] [[0, 5], [0]] [1, 2, 3] [[0, 0], [0]]".into()
+        ))
+    );
+}
+
+// Same inputs as bench/cross-language/matrix_mult; the expected checksum is
+// the output of the fixed-size `[N][N]float64` Go version.
+#[test]
+fn e2e_dynamic_matrix_multiply_matches_fixed_array_checksum() {
+    let result = run_main(
+        r#"
+cell square(n: Int, transpose: Bool) -> list[list[Float]]
+  let mut m = [[0.0; n]; n]
+  for i in range(0, n)
+    for j in range(0, n)
+      if transpose
+        m[i][j] = (j * n + i) % 1000 / 1000.0
+      else
+        m[i][j] = (i * n + j) % 1000 / 1000.0
+      end
+    end
+  end
+  return m
+end
+
+cell main() -> String
+  let n = 200
+  let a = square(n, false)
+  let b = square(n, true)
+  let mut c = [[0.0; n]; n]
+  for i in range(0, n)
+    for j in range(0, n)
+      let mut sum = 0.0
+      for k in range(0, n)
+        sum += a[i][k] * b[k][j]
+      end
+      c[i][j] = sum
+    end
+  end
+  let mut checksum = 0.0
+  for row in c
+    for x in row
+      checksum += x
+    end
+  end
+  return format_fixed(checksum, 6)
+end
+"#,
+    );
+    assert_eq!(
+        result,
+        Value::String(StringRef::Owned("2022668.000001".into()))
+    );
+}
+
+/// The source of a program in bench/cross-language.
+fn bench_source(relative: &str) -> String {
+    let path = std::path::PathBuf::from(env!("CARGO_MANIFEST_DIR"))
+        .join("../../bench/cross-language")
+        .join(relative);
+    std::fs::read_to_string(&path)
+        .unwrap_or_else(|e| panic!("cannot read {}: {}", path.display(), e))
+}
+
+/// bench/cross-language/tree builds a depth-18 tree and takes no arguments,
+/// so tests build a smaller one by rewriting the call.
+fn tree_source(depth: u32) -> String {
+    let source = bench_source("tree/tree.lm");
+    assert!(
+        source.contains("build_tree(18)"),
+        "tree.lm no longer calls build_tree(18)"
+    );
+    source.replace("build_tree(18)", &format!("build_tree({})", depth))
+}
+
+/// Run a program from bench/cross-language with `args()` set to `program_args`,
+/// returning its printed lines.
+fn run_bench_program(relative: &str, program_args: &[&str]) -> Vec<String> {
+    run_bench_source(&bench_source(relative), program_args, DispatchMode::Match)
+}
+
+fn run_bench_source(source: &str, program_args: &[&str], mode: DispatchMode) -> Vec<String> {
+    let module = compile_raw(source).expect("benchmark should compile");
+    let mut vm = VM::new();
+    vm.set_dispatch_mode(mode);
+    vm.load(module);
+    vm.set_program_args(program_args.iter().copied());
+    vm.execute("main", vec![]).expect("main should execute");
+    vm.output
+}
+
+// bench/cross-language/call_overhead: `tick` is the only thing that advances
+// the counter, so the count proves each loop iteration really made the call.
+#[test]
+fn e2e_call_overhead_benchmark_calls_tick_n_times() {
+    for n in ["1", "1000", "25000"] {
+        let output = run_bench_program("call_overhead/call_overhead.lm", &[n]);
+        assert_eq!(output.len(), 2, "unexpected output: {:?}", output);
+        assert_eq!(output[0], format!("calls = {}", n));
+        let per_call = output[1]
+            .strip_prefix("ns/call = ")
+            .unwrap_or_else(|| panic!("unexpected timing line: {}", output[1]));
+        assert!(
+            per_call.parse::<f64>().is_ok(),
+            "ns/call should be a number: {}",
+            per_call
+        );
+    }
+}
+
+// Calls, tail calls, and intrinsics share the main dispatch match with every
+// other opcode; these outputs are those of the Go versions at the same sizes.
+#[test]
+fn e2e_call_heavy_benchmarks_match_go_output() {
+    assert_eq!(
+        run_bench_program("fibonacci/fib.lm", &["20"]),
+        ["fib(20) = 6765"]
+    );
+    assert_eq!(
+        run_bench_source(&tree_source(10), &[], DispatchMode::Match),
+        ["Checksum: 1024"]
+    );
+}
+
+#[test]
+fn e2e_call_dispatch_covers_closures_tail_calls_and_builtins() {
+    let (result, output) = run_main_with_output(
+        r#"
+cell count_down(n: Int, acc: Int) -> Int
+  if n == 0
+    return acc
+  end
+  return count_down(n - 1, acc + n)
+end
+
+cell apply(f: fn(Int) -> Int, x: Int) -> Int
+  return f(x)
+end
+
+cell main() -> Int
+  let k = 3
+  let scaled = apply(fn(x: Int) -> Int => x * k, 14)
+  let total = count_down(10000, 0)
+  let n = len([1, 2, 3])
+  print("scaled {scaled} total {total} len {n}")
+  return scaled + total
+end
+"#,
+    );
+    assert_eq!(output, ["scaled 42 total 50005000 len 3"]);
+    assert_eq!(result, Value::Int(50005042));
+}
+
+// Table dispatch reaches the same handlers as the match through a function
+// pointer, so every program prints and returns the same under both.
+#[test]
+fn e2e_table_dispatch_matches_match_dispatch() {
+    for (program, source, program_args) in [
+        (
+            "fibonacci/fib.lm",
+            bench_source("fibonacci/fib.lm"),
+            &["20"][..],
+        ),
+        ("tree/tree.lm", tree_source(10), &[][..]),
+    ] {
+        assert_eq!(
+            run_bench_source(&source, program_args, DispatchMode::Table),
+            run_bench_source(&source, program_args, DispatchMode::Match),
+            "{} differs between dispatch modes",
+            program
+        );
+    }
+
+    let module = compile_raw(
+        r#"
+enum Shape
+  Circle(radius: Float)
+  Square(side: Int)
+end
+
+cell area(s: Shape) -> Float
+  match s
+    Circle(r) -> return 3.0 * r * r
+    Square(side) -> return to_float(side * side)
+  end
+end
+
+cell main() -> String
+  var s = "a"
+  var n = 0
+  for i in 0..50
+    s = s + "b"
+    n = n + i * 3 - i // 2 + i % 4 - 2 ** 2
+    if n <= 100 and not (n < 0)
+      n = -n
+    end
+  end
+  let missing: Int? = null
+  var total = 0.0
+  for shape in [Circle(radius: 2.0), Square(side: 3)]
+    total = total + area(shape)
+  end
+  return "{len(s)} {n} {missing ?? 7} {total} {total > 20.5} {"b" < "c"} {n == 2}"
+end
+"#,
+    )
+    .expect("source should compile");
+    let run = |mode: DispatchMode| {
+        let mut vm = VM::new();
+        vm.set_dispatch_mode(mode);
+        vm.load(module.clone());
+        vm.execute("main", vec![]).expect("main should execute")
+    };
+    let table = run(DispatchMode::Table);
+    assert_eq!(table, run(DispatchMode::Match));
+    assert!(
+        matches!(table, Value::String(_)),
+        "unexpected result {:?}",
+        table
+    );
+
+    // Errors raised in a handler surface the same way
+    let module = compile_raw(
+        r#"
+cell main() -> Int
+  let zero = 0
+  return 10 % zero
+end
+"#,
+    )
+    .expect("source should compile");
+    let mut vm = VM::new();
+    vm.set_dispatch_mode(DispatchMode::Table);
+    vm.load(module);
+    let err = vm
+        .execute("main", vec![])
+        .expect_err("division by zero should fail");
+    assert!(
+        err.to_string().contains("zero"),
+        "unexpected error: {}",
+        err
+    );
+}
+
+// ─── Block scoping ───
+
+#[test]
+fn e2e_inner_block_shadows_outer_binding() {
+    let (result, output) = run_main_with_output(
+        r#"
+cell main() -> Int
+  let x = 1
+  if true
+    let x = "inner"
+    print(x)
+  end
+  for i in [10, 20]
+    let x = i + 1
+    print(x)
+  end
+  match x
+    1 ->
+      let x = 100
+      print(x)
+    _ -> print("other")
+  end
+  return x
+end
+"#,
+    );
+    assert_eq!(output, vec!["inner", "11", "21", "100"]);
+    assert_eq!(result, Value::Int(1));
+}
+
+// ─── Record layout ───
+
+#[test]
+fn e2e_sizeof_and_alignof_records() {
+    let result = run_main(
+        r#"
+record Body
+  x: Float
+  y: Float
+  z: Float
+  vx: Float
+  vy: Float
+  vz: Float
+  mass: Float
+end
+
+record Flags
+  a: Bool
+  n: Int
+  b: Bool
+end
+
+@packed
+record PackedFlags
+  a: Bool
+  n: Int
+  b: Bool
+end
+
+cell main() -> list[Int]
+  return [sizeof(Body), alignof(Body), sizeof(Flags), sizeof(PackedFlags), alignof(PackedFlags)]
+end
+"#,
+    );
+    assert_eq!(
+        result,
+        Value::new_list(vec![
+            Value::Int(56),
+            Value::Int(8),
+            Value::Int(16),
+            Value::Int(10),
+            Value::Int(1),
+        ])
+    );
+}
+
+// ─── Weak references ───
+
+#[test]
+fn e2e_weak_reference_upgrades_until_target_is_freed() {
+    let result = run_main(
+        r#"
+record Node
+  value: Int
+end
+
+cell detached() -> Weak[Node]
+  let n = Node(value: 7)
+  return weak(n)
+end
+
+cell main() -> String
+  let held = Node(value: 3)
+  let live = weak(held)
+  let dropped = detached()
+  let a = live.upgrade()
+  let b = dropped.upgrade()
+  return "{a == null},{b == null},{a == held}"
+end
+"#,
+    );
+    // `detached`'s frame held the only strong reference to its node
+    assert_eq!(result, Value::String(StringRef::Owned("false,true,true".into())));
+}
+
+// ─── Field read inline caches ───
+
+fn run_main_with_field_cache_stats(source: &str) -> (Value, FieldCacheStats) {
+    let md = format!("# e2e-test\n\n```lumen\n{}\n```\n", source.trim());
+    let module = compile(&md).expect("source should compile");
+    let mut vm = VM::new();
+    vm.load(module);
+    let result = vm.execute("main", vec![]).expect("main should execute");
+    (result, vm.field_cache_stats())
+}
+
+#[test]
+fn e2e_field_cache_hits_repeated_same_type_reads() {
+    let (result, stats) = run_main_with_field_cache_stats(
+        r#"
+record Body
+  x: Float
+  vx: Float
+  mass: Float
+end
+
+cell main() -> Float
+  let b = Body(x: 1.0, vx: 0.5, mass: 2.0)
+  let mut total = 0.0
+  for i in range(0, 100)
+    total += b.mass * b.vx
+  end
+  return total
+end
+"#,
+    );
+    assert_eq!(result, Value::Float(100.0));
+    // Two read sites, each missing once and then hitting on every iteration.
+    assert_eq!(
+        stats,
+        FieldCacheStats {
+            hits: 198,
+            misses: 2
+        }
+    );
+}
+
+#[test]
+fn e2e_field_cache_misses_when_trait_object_layout_changes() {
+    let (result, stats) = run_main_with_field_cache_stats(
+        r#"
+trait Named
+  cell label(self: Self) -> String
+end
+
+record Square
+  side: Int
+  name: String
+end
+
+record Rect
+  w: Int
+  h: Int
+  name: String
+end
+
+impl Named for Square
+  cell label(self: Self) -> String
+    return "square"
+  end
+end
+
+impl Named for Rect
+  cell label(self: Self) -> String
+    return "rect"
+  end
+end
+
+cell total_name_length(shapes: list[Named]) -> Int
+  let mut total = 0
+  for s in shapes
+    total += len(s.name)
+  end
+  return total
+end
+
+cell main() -> Int
+  let shapes: list[Named] = [
+    Square(side: 1, name: "a"),
+    Square(side: 2, name: "bb"),
+    Rect(w: 1, h: 2, name: "ccc"),
+    Rect(w: 3, h: 4, name: "dddd"),
+    Square(side: 3, name: "eeeee")
+  ]
+  return total_name_length(shapes)
+end
+"#,
+    );
+    // `name` is the first of Square's key-ordered fields but the second of
+    // Rect's, so every change of record type re-primes the one read site.
+    assert_eq!(result, Value::Int(15));
+    assert_eq!(stats, FieldCacheStats { hits: 2, misses: 3 });
+}
+
+#[test]
+fn e2e_field_reads_match_with_the_field_cache_off() {
+    let md = r#"# e2e-test
+
+```lumen
+record Body
+  x: Float
+  vx: Float
+  mass: Float
+end
+
+cell main() -> Float
+  let b = Body(x: 1.0, vx: 0.5, mass: 2.0)
+  var total = 0.0
+  for i in range(0, 10)
+    total += b.mass * b.vx + b.x
+  end
+  return total
+end
+```
+"#;
+    let module = compile(md).expect("source should compile");
+    let mut vm = VM::new();
+    vm.set_field_cache(false);
+    vm.load(module);
+    let result = vm.execute("main", vec![]).expect("main should execute");
+    assert_eq!(result, Value::Float(20.0));
+    assert_eq!(vm.field_cache_stats(), FieldCacheStats::default());
+}
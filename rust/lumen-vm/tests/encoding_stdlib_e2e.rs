@@ -0,0 +1,133 @@
+mod common;
+
+use common::{owned, StdModule};
+use lumen_vm::values::Value;
+
+const STD_ENCODING: StdModule = StdModule {
+    import: "std.encoding",
+    path: "stdlib/std/encoding.lm.md",
+};
+
+/// Deterministic pseudo-random bytes from a 64-bit LCG.
+fn random_bytes(seed: u64, len: usize) -> Vec<u8> {
+    let mut state = seed;
+    (0..len)
+        .map(|_| {
+            state = state
+                .wrapping_mul(6364136223846793005)
+                .wrapping_add(1442695040888963407);
+            (state >> 56) as u8
+        })
+        .collect()
+}
+
+const ROUND_TRIP: &str = r#"
+import std.encoding: base64_encode, base64_decode, hex_encode, hex_decode
+
+cell via_base64(data: Bytes) -> Bytes
+  match base64_decode(base64_encode(data))
+    ok(b) -> return b
+    err(e) -> halt(e)
+  end
+end
+
+cell via_hex(data: Bytes) -> Bytes
+  match hex_decode(hex_encode(data))
+    ok(b) -> return b
+    err(e) -> halt(e)
+  end
+end
+"#;
+
+#[test]
+fn e2e_encoding_round_trips_random_bytes() {
+    let mut vm = STD_ENCODING.load(ROUND_TRIP);
+    // Every length up to a few quads covers each base64 padding case
+    for len in 0..40 {
+        let data = random_bytes(len as u64 + 1, len);
+        for codec in ["via_base64", "via_hex"] {
+            let out = vm
+                .execute(codec, vec![Value::Bytes(data.clone())])
+                .expect("round trip should execute");
+            assert_eq!(
+                out,
+                Value::Bytes(data.clone()),
+                "{} of {} bytes",
+                codec,
+                len
+            );
+        }
+    }
+}
+
+#[test]
+fn e2e_encoding_encodes_known_vectors() {
+    let source = r#"
+import std.encoding: base64_encode, hex_encode
+
+cell main() -> String
+  return base64_encode("foob") + " " + base64_encode("fooba") + " " + hex_encode("Lm") + " " + hex_encode(utf8_encode("é"))
+end
+"#;
+    assert_eq!(
+        STD_ENCODING.run_main(source),
+        owned("Zm9vYg== Zm9vYmE= 4c6d c3a9")
+    );
+}
+
+/// A program returning the error from decoding `input` with `decode`, or
+/// `"decoded"` when it succeeds.
+fn decode_error_source(decode: &str, input: &str) -> String {
+    format!(
+        r#"
+import std.encoding: {decode}
+
+cell main() -> String
+  match {decode}("{input}")
+    ok(b) -> return "decoded"
+    err(e) -> return e
+  end
+end
+"#
+    )
+}
+
+#[test]
+fn e2e_encoding_base64_decode_rejects_malformed_input() {
+    let cases = [
+        ("Zm9", "base64: invalid input length 3"),
+        ("Zm9v\\nYg==", "base64: invalid input length 9"),
+        ("Zm*v", "base64: invalid character '*' at offset 2"),
+        ("Zg==Zm8=", "base64: misplaced padding at offset 2"),
+        ("Z===", "base64: misplaced padding at offset 1"),
+    ];
+    for (input, expected) in cases {
+        let source = decode_error_source("base64_decode", input);
+        assert_eq!(
+            STD_ENCODING.run_main(&source),
+            owned(expected),
+            "decoding {:?}",
+            input
+        );
+    }
+}
+
+#[test]
+fn e2e_encoding_hex_decode_rejects_malformed_input() {
+    let cases = [
+        ("abc", "hex: invalid input length 3"),
+        ("0g", "hex: invalid character 'g' at offset 1"),
+        ("de ad", "hex: invalid character ' ' at offset 2"),
+    ];
+    for (input, expected) in cases {
+        let source = decode_error_source("hex_decode", input);
+        assert_eq!(
+            STD_ENCODING.run_main(&source),
+            owned(expected),
+            "decoding {:?}",
+            input
+        );
+    }
+    let source = decode_error_source("hex_decode", "DEADbeef");
+    assert_eq!(STD_ENCODING.run_main(&source), owned("decoded"));
+}
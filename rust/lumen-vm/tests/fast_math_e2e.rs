@@ -0,0 +1,92 @@
+//! `CompileOptions::fast_math` may change float results in the last bits.
+//! With it off, float programs match a reference computed in the same order
+//! bit for bit; with it on, they stay close to it.
+
+use lumen_compiler::{compile_raw_with_options, CompileOptions};
+use lumen_vm::vm::VM;
+
+/// Printed lines and result of `main` in `source`.
+fn run(source: &str, fast_math: bool) -> (Vec<String>, f64) {
+    let options = CompileOptions {
+        fast_math,
+        ..Default::default()
+    };
+    let module = compile_raw_with_options(source, &options).expect("source should compile");
+    let mut vm = VM::new();
+    vm.load(module);
+    let result = vm.execute("main", vec![]).expect("main should execute");
+    (vm.output, result.as_float().unwrap_or(f64::NAN))
+}
+
+/// Every fast-math rewrite applies to the loop body.
+const SPRING: &str = r#"
+cell main() -> Float
+  var x = 1.0
+  var v = 0.0
+  var energy = 0.0
+  for i in 0..1000
+    let a = 0.0 - x * 0.5 * 0.3
+    v = v + a * 0.01 / 3.0
+    x = x + v * 0.01 - 0.001 + 0.0007
+    energy = energy + v * v / 3.0 + x * x * 0.7 * 0.1
+  end
+  return energy
+end
+"#;
+
+fn spring_reference() -> f64 {
+    let (mut x, mut v, mut energy) = (1.0f64, 0.0f64, 0.0f64);
+    for _ in 0..1000 {
+        let a = 0.0 - x * 0.5 * 0.3;
+        v = v + a * 0.01 / 3.0;
+        x = x + v * 0.01 - 0.001 + 0.0007;
+        energy = energy + v * v / 3.0 + x * x * 0.7 * 0.1;
+    }
+    energy
+}
+
+#[test]
+fn fast_math_off_matches_the_reference_bit_for_bit() {
+    let (_, energy) = run(SPRING, false);
+    assert_eq!(energy.to_bits(), spring_reference().to_bits());
+}
+
+#[test]
+fn fast_math_on_stays_within_tolerance() {
+    let (_, energy) = run(SPRING, true);
+    let reference = spring_reference();
+    assert!(
+        (energy - reference).abs() <= 1e-9 * reference.abs(),
+        "fast math: {}, reference: {}",
+        energy,
+        reference
+    );
+}
+
+/// bench/cross-language/nbody, shortened to `steps` steps: the energy
+/// before and after, as printed.
+fn nbody(steps: u32, fast_math: bool) -> Vec<String> {
+    let path = std::path::PathBuf::from(env!("CARGO_MANIFEST_DIR"))
+        .join("../../bench/cross-language/nbody/nbody.lm");
+    let source = std::fs::read_to_string(&path)
+        .unwrap_or_else(|e| panic!("cannot read {}: {}", path.display(), e));
+    let source = source.replace(
+        "advance(x, y, z, vx, vy, vz, mass, 1000000)",
+        &format!("advance(x, y, z, vx, vy, vz, mass, {})", steps),
+    );
+    run(&source, fast_math).0
+}
+
+#[test]
+fn nbody_energy_stays_close_to_the_reference_with_fast_math() {
+    // The reference implementations' output for 1000 steps
+    let reference = ["-0.169075164", "-0.169087605"];
+    assert_eq!(nbody(1000, false), reference);
+
+    let energies = nbody(1000, true);
+    assert_eq!(energies.len(), reference.len());
+    for (got, want) in energies.iter().zip(reference) {
+        let (got, want): (f64, f64) = (got.parse().unwrap(), want.parse().unwrap());
+        assert!((got - want).abs() < 1e-6, "{} vs {}", got, want);
+    }
+}
@@ -0,0 +1,93 @@
+//! `set_finalizer(obj, f)` registers `f` to run once `obj` has been freed.
+//! Finalizers run only when `run_finalizers()` is called, in registration
+//! order; they receive nothing, so an object cannot be brought back.
+
+use lumen_compiler::compile;
+use lumen_vm::values::Value;
+use lumen_vm::vm::VM;
+
+fn load(source: &str) -> VM {
+    let md = format!("# finalizer-test\n\n```lumen\n{}\n```\n", source.trim());
+    let module = compile(&md).expect("source should compile");
+    let mut vm = VM::new();
+    vm.load(module);
+    vm
+}
+
+const HANDLES: &str = r#"
+record Handle
+  fd: Int
+end
+
+cell open_handle(fd: Int) -> Null
+  let h = Handle(fd: fd)
+  set_finalizer(h, fn() => print("closed {fd}"))
+  return null
+end
+"#;
+
+#[test]
+fn finalizer_runs_after_object_is_freed() {
+    let mut vm = load(&format!(
+        "{}\n{}",
+        HANDLES,
+        r#"
+cell main() -> Int
+  let kept = Handle(fd: 1)
+  set_finalizer(kept, fn() => print("closed 1"))
+  open_handle(2)
+  open_handle(3)
+  print("before")
+  let ran = run_finalizers()
+  print("ran {ran}")
+  return run_finalizers()
+end
+"#
+    ));
+    let result = vm.execute("main", vec![]).expect("main should execute");
+    // `kept` is still alive, so its finalizer has not run
+    assert_eq!(result, Value::Int(0));
+    assert_eq!(vm.output, vec!["before", "closed 2", "closed 3", "ran 2"]);
+
+    // Once `main` returns nothing holds `kept`
+    assert_eq!(vm.run_finalizers().expect("finalizers should run"), 1);
+    assert_eq!(vm.output.last().map(String::as_str), Some("closed 1"));
+    assert_eq!(vm.run_finalizers().expect("nothing left to run"), 0);
+}
+
+#[test]
+fn finalizer_capturing_its_object_is_rejected() {
+    let mut vm = load(
+        r#"
+record Handle
+  fd: Int
+end
+
+cell main() -> Int
+  let h = Handle(fd: 4)
+  set_finalizer(h, fn() => print("closing {h.fd}"))
+  return h.fd
+end
+"#,
+    );
+    let err = vm
+        .execute("main", vec![])
+        .expect_err("capturing callback should be rejected");
+    assert!(err.message_contains("must not capture"), "unexpected error: {}", err);
+}
+
+#[test]
+fn finalizer_of_scalar_is_rejected() {
+    let mut vm = load(
+        r#"
+cell main() -> Null
+  set_finalizer(5, fn() => print("never"))
+  return null
+end
+"#,
+    );
+    let err = vm
+        .execute("main", vec![])
+        .expect_err("scalar target should be rejected");
+    assert!(err.message_contains("set_finalizer expects"), "unexpected error: {}", err);
+}
@@ -0,0 +1,123 @@
+mod common;
+
+use common::StdModule;
+use lumen_vm::values::{StringRef, Value};
+use lumen_vm::vm::VM;
+
+const STD_FLAG: StdModule = StdModule {
+    import: "std.flag",
+    path: "stdlib/std/flag.lm.md",
+};
+
+fn run_raw_main_with_std_flag(source: &str, program_args: &[&str]) -> Value {
+    let mut vm = VM::new();
+    vm.set_program_args(program_args.iter().copied());
+    vm.load(STD_FLAG.compile(source));
+    vm.execute("main", vec![]).expect("main should execute")
+}
+
+fn as_string(value: &Value) -> String {
+    match value {
+        Value::String(StringRef::Owned(s)) => s.clone(),
+        Value::Union(u) => as_string(&u.payload),
+        other => panic!("expected string, got {:?}", other),
+    }
+}
+
+const BENCH_FLAGS: &str = r#"
+import std.flag: int_flag, bool_flag, string_flag, parse, program_args, get_int, get_bool, get_string
+
+cell main() -> String
+  let specs = [
+    int_flag("size", 100, "problem size"),
+    int_flag("depth", 4),
+    bool_flag("verbose"),
+    string_flag("mode", "fast")
+  ]
+  match parse(specs, program_args())
+    ok(f) ->
+      let size = get_int(f, "size")
+      let depth = get_int(f, "depth")
+      let verbose = get_bool(f, "verbose")
+      let mode = get_string(f, "mode")
+      let rest = join(f.positional, "|")
+      return "size={size} depth={depth} verbose={verbose} mode={mode} rest={rest}"
+    err(msg) -> return "error: " + msg
+  end
+end
+"#;
+
+#[test]
+fn e2e_flag_defaults_when_no_args() {
+    let result = as_string(&run_raw_main_with_std_flag(BENCH_FLAGS, &[]));
+    assert_eq!(result, "size=100 depth=4 verbose=false mode=fast rest=");
+}
+
+#[test]
+fn e2e_flag_mixed_flags_and_positional_args() {
+    let result = as_string(&run_raw_main_with_std_flag(
+        BENCH_FLAGS,
+        &[
+            "-size",
+            "5000",
+            "--depth=12",
+            "-verbose",
+            "input.txt",
+            "-mode",
+            "x",
+        ],
+    ));
+    // Parsing stops at the first positional argument.
+    assert_eq!(
+        result,
+        "size=5000 depth=12 verbose=true mode=fast rest=input.txt|-mode|x"
+    );
+}
+
+#[test]
+fn e2e_flag_double_dash_ends_flags() {
+    let result = as_string(&run_raw_main_with_std_flag(
+        BENCH_FLAGS,
+        &["-mode=slow", "--", "-size", "1"],
+    ));
+    assert_eq!(
+        result,
+        "size=100 depth=4 verbose=false mode=slow rest=-size|1"
+    );
+}
+
+#[test]
+fn e2e_flag_unknown_flag_is_an_error() {
+    let result = as_string(&run_raw_main_with_std_flag(
+        BENCH_FLAGS,
+        &["-size", "10", "-width", "3"],
+    ));
+    assert_eq!(result, "error: flag provided but not defined: -width");
+}
+
+#[test]
+fn e2e_flag_invalid_and_missing_values_are_errors() {
+    let invalid = as_string(&run_raw_main_with_std_flag(BENCH_FLAGS, &["-size", "big"]));
+    assert_eq!(invalid, "error: invalid value \"big\" for flag -size");
+
+    let missing = as_string(&run_raw_main_with_std_flag(BENCH_FLAGS, &["-depth"]));
+    assert_eq!(missing, "error: flag needs an argument: -depth");
+}
+
+#[test]
+fn e2e_flag_int_values_accept_prefixes_and_reject_overflow() {
+    let result = as_string(&run_raw_main_with_std_flag(
+        BENCH_FLAGS,
+        &["-size=0x400", "-depth", "-0b11"],
+    ));
+    assert_eq!(result, "size=1024 depth=-3 verbose=false mode=fast rest=");
+
+    let overflow = as_string(&run_raw_main_with_std_flag(
+        BENCH_FLAGS,
+        &["-size", "9223372036854775808"],
+    ));
+    assert_eq!(
+        overflow,
+        "error: invalid value \"9223372036854775808\" for flag -size"
+    );
+}
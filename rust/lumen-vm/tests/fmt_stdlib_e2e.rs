@@ -0,0 +1,251 @@
+mod common;
+
+use common::{as_string, StdModule};
+use lumen_vm::values::Value;
+
+const STD_FMT: StdModule = StdModule {
+    import: "std.fmt",
+    path: "stdlib/std/fmt.lm.md",
+};
+
+// Expected rows are Go's `fmt.Sprintf("%.*f", p, x)` for p = 0, 2, 6, 9.
+#[test]
+fn e2e_fmt_matches_go_fixed_precision() {
+    let source = r#"
+import std.fmt: format
+
+cell main() -> String
+  let values = [
+    0.0, -0.0, 0.5, 1.5, 2.5, -2.5, 0.125, -0.375,
+    1.0e-10, -4.9999999e-10, 5.0e-10, 0.0000005, 0.0000015, 2.5e-9,
+    3.141592653589793, -0.169075164, 1234.5678905, 9.9999999995,
+    1.0e21, -123456789012345678.0, 5.0e-324
+  ]
+  let mut rows = []
+  for x in values
+    let mut cols = []
+    for p in [0, 2, 6, 9]
+      cols = append(cols, format(x, p))
+    end
+    rows = append(rows, join(cols, " "))
+  end
+  return join(rows, "\n")
+end
+"#;
+
+    let expected = [
+        "0 0.00 0.000000 0.000000000",
+        "-0 -0.00 -0.000000 -0.000000000",
+        "0 0.50 0.500000 0.500000000",
+        "2 1.50 1.500000 1.500000000",
+        "2 2.50 2.500000 2.500000000",
+        "-2 -2.50 -2.500000 -2.500000000",
+        "0 0.12 0.125000 0.125000000",
+        "-0 -0.38 -0.375000 -0.375000000",
+        "0 0.00 0.000000 0.000000000",
+        "-0 -0.00 -0.000000 -0.000000000",
+        "0 0.00 0.000000 0.000000001",
+        "0 0.00 0.000000 0.000000500",
+        "0 0.00 0.000002 0.000001500",
+        "0 0.00 0.000000 0.000000003",
+        "3 3.14 3.141593 3.141592654",
+        "-0 -0.17 -0.169075 -0.169075164",
+        "1235 1234.57 1234.567890 1234.567890500",
+        "10 10.00 10.000000 9.999999999",
+        "1000000000000000000000 1000000000000000000000.00 \
+         1000000000000000000000.000000 1000000000000000000000.000000000",
+        "-123456789012345680 -123456789012345680.00 \
+         -123456789012345680.000000 -123456789012345680.000000000",
+        "0 0.00 0.000000 0.000000000",
+    ];
+    assert_eq!(as_string(&STD_FMT.run_main(source)), expected.join("\n"));
+}
+
+#[test]
+fn e2e_fmt_non_finite_and_int_values() {
+    let source = r#"
+import std.fmt: format
+
+cell main() -> String
+  let parts = [format(INFINITY, 9), format(-INFINITY, 9), format(NAN, 6), format(42, 3)]
+  return join(parts, " ")
+end
+"#;
+
+    assert_eq!(as_string(&STD_FMT.run_main(source)), "+Inf -Inf NaN 42.000");
+}
+
+// Expected bits are Go's `math.Float64bits(strconv.ParseFloat(s, 64))`.
+#[test]
+fn e2e_fmt_parse_matches_go_bits() {
+    let cases: [(&str, u64); 12] = [
+        ("4.84143144246472090e+00", 0x40135da0343cd92c),
+        ("-1.16032004402742839e+00", 0xbff290abc01fdb7c),
+        ("-1.03622044471123109e-01", 0xbfba86f96c25ebf0),
+        ("8.34336671824457987e+00", 0x4020afcdc332ca67),
+        ("-2.59193146099879641e+01", 0xc039eb5833c8a220),
+        ("1.66007664274403694e-03", 0x3f5b32ddb8ec9209),
+        ("-6.90460016972063023e-05", 0xbf12199946debd80),
+        ("9.54791938424326609e-04", 0x3f4f49601333c135),
+        ("5.15138902046611451e-05", 0x3f0b0213ca2d0eec),
+        ("2.2250738585072011e-308", 0x000fffffffffffff),
+        ("2.4703282292062328e-324", 0x0000000000000001),
+        ("2.4703282292062327e-324", 0x0000000000000000),
+    ];
+    let inputs: Vec<String> = cases.iter().map(|(s, _)| format!("\"{}\"", s)).collect();
+    let source = format!(
+        r#"
+import std.fmt: parse
+
+cell main() -> list[Float]
+  let mut out = []
+  for s in [{}]
+    match parse(s)
+      ok(f) -> out = append(out, f)
+      err(e) -> halt(e)
+    end
+  end
+  return out
+end
+"#,
+        inputs.join(", ")
+    );
+
+    let result = STD_FMT.run_main(&source);
+    let parsed = result.as_list().expect("main should return a list");
+    assert_eq!(parsed.len(), cases.len());
+    for ((text, bits), value) in cases.iter().zip(parsed.iter()) {
+        match value {
+            Value::Float(f) => assert_eq!(f.to_bits(), *bits, "parsing {}", text),
+            other => panic!("parsing {} gave {:?}", text, other),
+        }
+    }
+}
+
+#[test]
+fn e2e_fmt_parse_rejects_malformed_and_out_of_range() {
+    let source = r#"
+import std.fmt: parse
+
+cell main() -> String
+  let mut errors = []
+  for s in ["", "1e", "1.5.2", "abc", "1e400", "-1e400"]
+    match parse(s)
+      ok(f) -> errors = append(errors, "parsed {f}")
+      err(e) -> errors = append(errors, e)
+    end
+  end
+  return join(errors, "; ")
+end
+"#;
+
+    assert_eq!(
+        as_string(&STD_FMT.run_main(source)),
+        "invalid float: ; invalid float: 1e; invalid float: 1.5.2; invalid float: abc; \
+         float out of range: 1e400; float out of range: -1e400"
+    );
+}
+
+const PARSE_INT_RUNNER: &str = r#"
+import std.fmt: parse_int
+
+cell run(inputs: list[String], base: Int) -> String
+  let mut out = []
+  for s in inputs
+    match parse_int(s, base)
+      ok(n) -> out = append(out, string(n))
+      err(e) -> out = append(out, e)
+    end
+  end
+  return join(out, "; ")
+end
+"#;
+
+fn run_parse_int_cases(main_body: &str) -> String {
+    let source = format!(
+        "{}\ncell main() -> String\n  return join([{}], \" | \")\nend\n",
+        PARSE_INT_RUNNER, main_body
+    );
+    as_string(&STD_FMT.run_main(&source))
+}
+
+#[test]
+fn e2e_fmt_parse_int_bases_and_whitespace() {
+    let result = run_parse_int_cases(
+        r#"
+    run(["42", "-17", "+0", "  123\t", "\n-9 "], 10),
+    run(["ff", "0x1F", "-0XfF", "0b1"], 16),
+    run(["1011", "0b1011", "-0b111"], 2),
+    run(["0o17", "777"], 8),
+    run(["zz", "Lumen"], 36),
+    run(["0x10", "0b10", "0o10", "010"], 0)
+"#,
+    );
+    assert_eq!(
+        result,
+        "42; -17; 0; 123; -9 | 255; 31; -255; 177 | 11; 11; -7 | 15; 511 | 1295; 36700655 \
+         | 16; 2; 8; 10"
+    );
+}
+
+#[test]
+fn e2e_fmt_parse_int_rejects_overflow_and_malformed() {
+    let result = run_parse_int_cases(
+        r#"
+    run(["9223372036854775807", "-9223372036854775808"], 10),
+    run(["9223372036854775808", "-9223372036854775809"], 10),
+    run(["0x7fffffffffffffff", " 0x8000000000000000 "], 16),
+    run(["", "-", "--5", "1 2", "0x10"], 10),
+    run(["0x", "0x-5"], 16),
+    run(["12"], 2),
+    run(["10"], 1),
+    run(["10"], 37)
+"#,
+    );
+    let expected = [
+        "9223372036854775807; -9223372036854775808",
+        "integer out of range: 9223372036854775808; \
+         integer out of range: -9223372036854775809",
+        "9223372036854775807; integer out of range: 0x8000000000000000",
+        "invalid integer: ; invalid integer: -; invalid integer: --5; \
+         invalid integer: 1 2; invalid integer: 0x10",
+        "invalid integer: 0x; invalid integer: 0x-5",
+        "invalid integer: 12",
+        "invalid base 1: must be 0 or 2 through 36",
+        "invalid base 37: must be 0 or 2 through 36",
+    ];
+    assert_eq!(result, expected.join(" | "));
+}
+
+// Expected columns are Go's `strconv.FormatInt(x, 10)` and
+// `strconv.FormatUint(uint64(x), 10)`.
+#[test]
+fn e2e_fmt_format_int_matches_go_strconv() {
+    let source = r#"
+import std.fmt: format_int, format_uint
+
+cell main() -> String
+  let values = [0, 1, -1, 9, 10, -10, 4294967295, 4294967296, 9223372036854775807, -9223372036854775807, -9223372036854775808]
+  var rows = []
+  for x in values
+    rows = append(rows, format_int(x) + " " + format_uint(x))
+  end
+  return join(rows, "\n")
+end
+"#;
+
+    let expected = [
+        "0 0",
+        "1 1",
+        "-1 18446744073709551615",
+        "9 9",
+        "10 10",
+        "-10 18446744073709551606",
+        "4294967295 4294967295",
+        "4294967296 4294967296",
+        "9223372036854775807 9223372036854775807",
+        "-9223372036854775807 9223372036854775809",
+        "-9223372036854775808 9223372036854775808",
+    ];
+    assert_eq!(as_string(&STD_FMT.run_main(source)), expected.join("\n"));
+}
@@ -0,0 +1,181 @@
+mod common;
+
+use std::fs;
+
+use common::{owned, scratch_dir, StdModule};
+use lumen_vm::values::Value;
+
+const STD_FS: StdModule = StdModule {
+    import: "std.fs",
+    path: "stdlib/std/fs.lm.md",
+};
+
+#[test]
+fn e2e_fs_write_then_read_round_trips() {
+    let dir = scratch_dir("fs", "roundtrip");
+    let path = dir.join("data.txt");
+    let source = format!(
+        r#"
+import std.fs: write_text, read_text
+
+cell main() -> String
+  match write_text("{path}", "line one\nline two\n")
+    ok(_) -> null
+    err(e) -> return "write failed: " + e.message
+  end
+  match read_text("{path}")
+    ok(text) -> return text
+    err(e) -> return "read failed: " + e.message
+  end
+end
+"#,
+        path = path.display()
+    );
+
+    assert_eq!(STD_FS.run_main(&source), owned("line one\nline two\n"));
+    assert_eq!(fs::read_to_string(&path).unwrap(), "line one\nline two\n");
+    let _ = fs::remove_dir_all(&dir);
+}
+
+#[test]
+fn e2e_fs_read_text_decodes_utf8() {
+    let dir = scratch_dir("fs", "utf8");
+    let path = dir.join("greeting.txt");
+    fs::write(&path, "héllo ✓\n").expect("write fixture");
+    let source = format!(
+        r#"
+import std.fs: read_text
+
+cell main() -> String
+  match read_text("{path}")
+    ok(text) -> return text
+    err(e) -> return "read failed: " + e.message
+  end
+end
+"#,
+        path = path.display()
+    );
+
+    assert_eq!(STD_FS.run_main(&source), owned("héllo ✓\n"));
+    let _ = fs::remove_dir_all(&dir);
+}
+
+#[test]
+fn e2e_fs_read_text_rejects_invalid_utf8() {
+    let dir = scratch_dir("fs", "badutf8");
+    let path = dir.join("latin1.txt");
+    fs::write(&path, [b'c', b'a', b'f', 0xE9]).expect("write fixture");
+    let source = format!(
+        r#"
+import std.fs: read_text
+
+cell main() -> String
+  match read_text("{path}")
+    ok(_) -> return "unexpected success"
+    err(e) -> return e.kind + ": " + e.message
+  end
+end
+"#,
+        path = path.display()
+    );
+
+    assert_eq!(
+        STD_FS.run_main(&source),
+        owned("invalid_utf8: invalid UTF-8 at byte 3")
+    );
+    let _ = fs::remove_dir_all(&dir);
+}
+
+#[test]
+fn e2e_fs_missing_file_reports_not_found() {
+    let dir = scratch_dir("fs", "missing");
+    let path = dir.join("does-not-exist.txt");
+    let source = format!(
+        r#"
+import std.fs: read_file_bytes, is_not_found
+
+cell main() -> String
+  match read_file_bytes("{path}")
+    ok(_) -> return "unexpected success"
+    err(e) ->
+      if is_not_found(e)
+        return e.kind
+      end
+      return "wrong kind: " + e.kind
+  end
+end
+"#,
+        path = path.display()
+    );
+
+    assert_eq!(STD_FS.run_main(&source), owned("not_found"));
+    let _ = fs::remove_dir_all(&dir);
+}
+
+#[test]
+fn e2e_fs_write_into_missing_directory_is_an_error() {
+    let dir = scratch_dir("fs", "nodir");
+    let path = dir.join("no-such-dir").join("out.txt");
+    let source = format!(
+        r#"
+import std.fs: write_text
+
+cell main() -> String
+  match write_text("{path}", "x")
+    ok(_) -> return "unexpected success"
+    err(e) -> return e.kind
+  end
+end
+"#,
+        path = path.display()
+    );
+
+    assert_eq!(STD_FS.run_main(&source), owned("not_found"));
+    let _ = fs::remove_dir_all(&dir);
+}
+
+#[test]
+fn e2e_fs_buffered_writer_and_reader_round_trip() {
+    let dir = scratch_dir("fs", "buffered");
+    let path = dir.join("buffered.txt");
+    let source = format!(
+        r#"
+import std.fs: FsError, create_writer, write_string, flush_writer, open_reader, read_chunk
+
+cell main() -> result[String, FsError]
+  let mut w = create_writer("{path}", 8)?
+  let mut i = 0
+  while i < 5
+    w = write_string(w, "chunk-{{i}};")?
+    i += 1
+  end
+  w = flush_writer(w)?
+
+  let mut r = open_reader("{path}", 7)?
+  let mut text = ""
+  let mut reads = 0
+  loop
+    let (next, data) = read_chunk(r)?
+    if bytes_len(data) == 0
+      break
+    end
+    text = text + bytes_to_ascii(data)
+    reads += 1
+    r = next
+  end
+  return ok("{{reads}}:" + text)
+end
+"#,
+        path = path.display()
+    );
+
+    let expected = "chunk-0;chunk-1;chunk-2;chunk-3;chunk-4;";
+    let payload = match STD_FS.run_main(&source) {
+        Value::Union(u) => (*u.payload).clone(),
+        other => panic!("expected result union, got {:?}", other),
+    };
+    // 40 bytes read 7 at a time takes 6 non-empty reads.
+    assert_eq!(payload, owned(&format!("6:{}", expected)));
+    assert_eq!(fs::read_to_string(&path).unwrap(), expected);
+    let _ = fs::remove_dir_all(&dir);
+}
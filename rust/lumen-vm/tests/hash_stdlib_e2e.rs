@@ -0,0 +1,82 @@
+mod common;
+
+use common::{as_string, StdModule};
+
+const STD_HASH: StdModule = StdModule {
+    import: "std.hash",
+    path: "stdlib/std/hash.lm.md",
+};
+
+#[test]
+fn e2e_hash_reference_vectors() {
+    let source = r#"
+import std.hash: fnv1a_hex, crc32_hex, sha256_hex
+
+cell main() -> String
+  let parts = [
+    fnv1a_hex("foobar"),
+    crc32_hex("123456789"),
+    sha256_hex("abc"),
+    sha256_hex("")
+  ]
+  return join(parts, " ")
+end
+"#;
+
+    assert_eq!(
+        as_string(&STD_HASH.run_main(source)),
+        "85944171f73967e8 cbf43926 \
+         ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad \
+         e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+    );
+}
+
+#[test]
+fn e2e_hash_incremental_writes_match_single_write() {
+    let source = r#"
+import std.hash: new_hasher, write, sum_hex
+
+cell main() -> String
+  let text = "The quick brown fox jumps over the lazy dog, then does it again for good measure."
+  let mut mismatches = []
+  for name in ["fnv1a", "crc32", "sha256"]
+    match new_hasher(name)
+      ok(h) ->
+        let whole = sum_hex(write(h, text))
+        let mut pieces = h
+        let mut i = 0
+        while i < len(text)
+          pieces = write(pieces, slice(text, i, i + 7))
+          i += 7
+        end
+        if sum_hex(pieces) != whole
+          mismatches = append(mismatches, name)
+        end
+      err(e) -> return e
+    end
+  end
+  return "mismatches=" + join(mismatches, ",")
+end
+"#;
+
+    assert_eq!(as_string(&STD_HASH.run_main(source)), "mismatches=");
+}
+
+#[test]
+fn e2e_hash_unknown_algorithm_is_an_error() {
+    let source = r#"
+import std.hash: new_hasher
+
+cell main() -> String
+  match new_hasher("md5")
+    ok(_) -> return "created"
+    err(e) -> return e
+  end
+end
+"#;
+
+    assert_eq!(
+        as_string(&STD_HASH.run_main(source)),
+        "unknown hash algorithm: md5"
+    );
+}
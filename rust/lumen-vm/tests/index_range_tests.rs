@@ -0,0 +1,137 @@
+//! Indexing past the end of a list, tuple, or set fails with the index and
+//! the valid range, in Go's form: `index 5 out of range [0:3]`.
+
+use lumen_compiler::compile;
+use lumen_vm::values::{StringRef, Value};
+use lumen_vm::vm::{VmError, VM};
+
+fn run(source: &str) -> Result<Value, VmError> {
+    let md = format!("# index-range-test\n\n```lumen\n{}\n```\n", source.trim());
+    let module = compile(&md).expect("source should compile");
+    let mut vm = VM::new();
+    vm.load(module);
+    vm.execute("main", vec![])
+}
+
+/// Run `main`, which must fail, and return the error's message.
+fn failure(source: &str) -> String {
+    let err = run(source).expect_err("main should fail");
+    assert!(err.panic_message().is_some(), "not recoverable: {}", err);
+    err.to_string()
+}
+
+#[test]
+fn out_of_range_read_names_index_and_length() {
+    let msg = failure(
+        r#"
+cell at(xs: list[Int], i: Int) -> Int
+  return xs[i]
+end
+
+cell main() -> Int
+  return at([10, 20, 30], 5)
+end
+"#,
+    );
+    assert!(msg.contains("index 5 out of range [0:3]"), "{}", msg);
+}
+
+#[test]
+fn read_one_past_the_end_fails() {
+    let msg = failure(
+        r#"
+cell main() -> Int
+  var xs = []
+  var total = 0
+  for i in 0..4
+    xs = append(xs, i)
+  end
+  for i in 0..5
+    total = total + xs[i]
+  end
+  return total
+end
+"#,
+    );
+    assert!(msg.contains("index 4 out of range [0:4]"), "{}", msg);
+}
+
+#[test]
+fn out_of_range_write_names_index_and_length() {
+    let msg = failure(
+        r#"
+cell main() -> list[Int]
+  let mut xs = [1, 2, 3]
+  let i = len(xs)
+  xs[i] = 4
+  return xs
+end
+"#,
+    );
+    assert!(msg.contains("index 3 out of range [0:3]"), "{}", msg);
+}
+
+#[test]
+fn recover_reports_the_out_of_range_write() {
+    let result = run(r#"
+cell poke(i: Int) -> Null
+  let mut xs = [1, 2, 3]
+  xs[i] = 0
+  return null
+end
+
+cell main() -> String
+  match recover(fn() => poke(7))
+    ok(_) -> return "no panic"
+    err(msg) -> return msg
+  end
+end
+"#)
+    .expect("recover should catch the failure");
+    assert_eq!(
+        result,
+        Value::String(StringRef::Owned(
+            "runtime error: index 7 out of range [0:3]".into()
+        ))
+    );
+}
+
+#[test]
+fn negative_index_range_starts_at_minus_the_length() {
+    let msg = failure(
+        r#"
+cell main() -> Int
+  let xs = [10, 20, 30]
+  let i = 0 - 4
+  return xs[i]
+end
+"#,
+    );
+    assert!(msg.contains("index -4 out of range [-3:3]"), "{}", msg);
+
+    let last = run(r#"
+cell main() -> Int
+  let xs = [10, 20, 30]
+  let i = 0 - 3
+  return xs[i]
+end
+"#)
+    .expect("-3 is the first element");
+    assert_eq!(last, Value::Int(10));
+}
+
+#[test]
+fn out_of_range_tuple_read_names_index_and_length() {
+    let msg = failure(
+        r#"
+cell pick(t: tuple[Int, Int], i: Int) -> Int
+  return t[i]
+end
+
+cell main() -> Int
+  return pick((1, 2), 2)
+end
+"#,
+    );
+    assert!(msg.contains("index 2 out of range [0:2]"), "{}", msg);
+}
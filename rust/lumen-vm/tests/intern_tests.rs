@@ -0,0 +1,63 @@
+//! `intern(s)` returns the VM's shared copy of `s`: equal strings intern to
+//! the same table entry, and distinct strings to distinct ones.
+
+use lumen_compiler::compile;
+use lumen_vm::values::{StringRef, Value};
+use lumen_vm::vm::VM;
+
+fn markdown(source: &str) -> String {
+    format!("# intern-test\n\n```lumen\n{}\n```\n", source.trim())
+}
+
+/// The table id of an interned string result.
+fn interned_id(value: &Value) -> u32 {
+    match value {
+        Value::String(StringRef::Interned(id)) => *id,
+        other => panic!("expected an interned string, got {:?}", other),
+    }
+}
+
+#[test]
+fn interned_copies_of_equal_strings_share_an_entry() {
+    let md = markdown(
+        r#"
+cell main() -> tuple[String, String, String, Bool]
+  let built = "ab" + "c"
+  return (intern(built), intern("abc"), intern("abd"), intern(built) == "abc")
+end
+"#,
+    );
+    let mut vm = VM::new();
+    vm.load(compile(&md).expect("source should compile"));
+    let result = vm.execute("main", vec![]).expect("main should execute");
+    let Value::Tuple(items) = result else {
+        panic!("expected a tuple, got {:?}", result);
+    };
+
+    let (built, literal, other) = (
+        interned_id(&items[0]),
+        interned_id(&items[1]),
+        interned_id(&items[2]),
+    );
+    assert_eq!(built, literal, "equal contents should share one entry");
+    assert_ne!(built, other, "distinct contents should not collide");
+    assert_eq!(vm.strings.resolve(built), Some("abc"));
+    assert_eq!(vm.strings.resolve(other), Some("abd"));
+    assert_eq!(items[3], Value::Bool(true));
+}
+
+#[test]
+fn intern_is_idempotent() {
+    let md = markdown(
+        r#"
+cell main() -> Bool
+  let s = intern("lumen")
+  return intern(s) == s
+end
+"#,
+    );
+    let mut vm = VM::new();
+    vm.load(compile(&md).expect("source should compile"));
+    let result = vm.execute("main", vec![]).expect("main should execute");
+    assert_eq!(result, Value::Bool(true));
+}
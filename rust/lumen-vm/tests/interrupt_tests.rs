@@ -0,0 +1,126 @@
+//! An `InterruptHandle` stops a running VM from another thread at its next
+//! loop back-edge or call.
+
+use std::fs;
+use std::path::PathBuf;
+use std::thread;
+use std::time::{Duration, Instant};
+
+use lumen_compiler::{compile, compile_raw};
+use lumen_vm::values::Value;
+use lumen_vm::vm::{VmError, VM};
+
+fn load(source: &str) -> VM {
+    let md = format!("# interrupt-test\n\n```lumen\n{}\n```\n", source.trim());
+    let module = compile(&md).expect("source should compile");
+    let mut vm = VM::new();
+    vm.load(module);
+    vm
+}
+
+/// Most time an interrupted run may take to stop.
+const STOP_WITHIN: Duration = Duration::from_secs(2);
+
+/// Run `cell`, interrupting it from another thread after `delay`. Returns
+/// the outcome and how long the run went on after the interrupt.
+fn run_and_interrupt(
+    vm: &mut VM,
+    cell: &str,
+    delay: Duration,
+) -> (Result<Value, VmError>, Duration) {
+    let handle = vm.interrupt_handle();
+    let interrupter = thread::spawn(move || {
+        thread::sleep(delay);
+        handle.interrupt();
+        Instant::now()
+    });
+    let outcome = vm.execute(cell, vec![]);
+    let stopped = Instant::now();
+    let interrupted_at = interrupter.join().expect("interrupter thread");
+    (outcome, stopped.saturating_duration_since(interrupted_at))
+}
+
+fn assert_interrupted(outcome: Result<Value, VmError>, lag: Duration) {
+    let err = outcome.expect_err("the run should have been interrupted");
+    assert!(err.is_interrupted(), "{}", err);
+    assert!(lag < STOP_WITHIN, "took {:?} to stop", lag);
+}
+
+#[test]
+fn interrupt_stops_the_nbody_benchmark() {
+    // bench/cross-language/nbody's million-step advance loop
+    let manifest_dir = PathBuf::from(env!("CARGO_MANIFEST_DIR"));
+    let path = manifest_dir.join("../../bench/cross-language/nbody/nbody.lm");
+    let source = fs::read_to_string(&path)
+        .unwrap_or_else(|e| panic!("cannot read {}: {}", path.display(), e));
+    let mut vm = VM::new();
+    vm.load(compile_raw(&source).expect("nbody should compile"));
+
+    let (outcome, lag) = run_and_interrupt(&mut vm, "main", Duration::from_millis(200));
+    assert_interrupted(outcome, lag);
+    // Only the initial energy was printed; the simulation never finished
+    assert_eq!(vm.output.len(), 1, "{:?}", vm.output);
+}
+
+#[test]
+fn interrupt_stops_a_loop_that_never_ends() {
+    let mut vm = load(
+        r#"
+cell main() -> Int
+  var i = 0
+  while i >= 0
+    i = i + 1
+  end
+  return i
+end
+"#,
+    );
+    let (outcome, lag) = run_and_interrupt(&mut vm, "main", Duration::from_millis(50));
+    assert_interrupted(outcome, lag);
+}
+
+#[test]
+fn interrupt_stops_recursion_without_loops() {
+    let mut vm = load(
+        r#"
+cell fib(n: Int) -> Int
+  if n < 2
+    return n
+  end
+  return fib(n - 1) + fib(n - 2)
+end
+
+cell main() -> Int
+  return fib(60)
+end
+"#,
+    );
+    let (outcome, lag) = run_and_interrupt(&mut vm, "main", Duration::from_millis(50));
+    assert_interrupted(outcome, lag);
+}
+
+#[test]
+fn an_interrupted_vm_runs_again() {
+    let mut vm = load(
+        r#"
+cell count(n: Int) -> Int
+  var total = 0
+  for i in 0..n
+    total = total + i
+  end
+  return total
+end
+"#,
+    );
+    // A pending interrupt stops the next run at its first safepoint, once
+    vm.interrupt_handle().interrupt();
+    let err = vm
+        .execute("count", vec![Value::Int(10)])
+        .expect_err("the interrupt was pending");
+    assert!(err.is_interrupted(), "{}", err);
+
+    let total = vm
+        .execute("count", vec![Value::Int(10)])
+        .expect("count should execute");
+    assert_eq!(total, Value::Int(45));
+}
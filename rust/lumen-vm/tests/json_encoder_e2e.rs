@@ -0,0 +1,179 @@
+use std::fs;
+use std::path::PathBuf;
+
+use lumen_compiler::compile_raw_with_imports;
+use lumen_vm::values::{StringRef, Value};
+use lumen_vm::vm::VM;
+
+fn std_json_module_source() -> String {
+    let manifest_dir = PathBuf::from(env!("CARGO_MANIFEST_DIR"));
+    let path = manifest_dir.join("../../stdlib/std/json.lm.md");
+    fs::read_to_string(&path).unwrap_or_else(|e| panic!("cannot read {}: {}", path.display(), e))
+}
+
+/// `ENCODE` followed by `body`, run with std.json imported.
+fn run_raw_main_with_std_json(body: &str) -> Value {
+    let source = format!("{}\n{}", ENCODE, body);
+    let json_source = std_json_module_source();
+    let module = compile_raw_with_imports(&source, &|module| {
+        if module == "std.json" {
+            Some(json_source.clone())
+        } else {
+            None
+        }
+    })
+    .expect("raw source should compile with std.json");
+    let mut vm = VM::new();
+    vm.load(module);
+    vm.execute("main", vec![]).expect("main should execute")
+}
+
+/// `n` objects written member by member (in key order, so the text matches
+/// `marshal`), and the same objects built as one list.
+const ENCODE: &str = r#"
+import std.json: Encoder, new_encoder, begin_array, end_array, begin_object, end_object, field, value, close_encoder, marshal
+
+cell encode(writer: String, n: Int) -> result[Encoder, String]
+  var e = new_encoder(writer, 1024)?
+  e = begin_array(e)?
+  var i = 0
+  while i < n
+    e = begin_object(e)?
+    e = field(e, "id")?
+    e = value(e, i)?
+    e = field(e, "name")?
+    e = value(e, "item \"{i}\"")?
+    e = field(e, "tags")?
+    e = begin_array(e)?
+    e = value(e, i * 2)?
+    e = value(e, i % 7 == 0)?
+    e = end_array(e)?
+    e = end_object(e)?
+    i = i + 1
+  end
+  e = end_array(e)?
+  return close_encoder(e)
+end
+
+cell built(n: Int) -> String
+  var items = []
+  var i = 0
+  while i < n
+    items = append(items, {"id": i, "name": "item \"{i}\"", "tags": [i * 2, i % 7 == 0]})
+    i = i + 1
+  end
+  return marshal(items)
+end
+"#;
+
+fn owned(s: &str) -> Value {
+    Value::String(StringRef::Owned(s.to_string()))
+}
+
+fn items(v: Value) -> Vec<Value> {
+    match v {
+        Value::List(items) => items.as_ref().clone(),
+        Value::Tuple(items) => items.as_ref().clone(),
+        other => panic!("expected a list, got {:?}", other),
+    }
+}
+
+fn result_payload(v: &Value) -> Value {
+    match v {
+        Value::Union(u) => (*u.payload).clone(),
+        other => panic!("expected a result, got {:?}", other),
+    }
+}
+
+fn as_str(v: &Value) -> &str {
+    match v {
+        Value::String(StringRef::Owned(s)) => s,
+        other => panic!("expected a string, got {:?}", other),
+    }
+}
+
+#[test]
+fn e2e_json_encoder_matches_marshal_on_a_large_array() {
+    let results = items(run_raw_main_with_std_json(
+        r#"
+cell main() -> tuple[String, String]
+  match encode("memory", 3000)
+    ok(e) -> return (e.text, built(3000))
+    err(msg) -> return (msg, "")
+  end
+end
+"#,
+    ));
+
+    let (streamed, marshalled) = (as_str(&results[0]), as_str(&results[1]));
+    // Far more than one buffer's worth, so it was flushed many times
+    assert!(streamed.len() > 100_000, "{}", streamed.len());
+    assert!(streamed.starts_with(r#"[{"id":0,"name":"item \"0\"","tags":[0,true]},"#));
+    assert_eq!(streamed, marshalled);
+}
+
+#[test]
+fn e2e_json_encoder_streams_to_a_file() {
+    let dir = std::env::temp_dir().join(format!("lumen-json-encoder-{}", std::process::id()));
+    let _ = fs::remove_dir_all(&dir);
+    fs::create_dir_all(&dir).expect("create scratch dir");
+    let path = dir.join("results.json");
+
+    let results = items(run_raw_main_with_std_json(&format!(
+        r#"
+cell main() -> tuple[String, String]
+  match encode("file:{}", 500)
+    ok(e) -> return (e.text, built(500))
+    err(msg) -> return (msg, "")
+  end
+end
+"#,
+        path.display()
+    )));
+
+    // A file encoder keeps nothing in memory
+    assert_eq!(results[0], owned(""));
+    let written = fs::read_to_string(&path).expect("encoder should write the file");
+    assert_eq!(written, as_str(&results[1]));
+    let _ = fs::remove_dir_all(&dir);
+}
+
+#[test]
+fn e2e_json_encoder_rejects_calls_out_of_order() {
+    let results = items(run_raw_main_with_std_json(
+        r#"
+cell must(r: result[Encoder, String]) -> Encoder
+  match r
+    ok(e) -> return e
+    err(msg) -> panic(msg)
+  end
+end
+
+cell main() -> list[result[Encoder, String]]
+  let e = must(new_encoder())
+  let obj = must(begin_object(e))
+  let arr = must(begin_array(e))
+  let named = must(field(obj, "id"))
+  let done = must(value(e, 1))
+  let nested = must(begin_array(must(field(obj, "tags"))))
+  return [value(obj, 1), field(arr, "id"), field(named, "id"), end_array(obj), end_object(arr), end_object(named), value(done, 2), close_encoder(nested)]
+end
+"#,
+    ));
+
+    let messages: Vec<Value> = results.iter().map(result_payload).collect();
+    assert_eq!(
+        messages,
+        [
+            "json encoder: a value inside an object needs a field name first",
+            "json encoder: field \"id\" outside an object",
+            "json encoder: field \"id\" follows a field with no value",
+            "json encoder: end_array without an open array",
+            "json encoder: end_object without an open object",
+            "json encoder: end_object after a field with no value",
+            "json encoder: the document is already complete",
+            "json encoder: 2 containers are still open",
+        ]
+        .map(owned)
+    );
+}
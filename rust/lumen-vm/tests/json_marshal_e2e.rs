@@ -0,0 +1,152 @@
+use lumen_compiler::compile_raw;
+use lumen_vm::values::{StringRef, Value};
+use lumen_vm::vm::VM;
+
+const ACCOUNT: &str = r#"
+record Address
+  @json("zip_code")
+  zip: String
+  city: String
+end
+
+record Account
+  @json("first_name")
+  name: String
+  @json(skip)
+  session: String = "none"
+  @json("tags", omit_empty)
+  labels: list[String]
+  @json(omit_empty)
+  nickname: String?
+  age: Int = 0
+  home: Address
+end
+"#;
+
+fn run(body: &str) -> Value {
+    let source = format!("{}\n{}", ACCOUNT, body);
+    let module = compile_raw(&source).expect("raw source should compile");
+    let mut vm = VM::new();
+    vm.load(module);
+    vm.execute("main", vec![]).expect("main should execute")
+}
+
+fn owned(s: &str) -> Value {
+    Value::String(StringRef::Owned(s.to_string()))
+}
+
+fn result_payload(v: Value) -> Value {
+    match v {
+        Value::Union(u) => (*u.payload).clone(),
+        other => panic!("expected a result, got {:?}", other),
+    }
+}
+
+fn field(v: &Value, name: &str) -> Value {
+    match v {
+        Value::Record(r) => r
+            .fields
+            .get(name)
+            .cloned()
+            .unwrap_or_else(|| panic!("no field {} in {:?}", name, r)),
+        other => panic!("expected a record, got {:?}", other),
+    }
+}
+
+#[test]
+fn e2e_marshal_renames_and_omits_fields() {
+    let out = run(r#"
+cell main() -> String
+  let a = Account(name: "Ada", session: "s3cret", labels: [], nickname: null, age: 36, home: Address(zip: "02139", city: "Cambridge"))
+  return json_marshal(a)
+end
+"#);
+    assert_eq!(
+        out,
+        owned(r#"{"age":36,"first_name":"Ada","home":{"city":"Cambridge","zip_code":"02139"}}"#)
+    );
+}
+
+#[test]
+fn e2e_marshal_keeps_non_empty_omit_empty_fields() {
+    let out = run(r#"
+cell main() -> String
+  let a = Account(name: "Ada", labels: ["admin"], nickname: "ada", home: Address(zip: "1", city: "x"))
+  return json_marshal(a)
+end
+"#);
+    assert_eq!(
+        out,
+        owned(
+            r#"{"age":0,"first_name":"Ada","home":{"city":"x","zip_code":"1"},"nickname":"ada","tags":["admin"]}"#
+        )
+    );
+}
+
+#[test]
+fn e2e_unmarshal_reads_marshalled_record_back() {
+    let out = run(r#"
+cell main() -> result[Any, String]
+  let a = Account(name: "Ada", session: "s3cret", labels: [], nickname: null, age: 36, home: Address(zip: "02139", city: "Cambridge"))
+  return json_unmarshal(json_marshal(a), "Account")
+end
+"#);
+    let account = result_payload(out);
+    assert_eq!(field(&account, "name"), owned("Ada"));
+    // Skipped on the way out, so it takes its default on the way back
+    assert_eq!(field(&account, "session"), owned("none"));
+    assert_eq!(field(&account, "labels"), Value::new_list(vec![]));
+    assert_eq!(field(&account, "nickname"), Value::Null);
+    assert_eq!(field(&account, "age"), Value::Int(36));
+    let home = field(&account, "home");
+    assert_eq!(field(&home, "zip"), owned("02139"));
+    assert_eq!(field(&home, "city"), owned("Cambridge"));
+}
+
+#[test]
+fn e2e_unmarshal_uses_defaults_and_ignores_unknown_keys() {
+    let out = run(r#"
+cell main() -> result[Any, String]
+  return json_unmarshal("{\"first_name\": \"Lin\", \"session\": \"x\", \"extra\": 1, \"tags\": [\"a\", \"b\"], \"home\": {\"zip_code\": \"9\", \"city\": \"y\"}}", "Account")
+end
+"#);
+    let account = result_payload(out);
+    assert_eq!(field(&account, "name"), owned("Lin"));
+    assert_eq!(field(&account, "session"), owned("none"));
+    assert_eq!(field(&account, "age"), Value::Int(0));
+    assert_eq!(
+        field(&account, "labels"),
+        Value::new_list(vec![owned("a"), owned("b")])
+    );
+}
+
+#[test]
+fn e2e_unmarshal_reports_missing_and_mistyped_fields() {
+    let missing = run(r#"
+cell main() -> result[Any, String]
+  return json_unmarshal("{\"first_name\": \"Lin\", \"home\": {\"city\": \"y\"}}", "Account")
+end
+"#);
+    assert_eq!(
+        result_payload(missing),
+        owned("unmarshal: missing field home.zip_code")
+    );
+    let mistyped = run(r#"
+cell main() -> result[Any, String]
+  return json_unmarshal("{\"first_name\": \"Lin\", \"age\": \"old\", \"home\": {\"zip_code\": \"9\", \"city\": \"y\"}}", "Account")
+end
+"#);
+    assert_eq!(
+        result_payload(mistyped),
+        owned("unmarshal: expected Int at age, found a string")
+    );
+    let unknown = run(r#"
+cell main() -> result[Any, String]
+  return json_unmarshal("{}", "Nope")
+end
+"#);
+    assert_eq!(
+        result_payload(unknown),
+        owned("unmarshal: unknown record type Nope")
+    );
+}
@@ -0,0 +1,148 @@
+use std::fs;
+use std::path::PathBuf;
+
+use lumen_compiler::compile_raw_with_imports;
+use lumen_vm::values::{StringRef, Value};
+use lumen_vm::vm::VM;
+
+fn std_json_module_source() -> String {
+    let manifest_dir = PathBuf::from(env!("CARGO_MANIFEST_DIR"));
+    let path = manifest_dir.join("../../stdlib/std/json.lm.md");
+    fs::read_to_string(&path).unwrap_or_else(|e| panic!("cannot read {}: {}", path.display(), e))
+}
+
+/// `main` run with `DOCUMENT` parsed into `doc`, a `std.json` Value.
+fn run_with_document(body: &str) -> Value {
+    let source = format!(
+        r#"
+import std.json: Value, parse_value, get, kind, is_null, as_string, as_number, as_bool, as_array, as_object
+
+cell document() -> Value
+  let text = "{{\"user\": {{\"name\": \"Ada\", \"age\": 36, \"admin\": false, \"langs\": [\"lumen\", \"rust\"], \"manager\": null}}, \"scores\": [1.5, 2]}}"
+  match parse_value(text)
+    ok(doc) -> return doc
+    err(msg) -> panic(msg)
+  end
+end
+
+cell at(doc: Value, path: String) -> Value
+  match get(doc, path)
+    ok(v) -> return v
+    err(msg) -> panic(msg)
+  end
+end
+
+{}
+"#,
+        body
+    );
+    let json_source = std_json_module_source();
+    let module = compile_raw_with_imports(&source, &|module| {
+        if module == "std.json" {
+            Some(json_source.clone())
+        } else {
+            None
+        }
+    })
+    .expect("raw source should compile with std.json");
+    let mut vm = VM::new();
+    vm.load(module);
+    vm.execute("main", vec![]).expect("main should execute")
+}
+
+fn owned(s: &str) -> Value {
+    Value::String(StringRef::Owned(s.to_string()))
+}
+
+fn items(v: Value) -> Vec<Value> {
+    match v {
+        Value::List(items) => items.as_ref().clone(),
+        other => panic!("expected a list, got {:?}", other),
+    }
+}
+
+/// The payload of an `ok` or `err` result.
+fn payload(v: &Value) -> Value {
+    match v {
+        Value::Union(u) => (*u.payload).clone(),
+        other => panic!("expected a result, got {:?}", other),
+    }
+}
+
+#[test]
+fn e2e_json_value_gets_through_objects_and_arrays() {
+    let results = items(run_with_document(
+        r#"
+cell main() -> list[Any]
+  let doc = document()
+  let user = at(doc, "user")
+  let langs = at(user, "langs")
+  var names = ""
+  match as_array(langs)
+    ok(items) -> names = string(len(items))
+    err(msg) -> panic(msg)
+  end
+  return [as_string(at(doc, "user.name")), as_number(at(doc, "user.age")), as_bool(at(user, "admin")), as_string(at(doc, "user.langs.1")), as_string(at(langs, "0")), as_number(at(doc, "scores.1")), kind(at(doc, "scores")), is_null(at(user, "manager")), at(doc, "user.langs.1").path, names]
+end
+"#,
+    ));
+
+    assert_eq!(payload(&results[0]), owned("Ada"));
+    assert_eq!(payload(&results[1]), Value::Float(36.0));
+    assert_eq!(payload(&results[2]), Value::Bool(false));
+    assert_eq!(payload(&results[3]), owned("rust"));
+    assert_eq!(payload(&results[4]), owned("lumen"));
+    assert_eq!(payload(&results[5]), Value::Float(2.0));
+    assert_eq!(results[6], owned("array"));
+    assert_eq!(results[7], Value::Bool(true));
+    assert_eq!(results[8], owned("user.langs.1"));
+    assert_eq!(results[9], owned("2"));
+}
+
+#[test]
+fn e2e_json_value_errors_name_the_path() {
+    let results = items(run_with_document(
+        r#"
+cell main() -> list[Any]
+  let doc = document()
+  let user = at(doc, "user")
+  return [get(doc, "user.email"), get(doc, "user.langs.5"), get(doc, "user.langs.first"), get(doc, "user.name.x"), as_number(at(doc, "user.name")), as_object(at(user, "langs")), as_string(doc), get(doc, "user..name"), parse_value("{\"a\": ")]
+end
+"#,
+    ));
+
+    let messages: Vec<Value> = results.iter().map(payload).collect();
+    assert_eq!(messages[0], owned("json: no key \"email\" at user"));
+    assert_eq!(
+        messages[1],
+        owned("json: index 5 is out of range at user.langs, which has 2 elements")
+    );
+    assert_eq!(
+        messages[2],
+        owned("json: \"first\" is not an index into the array at user.langs")
+    );
+    assert_eq!(
+        messages[3],
+        owned("json: cannot look up \"x\" in a string at user.name")
+    );
+    assert_eq!(
+        messages[4],
+        owned("json: expected a number at user.name, found a string")
+    );
+    assert_eq!(
+        messages[5],
+        owned("json: expected an object at user.langs, found an array")
+    );
+    assert_eq!(
+        messages[6],
+        owned("json: expected a string at the root, found an object")
+    );
+    assert_eq!(
+        messages[7],
+        owned("json: empty segment in path \"user..name\"")
+    );
+    let Value::String(StringRef::Owned(parse_error)) = &messages[8] else {
+        panic!("expected a parse error message, got {:?}", messages[8]);
+    };
+    assert!(parse_error.starts_with("json: "), "{}", parse_error);
+}
@@ -0,0 +1,149 @@
+mod common;
+
+use common::{repo_file, StdModule};
+use lumen_vm::values::{StringRef, Value};
+
+const STD_LINALG: StdModule = StdModule {
+    import: "std.linalg",
+    path: "stdlib/std/linalg.lm.md",
+};
+
+fn run_raw_main_with_std_linalg(source: &str) -> Result<Value, String> {
+    STD_LINALG
+        .load(source)
+        .execute("main", vec![])
+        .map_err(|e| e.to_string())
+}
+
+fn floats(rows: &[&[f64]]) -> Value {
+    Value::new_list(
+        rows.iter()
+            .map(|row| Value::new_list(row.iter().map(|&x| Value::Float(x)).collect()))
+            .collect(),
+    )
+}
+
+#[test]
+fn e2e_linalg_matmul_of_small_matrices() {
+    let source = r#"
+import std.linalg: matmul
+
+cell main() -> list[list[list[Float]]]
+  let a = [[1.0, 2.0, 3.0], [4.0, 5.0, 6.0]]
+  let b = [[7.0, 8.0], [9.0, 10.0], [11.0, 12.0]]
+  let identity = [[1.0, 0.0], [0.0, 1.0]]
+  return [matmul(a, b), matmul(b, a), matmul(identity, [[2.0, 3.0], [5.0, 7.0]])]
+end
+"#;
+
+    assert_eq!(
+        run_raw_main_with_std_linalg(source).expect("main should execute"),
+        Value::new_list(vec![
+            floats(&[&[58.0, 64.0], &[139.0, 154.0]]),
+            floats(&[
+                &[39.0, 54.0, 69.0],
+                &[49.0, 68.0, 87.0],
+                &[59.0, 82.0, 105.0],
+            ]),
+            floats(&[&[2.0, 3.0], &[5.0, 7.0]]),
+        ])
+    );
+}
+
+#[test]
+fn e2e_linalg_transpose_and_dot() {
+    let source = r#"
+import std.linalg: transpose, dot
+
+cell main() -> tuple[list[list[Float]], Float, Float]
+  let m = [[1.0, 2.0, 3.0], [4.0, 5.0, 6.0]]
+  return (transpose(m), dot([1.0, 2.0, 3.0], [4.0, 5.0, 6.0]), dot([], []))
+end
+"#;
+
+    assert_eq!(
+        run_raw_main_with_std_linalg(source).expect("main should execute"),
+        Value::new_tuple(vec![
+            floats(&[&[1.0, 4.0], &[2.0, 5.0], &[3.0, 6.0]]),
+            Value::Float(32.0),
+            Value::Float(0.0),
+        ])
+    );
+}
+
+#[test]
+fn e2e_linalg_reports_mismatched_shapes() {
+    let source = r#"
+import std.linalg: matmul
+
+cell main() -> list[list[Float]]
+  return matmul([[1.0, 2.0, 3.0]], [[1.0, 2.0]])
+end
+"#;
+
+    let err = STD_LINALG
+        .run_main(source)
+        .expect_err("1x3 by 1x2 is undefined");
+    assert!(
+        err.contains("cannot multiply a 1x3 matrix by a 1x2 matrix"),
+        "{}",
+        err
+    );
+}
+
+/// The checksum line `bench/cross-language/bench.toml` expects from every
+/// matrix_mult implementation.
+fn matrix_mult_checksum() -> String {
+    let manifest = repo_file("bench/cross-language/bench.toml");
+    let section = manifest
+        .split("[matrix_mult]")
+        .nth(1)
+        .expect("bench.toml should list matrix_mult");
+    let line = section
+        .lines()
+        .find_map(|l| l.strip_prefix("checksum = "))
+        .expect("matrix_mult should have a checksum");
+    line.trim_matches('"').to_string()
+}
+
+#[test]
+fn e2e_linalg_matmul_reproduces_the_matrix_mult_benchmark() {
+    // bench/cross-language/matrix_mult with the multiply as a library call
+    let source = r#"
+import std.linalg: matmul
+
+cell main() -> String
+  let n = 200
+  var a = []
+  var b = []
+  var i = 0
+  while i < n
+    var row_a = []
+    var row_b = []
+    var j = 0
+    while j < n
+      row_a = append(row_a, (i * n + j) % 1000 / 1000.0)
+      row_b = append(row_b, (j * n + i) % 1000 / 1000.0)
+      j = j + 1
+    end
+    a = append(a, row_a)
+    b = append(b, row_b)
+    i = i + 1
+  end
+
+  let c = matmul(a, b)
+  var checksum = 0.0
+  for row in c
+    for x in row
+      checksum = checksum + x
+    end
+  end
+  return "matrix_mult(200): checksum = " + format_fixed(checksum, 6)
+end
+"#;
+
+    assert_eq!(
+        run_raw_main_with_std_linalg(source).expect("main should execute"),
+        Value::String(StringRef::Owned(matrix_mult_checksum()))
+    );
+}
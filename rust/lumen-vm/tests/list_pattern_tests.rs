@@ -0,0 +1,88 @@
+//! List patterns in `match`: `[a, b]` matches exactly two elements and
+//! `[head, tail..]` (or `[head, ...tail]`) at least one, binding the rest.
+
+use lumen_compiler::compile;
+use lumen_vm::values::{StringRef, Value};
+use lumen_vm::vm::VM;
+
+fn run_main(source: &str) -> Value {
+    let md = format!("# list-pattern-test\n\n```lumen\n{}\n```\n", source.trim());
+    let module = compile(&md).expect("source should compile");
+    let mut vm = VM::new();
+    vm.load(module);
+    vm.execute("main", vec![]).expect("main should execute")
+}
+
+#[test]
+fn head_and_tail_of_a_fixed_list() {
+    let result = run_main(
+        r#"
+cell main() -> String
+  let p = [0, 4, 2]
+  match p
+    [1, rest..] -> return "starts with one"
+    [0, rest..] -> return "zero then " + to_string(rest)
+    _ -> return "other"
+  end
+end
+"#,
+    );
+    assert_eq!(
+        result,
+        Value::String(StringRef::Owned("zero then [4, 2]".into()))
+    );
+}
+
+#[test]
+fn recursion_over_head_and_tail() {
+    let result = run_main(
+        r#"
+cell sum(xs: list[Int]) -> Int
+  match xs
+    [] -> return 0
+    [head, tail..] -> return head + sum(tail)
+  end
+end
+
+cell main() -> Int
+  return sum([1, 2, 3, 4])
+end
+"#,
+    );
+    assert_eq!(result, Value::Int(10));
+}
+
+#[test]
+fn fannkuch_flips_by_matching_the_first_element() {
+    // Count prefix reversals until the permutation starts with 0.
+    let result = run_main(
+        r#"
+cell reverse_prefix(xs: list[Int], n: Int) -> list[Int]
+  var out: list[Int] = []
+  var i = n - 1
+  while i >= 0
+    out = append(out, xs[i])
+    i = i - 1
+  end
+  var j = n
+  while j < len(xs)
+    out = append(out, xs[j])
+    j = j + 1
+  end
+  return out
+end
+
+cell flips(perm: list[Int]) -> Int
+  match perm
+    [0, ..] -> return 0
+    [k, ..] -> return 1 + flips(reverse_prefix(perm, k + 1))
+  end
+end
+
+cell main() -> String
+  return to_string(flips([2, 0, 1])) + " " + to_string(flips([3, 1, 2, 0]))
+end
+"#,
+    );
+    assert_eq!(result, Value::String(StringRef::Owned("2 1".into())));
+}
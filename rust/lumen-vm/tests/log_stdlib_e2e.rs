@@ -0,0 +1,100 @@
+mod common;
+
+use common::{as_string, StdModule};
+
+const STD_LOG: StdModule = StdModule {
+    import: "std.log",
+    path: "stdlib/std/log.lm.md",
+};
+
+// Unwraps the `result[Logger, String]` returned by the `with_*` setters.
+const MUST: &str = r#"
+cell must(r: result[Logger, String]) -> Logger
+  match r
+    ok(l) -> return l
+    err(e) -> halt(e)
+  end
+end
+"#;
+
+#[test]
+fn e2e_log_suppresses_records_below_minimum_level() {
+    let source = r#"
+import std.log: Logger, new_logger, with_level, with_writer, debug, info, warn, error
+
+cell main() -> String
+  let mut out = []
+  for level in ["debug", "info", "warn", "error"]
+    let mut l = must(with_writer(must(with_level(new_logger(), level)), "memory"))
+    l = debug(l, "d")
+    l = info(l, "i")
+    l = warn(l, "w")
+    l = error(l, "e")
+    out = append(out, level + ":" + join(l.lines, ","))
+  end
+  return join(out, " ")
+end
+"#;
+
+    assert_eq!(
+        as_string(&STD_LOG.run_main(&format!("{}{}", source, MUST))),
+        "debug:level=DEBUG msg=d,level=INFO msg=i,level=WARN msg=w,level=ERROR msg=e \
+         info:level=INFO msg=i,level=WARN msg=w,level=ERROR msg=e \
+         warn:level=WARN msg=w,level=ERROR msg=e \
+         error:level=ERROR msg=e"
+    );
+}
+
+#[test]
+fn e2e_log_renders_fields_in_key_order_with_quoting() {
+    let source = r#"
+import std.log: Logger, new_logger, with_writer, with_fields, info, warn
+
+cell main() -> String
+  let base = with_fields(must(with_writer(new_logger(), "memory")), {"bench": "fib", "run": 1})
+  let mut l = base
+  l = info(l, "run started", {"n": 35, "mode": "fast"})
+  l = info(l, "run started", {"mode": "fast", "n": 35})
+  l = warn(l, "slow", {"run": 2, "note": "took \"long\"", "empty": "", "path": "a=b", "ratio": 1.5})
+  l = info(l, "multi\nline")
+  return join(l.lines, "\n")
+end
+"#;
+
+    let expected = [
+        "level=INFO msg=\"run started\" bench=fib mode=fast n=35 run=1",
+        "level=INFO msg=\"run started\" bench=fib mode=fast n=35 run=1",
+        "level=WARN msg=slow bench=fib empty=\"\" note=\"took \\\"long\\\"\" path=\"a=b\" \
+         ratio=1.5 run=2",
+        "level=INFO msg=\"multi\\nline\" bench=fib run=1",
+    ];
+    assert_eq!(
+        as_string(&STD_LOG.run_main(&format!("{}{}", source, MUST))),
+        expected.join("\n")
+    );
+}
+
+#[test]
+fn e2e_log_rejects_unknown_level_and_writer() {
+    let source = r#"
+import std.log: new_logger, with_level, with_writer
+
+cell main() -> String
+  let mut out = []
+  match with_level(new_logger(), "trace")
+    ok(_) -> out = append(out, "accepted trace")
+    err(e) -> out = append(out, e)
+  end
+  match with_writer(new_logger(), "syslog")
+    ok(_) -> out = append(out, "accepted syslog")
+    err(e) -> out = append(out, e)
+  end
+  return join(out, "; ")
+end
+"#;
+
+    assert_eq!(
+        as_string(&STD_LOG.run_main(source)),
+        "unknown log level: trace; unknown log writer: syslog"
+    );
+}
@@ -0,0 +1,72 @@
+mod common;
+
+use common::{as_string, StdModule};
+
+const STD_MAPS: StdModule = StdModule {
+    import: "std.maps",
+    path: "stdlib/std/maps.lm.md",
+};
+
+#[test]
+fn e2e_maps_keys_and_values_line_up() {
+    let source = r#"
+import std.maps: keys, values
+
+cell main() -> String
+  let m = {"b": 2, "a": 1, "c": 3}
+  let ks = keys(m)
+  let vs = values(m)
+  if len(ks) != len(vs)
+    return "length mismatch"
+  end
+  let mut pairs = []
+  let mut i = 0
+  while i < len(ks)
+    if m[ks[i]] != vs[i]
+      return "value does not match key " + ks[i]
+    end
+    pairs = append(pairs, ks[i] + "=" + string(vs[i]))
+    i += 1
+  end
+  return join(pairs, ",")
+end
+"#;
+
+    assert_eq!(as_string(&STD_MAPS.run_main(source)), "a=1,b=2,c=3");
+}
+
+#[test]
+fn e2e_maps_merge_prefers_second_map() {
+    let source = r#"
+import std.maps: merge, keys
+
+cell main() -> String
+  let a = {"host": "localhost", "port": "80"}
+  let b = {"port": "8080", "tls": "on"}
+  let m = merge(a, b)
+  return join(keys(m), ",") + " " + m["host"] + ":" + m["port"] + " " + m["tls"] + " " + a["port"]
+end
+"#;
+
+    assert_eq!(
+        as_string(&STD_MAPS.run_main(source)),
+        "host,port,tls localhost:8080 on 80"
+    );
+}
+
+#[test]
+fn e2e_maps_clone_is_independent() {
+    let source = r#"
+import std.maps: clone, keys, values
+
+cell main() -> String
+  let original = {"x": 1}
+  let mut copy = clone(original)
+  copy["y"] = 2
+  let empty = {}
+  return "{len(keys(original))}:{len(keys(copy))}:{len(values(clone(empty)))}"
+end
+"#;
+
+    assert_eq!(as_string(&STD_MAPS.run_main(source)), "1:2:0");
+}
@@ -0,0 +1,250 @@
+mod common;
+
+use common::{as_string, StdModule};
+use lumen_vm::values::Value;
+use lumen_vm::vecops;
+
+const STD_MATH: StdModule = StdModule {
+    import: "std.math",
+    path: "stdlib/std/math.lm.md",
+};
+
+fn as_floats(value: &Value) -> Vec<f64> {
+    match value {
+        Value::List(items) => items
+            .iter()
+            .map(|item| match item {
+                Value::Float(f) => *f,
+                other => panic!("expected float, got {:?}", other),
+            })
+            .collect(),
+        other => panic!("expected list, got {:?}", other),
+    }
+}
+
+fn bits(xs: &[f64]) -> Vec<u64> {
+    xs.iter().map(|x| x.to_bits()).collect()
+}
+
+#[test]
+fn e2e_math_vector_ops_match_scalar_bit_for_bit() {
+    // Eleven elements: two full AVX blocks and a three-element scalar tail.
+    let source = r#"
+import std.math: vec_add, vec_mul, vec_triad
+
+cell main() -> list[list[Float]]
+  let a = [0.1, -2.5, 1e300, -0.0, 3.0, 7.25, 1e-310, -4.0, 0.3, 123456.789, 2.0]
+  let b = [0.2, 0.5, 1e300, 0.0, -3.0, 1.0 / 3.0, 1e-10, 2.5, 0.7, -0.001, 1e16]
+  let mut dst = []
+  for x in a
+    dst = append(dst, 0.0)
+  end
+  let mut looped = dst
+  for i in range(0, len(a))
+    looped[i] = a[i] + 0.1 * b[i]
+  end
+  return [vec_add(dst, a, b), vec_mul(dst, a, b), vec_triad(dst, a, b, 0.1), looped]
+end
+"#;
+
+    let a = [
+        0.1, -2.5, 1e300, -0.0, 3.0, 7.25, 1e-310, -4.0, 0.3, 123456.789, 2.0,
+    ];
+    let b = [
+        0.2,
+        0.5,
+        1e300,
+        0.0,
+        -3.0,
+        1.0 / 3.0,
+        1e-10,
+        2.5,
+        0.7,
+        -0.001,
+        1e16,
+    ];
+    let mut sum = [0.0; 11];
+    let mut product = [0.0; 11];
+    let mut triad = [0.0; 11];
+    vecops::scalar::add(&mut sum, &a, &b);
+    vecops::scalar::mul(&mut product, &a, &b);
+    vecops::scalar::triad(&mut triad, &a, &b, 0.1);
+
+    let results: Vec<Vec<f64>> = match STD_MATH.run_main(source) {
+        Value::List(items) => items.iter().map(as_floats).collect(),
+        other => panic!("expected list, got {:?}", other),
+    };
+    assert_eq!(bits(&results[0]), bits(&sum));
+    assert_eq!(bits(&results[1]), bits(&product));
+    assert_eq!(bits(&results[2]), bits(&triad));
+    // The interpreter's own arithmetic agrees with the kernel too.
+    assert_eq!(bits(&results[3]), bits(&triad));
+}
+
+#[test]
+fn e2e_math_vector_ops_reject_mismatched_lengths() {
+    let source = r#"
+import std.math: vec_add, vec_triad
+
+cell main() -> String
+  let mut out = []
+  match recover(fn() => vec_add([0.0, 0.0], [1.0, 2.0, 3.0], [1.0, 2.0, 3.0]))
+    ok(_) -> out = append(out, "added")
+    err(msg) -> out = append(out, msg)
+  end
+  match recover(fn() => vec_triad([0.0], [1.0], [2.0], 0.5))
+    ok(v) -> out = append(out, string(v))
+    err(msg) -> out = append(out, msg)
+  end
+  return join(out, "; ")
+end
+"#;
+
+    assert_eq!(
+        as_string(&STD_MATH.run_main(source)),
+        "runtime error: float_vec_add: vector lengths differ: dst 2, a 3, b 3; [2.0]"
+    );
+}
+
+#[test]
+fn e2e_math_reciprocals_match_exact_division() {
+    // Inputs from 1e-3 to 1e6 in steps of 37%, plus exact powers of four.
+    let source = r#"
+import std.math: recip, rsqrt, vec_recip, vec_rsqrt
+
+cell main() -> list[list[Float]]
+  let mut xs = [0.25, 1.0, 16.0, 1048576.0]
+  let mut x = 0.001
+  while x < 1000000.0
+    xs = append(xs, x)
+    x = x * 1.37
+  end
+  let mut dst = []
+  let mut inverses = []
+  let mut roots = []
+  for v in xs
+    dst = append(dst, 0.0)
+    inverses = append(inverses, recip(v))
+    roots = append(roots, rsqrt(v))
+  end
+  return [xs, inverses, roots, vec_recip(dst, xs), vec_rsqrt(dst, xs)]
+end
+"#;
+
+    let results: Vec<Vec<f64>> = match STD_MATH.run_main(source) {
+        Value::List(items) => items.iter().map(as_floats).collect(),
+        other => panic!("expected list, got {:?}", other),
+    };
+    let xs = &results[0];
+    assert!(xs.len() > 40);
+    let exact_recip: Vec<f64> = xs.iter().map(|x| 1.0 / x).collect();
+    let exact_rsqrt: Vec<f64> = xs.iter().map(|x| 1.0 / x.sqrt()).collect();
+    assert_eq!(bits(&results[1]), bits(&exact_recip));
+    assert_eq!(bits(&results[2]), bits(&exact_rsqrt));
+    assert_eq!(bits(&results[3]), bits(&exact_recip));
+    assert_eq!(bits(&results[4]), bits(&exact_rsqrt));
+
+    // Powers of four have exact roots; elsewhere rsqrt(x)^2 * x is 1 to
+    // within the rounding of its three operations.
+    assert_eq!(&results[2][..4], &[2.0, 1.0, 0.25, 1.0 / 1024.0]);
+    for (x, r) in xs.iter().zip(&results[2]) {
+        let err = (r * r * x - 1.0).abs();
+        assert!(
+            err <= 4.0 * f64::EPSILON,
+            "rsqrt({}) = {}, error {}",
+            x,
+            r,
+            err
+        );
+    }
+}
+
+#[test]
+fn e2e_math_vec_recip_rejects_mismatched_lengths() {
+    let source = r#"
+import std.math: vec_recip
+
+cell main() -> String
+  match recover(fn() => vec_recip([0.0], [1.0, 2.0]))
+    ok(_) -> return "divided"
+    err(msg) -> return msg
+  end
+end
+"#;
+
+    assert_eq!(
+        as_string(&STD_MATH.run_main(source)),
+        "runtime error: float_vec_recip: vector lengths differ: dst 1, a 2"
+    );
+}
+
+#[test]
+fn e2e_math_sum_is_naive_left_to_right_and_compensated_is_not() {
+    let source = r#"
+import std.math: sum, sum_compensated
+
+cell main() -> list[float]
+  let cancel = [1.0, 10000000000000000.0, -10000000000000000.0]
+  var tenths = []
+  var loop_total = 0.0
+  var i = 0
+  while i < 10
+    tenths = append(tenths, 0.1)
+    loop_total = loop_total + 0.1
+    i = i + 1
+  end
+  return [sum(cancel), sum_compensated(cancel), sum(tenths), sum_compensated(tenths), loop_total, sum([])]
+end
+"#;
+
+    let results = as_floats(&STD_MATH.run_main(source));
+    // 1.0 + 1e16 rounds back to 1e16, so the naive sum loses the 1.0
+    assert_eq!(results[0], 0.0);
+    assert_eq!(results[1], 1.0);
+    // Ten tenths: the naive sum is one ulp short, the compensated one exact
+    assert_eq!(results[2], 0.9999999999999999);
+    assert_eq!(results[3], 1.0);
+    // The documented order is the plain loop, in Lumen or in Rust
+    assert_eq!(results[2].to_bits(), results[4].to_bits());
+    assert_eq!(
+        results[2].to_bits(),
+        [0.1f64; 10].iter().fold(0.0, |t, x| t + x).to_bits()
+    );
+    assert_eq!(results[5], 0.0);
+}
+
+#[test]
+fn e2e_math_sum_matches_a_plain_loop_over_mixed_magnitudes() {
+    let source = r#"
+import std.math: sum
+
+cell main() -> list[float]
+  var xs = []
+  var i = 0
+  while i < 40000
+    var x = (i * 7919) % 1000 / 1000.0
+    if i % 3 == 0
+      x = x * 1000000.0
+    end
+    xs = append(xs, x)
+    i = i + 1
+  end
+  return [sum(xs)]
+end
+"#;
+
+    let xs: Vec<f64> = (0..40_000)
+        .map(|i| {
+            let x = ((i * 7919) % 1000) as f64 / 1000.0;
+            if i % 3 == 0 {
+                x * 1e6
+            } else {
+                x
+            }
+        })
+        .collect();
+    let naive = xs.iter().fold(0.0, |t, x| t + x);
+    let results = as_floats(&STD_MATH.run_main(source));
+    assert_eq!(results[0].to_bits(), naive.to_bits());
+    assert_eq!(vecops::sum(&xs).to_bits(), naive.to_bits());
+}
@@ -0,0 +1,146 @@
+//! `@memoize` caches a cell's results by argument, recursive calls
+//! included; `@memoize(n)` keeps only the `n` most recently used.
+
+use lumen_compiler::compile;
+use lumen_vm::values::{StringRef, Value};
+use lumen_vm::vm::VM;
+
+fn markdown(source: &str) -> String {
+    format!("# memoize-test\n\n```lumen\n{}\n```\n", source.trim())
+}
+
+fn load(source: &str) -> VM {
+    let module = compile(&markdown(source)).expect("source should compile");
+    let mut vm = VM::new();
+    vm.load(module);
+    vm
+}
+
+/// Naive fibonacci that prints each time its body runs.
+const FIB: &str = r#"
+@memoize
+cell fib(n: Int) -> Int
+  print("fib {n}")
+  if n < 2
+    return n
+  end
+  return fib(n - 1) + fib(n - 2)
+end
+"#;
+
+#[test]
+fn memoized_fibonacci_computes_each_n_once() {
+    let mut vm = load(FIB);
+    let result = vm
+        .execute("fib", vec![Value::Int(40)])
+        .expect("fib should execute");
+
+    assert_eq!(result, Value::Int(102_334_155));
+    let expected: Vec<String> = (0..=40).rev().map(|n| format!("fib {}", n)).collect();
+    // Uncached, the body would run over 300 million times
+    assert_eq!(vm.output, expected);
+
+    // A second call is answered from the cache
+    let again = vm
+        .execute("fib", vec![Value::Int(40)])
+        .expect("fib should execute");
+    assert_eq!(again, result);
+    assert_eq!(vm.output.len(), 41);
+}
+
+#[test]
+fn cache_is_shared_across_calls_and_emptied_by_load() {
+    let mut vm = load(&format!(
+        "{}\n{}",
+        FIB,
+        r#"
+cell main() -> list[Int]
+  return [fib(10), fib(12), fib(11)]
+end
+"#
+    ));
+    let result = vm.execute("main", vec![]).expect("main should execute");
+    assert_eq!(
+        result,
+        Value::new_list(vec![Value::Int(55), Value::Int(144), Value::Int(89)])
+    );
+    // fib(12) only had to compute 12 and 11; fib(11) was a hit
+    assert_eq!(vm.output.len(), 13);
+
+    let module = compile(&markdown(FIB)).expect("source should compile");
+    vm.load(module);
+    vm.output.clear();
+    vm.execute("fib", vec![Value::Int(3)])
+        .expect("fib should execute");
+    assert_eq!(vm.output, vec!["fib 3", "fib 2", "fib 1", "fib 0"]);
+}
+
+#[test]
+fn bounded_cache_evicts_the_least_recently_used_result() {
+    let mut vm = load(
+        r#"
+@memoize(2)
+cell square(n: Int) -> Int
+  print("square {n}")
+  return n * n
+end
+
+cell main() -> list[Int]
+  let a = square(1)
+  let b = square(2)
+  let c = square(1)
+  let d = square(3)
+  let e = square(1)
+  let f = square(2)
+  return [a, b, c, d, e, f]
+end
+"#,
+    );
+    let result = vm.execute("main", vec![]).expect("main should execute");
+    assert_eq!(
+        result,
+        Value::new_list([1, 4, 1, 9, 1, 4].map(Value::Int).to_vec())
+    );
+    // Reading 1 again made 2 the oldest, so 3 evicted 2 and not 1
+    assert_eq!(
+        vm.output,
+        vec!["square 1", "square 2", "square 3", "square 2"]
+    );
+}
+
+#[test]
+fn keys_are_whole_argument_lists() {
+    let mut vm = load(
+        r#"
+@memoize
+cell label(name: String, parts: list[Int]) -> String
+  print("label {name}")
+  return name + ":" + string(len(parts))
+end
+
+cell main() -> list[String]
+  let first = "a" + "da"
+  return [label("ada", [1, 2]), label(first, [1, 2]), label("ada", [1]), label("bob", [1, 2])]
+end
+"#,
+    );
+    let result = vm.execute("main", vec![]).expect("main should execute");
+    assert_eq!(
+        result,
+        Value::new_list(
+            ["ada:2", "ada:2", "ada:1", "bob:2"]
+                .map(|s| Value::String(StringRef::Owned(s.into())))
+                .to_vec()
+        )
+    );
+    assert_eq!(vm.output, vec!["label ada", "label ada", "label bob"]);
+}
+
+#[test]
+fn memoize_bound_must_be_positive() {
+    let err = compile(&markdown(
+        "@memoize(0)\ncell f(n: Int) -> Int\n  return n\nend",
+    ))
+    .expect_err("a cache with no room is rejected");
+    assert!(err.to_string().contains("positive cache size"), "{}", err);
+}
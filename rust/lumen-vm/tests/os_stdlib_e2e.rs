@@ -0,0 +1,149 @@
+mod common;
+
+use common::{owned, StdModule};
+use lumen_vm::values::{StringRef, Value};
+
+const STD_OS: StdModule = StdModule {
+    import: "std.os",
+    path: "stdlib/std/os.lm.md",
+};
+
+// Each test uses its own variable names: the environment is shared by all
+// test threads in the process.
+
+#[test]
+fn e2e_os_getenv_reads_a_set_variable() {
+    std::env::set_var("LUMEN_OS_TEST_SET_SIZE", "4096");
+    let source = r#"
+import std.os: getenv, getenv_int
+
+cell main() -> String
+  let raw = getenv("LUMEN_OS_TEST_SET_SIZE")
+  let size = getenv_int("LUMEN_OS_TEST_SET_SIZE", 10)
+  return "{raw}:{size * 2}"
+end
+"#;
+
+    assert_eq!(STD_OS.run_main(source), owned("4096:8192"));
+}
+
+#[test]
+fn e2e_os_getenv_missing_variable_is_null() {
+    std::env::remove_var("LUMEN_OS_TEST_MISSING");
+    let source = r#"
+import std.os: getenv, getenv_or, getenv_int
+
+cell main() -> String
+  let value = getenv("LUMEN_OS_TEST_MISSING")
+  if value != null
+    return "unexpectedly set"
+  end
+  let fallback = getenv_or("LUMEN_OS_TEST_MISSING", "dflt")
+  let size = getenv_int("LUMEN_OS_TEST_MISSING", 1000)
+  return "{fallback}:{size}"
+end
+"#;
+
+    assert_eq!(STD_OS.run_main(source), owned("dflt:1000"));
+}
+
+#[test]
+fn e2e_os_setenv_round_trips() {
+    std::env::remove_var("LUMEN_OS_TEST_ROUNDTRIP");
+    let source = r#"
+import std.os: getenv, setenv, unsetenv
+
+cell main() -> String
+  setenv("LUMEN_OS_TEST_ROUNDTRIP", "hello")
+  let set_value = getenv("LUMEN_OS_TEST_ROUNDTRIP")
+  unsetenv("LUMEN_OS_TEST_ROUNDTRIP")
+  let after = getenv("LUMEN_OS_TEST_ROUNDTRIP")
+  if after != null
+    return "still set"
+  end
+  return set_value
+end
+"#;
+
+    assert_eq!(STD_OS.run_main(source), owned("hello"));
+    assert!(std::env::var("LUMEN_OS_TEST_ROUNDTRIP").is_err());
+}
+
+#[cfg(unix)]
+#[test]
+fn e2e_os_exec_captures_output_and_exit_code() {
+    let source = r#"
+import std.os: exec
+
+cell main() -> String
+  let (out, code, error) = exec("echo", ["hello", "a b"])
+  if error != null
+    return "unexpected error: " + error
+  end
+  let (_, failed, failure) = exec("sh", ["-c", "exit 3"])
+  return "{out}|{code}|{failed}|{failure}"
+end
+"#;
+
+    assert_eq!(
+        STD_OS.run_main(source),
+        owned("hello a b\n|0|3|exit status 3")
+    );
+}
+
+#[cfg(unix)]
+#[test]
+fn e2e_os_exec_capture_keeps_stderr() {
+    let source = r#"
+import std.os: exec_capture
+
+cell main() -> String
+  let (out, err, code, _) = exec_capture("sh", ["-c", "echo out; echo err >&2; exit 1"], 0)
+  return "{out}{err}{code}"
+end
+"#;
+
+    assert_eq!(STD_OS.run_main(source), owned("out\nerr\n1"));
+}
+
+#[cfg(unix)]
+#[test]
+fn e2e_os_exec_timeout_kills_a_hanging_command() {
+    let source = r#"
+import std.os: exec_timeout
+
+cell main() -> String
+  let (out, code, error) = exec_timeout("sh", ["-c", "echo started; exec sleep 30"], 200)
+  return "{out}|{code}|{error}"
+end
+"#;
+
+    let start = std::time::Instant::now();
+    let result = STD_OS.run_main(source);
+    assert!(start.elapsed() < std::time::Duration::from_secs(10));
+    assert_eq!(result, owned("started\n|-1|timed out after 200 ms"));
+}
+
+#[test]
+fn e2e_os_exec_reports_a_missing_program() {
+    let source = r#"
+import std.os: exec
+
+cell main() -> String
+  let (out, code, error) = exec("lumen-no-such-program", [])
+  if error == null
+    return "no error"
+  end
+  return "{out}|{code}|" + error
+end
+"#;
+
+    let Value::String(StringRef::Owned(result)) = STD_OS.run_main(source) else {
+        panic!("main should return an owned string");
+    };
+    assert!(
+        result.starts_with("|-1|exec lumen-no-such-program: "),
+        "{}",
+        result
+    );
+}
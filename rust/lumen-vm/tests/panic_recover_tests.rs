@@ -0,0 +1,297 @@
+//! `panic(msg)` unwinds as an unrecoverable error; `recover(f)` is the only
+//! boundary that turns it back into a value, as `err(message)`. `result`
+//! errors are ordinary values and pass through `recover` untouched.
+
+use lumen_compiler::compile;
+use lumen_vm::values::{StringRef, Value};
+use lumen_vm::vm::{VmError, VM};
+
+fn run(source: &str) -> Result<Value, VmError> {
+    let md = format!("# panic-recover-test\n\n```lumen\n{}\n```\n", source.trim());
+    let module = compile(&md).expect("source should compile");
+    let mut vm = VM::new();
+    vm.load(module);
+    vm.execute("main", vec![])
+}
+
+fn as_string(value: &Value) -> String {
+    match value {
+        Value::String(StringRef::Owned(s)) => s.clone(),
+        other => panic!("expected owned string, got {:?}", other),
+    }
+}
+
+#[test]
+fn panic_unwinds_nested_calls_to_recover_boundary() {
+    let result = run(r#"
+cell inner(n: Int) -> Int
+  if n > 2
+    panic("too deep at {n}")
+  end
+  return inner(n + 1)
+end
+
+cell worker() -> Int
+  return inner(0) + 1
+end
+
+cell main() -> String
+  let mut out = []
+  match recover(worker)
+    ok(v) -> out = append(out, "ok {v}")
+    err(msg) -> out = append(out, "recovered: " + msg)
+  end
+  match recover(fn() => 40 + 2)
+    ok(v) -> out = append(out, "ok {v}")
+    err(msg) -> out = append(out, "recovered: " + msg)
+  end
+  return join(out, "; ")
+end
+"#)
+    .expect("main should execute");
+    assert_eq!(as_string(&result), "recovered: too deep at 3; ok 42");
+}
+
+#[test]
+fn recover_catches_runtime_errors_but_not_result_errors() {
+    let result = run(r#"
+cell out_of_bounds() -> Int
+  let items = [1, 2, 3]
+  return items[10]
+end
+
+cell failing() -> result[Int, String]
+  return err("not a panic")
+end
+
+cell main() -> String
+  let mut out = []
+  match recover(out_of_bounds)
+    ok(_) -> out = append(out, "no panic")
+    err(msg) -> out = append(out, msg)
+  end
+  match recover(failing)
+    ok(r) ->
+      match r
+        ok(_) -> out = append(out, "ok")
+        err(e) -> out = append(out, "value " + e)
+      end
+    err(msg) -> out = append(out, "panicked " + msg)
+  end
+  return join(out, "; ")
+end
+"#)
+    .expect("main should execute");
+    assert_eq!(
+        as_string(&result),
+        "runtime error: index 10 out of range [0:3]; value not a panic"
+    );
+}
+
+#[test]
+fn recover_leaves_the_vm_usable_after_many_panics() {
+    let result = run(r#"
+cell explode(depth: Int) -> Int
+  if depth == 0
+    panic("boom")
+  end
+  return explode(depth - 1)
+end
+
+cell supervise() -> Int
+  match recover(fn() => explode(5))
+    ok(_) -> return 0
+    err(_) -> return 1
+  end
+end
+
+cell main() -> Int
+  let mut caught = 0
+  for i in range(0, 200)
+    caught += supervise()
+  end
+  let nested = recover(fn() => supervise() + explode(0))
+  match nested
+    ok(_) -> return -1
+    err(_) -> return caught * 10 + supervise()
+  end
+end
+"#)
+    .expect("main should execute");
+    assert_eq!(result, Value::Int(2001));
+}
+
+#[test]
+fn unrecovered_panic_terminates_with_backtrace() {
+    let err = run(r#"
+cell inner() -> Int
+  panic("invariant violated")
+  return 0
+end
+
+cell outer() -> Int
+  return inner() + 1
+end
+
+cell main() -> Int
+  let v = outer()
+  return v
+end
+"#)
+    .expect_err("panic should escape main");
+
+    assert!(err.is_panic(), "expected a panic, got {:?}", err);
+    let names: Vec<&str> = err
+        .stack_frames()
+        .iter()
+        .map(|f| f.cell_name.as_str())
+        .collect();
+    assert_eq!(names, ["main", "outer", "inner"]);
+    let text = err.to_string();
+    assert!(
+        text.starts_with("panic: invariant violated\nStack trace (most recent call last):"),
+        "unexpected panic report: {}",
+        text
+    );
+    assert!(
+        text.contains("#0: inner"),
+        "innermost frame first: {}",
+        text
+    );
+}
+
+#[test]
+fn runtime_errors_are_not_reported_as_explicit_panics() {
+    let err = run(r#"
+cell main() -> Int
+  let x = 0
+  return 10 / x
+end
+"#)
+    .expect_err("division by zero should escape main");
+    assert!(!err.is_panic());
+    assert!(err.is_division_by_zero());
+}
+
+#[test]
+fn todo_typechecks_in_any_return_position() {
+    let result = run(r#"
+record Config
+  name: String
+end
+
+cell parse_config(text: String) -> Config
+  todo()
+end
+
+cell load(path: String) -> result[Config, String]
+  todo()
+end
+
+cell count_words(text: String) -> Int
+  return unimplemented("word counting")
+end
+
+cell label(n: Int) -> String
+  let s: String = todo()
+  return s
+end
+
+cell main() -> Int
+  return 7
+end
+"#)
+    .expect("main should execute");
+    assert_eq!(result, Value::Int(7));
+}
+
+#[test]
+fn todo_traps_with_message_and_line() {
+    // Source lines start at 4: the markdown title, a blank line and the fence come first.
+    let err = run(r#"
+cell pending() -> Int
+  todo()
+end
+
+cell main() -> Int
+  let v = pending()
+  return v
+end
+"#)
+    .expect_err("todo should trap");
+    assert!(err.is_panic(), "expected a panic, got {:?}", err);
+    let text = err.to_string();
+    assert!(
+        text.starts_with("panic: not yet implemented at line 5\n"),
+        "unexpected panic report: {}",
+        text
+    );
+
+    let result = run(r#"
+cell main() -> String
+  match recover(fn() => unimplemented("tail calls"))
+    ok(_) -> return "ran"
+    err(msg) -> return msg
+  end
+end
+"#)
+    .expect("main should execute");
+    assert_eq!(as_string(&result), "not implemented: tail calls at line 5");
+}
+
+#[test]
+fn null_map_reads_yield_null_and_writes_fail_loudly() {
+    let result = run(r#"
+cell lookup(found: Bool) -> map[String, Int]?
+  if found
+    return {"hits": 1}
+  end
+  return null
+end
+
+cell bump(m: map[String, Int]?) -> Int
+  let mut counts = m
+  counts["hits"] = 2
+  return 0
+end
+
+cell main() -> String
+  let m = lookup(false)
+  let mut out = [string(m["hits"] == null), string(len(m))]
+  match recover(fn() => bump(lookup(true)))
+    ok(v) -> out = append(out, "ok {v}")
+    err(msg) -> out = append(out, msg)
+  end
+  match recover(fn() => bump(lookup(false)))
+    ok(v) -> out = append(out, "ok {v}")
+    err(msg) -> out = append(out, msg)
+  end
+  return join(out, "; ")
+end
+"#)
+    .expect("main should execute");
+    assert_eq!(
+        as_string(&result),
+        "true; 0; ok 0; runtime error: assignment to key \"hits\" in null map"
+    );
+}
+
+#[test]
+fn write_into_missing_nested_map_is_a_runtime_error() {
+    let err = run(r#"
+cell main() -> Int
+  let config = parse_json("{\"name\": \"bench\"}")
+  let mut settings = config["settings"]
+  settings["verbose"] = true
+  return 0
+end
+"#)
+    .expect_err("writing a null map should fail");
+    assert!(!err.is_panic());
+    let text = err.to_string();
+    assert!(
+        text.contains("runtime error: assignment to key \"verbose\" in null map"),
+        "unexpected error: {}",
+        text
+    );
+}
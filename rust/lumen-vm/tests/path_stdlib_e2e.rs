@@ -0,0 +1,80 @@
+mod common;
+
+use common::{owned, StdModule};
+
+const STD_PATH: StdModule = StdModule {
+    import: "std.path",
+    path: "stdlib/std/path.lm.md",
+};
+
+#[cfg(unix)]
+#[test]
+fn e2e_path_join_cleans_the_joined_path() {
+    let source = r#"
+import std.path: join
+
+cell main() -> String
+  let a = join(["src", "std", "path.lm.md"])
+  let b = join(["a/", "", "/b", "../c"])
+  let c = join(["/", "usr", "lib/"])
+  let d = join(["", ""])
+  return a + "|" + b + "|" + c + "|" + d
+end
+"#;
+
+    assert_eq!(
+        STD_PATH.run_main(source),
+        owned("src/std/path.lm.md|a/c|/usr/lib|")
+    );
+}
+
+#[cfg(unix)]
+#[test]
+fn e2e_path_clean_resolves_dot_dot_segments() {
+    let source = r#"
+import std.path: clean
+
+cell main() -> String
+  return clean("./a//b/../c/") + "|" + clean("../../x/./y/..") + "|" + clean("/../x") + "|" + clean("a/..")
+end
+"#;
+
+    assert_eq!(STD_PATH.run_main(source), owned("a/c|../../x|/x|."));
+}
+
+#[cfg(unix)]
+#[test]
+fn e2e_path_base_dir_and_ext() {
+    let source = r#"
+import std.path: base, dir, ext, is_abs
+
+cell main() -> String
+  let p = "/srv/app/notes.lm.md"
+  let abs = is_abs(p)
+  let rel = is_abs("srv/app")
+  return base(p) + "|" + dir(p) + "|" + ext(p) + "|" + ext("Makefile") + "|" + dir("main.lm") + "|{abs}|{rel}"
+end
+"#;
+
+    assert_eq!(
+        STD_PATH.run_main(source),
+        owned("notes.lm.md|/srv/app|.md||.|true|false")
+    );
+}
+
+#[test]
+fn e2e_path_uses_the_platform_separator() {
+    let source = r#"
+import std.path: join, separator
+
+cell main() -> String
+  return separator() + "|" + join(["a", "b"])
+end
+"#;
+
+    let sep = std::path::MAIN_SEPARATOR;
+    assert_eq!(
+        STD_PATH.run_main(source),
+        owned(&format!("{}|a{}b", sep, sep))
+    );
+}
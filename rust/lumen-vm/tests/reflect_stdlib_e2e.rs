@@ -0,0 +1,106 @@
+mod common;
+
+use common::{owned, StdModule};
+
+const STD_REFLECT: StdModule = StdModule {
+    import: "std.reflect",
+    path: "stdlib/std/reflect.lm.md",
+};
+
+#[test]
+fn e2e_reflect_record_field_names_and_types() {
+    let source = r#"
+import std.reflect: fields, kind, type_name_of, field_type
+
+record Point
+  x: Float
+  y: Float
+  label: String?
+  tags: list[String]
+end
+
+cell main() -> String
+  let p = Point(x: 1.5, y: -2.0, label: null, tags: ["a"])
+  var out = kind(p) + " " + type_name_of(p) + ":"
+  for f in fields(p)
+    out = out + " " + f.name + "=" + f.ty
+  end
+  let missing = field_type(p, "z")
+  if missing == null
+    out = out + " z=none"
+  end
+  return out
+end
+"#;
+    assert_eq!(
+        STD_REFLECT.run_main(source),
+        owned("record Point: x=Float y=Float label=String | Null tags=list[String] z=none")
+    );
+}
+
+#[test]
+fn e2e_reflect_reads_record_fields_by_name() {
+    let source = r#"
+import std.reflect: field_names, get_field, size
+
+record Pair
+  left: Int
+  right: String
+end
+
+cell main() -> String
+  let p = Pair(left: 7, right: "seven")
+  var out = to_string(size(p))
+  for name in field_names(p)
+    out = out + " " + name + "=" + to_string(get_field(p, name))
+  end
+  return out
+end
+"#;
+    assert_eq!(STD_REFLECT.run_main(source), owned("2 left=7 right=seven"));
+}
+
+#[test]
+fn e2e_reflect_list_element_type_length_and_access() {
+    let source = r#"
+import std.reflect: kind, element, element_type, size
+
+cell main() -> String
+  let xs = [10, 20, 30]
+  let mixed = [1, "two"]
+  let last = element(xs, -1)
+  let past_end = element(xs, 3)
+  var out = kind(xs) + " " + element_type(xs) + " " + to_string(size(xs)) + " " + to_string(last)
+  out = out + " " + element_type(mixed) + " " + element_type([])
+  if past_end == null
+    out = out + " out-of-range"
+  end
+  return out
+end
+"#;
+    assert_eq!(
+        STD_REFLECT.run_main(source),
+        owned("list Int 3 30 Any Any out-of-range")
+    );
+}
+
+#[test]
+fn e2e_reflect_kinds_and_enum_type_names() {
+    let source = r#"
+import std.reflect: kind, type_name_of
+
+enum Shape
+  Circle(Float)
+  Square
+end
+
+cell main() -> String
+  let parts = [kind(null), kind(true), kind(1), kind(1.5), kind("s"), kind((1, 2)), kind({"k": 1}), kind(Square), type_name_of(Circle(1.0)), type_name_of(ok(1))]
+  return join(parts, ",")
+end
+"#;
+    assert_eq!(
+        STD_REFLECT.run_main(source),
+        owned("null,bool,int,float,string,tuple,map,enum,Shape,ok")
+    );
+}
@@ -0,0 +1,143 @@
+mod common;
+
+use common::StdModule;
+use lumen_vm::values::{StringRef, Value};
+
+const STD_REGEXP: StdModule = StdModule {
+    import: "std.regexp",
+    path: "stdlib/std/regexp.lm.md",
+};
+
+fn as_string(value: &Value) -> String {
+    match value {
+        Value::String(StringRef::Owned(s)) => s.clone(),
+        Value::Union(u) => as_string(&u.payload),
+        other => panic!("expected owned string, got {:?}", other),
+    }
+}
+
+fn unwrap_ok(value: Value) -> Value {
+    match value {
+        Value::Union(u) => (*u.payload).clone(),
+        other => panic!("expected result union, got {:?}", other),
+    }
+}
+
+#[test]
+fn e2e_regexp_alternation_and_classes() {
+    let source = r#"
+import std.regexp: compile, is_match, find_all
+
+cell main() -> result[String, String]
+  let re = compile("^(cat|dog)s?$")?
+  let digits = compile("[0-9]+")?
+  let mut out = []
+  for word in ["cat", "dogs", "cow", "cats!"]
+    out = append(out, "{word}={is_match(re, word)}")
+  end
+  out = append(out, join(find_all(digits, "a1b22c333"), "/"))
+  return ok(join(out, " "))
+end
+"#;
+
+    assert_eq!(
+        as_string(&STD_REGEXP.run_main(source)),
+        "cat=true dogs=true cow=false cats!=false 1/22/333"
+    );
+}
+
+#[test]
+fn e2e_regexp_anchors() {
+    let source = r#"
+import std.regexp: compile, is_match
+
+cell main() -> result[String, String]
+  let starts = compile("^lumen")?
+  let ends = compile("vm$")?
+  let a = is_match(starts, "lumen vm")
+  let b = is_match(starts, "the lumen vm")
+  let c = is_match(ends, "lumen vm")
+  let d = is_match(ends, "lumen vm!")
+  return ok("{a},{b},{c},{d}")
+end
+"#;
+
+    assert_eq!(
+        as_string(&STD_REGEXP.run_main(source)),
+        "true,false,true,false"
+    );
+}
+
+#[test]
+fn e2e_regexp_capture_groups_and_replace() {
+    let source = r#"
+import std.regexp: compile, find, replace_all
+
+cell main() -> result[String, String]
+  let re = compile("(\\w+)@(\\w+)\\.com")?
+  let m = find(re, "mail ada@example.com now")
+  if m == null
+    return ok("no match")
+  end
+  let swapped = replace_all(re, "ada@example.com, bob@test.com", "$2:$1")
+  return ok("{m.text}|{m.start}|{m.stop}|{m.groups[1]}|{m.groups[2]}|{swapped}")
+end
+"#;
+
+    assert_eq!(
+        as_string(&STD_REGEXP.run_main(source)),
+        "ada@example.com|5|20|ada|example|example:ada, test:bob"
+    );
+}
+
+#[test]
+fn e2e_regexp_no_match_is_null() {
+    let source = r#"
+import std.regexp: compile, find
+
+cell main() -> result[Bool, String]
+  let re = compile("z+")?
+  return ok(find(re, "abc") == null)
+end
+"#;
+
+    assert_eq!(unwrap_ok(STD_REGEXP.run_main(source)), Value::Bool(true));
+}
+
+#[test]
+fn e2e_regexp_invalid_pattern_is_compile_error() {
+    let source = r#"
+import std.regexp: compile
+
+cell main() -> String
+  match compile("(unclosed")
+    ok(_) -> return "compiled"
+    err(e) -> return "error: " + e
+  end
+end
+"#;
+
+    let result = as_string(&STD_REGEXP.run_main(source));
+    assert!(result.starts_with("error: "), "got {}", result);
+}
+
+#[test]
+fn e2e_regexp_pathological_pattern_runs_in_linear_time() {
+    let source = r#"
+import std.regexp: compile, is_match
+
+cell main() -> result[Bool, String]
+  let re = compile("^(a*)*b$")?
+  let mut s = ""
+  for i in 0..5000
+    s = s + "a"
+  end
+  return ok(is_match(re, s))
+end
+"#;
+
+    let start = std::time::Instant::now();
+    assert_eq!(unwrap_ok(STD_REGEXP.run_main(source)), Value::Bool(false));
+    // A backtracking engine would effectively never finish on this input.
+    assert!(start.elapsed() < std::time::Duration::from_secs(10));
+}
@@ -0,0 +1,176 @@
+mod common;
+
+use common::{as_string, StdModule};
+
+const STD_RINGBUFFER: StdModule = StdModule {
+    import: "std.container.ringbuffer",
+    path: "stdlib/std/container/ringbuffer.lm.md",
+};
+
+#[test]
+fn e2e_ringbuffer_rejects_pushes_when_full_and_pops_null_when_empty() {
+    let source = r#"
+import std.container.ringbuffer: new, push, pop, len, cap
+
+cell main() -> String
+  var r = new(3)
+  var accepted = []
+  for i in range(1, 5)
+    let (next, took) = push(r, i * 10)
+    r = next
+    accepted = append(accepted, string(took))
+  end
+  let full = "{len(r)}/{cap(r)}"
+
+  var popped = []
+  for i in range(0, 4)
+    let (next, item) = pop(r)
+    r = next
+    popped = append(popped, "{item}")
+  end
+
+  # An empty buffer takes items again
+  let (after, took) = push(r, 7)
+  let pushes = join(accepted, ",")
+  let pops = join(popped, ",")
+  return "{pushes} {full} {pops} {len(r)} {took} {len(after)}/{cap(after)}"
+end
+"#;
+
+    assert_eq!(
+        as_string(&STD_RINGBUFFER.run_main(source)),
+        "true,true,true,false 3/3 10,20,30,null 0 true 1/3"
+    );
+}
+
+#[test]
+fn e2e_ringbuffer_wraps_around_its_slots() {
+    let source = r#"
+import std.container.ringbuffer: new, push, pop, len
+
+cell main() -> String
+  let r = new(2)
+  let (a, _) = push(r, "a")
+  let (b, _) = push(a, "b")
+  # Popping frees the first slot, so "c" goes in at the start of the list
+  let (c, one) = pop(b)
+  let (d, took_c) = push(c, "c")
+  let (e, took_d) = push(d, "d")
+  let (f, two) = pop(e)
+  let (g, three) = pop(f)
+  let (h, four) = pop(g)
+  return "{one} {took_c} {took_d} {two} {three} {four} {len(h)}"
+end
+"#;
+
+    assert_eq!(
+        as_string(&STD_RINGBUFFER.run_main(source)),
+        "a true false b c null 0"
+    );
+}
+
+#[test]
+fn e2e_ringbuffer_keeps_fifo_order_across_many_wraps() {
+    // A producer pushes 0..1000 into 3 slots while a consumer pops whenever
+    // the buffer fills, so the head and tail each go round hundreds of times
+    let source = r#"
+import std.container.ringbuffer: new, push, pop, len, cap
+
+cell main() -> String
+  var r = new(3)
+  var expected = 0
+  var mismatches = 0
+  var rejected = 0
+  for i in range(0, 1000)
+    let (next, took) = push(r, i)
+    r = next
+    if not took
+      rejected = rejected + 1
+    end
+    if len(r) == cap(r)
+      let (rest, item) = pop(r)
+      r = rest
+      if item != expected
+        mismatches = mismatches + 1
+      end
+      expected = expected + 1
+    end
+  end
+  while len(r) > 0
+    let (rest, item) = pop(r)
+    r = rest
+    if item != expected
+      mismatches = mismatches + 1
+    end
+    expected = expected + 1
+  end
+  return "{expected} {mismatches} {rejected}"
+end
+"#;
+
+    assert_eq!(as_string(&STD_RINGBUFFER.run_main(source)), "1000 0 0");
+}
+
+#[test]
+fn e2e_ringbuffer_pop_clears_the_vacated_slot() {
+    let source = r#"
+import std.container.ringbuffer: new, push, pop
+
+cell main() -> String
+  let r = new(2)
+  let (a, _) = push(r, 10)
+  let (b, _) = push(a, 20)
+  let (c, _) = pop(b)
+  return "{c.slots}"
+end
+"#;
+
+    assert_eq!(as_string(&STD_RINGBUFFER.run_main(source)), "[null, 20]");
+}
+
+#[test]
+fn e2e_ringbuffer_wraps_a_large_buffer_in_place() {
+    // A write that copied the 100000 slots would make the 600000 pushes and
+    // pops below take tens of billions of steps
+    let source = r#"
+import std.container.ringbuffer: new, push, pop, len, cap
+
+cell main() -> String
+  let n = 100000
+  var r = new(n)
+  for i in range(0, n)
+    let (next, _) = push(r, i)
+    r = next
+  end
+  # Each pop frees the front slot for the next push, so the tail goes
+  # round every slot twice more
+  var mismatches = 0
+  var rejected = 0
+  for i in range(n, 3 * n)
+    let (rest, item) = pop(r)
+    r = rest
+    let (next, took) = push(r, i)
+    r = next
+    if item != i - n
+      mismatches = mismatches + 1
+    end
+    if not took
+      rejected = rejected + 1
+    end
+  end
+  let full = "{len(r)}/{cap(r)}"
+  var sum = 0
+  while len(r) > 0
+    let (rest, item) = pop(r)
+    r = rest
+    sum = sum + (item ?? 0)
+  end
+  return "{full} {mismatches} {rejected} {sum}"
+end
+"#;
+
+    assert_eq!(
+        as_string(&STD_RINGBUFFER.run_main(source)),
+        "100000/100000 0 0 24999950000"
+    );
+}
@@ -0,0 +1,237 @@
+mod common;
+
+use std::thread;
+use std::time::Duration;
+
+use common::StdModule;
+use lumen_compiler::compile_raw_with_imports;
+use lumen_runtime::signal::Signal;
+use lumen_vm::values::Value;
+
+const STD_SIGNAL: StdModule = StdModule {
+    import: "std.os.signal",
+    path: "stdlib/std/os/signal.lm.md",
+};
+
+/// A `main` that counts for as long as it is left running.
+const FOREVER: &str = r#"
+cell main() -> Int
+  var i = 0
+  while i >= 0
+    i = i + 1
+  end
+  return i
+end
+"#;
+
+#[test]
+fn e2e_signal_handler_runs_at_the_next_call_and_the_run_continues() {
+    let mut vm = STD_SIGNAL.load(
+        r#"
+import std.os.signal: notify, raise, SIGINT
+
+cell work(n: Int) -> Int
+  var total = 0
+  for i in 0..n
+    total = total + i
+  end
+  return total
+end
+
+cell main() -> Int
+  notify(SIGINT(), fn(sig: String) => print("cleaning up after {sig}"))
+  print("before")
+  raise(SIGINT())
+  let total = work(100)
+  print("after {total}")
+  return total
+end
+"#,
+    );
+    let result = vm.execute("main", vec![]).expect("main should execute");
+    assert_eq!(result, Value::Int(4950));
+    assert_eq!(
+        vm.output,
+        vec!["before", "cleaning up after SIGINT", "after 4950"]
+    );
+}
+
+#[test]
+fn e2e_signal_handler_runs_when_raised_from_another_thread() {
+    let mut vm = STD_SIGNAL.load(&format!(
+        "{}\n{}",
+        r#"
+import std.os.signal: notify, SIGTERM
+
+cell on_term(sig: String) -> Null
+  print("flushing results on {sig}")
+  return null
+end
+"#,
+        FOREVER.replace(
+            "  var i = 0\n",
+            "  notify(SIGTERM(), fn(sig: String) => on_term(sig))\n  var i = 0\n"
+        )
+    ));
+    // The handled SIGTERM lets the loop go on; the unhandled SIGINT stops it
+    let handle = vm.interrupt_handle();
+    let sender = thread::spawn(move || {
+        thread::sleep(Duration::from_millis(50));
+        handle.raise(Signal::Terminate);
+        thread::sleep(Duration::from_millis(200));
+        handle.raise(Signal::Interrupt);
+    });
+    let err = vm
+        .execute("main", vec![])
+        .expect_err("SIGINT has no handler");
+    sender.join().expect("sender thread");
+    assert!(err.is_interrupted(), "{}", err);
+    assert_eq!(vm.output, vec!["flushing results on SIGTERM"]);
+}
+
+#[test]
+fn e2e_signal_unhandled_signals_stop_with_their_exit_codes() {
+    let mut vm = STD_SIGNAL.load(FOREVER);
+    vm.interrupt_handle().raise(Signal::Terminate);
+    let err = vm
+        .execute("main", vec![])
+        .expect_err("SIGTERM has no handler");
+    assert!(err.is_terminated(), "{}", err);
+    assert_eq!(err.signal().map(Signal::exit_code), Some(143));
+
+    vm.interrupt_handle().raise(Signal::Interrupt);
+    let err = vm
+        .execute("main", vec![])
+        .expect_err("SIGINT has no handler");
+    assert!(err.is_interrupted(), "{}", err);
+    assert_eq!(err.signal().map(Signal::exit_code), Some(130));
+
+    // The std.os.signal names agree with the codes `lumen run` exits with
+    let mut vm = STD_SIGNAL.load(
+        r#"
+import std.os.signal: exit_code, SIGINT, SIGTERM
+
+cell main() -> list[Int]
+  return [exit_code(SIGINT()), exit_code(SIGTERM())]
+end
+"#,
+    );
+    let codes = vm.execute("main", vec![]).expect("main should execute");
+    assert_eq!(
+        codes,
+        Value::new_list(vec![Value::Int(130), Value::Int(143)])
+    );
+}
+
+#[test]
+fn e2e_signal_reset_restores_the_default() {
+    let mut vm = STD_SIGNAL.load(
+        r#"
+import std.os.signal: notify, reset, raise, SIGTERM
+
+cell tick(label: String) -> Null
+  print(label)
+  return null
+end
+
+cell main() -> Null
+  notify(SIGTERM(), fn(sig: String) => print("handled {sig}"))
+  raise(SIGTERM())
+  tick("first")
+  reset(SIGTERM())
+  raise(SIGTERM())
+  tick("second")
+  return null
+end
+"#,
+    );
+    let err = vm
+        .execute("main", vec![])
+        .expect_err("the second SIGTERM has no handler");
+    assert!(err.is_terminated(), "{}", err);
+    assert_eq!(vm.output, vec!["handled SIGTERM", "first"]);
+}
+
+#[test]
+fn e2e_signal_raised_inside_a_handler_waits_for_it_to_return() {
+    let mut vm = STD_SIGNAL.load(
+        r#"
+import std.os.signal: notify, raise, SIGINT, SIGTERM
+
+cell tick(label: String) -> Null
+  print(label)
+  return null
+end
+
+cell cleanup(sig: String) -> Null
+  tick("cleanup for {sig} starts")
+  raise(SIGINT())
+  tick("cleanup for {sig} ends")
+  return null
+end
+
+cell main() -> Null
+  notify(SIGTERM(), fn(sig: String) => cleanup(sig))
+  notify(SIGINT(), fn(sig: String) => print("handled {sig}"))
+  raise(SIGTERM())
+  tick("done")
+  return null
+end
+"#,
+    );
+    vm.execute("main", vec![]).expect("main should execute");
+    assert_eq!(
+        vm.output,
+        vec![
+            "cleanup for SIGTERM starts",
+            "cleanup for SIGTERM ends",
+            "handled SIGINT",
+            "done"
+        ]
+    );
+}
+
+#[test]
+fn e2e_signal_unknown_name_is_an_error() {
+    let mut vm = STD_SIGNAL.load(
+        r#"
+import std.os.signal: notify
+
+cell main() -> Null
+  notify("SIGKILL", fn(sig: String) => print(sig))
+  return null
+end
+"#,
+    );
+    let err = vm
+        .execute("main", vec![])
+        .expect_err("SIGKILL cannot be handled");
+    assert!(
+        err.message_contains("unknown signal \"SIGKILL\""),
+        "{}",
+        err
+    );
+}
+
+#[test]
+fn e2e_signal_handlers_are_dropped_by_load() {
+    let source = r#"
+import std.os.signal: notify, SIGINT
+
+cell main() -> Null
+  notify(SIGINT(), fn(sig: String) => print("handled {sig}"))
+  return null
+end
+"#;
+    let mut vm = STD_SIGNAL.load(source);
+    vm.execute("main", vec![]).expect("main should execute");
+
+    let module = compile_raw_with_imports(FOREVER, &|_| None).expect("FOREVER should compile");
+    vm.load(module);
+    vm.interrupt_handle().interrupt();
+    let err = vm
+        .execute("main", vec![])
+        .expect_err("the new module installed no handler");
+    assert!(err.is_interrupted(), "{}", err);
+    assert!(vm.output.is_empty(), "{:?}", vm.output);
+}
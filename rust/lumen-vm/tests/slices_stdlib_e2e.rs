@@ -0,0 +1,77 @@
+mod common;
+
+use common::{as_string, StdModule};
+
+const STD_SLICES: StdModule = StdModule {
+    import: "std.slices",
+    path: "stdlib/std/slices.lm.md",
+};
+
+#[test]
+fn e2e_slices_over_ints() {
+    let source = r#"
+import std.slices: map, filter, reduce, contains, index_of, reverse
+
+cell main() -> String
+  let xs = [3, 1, 4, 1, 5]
+  let doubled = map(xs, fn(x: Int) => x * 2)
+  let odd = filter(xs, fn(x: Int) => x % 2 == 1)
+  let total = reduce(xs, 100, fn(acc: Int, x: Int) => acc + x)
+  let parts = [
+    join(map(doubled, fn(x: Int) => string(x)), ","),
+    join(map(odd, fn(x: Int) => string(x)), ","),
+    string(total),
+    string(contains(xs, 4)),
+    string(contains(xs, 9)),
+    string(index_of(xs, 1)),
+    string(index_of(xs, 9)),
+    join(map(reverse(xs), fn(x: Int) => string(x)), ",")
+  ]
+  return join(parts, " | ")
+end
+"#;
+
+    assert_eq!(
+        as_string(&STD_SLICES.run_main(source)),
+        "6,2,8,2,10 | 3,1,1,5 | 114 | true | false | 1 | -1 | 5,1,4,1,3"
+    );
+}
+
+#[test]
+fn e2e_slices_over_strings() {
+    let source = r#"
+import std.slices: map, filter, reduce, index_of, reverse
+
+cell main() -> String
+  let words = ["alpha", "be", "gamma", "pi"]
+  let upper_words = map(words, fn(w: String) => upper(w))
+  let short = filter(words, fn(w: String) => len(w) <= 2)
+  let joined = reduce(words, ">", fn(acc: String, w: String) => acc + slice(w, 0, 1))
+  let pos = index_of(words, "gamma")
+  return join(upper_words, ",") + " " + join(short, ",") + " " + joined + " " + string(pos) + " " + join(reverse(words), ",")
+end
+"#;
+
+    assert_eq!(
+        as_string(&STD_SLICES.run_main(source)),
+        "ALPHA,BE,GAMMA,PI be,pi >abgp 2 pi,gamma,be,alpha"
+    );
+}
+
+#[test]
+fn e2e_slices_empty_inputs() {
+    let source = r#"
+import std.slices: map, filter, reduce, contains, index_of, reverse
+
+cell main() -> String
+  let empty: list[Int] = []
+  let mapped = map(empty, fn(x: Int) => x + 1)
+  let kept = filter(empty, fn(x: Int) => true)
+  let total = reduce(empty, 42, fn(acc: Int, x: Int) => acc + x)
+  let reversed = reverse(empty)
+  return "{len(mapped)}:{len(kept)}:{total}:{contains(empty, 1)}:{index_of(empty, 1)}:{len(reversed)}"
+end
+"#;
+
+    assert_eq!(as_string(&STD_SLICES.run_main(source)), "0:0:42:false:-1:0");
+}
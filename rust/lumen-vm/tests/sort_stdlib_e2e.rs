@@ -0,0 +1,68 @@
+mod common;
+
+use common::{as_string, StdModule};
+
+const STD_SORT: StdModule = StdModule {
+    import: "std.sort",
+    path: "stdlib/std/sort.lm.md",
+};
+
+#[test]
+fn e2e_sort_floats_places_nans_last() {
+    // `zero / zero` is computed at runtime, where it yields a NaN with the
+    // sign bit set; it must still sort after every number.
+    let source = r#"
+import std.sort: floats, floats_desc, floats_are_sorted
+
+cell nan_of(x: Float) -> Float
+  return x / x
+end
+
+cell show(xs: list[Float]) -> String
+  let mut parts = []
+  for x in xs
+    parts = append(parts, string(x))
+  end
+  return join(parts, " ")
+end
+
+cell main() -> String
+  let zero = 0.0
+  let xs = [3.5, NAN, -1.0, nan_of(zero), 0.0, -0.0, INFINITY, -INFINITY, 2.0]
+  let up = floats(xs)
+  let down = floats_desc(xs)
+  return join([show(up), show(down), string(floats_are_sorted(up)), string(floats_are_sorted(xs)), string(floats_are_sorted(down))], "\n")
+end
+"#;
+
+    assert_eq!(
+        as_string(&STD_SORT.run_main(source)),
+        "-inf -1.0 -0.0 0.0 2.0 3.5 inf NaN NaN\n\
+         inf 3.5 2.0 0.0 -0.0 -1.0 -inf NaN NaN\n\
+         true\n\
+         false\n\
+         false"
+    );
+}
+
+#[test]
+fn e2e_nan_comparisons_are_false_and_total_order_helpers_are_consistent() {
+    let source = r#"
+import std.sort: compare_floats, less_floats
+
+cell main() -> String
+  let nan = NAN
+  let zero = 0.0
+  let computed = zero / zero
+  let ops = [nan == nan, nan != nan, computed == computed, nan < 1.0, nan > 1.0, nan <= nan, nan >= 1.0, -0.0 == zero]
+  let order = [compare_floats(nan, INFINITY), compare_floats(nan, nan), compare_floats(-0.0, zero), compare_floats(1.0, 1.0), compare_floats(-INFINITY, -1.0)]
+  let less = [less_floats(1.0, nan), less_floats(nan, 1.0), less_floats(nan, nan)]
+  return "{ops} {order} {less}"
+end
+"#;
+
+    assert_eq!(
+        as_string(&STD_SORT.run_main(source)),
+        "[false, true, false, false, false, false, false, true] [1, 0, -1, 0, -1] [true, false, false]"
+    );
+}
@@ -0,0 +1,124 @@
+//! `@specialize(Type)` on a generic cell compiles a copy for that type in
+//! which trait method calls go straight to the impl. Calls whose argument
+//! types are known use the copy; the rest use the generic cell.
+
+use lumen_compiler::compile;
+use lumen_compiler::compiler::lir::{Constant, LirCell, LirModule};
+use lumen_vm::values::Value;
+use lumen_vm::vm::VM;
+
+fn build(source: &str) -> LirModule {
+    let md = format!("# specialize-test\n\n```lumen\n{}\n```\n", source.trim());
+    compile(&md).expect("source should compile")
+}
+
+fn run_main(source: &str) -> Value {
+    let mut vm = VM::new();
+    vm.load(build(source));
+    vm.execute("main", vec![]).expect("main should execute")
+}
+
+fn cell<'m>(module: &'m LirModule, name: &str) -> &'m LirCell {
+    module
+        .cells
+        .iter()
+        .find(|c| c.name == name)
+        .unwrap_or_else(|| panic!("no cell named {}", name))
+}
+
+fn has_string(cell: &LirCell, s: &str) -> bool {
+    cell.constants
+        .iter()
+        .any(|k| matches!(k, Constant::String(v) if v == s))
+}
+
+const SORT: &str = r#"
+trait Ranked
+  cell before(self: Self, other: Self) -> Bool
+end
+
+record Card
+  rank: Int
+end
+
+impl Ranked for Int
+  cell before(self: Self, other: Self) -> Bool
+    return self < other
+  end
+end
+
+impl Ranked for Card
+  cell before(self: Self, other: Self) -> Bool
+    return self.rank < other.rank
+  end
+end
+
+@specialize(Int)
+cell sorted[T: Ranked](xs: list[T]) -> list[T]
+  var out: list[T] = []
+  for x in xs
+    var next: list[T] = []
+    var placed = false
+    for y in out
+      if not placed and x.before(y)
+        next = append(next, x)
+        placed = true
+      end
+      next = append(next, y)
+    end
+    if not placed
+      next = append(next, x)
+    end
+    out = next
+  end
+  return out
+end
+"#;
+
+#[test]
+fn specialized_copy_calls_impl_without_dispatch() {
+    let module = build(&format!(
+        "{}\n{}",
+        SORT,
+        r#"
+cell main() -> list[Int]
+  return sorted([3, 1, 2])
+end
+"#
+    ));
+    let copy = cell(&module, "sorted[Int]");
+    assert!(has_string(copy, "Int.before"), "copy should call Int.before");
+    assert!(
+        !has_string(copy, "before"),
+        "copy should not look `before` up on the receiver"
+    );
+    assert!(has_string(cell(&module, "sorted"), "before"));
+    assert!(has_string(cell(&module, "main"), "sorted[Int]"));
+}
+
+#[test]
+fn specialized_and_generic_paths_agree() {
+    let result = run_main(&format!(
+        "{}\n{}",
+        SORT,
+        r#"
+cell through_generic(xs) -> list[Int]
+  return sorted(xs)
+end
+
+cell main() -> String
+  let xs = [5, 3, 9, 1, 4, 1, 8]
+  let fast = sorted(xs)
+  let slow = through_generic(xs)
+  let cards = sorted([Card(rank: 2), Card(rank: 1)])
+  return to_string(fast) + " " + to_string(fast == slow) + " " + to_string(cards[0].rank)
+end
+"#
+    ));
+    assert_eq!(
+        result,
+        Value::String(lumen_vm::values::StringRef::Owned(
+            "[1, 1, 3, 4, 5, 8, 9] true 1".into()
+        ))
+    );
+}
@@ -0,0 +1,68 @@
+//! `VM::set_max_call_depth` bounds how deep a program may call; going past
+//! it stops the run with a stack overflow error and a condensed trace.
+
+use lumen_compiler::compile;
+use lumen_vm::values::Value;
+use lumen_vm::vm::{VmError, VM};
+
+fn markdown(source: &str) -> String {
+    format!(
+        "# stack-overflow-test\n\n```lumen\n{}\n```\n",
+        source.trim()
+    )
+}
+
+/// A cell whose recursion is not a tail call, so every level keeps a frame.
+const DEPTH: &str = r#"
+cell depth(n: Int) -> Int
+  if n == 0
+    return 0
+  end
+  return 1 + depth(n - 1)
+end
+"#;
+
+fn vm_with_limit(frames: usize) -> VM {
+    let mut vm = VM::new();
+    vm.set_max_call_depth(frames);
+    vm.load(compile(&markdown(DEPTH)).expect("source should compile"));
+    vm
+}
+
+#[test]
+fn recursion_past_the_limit_is_a_clean_stack_overflow() {
+    let mut vm = vm_with_limit(100);
+    let err = vm
+        .execute("depth", vec![Value::Int(500)])
+        .expect_err("500 levels should not fit in 100 frames");
+
+    assert!(err.is_stack_overflow(), "{}", err);
+    assert!(err.message_contains("stack overflow"), "{}", err);
+    let VmError::WithStackTrace {
+        frames,
+        stack_trace,
+        ..
+    } = &err
+    else {
+        panic!("expected a stack trace, got {:?}", err);
+    };
+    assert_eq!(frames.len(), 100);
+    assert!(frames.iter().all(|f| f.cell_name == "depth"));
+    // The repeated recursive frames are summed up rather than listed.
+    assert!(stack_trace.lines().count() < 10, "{}", stack_trace);
+    assert!(
+        stack_trace.contains("more frames of depth"),
+        "{}",
+        stack_trace
+    );
+}
+
+#[test]
+fn raising_the_limit_lets_the_recursion_finish() {
+    let mut vm = vm_with_limit(1000);
+    let result = vm
+        .execute("depth", vec![Value::Int(500)])
+        .expect("500 levels should fit in 1000 frames");
+    assert_eq!(result, Value::Int(500));
+    assert_eq!(vm.max_call_depth(), 1000);
+}
@@ -0,0 +1,83 @@
+use std::io::{self, Write};
+use std::sync::{Arc, Mutex};
+
+use lumen_vm::vm::VM;
+
+/// A stdout sink the test can inspect while the VM still owns its writer.
+#[derive(Clone, Default)]
+struct SharedSink(Arc<Mutex<Vec<u8>>>);
+
+impl Write for SharedSink {
+    fn write(&mut self, data: &[u8]) -> io::Result<usize> {
+        self.0.lock().unwrap().extend_from_slice(data);
+        Ok(data.len())
+    }
+    fn flush(&mut self) -> io::Result<()> {
+        Ok(())
+    }
+}
+
+impl SharedSink {
+    fn contents(&self) -> String {
+        String::from_utf8(self.0.lock().unwrap().clone()).unwrap()
+    }
+}
+
+fn vm_with_sink(source: &str, capacity: usize) -> (VM, SharedSink) {
+    let md = format!("# test\n\n```lumen\n{}\n```\n", source.trim());
+    let module = lumen_compiler::compile(&md).expect("source should compile");
+    let sink = SharedSink::default();
+    let mut vm = VM::new();
+    vm.set_stdout_sink(Box::new(sink.clone()), capacity);
+    vm.load(module);
+    (vm, sink)
+}
+
+#[test]
+fn e2e_print_is_buffered_until_flush() {
+    let (mut vm, sink) = vm_with_sink(
+        r#"
+cell main() -> Null
+  print("first")
+  print("second")
+  flush()
+  print("third")
+  return null
+end
+"#,
+        4096,
+    );
+
+    vm.execute("main", vec![]).expect("main should execute");
+    // Only the lines before the explicit flush have reached the sink.
+    assert_eq!(sink.contents(), "first\nsecond\n");
+
+    vm.flush_stdout().unwrap();
+    assert_eq!(sink.contents(), "first\nsecond\nthird\n");
+}
+
+#[test]
+fn e2e_buffered_output_is_not_lost_at_exit() {
+    let (mut vm, sink) = vm_with_sink(
+        r#"
+cell main() -> Int
+  for i in 0..1000
+    print("line {i}")
+  end
+  return 1000
+end
+"#,
+        256,
+    );
+
+    vm.execute("main", vec![]).expect("main should execute");
+    // The small capacity forces intermediate flushes, but the tail stays
+    // buffered until the VM goes away.
+    let partial = sink.contents();
+    assert!(!partial.is_empty());
+    assert!(!partial.ends_with("line 999\n"));
+
+    drop(vm);
+    let expected: String = (0..1000).map(|i| format!("line {}\n", i)).collect();
+    assert_eq!(sink.contents(), expected);
+}
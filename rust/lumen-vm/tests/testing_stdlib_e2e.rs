@@ -1,44 +1,17 @@
-use std::fs;
-use std::path::PathBuf;
+mod common;
 
-use lumen_compiler::{compile_raw_with_imports, compile_with_imports};
+use common::{as_string, StdModule};
 use lumen_vm::values::Value;
 use lumen_vm::vm::VM;
 
-fn std_testing_module_source() -> String {
-    let manifest_dir = PathBuf::from(env!("CARGO_MANIFEST_DIR"));
-    let testing_path = manifest_dir.join("../../stdlib/std/testing.lm.md");
-    fs::read_to_string(&testing_path)
-        .unwrap_or_else(|e| panic!("cannot read {}: {}", testing_path.display(), e))
-}
-
-fn resolve_std_testing(module: &str, testing_source: &str) -> Option<String> {
-    if module == "std.testing" {
-        Some(testing_source.to_string())
-    } else {
-        None
-    }
-}
+const STD_TESTING: StdModule = StdModule {
+    import: "std.testing",
+    path: "stdlib/std/testing.lm.md",
+};
 
 fn run_markdown_main_with_std_imports(source: &str) -> Value {
-    let testing_source = std_testing_module_source();
-    let module = compile_with_imports(source, &|module| {
-        resolve_std_testing(module, &testing_source)
-    })
-    .expect("markdown source should compile with std.testing");
     let mut vm = VM::new();
-    vm.load(module);
-    vm.execute("main", vec![]).expect("main should execute")
-}
-
-fn run_raw_main_with_std_imports(source: &str) -> Value {
-    let testing_source = std_testing_module_source();
-    let module = compile_raw_with_imports(source, &|module| {
-        resolve_std_testing(module, &testing_source)
-    })
-    .expect("raw source should compile with std.testing");
-    let mut vm = VM::new();
-    vm.load(module);
+    vm.load(STD_TESTING.compile_markdown(source));
     vm.execute("main", vec![]).expect("main should execute")
 }
 
@@ -51,7 +24,7 @@ fn e2e_testing_helpers_pass_in_markdown_program() {
 import std.testing: create_test_suite, add_test, assert_eq, assert_true, assert_contains, assert_not_contains, run_tests
 
 cell main() -> Bool / {emit}
-  let tests = create_test_suite()
+  var tests = create_test_suite()
   tests = add_test(tests, assert_eq(10 + 5, 15, "math works"))
   tests = add_test(tests, assert_true(3 < 7, "comparison works"))
   tests = add_test(tests, assert_contains([5, 6, 7], 6, "value exists"))
@@ -72,13 +45,138 @@ fn e2e_testing_helpers_report_failure_in_raw_program() {
 import std.testing: create_test_suite, add_test, assert_eq, summarize_tests
 
 cell main() -> Bool
-  let tests = create_test_suite()
+  var tests = create_test_suite()
   tests = add_test(tests, assert_eq(1, 2, "intentional failure"))
   let summary = summarize_tests(tests)
   return summary.all_passed
 end
 "#;
 
-    let result = run_raw_main_with_std_imports(source);
+    let result = STD_TESTING.run_main(source);
     assert_eq!(result, Value::Bool(false));
 }
+
+#[test]
+fn e2e_testing_table_driven_subtests_aggregate_results() {
+    let source = r#"
+import std.testing: T, new_t, run, error, fatal, helper, failed, passed_tests, failed_tests, report
+
+record Case
+  name: String
+  a: Int
+  b: Int
+  want: Int
+end
+
+cell check_add(t: T, c: Case) -> T
+  t = helper(t)
+  if c.want < 0
+    t = fatal(t, "want must be non-negative")
+  end
+  if c.a == 99
+    panic("unexpected operand")
+  end
+  if c.a + c.b != c.want
+    t = error(t, sprintf("add(%d, %d) = %d, want %d", c.a, c.b, c.a + c.b, c.want))
+    t = error(t, "first line\nsecond line")
+  end
+  return t
+end
+
+cell main() -> String
+  let cases = [
+    Case(name: "small values", a: 1, b: 2, want: 3),
+    Case(name: "carry", a: 5, b: 5, want: 11),
+    Case(name: "carry", a: 9, b: 1, want: 10),
+    Case(name: "negative", a: 1, b: 1, want: -1),
+    Case(name: "operand", a: 99, b: 0, want: 99)
+  ]
+  let mut t = new_t("TestAdd")
+  for c in cases
+    t = run(t, c.name, fn(st: T) -> T => check_add(st, c))
+  end
+  let passed = join(passed_tests(t), ",")
+  let failures = join(failed_tests(t), ",")
+  return "failed={failed(t)}\npassed={passed}\nfailures={failures}\n" + report(t)
+end
+"#;
+
+    let expected = [
+        "failed=true",
+        "passed=TestAdd/small_values,TestAdd/carry#01",
+        "failures=TestAdd/carry,TestAdd/negative,TestAdd/operand",
+        "=== RUN   TestAdd/small_values",
+        "--- PASS: TestAdd/small_values",
+        "=== RUN   TestAdd/carry",
+        "    TestAdd/carry: add(5, 5) = 10, want 11",
+        "    TestAdd/carry: first line",
+        "        second line",
+        "--- FAIL: TestAdd/carry",
+        "=== RUN   TestAdd/carry#01",
+        "--- PASS: TestAdd/carry#01",
+        "=== RUN   TestAdd/negative",
+        "    TestAdd/negative: want must be non-negative",
+        "--- FAIL: TestAdd/negative",
+        "=== RUN   TestAdd/operand",
+        "    TestAdd/operand: panic: unexpected operand",
+        "--- FAIL: TestAdd/operand",
+    ];
+    assert_eq!(
+        as_string(&STD_TESTING.run_main(source)),
+        expected.join("\n")
+    );
+}
+
+#[test]
+fn e2e_testing_nested_subtests_propagate_failure_to_parents() {
+    let source = r#"
+import std.testing: T, new_t, run, error, failed, report
+
+cell leaf_ok(t: T) -> T
+  return t
+end
+
+cell leaf_bad(t: T) -> T
+  return error(t, "mismatch")
+end
+
+cell passing_group(t: T) -> T
+  t = run(t, "a", leaf_ok)
+  return run(t, "b", leaf_ok)
+end
+
+cell failing_group(t: T) -> T
+  t = run(t, "a", leaf_ok)
+  return run(t, "b", leaf_bad)
+end
+
+cell main() -> String
+  let mut t = new_t("TestGroups")
+  t = run(t, "good", passing_group)
+  let after_good = failed(t)
+  t = run(t, "bad", failing_group)
+  return "{after_good} {failed(t)}\n" + report(t)
+end
+"#;
+
+    let expected = [
+        "false true",
+        "=== RUN   TestGroups/good",
+        "=== RUN   TestGroups/good/a",
+        "--- PASS: TestGroups/good/a",
+        "=== RUN   TestGroups/good/b",
+        "--- PASS: TestGroups/good/b",
+        "--- PASS: TestGroups/good",
+        "=== RUN   TestGroups/bad",
+        "=== RUN   TestGroups/bad/a",
+        "--- PASS: TestGroups/bad/a",
+        "=== RUN   TestGroups/bad/b",
+        "    TestGroups/bad/b: mismatch",
+        "--- FAIL: TestGroups/bad/b",
+        "--- FAIL: TestGroups/bad",
+    ];
+    assert_eq!(
+        as_string(&STD_TESTING.run_main(source)),
+        expected.join("\n")
+    );
+}
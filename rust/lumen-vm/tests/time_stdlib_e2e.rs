@@ -0,0 +1,79 @@
+mod common;
+
+use common::{as_string, StdModule};
+use lumen_vm::values::Value;
+
+const STD_TIME: StdModule = StdModule {
+    import: "std.time",
+    path: "stdlib/std/time.lm.md",
+};
+
+#[test]
+fn e2e_time_now_is_monotonic() {
+    let source = r#"
+import std.time: now, since, as_nanos
+
+cell main() -> Bool
+  let mut prev = now()
+  let mut i = 0
+  while i < 1000
+    let next = now()
+    if as_nanos(since(next, prev)) < 0
+      return false
+    end
+    prev = next
+    i += 1
+  end
+  return true
+end
+"#;
+
+    assert_eq!(STD_TIME.run_main(source), Value::Bool(true));
+}
+
+#[test]
+fn e2e_time_sleep_measures_within_tolerance() {
+    let source = r#"
+import std.time: now, elapsed, milliseconds, sleep_for, as_millis
+
+cell main() -> Int
+  let start = now()
+  sleep_for(milliseconds(25))
+  return as_millis(elapsed(start))
+end
+"#;
+
+    let measured = match STD_TIME.run_main(source) {
+        Value::Int(ms) => ms,
+        other => panic!("expected Int, got {:?}", other),
+    };
+    // Sleeping never returns early; allow generous headroom for loaded CI hosts.
+    assert!(measured >= 25, "measured {}ms, expected >= 25ms", measured);
+    assert!(
+        measured < 2000,
+        "measured {}ms, expected < 2000ms",
+        measured
+    );
+}
+
+#[test]
+fn e2e_time_format_duration_picks_unit() {
+    let source = r#"
+import std.time: format_duration, nanoseconds, microseconds, milliseconds, seconds
+
+cell main() -> String
+  let parts = [
+    format_duration(nanoseconds(850)),
+    format_duration(nanoseconds(12500)),
+    format_duration(microseconds(3250)),
+    format_duration(milliseconds(1500)),
+    format_duration(seconds(2)),
+    format_duration(nanoseconds(0 - 1000))
+  ]
+  return join(parts, ",")
+end
+"#;
+
+    let result = as_string(&STD_TIME.run_main(source));
+    assert_eq!(result, "850ns,12.5µs,3.25ms,1.5s,2s,-1µs");
+}
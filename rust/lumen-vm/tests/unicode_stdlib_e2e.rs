@@ -0,0 +1,79 @@
+mod common;
+
+use common::{owned, StdModule};
+use lumen_vm::values::Value;
+
+const STD_UNICODE: StdModule = StdModule {
+    import: "std.unicode",
+    path: "stdlib/std/unicode.lm.md",
+};
+
+/// A program marking each rune of `text` with `flag` where `classify`
+/// (one of the std.unicode classifiers) holds and `.` elsewhere.
+fn classify_source(classify: &str, flag: &str, text: &str) -> String {
+    format!(
+        r#"
+import std.unicode: {classify}
+
+cell main() -> String
+  var out = ""
+  for r in runes("{text}")
+    if {classify}(r)
+      out = out + "{flag}"
+    else
+      out = out + "."
+    end
+  end
+  return out
+end
+"#
+    )
+}
+
+#[test]
+fn e2e_unicode_is_digit_accepts_decimal_digits_in_any_script() {
+    // ASCII, Arabic-Indic three, Devanagari nine, fullwidth zero; then a
+    // letter, superscript two, and Roman numeral four, which are not Nd
+    let source = classify_source("is_digit", "d", "09٣९０a²Ⅳ");
+    assert_eq!(STD_UNICODE.run_main(&source), owned("ddddd..."));
+}
+
+#[test]
+fn e2e_unicode_is_letter_accepts_letters_in_any_script() {
+    let source = classify_source("is_letter", "l", "aZ1_éЖλ語");
+    assert_eq!(STD_UNICODE.run_main(&source), owned("ll..llll"));
+}
+
+#[test]
+fn e2e_unicode_is_space_accepts_unicode_white_space() {
+    // space, tab, newline, no-break space, ideographic space; then a
+    // letter and a zero-width space, which is not White_Space
+    let source = classify_source("is_space", "s", " \\t\\n\u{a0}\u{3000}x\u{200b}");
+    assert_eq!(STD_UNICODE.run_main(&source), owned("sssss.."));
+}
+
+#[test]
+fn e2e_unicode_case_conversion_maps_rune_to_rune() {
+    let source = r#"
+import std.unicode: to_upper, to_lower
+
+cell main() -> String
+  let up = from_runes([to_upper(runes("a")[0]), to_upper(runes("é")[0]), to_upper(runes("ж")[0]), to_upper(runes("ß")[0]), to_upper(runes("1")[0])])
+  let down = from_runes([to_lower(runes("Q")[0]), to_lower(runes("Σ")[0]), to_lower(runes("Ж")[0]), to_lower(runes("語")[0])])
+  return up + " " + down + " " + to_string(to_upper(-1))
+end
+"#;
+    assert_eq!(STD_UNICODE.run_main(source), owned("AÉЖß1 qσж語 -1"));
+}
+
+#[test]
+fn e2e_unicode_classifiers_reject_non_code_points() {
+    let source = r#"
+import std.unicode: is_digit, is_letter, is_space
+
+cell main() -> Bool
+  return is_digit(-1) or is_letter(55296) or is_space(1114112)
+end
+"#;
+    assert_eq!(STD_UNICODE.run_main(source), Value::Bool(false));
+}
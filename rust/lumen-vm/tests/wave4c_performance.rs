@@ -217,7 +217,7 @@ cell add_ten(x: Int) -> Int
 end
 
 cell main() -> Int
-  let sum = 0
+  var sum = 0
   for i in [1, 2, 3]
     sum = sum + add_ten(i)
   end
@@ -380,7 +380,7 @@ fn t393_large_function_50_locals() {
     }
 
     // Create a sum using some of them
-    source.push_str("  let total = v0 + v1 + v2 + v3 + v4 + v5 + v6 + v7 + v8 + v9\n");
+    source.push_str("  var total = v0 + v1 + v2 + v3 + v4 + v5 + v6 + v7 + v8 + v9\n");
     source
         .push_str("  total = total + v10 + v11 + v12 + v13 + v14 + v15 + v16 + v17 + v18 + v19\n");
 
@@ -403,7 +403,7 @@ fn t393_deeply_nested_function() {
     let result = run_main(
         r#"
 cell main() -> Int
-  let x = 0
+  var x = 0
   if true
     if true
       if true
@@ -447,7 +447,7 @@ fn t393_large_function_with_helper_cells() {
 
     // Main cell calls all helpers
     source.push_str("cell main() -> Int\n");
-    source.push_str("  let total = 0\n");
+    source.push_str("  var total = 0\n");
     for i in 0..30 {
         source.push_str(&format!("  total = total + helper_{}(1)\n", i));
     }
@@ -468,7 +468,7 @@ fn t393_large_function_with_helper_cells() {
 fn t393_register_limit_exceeded_is_reported() {
     // Verify that exceeding 255 registers produces a clear error
     let mut source = String::from("cell main() -> Int\n");
-    source.push_str("  let total = 0\n");
+    source.push_str("  var total = 0\n");
 
     // Generate enough statements to exceed 255 registers
     for i in 0..100 {
@@ -645,8 +645,8 @@ end
     assert!(err.is_err());
     let msg = err.unwrap_err();
     assert!(
-        msg.contains("index") && msg.contains("out of bounds"),
-        "should mention index out of bounds, got: {}",
+        msg.contains("index 10 out of range [0:3]"),
+        "should mention index out of range, got: {}",
         msg
     );
     // Should include stack trace info with cell name
@@ -704,7 +704,7 @@ end
     assert!(err.is_err());
     let msg = err.unwrap_err();
     assert!(
-        msg.contains("index") && msg.contains("out of bounds"),
+        msg.contains("index 99 out of range [0:2]"),
         "should mention index error, got: {}",
         msg
     );
@@ -731,7 +731,7 @@ end
     let msg = err.unwrap_err();
     // Should have a specific, actionable message
     assert!(
-        msg.contains("index 0 out of bounds") || msg.contains("out of bounds for list of length 0"),
+        msg.contains("index 0 out of range [0:0]"),
         "should specify what went wrong, got: {}",
         msg
     );
@@ -787,8 +787,8 @@ end
     assert!(err.is_err());
     let msg = err.unwrap_err();
     assert!(
-        msg.contains("out of bounds"),
-        "negative index out of bounds should be reported, got: {}",
+        msg.contains("index -5 out of range [-3:3]"),
+        "negative index out of range should be reported, got: {}",
         msg
     );
 }